@@ -43,6 +43,66 @@ func main() {
 	services.StartExpirationWorker(database.GetDB(), minutosExpiracion, 5)
 	log.Printf("OK. Worker de expiración de compras iniciado (%d minutos)", minutosExpiracion)
 
+	// Iniciar worker de recordatorios de saldo (reservas con anticipo)
+	diasRecordatorioSaldo, err := strconv.Atoi(cfg.SaldoRecordatorioDias)
+	if err != nil || diasRecordatorioSaldo < 0 {
+		diasRecordatorioSaldo = 3
+	}
+	diasGraciaSaldo, err := strconv.Atoi(cfg.SaldoGraciaDias)
+	if err != nil || diasGraciaSaldo < 0 {
+		diasGraciaSaldo = 2
+	}
+	services.StartSaldoReminderWorker(database.GetDB(), diasRecordatorioSaldo, diasGraciaSaldo, 60)
+	log.Printf("OK. Worker de recordatorios de saldo iniciado (%d días, cancela %d días después del vencimiento)", diasRecordatorioSaldo, diasGraciaSaldo)
+
+	// Iniciar worker de series de salidas recurrentes
+	services.StartSalidaSerieWorker(database.GetDB(), 60)
+	log.Println("OK. Worker de series de salidas iniciado")
+
+	// Iniciar worker de cupo mínimo (cierre de inscripciones de salidas compartidas)
+	services.StartQuorumWorker(database.GetDB(), 15)
+	log.Println("OK. Worker de cupo mínimo de salidas iniciado")
+
+	// Iniciar worker del libro de comisiones (sincronización de ventas y liquidaciones mensuales)
+	services.StartComisionWorker(database.GetDB(), 30)
+	log.Println("OK. Worker de comisiones iniciado")
+
+	// Huellas de los comprobantes subidos antes de la detección de reutilización
+	go func() {
+		actualizados, err := services.NewComprobanteService(database.GetDB()).RegistrarHuellasPendientes()
+		if err != nil {
+			log.Printf("Error al calcular huellas de comprobantes: %v", err)
+			return
+		}
+		if actualizados > 0 {
+			log.Printf("OK. Huellas calculadas para %d comprobantes anteriores", actualizados)
+		}
+	}()
+
+	// Pasarela de pago simulada para desarrollo: solo con PASARELA_MOCK=true y un secreto propio, nunca en producción
+	var pasarelaMock *services.MockPaymentProvider
+	if strings.ToLower(strings.TrimSpace(os.Getenv("PASARELA_MOCK"))) == "true" {
+		secreto := strings.TrimSpace(os.Getenv("PASARELA_MOCK_SECRETO"))
+		switch {
+		case strings.ToLower(strings.TrimSpace(cfg.AppEnv)) == "production":
+			log.Println("Warning: PASARELA_MOCK ignorado con APP_ENV=production")
+		case secreto == "":
+			log.Println("Warning: PASARELA_MOCK requiere PASARELA_MOCK_SECRETO; pasarela simulada deshabilitada")
+		default:
+			baseURL := strings.TrimSpace(os.Getenv("PUBLIC_API_URL"))
+			if baseURL == "" {
+				baseURL = fmt.Sprintf("http://%s:%s", cfg.ServerHost, cfg.ServerPort)
+			}
+			demora, err := strconv.Atoi(os.Getenv("PASARELA_MOCK_DEMORA_SEGUNDOS"))
+			if err != nil || demora < 0 {
+				demora = 30
+			}
+			pasarelaMock = services.NewMockPaymentProvider(database.GetDB(), baseURL, secreto, time.Duration(demora)*time.Second)
+			services.RegistrarProveedorPago(pasarelaMock)
+			log.Println("OK. Pasarela de pago simulada habilitada")
+		}
+	}
+
 	// Iniciar WebSocket Hub
 	hub := websocket.NewHub()
 	go hub.Run()
@@ -80,6 +140,29 @@ func main() {
 	notificacionHandler := handlers.NewNotificacionHandler()
 	wsHandler := handlers.NewWebSocketHandler(hub)
 	salidaHandler := handlers.NewSalidaHandler()
+	resenaHandler := handlers.NewResenaHandler()
+	comisionHandler := handlers.NewComisionHandler()
+
+	// Cotización pública (sin caché: refleja la disponibilidad real de cupos).
+	// Se registra antes del subrouter /public para que no pase por CacheMiddleware.
+	api.Handle("/public/paquetes/{id:[0-9]+}/cotizar",
+		middleware.RateLimitMiddleware(100)(http.HandlerFunc(compraHandler.CotizarPaquetePublico))).Methods("GET")
+
+	// Calendarios .ics con token secreto (sin caché: los clientes los consultan periódicamente)
+	api.Handle("/public/calendario/agencias/{token:[a-f0-9]+}.ics",
+		middleware.RateLimitMiddleware(60)(http.HandlerFunc(agenciaHandler.GetCalendarioAgenciaICS))).Methods("GET")
+	api.Handle("/public/calendario/turistas/{token:[a-f0-9]+}.ics",
+		middleware.RateLimitMiddleware(60)(http.HandlerFunc(compraHandler.GetCalendarioTuristaICS))).Methods("GET")
+
+	// Webhooks de pago de pasarelas y QR bancario (firma verificada por proveedor)
+	api.Handle("/webhooks/pagos/{proveedor:[a-zA-Z0-9_-]+}",
+		middleware.RateLimitMiddleware(120)(http.HandlerFunc(pagoHandler.WebhookPago))).Methods("POST")
+
+	if pasarelaMock != nil {
+		pasarelaMockHandler := handlers.NewPasarelaMockHandler(pasarelaMock)
+		api.HandleFunc("/pasarela-mock/checkout/{referencia}", pasarelaMockHandler.GetCheckout).Methods("GET")
+		api.HandleFunc("/pasarela-mock/checkout/{referencia}", pasarelaMockHandler.PostCheckout).Methods("POST")
+	}
 
 	// ========== RUTAS PÚBLICAS (sin autenticación) ==========
 	// Aplicar rate limiting (100 requests/minuto) y caché (5 minutos)
@@ -91,12 +174,14 @@ func main() {
 	publicAPI.HandleFunc("/paquetes", agenciaHandler.GetPaquetesPublicos).Methods("GET")
 	publicAPI.HandleFunc("/paquetes/{id:[0-9]+}", agenciaHandler.GetPaquetePublico).Methods("GET")
 	publicAPI.HandleFunc("/paquetes/{id:[0-9]+}/salidas", agenciaHandler.GetPaqueteSalidasPublicas).Methods("GET")
+	publicAPI.HandleFunc("/paquetes/{id:[0-9]+}/resenas", resenaHandler.GetResenasPaquetePublico).Methods("GET")
 	publicAPI.HandleFunc("/salidas-confirmadas", agenciaHandler.GetSalidasConfirmadasPublicas).Methods("GET")
 	publicAPI.HandleFunc("/salidas-disponibles", salidaHandler.ObtenerSalidasPublicas).Methods("GET")
 
 	// Agencias públicas
 	publicAPI.HandleFunc("/agencias", agenciaHandler.GetAgencias).Methods("GET")
 	publicAPI.HandleFunc("/agencias/{id:[0-9]+}", agenciaHandler.GetAgencia).Methods("GET")
+	publicAPI.HandleFunc("/agencias/{id:[0-9]+}/resenas", resenaHandler.GetResenasAgenciaPublico).Methods("GET")
 	// Agencias por slug (público, con caché) - Excluye palabras reservadas
 	publicAPI.HandleFunc("/agencias/{id:[a-zA-Z0-9-]+}", agenciaHandler.GetAgencia).Methods("GET")
 	// Registrar visitas (público, sin caché porque es POST)
@@ -174,6 +259,9 @@ func main() {
 	protected.HandleFunc("/agencias/{id:[0-9]+}/reportes/ocupacion", agenciaHandler.GetAgenciaReporteOcupacion).Methods("GET")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/reportes/financiero", agenciaHandler.GetAgenciaReporteFinanciero).Methods("GET")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/reportes/turistas", agenciaHandler.GetAgenciaReporteTuristas).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/comisiones", agenciaHandler.GetAgenciaComisiones).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/liquidaciones", agenciaHandler.GetAgenciaLiquidaciones).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/liquidaciones/{liquidacion_id:[0-9]+}", agenciaHandler.GetAgenciaLiquidacion).Methods("GET")
 	protected.HandleFunc("/agencias/{id:[0-9]+}", agenciaHandler.UpdateAgencia).Methods("PUT")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/fotos/upload", agenciaHandler.UploadAgenciaFoto).Methods("POST")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/fotos/{foto_id:[0-9]+}", agenciaHandler.RemoveFotoWithFile).Methods("DELETE")
@@ -186,9 +274,74 @@ func main() {
 	protected.HandleFunc("/agencias/{id:[0-9]+}/datos-pago/qr/upload", agenciaHandler.UploadAgenciaDatosPagoQrFoto).Methods("POST")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/capacidad", agenciaHandler.GetAgenciaCapacidad).Methods("GET")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/capacidad", agenciaHandler.UpdateAgenciaCapacidad).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/promociones", agenciaHandler.GetAgenciaPromociones).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/promociones", agenciaHandler.CreateAgenciaPromocion).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/promociones/{promocion_id:[0-9]+}", agenciaHandler.UpdateAgenciaPromocion).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/promociones/{promocion_id:[0-9]+}", agenciaHandler.DeleteAgenciaPromocion).Methods("DELETE")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/reglas-descuento", agenciaHandler.GetAgenciaReglasDescuento).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/reglas-descuento", agenciaHandler.CreateAgenciaReglaDescuento).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/reglas-descuento/{regla_id:[0-9]+}", agenciaHandler.UpdateAgenciaReglaDescuento).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/reglas-descuento/{regla_id:[0-9]+}", agenciaHandler.DeleteAgenciaReglaDescuento).Methods("DELETE")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/politica-reembolso", agenciaHandler.GetAgenciaPoliticaReembolso).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/politica-reembolso", agenciaHandler.UpdateAgenciaPoliticaReembolso).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/reembolsos", agenciaHandler.GetAgenciaReembolsos).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/reembolsos/{reembolso_id:[0-9]+}/pagar", agenciaHandler.PagarAgenciaReembolso).Methods("PUT")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/ventas/pagos", agenciaHandler.GetAgenciaVentasPagos).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/ventas/pagos/{pago_id:[0-9]+}/reportar-comprobante", agenciaHandler.ReportarAgenciaVentaPagoComprobante).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/formatos-extracto", agenciaHandler.GetAgenciaFormatosExtracto).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/formatos-extracto", agenciaHandler.CreateAgenciaFormatoExtracto).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/formatos-extracto/{formato_id:[0-9]+}", agenciaHandler.UpdateAgenciaFormatoExtracto).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/formatos-extracto/{formato_id:[0-9]+}", agenciaHandler.DeleteAgenciaFormatoExtracto).Methods("DELETE")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/extractos", agenciaHandler.GetAgenciaExtractos).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/extractos", agenciaHandler.ImportarAgenciaExtracto).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/extractos/{extracto_id:[0-9]+}", agenciaHandler.GetAgenciaExtracto).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/extractos/{extracto_id:[0-9]+}/conciliar", agenciaHandler.ConciliarAgenciaExtracto).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/extractos/{extracto_id:[0-9]+}/confirmar", agenciaHandler.ConfirmarAgenciaExtracto).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/extractos/{extracto_id:[0-9]+}/pendientes", agenciaHandler.GetAgenciaExtractoPendientes).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/extractos/{extracto_id:[0-9]+}/lineas/{linea_id:[0-9]+}/ignorar", agenciaHandler.IgnorarAgenciaExtractoLinea).Methods("POST")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/ventas/salidas", agenciaHandler.GetAgenciaVentasSalidas).Methods("GET")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/ventas/salidas/{salida_id:[0-9]+}/compras", agenciaHandler.GetAgenciaVentasSalidaCompras).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/ventas/salidas/{salida_id:[0-9]+}/participantes", agenciaHandler.GetAgenciaVentasSalidaParticipantes).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/ventas/salidas/{salida_id:[0-9]+}/lista-espera", agenciaHandler.GetAgenciaVentasSalidaListaEspera).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/asistencia", agenciaHandler.GetAgenciaSalidaAsistencia).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/check-in", agenciaHandler.CheckInAgenciaSalida).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/iniciar", agenciaHandler.IniciarAgenciaSalida).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/completar", agenciaHandler.CompletarAgenciaSalida).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/guias", agenciaHandler.GetAgenciaSalidaGuias).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/guias", agenciaHandler.AsignarAgenciaSalidaGuias).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/guias", agenciaHandler.GetAgenciaGuias).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/guias", agenciaHandler.CreateAgenciaGuia).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/guias/{guia_id:[0-9]+}", agenciaHandler.UpdateAgenciaGuia).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/guias/{guia_id:[0-9]+}", agenciaHandler.DeleteAgenciaGuia).Methods("DELETE")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/guias/{guia_id:[0-9]+}/calendario", agenciaHandler.GetAgenciaGuiaCalendario).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/calendario/feed", agenciaHandler.GetAgenciaCalendarioFeed).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/calendario/feed/regenerar", agenciaHandler.RegenerarAgenciaCalendarioFeed).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/recursos", agenciaHandler.GetAgenciaSalidaRecursos).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/salidas/{salida_id:[0-9]+}/recursos", agenciaHandler.AsignarAgenciaSalidaRecursos).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/recursos", agenciaHandler.GetAgenciaRecursos).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/recursos", agenciaHandler.CreateAgenciaRecurso).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/recursos/disponibilidad", agenciaHandler.GetAgenciaRecursosDisponibilidad).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/recursos/{recurso_id:[0-9]+}", agenciaHandler.UpdateAgenciaRecurso).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/recursos/{recurso_id:[0-9]+}", agenciaHandler.DeleteAgenciaRecurso).Methods("DELETE")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/recursos/{recurso_id:[0-9]+}/mantenimientos", agenciaHandler.CreateAgenciaRecursoMantenimiento).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/recursos/{recurso_id:[0-9]+}/mantenimientos/{mantenimiento_id:[0-9]+}", agenciaHandler.DeleteAgenciaRecursoMantenimiento).Methods("DELETE")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/series-salidas", agenciaHandler.GetAgenciaSalidaSeries).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/series-salidas", agenciaHandler.CreateAgenciaSalidaSerie).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/series-salidas/{serie_id:[0-9]+}", agenciaHandler.GetAgenciaSalidaSerie).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/series-salidas/{serie_id:[0-9]+}", agenciaHandler.UpdateAgenciaSalidaSerie).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/series-salidas/{serie_id:[0-9]+}/generar", agenciaHandler.GenerarAgenciaSalidaSerie).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/series-salidas/{serie_id:[0-9]+}/cancelar", agenciaHandler.CancelarAgenciaSalidaSerie).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/fechas-bloqueadas", agenciaHandler.GetAgenciaFechasBloqueadas).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/fechas-bloqueadas", agenciaHandler.CreateAgenciaFechaBloqueada).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/fechas-bloqueadas/{bloqueo_id:[0-9]+}", agenciaHandler.DeleteAgenciaFechaBloqueada).Methods("DELETE")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/ventas/compras/{compra_id:[0-9]+}/modificaciones", agenciaHandler.GetAgenciaVentaCompraModificaciones).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/resenas", resenaHandler.GetAgenciaResenas).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/solicitudes-privadas", agenciaHandler.GetAgenciaSolicitudesPrivadas).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/solicitudes-privadas/{solicitud_id:[0-9]+}", agenciaHandler.GetAgenciaSolicitudPrivada).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/solicitudes-privadas/{solicitud_id:[0-9]+}/cotizar", agenciaHandler.CotizarAgenciaSolicitudPrivada).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/solicitudes-privadas/{solicitud_id:[0-9]+}/mensajes", agenciaHandler.EnviarMensajeAgenciaSolicitudPrivada).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/solicitudes-privadas/{solicitud_id:[0-9]+}/rechazar", agenciaHandler.RechazarAgenciaSolicitudPrivada).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/resenas/{resena_id:[0-9]+}/respuesta", resenaHandler.ResponderAgenciaResena).Methods("POST")
 
 	// Estadísticas de visitas (solo para encargado de la agencia o admin)
 	protected.HandleFunc("/agencias/{id:[0-9]+}/estadisticas-visitas", agenciaVisitasHandler.GetEstadisticasVisitas).Methods("GET")
@@ -217,6 +370,12 @@ func main() {
 	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/atracciones/{paquete_atraccion_id:[0-9]+}", agenciaHandler.UpdatePaqueteAtraccion).Methods("PUT")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/atracciones/{paquete_atraccion_id:[0-9]+}", agenciaHandler.RemovePaqueteAtraccion).Methods("DELETE")
 
+	// Precios por temporada del paquete
+	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/temporadas-precio", agenciaHandler.GetPaqueteTemporadasPrecio).Methods("GET")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/temporadas-precio", agenciaHandler.CreatePaqueteTemporadaPrecio).Methods("POST")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/temporadas-precio/{temporada_id:[0-9]+}", agenciaHandler.UpdatePaqueteTemporadaPrecio).Methods("PUT")
+	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/temporadas-precio/{temporada_id:[0-9]+}", agenciaHandler.DeletePaqueteTemporadaPrecio).Methods("DELETE")
+
 	// Salidas habilitadas (edición logística/estado)
 	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/salidas", agenciaHandler.GetPaqueteSalidas).Methods("GET")
 	protected.HandleFunc("/agencias/{id:[0-9]+}/paquetes/{paquete_id:[0-9]+}/salidas", agenciaHandler.CreatePaqueteSalida).Methods("POST")
@@ -227,17 +386,57 @@ func main() {
 	// Nuevas rutas para crear y gestionar salidas manualmente
 	protected.HandleFunc("/agencias/paquetes/{paquete_id:[0-9]+}/salidas-manuales", salidaHandler.CrearSalidaManual).Methods("POST")
 	protected.HandleFunc("/agencias/paquetes/{paquete_id:[0-9]+}/salidas-manuales", salidaHandler.ObtenerSalidasPorPaquete).Methods("GET")
+	protected.HandleFunc("/agencias/paquetes/{paquete_id:[0-9]+}/salidas-manuales/importar", salidaHandler.ImportarSalidasICS).Methods("POST")
 	protected.HandleFunc("/agencias/salidas/{salida_id:[0-9]+}", salidaHandler.ActualizarSalida).Methods("PUT")
 	protected.HandleFunc("/agencias/salidas/{salida_id:[0-9]+}/cancelar", salidaHandler.CancelarSalida).Methods("POST")
+	protected.HandleFunc("/agencias/salidas/{salida_id:[0-9]+}/reprogramar", salidaHandler.ReprogramarSalida).Methods("POST")
+	protected.HandleFunc("/agencias/salidas/{salida_id:[0-9]+}/reprogramaciones", salidaHandler.ObtenerReprogramacionesSalida).Methods("GET")
 
 	// ========== COMPRAS DE PAQUETES (Turista) ==========
 	protected.HandleFunc("/compras", compraHandler.CrearCompra).Methods("POST")
 	protected.HandleFunc("/compras/{id:[0-9]+}", compraHandler.ObtenerDetalleCompra).Methods("GET")
+	protected.HandleFunc("/compras/{id:[0-9]+}/voucher.pdf", compraHandler.DescargarVoucher).Methods("GET")
 	protected.HandleFunc("/compras/{id:[0-9]+}/cancelar", compraHandler.CancelarCompra).Methods("POST")
+	protected.HandleFunc("/compras/{id:[0-9]+}/cancelacion", compraHandler.PreviewCancelacion).Methods("GET")
+	protected.HandleFunc("/compras/{id:[0-9]+}/participantes", compraHandler.ActualizarParticipantes).Methods("PUT")
+	protected.HandleFunc("/compras/{id:[0-9]+}/modificaciones", compraHandler.ModificarCompra).Methods("POST")
+	protected.HandleFunc("/compras/{id:[0-9]+}/modificaciones", compraHandler.ListarModificacionesCompra).Methods("GET")
 	protected.HandleFunc("/mis-compras", compraHandler.ListarMisCompras).Methods("GET")
 
+	// Reseñas (turista)
+	protected.HandleFunc("/compras/{id:[0-9]+}/resena", resenaHandler.CrearResena).Methods("POST")
+	protected.HandleFunc("/resenas/{id:[0-9]+}/fotos", resenaHandler.SubirFotoResena).Methods("POST")
+
+	// Lista de espera de salidas llenas
+	protected.HandleFunc("/salidas/{salida_id:[0-9]+}/lista-espera", compraHandler.UnirseListaEspera).Methods("POST")
+	protected.HandleFunc("/mis-listas-espera", compraHandler.ListarMisListasEspera).Methods("GET")
+	protected.HandleFunc("/lista-espera/{id:[0-9]+}", compraHandler.SalirListaEspera).Methods("DELETE")
+	protected.HandleFunc("/lista-espera/{id:[0-9]+}/aceptar", compraHandler.AceptarOfertaListaEspera).Methods("POST")
+
+	// Cambios de fecha de salidas decididos por la agencia (requieren aceptación del turista)
+	protected.HandleFunc("/mis-reprogramaciones", salidaHandler.ListarMisReprogramaciones).Methods("GET")
+	protected.HandleFunc("/reprogramaciones/{id:[0-9]+}/responder", salidaHandler.ResponderReprogramacion).Methods("POST")
+
+	// Calendario del usuario como guía de agencias
+	protected.HandleFunc("/mis-salidas-guia", agenciaHandler.GetMiCalendarioGuia).Methods("GET")
+
+	// Calendario .ics de compras confirmadas del turista
+	protected.HandleFunc("/mi-calendario/feed", compraHandler.GetMiCalendarioFeed).Methods("GET")
+	protected.HandleFunc("/mi-calendario/feed/regenerar", compraHandler.RegenerarMiCalendarioFeed).Methods("POST")
+
+	// Solicitudes de tour privado (cotización a medida)
+	protected.HandleFunc("/solicitudes-privadas", compraHandler.CrearSolicitudPrivada).Methods("POST")
+	protected.HandleFunc("/mis-solicitudes-privadas", compraHandler.ListarMisSolicitudesPrivadas).Methods("GET")
+	protected.HandleFunc("/solicitudes-privadas/{id:[0-9]+}", compraHandler.ObtenerSolicitudPrivada).Methods("GET")
+	protected.HandleFunc("/solicitudes-privadas/{id:[0-9]+}/mensajes", compraHandler.EnviarMensajeSolicitudPrivada).Methods("POST")
+	protected.HandleFunc("/solicitudes-privadas/{id:[0-9]+}/aceptar", compraHandler.AceptarSolicitudPrivada).Methods("POST")
+	protected.HandleFunc("/solicitudes-privadas/{id:[0-9]+}/cancelar", compraHandler.CancelarSolicitudPrivada).Methods("POST")
+
 	// ========== PAGOS DE COMPRAS ==========
 	protected.HandleFunc("/pagos", pagoHandler.CrearPago).Methods("POST")
+	protected.HandleFunc("/pagos/proveedores", pagoHandler.ListarProveedoresPago).Methods("GET")
+	protected.HandleFunc("/pagos/pasarela", pagoHandler.IniciarPagoPasarela).Methods("POST")
+	protected.HandleFunc("/pagos/{id:[0-9]+}/pasarela/sincronizar", pagoHandler.SincronizarPagoPasarela).Methods("POST")
 
 	pagosManager := protected.PathPrefix("").Subrouter()
 	pagosManager.Use(middleware.RoleMiddleware("admin", "encargado_agencia"))
@@ -269,6 +468,23 @@ func main() {
 	adminRouter.HandleFunc("/agencias/{id:[0-9]+}", agenciaHandler.DeleteAgencia).Methods("DELETE")
 	adminRouter.HandleFunc("/agencias/{id:[0-9]+}/status", agenciaHandler.UpdateAgenciaStatus).Methods("PATCH")
 	adminRouter.HandleFunc("/agencias/stats", agenciaHandler.GetStats).Methods("GET")
+	adminRouter.HandleFunc("/resenas/{id:[0-9]+}/moderar", resenaHandler.ModerarResena).Methods("PUT")
+	adminRouter.HandleFunc("/webhooks-pagos", pagoHandler.ListarEventosWebhookPago).Methods("GET")
+	adminRouter.HandleFunc("/webhooks-pagos/{id:[0-9]+}", pagoHandler.ObtenerEventoWebhookPago).Methods("GET")
+	adminRouter.HandleFunc("/webhooks-pagos/{id:[0-9]+}/asignar", pagoHandler.AsignarEventoWebhookPago).Methods("POST")
+	adminRouter.HandleFunc("/webhooks-pagos/{id:[0-9]+}/descartar", pagoHandler.DescartarEventoWebhookPago).Methods("POST")
+	adminRouter.HandleFunc("/comprobantes-duplicados", pagoHandler.ListarComprobantesDuplicados).Methods("GET")
+	adminRouter.HandleFunc("/comprobantes-duplicados/{id:[0-9]+}", pagoHandler.ObtenerComprobanteDuplicado).Methods("GET")
+	adminRouter.HandleFunc("/comprobantes-duplicados/{id:[0-9]+}/resolver", pagoHandler.ResolverComprobanteDuplicado).Methods("POST")
+	adminRouter.HandleFunc("/comisiones", comisionHandler.GetResumenComisiones).Methods("GET")
+	adminRouter.HandleFunc("/comisiones/tarifas", comisionHandler.ListarTarifasComision).Methods("GET")
+	adminRouter.HandleFunc("/comisiones/tarifas", comisionHandler.CrearTarifaComision).Methods("POST")
+	adminRouter.HandleFunc("/comisiones/tarifas/{id:[0-9]+}", comisionHandler.ActualizarTarifaComision).Methods("PUT")
+	adminRouter.HandleFunc("/comisiones/tarifas/{id:[0-9]+}", comisionHandler.EliminarTarifaComision).Methods("DELETE")
+	adminRouter.HandleFunc("/comisiones/ajustes", comisionHandler.RegistrarAjusteComision).Methods("POST")
+	adminRouter.HandleFunc("/comisiones/pagos", comisionHandler.RegistrarPagoComision).Methods("POST")
+	adminRouter.HandleFunc("/comisiones/liquidaciones", comisionHandler.ListarLiquidacionesComision).Methods("GET")
+	adminRouter.HandleFunc("/comisiones/liquidaciones", comisionHandler.EmitirLiquidacionesComision).Methods("POST")
 
 	// Health check
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -21,6 +21,10 @@ type Config struct {
     AppEnv               string
     // Configuración de expiración de compras (en minutos)
     CompraExpiracionMinutos string
+    // Días de anticipación para recordar el saldo pendiente de reservas con anticipo
+    SaldoRecordatorioDias string
+    // Días después de la fecha límite del saldo en que se cancela la reserva impaga
+    SaldoGraciaDias string
 }
 
 func LoadConfig() *Config {
@@ -43,6 +47,8 @@ func LoadConfig() *Config {
         JWTRefreshExpiration: getEnv("JWT_REFRESH_EXPIRATION", "168h"),
         AppEnv:               getEnv("APP_ENV", "development"),
         CompraExpiracionMinutos: getEnv("COMPRA_EXPIRACION_MINUTOS", "30"),
+        SaldoRecordatorioDias: getEnv("SALDO_RECORDATORIO_DIAS", "3"),
+        SaldoGraciaDias: getEnv("SALDO_GRACIA_DIAS", "2"),
     }
 }
 
@@ -77,7 +77,17 @@ func runMigrations() error {
 	// Migrar todas las tablas en orden de dependencias
 	log.Println("Creating database tables...")
 
-	err := DB.AutoMigrate(
+	if err := AutoMigrate(DB); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	log.Println("Tables created successfully")
+	return nil
+}
+
+// AutoMigrate crea o actualiza todas las tablas del modelo (también la usan las pruebas de integración).
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
 		// Tablas base sin dependencias
 		&models.Usuario{},
 		&models.Departamento{},
@@ -96,10 +106,44 @@ func runMigrations() error {
 		&models.PaquetePolitica{},
 		&models.AgenciaDatosPago{},
 		&models.AgenciaCapacidad{},
+		&models.PoliticaReembolsoTramo{},
 		&models.PaqueteTuristico{},
+		&models.PaqueteTemporadaPrecio{},
+		&models.Promocion{},
+		&models.PromocionPaquete{},
+		&models.ReglaDescuento{},
 		&models.PaqueteSalidaHabilitada{},
 		&models.CompraPaquete{},
 		&models.PagoCompra{},
+		&models.EventoWebhookPago{},
+		&models.FormatoExtractoBancario{},
+		&models.ExtractoBancario{},
+		&models.LineaExtractoBancario{},
+		&models.CoincidenciaComprobante{},
+		&models.TarifaComision{},
+		&models.AsientoComision{},
+		&models.MovimientoComision{},
+		&models.LiquidacionComision{},
+		&models.CompraParticipante{},
+		&models.CompraModificacion{},
+		&models.PromocionUso{},
+		&models.CompraDescuento{},
+		&models.Reembolso{},
+		&models.ListaEsperaSalida{},
+		&models.Resena{},
+		&models.ResenaFoto{},
+		&models.SolicitudPrivada{},
+		&models.SolicitudPrivadaMensaje{},
+		&models.Guia{},
+		&models.SalidaGuia{},
+		&models.Recurso{},
+		&models.RecursoMantenimiento{},
+		&models.SalidaRecurso{},
+		&models.SalidaSerie{},
+		&models.AgenciaFechaBloqueada{},
+		&models.CalendarioFeed{},
+		&models.ReprogramacionSalida{},
+		&models.ReprogramacionCompra{},
 		&models.PaqueteItinerario{},
 		&models.PaqueteFoto{},
 		&models.PaqueteAtraccion{},
@@ -110,13 +154,6 @@ func runMigrations() error {
 		&models.AgenciaFoto{},
 		&models.AgenciaEspecialidad{},
 	)
-
-	if err != nil {
-		return fmt.Errorf("migration failed: %w", err)
-	}
-
-	log.Println("Tables created successfully")
-	return nil
 }
 
 func runSeeds() error {
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"log"
 
 	"gorm.io/gorm"
 )
@@ -35,6 +36,18 @@ func ApplySQLBootstrap(db *gorm.DB) error {
 		return err
 	}
 
+	if err := ensureSalidaCuposConstraint(db); err != nil {
+		return err
+	}
+
+	if err := ensureTarifaComisionUnica(db); err != nil {
+		return err
+	}
+
+	if err := ensureIndiceComprobantesSimilares(db); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -62,125 +75,42 @@ DROP FUNCTION IF EXISTS public.procesar_compra_paquete(
     TEXT
 );
 
-CREATE OR REPLACE FUNCTION public.procesar_compra_paquete(
-    p_turista_id INTEGER,
+-- validar_compra_paquete valida cantidades de participantes y disponibilidad del paquete y su agencia.
+-- Retorna NULL si la compra es válida o el mensaje de error.
+CREATE OR REPLACE FUNCTION public.validar_compra_paquete(
     p_paquete_id INTEGER,
-    p_fecha_seleccionada DATE,
-    p_tipo_compra TEXT,
-    p_extranjero BOOLEAN,
     p_cantidad_adultos INTEGER,
     p_cantidad_ninos_pagan INTEGER,
-    p_cantidad_ninos_gratis INTEGER,
-    p_tiene_discapacidad BOOLEAN,
-    p_descripcion_discapacidad TEXT,
-    p_notas_turista TEXT
-)
-RETURNS TABLE (
-    compra_id INTEGER,
-    salida_id INTEGER,
-    precio_total NUMERIC,
-    mensaje TEXT,
-    success BOOLEAN
+    p_cantidad_ninos_gratis INTEGER
 )
+RETURNS TEXT
 LANGUAGE plpgsql
 AS $$
 DECLARE
     v_paquete RECORD;
-    v_max_salidas_por_dia INTEGER := 5;
-    v_max_salidas_por_horario INTEGER := 3;
-    v_recargo_privado_porcentaje NUMERIC := 0;
-
     v_total_participantes INTEGER := 0;
-    v_personas_pagan INTEGER := 0;
-
-    v_horario_capacidad TEXT := 'todo_dia';
-    v_horario_seleccionado TEXT := NULL;
-
-    v_salidas_dia INTEGER := 0;
-    v_salidas_horario INTEGER := 0;
-
-    v_salida_id INTEGER := 0;
-    v_compra_id INTEGER := 0;
-
-    v_precio_base NUMERIC := 0;
-    v_recargo_extranjero_total NUMERIC := 0;
-    v_subtotal_base NUMERIC := 0;
-    v_subtotal_full NUMERIC := 0;
-    v_recargo_privado NUMERIC := 0;
-    v_total_recargo NUMERIC := 0;
-    v_precio_total NUMERIC := 0;
 BEGIN
-    -- Validar turista
-    IF NOT EXISTS (SELECT 1 FROM usuarios WHERE id = p_turista_id) THEN
-        compra_id := 0;
-        salida_id := 0;
-        precio_total := 0;
-        mensaje := 'Turista no encontrado';
-        success := FALSE;
-        RETURN NEXT;
-        RETURN;
-    END IF;
-
-    IF NOT EXISTS (SELECT 1 FROM usuarios WHERE id = p_turista_id AND rol = 'turista') THEN
-        compra_id := 0;
-        salida_id := 0;
-        precio_total := 0;
-        mensaje := 'Solo usuarios con rol "turista" pueden realizar compras';
-        success := FALSE;
-        RETURN NEXT;
-        RETURN;
-    END IF;
-
     -- Validaciones básicas de participantes
     IF p_cantidad_adultos IS NULL OR p_cantidad_adultos < 1 THEN
-        compra_id := 0;
-        salida_id := 0;
-        precio_total := 0;
-        mensaje := 'Debe haber al menos 1 adulto';
-        success := FALSE;
-        RETURN NEXT;
-        RETURN;
+        RETURN 'Debe haber al menos 1 adulto';
     END IF;
 
     IF COALESCE(p_cantidad_ninos_pagan, 0) < 0 OR COALESCE(p_cantidad_ninos_gratis, 0) < 0 THEN
-        compra_id := 0;
-        salida_id := 0;
-        precio_total := 0;
-        mensaje := 'Las cantidades de niños no pueden ser negativas';
-        success := FALSE;
-        RETURN NEXT;
-        RETURN;
+        RETURN 'Las cantidades de niños no pueden ser negativas';
     END IF;
 
     v_total_participantes := p_cantidad_adultos
         + COALESCE(p_cantidad_ninos_pagan, 0)
         + COALESCE(p_cantidad_ninos_gratis, 0);
-    v_personas_pagan := p_cantidad_adultos + COALESCE(p_cantidad_ninos_pagan, 0);
 
     IF v_total_participantes < 1 THEN
-        compra_id := 0;
-        salida_id := 0;
-        precio_total := 0;
-        mensaje := 'Debe registrar al menos 1 participante';
-        success := FALSE;
-        RETURN NEXT;
-        RETURN;
+        RETURN 'Debe registrar al menos 1 participante';
     END IF;
 
     -- Cargar paquete y validar estado/visibilidad de agencia y paquete
     SELECT
         p.id,
         p.agencia_id,
-        p.frecuencia,
-        p.fecha_salida_fija,
-        p.duracion_dias,
-        p.horario,
-        p.permite_privado,
-        p.dias_previos_compra,
-        p.cupo_minimo,
-        p.cupo_maximo,
-        p.precio_base_nacionales,
-        p.precio_adicional_extranjeros,
         p.status,
         p.visible_publico,
         a.status AS agencia_status,
@@ -191,94 +121,121 @@ BEGIN
     WHERE p.id = p_paquete_id;
 
     IF NOT FOUND THEN
-        compra_id := 0;
-        salida_id := 0;
-        precio_total := 0;
-        mensaje := 'Paquete no encontrado';
-        success := FALSE;
-        RETURN NEXT;
-        RETURN;
+        RETURN 'Paquete no encontrado';
     END IF;
 
     IF v_paquete.status <> 'activo' OR v_paquete.visible_publico <> TRUE THEN
-        compra_id := 0;
-        salida_id := 0;
-        precio_total := 0;
-        mensaje := 'El paquete no está disponible';
-        success := FALSE;
-        RETURN NEXT;
-        RETURN;
+        RETURN 'El paquete no está disponible';
     END IF;
 
     IF v_paquete.agencia_status <> 'activa' OR v_paquete.agencia_visible <> TRUE THEN
-        compra_id := 0;
+        RETURN 'La agencia no está disponible';
+    END IF;
+
+    RETURN NULL;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS public.reservar_salida_paquete(INTEGER, DATE, TEXT, INTEGER);
+DROP FUNCTION IF EXISTS public.reservar_salida_paquete(INTEGER, DATE, TEXT, INTEGER, BOOLEAN);
+
+-- reservar_salida_paquete valida tipo/fecha/capacidad y elige una salida existente (compartida con lugar,
+-- bloqueada hasta el fin de la transacción) o crea una nueva sin cupos ocupados. No mueve cupos: el llamador
+-- los reserva con InventarioService.Reservar en la misma transacción. Usada por procesar_compra_paquete y por
+-- la modificación de compras.
+-- Con p_solo_verificar = TRUE aplica las mismas reglas sin reservar ni crear salidas (cotizaciones);
+-- en ese caso salida_id es 0 cuando la compra crearía una salida nueva.
+CREATE OR REPLACE FUNCTION public.reservar_salida_paquete(
+    p_paquete_id INTEGER,
+    p_fecha DATE,
+    p_tipo_compra TEXT,
+    p_total_participantes INTEGER,
+    p_solo_verificar BOOLEAN DEFAULT FALSE
+)
+RETURNS TABLE (
+    salida_id INTEGER,
+    mensaje TEXT,
+    nueva_salida BOOLEAN,
+    cupos_disponibles INTEGER
+)
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_paquete RECORD;
+    v_max_salidas_por_dia INTEGER := 5;
+    v_max_salidas_por_horario INTEGER := 3;
+    v_horario_capacidad TEXT := 'todo_dia';
+    v_salidas_dia INTEGER := 0;
+    v_salidas_horario INTEGER := 0;
+    v_salida_id INTEGER := 0;
+    v_cupos_disponibles INTEGER := 0;
+BEGIN
+    nueva_salida := FALSE;
+    cupos_disponibles := 0;
+
+    SELECT
+        p.id,
+        p.agencia_id,
+        p.frecuencia,
+        p.fecha_salida_fija,
+        p.duracion_dias,
+        p.horario,
+        p.permite_privado,
+        p.dias_previos_compra,
+        p.cupo_minimo,
+        p.cupo_maximo
+    INTO v_paquete
+    FROM paquetes_turisticos p
+    WHERE p.id = p_paquete_id;
+
+    IF NOT FOUND THEN
         salida_id := 0;
-        precio_total := 0;
-        mensaje := 'La agencia no está disponible';
-        success := FALSE;
+        mensaje := 'Paquete no encontrado';
         RETURN NEXT;
         RETURN;
     END IF;
 
     -- Validar tipo de compra
     IF p_tipo_compra NOT IN ('compartido', 'privado') THEN
-        compra_id := 0;
         salida_id := 0;
-        precio_total := 0;
         mensaje := 'Tipo de compra inválido';
-        success := FALSE;
         RETURN NEXT;
         RETURN;
     END IF;
 
     IF p_tipo_compra = 'privado' THEN
         IF v_paquete.frecuencia <> 'salida_diaria' THEN
-            compra_id := 0;
             salida_id := 0;
-            precio_total := 0;
             mensaje := 'El tipo privado solo está disponible para paquetes de salida diaria';
-            success := FALSE;
             RETURN NEXT;
             RETURN;
         END IF;
         IF v_paquete.permite_privado IS DISTINCT FROM TRUE THEN
-            compra_id := 0;
             salida_id := 0;
-            precio_total := 0;
             mensaje := 'Este paquete no permite compras privadas';
-            success := FALSE;
             RETURN NEXT;
             RETURN;
         END IF;
     END IF;
 
     -- Validar fecha seleccionada
-    IF p_fecha_seleccionada < (CURRENT_DATE + COALESCE(v_paquete.dias_previos_compra, 1)::int) THEN
-        compra_id := 0;
+    IF p_fecha < (CURRENT_DATE + COALESCE(v_paquete.dias_previos_compra, 1)::int) THEN
         salida_id := 0;
-        precio_total := 0;
         mensaje := 'La fecha seleccionada no cumple los días previos de compra';
-        success := FALSE;
         RETURN NEXT;
         RETURN;
     END IF;
 
     IF v_paquete.frecuencia = 'salida_unica' THEN
         IF v_paquete.fecha_salida_fija IS NULL THEN
-            compra_id := 0;
             salida_id := 0;
-            precio_total := 0;
             mensaje := 'El paquete no tiene fecha de salida fija configurada';
-            success := FALSE;
             RETURN NEXT;
             RETURN;
         END IF;
-        IF p_fecha_seleccionada <> v_paquete.fecha_salida_fija::date THEN
-            compra_id := 0;
+        IF p_fecha <> v_paquete.fecha_salida_fija::date THEN
             salida_id := 0;
-            precio_total := 0;
             mensaje := 'La compra debe realizarse en la fecha de salida fija del paquete';
-            success := FALSE;
             RETURN NEXT;
             RETURN;
         END IF;
@@ -286,23 +243,11 @@ BEGIN
 
     -- Horario (para capacidad y para la compra)
     IF COALESCE(v_paquete.duracion_dias, 1) > 1 THEN
-        v_horario_seleccionado := NULL;
         v_horario_capacidad := 'todo_dia';
     ELSE
-        v_horario_seleccionado := v_paquete.horario;
         v_horario_capacidad := COALESCE(v_paquete.horario, 'todo_dia');
     END IF;
 
-    -- Políticas (recargo privado)
-    SELECT recargo_privado_porcentaje
-    INTO v_recargo_privado_porcentaje
-    FROM paquete_politicas
-    WHERE agencia_id = v_paquete.agencia_id;
-
-    IF NOT FOUND THEN
-        v_recargo_privado_porcentaje := 0;
-    END IF;
-
     -- Capacidad (si no existe, defaults)
     SELECT max_salidas_por_dia, max_salidas_por_horario
     INTO v_max_salidas_por_dia, v_max_salidas_por_horario
@@ -315,12 +260,9 @@ BEGIN
     END IF;
 
     -- Validar cupo máximo del paquete
-    IF v_total_participantes > v_paquete.cupo_maximo THEN
-        compra_id := 0;
+    IF p_total_participantes > v_paquete.cupo_maximo THEN
         salida_id := 0;
-        precio_total := 0;
         mensaje := 'La cantidad de participantes excede el cupo máximo del paquete';
-        success := FALSE;
         RETURN NEXT;
         RETURN;
     END IF;
@@ -328,14 +270,14 @@ BEGIN
     -- Buscar/crear salida
     IF p_tipo_compra = 'compartido' THEN
         -- Buscar una salida compartida existente con cupo disponible
-        SELECT s.id
-        INTO v_salida_id
+        SELECT s.id, (s.cupo_maximo - s.cupos_reservados - s.cupos_confirmados - COALESCE(s.cupos_ofertados, 0))
+        INTO v_salida_id, v_cupos_disponibles
         FROM paquete_salidas_habilitadas s
         WHERE s.paquete_id = p_paquete_id
-          AND s.fecha_salida = p_fecha_seleccionada
+          AND s.fecha_salida = p_fecha
           AND s.tipo_salida = 'compartido'
           AND s.estado IN ('pendiente', 'activa')
-          AND (s.cupo_maximo - s.cupos_reservados - s.cupos_confirmados) >= v_total_participantes
+          AND (s.cupo_maximo - s.cupos_reservados - s.cupos_confirmados - COALESCE(s.cupos_ofertados, 0)) >= p_total_participantes
         ORDER BY s.id
         FOR UPDATE
         LIMIT 1;
@@ -348,15 +290,12 @@ BEGIN
             FROM paquete_salidas_habilitadas s
             JOIN paquetes_turisticos p ON p.id = s.paquete_id
             WHERE p.agencia_id = v_paquete.agencia_id
-              AND s.fecha_salida = p_fecha_seleccionada
+              AND s.fecha_salida = p_fecha
               AND s.estado IN ('pendiente', 'activa');
 
             IF v_salidas_dia >= v_max_salidas_por_dia THEN
-                compra_id := 0;
                 salida_id := 0;
-                precio_total := 0;
                 mensaje := 'La agencia alcanzó su máximo de salidas para ese día';
-                success := FALSE;
                 RETURN NEXT;
                 RETURN;
             END IF;
@@ -366,16 +305,37 @@ BEGIN
             FROM paquete_salidas_habilitadas s
             JOIN paquetes_turisticos p ON p.id = s.paquete_id
             WHERE p.agencia_id = v_paquete.agencia_id
-              AND s.fecha_salida = p_fecha_seleccionada
+              AND s.fecha_salida = p_fecha
               AND s.estado IN ('pendiente', 'activa')
               AND COALESCE(p.horario, 'todo_dia') = v_horario_capacidad;
 
             IF v_salidas_horario >= v_max_salidas_por_horario THEN
-                compra_id := 0;
                 salida_id := 0;
-                precio_total := 0;
                 mensaje := 'La agencia alcanzó su máximo de salidas simultáneas para ese horario';
-                success := FALSE;
+                RETURN NEXT;
+                RETURN;
+            END IF;
+
+            -- No se crean salidas en fechas bloqueadas por la agencia
+            IF EXISTS (
+                SELECT 1
+                FROM agencia_fechas_bloqueadas b
+                WHERE b.agencia_id = v_paquete.agencia_id
+                  AND (b.paquete_id IS NULL OR b.paquete_id = p_paquete_id)
+                  AND p_fecha BETWEEN b.fecha_inicio AND b.fecha_fin
+            ) THEN
+                salida_id := 0;
+                mensaje := 'La agencia no opera en la fecha seleccionada';
+                RETURN NEXT;
+                RETURN;
+            END IF;
+
+            nueva_salida := TRUE;
+            v_cupos_disponibles := v_paquete.cupo_maximo;
+            IF p_solo_verificar THEN
+                salida_id := 0;
+                mensaje := NULL;
+                cupos_disponibles := v_cupos_disponibles;
                 RETURN NEXT;
                 RETURN;
             END IF;
@@ -394,22 +354,16 @@ BEGIN
                 updated_at
             ) VALUES (
                 p_paquete_id,
-                p_fecha_seleccionada,
+                p_fecha,
                 'compartido',
                 v_paquete.cupo_minimo,
                 v_paquete.cupo_maximo,
-                v_total_participantes,
+                0,
                 0,
                 'pendiente',
                 CURRENT_TIMESTAMP,
                 CURRENT_TIMESTAMP
             ) RETURNING id INTO v_salida_id;
-        ELSE
-            -- Salida existente encontrada, actualizar cupos reservados
-            UPDATE paquete_salidas_habilitadas
-            SET cupos_reservados = cupos_reservados + v_total_participantes,
-                updated_at = CURRENT_TIMESTAMP
-            WHERE id = v_salida_id;
         END IF;
     ELSE
         -- Privado: siempre crea una salida exclusiva
@@ -418,15 +372,12 @@ BEGIN
         FROM paquete_salidas_habilitadas s
         JOIN paquetes_turisticos p ON p.id = s.paquete_id
         WHERE p.agencia_id = v_paquete.agencia_id
-          AND s.fecha_salida = p_fecha_seleccionada
+          AND s.fecha_salida = p_fecha
           AND s.estado IN ('pendiente', 'activa');
 
         IF v_salidas_dia >= v_max_salidas_por_dia THEN
-            compra_id := 0;
             salida_id := 0;
-            precio_total := 0;
             mensaje := 'La agencia alcanzó su máximo de salidas para ese día';
-            success := FALSE;
             RETURN NEXT;
             RETURN;
         END IF;
@@ -436,16 +387,37 @@ BEGIN
         FROM paquete_salidas_habilitadas s
         JOIN paquetes_turisticos p ON p.id = s.paquete_id
         WHERE p.agencia_id = v_paquete.agencia_id
-          AND s.fecha_salida = p_fecha_seleccionada
+          AND s.fecha_salida = p_fecha
           AND s.estado IN ('pendiente', 'activa')
           AND COALESCE(p.horario, 'todo_dia') = v_horario_capacidad;
 
         IF v_salidas_horario >= v_max_salidas_por_horario THEN
-            compra_id := 0;
             salida_id := 0;
-            precio_total := 0;
             mensaje := 'La agencia alcanzó su máximo de salidas simultáneas para ese horario';
-            success := FALSE;
+            RETURN NEXT;
+            RETURN;
+        END IF;
+
+        -- No se crean salidas en fechas bloqueadas por la agencia
+        IF EXISTS (
+            SELECT 1
+            FROM agencia_fechas_bloqueadas b
+            WHERE b.agencia_id = v_paquete.agencia_id
+              AND (b.paquete_id IS NULL OR b.paquete_id = p_paquete_id)
+              AND p_fecha BETWEEN b.fecha_inicio AND b.fecha_fin
+        ) THEN
+            salida_id := 0;
+            mensaje := 'La agencia no opera en la fecha seleccionada';
+            RETURN NEXT;
+            RETURN;
+        END IF;
+
+        nueva_salida := TRUE;
+        v_cupos_disponibles := p_total_participantes;
+        IF p_solo_verificar THEN
+            salida_id := 0;
+            mensaje := NULL;
+            cupos_disponibles := v_cupos_disponibles;
             RETURN NEXT;
             RETURN;
         END IF;
@@ -463,11 +435,11 @@ BEGIN
             updated_at
         ) VALUES (
             p_paquete_id,
-            p_fecha_seleccionada,
+            p_fecha,
             'privado',
-            v_total_participantes,
-            v_total_participantes,
-            v_total_participantes,
+            p_total_participantes,
+            p_total_participantes,
+            0,
             0,
             'pendiente',
             CURRENT_TIMESTAMP,
@@ -475,26 +447,285 @@ BEGIN
         ) RETURNING id INTO v_salida_id;
     END IF;
 
-    -- Calcular precios
-    v_precio_base := COALESCE(v_paquete.precio_base_nacionales, 0);
+    salida_id := v_salida_id;
+    mensaje := NULL;
+    cupos_disponibles := v_cupos_disponibles;
+    RETURN NEXT;
+    RETURN;
+END;
+$$;
+
+-- resolver_precio_paquete determina el precio por persona de un paquete para una fecha.
+-- Prioridad: precio especial de la salida > regla de temporada > precio base del paquete.
+CREATE OR REPLACE FUNCTION public.resolver_precio_paquete(
+    p_paquete_id INTEGER,
+    p_fecha DATE,
+    p_salida_id INTEGER
+)
+RETURNS TABLE (
+    precio_base NUMERIC,
+    precio_adicional_extranjeros NUMERIC,
+    regla_precio TEXT,
+    temporada_precio_id INTEGER
+)
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_paquete RECORD;
+    v_salida RECORD;
+    v_temporada RECORD;
+BEGIN
+    SELECT p.precio_base_nacionales, p.precio_adicional_extranjeros
+    INTO v_paquete
+    FROM paquetes_turisticos p
+    WHERE p.id = p_paquete_id;
+
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    -- Precio especial de la salida
+    IF COALESCE(p_salida_id, 0) > 0 THEN
+        SELECT s.precio_base_nacionales, s.precio_adicional_extranjeros
+        INTO v_salida
+        FROM paquete_salidas_habilitadas s
+        WHERE s.id = p_salida_id
+          AND s.paquete_id = p_paquete_id;
+
+        IF FOUND AND v_salida.precio_base_nacionales IS NOT NULL THEN
+            precio_base := v_salida.precio_base_nacionales;
+            precio_adicional_extranjeros := COALESCE(v_salida.precio_adicional_extranjeros, v_paquete.precio_adicional_extranjeros, 0);
+            regla_precio := 'salida';
+            temporada_precio_id := NULL;
+            RETURN NEXT;
+            RETURN;
+        END IF;
+    END IF;
+
+    -- Regla de temporada
+    SELECT t.id, t.precio_base_nacionales, t.precio_adicional_extranjeros
+    INTO v_temporada
+    FROM paquete_temporadas_precio t
+    WHERE t.paquete_id = p_paquete_id
+      AND t.activa = TRUE
+      AND (t.fecha_inicio IS NULL OR p_fecha >= t.fecha_inicio)
+      AND (t.fecha_fin IS NULL OR p_fecha <= t.fecha_fin)
+      AND (
+            (t.tipo = 'rango_fechas' AND t.fecha_inicio IS NOT NULL AND t.fecha_fin IS NOT NULL)
+         OR (t.tipo = 'dias_semana' AND EXTRACT(ISODOW FROM p_fecha)::int::text = ANY(t.dias_semana))
+         OR (t.tipo = 'fechas_especificas' AND to_char(p_fecha, 'YYYY-MM-DD') = ANY(t.fechas))
+      )
+    ORDER BY
+        t.prioridad DESC,
+        CASE t.tipo WHEN 'fechas_especificas' THEN 0 WHEN 'rango_fechas' THEN 1 ELSE 2 END,
+        t.id DESC
+    LIMIT 1;
+
+    IF FOUND THEN
+        precio_base := v_temporada.precio_base_nacionales;
+        precio_adicional_extranjeros := COALESCE(v_temporada.precio_adicional_extranjeros, v_paquete.precio_adicional_extranjeros, 0);
+        regla_precio := 'temporada';
+        temporada_precio_id := v_temporada.id;
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    precio_base := COALESCE(v_paquete.precio_base_nacionales, 0);
+    precio_adicional_extranjeros := COALESCE(v_paquete.precio_adicional_extranjeros, 0);
+    regla_precio := 'base';
+    temporada_precio_id := NULL;
+    RETURN NEXT;
+    RETURN;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS public.cotizar_compra_paquete(INTEGER, TEXT, BOOLEAN, INTEGER, INTEGER);
+
+-- cotizar_compra_paquete calcula el precio base de una compra (sin descuentos) con las reglas de la agencia.
+-- El precio por persona se resuelve con resolver_precio_paquete (p_salida_id puede ser NULL/0 si la salida aún no existe).
+CREATE OR REPLACE FUNCTION public.cotizar_compra_paquete(
+    p_paquete_id INTEGER,
+    p_fecha DATE,
+    p_salida_id INTEGER,
+    p_tipo_compra TEXT,
+    p_extranjero BOOLEAN,
+    p_cantidad_adultos INTEGER,
+    p_cantidad_ninos_pagan INTEGER
+)
+RETURNS TABLE (
+    precio_unitario NUMERIC,
+    recargo_privado_porcentaje NUMERIC,
+    recargo_extranjero NUMERIC,
+    subtotal NUMERIC,
+    total_recargo NUMERIC,
+    precio_total NUMERIC,
+    regla_precio TEXT,
+    temporada_precio_id INTEGER
+)
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_paquete RECORD;
+    v_precio RECORD;
+    v_personas_pagan INTEGER := 0;
+    v_recargo_privado_porcentaje NUMERIC := 0;
+    v_recargo_privado NUMERIC := 0;
+BEGIN
+    SELECT p.agencia_id
+    INTO v_paquete
+    FROM paquetes_turisticos p
+    WHERE p.id = p_paquete_id;
+
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    SELECT r.precio_base, r.precio_adicional_extranjeros, r.regla_precio, r.temporada_precio_id
+    INTO v_precio
+    FROM public.resolver_precio_paquete(p_paquete_id, p_fecha, p_salida_id) r;
+
+    regla_precio := v_precio.regla_precio;
+    temporada_precio_id := v_precio.temporada_precio_id;
+
+    SELECT pp.recargo_privado_porcentaje
+    INTO v_recargo_privado_porcentaje
+    FROM paquete_politicas pp
+    WHERE pp.agencia_id = v_paquete.agencia_id;
+
+    IF NOT FOUND OR v_recargo_privado_porcentaje IS NULL THEN
+        v_recargo_privado_porcentaje := 0;
+    END IF;
+
+    v_personas_pagan := COALESCE(p_cantidad_adultos, 0) + COALESCE(p_cantidad_ninos_pagan, 0);
+
+    precio_unitario := COALESCE(v_precio.precio_base, 0);
 
     IF p_extranjero THEN
-        v_recargo_extranjero_total := COALESCE(v_paquete.precio_adicional_extranjeros, 0) * v_personas_pagan;
+        recargo_extranjero := COALESCE(v_precio.precio_adicional_extranjeros, 0) * v_personas_pagan;
     ELSE
-        v_recargo_extranjero_total := 0;
+        recargo_extranjero := 0;
     END IF;
 
-    v_subtotal_base := v_precio_base * v_personas_pagan;
-    v_subtotal_full := v_subtotal_base + v_recargo_extranjero_total;
+    subtotal := precio_unitario * v_personas_pagan;
 
     IF p_tipo_compra = 'privado' AND v_recargo_privado_porcentaje > 0 THEN
-        v_recargo_privado := v_subtotal_full * (v_recargo_privado_porcentaje / 100);
+        recargo_privado_porcentaje := v_recargo_privado_porcentaje;
+        v_recargo_privado := (subtotal + recargo_extranjero) * (v_recargo_privado_porcentaje / 100);
     ELSE
+        recargo_privado_porcentaje := 0;
         v_recargo_privado := 0;
     END IF;
 
-    v_total_recargo := v_recargo_extranjero_total + v_recargo_privado;
-    v_precio_total := v_subtotal_base + v_total_recargo;
+    total_recargo := recargo_extranjero + v_recargo_privado;
+    precio_total := subtotal + total_recargo;
+    RETURN NEXT;
+    RETURN;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.procesar_compra_paquete(
+    p_turista_id INTEGER,
+    p_paquete_id INTEGER,
+    p_fecha_seleccionada DATE,
+    p_tipo_compra TEXT,
+    p_extranjero BOOLEAN,
+    p_cantidad_adultos INTEGER,
+    p_cantidad_ninos_pagan INTEGER,
+    p_cantidad_ninos_gratis INTEGER,
+    p_tiene_discapacidad BOOLEAN,
+    p_descripcion_discapacidad TEXT,
+    p_notas_turista TEXT
+)
+RETURNS TABLE (
+    compra_id INTEGER,
+    salida_id INTEGER,
+    precio_total NUMERIC,
+    mensaje TEXT,
+    success BOOLEAN
+)
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_paquete RECORD;
+    v_cotizacion RECORD;
+
+    v_total_participantes INTEGER := 0;
+
+    v_horario_seleccionado TEXT := NULL;
+
+    v_salida_id INTEGER := 0;
+    v_mensaje_validacion TEXT := NULL;
+    v_mensaje_reserva TEXT := NULL;
+    v_compra_id INTEGER := 0;
+BEGIN
+    -- Validar turista
+    IF NOT EXISTS (SELECT 1 FROM usuarios WHERE id = p_turista_id) THEN
+        compra_id := 0;
+        salida_id := 0;
+        precio_total := 0;
+        mensaje := 'Turista no encontrado';
+        success := FALSE;
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM usuarios WHERE id = p_turista_id AND rol = 'turista') THEN
+        compra_id := 0;
+        salida_id := 0;
+        precio_total := 0;
+        mensaje := 'Solo usuarios con rol "turista" pueden realizar compras';
+        success := FALSE;
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    -- Validar paquete, agencia y cantidades de participantes
+    v_mensaje_validacion := public.validar_compra_paquete(p_paquete_id, p_cantidad_adultos, p_cantidad_ninos_pagan, p_cantidad_ninos_gratis);
+    IF v_mensaje_validacion IS NOT NULL THEN
+        compra_id := 0;
+        salida_id := 0;
+        precio_total := 0;
+        mensaje := v_mensaje_validacion;
+        success := FALSE;
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    v_total_participantes := p_cantidad_adultos
+        + COALESCE(p_cantidad_ninos_pagan, 0)
+        + COALESCE(p_cantidad_ninos_gratis, 0);
+
+    SELECT p.duracion_dias, p.horario
+    INTO v_paquete
+    FROM paquetes_turisticos p
+    WHERE p.id = p_paquete_id;
+
+    -- Horario de la compra
+    IF COALESCE(v_paquete.duracion_dias, 1) > 1 THEN
+        v_horario_seleccionado := NULL;
+    ELSE
+        v_horario_seleccionado := v_paquete.horario;
+    END IF;
+
+    -- Validar tipo/fecha/capacidad y elegir la salida (los cupos los reserva el llamador con InventarioService)
+    SELECT r.salida_id, r.mensaje
+    INTO v_salida_id, v_mensaje_reserva
+    FROM public.reservar_salida_paquete(p_paquete_id, p_fecha_seleccionada, p_tipo_compra, v_total_participantes) r;
+
+    IF COALESCE(v_salida_id, 0) = 0 THEN
+        compra_id := 0;
+        salida_id := 0;
+        precio_total := 0;
+        mensaje := COALESCE(v_mensaje_reserva, 'No se pudo reservar cupos para la fecha seleccionada');
+        success := FALSE;
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    -- Calcular precios
+    SELECT *
+    INTO v_cotizacion
+    FROM public.cotizar_compra_paquete(p_paquete_id, p_fecha_seleccionada, v_salida_id, p_tipo_compra, p_extranjero, p_cantidad_adultos, COALESCE(p_cantidad_ninos_pagan, 0));
 
     -- Crear compra
     INSERT INTO compras_paquetes (
@@ -516,6 +747,8 @@ BEGIN
         subtotal,
         total_recargo,
         precio_total,
+        regla_precio,
+        temporada_precio_id,
         tiene_discapacidad,
         descripcion_discapacidad,
         notas_turista,
@@ -535,12 +768,14 @@ BEGIN
         COALESCE(p_cantidad_ninos_pagan, 0),
         COALESCE(p_cantidad_ninos_gratis, 0),
         v_total_participantes,
-        v_precio_base,
-        CASE WHEN p_tipo_compra = 'privado' THEN v_recargo_privado_porcentaje ELSE 0 END,
-        v_recargo_extranjero_total,
-        v_subtotal_base,
-        v_total_recargo,
-        v_precio_total,
+        v_cotizacion.precio_unitario,
+        v_cotizacion.recargo_privado_porcentaje,
+        v_cotizacion.recargo_extranjero,
+        v_cotizacion.subtotal,
+        v_cotizacion.total_recargo,
+        v_cotizacion.precio_total,
+        v_cotizacion.regla_precio,
+        v_cotizacion.temporada_precio_id,
         COALESCE(p_tiene_discapacidad, FALSE),
         p_descripcion_discapacidad,
         p_notas_turista,
@@ -551,7 +786,7 @@ BEGIN
 
     compra_id := v_compra_id;
     salida_id := v_salida_id;
-    precio_total := v_precio_total;
+    precio_total := v_cotizacion.precio_total;
     mensaje := 'Compra registrada. Esperando confirmación de pago.';
     success := TRUE;
     RETURN NEXT;
@@ -646,7 +881,10 @@ func ensureTriggerPagoConfirmado(db *gorm.DB) error {
 }
 
 const sqlTriggerPagoConfirmado = `
--- Función trigger para cuando un pago es confirmado
+-- Función trigger para cuando un pago es confirmado.
+-- Acumula el monto pagado; la compra solo pasa a 'confirmada' cuando los pagos confirmados cubren el total.
+-- Mientras tanto queda 'reservada_con_anticipo' y sus cupos siguen reservados. Los cupos no se mueven aquí:
+-- PagoService los pasa a confirmados con InventarioService al ver la compra confirmada.
 CREATE OR REPLACE FUNCTION public.fn_on_pago_confirmado()
 RETURNS TRIGGER AS $$
 DECLARE
@@ -655,42 +893,27 @@ DECLARE
     v_fecha_salida DATE;
     v_confirmado_por_nombre TEXT;
     v_notif_id INTEGER;
+    v_monto_pagado NUMERIC := 0;
+    v_saldo NUMERIC := 0;
 BEGIN
     -- Solo ejecutar cuando el estado cambia a 'confirmado'
     IF NEW.estado = 'confirmado' AND OLD.estado = 'pendiente' THEN
         -- Obtener datos de la compra
-        SELECT id, salida_id, total_participantes, status, turista_id, paquete_id
+        SELECT id, salida_id, total_participantes, status, turista_id, paquete_id,
+               precio_total, COALESCE(monto_pagado, 0) AS monto_pagado, fecha_limite_saldo
         INTO v_compra
         FROM compras_paquetes
-        WHERE id = NEW.compra_id;
+        WHERE id = NEW.compra_id
+        FOR UPDATE;
 
         IF NOT FOUND THEN
             RAISE EXCEPTION 'Compra no encontrada: %', NEW.compra_id;
         END IF;
 
-        -- Solo procesar si la compra está pendiente de confirmación
-        IF v_compra.status = 'pendiente_confirmacion' THEN
-            -- Actualizar estado de la compra
-            UPDATE compras_paquetes
-            SET status = 'confirmada',
-                fecha_confirmacion = NOW(),
-                codigo_confirmacion = COALESCE(codigo_confirmacion, 'CONF-' || LPAD(NEW.compra_id::text, 6, '0')),
-                updated_at = NOW()
-            WHERE id = NEW.compra_id;
-
-            -- Mover cupos de reservados a confirmados
-            IF v_compra.salida_id IS NOT NULL THEN
-                UPDATE paquete_salidas_habilitadas
-                SET cupos_reservados = GREATEST(0, cupos_reservados - v_compra.total_participantes),
-                    cupos_confirmados = cupos_confirmados + v_compra.total_participantes,
-                    updated_at = NOW()
-                WHERE id = v_compra.salida_id;
-
-                -- Obtener fecha de salida
-                SELECT fecha_salida INTO v_fecha_salida
-                FROM paquete_salidas_habilitadas
-                WHERE id = v_compra.salida_id;
-            END IF;
+        -- Solo procesar si la compra está pendiente de pago
+        IF v_compra.status IN ('pendiente_confirmacion', 'reservada_con_anticipo') THEN
+            v_monto_pagado := v_compra.monto_pagado + NEW.monto;
+            v_saldo := GREATEST(0, v_compra.precio_total - v_monto_pagado);
 
             -- Obtener nombre del paquete
             SELECT nombre INTO v_paquete_nombre
@@ -704,28 +927,79 @@ BEGIN
                 WHERE id = NEW.confirmado_por;
             END IF;
 
-            -- Crear notificación para el turista
-            INSERT INTO notificaciones (
-                usuario_id,
-                tipo,
-                titulo,
-                mensaje,
-                datos_json
-            ) VALUES (
-                v_compra.turista_id,
-                'pago_confirmado',
-                '¡Tu pago fue confirmado!',
-                'Tu pago de Bs ' || NEW.monto || ' para "' || v_paquete_nombre || '" fue confirmado exitosamente',
-                jsonb_build_object(
-                    'pago_id', NEW.id,
-                    'compra_id', NEW.compra_id,
-                    'paquete_id', v_compra.paquete_id,
-                    'paquete_nombre', v_paquete_nombre,
-                    'confirmado_por', v_confirmado_por_nombre,
-                    'fecha_salida', v_fecha_salida,
-                    'monto', NEW.monto
-                )
-            ) RETURNING id INTO v_notif_id;
+            IF v_saldo <= 0.01 THEN
+                -- Pago completo: confirmar compra
+                UPDATE compras_paquetes
+                SET status = 'confirmada',
+                    monto_pagado = v_monto_pagado,
+                    fecha_confirmacion = NOW(),
+                    codigo_confirmacion = COALESCE(codigo_confirmacion, 'CONF-' || LPAD(NEW.compra_id::text, 6, '0')),
+                    updated_at = NOW()
+                WHERE id = NEW.compra_id;
+
+                -- Obtener fecha de salida
+                IF v_compra.salida_id IS NOT NULL THEN
+                    SELECT fecha_salida INTO v_fecha_salida
+                    FROM paquete_salidas_habilitadas
+                    WHERE id = v_compra.salida_id;
+                END IF;
+
+                -- Crear notificación para el turista
+                INSERT INTO notificaciones (
+                    usuario_id,
+                    tipo,
+                    titulo,
+                    mensaje,
+                    datos_json
+                ) VALUES (
+                    v_compra.turista_id,
+                    'pago_confirmado',
+                    '¡Tu pago fue confirmado!',
+                    'Tu pago de Bs ' || NEW.monto || ' para "' || v_paquete_nombre || '" fue confirmado exitosamente',
+                    jsonb_build_object(
+                        'pago_id', NEW.id,
+                        'compra_id', NEW.compra_id,
+                        'paquete_id', v_compra.paquete_id,
+                        'paquete_nombre', v_paquete_nombre,
+                        'confirmado_por', v_confirmado_por_nombre,
+                        'fecha_salida', v_fecha_salida,
+                        'monto', NEW.monto
+                    )
+                ) RETURNING id INTO v_notif_id;
+            ELSE
+                -- Pago parcial: la reserva queda retenida con anticipo hasta completar el saldo
+                UPDATE compras_paquetes
+                SET status = 'reservada_con_anticipo',
+                    monto_pagado = v_monto_pagado,
+                    updated_at = NOW()
+                WHERE id = NEW.compra_id;
+
+                INSERT INTO notificaciones (
+                    usuario_id,
+                    tipo,
+                    titulo,
+                    mensaje,
+                    datos_json
+                ) VALUES (
+                    v_compra.turista_id,
+                    'anticipo_confirmado',
+                    'Recibimos tu pago parcial',
+                    'Tu pago de Bs ' || NEW.monto || ' para "' || v_paquete_nombre || '" fue confirmado. Saldo pendiente: Bs '
+                        || ROUND(v_saldo, 2)
+                        || COALESCE(' (fecha límite ' || TO_CHAR(v_compra.fecha_limite_saldo, 'YYYY-MM-DD') || ')', ''),
+                    jsonb_build_object(
+                        'pago_id', NEW.id,
+                        'compra_id', NEW.compra_id,
+                        'paquete_id', v_compra.paquete_id,
+                        'paquete_nombre', v_paquete_nombre,
+                        'confirmado_por', v_confirmado_por_nombre,
+                        'monto', NEW.monto,
+                        'monto_pagado', v_monto_pagado,
+                        'saldo_pendiente', ROUND(v_saldo, 2),
+                        'fecha_limite_saldo', v_compra.fecha_limite_saldo
+                    )
+                ) RETURNING id INTO v_notif_id;
+            END IF;
 
             -- Notificar vía PostgreSQL NOTIFY
             PERFORM pg_notify('notificaciones', json_build_object(
@@ -754,17 +1028,8 @@ END $$;
 `
 
 // Trigger para rechazo de pago
-const triggerPagoRechazadoSignature = "public.fn_on_pago_rechazado()"
-
 func ensureTriggerPagoRechazado(db *gorm.DB) error {
-	var exists bool
-	if err := db.Raw(`SELECT to_regprocedure(?::text) IS NOT NULL`, triggerPagoRechazadoSignature).Scan(&exists).Error; err != nil {
-		return fmt.Errorf("failed to check fn_on_pago_rechazado existence: %w", err)
-	}
-	if exists {
-		return nil
-	}
-
+	// Always replace so existing databases get the current cupos handling.
 	if err := db.Exec(sqlTriggerPagoRechazado).Error; err != nil {
 		return fmt.Errorf("trigger pago rechazado bootstrap failed: %w", err)
 	}
@@ -773,7 +1038,8 @@ func ensureTriggerPagoRechazado(db *gorm.DB) error {
 }
 
 const sqlTriggerPagoRechazado = `
--- Función trigger para cuando un pago es rechazado
+-- Función trigger para cuando un pago es rechazado.
+-- Los cupos no se liberan aquí: PagoService.RechazarPago los libera con InventarioService.
 CREATE OR REPLACE FUNCTION public.fn_on_pago_rechazado()
 RETURNS TRIGGER AS $$
 DECLARE
@@ -787,7 +1053,8 @@ BEGIN
         SELECT id, salida_id, total_participantes, status, turista_id, paquete_id
         INTO v_compra
         FROM compras_paquetes
-        WHERE id = NEW.compra_id;
+        WHERE id = NEW.compra_id
+        FOR UPDATE;
 
         IF NOT FOUND THEN
             RAISE EXCEPTION 'Compra no encontrada: %', NEW.compra_id;
@@ -803,14 +1070,6 @@ BEGIN
                 updated_at = NOW()
             WHERE id = NEW.compra_id;
 
-            -- Liberar cupos reservados
-            IF v_compra.salida_id IS NOT NULL THEN
-                UPDATE paquete_salidas_habilitadas
-                SET cupos_reservados = GREATEST(0, cupos_reservados - v_compra.total_participantes),
-                    updated_at = NOW()
-                WHERE id = v_compra.salida_id;
-            END IF;
-
             -- Obtener nombre del paquete
             SELECT nombre INTO v_paquete_nombre
             FROM paquetes_turisticos
@@ -901,6 +1160,11 @@ DECLARE
     v_turista_nombre TEXT;
     v_notif_id INTEGER;
 BEGIN
+    -- Los pagos en línea los confirma la pasarela: no requieren revisión del encargado
+    IF NEW.metodo_pago = 'pasarela' THEN
+        RETURN NEW;
+    END IF;
+
     -- Obtener datos de la compra, paquete y turista
     SELECT
         pt.id,
@@ -982,3 +1246,75 @@ END $$;
 
 	return db.Exec(sqlTriggerNuevoPago).Error
 }
+
+// ensureSalidaCuposConstraint agrega la restricción que impide contadores de cupos negativos o por encima
+// del cupo máximo. Se crea NOT VALID para no bloquear el arranque con datos previos inconsistentes: rige
+// para toda escritura nueva y se valida sobre las filas existentes cuando estas quedan corregidas.
+func ensureSalidaCuposConstraint(db *gorm.DB) error {
+	if err := db.Exec(sqlSalidaCuposConstraint).Error; err != nil {
+		return fmt.Errorf("chk_salida_cupos bootstrap failed: %w", err)
+	}
+
+	var validada bool
+	if err := db.Raw(`SELECT convalidated FROM pg_constraint WHERE conname = 'chk_salida_cupos'`).Scan(&validada).Error; err != nil {
+		return fmt.Errorf("failed to check chk_salida_cupos: %w", err)
+	}
+	if validada {
+		return nil
+	}
+
+	if err := db.Exec(`ALTER TABLE paquete_salidas_habilitadas VALIDATE CONSTRAINT chk_salida_cupos`).Error; err != nil {
+		var ids []uint
+		db.Raw(`
+			SELECT id FROM paquete_salidas_habilitadas
+			WHERE NOT (` + condicionSalidaCupos + `)
+			ORDER BY id
+			LIMIT 20
+		`).Scan(&ids)
+		log.Printf("Warning: salidas con cupos inconsistentes, corrija sus contadores (ids: %v): %v", ids, err)
+	}
+
+	return nil
+}
+
+const condicionSalidaCupos = `cupos_reservados >= 0
+        AND cupos_confirmados >= 0
+        AND COALESCE(cupos_ofertados, 0) >= 0
+        AND cupos_reservados + cupos_confirmados + COALESCE(cupos_ofertados, 0) <= cupo_maximo`
+
+const sqlSalidaCuposConstraint = `
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'chk_salida_cupos'
+    ) THEN
+        ALTER TABLE paquete_salidas_habilitadas
+            ADD CONSTRAINT chk_salida_cupos CHECK (
+                ` + condicionSalidaCupos + `
+            ) NOT VALID;
+    END IF;
+END $$;
+`
+
+// Una sola tarifa por combinación de agencia y categoría (NULL cuenta como "cualquiera")
+func ensureTarifaComisionUnica(db *gorm.DB) error {
+	if err := db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS uniq_tarifa_comision
+		ON tarifas_comision (COALESCE(agencia_id, 0), COALESCE(categoria_id, 0))
+	`).Error; err != nil {
+		return fmt.Errorf("uniq_tarifa_comision bootstrap failed: %w", err)
+	}
+	return nil
+}
+
+// La búsqueda de comprobantes parecidos recorre por fecha solo los pagos con hash perceptual
+func ensureIndiceComprobantesSimilares(db *gorm.DB) error {
+	if err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_pagos_compras_phash_fecha
+		ON pagos_compras (created_at)
+		WHERE comprobante_phash IS NOT NULL
+	`).Error; err != nil {
+		return fmt.Errorf("idx_pagos_compras_phash_fecha bootstrap failed: %w", err)
+	}
+	return nil
+}
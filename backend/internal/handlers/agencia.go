@@ -93,6 +93,15 @@ func (h *AgenciaHandler) GetAgencias(w http.ResponseWriter, r *http.Request) {
 		query = query.Where("visible_publico = ?", visible == "true")
 	}
 
+	if ratingMin := r.URL.Query().Get("rating_min"); ratingMin != "" {
+		min, err := strconv.ParseFloat(ratingMin, 64)
+		if err != nil || min < 0 || min > 5 {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "rating_min inválido (0 a 5)", nil, http.StatusBadRequest)
+			return
+		}
+		query = query.Where("agencias_turismo.rating_promedio >= ?", min)
+	}
+
 	// Paginación
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page < 1 {
@@ -109,6 +118,9 @@ func (h *AgenciaHandler) GetAgencias(w http.ResponseWriter, r *http.Request) {
 	if sortBy == "" {
 		sortBy = "created_at"
 	}
+	if sortBy == "rating" {
+		sortBy = "agencias_turismo.rating_promedio"
+	}
 
 	sortOrder := strings.ToLower(r.URL.Query().Get("sort_order"))
 	if sortOrder != "asc" {
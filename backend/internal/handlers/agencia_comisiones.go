@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// GetAgenciaComisiones retorna lo que la agencia adeuda a la plataforma, las tarifas que le aplican y los
+// asientos del rango (mes/anio o fecha_inicio/fecha_fin).
+func (h *AgenciaHandler) GetAgenciaComisiones(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := h.loadAgenciaForReport(w, r)
+	if !ok {
+		return
+	}
+
+	rango, err := parseReportRange(r)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	comisionService := services.NewComisionService(database.GetDB())
+	cuenta, err := comisionService.CuentaAgencia(agencia.ID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener la cuenta de comisiones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	asientos, total, err := comisionService.ListarAsientos(agencia.ID, rango.Start, rango.End, page, pageSize)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener los movimientos de comisiones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"rango": map[string]interface{}{
+			"inicio": rango.StartLabel,
+			"fin":    rango.EndLabel,
+		},
+		"cuenta":   cuenta,
+		"asientos": asientos,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, "Comisiones obtenidas exitosamente", http.StatusOK)
+}
+
+// GetAgenciaLiquidaciones lista las liquidaciones de comisiones de la agencia.
+func (h *AgenciaHandler) GetAgenciaLiquidaciones(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := h.loadAgenciaForReport(w, r)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	liquidaciones, total, err := services.NewComisionService(database.GetDB()).ListarLiquidaciones(agencia.ID, page, pageSize)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener las liquidaciones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"liquidaciones": liquidaciones,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, "Liquidaciones obtenidas exitosamente", http.StatusOK)
+}
+
+// GetAgenciaLiquidacion retorna una liquidación con sus asientos, en JSON, CSV o PDF (formato).
+func (h *AgenciaHandler) GetAgenciaLiquidacion(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := h.loadAgenciaForReport(w, r)
+	if !ok {
+		return
+	}
+
+	liquidacionID, err := strconv.ParseUint(mux.Vars(r)["liquidacion_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de liquidacion invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	format := parseReportFormat(r)
+	if format != "json" && format != "csv" && format != "pdf" {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "formato invalido (json|csv|pdf)", nil, http.StatusBadRequest)
+		return
+	}
+
+	liquidacion, err := services.NewComisionService(database.GetDB()).ObtenerLiquidacion(agencia.ID, uint(liquidacionID))
+	if err != nil {
+		if errors.Is(err, services.ErrLiquidacionNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener la liquidacion", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "json" {
+		utils.SuccessResponse(w, liquidacion, "Liquidacion obtenida exitosamente", http.StatusOK)
+		return
+	}
+
+	rango := reportRange{
+		Start:      liquidacion.PeriodoDesde,
+		End:        liquidacion.PeriodoHasta.AddDate(0, 0, 1),
+		StartLabel: liquidacion.PeriodoDesde.Format("2006-01-02"),
+		EndLabel:   liquidacion.PeriodoHasta.Format("2006-01-02"),
+		Month:      int(liquidacion.PeriodoHasta.Month()),
+		Year:       liquidacion.PeriodoHasta.Year(),
+	}
+
+	// Saldo acumulado tras cada asiento, partiendo del saldo de la liquidación anterior
+	saldo := liquidacion.SaldoAnterior
+	filas := make([][]string, 0, len(liquidacion.Asientos))
+	for i := range liquidacion.Asientos {
+		asiento := &liquidacion.Asientos[i]
+		efecto := asiento.EfectoSaldo()
+		saldo += efecto
+		filas = append(filas, []string{
+			asiento.Fecha.Format("2006-01-02"),
+			asiento.Tipo,
+			asiento.Descripcion,
+			fmt.Sprintf("%.2f", asiento.MontoBase),
+			formatPorcentajeComision(asiento.Porcentaje),
+			fmt.Sprintf("%.2f", efecto),
+			fmt.Sprintf("%.2f", saldo),
+		})
+	}
+
+	filename := reportFilename("liquidacion_comisiones", rango, format)
+	if format == "csv" {
+		csvRows := [][]string{
+			{"Fecha", "Tipo", "Descripcion", "Monto base", "Porcentaje", "Movimiento", "Saldo"},
+			{"", "", "Saldo anterior", "", "", "", fmt.Sprintf("%.2f", liquidacion.SaldoAnterior)},
+		}
+		csvRows = append(csvRows, filas...)
+		if err := writeCSV(w, filename, csvRows); err != nil {
+			utils.ErrorResponse(w, "EXPORT_ERROR", "Error al generar CSV", err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	pdf := newReportPDF("Liquidacion de comisiones", agencia, rango)
+	pdfKeyValue(pdf, "Saldo anterior", fmt.Sprintf("Bs %.2f", liquidacion.SaldoAnterior))
+	pdfKeyValue(pdf, "Ventas brutas", fmt.Sprintf("Bs %.2f", liquidacion.VentasBrutas))
+	pdfKeyValue(pdf, "Reembolsos", fmt.Sprintf("Bs %.2f", liquidacion.Reembolsos))
+	pdfKeyValue(pdf, "Comision", fmt.Sprintf("Bs %.2f", liquidacion.Comision))
+	pdfKeyValue(pdf, "Ajustes", fmt.Sprintf("Bs %.2f", liquidacion.Ajustes))
+	pdfKeyValue(pdf, "Pagos recibidos", fmt.Sprintf("Bs %.2f", liquidacion.Pagos))
+	pdfKeyValue(pdf, "Saldo a pagar", fmt.Sprintf("Bs %.2f", liquidacion.SaldoFinal))
+	pdf.Ln(4)
+
+	headers := []string{"Fecha", "Tipo", "Descripcion", "Base", "%", "Movim.", "Saldo"}
+	widths := []float64{22, 24, 62, 22, 12, 24, 24}
+	pdfRows := make([][]string, 0, len(filas))
+	for _, fila := range filas {
+		pdfRows = append(pdfRows, []string{
+			fila[0],
+			fila[1],
+			truncateText(fila[2], 34),
+			fila[3],
+			fila[4],
+			fila[5],
+			fila[6],
+		})
+	}
+	pdfTable(pdf, headers, widths, pdfRows)
+
+	if err := writePDF(w, filename, pdf); err != nil {
+		utils.ErrorResponse(w, "EXPORT_ERROR", "Error al generar PDF", err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func formatPorcentajeComision(porcentaje *float64) string {
+	if porcentaje == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *porcentaje)
+}
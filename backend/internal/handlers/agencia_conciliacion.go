@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Tamaño máximo de un extracto bancario importado.
+const maxArchivoExtracto = 5 << 20
+
+func parseConciliacionIDParam(w http.ResponseWriter, r *http.Request, nombre string, etiqueta string) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)[nombre], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de "+etiqueta+" invalido", nil, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// errorConciliacion responde 404 para formatos o extractos inexistentes y 400 para el resto.
+func errorConciliacion(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrFormatoExtractoNoEncontrado) || errors.Is(err, services.ErrExtractoNoEncontrado) {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+	utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+}
+
+// GetAgenciaFormatosExtracto lista los formatos de extracto configurados por banco.
+func (h *AgenciaHandler) GetAgenciaFormatosExtracto(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	formatos, err := services.NewConciliacionService(database.GetDB()).ListarFormatos(agencia.ID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener formatos de extracto", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"formatos": formatos,
+	}, "Formatos de extracto obtenidos exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaFormatoExtracto registra cómo leer el extracto de un banco.
+func (h *AgenciaHandler) CreateAgenciaFormatoExtracto(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.FormatoExtractoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	formato, err := services.NewConciliacionService(database.GetDB()).CrearFormato(agencia.ID, &req)
+	if err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, formato, "Formato de extracto creado exitosamente", http.StatusCreated)
+}
+
+// UpdateAgenciaFormatoExtracto modifica el formato de extracto de un banco.
+func (h *AgenciaHandler) UpdateAgenciaFormatoExtracto(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	formatoID, ok := parseConciliacionIDParam(w, r, "formato_id", "formato")
+	if !ok {
+		return
+	}
+
+	var req models.FormatoExtractoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	formato, err := services.NewConciliacionService(database.GetDB()).ActualizarFormato(agencia.ID, formatoID, &req)
+	if err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, formato, "Formato de extracto actualizado exitosamente", http.StatusOK)
+}
+
+// DeleteAgenciaFormatoExtracto elimina un formato; los extractos ya importados se conservan.
+func (h *AgenciaHandler) DeleteAgenciaFormatoExtracto(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	formatoID, ok := parseConciliacionIDParam(w, r, "formato_id", "formato")
+	if !ok {
+		return
+	}
+
+	if err := services.NewConciliacionService(database.GetDB()).EliminarFormato(agencia.ID, formatoID); err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Formato de extracto eliminado exitosamente", http.StatusOK)
+}
+
+// ImportarAgenciaExtracto importa un extracto bancario (campo "archivo", CSV o .xlsx) con el formato
+// formato_id y propone coincidencias con los pagos pendientes. ventana_dias es opcional.
+func (h *AgenciaHandler) ImportarAgenciaExtracto(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxArchivoExtracto + 1<<20); err != nil {
+		utils.ErrorResponse(w, "PARSE_ERROR", "Error al procesar el formulario", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	formatoID, err := strconv.ParseUint(strings.TrimSpace(r.FormValue("formato_id")), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "formato_id invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	ventanaDias := 0
+	if value := strings.TrimSpace(r.FormValue("ventana_dias")); value != "" {
+		ventanaDias, err = strconv.Atoi(value)
+		if err != nil || ventanaDias < 0 {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "ventana_dias invalido", nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	file, header, err := r.FormFile("archivo")
+	if err != nil {
+		utils.ErrorResponse(w, "NO_FILE", "No se proporcionó ningún archivo", err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxArchivoExtracto {
+		utils.ErrorResponse(w, "FILE_TOO_LARGE", "El archivo no debe superar 5MB", nil, http.StatusBadRequest)
+		return
+	}
+
+	contenido, err := io.ReadAll(io.LimitReader(file, maxArchivoExtracto))
+	if err != nil {
+		utils.ErrorResponse(w, "PARSE_ERROR", "No se pudo leer el archivo", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resultado, err := services.NewConciliacionService(database.GetDB()).
+		ImportarExtracto(agencia.ID, claims.UserID, uint(formatoID), header.Filename, contenido, ventanaDias)
+	if err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, resultado, "Extracto importado exitosamente", http.StatusCreated)
+}
+
+// GetAgenciaExtractos lista los extractos importados por la agencia.
+func (h *AgenciaHandler) GetAgenciaExtractos(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	extractos, total, err := services.NewConciliacionService(database.GetDB()).ListarExtractos(agencia.ID, page, pageSize)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener extractos", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"extractos": extractos,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, "Extractos obtenidos exitosamente", http.StatusOK)
+}
+
+// GetAgenciaExtracto retorna un extracto con sus líneas y los pagos propuestos.
+func (h *AgenciaHandler) GetAgenciaExtracto(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	extractoID, ok := parseConciliacionIDParam(w, r, "extracto_id", "extracto")
+	if !ok {
+		return
+	}
+
+	extracto, err := services.NewConciliacionService(database.GetDB()).ObtenerExtracto(agencia.ID, extractoID)
+	if err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, extracto, "Extracto obtenido exitosamente", http.StatusOK)
+}
+
+// ConciliarAgenciaExtracto vuelve a buscar coincidencias para las líneas sin conciliar (p. ej. después
+// de que los turistas registraron nuevos pagos).
+func (h *AgenciaHandler) ConciliarAgenciaExtracto(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	extractoID, ok := parseConciliacionIDParam(w, r, "extracto_id", "extracto")
+	if !ok {
+		return
+	}
+
+	propuestas, err := services.NewConciliacionService(database.GetDB()).ProponerCoincidencias(agencia.ID, extractoID)
+	if err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"propuestas": propuestas,
+	}, "Coincidencias actualizadas", http.StatusOK)
+}
+
+// ConfirmarAgenciaExtracto confirma en bloque los pagos de las líneas indicadas.
+func (h *AgenciaHandler) ConfirmarAgenciaExtracto(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	extractoID, ok := parseConciliacionIDParam(w, r, "extracto_id", "extracto")
+	if !ok {
+		return
+	}
+
+	var req models.ConfirmarConciliacionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resultados, err := services.NewConciliacionService(database.GetDB()).ConfirmarCoincidencias(agencia.ID, extractoID, claims.UserID, &req)
+	if err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	confirmados := 0
+	for _, resultado := range resultados {
+		if resultado.Confirmado {
+			confirmados++
+		}
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"confirmados": confirmados,
+		"resultados":  resultados,
+	}, "Conciliación procesada", http.StatusOK)
+}
+
+// IgnorarAgenciaExtractoLinea marca un crédito del extracto que no corresponde a un pago de turista.
+func (h *AgenciaHandler) IgnorarAgenciaExtractoLinea(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	extractoID, ok := parseConciliacionIDParam(w, r, "extracto_id", "extracto")
+	if !ok {
+		return
+	}
+	lineaID, ok := parseConciliacionIDParam(w, r, "linea_id", "linea")
+	if !ok {
+		return
+	}
+
+	if err := services.NewConciliacionService(database.GetDB()).IgnorarLinea(agencia.ID, extractoID, lineaID); err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Linea ignorada", http.StatusOK)
+}
+
+// GetAgenciaExtractoPendientes lista los créditos sin pago y los pagos pendientes sin crédito del período.
+func (h *AgenciaHandler) GetAgenciaExtractoPendientes(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	extractoID, ok := parseConciliacionIDParam(w, r, "extracto_id", "extracto")
+	if !ok {
+		return
+	}
+
+	pendientes, err := services.NewConciliacionService(database.GetDB()).Pendientes(agencia.ID, extractoID)
+	if err != nil {
+		errorConciliacion(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, pendientes, "Pendientes de conciliación obtenidos", http.StatusOK)
+}
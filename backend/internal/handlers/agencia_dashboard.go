@@ -264,6 +264,15 @@ func (h *AgenciaHandler) GetAgenciaDashboard(w http.ResponseWriter, r *http.Requ
         return
     }
 
+    // Solicitudes de tour privado esperando cotización
+    var solicitudesPendientes int64
+    if err := db.Model(&models.SolicitudPrivada{}).
+        Where("agencia_id = ? AND estado = ?", agencia.ID, "pendiente").
+        Count(&solicitudesPendientes).Error; err != nil {
+        utils.ErrorResponse(w, "DB_ERROR", "Error al obtener solicitudes privadas", err.Error(), http.StatusInternalServerError)
+        return
+    }
+
     response := map[string]interface{}{
         "mes":  mes,
         "anio": anio,
@@ -293,6 +302,7 @@ func (h *AgenciaHandler) GetAgenciaDashboard(w http.ResponseWriter, r *http.Requ
         "alertas": map[string]interface{}{
             "pagos_pendientes": metrics.PendientesPago,
             "salidas_proximas": salidasProximas,
+            "solicitudes_privadas_pendientes": solicitudesPendientes,
         },
     }
 
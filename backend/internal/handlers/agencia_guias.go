@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type guiaRequest struct {
+	Nombre              *string   `json:"nombre"`
+	Telefono            *string   `json:"telefono"`
+	Email               *string   `json:"email"`
+	UsuarioEmail        *string   `json:"usuario_email"` // vincula al guía con una cuenta ("" desvincula)
+	Idiomas             *[]string `json:"idiomas"`
+	Certificaciones     *[]string `json:"certificaciones"`
+	NumeroLicencia      *string   `json:"numero_licencia"`
+	LicenciaVencimiento *string   `json:"licencia_vencimiento"` // YYYY-MM-DD ("" = sin vencimiento)
+	Notas               *string   `json:"notas"`
+	Activo              *bool     `json:"activo"`
+}
+
+func loadAgenciaGuia(w http.ResponseWriter, r *http.Request, agenciaID uint) (*models.Guia, bool) {
+	guiaID, err := strconv.ParseUint(mux.Vars(r)["guia_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de guia invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var guia models.Guia
+	if err := database.GetDB().Where("id = ? AND agencia_id = ?", uint(guiaID), agenciaID).First(&guia).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Guia no encontrado", nil, http.StatusNotFound)
+			return nil, false
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener guia", err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &guia, true
+}
+
+func optionalTrimmed(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+func cleanStringList(values []string) models.StringArray {
+	out := models.StringArray{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// applyGuiaRequest valida el request y copia los valores al guía.
+func applyGuiaRequest(db *gorm.DB, agenciaID uint, guia *models.Guia, req *guiaRequest) error {
+	if req.Nombre != nil {
+		guia.Nombre = strings.TrimSpace(*req.Nombre)
+	}
+	if guia.Nombre == "" || len(guia.Nombre) > 255 {
+		return errors.New("nombre es obligatorio (maximo 255 caracteres)")
+	}
+
+	if req.Telefono != nil {
+		guia.Telefono = optionalTrimmed(req.Telefono)
+		if guia.Telefono != nil && len(*guia.Telefono) > 20 {
+			return errors.New("telefono invalido (maximo 20 caracteres)")
+		}
+	}
+	if req.Email != nil {
+		guia.Email = optionalTrimmed(req.Email)
+	}
+
+	if req.UsuarioEmail != nil {
+		email := strings.ToLower(strings.TrimSpace(*req.UsuarioEmail))
+		if email == "" {
+			guia.UsuarioID = nil
+		} else {
+			var usuario models.Usuario
+			if err := db.Select("id").Where("LOWER(email) = ? AND status = ?", email, "active").First(&usuario).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errors.New("no existe un usuario activo con ese email")
+				}
+				return err
+			}
+
+			var count int64
+			query := db.Model(&models.Guia{}).Where("agencia_id = ? AND usuario_id = ?", agenciaID, usuario.ID)
+			if guia.ID != 0 {
+				query = query.Where("id <> ?", guia.ID)
+			}
+			if err := query.Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errors.New("el usuario ya esta vinculado a otro guia de la agencia")
+			}
+			guia.UsuarioID = &usuario.ID
+		}
+	}
+
+	if req.Idiomas != nil {
+		guia.Idiomas = cleanStringList(*req.Idiomas)
+	}
+	if guia.Idiomas == nil {
+		guia.Idiomas = models.StringArray{}
+	}
+	if req.Certificaciones != nil {
+		guia.Certificaciones = cleanStringList(*req.Certificaciones)
+	}
+	if guia.Certificaciones == nil {
+		guia.Certificaciones = models.StringArray{}
+	}
+
+	if req.NumeroLicencia != nil {
+		guia.NumeroLicencia = optionalTrimmed(req.NumeroLicencia)
+	}
+	if req.LicenciaVencimiento != nil {
+		value := strings.TrimSpace(*req.LicenciaVencimiento)
+		if value == "" {
+			guia.LicenciaVencimiento = nil
+		} else {
+			fecha, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return errors.New("licencia_vencimiento invalida (YYYY-MM-DD)")
+			}
+			guia.LicenciaVencimiento = &fecha
+		}
+	}
+
+	if req.Notas != nil {
+		guia.Notas = optionalTrimmed(req.Notas)
+	}
+	if req.Activo != nil {
+		guia.Activo = *req.Activo
+	}
+
+	return nil
+}
+
+// parseRangoCalendario lee desde/hasta (YYYY-MM-DD). Por defecto: hoy a +60 días.
+func parseRangoCalendario(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	hoy := time.Now().UTC().Truncate(24 * time.Hour)
+	desde, hasta := hoy, hoy.AddDate(0, 0, 60)
+
+	if value := strings.TrimSpace(r.URL.Query().Get("desde")); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "desde invalido (YYYY-MM-DD)", nil, http.StatusBadRequest)
+			return desde, hasta, false
+		}
+		desde = parsed
+	}
+	if value := strings.TrimSpace(r.URL.Query().Get("hasta")); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "hasta invalido (YYYY-MM-DD)", nil, http.StatusBadRequest)
+			return desde, hasta, false
+		}
+		hasta = parsed
+	}
+	if hasta.Before(desde) {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "hasta debe ser posterior a desde", nil, http.StatusBadRequest)
+		return desde, hasta, false
+	}
+	if hasta.Sub(desde) > 366*24*time.Hour {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "El rango maximo es de un año", nil, http.StatusBadRequest)
+		return desde, hasta, false
+	}
+
+	return desde, hasta, true
+}
+
+// GetAgenciaGuias lista los guías de la agencia. ?activo=true|false filtra por estado.
+func (h *AgenciaHandler) GetAgenciaGuias(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	query := database.GetDB().Where("agencia_id = ?", agencia.ID)
+	if value := strings.TrimSpace(r.URL.Query().Get("activo")); value != "" {
+		activo, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "activo invalido (true|false)", nil, http.StatusBadRequest)
+			return
+		}
+		query = query.Where("activo = ?", activo)
+	}
+
+	var guias []models.Guia
+	if err := query.Order("activo DESC").Order("nombre ASC").Find(&guias).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener guias", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"guias": guias,
+	}, "Guias obtenidos exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaGuia registra un guía en el staff de la agencia.
+func (h *AgenciaHandler) CreateAgenciaGuia(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req guiaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	guia := models.Guia{AgenciaID: agencia.ID, Activo: true}
+	if err := applyGuiaRequest(db, agencia.ID, &guia, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := db.Create(&guia).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al crear guia", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, guia, "Guia creado exitosamente", http.StatusCreated)
+}
+
+// UpdateAgenciaGuia actualiza los datos de un guía. Las asignaciones existentes no se revalidan.
+func (h *AgenciaHandler) UpdateAgenciaGuia(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	guia, ok := loadAgenciaGuia(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	var req guiaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if err := applyGuiaRequest(db, agencia.ID, guia, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := db.Omit("Usuario").Save(guia).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al actualizar guia", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, guia, "Guia actualizado exitosamente", http.StatusOK)
+}
+
+// DeleteAgenciaGuia elimina un guía sin asignaciones o lo desactiva si ya fue asignado a salidas.
+func (h *AgenciaHandler) DeleteAgenciaGuia(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	guia, ok := loadAgenciaGuia(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var asignaciones int64
+	if err := db.Model(&models.SalidaGuia{}).Where("guia_id = ?", guia.ID).Count(&asignaciones).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al validar asignaciones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if asignaciones > 0 {
+		if err := db.Model(guia).Update("activo", false).Error; err != nil {
+			utils.ErrorResponse(w, "DB_ERROR", "Error al desactivar guia", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.SuccessResponse(w, nil, "El guia tiene salidas asignadas y fue desactivado", http.StatusOK)
+		return
+	}
+
+	if err := db.Delete(&models.Guia{}, guia.ID).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar guia", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Guia eliminado exitosamente", http.StatusOK)
+}
+
+// GetAgenciaGuiaCalendario retorna las salidas asignadas a un guía (?desde=&hasta=, YYYY-MM-DD).
+func (h *AgenciaHandler) GetAgenciaGuiaCalendario(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	guia, ok := loadAgenciaGuia(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	desde, hasta, ok := parseRangoCalendario(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := services.NewGuiaService(database.GetDB()).CalendarioGuia(agencia.ID, guia.ID, desde, hasta)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener calendario", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"guia":    guia,
+		"desde":   desde.Format("2006-01-02"),
+		"hasta":   hasta.Format("2006-01-02"),
+		"salidas": items,
+	}, "Calendario obtenido exitosamente", http.StatusOK)
+}
+
+// GetAgenciaSalidaGuias lista los guías asignados a una salida.
+func (h *AgenciaHandler) GetAgenciaSalidaGuias(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	guias, err := services.NewGuiaService(database.GetDB()).ObtenerGuiasSalida(agencia.ID, salidaID)
+	if err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"guias": guias,
+	}, "Guias de la salida obtenidos exitosamente", http.StatusOK)
+}
+
+// AsignarAgenciaSalidaGuias reemplaza los guías asignados a una salida.
+// Responde 409 con el detalle de las salidas superpuestas si algún guía ya está ocupado.
+func (h *AgenciaHandler) AsignarAgenciaSalidaGuias(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AsignarGuiasSalidaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	guias, err := services.NewGuiaService(database.GetDB()).AsignarGuias(agencia.ID, salidaID, claims.UserID, &req)
+	if err != nil {
+		var conflicto *services.ConflictoGuiasError
+		if errors.As(err, &conflicto) {
+			utils.ErrorResponse(w, "CONFLICT", err.Error(), conflicto.Conflictos, http.StatusConflict)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"guias": guias,
+	}, "Guias asignados exitosamente", http.StatusOK)
+}
+
+// GetMiCalendarioGuia retorna las salidas asignadas al usuario autenticado como guía.
+func (h *AgenciaHandler) GetMiCalendarioGuia(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	desde, hasta, ok := parseRangoCalendario(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := services.NewGuiaService(database.GetDB()).CalendarioUsuario(claims.UserID, desde, hasta)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener calendario", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"desde":   desde.Format("2006-01-02"),
+		"hasta":   hasta.Format("2006-01-02"),
+		"salidas": items,
+	}, "Calendario obtenido exitosamente", http.StatusOK)
+}
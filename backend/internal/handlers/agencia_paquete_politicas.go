@@ -17,8 +17,11 @@ import (
 
 type paquetePoliticasUpdateRequest struct {
 	EdadMinimaPago          *int     `json:"edad_minima_pago"`
+	EdadAdulto              *int     `json:"edad_adulto"`
 	RecargoPrivadoPorcentaje *float64 `json:"recargo_privado_porcentaje"`
 	PoliticaCancelacion     *string  `json:"politica_cancelacion"`
+	AnticipoPorcentaje      *float64 `json:"anticipo_porcentaje"`
+	DiasLimiteSaldo         *int     `json:"dias_limite_saldo"`
 }
 
 func ensurePaquetePoliticasRow(db *gorm.DB, agenciaID uint) (*models.PaquetePolitica, error) {
@@ -34,8 +37,11 @@ func ensurePaquetePoliticasRow(db *gorm.DB, agenciaID uint) (*models.PaquetePoli
 	politica = models.PaquetePolitica{
 		AgenciaID:                agenciaID,
 		EdadMinimaPago:           6,
+		EdadAdulto:               18,
 		RecargoPrivadoPorcentaje: 0,
 		PoliticaCancelacion:      nil,
+		AnticipoPorcentaje:       0,
+		DiasLimiteSaldo:          7,
 	}
 
 	if err := db.Create(&politica).Error; err != nil {
@@ -115,11 +121,26 @@ func (h *AgenciaHandler) UpdatePaquetePoliticas(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	if req.EdadAdulto != nil && (*req.EdadAdulto < 1 || *req.EdadAdulto > 25) {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "La edad adulta debe estar entre 1 y 25", nil, http.StatusBadRequest)
+		return
+	}
+
 	if req.RecargoPrivadoPorcentaje != nil && (*req.RecargoPrivadoPorcentaje < 0 || *req.RecargoPrivadoPorcentaje > 100) {
 		utils.ErrorResponse(w, "VALIDATION_ERROR", "El recargo debe estar entre 0 y 100", nil, http.StatusBadRequest)
 		return
 	}
 
+	if req.AnticipoPorcentaje != nil && (*req.AnticipoPorcentaje < 0 || *req.AnticipoPorcentaje >= 100) {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "El anticipo debe estar entre 0 y 99.99 (0 = pago completo)", nil, http.StatusBadRequest)
+		return
+	}
+
+	if req.DiasLimiteSaldo != nil && (*req.DiasLimiteSaldo < 0 || *req.DiasLimiteSaldo > 365) {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Los dias limite de saldo deben estar entre 0 y 365", nil, http.StatusBadRequest)
+		return
+	}
+
 	if req.PoliticaCancelacion != nil && len(*req.PoliticaCancelacion) > 5000 {
 		utils.ErrorResponse(w, "VALIDATION_ERROR", "La politica de cancelacion es demasiado larga", nil, http.StatusBadRequest)
 		return
@@ -137,10 +158,27 @@ func (h *AgenciaHandler) UpdatePaquetePoliticas(w http.ResponseWriter, r *http.R
 		politica.EdadMinimaPago = *req.EdadMinimaPago
 	}
 
+	if req.EdadAdulto != nil {
+		politica.EdadAdulto = *req.EdadAdulto
+	}
+
+	if politica.EdadMinimaPago >= politica.EdadAdulto {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "La edad minima de pago debe ser menor a la edad adulta", nil, http.StatusBadRequest)
+		return
+	}
+
 	if req.RecargoPrivadoPorcentaje != nil {
 		politica.RecargoPrivadoPorcentaje = *req.RecargoPrivadoPorcentaje
 	}
 
+	if req.AnticipoPorcentaje != nil {
+		politica.AnticipoPorcentaje = *req.AnticipoPorcentaje
+	}
+
+	if req.DiasLimiteSaldo != nil {
+		politica.DiasLimiteSaldo = *req.DiasLimiteSaldo
+	}
+
 	if req.PoliticaCancelacion != nil {
 		trimmed := strings.TrimSpace(*req.PoliticaCancelacion)
 		if trimmed == "" {
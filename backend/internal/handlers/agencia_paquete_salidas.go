@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,14 +11,17 @@ import (
 
 	"andaria-backend/internal/database"
 	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
 	"andaria-backend/pkg/utils"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 var allowedSalidaEstado = map[string]bool{
 	"pendiente":  true,
 	"activa":     true,
+	"en_curso":   true,
 	"completada": true,
 	"cancelada":  true,
 }
@@ -30,6 +35,10 @@ type updateSalidaRequest struct {
 	GuiaTelefono          *string `json:"guia_telefono"`
 	Estado                *string `json:"estado"`
 	RazonCancelacion      *string `json:"razon_cancelacion"`
+
+	// Precio especial de la salida (0 lo elimina)
+	PrecioBaseNacionales       *float64 `json:"precio_base_nacionales"`
+	PrecioAdicionalExtranjeros *float64 `json:"precio_adicional_extranjeros"`
 }
 
 type createSalidaRequest struct {
@@ -143,6 +152,16 @@ func (h *AgenciaHandler) CreatePaqueteSalida(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	bloqueada, err := services.FechaBloqueada(db, agencia.ID, paquete.ID, fechaStr)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al validar fechas bloqueadas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if bloqueada {
+		utils.ErrorResponse(w, "DATE_BLOCKED", "La agencia tiene bloqueada esa fecha", nil, http.StatusBadRequest)
+		return
+	}
+
 	maxSalidasDia := 5
 	maxSalidasHorario := 3
 	var capacidad models.AgenciaCapacidad
@@ -288,63 +307,131 @@ func (h *AgenciaHandler) UpdatePaqueteSalida(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	db := database.GetDB()
-	var salida models.PaqueteSalidaHabilitada
-	if err := db.Where("id = ? AND paquete_id = ?", salidaID, paqueteID).First(&salida).Error; err != nil {
-		utils.ErrorResponse(w, "NOT_FOUND", "Salida no encontrada", nil, http.StatusNotFound)
-		return
-	}
-
 	var req updateSalidaRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
 		return
 	}
 
-	if req.PuntoEncuentro != nil {
-		salida.PuntoEncuentro = normalizeStringPtr(req.PuntoEncuentro)
-	}
-	if req.HoraEncuentro != nil {
-		salida.HoraEncuentro = normalizeStringPtr(req.HoraEncuentro)
-	}
-	if req.NotasLogistica != nil {
-		salida.NotasLogistica = normalizeStringPtr(req.NotasLogistica)
-	}
-	if req.InstruccionesTuristas != nil {
-		salida.InstruccionesTuristas = normalizeStringPtr(req.InstruccionesTuristas)
-	}
-	if req.GuiaNombre != nil {
-		salida.GuiaNombre = normalizeStringPtr(req.GuiaNombre)
-	}
-	if req.GuiaTelefono != nil {
-		salida.GuiaTelefono = normalizeStringPtr(req.GuiaTelefono)
-	}
-
+	var estadoPedido string
 	if req.Estado != nil {
-		estado := strings.TrimSpace(*req.Estado)
-		if !allowedSalidaEstado[estado] {
+		estadoPedido = strings.TrimSpace(*req.Estado)
+		if !allowedSalidaEstado[estadoPedido] {
 			utils.ErrorResponse(w, "VALIDATION_ERROR", "Estado inválido", nil, http.StatusBadRequest)
 			return
 		}
-		salida.Estado = estado
 	}
 
-	if salida.Estado == "cancelada" {
-		rc := normalizeStringPtr(req.RazonCancelacion)
-		if rc == nil {
-			utils.ErrorResponse(w, "VALIDATION_ERROR", "Debe especificar razon_cancelacion al cancelar", nil, http.StatusBadRequest)
-			return
+	// La salida se edita bloqueada y solo se escriben las columnas editadas: los cupos los mueve
+	// InventarioService y no se sobrescriben con valores leídos antes
+	db := database.GetDB()
+	var salida models.PaqueteSalidaHabilitada
+	var cancelar bool
+	var razonCancelacion *string
+	var errValidacion error
+	errSalidaNoEncontrada := errors.New("salida no encontrada")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		bloqueada, err := services.BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil || bloqueada.PaqueteID != paqueteID {
+			return errSalidaNoEncontrada
+		}
+		salida = *bloqueada
+
+		updates := map[string]interface{}{}
+		if req.PuntoEncuentro != nil {
+			updates["punto_encuentro"] = normalizeStringPtr(req.PuntoEncuentro)
 		}
-		salida.RazonCancelacion = rc
-	} else if req.RazonCancelacion != nil {
-		salida.RazonCancelacion = nil
+		if req.HoraEncuentro != nil {
+			updates["hora_encuentro"] = normalizeStringPtr(req.HoraEncuentro)
+		}
+		if req.NotasLogistica != nil {
+			updates["notas_logistica"] = normalizeStringPtr(req.NotasLogistica)
+		}
+		if req.InstruccionesTuristas != nil {
+			updates["instrucciones_turistas"] = normalizeStringPtr(req.InstruccionesTuristas)
+		}
+		if req.GuiaNombre != nil {
+			updates["guia_nombre"] = normalizeStringPtr(req.GuiaNombre)
+		}
+		if req.GuiaTelefono != nil {
+			updates["guia_telefono"] = normalizeStringPtr(req.GuiaTelefono)
+		}
+		if req.PrecioBaseNacionales != nil || req.PrecioAdicionalExtranjeros != nil {
+			base, adicional, err := services.CombinarPrecioSalida(req.PrecioBaseNacionales, req.PrecioAdicionalExtranjeros, salida.PrecioBaseNacionales, salida.PrecioAdicionalExtranjeros)
+			if err != nil {
+				errValidacion = err
+				return err
+			}
+			updates["precio_base_nacionales"] = base
+			updates["precio_adicional_extranjeros"] = adicional
+		}
+
+		estado := salida.Estado
+		if req.Estado != nil {
+			estado = estadoPedido
+		}
+
+		// Cancelar una salida vigente cancela también sus compras (ver SalidaService.CancelarSalida)
+		cancelar = estado == "cancelada" && salida.Estado != "cancelada"
+		if estado == "cancelada" {
+			razonCancelacion = normalizeStringPtr(req.RazonCancelacion)
+			if razonCancelacion == nil {
+				errValidacion = errors.New("Debe especificar razon_cancelacion al cancelar")
+				return errValidacion
+			}
+			if !cancelar {
+				updates["razon_cancelacion"] = razonCancelacion
+			}
+		} else {
+			if req.Estado != nil {
+				updates["estado"] = estado
+			}
+			if req.RazonCancelacion != nil {
+				updates["razon_cancelacion"] = nil
+			}
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+		updates["updated_at"] = time.Now()
+		if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(&salida, salida.ID).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errSalidaNoEncontrada):
+			utils.ErrorResponse(w, "NOT_FOUND", "Salida no encontrada", nil, http.StatusNotFound)
+		case errValidacion != nil:
+			utils.ErrorResponse(w, "VALIDATION_ERROR", errValidacion.Error(), nil, http.StatusBadRequest)
+		default:
+			utils.ErrorResponse(w, "DB_ERROR", "Error al actualizar salida", err.Error(), http.StatusInternalServerError)
+		}
+		return
 	}
 
-	if err := db.Save(&salida).Error; err != nil {
-		utils.ErrorResponse(w, "DB_ERROR", "Error al actualizar salida", err.Error(), http.StatusInternalServerError)
+	if cancelar {
+		if _, err := services.NewSalidaService(db).CancelarSalida(agencia.ID, salida.ID, *razonCancelacion); err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+			return
+		}
+		// CancelarSalida ya avisó a guías y turistas
+		db.First(&salida, salida.ID)
+		utils.SuccessResponse(w, salida, "Salida cancelada exitosamente", http.StatusOK)
 		return
 	}
 
+	if req.Estado != nil || req.PuntoEncuentro != nil || req.HoraEncuentro != nil || req.NotasLogistica != nil || req.InstruccionesTuristas != nil {
+		fecha := salida.FechaSalida
+		if len(fecha) > 10 {
+			fecha = fecha[:10]
+		}
+		mensaje := fmt.Sprintf("La salida de %s del %s fue actualizada (estado: %s). Revise los detalles.", paquete.Nombre, fecha, salida.Estado)
+		services.NotificarCambioSalidaGuias(db, salida.ID, mensaje)
+	}
+
 	utils.SuccessResponse(w, salida, "Salida actualizada exitosamente", http.StatusOK)
 }
 
@@ -410,6 +497,10 @@ func (h *AgenciaHandler) ActivarSalida(w http.ResponseWriter, r *http.Request) {
 		utils.ErrorResponse(w, "COMPLETED", "No se puede activar una salida completada", nil, http.StatusBadRequest)
 		return
 	}
+	if salida.Estado == "en_curso" {
+		utils.ErrorResponse(w, "IN_PROGRESS", "La salida ya está en curso", nil, http.StatusBadRequest)
+		return
+	}
 
 	// Validar cupo mínimo (opcional: el encargado puede forzar la activación)
 	var req struct {
@@ -430,12 +521,21 @@ func (h *AgenciaHandler) ActivarSalida(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Activar la salida
-	salida.Estado = "activa"
-	if err := db.Save(&salida).Error; err != nil {
-		utils.ErrorResponse(w, "DB_ERROR", "Error al activar salida", err.Error(), http.StatusInternalServerError)
+	// Activar la salida (solo el estado: los cupos los mueve InventarioService)
+	now := time.Now()
+	res := db.Model(&models.PaqueteSalidaHabilitada{}).
+		Where("id = ? AND estado = ?", salida.ID, salida.Estado).
+		Updates(map[string]interface{}{"estado": "activa", "updated_at": now})
+	if res.Error != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al activar salida", res.Error.Error(), http.StatusInternalServerError)
 		return
 	}
+	if res.RowsAffected == 0 {
+		utils.ErrorResponse(w, "CONFLICT", "La salida cambió mientras se activaba, intente nuevamente", nil, http.StatusConflict)
+		return
+	}
+	salida.Estado = "activa"
+	salida.UpdatedAt = now
 
 	mensaje := "Salida activada exitosamente"
 	if cuposActuales < salida.CupoMinimo {
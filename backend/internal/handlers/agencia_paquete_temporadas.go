@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var allowedTemporadaTipo = map[string]bool{
+	"rango_fechas":       true,
+	"dias_semana":        true,
+	"fechas_especificas": true,
+}
+
+type temporadaPrecioRequest struct {
+	Nombre                     *string   `json:"nombre"`
+	Tipo                       *string   `json:"tipo"`
+	FechaInicio                *string   `json:"fecha_inicio"`
+	FechaFin                   *string   `json:"fecha_fin"`
+	DiasSemana                 *[]int    `json:"dias_semana"` // 1 = lunes ... 7 = domingo
+	Fechas                     *[]string `json:"fechas"`      // YYYY-MM-DD
+	PrecioBaseNacionales       *float64  `json:"precio_base_nacionales"`
+	PrecioAdicionalExtranjeros *float64  `json:"precio_adicional_extranjeros"` // negativo = usar el del paquete
+	Prioridad                  *int      `json:"prioridad"`
+	Activa                     *bool     `json:"activa"`
+}
+
+func loadAgenciaPaqueteForManage(w http.ResponseWriter, r *http.Request) (*models.PaqueteTuristico, bool) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	paqueteID, err := strconv.ParseUint(mux.Vars(r)["paquete_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de paquete invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var paquete models.PaqueteTuristico
+	if err := database.GetDB().Where("id = ? AND agencia_id = ?", uint(paqueteID), agencia.ID).First(&paquete).Error; err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", "Paquete no encontrado", nil, http.StatusNotFound)
+		return nil, false
+	}
+
+	return &paquete, true
+}
+
+func loadPaqueteTemporada(w http.ResponseWriter, r *http.Request, paqueteID uint) (*models.PaqueteTemporadaPrecio, bool) {
+	temporadaID, err := strconv.ParseUint(mux.Vars(r)["temporada_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de temporada invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var temporada models.PaqueteTemporadaPrecio
+	if err := database.GetDB().Where("id = ? AND paquete_id = ?", uint(temporadaID), paqueteID).First(&temporada).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Temporada no encontrada", nil, http.StatusNotFound)
+			return nil, false
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener temporada", err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &temporada, true
+}
+
+// applyTemporadaPrecioRequest valida el request y copia los valores a la regla de temporada.
+func applyTemporadaPrecioRequest(t *models.PaqueteTemporadaPrecio, req *temporadaPrecioRequest) error {
+	if req.Nombre != nil {
+		t.Nombre = strings.TrimSpace(*req.Nombre)
+	}
+	if t.Nombre == "" || len(t.Nombre) > 100 {
+		return errors.New("nombre es obligatorio (maximo 100 caracteres)")
+	}
+
+	if req.Tipo != nil {
+		t.Tipo = strings.ToLower(strings.TrimSpace(*req.Tipo))
+	}
+	if !allowedTemporadaTipo[t.Tipo] {
+		return errors.New("tipo invalido (rango_fechas|dias_semana|fechas_especificas)")
+	}
+
+	if req.FechaInicio != nil {
+		fecha, err := normalizeDatePtr(req.FechaInicio)
+		if err != nil {
+			return errors.New("fecha_inicio invalida (YYYY-MM-DD)")
+		}
+		t.FechaInicio = fecha
+	}
+	if req.FechaFin != nil {
+		fecha, err := normalizeDatePtr(req.FechaFin)
+		if err != nil {
+			return errors.New("fecha_fin invalida (YYYY-MM-DD)")
+		}
+		t.FechaFin = fecha
+	}
+	if t.FechaInicio != nil && t.FechaFin != nil && (*t.FechaFin)[:10] < (*t.FechaInicio)[:10] {
+		return errors.New("fecha_fin no puede ser anterior a fecha_inicio")
+	}
+
+	if req.DiasSemana != nil {
+		vistos := map[int]bool{}
+		dias := models.StringArray{}
+		for _, d := range *req.DiasSemana {
+			if d < 1 || d > 7 {
+				return errors.New("dias_semana invalido (1 = lunes ... 7 = domingo)")
+			}
+			if !vistos[d] {
+				vistos[d] = true
+				dias = append(dias, strconv.Itoa(d))
+			}
+		}
+		t.DiasSemana = dias
+	}
+
+	if req.Fechas != nil {
+		vistas := map[string]bool{}
+		fechas := models.StringArray{}
+		for _, raw := range *req.Fechas {
+			f := strings.TrimSpace(raw)
+			if _, err := time.Parse("2006-01-02", f); err != nil {
+				return errors.New("fechas contiene una fecha invalida (YYYY-MM-DD)")
+			}
+			if !vistas[f] {
+				vistas[f] = true
+				fechas = append(fechas, f)
+			}
+		}
+		t.Fechas = fechas
+	}
+
+	switch t.Tipo {
+	case "rango_fechas":
+		if t.FechaInicio == nil || t.FechaFin == nil {
+			return errors.New("rango_fechas requiere fecha_inicio y fecha_fin")
+		}
+	case "dias_semana":
+		if len(t.DiasSemana) == 0 {
+			return errors.New("dias_semana requiere al menos un dia")
+		}
+	case "fechas_especificas":
+		if len(t.Fechas) == 0 {
+			return errors.New("fechas_especificas requiere al menos una fecha")
+		}
+	}
+
+	if req.PrecioBaseNacionales != nil {
+		t.PrecioBaseNacionales = *req.PrecioBaseNacionales
+	}
+	if t.PrecioBaseNacionales <= 0 {
+		return errors.New("precio_base_nacionales debe ser mayor a 0")
+	}
+
+	if req.PrecioAdicionalExtranjeros != nil {
+		if *req.PrecioAdicionalExtranjeros < 0 {
+			t.PrecioAdicionalExtranjeros = nil
+		} else {
+			value := *req.PrecioAdicionalExtranjeros
+			t.PrecioAdicionalExtranjeros = &value
+		}
+	}
+
+	if req.Prioridad != nil {
+		t.Prioridad = *req.Prioridad
+	}
+	if req.Activa != nil {
+		t.Activa = *req.Activa
+	}
+
+	return nil
+}
+
+// GetPaqueteTemporadasPrecio lista las reglas de precio por temporada de un paquete.
+func (h *AgenciaHandler) GetPaqueteTemporadasPrecio(w http.ResponseWriter, r *http.Request) {
+	paquete, ok := loadAgenciaPaqueteForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var temporadas []models.PaqueteTemporadaPrecio
+	if err := database.GetDB().
+		Where("paquete_id = ?", paquete.ID).
+		Order("prioridad desc").
+		Order("id asc").
+		Find(&temporadas).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener temporadas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, temporadas, "Temporadas obtenidas exitosamente", http.StatusOK)
+}
+
+// CreatePaqueteTemporadaPrecio crea una regla de precio por temporada.
+func (h *AgenciaHandler) CreatePaqueteTemporadaPrecio(w http.ResponseWriter, r *http.Request) {
+	paquete, ok := loadAgenciaPaqueteForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req temporadaPrecioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	temporada := models.PaqueteTemporadaPrecio{PaqueteID: paquete.ID, Activa: true}
+	if err := applyTemporadaPrecioRequest(&temporada, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := database.GetDB().Create(&temporada).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al crear temporada", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, temporada, "Temporada creada exitosamente", http.StatusCreated)
+}
+
+// UpdatePaqueteTemporadaPrecio actualiza una regla de precio por temporada.
+// Las compras existentes conservan el precio con el que fueron creadas.
+func (h *AgenciaHandler) UpdatePaqueteTemporadaPrecio(w http.ResponseWriter, r *http.Request) {
+	paquete, ok := loadAgenciaPaqueteForManage(w, r)
+	if !ok {
+		return
+	}
+
+	temporada, ok := loadPaqueteTemporada(w, r, paquete.ID)
+	if !ok {
+		return
+	}
+
+	var req temporadaPrecioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := applyTemporadaPrecioRequest(temporada, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := database.GetDB().Save(temporada).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al actualizar temporada", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, temporada, "Temporada actualizada exitosamente", http.StatusOK)
+}
+
+// DeletePaqueteTemporadaPrecio elimina una regla de precio por temporada.
+func (h *AgenciaHandler) DeletePaqueteTemporadaPrecio(w http.ResponseWriter, r *http.Request) {
+	paquete, ok := loadAgenciaPaqueteForManage(w, r)
+	if !ok {
+		return
+	}
+
+	temporada, ok := loadPaqueteTemporada(w, r, paquete.ID)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(temporada).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar temporada", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Temporada eliminada exitosamente", http.StatusOK)
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type promocionRequest struct {
+	Codigo         *string  `json:"codigo"`
+	Descripcion    *string  `json:"descripcion"`
+	TipoDescuento  *string  `json:"tipo_descuento"`
+	Valor          *float64 `json:"valor"`
+	FechaInicio    *string  `json:"fecha_inicio"`
+	FechaFin       *string  `json:"fecha_fin"`
+	UsosMaximos    *int     `json:"usos_maximos"`     // 0 = ilimitado
+	UsosPorUsuario *int     `json:"usos_por_usuario"` // 0 = ilimitado
+	Activa         *bool    `json:"activa"`
+	PaqueteIDs     *[]uint  `json:"paquete_ids"`
+}
+
+func loadAgenciaForManage(w http.ResponseWriter, r *http.Request) (*models.AgenciaTurismo, bool) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var agencia models.AgenciaTurismo
+	if err := database.GetDB().First(&agencia, id).Error; err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", "Agencia no encontrada", nil, http.StatusNotFound)
+		return nil, false
+	}
+
+	if !canManageAgencia(claims, &agencia) {
+		utils.ErrorResponse(w, "FORBIDDEN", "No tiene permisos para gestionar esta agencia", nil, http.StatusForbidden)
+		return nil, false
+	}
+
+	return &agencia, true
+}
+
+func loadAgenciaPromocion(w http.ResponseWriter, r *http.Request, agenciaID uint) (*models.Promocion, bool) {
+	promocionID, err := strconv.ParseUint(mux.Vars(r)["promocion_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de promocion invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var promo models.Promocion
+	if err := database.GetDB().
+		Preload("Paquetes").
+		Where("id = ? AND agencia_id = ?", uint(promocionID), agenciaID).
+		First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Promocion no encontrada", nil, http.StatusNotFound)
+			return nil, false
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener promocion", err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &promo, true
+}
+
+// applyPromocionRequest valida el request y copia los valores a la promoción.
+func applyPromocionRequest(db *gorm.DB, agenciaID uint, promo *models.Promocion, req *promocionRequest) error {
+	if req.Codigo != nil {
+		codigo := services.NormalizarCodigoPromocion(*req.Codigo)
+		if codigo == "" || len(codigo) > 50 || strings.ContainsAny(codigo, " \t") {
+			return errors.New("codigo invalido (sin espacios, maximo 50 caracteres)")
+		}
+		var existentes int64
+		if err := db.Model(&models.Promocion{}).
+			Where("agencia_id = ? AND codigo = ? AND id <> ?", agenciaID, codigo, promo.ID).
+			Count(&existentes).Error; err != nil {
+			return err
+		}
+		if existentes > 0 {
+			return errors.New("ya existe una promocion con ese codigo")
+		}
+		promo.Codigo = codigo
+	}
+	if promo.Codigo == "" {
+		return errors.New("codigo es obligatorio")
+	}
+
+	if req.Descripcion != nil {
+		promo.Descripcion = normalizeStringPtr(req.Descripcion)
+	}
+
+	if req.TipoDescuento != nil {
+		promo.TipoDescuento = strings.ToLower(strings.TrimSpace(*req.TipoDescuento))
+	}
+	if promo.TipoDescuento != "porcentaje" && promo.TipoDescuento != "monto_fijo" {
+		return errors.New("tipo_descuento invalido (porcentaje|monto_fijo)")
+	}
+
+	if req.Valor != nil {
+		promo.Valor = *req.Valor
+	}
+	if promo.Valor <= 0 {
+		return errors.New("valor debe ser mayor a 0")
+	}
+	if promo.TipoDescuento == "porcentaje" && promo.Valor > 100 {
+		return errors.New("el porcentaje no puede ser mayor a 100")
+	}
+
+	if req.FechaInicio != nil {
+		fecha, err := normalizeDatePtr(req.FechaInicio)
+		if err != nil {
+			return errors.New("fecha_inicio invalida (YYYY-MM-DD)")
+		}
+		promo.FechaInicio = fecha
+	}
+	if req.FechaFin != nil {
+		fecha, err := normalizeDatePtr(req.FechaFin)
+		if err != nil {
+			return errors.New("fecha_fin invalida (YYYY-MM-DD)")
+		}
+		promo.FechaFin = fecha
+	}
+	if promo.FechaInicio != nil && promo.FechaFin != nil && (*promo.FechaFin)[:10] < (*promo.FechaInicio)[:10] {
+		return errors.New("fecha_fin no puede ser anterior a fecha_inicio")
+	}
+
+	if req.UsosMaximos != nil {
+		if *req.UsosMaximos < 0 {
+			return errors.New("usos_maximos no puede ser negativo")
+		}
+		if *req.UsosMaximos == 0 {
+			promo.UsosMaximos = nil
+		} else {
+			value := *req.UsosMaximos
+			promo.UsosMaximos = &value
+		}
+	}
+	if req.UsosPorUsuario != nil {
+		if *req.UsosPorUsuario < 0 {
+			return errors.New("usos_por_usuario no puede ser negativo")
+		}
+		if *req.UsosPorUsuario == 0 {
+			promo.UsosPorUsuario = nil
+		} else {
+			value := *req.UsosPorUsuario
+			promo.UsosPorUsuario = &value
+		}
+	}
+
+	if req.Activa != nil {
+		promo.Activa = *req.Activa
+	}
+
+	if req.PaqueteIDs != nil && len(*req.PaqueteIDs) > 0 {
+		var validos int64
+		if err := db.Model(&models.PaqueteTuristico{}).
+			Where("id IN ? AND agencia_id = ?", *req.PaqueteIDs, agenciaID).
+			Count(&validos).Error; err != nil {
+			return err
+		}
+		unicos := map[uint]bool{}
+		for _, id := range *req.PaqueteIDs {
+			unicos[id] = true
+		}
+		if validos != int64(len(unicos)) {
+			return errors.New("paquete_ids contiene paquetes que no pertenecen a la agencia")
+		}
+	}
+
+	return nil
+}
+
+func savePromocionPaquetes(tx *gorm.DB, promocionID uint, paqueteIDs []uint) error {
+	if err := tx.Where("promocion_id = ?", promocionID).Delete(&models.PromocionPaquete{}).Error; err != nil {
+		return err
+	}
+	vistos := map[uint]bool{}
+	for _, paqueteID := range paqueteIDs {
+		if vistos[paqueteID] {
+			continue
+		}
+		vistos[paqueteID] = true
+		if err := tx.Create(&models.PromocionPaquete{PromocionID: promocionID, PaqueteID: paqueteID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAgenciaPromociones lista los códigos de promoción de la agencia con sus usos vigentes.
+func (h *AgenciaHandler) GetAgenciaPromociones(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var promociones []models.Promocion
+	if err := db.Preload("Paquetes").
+		Where("agencia_id = ?", agencia.ID).
+		Order("created_at DESC").
+		Order("id DESC").
+		Find(&promociones).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener promociones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range promociones {
+		usos, err := services.ContarUsosPromocion(db, promociones[i].ID, nil)
+		if err != nil {
+			utils.ErrorResponse(w, "DB_ERROR", "Error al contar usos", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		promociones[i].UsosActuales = usos
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"promociones": promociones,
+	}, "Promociones obtenidas exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaPromocion crea un código de promoción para la agencia.
+func (h *AgenciaHandler) CreateAgenciaPromocion(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req promocionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	promo := models.Promocion{AgenciaID: agencia.ID, Activa: true}
+	if err := applyPromocionRequest(db, agencia.ID, &promo, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Paquetes").Create(&promo).Error; err != nil {
+			return err
+		}
+		if req.PaqueteIDs != nil {
+			return savePromocionPaquetes(tx, promo.ID, *req.PaqueteIDs)
+		}
+		return nil
+	}); err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al crear promocion", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	db.Preload("Paquetes").First(&promo, promo.ID)
+	utils.SuccessResponse(w, promo, "Promocion creada exitosamente", http.StatusCreated)
+}
+
+// UpdateAgenciaPromocion actualiza un código de promoción de la agencia.
+func (h *AgenciaHandler) UpdateAgenciaPromocion(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	promo, ok := loadAgenciaPromocion(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	var req promocionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if err := applyPromocionRequest(db, agencia.ID, promo, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Paquetes").Save(promo).Error; err != nil {
+			return err
+		}
+		if req.PaqueteIDs != nil {
+			return savePromocionPaquetes(tx, promo.ID, *req.PaqueteIDs)
+		}
+		return nil
+	}); err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al actualizar promocion", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var updated models.Promocion
+	db.Preload("Paquetes").First(&updated, promo.ID)
+	updated.UsosActuales, _ = services.ContarUsosPromocion(db, promo.ID, nil)
+	utils.SuccessResponse(w, updated, "Promocion actualizada exitosamente", http.StatusOK)
+}
+
+// DeleteAgenciaPromocion elimina una promoción sin usos o la desactiva si ya fue utilizada.
+func (h *AgenciaHandler) DeleteAgenciaPromocion(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	promo, ok := loadAgenciaPromocion(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var usos int64
+	if err := db.Model(&models.PromocionUso{}).Where("promocion_id = ?", promo.ID).Count(&usos).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al validar usos", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if usos > 0 {
+		if err := db.Model(promo).Update("activa", false).Error; err != nil {
+			utils.ErrorResponse(w, "DB_ERROR", "Error al desactivar promocion", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.SuccessResponse(w, nil, "La promocion tiene usos registrados y fue desactivada", http.StatusOK)
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("promocion_id = ?", promo.ID).Delete(&models.PromocionPaquete{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Promocion{}, promo.ID).Error
+	}); err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar promocion", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Promocion eliminada exitosamente", http.StatusOK)
+}
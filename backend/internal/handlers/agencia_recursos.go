@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var allowedRecursoTipo = map[string]bool{
+	"vehiculo":       true,
+	"bicicleta":      true,
+	"equipo_camping": true,
+	"otro":           true,
+}
+
+type recursoRequest struct {
+	Tipo          *string `json:"tipo"`
+	Nombre        *string `json:"nombre"`
+	Identificador *string `json:"identificador"`
+	Capacidad     *int    `json:"capacidad"`
+	Notas         *string `json:"notas"`
+	Activo        *bool   `json:"activo"`
+}
+
+func loadAgenciaRecurso(w http.ResponseWriter, r *http.Request, agenciaID uint) (*models.Recurso, bool) {
+	recursoID, err := strconv.ParseUint(mux.Vars(r)["recurso_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de recurso invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var recurso models.Recurso
+	if err := database.GetDB().Where("id = ? AND agencia_id = ?", uint(recursoID), agenciaID).First(&recurso).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Recurso no encontrado", nil, http.StatusNotFound)
+			return nil, false
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener recurso", err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &recurso, true
+}
+
+// applyRecursoRequest valida el request y copia los valores al recurso.
+func applyRecursoRequest(recurso *models.Recurso, req *recursoRequest) error {
+	if req.Tipo != nil {
+		recurso.Tipo = strings.ToLower(strings.TrimSpace(*req.Tipo))
+	}
+	if !allowedRecursoTipo[recurso.Tipo] {
+		return errors.New("tipo invalido (vehiculo|bicicleta|equipo_camping|otro)")
+	}
+
+	if req.Nombre != nil {
+		recurso.Nombre = strings.TrimSpace(*req.Nombre)
+	}
+	if recurso.Nombre == "" || len(recurso.Nombre) > 255 {
+		return errors.New("nombre es obligatorio (maximo 255 caracteres)")
+	}
+
+	if req.Identificador != nil {
+		recurso.Identificador = optionalTrimmed(req.Identificador)
+		if recurso.Identificador != nil && len(*recurso.Identificador) > 100 {
+			return errors.New("identificador invalido (maximo 100 caracteres)")
+		}
+	}
+
+	if req.Capacidad != nil {
+		recurso.Capacidad = *req.Capacidad
+	}
+	if recurso.Capacidad < 1 {
+		return errors.New("capacidad debe ser al menos 1")
+	}
+
+	if req.Notas != nil {
+		recurso.Notas = optionalTrimmed(req.Notas)
+	}
+	if req.Activo != nil {
+		recurso.Activo = *req.Activo
+	}
+
+	return nil
+}
+
+// GetAgenciaRecursos lista los vehículos y equipos de la agencia con sus mantenimientos.
+// Filtros opcionales: ?tipo=, ?activo=true|false.
+func (h *AgenciaHandler) GetAgenciaRecursos(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	query := database.GetDB().Where("agencia_id = ?", agencia.ID)
+	if tipo := strings.TrimSpace(r.URL.Query().Get("tipo")); tipo != "" {
+		if !allowedRecursoTipo[tipo] {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "tipo invalido (vehiculo|bicicleta|equipo_camping|otro)", nil, http.StatusBadRequest)
+			return
+		}
+		query = query.Where("tipo = ?", tipo)
+	}
+	if value := strings.TrimSpace(r.URL.Query().Get("activo")); value != "" {
+		activo, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "activo invalido (true|false)", nil, http.StatusBadRequest)
+			return
+		}
+		query = query.Where("activo = ?", activo)
+	}
+
+	var recursos []models.Recurso
+	if err := query.
+		Preload("Mantenimientos", func(db *gorm.DB) *gorm.DB {
+			return db.Where("fecha_fin >= CURRENT_DATE").Order("fecha_inicio ASC")
+		}).
+		Order("tipo ASC").
+		Order("nombre ASC").
+		Find(&recursos).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener recursos", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"recursos": recursos,
+	}, "Recursos obtenidos exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaRecurso registra un vehículo o equipo.
+func (h *AgenciaHandler) CreateAgenciaRecurso(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req recursoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	recurso := models.Recurso{AgenciaID: agencia.ID, Capacidad: 1, Activo: true}
+	if err := applyRecursoRequest(&recurso, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := database.GetDB().Create(&recurso).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al crear recurso", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, recurso, "Recurso creado exitosamente", http.StatusCreated)
+}
+
+// UpdateAgenciaRecurso actualiza un recurso. Las asignaciones existentes no se revalidan.
+func (h *AgenciaHandler) UpdateAgenciaRecurso(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	recurso, ok := loadAgenciaRecurso(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	var req recursoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := applyRecursoRequest(recurso, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := database.GetDB().Save(recurso).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al actualizar recurso", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, recurso, "Recurso actualizado exitosamente", http.StatusOK)
+}
+
+// DeleteAgenciaRecurso elimina un recurso sin asignaciones o lo desactiva si ya fue asignado a salidas.
+func (h *AgenciaHandler) DeleteAgenciaRecurso(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	recurso, ok := loadAgenciaRecurso(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var asignaciones int64
+	if err := db.Model(&models.SalidaRecurso{}).Where("recurso_id = ?", recurso.ID).Count(&asignaciones).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al validar asignaciones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if asignaciones > 0 {
+		if err := db.Model(recurso).Update("activo", false).Error; err != nil {
+			utils.ErrorResponse(w, "DB_ERROR", "Error al desactivar recurso", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.SuccessResponse(w, nil, "El recurso tiene salidas asignadas y fue desactivado", http.StatusOK)
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("recurso_id = ?", recurso.ID).Delete(&models.RecursoMantenimiento{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Recurso{}, recurso.ID).Error
+	}); err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar recurso", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Recurso eliminado exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaRecursoMantenimiento bloquea un recurso por mantenimiento entre dos fechas.
+// Responde 409 si el recurso ya está asignado a salidas en ese período.
+func (h *AgenciaHandler) CreateAgenciaRecursoMantenimiento(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	recurso, ok := loadAgenciaRecurso(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	var req models.CrearMantenimientoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mantenimiento, err := services.NewRecursoService(database.GetDB()).CrearMantenimiento(recurso, &req)
+	if err != nil {
+		var conflicto *services.ConflictoRecursosError
+		if errors.As(err, &conflicto) {
+			utils.ErrorResponse(w, "CONFLICT", err.Error(), conflicto.Conflictos, http.StatusConflict)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, mantenimiento, "Mantenimiento registrado exitosamente", http.StatusCreated)
+}
+
+// DeleteAgenciaRecursoMantenimiento elimina una ventana de mantenimiento.
+func (h *AgenciaHandler) DeleteAgenciaRecursoMantenimiento(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	recurso, ok := loadAgenciaRecurso(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	mantenimientoID, err := strconv.ParseUint(mux.Vars(r)["mantenimiento_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de mantenimiento invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	result := database.GetDB().Where("id = ? AND recurso_id = ?", uint(mantenimientoID), recurso.ID).Delete(&models.RecursoMantenimiento{})
+	if result.Error != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar mantenimiento", result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(w, "NOT_FOUND", "Mantenimiento no encontrado", nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Mantenimiento eliminado exitosamente", http.StatusOK)
+}
+
+// GetAgenciaRecursosDisponibilidad retorna la ocupación de los recursos por fechas (?desde=&hasta=&tipo=).
+func (h *AgenciaHandler) GetAgenciaRecursosDisponibilidad(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	desde, hasta, ok := parseRangoCalendario(w, r)
+	if !ok {
+		return
+	}
+
+	tipo := strings.TrimSpace(r.URL.Query().Get("tipo"))
+	if tipo != "" && !allowedRecursoTipo[tipo] {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "tipo invalido (vehiculo|bicicleta|equipo_camping|otro)", nil, http.StatusBadRequest)
+		return
+	}
+
+	resp, err := services.NewRecursoService(database.GetDB()).Disponibilidad(agencia.ID, desde, hasta, tipo)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener disponibilidad", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Disponibilidad obtenida exitosamente", http.StatusOK)
+}
+
+// GetAgenciaSalidaRecursos lista los recursos asignados a una salida y su capacidad de asientos.
+func (h *AgenciaHandler) GetAgenciaSalidaRecursos(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := services.NewRecursoService(database.GetDB()).ObtenerRecursosSalida(agencia.ID, salidaID)
+	if err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Recursos de la salida obtenidos exitosamente", http.StatusOK)
+}
+
+// AsignarAgenciaSalidaRecursos reemplaza los recursos asignados a una salida.
+// Responde 409 con el detalle si algún recurso está ocupado o en mantenimiento.
+func (h *AgenciaHandler) AsignarAgenciaSalidaRecursos(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AsignarRecursosSalidaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	resp, err := services.NewRecursoService(database.GetDB()).AsignarRecursos(agencia.ID, salidaID, claims.UserID, &req)
+	if err != nil {
+		var conflicto *services.ConflictoRecursosError
+		if errors.As(err, &conflicto) {
+			utils.ErrorResponse(w, "CONFLICT", err.Error(), conflicto.Conflictos, http.StatusConflict)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Recursos asignados exitosamente", http.StatusOK)
+}
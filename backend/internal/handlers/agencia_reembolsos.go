@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetAgenciaPoliticaReembolso retorna los tramos de reembolso configurados por la agencia.
+func (h *AgenciaHandler) GetAgenciaPoliticaReembolso(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	tramos, err := services.CargarTramosReembolso(database.GetDB(), agencia.ID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener politica de reembolso", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"tramos": tramos,
+	}, "Politica de reembolso obtenida exitosamente", http.StatusOK)
+}
+
+// UpdateAgenciaPoliticaReembolso reemplaza todos los tramos de reembolso de la agencia.
+func (h *AgenciaHandler) UpdateAgenciaPoliticaReembolso(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ActualizarTramosReembolsoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vistos := map[int]bool{}
+	for _, tramo := range req.Tramos {
+		if vistos[tramo.DiasAntesMinimo] {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "Hay tramos repetidos para dias_antes_minimo", tramo.DiasAntesMinimo, http.StatusBadRequest)
+			return
+		}
+		vistos[tramo.DiasAntesMinimo] = true
+	}
+
+	db := database.GetDB()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("agencia_id = ?", agencia.ID).Delete(&models.PoliticaReembolsoTramo{}).Error; err != nil {
+			return err
+		}
+		for _, tramo := range req.Tramos {
+			if err := tx.Create(&models.PoliticaReembolsoTramo{
+				AgenciaID:           agencia.ID,
+				DiasAntesMinimo:     tramo.DiasAntesMinimo,
+				PorcentajeReembolso: tramo.PorcentajeReembolso,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al guardar politica de reembolso", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tramos, err := services.CargarTramosReembolso(db, agencia.ID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener politica de reembolso", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"tramos": tramos,
+	}, "Politica de reembolso actualizada exitosamente", http.StatusOK)
+}
+
+// GetAgenciaReembolsos lista los reembolsos de la agencia (pendientes, pagados o sin monto).
+func (h *AgenciaHandler) GetAgenciaReembolsos(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	estado := r.URL.Query().Get("estado")
+	if estado != "" && estado != "pendiente" && estado != "procesando" && estado != "pagado" && estado != "no_aplica" {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "estado invalido (use pendiente|procesando|pagado|no_aplica)", nil, http.StatusBadRequest)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	reembolsos, total, err := services.NewReembolsoService(database.GetDB()).ListarReembolsosAgencia(agencia.ID, estado, page, pageSize)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener reembolsos", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"reembolsos": reembolsos,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, "Reembolsos obtenidos exitosamente", http.StatusOK)
+}
+
+// PagarAgenciaReembolso registra la devolución del dinero al turista (multipart con comprobante opcional).
+func (h *AgenciaHandler) PagarAgenciaReembolso(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	reembolsoID, err := strconv.ParseUint(mux.Vars(r)["reembolso_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de reembolso invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.ErrorResponse(w, "INVALID_FORM", "No se pudo procesar el formulario", nil, http.StatusBadRequest)
+		return
+	}
+
+	req := models.PagarReembolsoRequest{
+		MetodoPago: strings.TrimSpace(r.FormValue("metodo_pago")),
+	}
+	if notas := strings.TrimSpace(r.FormValue("notas_encargado")); notas != "" {
+		req.NotasEncargado = &notas
+	}
+
+	if file, header, err := r.FormFile("comprobante"); err == nil {
+		file.Close()
+		req.Comprobante = header
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reembolso, err := services.NewReembolsoService(database.GetDB()).MarcarPagado(agencia.ID, uint(reembolsoID), claims.UserID, &req)
+	if err != nil {
+		if err.Error() == "reembolso no encontrado" {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, reembolso, "Reembolso registrado como pagado", http.StatusOK)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type reglaDescuentoRequest struct {
+	Nombre             *string  `json:"nombre"`
+	Tipo               *string  `json:"tipo"`
+	PaqueteID          *uint    `json:"paquete_id"` // 0 = todos los paquetes
+	MinPersonas        *int     `json:"min_personas"`
+	DiasAnticipacion   *int     `json:"dias_anticipacion"`
+	SoloBajoCupoMinimo *bool    `json:"solo_bajo_cupo_minimo"`
+	Porcentaje         *float64 `json:"porcentaje"`
+	Orden              *int     `json:"orden"`
+	Acumulable         *bool    `json:"acumulable"`
+	Activa             *bool    `json:"activa"`
+}
+
+func loadAgenciaReglaDescuento(w http.ResponseWriter, r *http.Request, agenciaID uint) (*models.ReglaDescuento, bool) {
+	reglaID, err := strconv.ParseUint(mux.Vars(r)["regla_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de regla invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	var regla models.ReglaDescuento
+	if err := database.GetDB().Where("id = ? AND agencia_id = ?", uint(reglaID), agenciaID).First(&regla).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Regla de descuento no encontrada", nil, http.StatusNotFound)
+			return nil, false
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener regla de descuento", err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &regla, true
+}
+
+// applyReglaDescuentoRequest valida el request y copia los valores a la regla.
+func applyReglaDescuentoRequest(db *gorm.DB, agenciaID uint, regla *models.ReglaDescuento, req *reglaDescuentoRequest) error {
+	if req.Nombre != nil {
+		regla.Nombre = strings.TrimSpace(*req.Nombre)
+	}
+	if regla.Nombre == "" || len(regla.Nombre) > 100 {
+		return errors.New("nombre es obligatorio (maximo 100 caracteres)")
+	}
+
+	if req.Tipo != nil {
+		regla.Tipo = strings.ToLower(strings.TrimSpace(*req.Tipo))
+	}
+	if regla.Tipo != "grupo" && regla.Tipo != "anticipacion" && regla.Tipo != "ultimo_minuto" {
+		return errors.New("tipo invalido (grupo|anticipacion|ultimo_minuto)")
+	}
+
+	if req.PaqueteID != nil {
+		if *req.PaqueteID == 0 {
+			regla.PaqueteID = nil
+		} else {
+			var count int64
+			if err := db.Model(&models.PaqueteTuristico{}).
+				Where("id = ? AND agencia_id = ?", *req.PaqueteID, agenciaID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return errors.New("paquete_id no pertenece a la agencia")
+			}
+			value := *req.PaqueteID
+			regla.PaqueteID = &value
+		}
+	}
+
+	if req.MinPersonas != nil {
+		value := *req.MinPersonas
+		regla.MinPersonas = &value
+	}
+	if req.DiasAnticipacion != nil {
+		value := *req.DiasAnticipacion
+		regla.DiasAnticipacion = &value
+	}
+	if req.SoloBajoCupoMinimo != nil {
+		regla.SoloBajoCupoMinimo = *req.SoloBajoCupoMinimo
+	}
+
+	switch regla.Tipo {
+	case "grupo":
+		if regla.MinPersonas == nil || *regla.MinPersonas < 2 {
+			return errors.New("min_personas debe ser al menos 2 para reglas de grupo")
+		}
+		regla.DiasAnticipacion = nil
+		regla.SoloBajoCupoMinimo = false
+	case "anticipacion", "ultimo_minuto":
+		if regla.DiasAnticipacion == nil || *regla.DiasAnticipacion < 0 {
+			return errors.New("dias_anticipacion es obligatorio y no puede ser negativo")
+		}
+		regla.MinPersonas = nil
+		if regla.Tipo == "anticipacion" {
+			regla.SoloBajoCupoMinimo = false
+		}
+	}
+
+	if req.Porcentaje != nil {
+		regla.Porcentaje = *req.Porcentaje
+	}
+	if regla.Porcentaje <= 0 || regla.Porcentaje > 100 {
+		return errors.New("porcentaje debe estar entre 0 y 100")
+	}
+
+	if req.Orden != nil {
+		regla.Orden = *req.Orden
+	}
+	if req.Acumulable != nil {
+		regla.Acumulable = *req.Acumulable
+	}
+	if req.Activa != nil {
+		regla.Activa = *req.Activa
+	}
+
+	return nil
+}
+
+// GetAgenciaReglasDescuento lista las reglas de descuento automático de la agencia en orden de evaluación.
+func (h *AgenciaHandler) GetAgenciaReglasDescuento(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var reglas []models.ReglaDescuento
+	if err := database.GetDB().
+		Where("agencia_id = ?", agencia.ID).
+		Order("orden ASC").
+		Order("id ASC").
+		Find(&reglas).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener reglas de descuento", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"reglas": reglas,
+	}, "Reglas de descuento obtenidas exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaReglaDescuento crea una regla de descuento automático.
+func (h *AgenciaHandler) CreateAgenciaReglaDescuento(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req reglaDescuentoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	regla := models.ReglaDescuento{AgenciaID: agencia.ID, Activa: true}
+	if err := applyReglaDescuentoRequest(db, agencia.ID, &regla, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := db.Create(&regla).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al crear regla de descuento", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, regla, "Regla de descuento creada exitosamente", http.StatusCreated)
+}
+
+// UpdateAgenciaReglaDescuento actualiza una regla de descuento. Las compras existentes no se recalculan.
+func (h *AgenciaHandler) UpdateAgenciaReglaDescuento(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	regla, ok := loadAgenciaReglaDescuento(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	var req reglaDescuentoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if err := applyReglaDescuentoRequest(db, agencia.ID, regla, &req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := db.Save(regla).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al actualizar regla de descuento", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, regla, "Regla de descuento actualizada exitosamente", http.StatusOK)
+}
+
+// DeleteAgenciaReglaDescuento elimina una regla sin aplicaciones o la desactiva si ya se aplicó a compras.
+func (h *AgenciaHandler) DeleteAgenciaReglaDescuento(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	regla, ok := loadAgenciaReglaDescuento(w, r, agencia.ID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var usos int64
+	if err := db.Model(&models.CompraDescuento{}).Where("regla_descuento_id = ?", regla.ID).Count(&usos).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al validar usos", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if usos > 0 {
+		if err := db.Model(regla).Update("activa", false).Error; err != nil {
+			utils.ErrorResponse(w, "DB_ERROR", "Error al desactivar regla de descuento", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utils.SuccessResponse(w, nil, "La regla fue aplicada a compras y fue desactivada", http.StatusOK)
+		return
+	}
+
+	if err := db.Delete(&models.ReglaDescuento{}, regla.ID).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar regla de descuento", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Regla de descuento eliminada exitosamente", http.StatusOK)
+}
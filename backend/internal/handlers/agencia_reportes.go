@@ -15,6 +15,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
 )
 
 type reportRange struct {
@@ -27,9 +28,17 @@ type reportRange struct {
 }
 
 type reporteVentasResumen struct {
-	Ingresos      float64 `gorm:"column:ingresos"`
-	Ventas        int64   `gorm:"column:ventas"`
-	Participantes int64   `gorm:"column:participantes"`
+	Ingresos       float64 `gorm:"column:ingresos"`
+	IngresosBrutos float64 `gorm:"column:ingresos_brutos"`
+	Descuentos     float64 `gorm:"column:descuentos"`
+	Ventas         int64   `gorm:"column:ventas"`
+	Participantes  int64   `gorm:"column:participantes"`
+}
+
+type reporteDescuentoTipoRow struct {
+	Tipo    string  `json:"tipo" gorm:"column:tipo"`
+	Compras int64   `json:"compras" gorm:"column:compras"`
+	Monto   float64 `json:"monto" gorm:"column:monto"`
 }
 
 type reporteMetodoPagoRow struct {
@@ -46,6 +55,7 @@ type reporteVentaRow struct {
 	TipoCompra         string    `json:"tipo_compra" gorm:"column:tipo_compra"`
 	TotalParticipantes int       `json:"total_participantes" gorm:"column:total_participantes"`
 	PrecioTotal        float64   `json:"precio_total" gorm:"column:precio_total"`
+	DescuentoAplicado  float64   `json:"descuento_aplicado" gorm:"column:descuento_aplicado"`
 	MetodoPago         *string   `json:"metodo_pago,omitempty" gorm:"column:metodo_pago"`
 	EstadoPago         *string   `json:"estado_pago,omitempty" gorm:"column:estado_pago"`
 }
@@ -60,6 +70,10 @@ type reporteOcupacionSalidaRow struct {
 	CuposConfirmados int       `json:"cupos_confirmados" gorm:"column:cupos_confirmados"`
 	CuposReservados  int       `json:"cupos_reservados" gorm:"column:cupos_reservados"`
 	Estado           string    `json:"estado" gorm:"column:estado"`
+	// Asistencia real (solo salidas en curso o completadas)
+	AsistenciaRegistrada bool `json:"asistencia_registrada" gorm:"column:asistencia_registrada"`
+	Presentes            int  `json:"presentes" gorm:"column:presentes"`
+	NoShows              int  `json:"no_shows" gorm:"column:no_shows"`
 }
 
 type reporteOcupacionPaqueteRow struct {
@@ -70,6 +84,11 @@ type reporteOcupacionPaqueteRow struct {
 	CuposConfirmados int64   `json:"cupos_confirmados" gorm:"column:cupos_confirmados"`
 	CuposReservados  int64   `json:"cupos_reservados" gorm:"column:cupos_reservados"`
 	Ocupacion        float64 `json:"ocupacion"`
+	// Ocupación real: presentes sobre el cupo de las salidas con asistencia registrada
+	CupoConAsistencia int64   `json:"cupo_con_asistencia" gorm:"column:cupo_con_asistencia"`
+	Presentes         int64   `json:"presentes" gorm:"column:presentes"`
+	NoShows           int64   `json:"no_shows" gorm:"column:no_shows"`
+	OcupacionReal     float64 `json:"ocupacion_real"`
 }
 
 type reporteFinancieroPaqueteRow struct {
@@ -298,6 +317,26 @@ func (h *AgenciaHandler) loadAgenciaForReport(w http.ResponseWriter, r *http.Req
 	return &agencia, true
 }
 
+// reporteDescuentosPorTipo agrupa los descuentos aplicados a las compras del filtro por tipo de regla.
+func reporteDescuentosPorTipo(db *gorm.DB, whereClause string, args []interface{}) ([]reporteDescuentoTipoRow, error) {
+	var rows []reporteDescuentoTipoRow
+	query := fmt.Sprintf(`
+        SELECT cd.tipo,
+               COUNT(DISTINCT cd.compra_id) AS compras,
+               COALESCE(SUM(cd.monto), 0) AS monto
+        FROM compra_descuentos cd
+        JOIN compras_paquetes cp ON cd.compra_id = cp.id
+        JOIN paquetes_turisticos pt ON cp.paquete_id = pt.id
+        WHERE %s
+        GROUP BY cd.tipo
+        ORDER BY monto DESC
+    `, whereClause)
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
 // GetAgenciaReporteVentas genera el reporte de ventas.
 func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.Request) {
 	agencia, ok := h.loadAgenciaForReport(w, r)
@@ -356,6 +395,8 @@ func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.
 	var resumen reporteVentasResumen
 	resumenQuery := fmt.Sprintf(`
         SELECT COALESCE(SUM(cp.precio_total), 0) AS ingresos,
+               COALESCE(SUM(COALESCE(cp.precio_sin_descuento, cp.precio_total)), 0) AS ingresos_brutos,
+               COALESCE(SUM(cp.descuento_aplicado), 0) AS descuentos,
                COUNT(*) AS ventas,
                COALESCE(SUM(cp.total_participantes), 0) AS participantes
         FROM compras_paquetes cp
@@ -390,6 +431,12 @@ func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.
 		return
 	}
 
+	descuentos, err := reporteDescuentosPorTipo(db, whereClause, args)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener descuentos", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	var rows []reporteVentaRow
 	rowsQuery := fmt.Sprintf(`
         SELECT
@@ -400,6 +447,7 @@ func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.
             cp.tipo_compra,
             cp.total_participantes,
             cp.precio_total,
+            cp.descuento_aplicado,
             pc.metodo_pago,
             pc.estado AS estado_pago
         FROM compras_paquetes cp
@@ -432,11 +480,14 @@ func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.
 			},
 			"resumen": map[string]interface{}{
 				"ingresos":        resumen.Ingresos,
+				"ingresos_brutos": resumen.IngresosBrutos,
+				"descuentos":      resumen.Descuentos,
 				"ventas":          resumen.Ventas,
 				"participantes":   resumen.Participantes,
 				"promedio_ticket": promedio,
 			},
 			"metodos_pago": metodos,
+			"descuentos":   descuentos,
 			"compras":      rows,
 		}, "Reporte generado", http.StatusOK)
 		return
@@ -446,7 +497,7 @@ func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.
 
 	if format == "csv" {
 		csvRows := [][]string{
-			{"Fecha", "Paquete", "Tipo compra", "Participantes", "Monto", "Metodo pago", "Estado pago"},
+			{"Fecha", "Paquete", "Tipo compra", "Participantes", "Monto", "Descuento", "Metodo pago", "Estado pago"},
 		}
 		for _, row := range rows {
 			fecha := row.FechaConfirmacion.Format("2006-01-02")
@@ -464,6 +515,7 @@ func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.
 				row.TipoCompra,
 				strconv.Itoa(row.TotalParticipantes),
 				fmt.Sprintf("%.2f", row.PrecioTotal),
+				fmt.Sprintf("%.2f", row.DescuentoAplicado),
 				metodo,
 				estado,
 			})
@@ -476,6 +528,7 @@ func (h *AgenciaHandler) GetAgenciaReporteVentas(w http.ResponseWriter, r *http.
 
 	pdf := newReportPDF("Reporte de ventas", agencia, rango)
 	pdfKeyValue(pdf, "Ingresos", fmt.Sprintf("Bs %.2f", resumen.Ingresos))
+	pdfKeyValue(pdf, "Descuentos", fmt.Sprintf("Bs %.2f", resumen.Descuentos))
 	pdfKeyValue(pdf, "Ventas", fmt.Sprintf("%d", resumen.Ventas))
 	pdfKeyValue(pdf, "Participantes", fmt.Sprintf("%d", resumen.Participantes))
 	pdfKeyValue(pdf, "Promedio ticket", fmt.Sprintf("Bs %.2f", promedio))
@@ -563,9 +616,18 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
             s.cupo_maximo,
             s.cupos_confirmados,
             s.cupos_reservados,
-            s.estado
+            s.estado,
+            s.estado IN ('en_curso', 'completada') AS asistencia_registrada,
+            COALESCE(a.presentes, 0) AS presentes,
+            COALESCE(a.no_shows, 0) AS no_shows
         FROM paquete_salidas_habilitadas s
         JOIN paquetes_turisticos pt ON pt.id = s.paquete_id
+        LEFT JOIN LATERAL (
+            SELECT COALESCE(SUM(c.participantes_presentes), 0) AS presentes,
+                   COUNT(*) FILTER (WHERE c.no_show) AS no_shows
+            FROM compras_paquetes c
+            WHERE c.salida_id = s.id AND c.status = 'confirmada'
+        ) a ON s.estado IN ('en_curso', 'completada')
         WHERE %s
         ORDER BY s.fecha_salida ASC, pt.nombre ASC
     `, whereClause)
@@ -582,9 +644,18 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
             COUNT(*) AS salidas,
             COALESCE(SUM(s.cupo_maximo), 0) AS cupo_maximo,
             COALESCE(SUM(s.cupos_confirmados), 0) AS cupos_confirmados,
-            COALESCE(SUM(s.cupos_reservados), 0) AS cupos_reservados
+            COALESCE(SUM(s.cupos_reservados), 0) AS cupos_reservados,
+            COALESCE(SUM(CASE WHEN s.estado IN ('en_curso', 'completada') THEN s.cupo_maximo ELSE 0 END), 0) AS cupo_con_asistencia,
+            COALESCE(SUM(a.presentes), 0) AS presentes,
+            COALESCE(SUM(a.no_shows), 0) AS no_shows
         FROM paquete_salidas_habilitadas s
         JOIN paquetes_turisticos pt ON pt.id = s.paquete_id
+        LEFT JOIN LATERAL (
+            SELECT COALESCE(SUM(c.participantes_presentes), 0) AS presentes,
+                   COUNT(*) FILTER (WHERE c.no_show) AS no_shows
+            FROM compras_paquetes c
+            WHERE c.salida_id = s.id AND c.status = 'confirmada'
+        ) a ON s.estado IN ('en_curso', 'completada')
         WHERE %s
         GROUP BY pt.id, pt.nombre
         ORDER BY pt.nombre ASC
@@ -597,19 +668,32 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
 	var totalSalidas int64
 	var totalCupo int64
 	var totalConfirmados int64
+	var totalCupoConAsistencia int64
+	var totalPresentes int64
+	var totalNoShows int64
 	for i := range paquetes {
 		if paquetes[i].CupoMaximo > 0 {
 			paquetes[i].Ocupacion = float64(paquetes[i].CuposConfirmados) / float64(paquetes[i].CupoMaximo)
 		}
+		if paquetes[i].CupoConAsistencia > 0 {
+			paquetes[i].OcupacionReal = float64(paquetes[i].Presentes) / float64(paquetes[i].CupoConAsistencia)
+		}
 		totalSalidas += paquetes[i].Salidas
 		totalCupo += paquetes[i].CupoMaximo
 		totalConfirmados += paquetes[i].CuposConfirmados
+		totalCupoConAsistencia += paquetes[i].CupoConAsistencia
+		totalPresentes += paquetes[i].Presentes
+		totalNoShows += paquetes[i].NoShows
 	}
 
 	ocupacionPromedio := 0.0
 	if totalCupo > 0 {
 		ocupacionPromedio = float64(totalConfirmados) / float64(totalCupo)
 	}
+	ocupacionReal := 0.0
+	if totalCupoConAsistencia > 0 {
+		ocupacionReal = float64(totalPresentes) / float64(totalCupoConAsistencia)
+	}
 
 	if format == "json" {
 		utils.SuccessResponse(w, map[string]interface{}{
@@ -622,6 +706,9 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
 				"cupo_maximo":        totalCupo,
 				"confirmados":        totalConfirmados,
 				"ocupacion_promedio": ocupacionPromedio,
+				"presentes":          totalPresentes,
+				"no_shows":           totalNoShows,
+				"ocupacion_real":     ocupacionReal,
 			},
 			"por_paquete": paquetes,
 			"por_salida":  salidas,
@@ -632,13 +719,21 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
 	filename := reportFilename("reporte_ocupacion", rango, format)
 	if format == "csv" {
 		csvRows := [][]string{
-			{"Fecha", "Paquete", "Tipo", "Cupo maximo", "Confirmados", "Reservados", "Ocupacion", "Estado"},
+			{"Fecha", "Paquete", "Tipo", "Cupo maximo", "Confirmados", "Reservados", "Ocupacion", "Presentes", "No-shows", "Ocupacion real", "Estado"},
 		}
 		for _, row := range salidas {
 			ocupacion := 0.0
 			if row.CupoMaximo > 0 {
 				ocupacion = float64(row.CuposConfirmados) / float64(row.CupoMaximo)
 			}
+			presentes, noShows, ocupacionReal := "", "", ""
+			if row.AsistenciaRegistrada {
+				presentes = strconv.Itoa(row.Presentes)
+				noShows = strconv.Itoa(row.NoShows)
+				if row.CupoMaximo > 0 {
+					ocupacionReal = fmt.Sprintf("%.2f", float64(row.Presentes)/float64(row.CupoMaximo)*100)
+				}
+			}
 			csvRows = append(csvRows, []string{
 				row.FechaSalida.Format("2006-01-02"),
 				row.PaqueteNombre,
@@ -647,6 +742,9 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
 				strconv.Itoa(row.CuposConfirmados),
 				strconv.Itoa(row.CuposReservados),
 				fmt.Sprintf("%.2f", ocupacion*100),
+				presentes,
+				noShows,
+				ocupacionReal,
 				row.Estado,
 			})
 		}
@@ -661,16 +759,23 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
 	pdfKeyValue(pdf, "Cupo maximo", fmt.Sprintf("%d", totalCupo))
 	pdfKeyValue(pdf, "Confirmados", fmt.Sprintf("%d", totalConfirmados))
 	pdfKeyValue(pdf, "Ocupacion promedio", fmt.Sprintf("%.1f%%", ocupacionPromedio*100))
+	pdfKeyValue(pdf, "Presentes", fmt.Sprintf("%d", totalPresentes))
+	pdfKeyValue(pdf, "No-shows", fmt.Sprintf("%d", totalNoShows))
+	pdfKeyValue(pdf, "Ocupacion real", fmt.Sprintf("%.1f%%", ocupacionReal*100))
 	pdf.Ln(4)
 
-	headers := []string{"Fecha", "Paquete", "Tipo", "Cupo", "Conf", "Res", "%", "Estado"}
-	widths := []float64{20, 55, 14, 14, 12, 12, 10, 22}
+	headers := []string{"Fecha", "Paquete", "Tipo", "Cupo", "Conf", "Res", "%", "Pres", "Estado"}
+	widths := []float64{20, 55, 14, 14, 12, 12, 10, 12, 22}
 	pdfRows := make([][]string, 0, len(salidas))
 	for _, row := range salidas {
 		ocupacion := 0.0
 		if row.CupoMaximo > 0 {
 			ocupacion = float64(row.CuposConfirmados) / float64(row.CupoMaximo)
 		}
+		presentes := "-"
+		if row.AsistenciaRegistrada {
+			presentes = strconv.Itoa(row.Presentes)
+		}
 		pdfRows = append(pdfRows, []string{
 			row.FechaSalida.Format("2006-01-02"),
 			truncateText(row.PaqueteNombre, 30),
@@ -679,6 +784,7 @@ func (h *AgenciaHandler) GetAgenciaReporteOcupacion(w http.ResponseWriter, r *ht
 			strconv.Itoa(row.CuposConfirmados),
 			strconv.Itoa(row.CuposReservados),
 			fmt.Sprintf("%.0f", ocupacion*100),
+			presentes,
 			truncateText(row.Estado, 10),
 		})
 	}
@@ -735,6 +841,8 @@ func (h *AgenciaHandler) GetAgenciaReporteFinanciero(w http.ResponseWriter, r *h
 	var resumen reporteVentasResumen
 	resumenQuery := fmt.Sprintf(`
         SELECT COALESCE(SUM(cp.precio_total), 0) AS ingresos,
+               COALESCE(SUM(COALESCE(cp.precio_sin_descuento, cp.precio_total)), 0) AS ingresos_brutos,
+               COALESCE(SUM(cp.descuento_aplicado), 0) AS descuentos,
                COUNT(*) AS ventas,
                COALESCE(SUM(cp.total_participantes), 0) AS participantes
         FROM compras_paquetes cp
@@ -746,6 +854,12 @@ func (h *AgenciaHandler) GetAgenciaReporteFinanciero(w http.ResponseWriter, r *h
 		return
 	}
 
+	descuentos, err := reporteDescuentosPorTipo(db, whereClause, args)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener descuentos", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	var paquetes []reporteFinancieroPaqueteRow
 	paquetesQuery := fmt.Sprintf(`
         SELECT
@@ -801,10 +915,13 @@ func (h *AgenciaHandler) GetAgenciaReporteFinanciero(w http.ResponseWriter, r *h
 			},
 			"resumen": map[string]interface{}{
 				"ingresos_totales": resumen.Ingresos,
+				"ingresos_brutos":  resumen.IngresosBrutos,
+				"descuentos":       resumen.Descuentos,
 				"ventas":           resumen.Ventas,
 				"participantes":    resumen.Participantes,
 			},
 			"ingresos_por_paquete": paquetes,
+			"descuentos_por_tipo":  descuentos,
 			"pagos_pendientes":     pendientes,
 			"ingresos_futuros":     ingresosFuturos,
 		}, "Reporte generado", http.StatusOK)
@@ -832,6 +949,11 @@ func (h *AgenciaHandler) GetAgenciaReporteFinanciero(w http.ResponseWriter, r *h
 
 	pdf := newReportPDF("Reporte financiero", agencia, rango)
 	pdfKeyValue(pdf, "Ingresos totales", fmt.Sprintf("Bs %.2f", resumen.Ingresos))
+	pdfKeyValue(pdf, "Ingresos brutos", fmt.Sprintf("Bs %.2f", resumen.IngresosBrutos))
+	pdfKeyValue(pdf, "Descuentos", fmt.Sprintf("Bs %.2f", resumen.Descuentos))
+	for _, d := range descuentos {
+		pdfKeyValue(pdf, "  "+d.Tipo, fmt.Sprintf("Bs %.2f (%d compras)", d.Monto, d.Compras))
+	}
 	pdfKeyValue(pdf, "Ventas", fmt.Sprintf("%d", resumen.Ventas))
 	pdfKeyValue(pdf, "Participantes", fmt.Sprintf("%d", resumen.Participantes))
 	pdfKeyValue(pdf, "Pagos pendientes", fmt.Sprintf("%d (Bs %.2f)", pendientes.Pendientes, pendientes.Monto))
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+func parseSalidaIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	salidaID, err := strconv.ParseUint(mux.Vars(r)["salida_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de salida invalido", nil, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(salidaID), true
+}
+
+// loadAgenciaForSalidaStaff permite operar la salida a quien gestiona la agencia o a un guía asignado a ella.
+func loadAgenciaForSalidaStaff(w http.ResponseWriter, r *http.Request, salidaID uint) (*models.AgenciaTurismo, bool) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID invalido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+
+	db := database.GetDB()
+	var agencia models.AgenciaTurismo
+	if err := db.First(&agencia, id).Error; err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", "Agencia no encontrada", nil, http.StatusNotFound)
+		return nil, false
+	}
+
+	if canManageAgencia(claims, &agencia) {
+		return &agencia, true
+	}
+
+	esGuia, err := services.EsGuiaDeSalida(db, claims.UserID, agencia.ID, salidaID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al validar permisos", err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if !esGuia {
+		utils.ErrorResponse(w, "FORBIDDEN", "No tiene permisos para operar esta salida", nil, http.StatusForbidden)
+		return nil, false
+	}
+
+	return &agencia, true
+}
+
+// GetAgenciaSalidaAsistencia retorna la asistencia registrada de una salida.
+func (h *AgenciaHandler) GetAgenciaSalidaAsistencia(w http.ResponseWriter, r *http.Request) {
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForSalidaStaff(w, r, salidaID)
+	if !ok {
+		return
+	}
+
+	resp, err := services.NewAsistenciaService(database.GetDB()).ObtenerAsistenciaSalida(agencia.ID, salidaID)
+	if err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Asistencia obtenida exitosamente", http.StatusOK)
+}
+
+// CheckInAgenciaSalida registra la llegada de una compra con el QR del voucher o el código de confirmación.
+func (h *AgenciaHandler) CheckInAgenciaSalida(w http.ResponseWriter, r *http.Request) {
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForSalidaStaff(w, r, salidaID)
+	if !ok {
+		return
+	}
+
+	var req models.CheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := services.NewAsistenciaService(database.GetDB()).RegistrarCheckIn(agencia.ID, salidaID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Check-in registrado exitosamente", http.StatusOK)
+}
+
+// IniciarAgenciaSalida marca una salida activa como en curso.
+func (h *AgenciaHandler) IniciarAgenciaSalida(w http.ResponseWriter, r *http.Request) {
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForSalidaStaff(w, r, salidaID)
+	if !ok {
+		return
+	}
+
+	resp, err := services.NewAsistenciaService(database.GetDB()).IniciarSalida(agencia.ID, salidaID)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Salida iniciada", http.StatusOK)
+}
+
+// CompletarAgenciaSalida cierra la salida y registra como no-show las compras sin check-in.
+func (h *AgenciaHandler) CompletarAgenciaSalida(w http.ResponseWriter, r *http.Request) {
+	salidaID, ok := parseSalidaIDParam(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForSalidaStaff(w, r, salidaID)
+	if !ok {
+		return
+	}
+
+	resp, err := services.NewAsistenciaService(database.GetDB()).CompletarSalida(agencia.ID, salidaID)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Salida completada", http.StatusOK)
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+func parseSerieIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	serieID, err := strconv.ParseUint(mux.Vars(r)["serie_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de serie invalido", nil, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(serieID), true
+}
+
+// GetAgenciaSalidaSeries lista las series recurrentes de la agencia (?paquete_id= opcional).
+func (h *AgenciaHandler) GetAgenciaSalidaSeries(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var paqueteID uint
+	if value := strings.TrimSpace(r.URL.Query().Get("paquete_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "paquete_id invalido", nil, http.StatusBadRequest)
+			return
+		}
+		paqueteID = uint(parsed)
+	}
+
+	series, err := services.NewSalidaSerieService(database.GetDB()).ListarSeries(agencia.ID, paqueteID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener series", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"series": series,
+	}, "Series obtenidas exitosamente", http.StatusOK)
+}
+
+// GetAgenciaSalidaSerie retorna una serie con sus próximas salidas.
+func (h *AgenciaHandler) GetAgenciaSalidaSerie(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	serieID, ok := parseSerieIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := services.NewSalidaSerieService(database.GetDB()).ObtenerSerie(agencia.ID, serieID)
+	if err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, resp, "Serie obtenida exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaSalidaSerie crea una serie recurrente y genera sus primeras salidas.
+func (h *AgenciaHandler) CreateAgenciaSalidaSerie(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CrearSalidaSerieRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serie, generacion, err := services.NewSalidaSerieService(database.GetDB()).CrearSerie(agencia.ID, claims.UserID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"serie":      serie,
+		"generacion": generacion,
+	}, "Serie creada exitosamente", http.StatusCreated)
+}
+
+// UpdateAgenciaSalidaSerie modifica una serie y opcionalmente sus salidas futuras (aplicar_a_futuras).
+func (h *AgenciaHandler) UpdateAgenciaSalidaSerie(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	serieID, ok := parseSerieIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ActualizarSalidaSerieRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serie, resultado, err := services.NewSalidaSerieService(database.GetDB()).ActualizarSerie(agencia.ID, serieID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"serie":     serie,
+		"resultado": resultado,
+	}, "Serie actualizada exitosamente", http.StatusOK)
+}
+
+// GenerarAgenciaSalidaSerie materializa ahora las salidas pendientes de la serie.
+func (h *AgenciaHandler) GenerarAgenciaSalidaSerie(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	serieID, ok := parseSerieIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	resultado, err := services.NewSalidaSerieService(database.GetDB()).Generar(agencia.ID, serieID)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resultado, "Salidas generadas exitosamente", http.StatusOK)
+}
+
+// CancelarAgenciaSalidaSerie cancela salidas futuras de la serie; sin hasta también desactiva la serie.
+func (h *AgenciaHandler) CancelarAgenciaSalidaSerie(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	serieID, ok := parseSerieIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CancelarSalidaSerieRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resultado, err := services.NewSalidaSerieService(database.GetDB()).CancelarSerie(agencia.ID, serieID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resultado, "Salidas de la serie canceladas", http.StatusOK)
+}
+
+// GetAgenciaFechasBloqueadas lista los períodos sin operación vigentes o futuros de la agencia.
+func (h *AgenciaHandler) GetAgenciaFechasBloqueadas(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var bloqueos []models.AgenciaFechaBloqueada
+	if err := database.GetDB().
+		Where("agencia_id = ? AND fecha_fin >= CURRENT_DATE", agencia.ID).
+		Order("fecha_inicio ASC").
+		Find(&bloqueos).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener fechas bloqueadas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"fechas_bloqueadas": bloqueos,
+	}, "Fechas bloqueadas obtenidas exitosamente", http.StatusOK)
+}
+
+// CreateAgenciaFechaBloqueada registra un período sin operación. Las salidas existentes no se modifican.
+func (h *AgenciaHandler) CreateAgenciaFechaBloqueada(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CrearFechaBloqueadaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validacion", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inicio, err := time.Parse("2006-01-02", strings.TrimSpace(req.FechaInicio))
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "fecha_inicio invalida (YYYY-MM-DD)", nil, http.StatusBadRequest)
+		return
+	}
+	fin, err := time.Parse("2006-01-02", strings.TrimSpace(req.FechaFin))
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "fecha_fin invalida (YYYY-MM-DD)", nil, http.StatusBadRequest)
+		return
+	}
+	if fin.Before(inicio) {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "fecha_fin debe ser igual o posterior a fecha_inicio", nil, http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if req.PaqueteID != nil {
+		var count int64
+		if err := db.Model(&models.PaqueteTuristico{}).
+			Where("id = ? AND agencia_id = ?", *req.PaqueteID, agencia.ID).
+			Count(&count).Error; err != nil {
+			utils.ErrorResponse(w, "DB_ERROR", "Error al validar paquete", err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "paquete_id no pertenece a la agencia", nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	bloqueo := models.AgenciaFechaBloqueada{
+		AgenciaID:   agencia.ID,
+		PaqueteID:   req.PaqueteID,
+		FechaInicio: inicio.Format("2006-01-02"),
+		FechaFin:    fin.Format("2006-01-02"),
+		Motivo:      optionalTrimmed(req.Motivo),
+	}
+	if err := db.Create(&bloqueo).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al bloquear fechas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, bloqueo, "Fechas bloqueadas exitosamente", http.StatusCreated)
+}
+
+// DeleteAgenciaFechaBloqueada elimina un período sin operación.
+func (h *AgenciaHandler) DeleteAgenciaFechaBloqueada(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	bloqueoID, err := strconv.ParseUint(mux.Vars(r)["bloqueo_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de bloqueo invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	result := database.GetDB().Where("id = ? AND agencia_id = ?", uint(bloqueoID), agencia.ID).Delete(&models.AgenciaFechaBloqueada{})
+	if result.Error != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar bloqueo", result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(w, "NOT_FOUND", "Bloqueo no encontrado", nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Bloqueo eliminado exitosamente", http.StatusOK)
+}
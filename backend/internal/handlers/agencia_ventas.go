@@ -9,6 +9,7 @@ import (
 
 	"andaria-backend/internal/database"
 	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
 	"andaria-backend/pkg/utils"
 
 	"github.com/gorilla/mux"
@@ -23,6 +24,10 @@ type agenciaVentaPagoRow struct {
 	Estado          string  `json:"estado" gorm:"column:estado"`
 	ComprobanteFoto *string `json:"comprobante_foto,omitempty" gorm:"column:comprobante_foto"`
 
+	// El comprobante coincide con el de otra compra; revisar las coincidencias antes de confirmar
+	ComprobanteAlerta        bool                                    `json:"comprobante_alerta" gorm:"column:comprobante_alerta"`
+	CoincidenciasComprobante []models.CoincidenciaComprobanteResumen `json:"coincidencias_comprobante,omitempty" gorm:"-"`
+
 	ConfirmadoPor     *uint      `json:"confirmado_por,omitempty" gorm:"column:confirmado_por"`
 	FechaConfirmacion *time.Time `json:"fecha_confirmacion,omitempty" gorm:"column:fecha_confirmacion"`
 	RazonRechazo      *string    `json:"razon_rechazo,omitempty" gorm:"column:razon_rechazo"`
@@ -101,6 +106,27 @@ type agenciaVentaSalidaCompraRow struct {
 	NotasEncargado    *string    `json:"notas_encargado,omitempty" gorm:"column:notas_encargado"`
 }
 
+type agenciaSalidaParticipanteRow struct {
+	ParticipanteID             uint      `json:"participante_id" gorm:"column:participante_id"`
+	CompraID                   uint      `json:"compra_id" gorm:"column:compra_id"`
+	CompraStatus               string    `json:"compra_status" gorm:"column:compra_status"`
+	Tipo                       string    `json:"tipo" gorm:"column:tipo"`
+	NombreCompleto             string    `json:"nombre_completo" gorm:"column:nombre_completo"`
+	TipoDocumento              string    `json:"tipo_documento" gorm:"column:tipo_documento"`
+	NumeroDocumento            string    `json:"numero_documento" gorm:"column:numero_documento"`
+	Nacionalidad               string    `json:"nacionalidad" gorm:"column:nacionalidad"`
+	FechaNacimiento            time.Time `json:"fecha_nacimiento" gorm:"column:fecha_nacimiento"`
+	ContactoEmergenciaNombre   string    `json:"contacto_emergencia_nombre" gorm:"column:contacto_emergencia_nombre"`
+	ContactoEmergenciaTelefono string    `json:"contacto_emergencia_telefono" gorm:"column:contacto_emergencia_telefono"`
+	Notas                      *string   `json:"notas,omitempty" gorm:"column:notas"`
+	TieneDiscapacidad          bool      `json:"tiene_discapacidad" gorm:"column:tiene_discapacidad"`
+	DescripcionDiscapacidad    *string   `json:"descripcion_discapacidad,omitempty" gorm:"column:descripcion_discapacidad"`
+
+	TuristaID    uint   `json:"turista_id" gorm:"column:turista_id"`
+	TuristaEmail string `json:"turista_email" gorm:"column:turista_email"`
+	TuristaPhone string `json:"turista_phone" gorm:"column:turista_phone"`
+}
+
 // GetAgenciaVentasPagos lista pagos (ventas) de paquetes asociados a una agencia.
 func (h *AgenciaHandler) GetAgenciaVentasPagos(w http.ResponseWriter, r *http.Request) {
 	claims, ok := getClaimsOrUnauthorized(w, r)
@@ -155,6 +181,14 @@ func (h *AgenciaHandler) GetAgenciaVentasPagos(w http.ResponseWriter, r *http.Re
 	if estado != "" {
 		base = base.Where("pc.estado = ?", estado)
 	}
+	if alerta := r.URL.Query().Get("alerta"); alerta != "" {
+		conAlerta, err := strconv.ParseBool(alerta)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "alerta invalida (use true|false)", nil, http.StatusBadRequest)
+			return
+		}
+		base = base.Where("pc.comprobante_alerta = ?", conAlerta)
+	}
 
 	var total int64
 	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
@@ -172,6 +206,7 @@ func (h *AgenciaHandler) GetAgenciaVentasPagos(w http.ResponseWriter, r *http.Re
 			pc.monto,
 			pc.estado,
 			pc.comprobante_foto,
+			pc.comprobante_alerta,
 			pc.confirmado_por,
 			pc.fecha_confirmacion,
 			pc.razon_rechazo,
@@ -204,6 +239,21 @@ func (h *AgenciaHandler) GetAgenciaVentasPagos(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	var conAlerta []uint
+	for _, row := range rows {
+		if row.ComprobanteAlerta {
+			conAlerta = append(conAlerta, row.PagoID)
+		}
+	}
+	coincidencias, err := services.NewComprobanteService(db).CoincidenciasDePagos(agencia.ID, conAlerta)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener coincidencias de comprobantes", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range rows {
+		rows[i].CoincidenciasComprobante = coincidencias[rows[i].PagoID]
+	}
+
 	utils.SuccessResponse(w, map[string]interface{}{
 		"pagos": rows,
 		"pagination": map[string]interface{}{
@@ -420,3 +470,128 @@ func (h *AgenciaHandler) GetAgenciaVentasSalidaCompras(w http.ResponseWriter, r
 		},
 	}, "Detalle de salida obtenido exitosamente", http.StatusOK)
 }
+
+// GetAgenciaVentasSalidaParticipantes retorna el manifiesto de participantes (con nombre) de una salida.
+func (h *AgenciaHandler) GetAgenciaVentasSalidaParticipantes(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	agenciaID64, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID invalido", nil, http.StatusBadRequest)
+		return
+	}
+	salidaID64, err := strconv.ParseUint(vars["salida_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de salida invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var agencia models.AgenciaTurismo
+	if err := database.GetDB().First(&agencia, agenciaID64).Error; err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", "Agencia no encontrada", nil, http.StatusNotFound)
+		return
+	}
+
+	if !canManageAgencia(claims, &agencia) {
+		utils.ErrorResponse(w, "FORBIDDEN", "No tiene permisos para ver ventas de esta agencia", nil, http.StatusForbidden)
+		return
+	}
+
+	db := database.GetDB()
+	var salida models.PaqueteSalidaHabilitada
+	if err := db.Table("paquete_salidas_habilitadas s").
+		Joins("JOIN paquetes_turisticos p ON p.id = s.paquete_id").
+		Where("s.id = ? AND p.agencia_id = ?", uint(salidaID64), agencia.ID).
+		Select("s.*").
+		First(&salida).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Salida no encontrada", nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener salida", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var participantes []agenciaSalidaParticipanteRow
+	if err := db.Raw(`
+		SELECT
+			cp.id AS participante_id,
+			c.id AS compra_id,
+			c.status AS compra_status,
+			cp.tipo,
+			cp.nombre_completo,
+			cp.tipo_documento,
+			cp.numero_documento,
+			cp.nacionalidad,
+			cp.fecha_nacimiento,
+			cp.contacto_emergencia_nombre,
+			cp.contacto_emergencia_telefono,
+			cp.notas,
+			c.tiene_discapacidad,
+			c.descripcion_discapacidad,
+			u.id AS turista_id,
+			u.email AS turista_email,
+			u.phone AS turista_phone
+		FROM compras_participantes cp
+		JOIN compras_paquetes c ON c.id = cp.compra_id
+		JOIN usuarios u ON u.id = c.turista_id
+		WHERE c.salida_id = ?
+		  AND c.status IN ('pendiente_confirmacion', 'reservada_con_anticipo', 'confirmada')
+		ORDER BY c.id ASC, cp.id ASC
+	`, salida.ID).Scan(&participantes).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener participantes", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Compras activas que aún no registraron el manifiesto completo
+	var comprasSinManifiesto []uint
+	if err := db.Raw(`
+		SELECT c.id
+		FROM compras_paquetes c
+		WHERE c.salida_id = ?
+		  AND c.status IN ('pendiente_confirmacion', 'reservada_con_anticipo', 'confirmada')
+		  AND (SELECT COUNT(*) FROM compras_participantes cp WHERE cp.compra_id = c.id) < c.total_participantes
+		ORDER BY c.id ASC
+	`, salida.ID).Scan(&comprasSinManifiesto).Error; err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener participantes", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"salida":                 salida,
+		"participantes":          participantes,
+		"compras_sin_manifiesto": comprasSinManifiesto,
+	}, "Manifiesto de salida obtenido exitosamente", http.StatusOK)
+}
+
+// GetAgenciaVentaCompraModificaciones retorna el historial de modificaciones de una compra de la agencia.
+func (h *AgenciaHandler) GetAgenciaVentaCompraModificaciones(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	compraID, err := strconv.ParseUint(mux.Vars(r)["compra_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de compra invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	modificaciones, err := services.ListarModificacionesCompraAgencia(database.GetDB(), agencia.ID, uint(compraID))
+	if err != nil {
+		if err.Error() == "compra no encontrada" {
+			utils.ErrorResponse(w, "NOT_FOUND", "Compra no encontrada", nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener modificaciones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"modificaciones": modificaciones,
+	}, "Historial de modificaciones obtenido exitosamente", http.StatusOK)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// ReportarAgenciaVentaPagoComprobante envía al admin un pago cuyo comprobante coincide con el de otra compra.
+func (h *AgenciaHandler) ReportarAgenciaVentaPagoComprobante(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	pagoID, err := strconv.ParseUint(mux.Vars(r)["pago_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de pago invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReportarComprobanteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	req.Motivo = strings.TrimSpace(req.Motivo)
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reportadas, err := services.NewComprobanteService(database.GetDB()).ReportarPago(agencia.ID, uint(pagoID), claims.UserID, req.Motivo)
+	if err != nil {
+		if errors.Is(err, services.ErrPagoAgenciaNoEncontrado) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"pago_id":    pagoID,
+		"reportadas": reportadas,
+	}, "Comprobante reportado al administrador", http.StatusOK)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// calendarioFeedURL arma la URL pública del feed. Usa PUBLIC_API_URL si está definida; si no, el host del request.
+func calendarioFeedURL(r *http.Request, ruta string) string {
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("PUBLIC_API_URL")), "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/api/v1/public/calendario/" + ruta
+}
+
+func writeICS(w http.ResponseWriter, filename string, contenido []byte) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(contenido)
+}
+
+func (h *AgenciaHandler) responderFeedAgencia(w http.ResponseWriter, r *http.Request, regenerar bool) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := services.NewCalendarioService(database.GetDB()).ObtenerFeedAgencia(agencia.ID, regenerar)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener el calendario", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	mensaje := "Calendario obtenido exitosamente"
+	if regenerar {
+		mensaje = "Enlace del calendario regenerado; el enlace anterior dejó de funcionar"
+	}
+	utils.SuccessResponse(w, map[string]interface{}{
+		"url":           calendarioFeedURL(r, "agencias/"+feed.Token+".ics"),
+		"ultimo_acceso": feed.UltimoAcceso,
+	}, mensaje, http.StatusOK)
+}
+
+// GetAgenciaCalendarioFeed retorna la URL secreta del calendario .ics de salidas de la agencia.
+func (h *AgenciaHandler) GetAgenciaCalendarioFeed(w http.ResponseWriter, r *http.Request) {
+	h.responderFeedAgencia(w, r, false)
+}
+
+// RegenerarAgenciaCalendarioFeed rota el token del calendario de la agencia.
+func (h *AgenciaHandler) RegenerarAgenciaCalendarioFeed(w http.ResponseWriter, r *http.Request) {
+	h.responderFeedAgencia(w, r, true)
+}
+
+func (h *CompraHandler) responderFeedTurista(w http.ResponseWriter, r *http.Request, regenerar bool) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas tienen calendario de compras", nil, http.StatusForbidden)
+		return
+	}
+
+	feed, err := services.NewCalendarioService(database.GetDB()).ObtenerFeedTurista(claims.UserID, regenerar)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener el calendario", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	mensaje := "Calendario obtenido exitosamente"
+	if regenerar {
+		mensaje = "Enlace del calendario regenerado; el enlace anterior dejó de funcionar"
+	}
+	utils.SuccessResponse(w, map[string]interface{}{
+		"url":           calendarioFeedURL(r, "turistas/"+feed.Token+".ics"),
+		"ultimo_acceso": feed.UltimoAcceso,
+	}, mensaje, http.StatusOK)
+}
+
+// GetMiCalendarioFeed retorna la URL secreta del calendario .ics de las compras confirmadas del turista.
+func (h *CompraHandler) GetMiCalendarioFeed(w http.ResponseWriter, r *http.Request) {
+	h.responderFeedTurista(w, r, false)
+}
+
+// RegenerarMiCalendarioFeed rota el token del calendario del turista.
+func (h *CompraHandler) RegenerarMiCalendarioFeed(w http.ResponseWriter, r *http.Request) {
+	h.responderFeedTurista(w, r, true)
+}
+
+// GetCalendarioAgenciaICS sirve el feed .ics de la agencia (público, protegido por el token).
+func (h *AgenciaHandler) GetCalendarioAgenciaICS(w http.ResponseWriter, r *http.Request) {
+	contenido, err := services.NewCalendarioService(database.GetDB()).FeedAgenciaICS(mux.Vars(r)["token"])
+	if err != nil {
+		if errors.Is(err, services.ErrCalendarioNoEncontrado) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Calendario no encontrado", nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al generar el calendario", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeICS(w, "salidas.ics", contenido)
+}
+
+// GetCalendarioTuristaICS sirve el feed .ics del turista (público, protegido por el token).
+func (h *CompraHandler) GetCalendarioTuristaICS(w http.ResponseWriter, r *http.Request) {
+	contenido, err := services.NewCalendarioService(database.GetDB()).FeedTuristaICS(mux.Vars(r)["token"])
+	if err != nil {
+		if errors.Is(err, services.ErrCalendarioNoEncontrado) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Calendario no encontrado", nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al generar el calendario", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeICS(w, "mis-tours.ics", contenido)
+}
+
+// ImportarSalidasICS crea salidas manuales a partir de un archivo .ics (campo "archivo").
+// Campos opcionales del formulario: cupo_maximo (por defecto el del paquete) y cupo_minimo.
+func (h *SalidaHandler) ImportarSalidasICS(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	agenciaID, ok := getAgenciaIDForEncargado(w, claims)
+	if !ok {
+		return
+	}
+
+	paqueteID, err := strconv.ParseUint(mux.Vars(r)["paquete_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de paquete invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseMultipartForm(2 << 20); err != nil {
+		utils.ErrorResponse(w, "PARSE_ERROR", "Error al procesar el formulario", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("archivo")
+	if err != nil {
+		utils.ErrorResponse(w, "NO_FILE", "No se proporcionó ningún archivo", err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > 1<<20 {
+		utils.ErrorResponse(w, "FILE_TOO_LARGE", "El archivo no debe superar 1MB", nil, http.StatusBadRequest)
+		return
+	}
+
+	cupos := map[string]int{}
+	for _, campo := range []string{"cupo_minimo", "cupo_maximo"} {
+		value := strings.TrimSpace(r.FormValue(campo))
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", campo+" invalido", nil, http.StatusBadRequest)
+			return
+		}
+		cupos[campo] = parsed
+	}
+
+	eventos, err := utils.ParseICalendar(file)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Archivo .ics invalido", err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(eventos) == 0 {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "El archivo no contiene eventos", nil, http.StatusBadRequest)
+		return
+	}
+	if len(eventos) > 500 {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "El archivo no puede tener más de 500 eventos", nil, http.StatusBadRequest)
+		return
+	}
+
+	resultados, err := h.salidaService.ImportarSalidasICS(agenciaID, claims.UserID, uint(paqueteID), eventos, cupos["cupo_minimo"], cupos["cupo_maximo"])
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	creadas := 0
+	for _, resultado := range resultados {
+		if resultado.SalidaID != nil && !resultado.Duplicada {
+			creadas++
+		}
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"total":      len(resultados),
+		"creadas":    creadas,
+		"omitidas":   len(resultados) - creadas,
+		"resultados": resultados,
+	}, "Importación finalizada", http.StatusOK)
+}
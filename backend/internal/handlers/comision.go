@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+// ComisionHandler administra las comisiones de la plataforma sobre las ventas de las agencias (admin).
+type ComisionHandler struct {
+	validate        *validator.Validate
+	comisionService *services.ComisionService
+}
+
+func NewComisionHandler() *ComisionHandler {
+	return &ComisionHandler{
+		validate:        validator.New(),
+		comisionService: services.NewComisionService(database.GetDB()),
+	}
+}
+
+// GetResumenComisiones lista por agencia las ventas, comisiones, ajustes y pagos del rango (mes/anio o
+// fecha_inicio/fecha_fin) y el saldo que cada una adeuda a la fecha.
+func (h *ComisionHandler) GetResumenComisiones(w http.ResponseWriter, r *http.Request) {
+	rango, err := parseReportRange(r)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	agencias, err := h.comisionService.Resumen(rango.Start, rango.End)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener el resumen de comisiones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var totales models.ResumenComisionAgencia
+	for _, a := range agencias {
+		totales.VentasBrutas += a.VentasBrutas
+		totales.Reembolsos += a.Reembolsos
+		totales.Comision += a.Comision
+		totales.Ajustes += a.Ajustes
+		totales.Pagos += a.Pagos
+		totales.SaldoAdeudado += a.SaldoAdeudado
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"rango": map[string]interface{}{
+			"inicio": rango.StartLabel,
+			"fin":    rango.EndLabel,
+		},
+		"tarifa_por_defecto": services.PorcentajeComisionDefecto(),
+		"totales": map[string]interface{}{
+			"ventas_brutas":  totales.VentasBrutas,
+			"reembolsos":     totales.Reembolsos,
+			"comision":       totales.Comision,
+			"ajustes":        totales.Ajustes,
+			"pagos":          totales.Pagos,
+			"saldo_adeudado": totales.SaldoAdeudado,
+		},
+		"agencias": agencias,
+	}, "Resumen de comisiones obtenido exitosamente", http.StatusOK)
+}
+
+// ListarTarifasComision lista las tarifas por agencia y por categoría.
+func (h *ComisionHandler) ListarTarifasComision(w http.ResponseWriter, r *http.Request) {
+	tarifas, err := h.comisionService.ListarTarifas()
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener las tarifas de comisión", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"tarifa_por_defecto": services.PorcentajeComisionDefecto(),
+		"tarifas":            tarifas,
+	}, "Tarifas de comisión obtenidas exitosamente", http.StatusOK)
+}
+
+func (h *ComisionHandler) decodeTarifa(w http.ResponseWriter, r *http.Request) (*models.TarifaComisionRequest, bool) {
+	var req models.TarifaComisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Notas != nil {
+		notas := strings.TrimSpace(*req.Notas)
+		req.Notas = &notas
+		if notas == "" {
+			req.Notas = nil
+		}
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+func parseTarifaComisionID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// CrearTarifaComision registra la tarifa de una agencia, de una categoría o de una categoría en una agencia.
+func (h *ComisionHandler) CrearTarifaComision(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := h.decodeTarifa(w, r)
+	if !ok {
+		return
+	}
+
+	tarifa, err := h.comisionService.CrearTarifa(claims.UserID, req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, tarifa, "Tarifa de comisión creada exitosamente", http.StatusCreated)
+}
+
+// ActualizarTarifaComision modifica una tarifa; no cambia las ventas ya registradas.
+func (h *ComisionHandler) ActualizarTarifaComision(w http.ResponseWriter, r *http.Request) {
+	tarifaID, ok := parseTarifaComisionID(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := h.decodeTarifa(w, r)
+	if !ok {
+		return
+	}
+
+	tarifa, err := h.comisionService.ActualizarTarifa(tarifaID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrTarifaComisionNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, tarifa, "Tarifa de comisión actualizada exitosamente", http.StatusOK)
+}
+
+// EliminarTarifaComision elimina una tarifa; las ventas siguientes usan la siguiente tarifa aplicable.
+func (h *ComisionHandler) EliminarTarifaComision(w http.ResponseWriter, r *http.Request) {
+	tarifaID, ok := parseTarifaComisionID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.comisionService.EliminarTarifa(tarifaID); err != nil {
+		if errors.Is(err, services.ErrTarifaComisionNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al eliminar la tarifa de comisión", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Tarifa de comisión eliminada exitosamente", http.StatusOK)
+}
+
+// RegistrarAjusteComision asienta un ajuste manual del saldo de una agencia.
+func (h *ComisionHandler) RegistrarAjusteComision(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AjusteComisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	req.Descripcion = strings.TrimSpace(req.Descripcion)
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asiento, err := h.comisionService.RegistrarAjuste(claims.UserID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, asiento, "Ajuste de comisiones registrado", http.StatusCreated)
+}
+
+// RegistrarPagoComision asienta un pago de comisiones recibido de una agencia.
+func (h *ComisionHandler) RegistrarPagoComision(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.PagoComisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	req.Referencia = strings.TrimSpace(req.Referencia)
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asiento, err := h.comisionService.RegistrarPago(claims.UserID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, asiento, "Pago de comisiones registrado", http.StatusCreated)
+}
+
+// ListarLiquidacionesComision lista las liquidaciones emitidas, opcionalmente de una agencia (agencia_id).
+func (h *ComisionHandler) ListarLiquidacionesComision(w http.ResponseWriter, r *http.Request) {
+	var agenciaID uint64
+	if value := strings.TrimSpace(r.URL.Query().Get("agencia_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "agencia_id invalido", nil, http.StatusBadRequest)
+			return
+		}
+		agenciaID = parsed
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	liquidaciones, total, err := h.comisionService.ListarLiquidaciones(uint(agenciaID), page, pageSize)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener las liquidaciones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"liquidaciones": liquidaciones,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, "Liquidaciones obtenidas exitosamente", http.StatusOK)
+}
+
+// EmitirLiquidacionesComision emite las liquidaciones de un mes cerrado, de una agencia o de todas las que
+// tengan movimientos sin liquidar (el worker las emite solo al cerrar cada mes).
+func (h *ComisionHandler) EmitirLiquidacionesComision(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.EmitirLiquidacionesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	liquidaciones, err := h.comisionService.EmitirLiquidacionesMes(req.Mes, req.Anio, req.AgenciaID, &claims.UserID)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), map[string]interface{}{
+			"emitidas": liquidaciones,
+		}, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"emitidas":      len(liquidaciones),
+		"liquidaciones": liquidaciones,
+	}, "Liquidaciones emitidas", http.StatusCreated)
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -15,14 +17,20 @@ import (
 )
 
 type CompraHandler struct {
-	validate      *validator.Validate
-	compraService *services.CompraService
+	validate           *validator.Validate
+	compraService      *services.CompraService
+	listaEsperaService *services.ListaEsperaService
+	voucherService     *services.VoucherService
+	solicitudService   *services.SolicitudPrivadaService
 }
 
 func NewCompraHandler() *CompraHandler {
 	return &CompraHandler{
-		validate:      validator.New(),
-		compraService: services.NewCompraService(database.GetDB()),
+		validate:           validator.New(),
+		compraService:      services.NewCompraService(database.GetDB()),
+		listaEsperaService: services.NewListaEsperaService(database.GetDB()),
+		voucherService:     services.NewVoucherService(database.GetDB()),
+		solicitudService:   services.NewSolicitudPrivadaService(database.GetDB()),
 	}
 }
 
@@ -56,9 +64,13 @@ func (h *CompraHandler) CrearCompra(w http.ResponseWriter, r *http.Request) {
 	}
 
 	utils.SuccessResponse(w, map[string]interface{}{
-		"compra_id":    result.CompraID,
-		"salida_id":    result.SalidaID,
-		"precio_total": result.PrecioTotal,
+		"compra_id":            result.CompraID,
+		"salida_id":            result.SalidaID,
+		"precio_total":         result.PrecioTotal,
+		"precio_sin_descuento": result.PrecioSinDescuento,
+		"descuento_aplicado":   result.DescuentoAplicado,
+		"monto_anticipo":       result.MontoAnticipo,
+		"fecha_limite_saldo":   result.FechaLimiteSaldo,
 	}, result.Mensaje, http.StatusCreated)
 }
 
@@ -90,6 +102,41 @@ func (h *CompraHandler) ObtenerDetalleCompra(w http.ResponseWriter, r *http.Requ
 	utils.SuccessResponse(w, resp, "Compra obtenida exitosamente", http.StatusOK)
 }
 
+// DescargarVoucher retorna el voucher PDF (con QR) de una compra confirmada del turista.
+func (h *CompraHandler) DescargarVoucher(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden descargar vouchers", nil, http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id64, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	voucher, err := h.voucherService.ObtenerVoucherTurista(uint(id64), claims.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrVoucherNoDisponible) {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusConflict)
+			return
+		}
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", voucher.Filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(voucher.Data)
+}
+
 // ListarMisCompras lista compras del turista con paginación.
 func (h *CompraHandler) ListarMisCompras(w http.ResponseWriter, r *http.Request) {
 	claims, ok := getClaimsOrUnauthorized(w, r)
@@ -132,7 +179,7 @@ func (h *CompraHandler) ListarMisCompras(w http.ResponseWriter, r *http.Request)
 	}, "Compras obtenidas exitosamente", http.StatusOK)
 }
 
-// CancelarCompra cancela una compra pendiente del turista
+// CancelarCompra cancela una compra del turista (con reembolso si ya estaba pagada)
 func (h *CompraHandler) CancelarCompra(w http.ResponseWriter, r *http.Request) {
 	claims, ok := getClaimsOrUnauthorized(w, r)
 	if !ok {
@@ -157,10 +204,146 @@ func (h *CompraHandler) CancelarCompra(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	if err := h.compraService.CancelarCompra(uint(id64), claims.UserID, req.Razon); err != nil {
+	reembolso, err := h.compraService.CancelarCompra(uint(id64), claims.UserID, req.Razon)
+	if err != nil {
 		utils.ErrorResponse(w, "CANCELATION_ERROR", err.Error(), nil, http.StatusBadRequest)
 		return
 	}
 
-	utils.SuccessResponse(w, nil, "Compra cancelada exitosamente", http.StatusOK)
+	utils.SuccessResponse(w, map[string]interface{}{
+		"reembolso": reembolso,
+	}, "Compra cancelada exitosamente", http.StatusOK)
+}
+
+// PreviewCancelacion muestra el reembolso que corresponde si el turista cancela hoy.
+func (h *CompraHandler) PreviewCancelacion(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden cancelar sus compras", nil, http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id64, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	preview, err := h.compraService.PreviewCancelacion(uint(id64), claims.UserID)
+	if err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, preview, "Reembolso estimado obtenido exitosamente", http.StatusOK)
+}
+
+// ActualizarParticipantes registra o reemplaza el listado de participantes de una compra del turista.
+func (h *CompraHandler) ActualizarParticipantes(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden registrar participantes", nil, http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id64, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ActualizarParticipantesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	participantes, err := h.compraService.ActualizarParticipantes(uint(id64), claims.UserID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"participantes": participantes,
+	}, "Participantes registrados exitosamente", http.StatusOK)
+}
+
+// ModificarCompra cambia fecha, tipo o participantes de una compra del turista.
+func (h *CompraHandler) ModificarCompra(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden modificar sus compras", nil, http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id64, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ModificarCompraRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.compraService.ModificarCompra(uint(id64), claims.UserID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, result, "Compra modificada exitosamente", http.StatusOK)
+}
+
+// ListarModificacionesCompra retorna el historial de cambios de una compra del turista.
+func (h *CompraHandler) ListarModificacionesCompra(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id64, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	modificaciones, err := h.compraService.ListarModificacionesCompra(uint(id64), claims.UserID)
+	if err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"modificaciones": modificaciones,
+	}, "Historial de modificaciones obtenido exitosamente", http.StatusOK)
 }
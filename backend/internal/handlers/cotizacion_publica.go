@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+func parseCantidadQuery(r *http.Request, key string, def int) (int, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get(key))
+	if raw == "" {
+		return def, true
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// CotizarPaquetePublico calcula el precio desglosado y la disponibilidad de una compra sin reservar cupos.
+// GET /public/paquetes/{id}/cotizar?fecha=&tipo=&adultos=&ninos_pagan=&ninos_gratis=&extranjero=&codigo=
+func (h *CompraHandler) CotizarPaquetePublico(w http.ResponseWriter, r *http.Request) {
+	id64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	fecha := strings.TrimSpace(q.Get("fecha"))
+	if fecha == "" {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "fecha es requerida (YYYY-MM-DD)", nil, http.StatusBadRequest)
+		return
+	}
+	if _, err := time.Parse("2006-01-02", fecha); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "fecha inválida (use YYYY-MM-DD)", nil, http.StatusBadRequest)
+		return
+	}
+
+	tipo := strings.TrimSpace(q.Get("tipo"))
+	if tipo == "" {
+		tipo = "compartido"
+	}
+	if tipo != "compartido" && tipo != "privado" {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "tipo inválido (use compartido o privado)", nil, http.StatusBadRequest)
+		return
+	}
+
+	adultos, ok := parseCantidadQuery(r, "adultos", 1)
+	if !ok || adultos < 1 {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "adultos debe ser un entero mayor o igual a 1", nil, http.StatusBadRequest)
+		return
+	}
+	ninosPagan, ok := parseCantidadQuery(r, "ninos_pagan", 0)
+	if !ok {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "ninos_pagan debe ser un entero mayor o igual a 0", nil, http.StatusBadRequest)
+		return
+	}
+	ninosGratis, ok := parseCantidadQuery(r, "ninos_gratis", 0)
+	if !ok {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "ninos_gratis debe ser un entero mayor o igual a 0", nil, http.StatusBadRequest)
+		return
+	}
+
+	extranjero := false
+	if raw := strings.TrimSpace(q.Get("extranjero")); raw != "" {
+		extranjero, err = strconv.ParseBool(raw)
+		if err != nil {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "extranjero debe ser true o false", nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	req := models.CotizarCompraRequest{
+		PaqueteID:           uint(id64),
+		FechaSeleccionada:   fecha,
+		TipoCompra:          tipo,
+		Extranjero:          extranjero,
+		CantidadAdultos:     adultos,
+		CantidadNinosPagan:  ninosPagan,
+		CantidadNinosGratis: ninosGratis,
+	}
+	if codigo := strings.TrimSpace(q.Get("codigo")); codigo != "" {
+		req.CodigoPromocion = &codigo
+	}
+
+	cotizacion, err := h.compraService.CotizarCompra(&req)
+	if err != nil {
+		if errors.Is(err, services.ErrCotizacionPaqueteNoDisponible) {
+			utils.ErrorResponse(w, "NOT_FOUND", "Paquete no encontrado", nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, cotizacion, "Cotización calculada exitosamente", http.StatusOK)
+}
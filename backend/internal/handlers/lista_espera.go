@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// UnirseListaEspera inscribe al turista en la lista de espera de una salida llena.
+func (h *CompraHandler) UnirseListaEspera(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden unirse a la lista de espera", nil, http.StatusForbidden)
+		return
+	}
+
+	salidaID, err := strconv.ParseUint(mux.Vars(r)["salida_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de salida inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UnirseListaEsperaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entrada, err := h.listaEsperaService.Unirse(claims.UserID, uint(salidaID), &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, entrada, "Inscrito en la lista de espera", http.StatusCreated)
+}
+
+// ListarMisListasEspera lista las entradas de lista de espera del turista.
+func (h *CompraHandler) ListarMisListasEspera(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden ver sus listas de espera", nil, http.StatusForbidden)
+		return
+	}
+
+	entradas, err := h.listaEsperaService.ListarTurista(claims.UserID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener listas de espera", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"lista_espera": entradas,
+	}, "Listas de espera obtenidas exitosamente", http.StatusOK)
+}
+
+// SalirListaEspera retira al turista de una lista de espera (rechazando la oferta si la tenía).
+func (h *CompraHandler) SalirListaEspera(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden salir de la lista de espera", nil, http.StatusForbidden)
+		return
+	}
+
+	id64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.listaEsperaService.Salir(claims.UserID, uint(id64)); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, nil, "Salió de la lista de espera", http.StatusOK)
+}
+
+// AceptarOfertaListaEspera convierte la oferta de cupos en una compra.
+func (h *CompraHandler) AceptarOfertaListaEspera(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden realizar compras", nil, http.StatusForbidden)
+		return
+	}
+
+	id64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.AceptarOfertaListaEsperaRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.compraService.AceptarOfertaListaEspera(claims.UserID, uint(id64), &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"compra_id":            result.CompraID,
+		"salida_id":            result.SalidaID,
+		"precio_total":         result.PrecioTotal,
+		"precio_sin_descuento": result.PrecioSinDescuento,
+		"descuento_aplicado":   result.DescuentoAplicado,
+		"monto_anticipo":       result.MontoAnticipo,
+		"fecha_limite_saldo":   result.FechaLimiteSaldo,
+	}, result.Mensaje, http.StatusCreated)
+}
+
+// GetAgenciaVentasSalidaListaEspera lista la lista de espera de una salida de la agencia.
+func (h *AgenciaHandler) GetAgenciaVentasSalidaListaEspera(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	salidaID, err := strconv.ParseUint(mux.Vars(r)["salida_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de salida invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	entradas, err := services.NewListaEsperaService(database.GetDB()).ListarSalidaAgencia(agencia.ID, uint(salidaID))
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener lista de espera", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"lista_espera": entradas,
+	}, "Lista de espera obtenida exitosamente", http.StatusOK)
+}
@@ -15,14 +15,20 @@ import (
 )
 
 type PagoHandler struct {
-	validate    *validator.Validate
-	pagoService *services.PagoService
+	validate           *validator.Validate
+	pagoService        *services.PagoService
+	pasarelaService    *services.PasarelaService
+	webhookService     *services.WebhookPagoService
+	comprobanteService *services.ComprobanteService
 }
 
 func NewPagoHandler() *PagoHandler {
 	return &PagoHandler{
-		validate:    validator.New(),
-		pagoService: services.NewPagoService(database.GetDB()),
+		validate:           validator.New(),
+		pagoService:        services.NewPagoService(database.GetDB()),
+		pasarelaService:    services.NewPasarelaService(database.GetDB()),
+		webhookService:     services.NewWebhookPagoService(database.GetDB()),
+		comprobanteService: services.NewComprobanteService(database.GetDB()),
 	}
 }
 
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+func parseCoincidenciaComprobanteID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// ListarComprobantesDuplicados lista los comprobantes que coinciden con los de otras compras (admin). Por
+// defecto muestra las coincidencias abiertas, primero las reportadas por las agencias.
+func (h *PagoHandler) ListarComprobantesDuplicados(w http.ResponseWriter, r *http.Request) {
+	var estados []string
+	switch estado := r.URL.Query().Get("estado"); estado {
+	case "":
+		estados = []string{services.CoincidenciaComprobantePendiente, services.CoincidenciaComprobanteReportada}
+	case "todos":
+	case services.CoincidenciaComprobantePendiente, services.CoincidenciaComprobanteReportada,
+		services.CoincidenciaComprobanteLegitima, services.CoincidenciaComprobanteFraude:
+		estados = []string{estado}
+	default:
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "estado invalido (use todos|pendiente|reportada|legitima|fraude)", nil, http.StatusBadRequest)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	coincidencias, total, err := h.comprobanteService.ListarCoincidencias(estados, page, pageSize)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener comprobantes duplicados", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"coincidencias": coincidencias,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, "Comprobantes duplicados obtenidos exitosamente", http.StatusOK)
+}
+
+// ObtenerComprobanteDuplicado retorna una coincidencia con los dos pagos, sus compras y agencias (admin).
+func (h *PagoHandler) ObtenerComprobanteDuplicado(w http.ResponseWriter, r *http.Request) {
+	coincidenciaID, ok := parseCoincidenciaComprobanteID(w, r)
+	if !ok {
+		return
+	}
+
+	coincidencia, err := h.comprobanteService.ObtenerCoincidencia(coincidenciaID)
+	if err != nil {
+		if errors.Is(err, services.ErrCoincidenciaComprobanteNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener el comprobante duplicado", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, coincidencia, "Comprobante duplicado obtenido exitosamente", http.StatusOK)
+}
+
+// ResolverComprobanteDuplicado marca una coincidencia como legítima o fraude (admin).
+func (h *PagoHandler) ResolverComprobanteDuplicado(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	coincidenciaID, ok := parseCoincidenciaComprobanteID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ResolverCoincidenciaComprobanteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	req.Notas = strings.TrimSpace(req.Notas)
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	coincidencia, err := h.comprobanteService.ResolverCoincidencia(coincidenciaID, claims.UserID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrCoincidenciaComprobanteNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, coincidencia, "Comprobante duplicado resuelto", http.StatusOK)
+}
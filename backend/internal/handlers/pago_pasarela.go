@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// ListarProveedoresPago lista las pasarelas de pago en línea habilitadas.
+func (h *PagoHandler) ListarProveedoresPago(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, map[string]interface{}{
+		"proveedores": services.ProveedoresPagoDisponibles(),
+	}, "Proveedores de pago obtenidos", http.StatusOK)
+}
+
+// IniciarPagoPasarela crea un pago en línea y retorna la URL de checkout de la pasarela (solo turista).
+func (h *PagoHandler) IniciarPagoPasarela(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden registrar pagos", nil, http.StatusForbidden)
+		return
+	}
+
+	var req models.IniciarPagoPasarelaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	req.Proveedor = strings.TrimSpace(req.Proveedor)
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.pasarelaService.IniciarPago(claims.UserID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrProveedorPagoNoDisponible) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, result, "Pago en línea iniciado. Complete el pago en la pasarela.", http.StatusCreated)
+}
+
+// SincronizarPagoPasarela consulta a la pasarela el estado de un pago en línea (turista dueño, encargado o admin).
+func (h *PagoHandler) SincronizarPagoPasarela(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	id64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	pago, err := h.pagoService.ObtenerPagoConContexto(uint(id64))
+	if err != nil {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	compra := pago.Compra
+	if compra == nil || compra.Paquete == nil || compra.Paquete.Agencia == nil {
+		utils.ErrorResponse(w, "DB_ERROR", "No se pudo resolver la compra asociada al pago", nil, http.StatusInternalServerError)
+		return
+	}
+	if compra.TuristaID != claims.UserID && !canManageAgencia(claims, compra.Paquete.Agencia) {
+		utils.ErrorResponse(w, "FORBIDDEN", "No tiene permisos sobre este pago", nil, http.StatusForbidden)
+		return
+	}
+
+	actualizado, err := h.pasarelaService.SincronizarPago(pago.ID)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, actualizado, "Estado del pago actualizado", http.StatusOK)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Tamaño máximo aceptado para el cuerpo de un webhook de pago.
+const maxCuerpoWebhookPago = 64 << 10
+
+// WebhookPago recibe las notificaciones de pago de pasarelas y bancos. El evento se guarda siempre; se
+// responde 2xx también cuando queda en revisión para que el proveedor no lo reintente.
+func (h *PagoHandler) WebhookPago(w http.ResponseWriter, r *http.Request) {
+	cuerpo, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCuerpoWebhookPago))
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_BODY", "No se pudo leer el webhook", nil, http.StatusBadRequest)
+		return
+	}
+
+	proveedor := mux.Vars(r)["proveedor"]
+	result, err := h.webhookService.Recibir(proveedor, r.Header, cuerpo)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFirmaWebhookInvalida):
+			utils.ErrorResponse(w, "INVALID_SIGNATURE", err.Error(), nil, http.StatusUnauthorized)
+		case errors.Is(err, services.ErrProveedorPagoNoDisponible):
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		case errors.Is(err, services.ErrWebhookInvalido):
+			utils.ErrorResponse(w, "INVALID_BODY", err.Error(), nil, http.StatusBadRequest)
+		default:
+			// 5xx: el proveedor reintenta y el evento registrado se vuelve a procesar
+			log.Printf("Error procesando webhook de %s: %v", proveedor, err)
+			utils.ErrorResponse(w, "WEBHOOK_ERROR", "No se pudo procesar el webhook", nil, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	mensaje := "Webhook procesado"
+	if result.Duplicado {
+		mensaje = "Webhook ya recibido"
+	}
+	utils.SuccessResponse(w, result, mensaje, http.StatusOK)
+}
+
+func parseEventoWebhookID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id64, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// ListarEventosWebhookPago lista los webhooks de pago recibidos; por defecto la cola de revisión (admin).
+func (h *PagoHandler) ListarEventosWebhookPago(w http.ResponseWriter, r *http.Request) {
+	estado := r.URL.Query().Get("estado")
+	switch estado {
+	case "":
+		estado = services.EventoWebhookPendienteRevision
+	case "todos":
+		estado = ""
+	case services.EventoWebhookRecibido, services.EventoWebhookProcesado, services.EventoWebhookIgnorado,
+		services.EventoWebhookPendienteRevision, services.EventoWebhookDescartado,
+		services.EventoWebhookFirmaInvalida, services.EventoWebhookInvalido:
+	default:
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "estado invalido (use todos|recibido|procesado|ignorado|pendiente_revision|descartado|firma_invalida|invalido)", nil, http.StatusBadRequest)
+		return
+	}
+	proveedor := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("proveedor")))
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	eventos, total, err := h.webhookService.ListarEventos(estado, proveedor, page, pageSize)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener webhooks de pago", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"eventos": eventos,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, "Webhooks de pago obtenidos exitosamente", http.StatusOK)
+}
+
+// ObtenerEventoWebhookPago retorna un webhook de pago con su cuerpo original (admin).
+func (h *PagoHandler) ObtenerEventoWebhookPago(w http.ResponseWriter, r *http.Request) {
+	eventoID, ok := parseEventoWebhookID(w, r)
+	if !ok {
+		return
+	}
+
+	evento, err := h.webhookService.ObtenerEvento(eventoID)
+	if err != nil {
+		if errors.Is(err, services.ErrEventoWebhookNoEncontrado) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener el webhook de pago", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, evento, "Webhook de pago obtenido exitosamente", http.StatusOK)
+}
+
+// AsignarEventoWebhookPago aplica un webhook en revisión a un pago pendiente y lo confirma (admin).
+func (h *PagoHandler) AsignarEventoWebhookPago(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	eventoID, ok := parseEventoWebhookID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AsignarEventoWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	evento, err := h.webhookService.AsignarEvento(eventoID, claims.UserID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrEventoWebhookNoEncontrado) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, evento, "Webhook aplicado y pago confirmado", http.StatusOK)
+}
+
+// DescartarEventoWebhookPago cierra un webhook en revisión sin aplicarlo (admin).
+func (h *PagoHandler) DescartarEventoWebhookPago(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	eventoID, ok := parseEventoWebhookID(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.DescartarEventoWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	req.Notas = strings.TrimSpace(req.Notas)
+
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	evento, err := h.webhookService.DescartarEvento(eventoID, claims.UserID, req.Notas)
+	if err != nil {
+		if errors.Is(err, services.ErrEventoWebhookNoEncontrado) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, evento, "Webhook descartado", http.StatusOK)
+}
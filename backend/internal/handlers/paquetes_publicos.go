@@ -37,6 +37,7 @@ func (h *AgenciaHandler) GetPaquetesPublicos(w http.ResponseWriter, r *http.Requ
 
 	precioMinStr := strings.TrimSpace(r.URL.Query().Get("precio_min"))
 	precioMaxStr := strings.TrimSpace(r.URL.Query().Get("precio_max"))
+	ratingMinStr := strings.TrimSpace(r.URL.Query().Get("rating_min"))
 
 	sortBy := strings.TrimSpace(r.URL.Query().Get("sort_by"))
 	if sortBy == "" {
@@ -51,6 +52,7 @@ func (h *AgenciaHandler) GetPaquetesPublicos(w http.ResponseWriter, r *http.Requ
 		"created_at": "paquetes_turisticos.created_at",
 		"precio":     "paquetes_turisticos.precio_base_nacionales",
 		"nombre":     "paquetes_turisticos.nombre",
+		"rating":     "paquetes_turisticos.rating_promedio",
 	}
 	orderCol, ok := allowedSort[sortBy]
 	if !ok {
@@ -121,6 +123,15 @@ func (h *AgenciaHandler) GetPaquetesPublicos(w http.ResponseWriter, r *http.Requ
 		query = query.Where("paquetes_turisticos.precio_base_nacionales <= ?", max)
 	}
 
+	if ratingMinStr != "" {
+		ratingMin, err := strconv.ParseFloat(ratingMinStr, 64)
+		if err != nil || ratingMin < 0 || ratingMin > 5 {
+			utils.ErrorResponse(w, "VALIDATION_ERROR", "rating_min inválido (0 a 5)", nil, http.StatusBadRequest)
+			return
+		}
+		query = query.Where("paquetes_turisticos.rating_promedio >= ?", ratingMin)
+	}
+
 	var total int64
 	query.Count(&total)
 
@@ -8,6 +8,7 @@ import (
 
 	"andaria-backend/internal/database"
 	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
 	"andaria-backend/pkg/utils"
 
 	"github.com/gorilla/mux"
@@ -23,6 +24,8 @@ type paqueteSalidaPublica struct {
 	CuposReservados  int    `json:"cupos_reservados"`
 	CuposConfirmados int    `json:"cupos_confirmados"`
 	CuposDisponibles int    `json:"cupos_disponibles"`
+
+	Precio *models.PrecioSalida `json:"precio,omitempty"`
 }
 
 // GetPaqueteSalidasPublicas lista salidas habilitadas (pendiente/activa) de un paquete visible al público.
@@ -97,6 +100,11 @@ func (h *AgenciaHandler) GetPaqueteSalidasPublicas(w http.ResponseWriter, r *htt
 		return
 	}
 
+	if err := services.ResolverPreciosSalidas(db, salidas); err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener precios de salidas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	out := make([]paqueteSalidaPublica, 0, len(salidas))
 	for _, s := range salidas {
 		out = append(out, paqueteSalidaPublica{
@@ -109,6 +117,7 @@ func (h *AgenciaHandler) GetPaqueteSalidasPublicas(w http.ResponseWriter, r *htt
 			CuposReservados:  s.CuposReservados,
 			CuposConfirmados: s.CuposConfirmados,
 			CuposDisponibles: s.CuposDisponibles(),
+			Precio:           s.Precio,
 		})
 	}
 
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"andaria-backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// PasarelaMockHandler sirve el checkout de la pasarela simulada (solo fuera de producción).
+type PasarelaMockHandler struct {
+	mock *services.MockPaymentProvider
+}
+
+func NewPasarelaMockHandler(mock *services.MockPaymentProvider) *PasarelaMockHandler {
+	return &PasarelaMockHandler{mock: mock}
+}
+
+var checkoutMockTemplate = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head><meta charset="utf-8"><title>Pasarela simulada</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 40px auto;">
+<h2>Pasarela de pago simulada</h2>
+{{if .Error}}<p style="color: #b00020;">{{.Error}}</p>{{end}}
+{{with .Checkout}}
+<p>{{.Descripcion}}</p>
+<p><strong>Bs {{printf "%.2f" .Monto}}</strong></p>
+<p>Estado: {{.Estado}} &middot; vence {{.ExpiraEn.Format "02/01/2006 15:04"}}</p>
+{{if eq .Estado "pendiente"}}
+<form method="post">
+<button name="resultado" value="exito">Pagar</button>
+<button name="resultado" value="fallo">Simular rechazo</button>
+<button name="resultado" value="demorado">Pagar con webhook demorado</button>
+</form>
+{{end}}
+{{end}}
+{{if .Mensaje}}<p>{{.Mensaje}}</p>{{end}}
+</body>
+</html>`))
+
+type checkoutMockVista struct {
+	Checkout *services.CheckoutMock
+	Error    string
+	Mensaje  string
+}
+
+func renderCheckoutMock(w http.ResponseWriter, status int, vista checkoutMockVista) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = checkoutMockTemplate.Execute(w, vista)
+}
+
+// GetCheckout muestra el intento de pago con las opciones de resultado.
+func (h *PasarelaMockHandler) GetCheckout(w http.ResponseWriter, r *http.Request) {
+	checkout, err := h.mock.Checkout(mux.Vars(r)["referencia"])
+	if err != nil {
+		renderCheckoutMock(w, http.StatusNotFound, checkoutMockVista{Error: err.Error()})
+		return
+	}
+	renderCheckoutMock(w, http.StatusOK, checkoutMockVista{Checkout: checkout})
+}
+
+// PostCheckout aplica el resultado elegido y devuelve al turista al frontend.
+func (h *PasarelaMockHandler) PostCheckout(w http.ResponseWriter, r *http.Request) {
+	referencia := mux.Vars(r)["referencia"]
+	resultado := strings.TrimSpace(r.FormValue("resultado"))
+
+	urlRetorno, err := h.mock.Simular(referencia, resultado)
+	if err != nil {
+		checkout, _ := h.mock.Checkout(referencia)
+		renderCheckoutMock(w, http.StatusBadRequest, checkoutMockVista{Checkout: checkout, Error: err.Error()})
+		return
+	}
+
+	if urlRetorno != "" {
+		http.Redirect(w, r, urlRetorno+"&resultado="+resultado, http.StatusSeeOther)
+		return
+	}
+	checkout, _ := h.mock.Checkout(referencia)
+	renderCheckoutMock(w, http.StatusOK, checkoutMockVista{Checkout: checkout, Mensaje: "Resultado registrado: " + resultado + ". Puede cerrar esta ventana."})
+}
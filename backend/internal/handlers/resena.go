@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+// ResenaHandler maneja las reseñas verificadas de paquetes y agencias
+type ResenaHandler struct {
+	validate      *validator.Validate
+	resenaService *services.ResenaService
+}
+
+// NewResenaHandler crea un nuevo handler de reseñas
+func NewResenaHandler() *ResenaHandler {
+	return &ResenaHandler{
+		validate:      validator.New(),
+		resenaService: services.NewResenaService(database.GetDB()),
+	}
+}
+
+func parsePaginacionResenas(r *http.Request) (int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return page, limit
+}
+
+func paginacionResenas(page, limit int, total int64) *models.Pagination {
+	return &models.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      int(total),
+		TotalPages: int((total + int64(limit) - 1) / int64(limit)),
+	}
+}
+
+// CrearResena registra la reseña del turista para una compra completada
+// POST /api/v1/compras/{id}/resena
+func (h *ResenaHandler) CrearResena(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden dejar reseñas", nil, http.StatusForbidden)
+		return
+	}
+
+	compraID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.CrearResenaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resena, err := h.resenaService.CrearResena(uint(compraID), claims.UserID, &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resena, "Reseña registrada exitosamente", http.StatusCreated)
+}
+
+// SubirFotoResena adjunta una foto a la reseña del turista
+// POST /api/v1/resenas/{id}/fotos
+func (h *ResenaHandler) SubirFotoResena(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	resenaID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.ErrorResponse(w, "PARSE_ERROR", "Error al procesar el formulario", err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("foto")
+	if err != nil {
+		utils.ErrorResponse(w, "NO_FILE", "No se proporcionó ningún archivo", err.Error(), http.StatusBadRequest)
+		return
+	}
+	file.Close()
+
+	foto, err := h.resenaService.AgregarFotoResena(uint(resenaID), claims.UserID, header)
+	if err != nil {
+		if errors.Is(err, services.ErrResenaNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, foto, "Foto subida exitosamente", http.StatusCreated)
+}
+
+// GetResenasPaquetePublico lista las reseñas visibles de un paquete
+// GET /api/v1/public/paquetes/{id}/resenas?page=1&limit=10
+func (h *ResenaHandler) GetResenasPaquetePublico(w http.ResponseWriter, r *http.Request) {
+	paqueteID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+	h.listarResenasPublicas(w, r, uint(paqueteID), 0)
+}
+
+// GetResenasAgenciaPublico lista las reseñas visibles de una agencia
+// GET /api/v1/public/agencias/{id}/resenas?page=1&limit=10
+func (h *ResenaHandler) GetResenasAgenciaPublico(w http.ResponseWriter, r *http.Request) {
+	agenciaID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+	h.listarResenasPublicas(w, r, 0, uint(agenciaID))
+}
+
+func (h *ResenaHandler) listarResenasPublicas(w http.ResponseWriter, r *http.Request, paqueteID uint, agenciaID uint) {
+	page, limit := parsePaginacionResenas(r)
+
+	resenas, resumen, total, err := h.resenaService.ListarResenasPublicas(paqueteID, agenciaID, page, limit)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener reseñas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"resenas":    resenas,
+		"resumen":    resumen,
+		"pagination": paginacionResenas(page, limit, total),
+	}, "Reseñas obtenidas exitosamente", http.StatusOK)
+}
+
+// GetAgenciaResenas lista todas las reseñas de la agencia, incluidas las ocultas
+// GET /api/v1/agencias/{id}/resenas?page=1&limit=10
+func (h *ResenaHandler) GetAgenciaResenas(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	page, limit := parsePaginacionResenas(r)
+
+	resenas, total, err := h.resenaService.ListarResenasAgencia(agencia.ID, page, limit)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener reseñas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"resenas":         resenas,
+		"rating_promedio": agencia.RatingPromedio,
+		"total_resenas":   agencia.TotalResenas,
+		"pagination":      paginacionResenas(page, limit, total),
+	}, "Reseñas obtenidas exitosamente", http.StatusOK)
+}
+
+// ResponderAgenciaResena registra la respuesta pública de la agencia a una reseña
+// POST /api/v1/agencias/{id}/resenas/{resena_id}/respuesta
+func (h *ResenaHandler) ResponderAgenciaResena(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	resenaID, err := strconv.ParseUint(mux.Vars(r)["resena_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de reseña inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ResponderResenaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resena, err := h.resenaService.ResponderResena(agencia.ID, uint(resenaID), claims.UserID, req.Respuesta)
+	if err != nil {
+		if errors.Is(err, services.ErrResenaNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resena, "Respuesta registrada exitosamente", http.StatusOK)
+}
+
+// ModerarResena oculta o vuelve a publicar una reseña (solo admin)
+// PUT /api/v1/admin/resenas/{id}/moderar
+func (h *ResenaHandler) ModerarResena(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	resenaID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ModerarResenaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resena, err := h.resenaService.ModerarResena(uint(resenaID), claims.UserID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrResenaNoEncontrada) {
+			utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		utils.ErrorResponse(w, "DB_ERROR", "Error al moderar la reseña", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, resena, "Reseña moderada exitosamente", http.StatusOK)
+}
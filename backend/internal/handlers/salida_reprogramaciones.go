@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// ReprogramarSalida cambia la fecha de una salida; las compras pasan a la nueva fecha a la espera de que
+// cada turista la acepte.
+func (h *SalidaHandler) ReprogramarSalida(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	agenciaID, ok := getAgenciaIDForEncargado(w, claims)
+	if !ok {
+		return
+	}
+
+	salidaID, err := strconv.ParseUint(mux.Vars(r)["salida_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de salida invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReprogramarSalidaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "Datos invalidos", err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Razon = strings.TrimSpace(req.Razon)
+	if strings.TrimSpace(req.NuevaFecha) == "" || req.Razon == "" {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Debe indicar nueva_fecha y razon", nil, http.StatusBadRequest)
+		return
+	}
+	if len(req.Razon) > 500 {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "La razon no puede superar 500 caracteres", nil, http.StatusBadRequest)
+		return
+	}
+	if req.DiasRespuesta < 0 || req.DiasRespuesta > 30 {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "dias_respuesta debe estar entre 1 y 30", nil, http.StatusBadRequest)
+		return
+	}
+	req.HoraEncuentro = optionalTrimmed(req.HoraEncuentro)
+
+	resultado, err := h.salidaService.ReprogramarSalida(agenciaID, claims.UserID, uint(salidaID), &req)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	utils.SuccessResponse(w, resultado, "Salida reprogramada exitosamente", http.StatusOK)
+}
+
+// ObtenerReprogramacionesSalida lista los cambios de fecha de una salida y la respuesta de cada compra.
+func (h *SalidaHandler) ObtenerReprogramacionesSalida(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	agenciaID, ok := getAgenciaIDForEncargado(w, claims)
+	if !ok {
+		return
+	}
+
+	salidaID, err := strconv.ParseUint(mux.Vars(r)["salida_id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de salida invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	reprogramaciones, err := h.salidaService.ObtenerReprogramacionesSalida(agenciaID, uint(salidaID))
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener reprogramaciones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, reprogramaciones, "Reprogramaciones obtenidas", http.StatusOK)
+}
+
+// ListarMisReprogramaciones lista los cambios de fecha que afectan a las compras del turista.
+func (h *SalidaHandler) ListarMisReprogramaciones(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	reprogramaciones, err := h.salidaService.ListarReprogramacionesTurista(claims.UserID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener reprogramaciones", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, reprogramaciones, "Reprogramaciones obtenidas", http.StatusOK)
+}
+
+// ResponderReprogramacion acepta o rechaza la nueva fecha. Rechazar cancela la compra con reembolso total.
+func (h *SalidaHandler) ResponderReprogramacion(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+
+	respuestaID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de reprogramacion invalido", nil, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ResponderReprogramacionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "Datos invalidos", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respuesta, err := h.salidaService.ResponderReprogramacion(claims.UserID, uint(respuestaID), req.Acepta)
+	if err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+		return
+	}
+
+	mensaje := "Nueva fecha aceptada"
+	if !req.Acepta {
+		mensaje = "Compra cancelada; la agencia te devolverá lo pagado"
+	}
+	utils.SuccessResponse(w, respuesta, mensaje, http.StatusOK)
+}
@@ -169,12 +169,13 @@ func (h *SalidaHandler) CancelarSalida(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.salidaService.CancelarSalida(agenciaID, uint(salidaID), body.Razon); err != nil {
+	resumen, err := h.salidaService.CancelarSalida(agenciaID, uint(salidaID), body.Razon)
+	if err != nil {
 		utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
 		return
 	}
 
-	utils.SuccessResponse(w, nil, "Salida cancelada exitosamente", http.StatusOK)
+	utils.SuccessResponse(w, resumen, "Salida cancelada exitosamente", http.StatusOK)
 }
 
 // ObtenerSalidasPublicas obtiene salidas disponibles para turistas (públicas)
@@ -205,5 +206,10 @@ func (h *SalidaHandler) ObtenerSalidasPublicas(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if err := services.ResolverPreciosSalidas(database.GetDB(), salidas); err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener precios de salidas", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	utils.SuccessResponse(w, salidas, "Salidas disponibles", http.StatusOK)
 }
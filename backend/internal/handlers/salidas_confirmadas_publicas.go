@@ -137,7 +137,7 @@ func (h *AgenciaHandler) GetSalidasConfirmadasPublicas(w http.ResponseWriter, r
 			s.cupo_maximo,
 			s.cupos_reservados,
 			s.cupos_confirmados,
-			(s.cupo_maximo - s.cupos_reservados - s.cupos_confirmados) AS cupos_disponibles
+			(s.cupo_maximo - s.cupos_reservados - s.cupos_confirmados - s.cupos_ofertados) AS cupos_disponibles
 		`).
 		Order("s.fecha_salida ASC").
 		Order("p.nombre ASC").
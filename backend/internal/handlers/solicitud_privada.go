@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+	"andaria-backend/internal/services"
+	"andaria-backend/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+var allowedSolicitudPrivadaEstado = map[string]bool{
+	"pendiente": true,
+	"cotizada":  true,
+	"aceptada":  true,
+	"rechazada": true,
+	"cancelada": true,
+	"expirada":  true,
+}
+
+func parseSolicitudIDParam(w http.ResponseWriter, r *http.Request, param string) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)[param], 10, 32)
+	if err != nil {
+		utils.ErrorResponse(w, "INVALID_ID", "ID de solicitud inválido", nil, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func solicitudPrivadaErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrSolicitudPrivadaNoEncontrada) {
+		utils.ErrorResponse(w, "NOT_FOUND", err.Error(), nil, http.StatusNotFound)
+		return
+	}
+	utils.ErrorResponse(w, "VALIDATION_ERROR", err.Error(), nil, http.StatusBadRequest)
+}
+
+func getTuristaClaimsOrForbidden(w http.ResponseWriter, r *http.Request) (*utils.JWTClaims, bool) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return nil, false
+	}
+	if claims.Rol != "turista" {
+		utils.ErrorResponse(w, "FORBIDDEN", "Solo turistas pueden gestionar solicitudes privadas", nil, http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
+
+// CrearSolicitudPrivada envía a la agencia un pedido de cotización de tour privado.
+func (h *CompraHandler) CrearSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getTuristaClaimsOrForbidden(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CrearSolicitudPrivadaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	solicitud, err := h.solicitudService.CrearSolicitud(claims.UserID, &req)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Solicitud enviada a la agencia", http.StatusCreated)
+}
+
+// ListarMisSolicitudesPrivadas lista las solicitudes privadas del turista.
+func (h *CompraHandler) ListarMisSolicitudesPrivadas(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getTuristaClaimsOrForbidden(w, r)
+	if !ok {
+		return
+	}
+
+	solicitudes, err := h.solicitudService.ListarTurista(claims.UserID)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener solicitudes", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"solicitudes": solicitudes,
+	}, "Solicitudes obtenidas exitosamente", http.StatusOK)
+}
+
+// ObtenerSolicitudPrivada retorna una solicitud del turista con el hilo de negociación.
+func (h *CompraHandler) ObtenerSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getTuristaClaimsOrForbidden(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	solicitud, err := h.solicitudService.ObtenerTurista(solicitudID, claims.UserID)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Solicitud obtenida exitosamente", http.StatusOK)
+}
+
+// EnviarMensajeSolicitudPrivada agrega un mensaje del turista al hilo.
+func (h *CompraHandler) EnviarMensajeSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getTuristaClaimsOrForbidden(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req models.MensajeSolicitudPrivadaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	solicitud, err := h.solicitudService.AgregarMensaje(solicitudID, claims.UserID, "turista", 0, req.Mensaje)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Mensaje enviado", http.StatusOK)
+}
+
+// AceptarSolicitudPrivada acepta la cotización vigente y crea la compra privada.
+func (h *CompraHandler) AceptarSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getTuristaClaimsOrForbidden(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req models.AceptarSolicitudPrivadaRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.solicitudService.Aceptar(solicitudID, claims.UserID, &req)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"compra_id":          result.CompraID,
+		"salida_id":          result.SalidaID,
+		"precio_total":       result.PrecioTotal,
+		"monto_anticipo":     result.MontoAnticipo,
+		"fecha_limite_saldo": result.FechaLimiteSaldo,
+	}, result.Mensaje, http.StatusCreated)
+}
+
+// CancelarSolicitudPrivada cancela una solicitud abierta del turista.
+func (h *CompraHandler) CancelarSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getTuristaClaimsOrForbidden(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req models.CerrarSolicitudPrivadaRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.ErrorResponse(w, "INVALID_JSON", "JSON inválido", nil, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	solicitud, err := h.solicitudService.Cerrar(solicitudID, claims.UserID, "turista", 0, req.Motivo)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Solicitud cancelada", http.StatusOK)
+}
+
+// GetAgenciaSolicitudesPrivadas lista las solicitudes privadas recibidas por la agencia.
+func (h *AgenciaHandler) GetAgenciaSolicitudesPrivadas(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+
+	estado := strings.TrimSpace(r.URL.Query().Get("estado"))
+	if estado != "" && !allowedSolicitudPrivadaEstado[estado] {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Estado inválido", nil, http.StatusBadRequest)
+		return
+	}
+
+	solicitudes, err := services.NewSolicitudPrivadaService(database.GetDB()).ListarAgencia(agencia.ID, estado)
+	if err != nil {
+		utils.ErrorResponse(w, "DB_ERROR", "Error al obtener solicitudes", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(w, map[string]interface{}{
+		"solicitudes": solicitudes,
+	}, "Solicitudes obtenidas exitosamente", http.StatusOK)
+}
+
+// GetAgenciaSolicitudPrivada retorna una solicitud de la agencia con el hilo de negociación.
+func (h *AgenciaHandler) GetAgenciaSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "solicitud_id")
+	if !ok {
+		return
+	}
+
+	solicitud, err := services.NewSolicitudPrivadaService(database.GetDB()).ObtenerAgencia(solicitudID, agencia.ID)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Solicitud obtenida exitosamente", http.StatusOK)
+}
+
+// CotizarAgenciaSolicitudPrivada envía (o reemplaza) la cotización de la agencia.
+func (h *AgenciaHandler) CotizarAgenciaSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "solicitud_id")
+	if !ok {
+		return
+	}
+
+	var req models.CotizarSolicitudPrivadaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	solicitud, err := services.NewSolicitudPrivadaService(database.GetDB()).Cotizar(agencia.ID, solicitudID, claims.UserID, &req)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Cotización enviada al turista", http.StatusOK)
+}
+
+// EnviarMensajeAgenciaSolicitudPrivada agrega un mensaje de la agencia al hilo.
+func (h *AgenciaHandler) EnviarMensajeAgenciaSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "solicitud_id")
+	if !ok {
+		return
+	}
+
+	var req models.MensajeSolicitudPrivadaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	solicitud, err := services.NewSolicitudPrivadaService(database.GetDB()).AgregarMensaje(solicitudID, claims.UserID, "agencia", agencia.ID, req.Mensaje)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Mensaje enviado", http.StatusOK)
+}
+
+// RechazarAgenciaSolicitudPrivada rechaza una solicitud abierta.
+func (h *AgenciaHandler) RechazarAgenciaSolicitudPrivada(w http.ResponseWriter, r *http.Request) {
+	claims, ok := getClaimsOrUnauthorized(w, r)
+	if !ok {
+		return
+	}
+	agencia, ok := loadAgenciaForManage(w, r)
+	if !ok {
+		return
+	}
+	solicitudID, ok := parseSolicitudIDParam(w, r, "solicitud_id")
+	if !ok {
+		return
+	}
+
+	var req models.CerrarSolicitudPrivadaRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.ErrorResponse(w, "INVALID_JSON", "JSON invalido", nil, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		utils.ErrorResponse(w, "VALIDATION_ERROR", "Error de validación", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	solicitud, err := services.NewSolicitudPrivadaService(database.GetDB()).Cerrar(solicitudID, claims.UserID, "agencia", agencia.ID, req.Motivo)
+	if err != nil {
+		solicitudPrivadaErrorResponse(w, err)
+		return
+	}
+
+	utils.SuccessResponse(w, solicitud, "Solicitud rechazada", http.StatusOK)
+}
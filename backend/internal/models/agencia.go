@@ -28,6 +28,8 @@ type AgenciaTurismo struct {
 	EncargadoPrincipal   *Usuario              `gorm:"foreignKey:EncargadoPrincipalID" json:"encargado_principal,omitempty"`
 	Status               string                `gorm:"size:20;default:'activa';index" json:"status"`
 	VisiblePublico       bool                  `gorm:"default:true;index" json:"visible_publico"`
+	RatingPromedio       float64               `gorm:"type:decimal(3,2);default:0;index;<-:false" json:"rating_promedio"` // desnormalizado desde resenas
+	TotalResenas         int                   `gorm:"default:0;<-:false" json:"total_resenas"`
 	Fotos                []AgenciaFoto         `gorm:"foreignKey:AgenciaID" json:"fotos,omitempty"`
 	Especialidades       []AgenciaEspecialidad `gorm:"foreignKey:AgenciaID" json:"especialidades,omitempty"`
 	// Many-to-many con tabla agencia_dias (columnas: agencia_id, dia_id)
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// AsistenciaParticipanteRequest marca a un participante registrado de la compra.
+type AsistenciaParticipanteRequest struct {
+	ParticipanteID uint `json:"participante_id" validate:"required"`
+	Presente       bool `json:"presente"`
+}
+
+// CheckInRequest registra la llegada de una compra a la salida.
+// La compra se identifica con el token del QR del voucher o con el código de confirmación.
+// Si la compra tiene participantes registrados y no se envía la lista, todos quedan presentes;
+// si no los tiene, Presentes indica cuántos llegaron (por defecto, todos).
+type CheckInRequest struct {
+	Token              *string                         `json:"token"`
+	CodigoConfirmacion *string                         `json:"codigo_confirmacion"`
+	Participantes      []AsistenciaParticipanteRequest `json:"participantes" validate:"omitempty,dive"`
+	Presentes          *int                            `json:"presentes" validate:"omitempty,min=0"`
+}
+
+// AsistenciaCompraResponse es el estado de asistencia de una compra confirmada de la salida.
+type AsistenciaCompraResponse struct {
+	CompraID               uint                 `json:"compra_id"`
+	CodigoConfirmacion     *string              `json:"codigo_confirmacion,omitempty"`
+	TuristaNombre          string               `json:"turista_nombre"`
+	TotalParticipantes     int                  `json:"total_participantes"`
+	ParticipantesPresentes *int                 `json:"participantes_presentes,omitempty"`
+	NoShow                 bool                 `json:"no_show"`
+	CheckInAt              *time.Time           `json:"check_in_at,omitempty"`
+	Participantes          []CompraParticipante `json:"participantes"`
+}
+
+// AsistenciaSalidaResponse resume la asistencia de una salida.
+type AsistenciaSalidaResponse struct {
+	SalidaID        uint       `json:"salida_id"`
+	Estado          string     `json:"estado"`
+	FechaInicioReal *time.Time `json:"fecha_inicio_real,omitempty"`
+	FechaFinReal    *time.Time `json:"fecha_fin_real,omitempty"`
+
+	Esperados  int `json:"esperados"`
+	Presentes  int `json:"presentes"`
+	Pendientes int `json:"pendientes"` // participantes de compras sin check-in
+	NoShows    int `json:"no_shows"`   // compras sin ningún participante presente
+
+	Compras []AsistenciaCompraResponse `json:"compras"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// CalendarioFeed es el token secreto de un feed iCalendar de suscripción.
+// Tipo agencia: todas las salidas de la agencia. Tipo turista: las compras confirmadas del usuario.
+// Tabla: calendario_feeds
+type CalendarioFeed struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	// agencia | turista
+	Tipo      string `gorm:"size:20;not null" json:"tipo"`
+	AgenciaID *uint  `gorm:"uniqueIndex" json:"agencia_id,omitempty"`
+	UsuarioID *uint  `gorm:"uniqueIndex" json:"usuario_id,omitempty"`
+
+	Token string `gorm:"size:64;not null;uniqueIndex" json:"-"`
+
+	UltimoAcceso *time.Time `json:"ultimo_acceso,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (CalendarioFeed) TableName() string {
+	return "calendario_feeds"
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// CoincidenciaComprobante relaciona un pago con un pago anterior de otra compra (de cualquier agencia) cuyo
+// comprobante es el mismo archivo o una imagen casi idéntica.
+// Tabla: coincidencias_comprobante
+type CoincidenciaComprobante struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	PagoID       uint        `gorm:"not null;uniqueIndex:uniq_coincidencia_comprobante" json:"pago_id"`
+	Pago         *PagoCompra `gorm:"foreignKey:PagoID" json:"pago,omitempty"`
+	PagoPrevioID uint        `gorm:"not null;uniqueIndex:uniq_coincidencia_comprobante;index" json:"pago_previo_id"`
+	PagoPrevio   *PagoCompra `gorm:"foreignKey:PagoPrevioID" json:"pago_previo,omitempty"`
+
+	// exacta (mismo archivo) | similar (hash perceptual cercano)
+	Tipo string `gorm:"size:10;not null" json:"tipo"`
+	// Bits distintos entre los hashes perceptuales (0 en una coincidencia exacta)
+	Distancia    int  `gorm:"not null;default:0" json:"distancia"`
+	MismaAgencia bool `gorm:"not null" json:"misma_agencia"`
+	MismoTurista bool `gorm:"not null" json:"mismo_turista"`
+
+	// pendiente | reportada | legitima | fraude
+	Estado string `gorm:"size:20;not null;default:'pendiente';index" json:"estado"`
+
+	// Reporte del encargado al admin
+	ReportadaPor  *uint      `json:"reportada_por,omitempty"`
+	FechaReporte  *time.Time `json:"fecha_reporte,omitempty"`
+	MotivoReporte *string    `gorm:"type:text" json:"motivo_reporte,omitempty"`
+
+	// Resolución del admin
+	RevisadoPor   *uint      `json:"revisado_por,omitempty"`
+	FechaRevision *time.Time `json:"fecha_revision,omitempty"`
+	NotasRevision *string    `gorm:"type:text" json:"notas_revision,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (CoincidenciaComprobante) TableName() string {
+	return "coincidencias_comprobante"
+}
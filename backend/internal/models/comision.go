@@ -0,0 +1,129 @@
+package models
+
+import "time"
+
+// TarifaComision es el porcentaje que cobra la plataforma sobre las ventas confirmadas. Se define para una
+// agencia, para una categoría de paquete o para una categoría dentro de una agencia; la más específica
+// gana y, sin ninguna, rige el porcentaje por defecto de la plataforma.
+// Tabla: tarifas_comision
+type TarifaComision struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	AgenciaID   *uint               `gorm:"index" json:"agencia_id,omitempty"`
+	Agencia     *AgenciaTurismo     `gorm:"foreignKey:AgenciaID" json:"agencia,omitempty"`
+	CategoriaID *uint               `gorm:"index" json:"categoria_id,omitempty"`
+	Categoria   *CategoriaAtraccion `gorm:"foreignKey:CategoriaID" json:"categoria,omitempty"`
+
+	Porcentaje float64 `gorm:"type:decimal(5,2);not null" json:"porcentaje"`
+	Notas      *string `gorm:"type:text" json:"notas,omitempty"`
+	CreadoPor  uint    `gorm:"not null" json:"creado_por"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (TarifaComision) TableName() string {
+	return "tarifas_comision"
+}
+
+// AsientoComision es un asiento del libro de comisiones entre la plataforma y una agencia. Sus movimientos
+// siempre suman lo mismo al debe y al haber.
+// Tabla: asientos_comision
+type AsientoComision struct {
+	ID        uint `gorm:"primaryKey" json:"id"`
+	AgenciaID uint `gorm:"not null;index" json:"agencia_id"`
+
+	// venta | modificacion | reembolso | ajuste | pago
+	Tipo string `gorm:"size:20;not null;index" json:"tipo"`
+
+	CompraID    *uint `gorm:"index" json:"compra_id,omitempty"`
+	ReembolsoID *uint `gorm:"uniqueIndex" json:"reembolso_id,omitempty"`
+
+	// Fecha contable: confirmación de la venta, pago del reembolso o la indicada por el admin
+	Fecha       time.Time `gorm:"type:date;not null;index" json:"fecha"`
+	Descripcion string    `gorm:"size:255;not null" json:"descripcion"`
+
+	// Monto sobre el que se calculó la comisión (negativo si la reduce) y tarifa aplicada
+	MontoBase   float64  `gorm:"type:decimal(12,2);not null;default:0" json:"monto_base"`
+	Porcentaje  *float64 `gorm:"type:decimal(5,2)" json:"porcentaje,omitempty"`
+	TarifaID    *uint    `json:"tarifa_id,omitempty"`
+	CategoriaID *uint    `json:"categoria_id,omitempty"`
+
+	Referencia *string `gorm:"size:100" json:"referencia,omitempty"`
+	CreadoPor  *uint   `json:"creado_por,omitempty"`
+
+	// Liquidación que incluyó el asiento
+	LiquidacionID *uint `gorm:"index" json:"liquidacion_id,omitempty"`
+
+	Movimientos []MovimientoComision `gorm:"foreignKey:AsientoID" json:"movimientos,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AsientoComision) TableName() string {
+	return "asientos_comision"
+}
+
+// EfectoSaldo es cuánto cambia el asiento lo que la agencia adeuda (cuenta comision_por_cobrar).
+func (a *AsientoComision) EfectoSaldo() float64 {
+	var efecto float64
+	for _, m := range a.Movimientos {
+		if m.Cuenta == "comision_por_cobrar" {
+			efecto += m.Debe - m.Haber
+		}
+	}
+	return efecto
+}
+
+// MovimientoComision es una línea de un asiento: un monto al debe o al haber de una cuenta de la agencia.
+// Tabla: movimientos_comision
+type MovimientoComision struct {
+	ID        uint `gorm:"primaryKey" json:"id"`
+	AsientoID uint `gorm:"not null;index" json:"asiento_id"`
+	AgenciaID uint `gorm:"not null;index:idx_movimientos_comision_cuenta" json:"agencia_id"`
+
+	// cobros_agencia | ventas_brutas | comision_por_cobrar | ingresos_comision | ajustes_comision | banco_plataforma
+	Cuenta string  `gorm:"size:30;not null;index:idx_movimientos_comision_cuenta" json:"cuenta"`
+	Debe   float64 `gorm:"type:decimal(12,2);not null;default:0" json:"debe"`
+	Haber  float64 `gorm:"type:decimal(12,2);not null;default:0" json:"haber"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (MovimientoComision) TableName() string {
+	return "movimientos_comision"
+}
+
+// LiquidacionComision es el estado de cuenta de comisiones de una agencia: parte del saldo de la liquidación
+// anterior, suma los asientos no liquidados hasta PeriodoHasta y cierra con el saldo adeudado.
+// Tabla: liquidaciones_comision
+type LiquidacionComision struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	AgenciaID uint            `gorm:"not null;uniqueIndex:uniq_liquidacion_comision_periodo" json:"agencia_id"`
+	Agencia   *AgenciaTurismo `gorm:"foreignKey:AgenciaID" json:"agencia,omitempty"`
+
+	PeriodoDesde time.Time `gorm:"type:date;not null" json:"periodo_desde"`
+	PeriodoHasta time.Time `gorm:"type:date;not null;uniqueIndex:uniq_liquidacion_comision_periodo" json:"periodo_hasta"`
+
+	SaldoAnterior float64 `gorm:"type:decimal(12,2);not null;default:0" json:"saldo_anterior"`
+	VentasBrutas  float64 `gorm:"type:decimal(12,2);not null;default:0" json:"ventas_brutas"`
+	Reembolsos    float64 `gorm:"type:decimal(12,2);not null;default:0" json:"reembolsos"`
+	// Comisión neta de ventas, modificaciones y reembolsos del período
+	Comision float64 `gorm:"type:decimal(12,2);not null;default:0" json:"comision"`
+	Ajustes  float64 `gorm:"type:decimal(12,2);not null;default:0" json:"ajustes"`
+	Pagos    float64 `gorm:"type:decimal(12,2);not null;default:0" json:"pagos"`
+	// Saldo que la agencia adeuda al cierre (negativo = saldo a favor de la agencia)
+	SaldoFinal float64 `gorm:"type:decimal(12,2);not null;default:0" json:"saldo_final"`
+
+	TotalAsientos int `gorm:"not null;default:0" json:"total_asientos"`
+	// nil = emitida automáticamente al cierre del mes
+	EmitidaPor *uint `json:"emitida_por,omitempty"`
+
+	Asientos []AsientoComision `gorm:"foreignKey:LiquidacionID" json:"asientos,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (LiquidacionComision) TableName() string {
+	return "liquidaciones_comision"
+}
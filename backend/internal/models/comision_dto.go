@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// TarifaComisionRequest crea o modifica una tarifa de comisión; se indica la agencia, la categoría o ambas.
+type TarifaComisionRequest struct {
+	AgenciaID   *uint   `json:"agencia_id"`
+	CategoriaID *uint   `json:"categoria_id"`
+	Porcentaje  float64 `json:"porcentaje" validate:"gte=0,lte=100"`
+	Notas       *string `json:"notas" validate:"omitempty,max=500"`
+}
+
+// AjusteComisionRequest registra un ajuste manual: positivo aumenta lo que la agencia adeuda, negativo lo reduce.
+type AjusteComisionRequest struct {
+	AgenciaID   uint    `json:"agencia_id" validate:"required"`
+	Monto       float64 `json:"monto" validate:"required,ne=0"`
+	Descripcion string  `json:"descripcion" validate:"required,min=3,max=255"`
+	Fecha       *string `json:"fecha"` // YYYY-MM-DD; sin fecha = hoy
+}
+
+// PagoComisionRequest registra un pago de comisiones recibido de una agencia.
+type PagoComisionRequest struct {
+	AgenciaID  uint    `json:"agencia_id" validate:"required"`
+	Monto      float64 `json:"monto" validate:"required,gt=0"`
+	Referencia string  `json:"referencia" validate:"required,min=3,max=100"`
+	Fecha      *string `json:"fecha"` // YYYY-MM-DD; sin fecha = hoy
+}
+
+// EmitirLiquidacionesRequest emite las liquidaciones de un mes cerrado, de una agencia o de todas.
+type EmitirLiquidacionesRequest struct {
+	AgenciaID *uint `json:"agencia_id"`
+	Mes       int   `json:"mes" validate:"required,min=1,max=12"`
+	Anio      int   `json:"anio" validate:"required,min=2000,max=2100"`
+}
+
+// ResumenComisionAgencia resume las comisiones de una agencia en un rango y su saldo a la fecha.
+type ResumenComisionAgencia struct {
+	AgenciaID         uint       `json:"agencia_id" gorm:"column:agencia_id"`
+	AgenciaNombre     string     `json:"agencia_nombre" gorm:"column:agencia_nombre"`
+	VentasBrutas      float64    `json:"ventas_brutas" gorm:"column:ventas_brutas"`
+	Reembolsos        float64    `json:"reembolsos" gorm:"column:reembolsos"`
+	Comision          float64    `json:"comision" gorm:"column:comision"`
+	Ajustes           float64    `json:"ajustes" gorm:"column:ajustes"`
+	Pagos             float64    `json:"pagos" gorm:"column:pagos"`
+	SaldoAdeudado     float64    `json:"saldo_adeudado" gorm:"column:saldo_adeudado"`
+	UltimaLiquidacion *time.Time `json:"ultima_liquidacion,omitempty" gorm:"column:ultima_liquidacion"`
+}
+
+// CuentaComisionAgencia es la situación de la agencia frente a la plataforma.
+type CuentaComisionAgencia struct {
+	SaldoAdeudado     float64              `json:"saldo_adeudado"`
+	SinLiquidar       float64              `json:"sin_liquidar"`
+	TarifaPorDefecto  float64              `json:"tarifa_por_defecto"`
+	Tarifas           []TarifaComision     `json:"tarifas"`
+	UltimaLiquidacion *LiquidacionComision `json:"ultima_liquidacion,omitempty"`
+}
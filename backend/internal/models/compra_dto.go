@@ -18,6 +18,103 @@ type CrearCompraRequest struct {
 	TieneDiscapacidad       bool    `json:"tiene_discapacidad"`
 	DescripcionDiscapacidad *string `json:"descripcion_discapacidad"`
 	NotasTurista            *string `json:"notas_turista"`
+
+	// Código de promoción opcional
+	CodigoPromocion *string `json:"codigo_promocion" validate:"omitempty,max=50"`
+
+	// Participantes es opcional al comprar; puede completarse luego con PUT /compras/{id}/participantes.
+	Participantes []ParticipanteRequest `json:"participantes" validate:"omitempty,dive"`
+}
+
+// ModificarCompraRequest cambia fecha, tipo o cantidades de una compra existente.
+// Los campos omitidos conservan su valor actual.
+type ModificarCompraRequest struct {
+	FechaSeleccionada   *string `json:"fecha_seleccionada"` // YYYY-MM-DD
+	TipoCompra          *string `json:"tipo_compra" validate:"omitempty,oneof=compartido privado"`
+	CantidadAdultos     *int    `json:"cantidad_adultos" validate:"omitempty,min=1"`
+	CantidadNinosPagan  *int    `json:"cantidad_ninos_pagan" validate:"omitempty,min=0"`
+	CantidadNinosGratis *int    `json:"cantidad_ninos_gratis" validate:"omitempty,min=0"`
+	Motivo              *string `json:"motivo"`
+
+	// Si cambian las cantidades, el listado nominal se reemplaza por éste (o se borra si se omite).
+	Participantes []ParticipanteRequest `json:"participantes" validate:"omitempty,dive"`
+}
+
+// ModificarCompraResponse resume el resultado de una modificación.
+type ModificarCompraResponse struct {
+	Modificacion   CompraModificacion `json:"modificacion"`
+	PrecioTotal    float64            `json:"precio_total"`
+	MontoPagado    float64            `json:"monto_pagado"`
+	SaldoPendiente float64            `json:"saldo_pendiente"`
+	Reembolso      *Reembolso         `json:"reembolso,omitempty"`
+}
+
+// CotizarCompraRequest contiene los parámetros de una cotización pública (query string).
+type CotizarCompraRequest struct {
+	PaqueteID           uint
+	FechaSeleccionada   string
+	TipoCompra          string
+	Extranjero          bool
+	CantidadAdultos     int
+	CantidadNinosPagan  int
+	CantidadNinosGratis int
+	CodigoPromocion     *string
+}
+
+// CotizacionCompraResponse es el desglose de precio y la disponibilidad de una compra sin reservar cupos.
+// Se calcula con las mismas funciones SQL que procesar_compra_paquete.
+type CotizacionCompraResponse struct {
+	PaqueteID           uint   `json:"paquete_id"`
+	FechaSeleccionada   string `json:"fecha_seleccionada"`
+	TipoCompra          string `json:"tipo_compra"`
+	Extranjero          bool   `json:"extranjero"`
+	CantidadAdultos     int    `json:"cantidad_adultos"`
+	CantidadNinosPagan  int    `json:"cantidad_ninos_pagan"`
+	CantidadNinosGratis int    `json:"cantidad_ninos_gratis"`
+	TotalParticipantes  int    `json:"total_participantes"`
+	PersonasQuePagan    int    `json:"personas_que_pagan"`
+
+	PrecioUnitario           float64           `json:"precio_unitario"`
+	ReglaPrecio              string            `json:"regla_precio"`
+	TemporadaPrecioID        *uint             `json:"temporada_precio_id,omitempty"`
+	Subtotal                 float64           `json:"subtotal"`
+	RecargoPrivadoPorcentaje float64           `json:"recargo_privado_porcentaje"`
+	RecargoExtranjero        float64           `json:"recargo_extranjero"`
+	TotalRecargo             float64           `json:"total_recargo"`
+	PrecioSinDescuento       *float64          `json:"precio_sin_descuento,omitempty"`
+	DescuentoAplicado        float64           `json:"descuento_aplicado"`
+	Descuentos               []CompraDescuento `json:"descuentos"`
+	CodigoPromocion          *string           `json:"codigo_promocion,omitempty"`
+	PrecioTotal              float64           `json:"precio_total"`
+
+	MontoAnticipo    *float64   `json:"monto_anticipo,omitempty"`
+	FechaLimiteSaldo *time.Time `json:"fecha_limite_saldo,omitempty"`
+
+	Disponible            bool    `json:"disponible"`
+	MensajeDisponibilidad *string `json:"mensaje_disponibilidad,omitempty"`
+	SalidaID              *uint   `json:"salida_id,omitempty"`
+	NuevaSalida           bool    `json:"nueva_salida"`
+	CuposDisponibles      int     `json:"cupos_disponibles"`
+}
+
+// ParticipanteRequest representa los datos de un participante enviados por el turista.
+type ParticipanteRequest struct {
+	Tipo            string `json:"tipo" validate:"required,oneof=adulto nino_paga nino_gratis"`
+	NombreCompleto  string `json:"nombre_completo" validate:"required,max=255"`
+	TipoDocumento   string `json:"tipo_documento" validate:"required,oneof=ci pasaporte"`
+	NumeroDocumento string `json:"numero_documento" validate:"required,max=50"`
+	Nacionalidad    string `json:"nacionalidad" validate:"required,max=100"`
+	FechaNacimiento string `json:"fecha_nacimiento" validate:"required"` // YYYY-MM-DD
+
+	ContactoEmergenciaNombre   string `json:"contacto_emergencia_nombre" validate:"required,max=255"`
+	ContactoEmergenciaTelefono string `json:"contacto_emergencia_telefono" validate:"required,max=20"`
+
+	Notas *string `json:"notas"`
+}
+
+// ActualizarParticipantesRequest reemplaza el listado de participantes de una compra.
+type ActualizarParticipantesRequest struct {
+	Participantes []ParticipanteRequest `json:"participantes" validate:"required,min=1,dive"`
 }
 
 // ProcesarCompraPaqueteResult representa el resultado retornado por la función SQL procesar_compra_paquete().
@@ -27,14 +124,31 @@ type ProcesarCompraPaqueteResult struct {
 	PrecioTotal float64 `json:"precio_total" gorm:"column:precio_total"`
 	Mensaje     string  `json:"mensaje" gorm:"column:mensaje"`
 	Success     bool    `json:"success" gorm:"column:success"`
+
+	// Completados en Go cuando se aplica un código de promoción.
+	PrecioSinDescuento *float64 `json:"precio_sin_descuento,omitempty" gorm:"-"`
+	DescuentoAplicado  float64  `json:"descuento_aplicado" gorm:"-"`
+
+	// Completados en Go cuando la política de la agencia permite reservar con anticipo.
+	MontoAnticipo    *float64   `json:"monto_anticipo,omitempty" gorm:"-"`
+	FechaLimiteSaldo *time.Time `json:"fecha_limite_saldo,omitempty" gorm:"-"`
 }
 
 // ParticipanteDetalle representa un participante individual (opcionalmente detallado).
 type ParticipanteDetalle struct {
+	ID     *uint   `json:"id,omitempty"`
 	Nombre *string `json:"nombre,omitempty"`
 	Edad   *int    `json:"edad,omitempty"`
 	Tipo   string  `json:"tipo"`
 	Notas  *string `json:"notas,omitempty"`
+
+	TipoDocumento   *string `json:"tipo_documento,omitempty"`
+	NumeroDocumento *string `json:"numero_documento,omitempty"`
+	Nacionalidad    *string `json:"nacionalidad,omitempty"`
+	FechaNacimiento *string `json:"fecha_nacimiento,omitempty"`
+
+	ContactoEmergenciaNombre   *string `json:"contacto_emergencia_nombre,omitempty"`
+	ContactoEmergenciaTelefono *string `json:"contacto_emergencia_telefono,omitempty"`
 }
 
 // ParticipantesDetalle agrupa participantes por tipo.
@@ -86,6 +200,8 @@ type PaqueteDetalleResponse struct {
 type CompraDetalleResponse struct {
 	ID                     uint                 `json:"id"`
 	CodigoConfirmacion     *string              `json:"codigo_confirmacion,omitempty"`
+	// Referencia que el turista indica en la glosa de su transferencia o pago QR
+	ReferenciaPago         string               `json:"referencia_pago"`
 	FechaCompra            time.Time            `json:"fecha_compra"`
 	FechaSeleccionada      time.Time            `json:"fecha_seleccionada"`
 	FechaConfirmacion      *time.Time           `json:"fecha_confirmacion,omitempty"`
@@ -97,6 +213,13 @@ type CompraDetalleResponse struct {
 	Participantes          *ParticipantesDetalle `json:"participantes,omitempty"`
 	TotalParticipantes     int                  `json:"total_participantes"`
 	PrecioTotal            float64              `json:"precio_total"`
+	PrecioSinDescuento     *float64             `json:"precio_sin_descuento,omitempty"`
+	DescuentoAplicado      float64              `json:"descuento_aplicado"`
+	Descuentos             []CompraDescuento    `json:"descuentos,omitempty"`
+	MontoPagado            float64              `json:"monto_pagado"`
+	SaldoPendiente         float64              `json:"saldo_pendiente"`
+	MontoAnticipo          *float64             `json:"monto_anticipo,omitempty"`
+	FechaLimiteSaldo       *time.Time           `json:"fecha_limite_saldo,omitempty"`
 	Status                 string               `json:"status"`
 	TieneDiscapacidad      bool                 `json:"tiene_discapacidad"`
 	DescripcionDiscapacidad *string             `json:"descripcion_discapacidad,omitempty"`
@@ -104,6 +227,9 @@ type CompraDetalleResponse struct {
 	Paquete                PaqueteDetalleResponse `json:"paquete"`
 	Salida                 *SalidaSimpleResponse `json:"salida"`
 	UltimoPago             *PagoSimpleResponse   `json:"ultimo_pago"`
+	Pagos                  []PagoSimpleResponse  `json:"pagos,omitempty"`
+	Reembolsos             []Reembolso           `json:"reembolsos,omitempty"`
+	Modificaciones         []CompraModificacion  `json:"modificaciones,omitempty"`
 }
 
 type SalidaSimpleResponse struct {
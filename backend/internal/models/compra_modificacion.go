@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// CompraModificacion registra un cambio de fecha, tipo o participantes de una compra.
+// Tabla: compras_modificaciones
+type CompraModificacion struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	CompraID     uint `gorm:"not null;index" json:"compra_id"`
+	RealizadoPor uint `gorm:"not null;index" json:"realizado_por"`
+
+	SalidaAnteriorID *uint     `json:"salida_anterior_id,omitempty"`
+	SalidaNuevaID    *uint     `json:"salida_nueva_id,omitempty"`
+	FechaAnterior    time.Time `gorm:"type:date;not null" json:"fecha_anterior"`
+	FechaNueva       time.Time `gorm:"type:date;not null" json:"fecha_nueva"`
+
+	TipoCompraAnterior string `gorm:"size:20;not null" json:"tipo_compra_anterior"`
+	TipoCompraNuevo    string `gorm:"size:20;not null" json:"tipo_compra_nuevo"`
+
+	AdultosAnterior     int `json:"adultos_anterior"`
+	NinosPaganAnterior  int `json:"ninos_pagan_anterior"`
+	NinosGratisAnterior int `json:"ninos_gratis_anterior"`
+	AdultosNuevo        int `json:"adultos_nuevo"`
+	NinosPaganNuevo     int `json:"ninos_pagan_nuevo"`
+	NinosGratisNuevo    int `json:"ninos_gratis_nuevo"`
+
+	PrecioAnterior float64 `gorm:"type:decimal(10,2);not null" json:"precio_anterior"`
+	PrecioNuevo    float64 `gorm:"type:decimal(10,2);not null" json:"precio_nuevo"`
+	// Positiva: monto a pagar por el turista. Negativa: monto a reembolsar.
+	Diferencia float64 `gorm:"type:decimal(10,2);not null" json:"diferencia"`
+
+	StatusAnterior string `gorm:"size:30;not null" json:"status_anterior"`
+	StatusNuevo    string `gorm:"size:30;not null" json:"status_nuevo"`
+
+	// Reembolso generado si lo ya pagado supera el nuevo precio
+	ReembolsoID *uint   `json:"reembolso_id,omitempty"`
+	Motivo      *string `gorm:"type:text" json:"motivo,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (CompraModificacion) TableName() string {
+	return "compras_modificaciones"
+}
@@ -19,7 +19,8 @@ type CompraPaquete struct {
 	SalidaID *uint                    `gorm:"index" json:"salida_id,omitempty"`
 	Salida   *PaqueteSalidaHabilitada `gorm:"foreignKey:SalidaID" json:"salida,omitempty"`
 
-	PromocionID *uint `gorm:"index" json:"promocion_id,omitempty"`
+	PromocionID *uint      `gorm:"index" json:"promocion_id,omitempty"`
+	Promocion   *Promocion `gorm:"foreignKey:PromocionID" json:"promocion,omitempty"`
 
 	// Información de la compra
 	FechaCompra         time.Time `json:"fecha_compra"`
@@ -43,11 +44,21 @@ type CompraPaquete struct {
 	TotalRecargo             float64 `gorm:"type:decimal(10,2);default:0" json:"total_recargo"`
 	PrecioTotal              float64 `gorm:"type:decimal(10,2);not null" json:"precio_total"`
 
-	// Campos de promoción (para futuro)
+	// Regla que definió PrecioUnitario: base | temporada | salida
+	ReglaPrecio       string `gorm:"size:20;default:'base'" json:"regla_precio"`
+	TemporadaPrecioID *uint  `gorm:"index" json:"temporada_precio_id,omitempty"`
+
+	// Campos de promoción (se completan al aplicar un código de descuento)
 	PrecioSinDescuento          *float64 `gorm:"type:decimal(10,2)" json:"precio_sin_descuento,omitempty"`
 	DescuentoAplicado           float64  `gorm:"type:decimal(10,2);default:0" json:"descuento_aplicado"`
 	PorcentajeDescuentoAplicado float64  `gorm:"type:decimal(5,2);default:0" json:"porcentaje_descuento_aplicado"`
 
+	// Pagos parciales (anticipo + cuotas)
+	MontoPagado             float64    `gorm:"type:decimal(10,2);default:0" json:"monto_pagado"`
+	MontoAnticipo           *float64   `gorm:"type:decimal(10,2)" json:"monto_anticipo,omitempty"`
+	FechaLimiteSaldo        *time.Time `gorm:"type:date" json:"fecha_limite_saldo,omitempty"`
+	UltimoRecordatorioSaldo *time.Time `json:"ultimo_recordatorio_saldo,omitempty"`
+
 	// Información adicional
 	TieneDiscapacidad       bool    `gorm:"default:false" json:"tiene_discapacidad"`
 	DescripcionDiscapacidad *string `gorm:"type:text" json:"descripcion_discapacidad,omitempty"`
@@ -59,8 +70,14 @@ type CompraPaquete struct {
 	FechaRechazo      *time.Time `json:"fecha_rechazo,omitempty"`
 	RazonRechazo      *string    `gorm:"type:text" json:"razon_rechazo,omitempty"`
 
+	// Asistencia (check-in en el punto de encuentro). ParticipantesPresentes NULL = sin registrar
+	CheckInAt              *time.Time `json:"check_in_at,omitempty"`
+	ParticipantesPresentes *int       `json:"participantes_presentes,omitempty"`
+	NoShow                 bool       `gorm:"default:false" json:"no_show"`
+
 	// Relaciones
-	Pagos []PagoCompra `gorm:"foreignKey:CompraID" json:"pagos,omitempty"`
+	Pagos         []PagoCompra         `gorm:"foreignKey:CompraID" json:"pagos,omitempty"`
+	Participantes []CompraParticipante `gorm:"foreignKey:CompraID" json:"participantes,omitempty"`
 
 	// Auditoría
 	CreatedAt time.Time `json:"created_at"`
@@ -70,3 +87,12 @@ type CompraPaquete struct {
 func (CompraPaquete) TableName() string {
 	return "compras_paquetes"
 }
+
+// SaldoPendiente retorna el monto que falta pagar (nunca negativo).
+func (c *CompraPaquete) SaldoPendiente() float64 {
+	saldo := c.PrecioTotal - c.MontoPagado
+	if saldo < 0 {
+		return 0
+	}
+	return saldo
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// CompraParticipante representa a una persona (con nombre) que viaja dentro de una compra.
+// Tabla: compras_participantes
+type CompraParticipante struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	CompraID uint           `gorm:"not null;index" json:"compra_id"`
+	Compra   *CompraPaquete `gorm:"foreignKey:CompraID" json:"-"`
+
+	// adulto | nino_paga | nino_gratis
+	Tipo string `gorm:"size:20;not null" json:"tipo"`
+
+	NombreCompleto string `gorm:"size:255;not null" json:"nombre_completo"`
+	// ci | pasaporte
+	TipoDocumento   string    `gorm:"size:20;not null" json:"tipo_documento"`
+	NumeroDocumento string    `gorm:"size:50;not null" json:"numero_documento"`
+	Nacionalidad    string    `gorm:"size:100;not null" json:"nacionalidad"`
+	FechaNacimiento time.Time `gorm:"type:date;not null" json:"fecha_nacimiento"`
+
+	ContactoEmergenciaNombre   string `gorm:"size:255;not null" json:"contacto_emergencia_nombre"`
+	ContactoEmergenciaTelefono string `gorm:"size:20;not null" json:"contacto_emergencia_telefono"`
+
+	Notas *string `gorm:"type:text" json:"notas,omitempty"`
+
+	// presente | ausente (NULL = sin registrar)
+	Asistencia   *string    `gorm:"size:20" json:"asistencia,omitempty"`
+	FechaCheckIn *time.Time `json:"fecha_check_in,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (CompraParticipante) TableName() string {
+	return "compras_participantes"
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// FormatoExtractoBancario describe cómo leer el extracto (CSV o Excel) de un banco de la agencia.
+// Las columnas se indican por su encabezado (sin distinguir mayúsculas) o por su letra de Excel (A, B, ...).
+// Tabla: formatos_extracto_bancario
+type FormatoExtractoBancario struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	AgenciaID uint   `gorm:"not null;uniqueIndex:uniq_formato_extracto_agencia_banco" json:"agencia_id"`
+	Banco     string `gorm:"size:60;not null;uniqueIndex:uniq_formato_extracto_agencia_banco" json:"banco"`
+
+	ColumnaFecha       string  `gorm:"size:60;not null" json:"columna_fecha"`
+	ColumnaMonto       string  `gorm:"size:60;not null" json:"columna_monto"`
+	ColumnaDescripcion *string `gorm:"size:60" json:"columna_descripcion,omitempty"`
+	ColumnaReferencia  *string `gorm:"size:60" json:"columna_referencia,omitempty"`
+
+	// Fila (desde 1) con los encabezados; las anteriores suelen ser títulos del banco
+	FilaEncabezado int `gorm:"not null;default:1" json:"fila_encabezado"`
+	// Patrón de fecha con DD, MM, YYYY/YY, HH, mm, ss (p. ej. DD/MM/YYYY)
+	FormatoFecha string `gorm:"size:30;not null;default:'DD/MM/YYYY'" json:"formato_fecha"`
+	// "," (1.234,56) o "." (1,234.56)
+	SeparadorDecimal string `gorm:"size:1;not null;default:','" json:"separador_decimal"`
+	// Delimitador del CSV; vacío = detectar
+	Delimitador string `gorm:"size:1" json:"delimitador"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (FormatoExtractoBancario) TableName() string {
+	return "formatos_extracto_bancario"
+}
+
+// ExtractoBancario es un extracto importado por la agencia para conciliar sus pagos pendientes.
+// Tabla: extractos_bancarios
+type ExtractoBancario struct {
+	ID        uint `gorm:"primaryKey" json:"id"`
+	AgenciaID uint `gorm:"not null;index" json:"agencia_id"`
+	FormatoID uint `gorm:"not null;index" json:"formato_id"`
+
+	Banco         string `gorm:"size:60;not null" json:"banco"`
+	NombreArchivo string `gorm:"size:255;not null" json:"nombre_archivo"`
+	SubidoPor     uint   `gorm:"not null" json:"subido_por"`
+
+	FechaDesde *time.Time `gorm:"type:date" json:"fecha_desde,omitempty"`
+	FechaHasta *time.Time `gorm:"type:date" json:"fecha_hasta,omitempty"`
+
+	// Créditos importados, repetidos de extractos anteriores (omitidos) y monto total importado
+	TotalLineas     int     `gorm:"not null;default:0" json:"total_lineas"`
+	LineasRepetidas int     `gorm:"not null;default:0" json:"lineas_repetidas"`
+	TotalCreditos   float64 `gorm:"type:decimal(12,2);not null;default:0" json:"total_creditos"`
+
+	// Días de tolerancia entre la fecha del crédito y el registro del pago
+	VentanaDias int `gorm:"not null;default:3" json:"ventana_dias"`
+
+	Lineas []LineaExtractoBancario `gorm:"foreignKey:ExtractoID" json:"lineas,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ExtractoBancario) TableName() string {
+	return "extractos_bancarios"
+}
+
+// LineaExtractoBancario es un crédito del extracto y su coincidencia con un pago pendiente.
+// Tabla: lineas_extracto_bancario
+type LineaExtractoBancario struct {
+	ID         uint `gorm:"primaryKey" json:"id"`
+	ExtractoID uint `gorm:"not null;index" json:"extracto_id"`
+	AgenciaID  uint `gorm:"not null;uniqueIndex:uniq_linea_extracto_huella" json:"agencia_id"`
+
+	Fila        int       `gorm:"not null" json:"fila"`
+	Fecha       time.Time `gorm:"type:date;not null" json:"fecha"`
+	Monto       float64   `gorm:"type:decimal(10,2);not null" json:"monto"`
+	Descripcion *string   `gorm:"type:text" json:"descripcion,omitempty"`
+	Referencia  *string   `gorm:"size:150" json:"referencia,omitempty"`
+
+	// Identifica el crédito entre extractos con períodos superpuestos
+	Huella string `gorm:"size:80;not null;uniqueIndex:uniq_linea_extracto_huella" json:"-"`
+
+	// sin_coincidencia | propuesta | conciliada | ignorada
+	Estado string `gorm:"size:20;not null;default:'sin_coincidencia';index" json:"estado"`
+
+	// Pago propuesto (o confirmado) y cómo se llegó a la coincidencia
+	PagoID    *uint       `gorm:"index" json:"pago_id,omitempty"`
+	Pago      *PagoCompra `gorm:"foreignKey:PagoID" json:"pago,omitempty"`
+	Puntaje   int         `gorm:"not null;default:0" json:"puntaje"`
+	Criterios *string     `gorm:"size:255" json:"criterios,omitempty"`
+
+	ConciliadoPor     *uint      `json:"conciliado_por,omitempty"`
+	FechaConciliacion *time.Time `json:"fecha_conciliacion,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (LineaExtractoBancario) TableName() string {
+	return "lineas_extracto_bancario"
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// FormatoExtractoRequest crea o modifica el formato de extracto de un banco.
+type FormatoExtractoRequest struct {
+	Banco              string  `json:"banco" validate:"required,max=60"`
+	ColumnaFecha       string  `json:"columna_fecha" validate:"required,max=60"`
+	ColumnaMonto       string  `json:"columna_monto" validate:"required,max=60"`
+	ColumnaDescripcion *string `json:"columna_descripcion" validate:"omitempty,max=60"`
+	ColumnaReferencia  *string `json:"columna_referencia" validate:"omitempty,max=60"`
+	FilaEncabezado     int     `json:"fila_encabezado" validate:"omitempty,min=1,max=100"`
+	FormatoFecha       string  `json:"formato_fecha" validate:"omitempty,max=30"`
+	SeparadorDecimal   string  `json:"separador_decimal" validate:"omitempty,max=1"`
+	Delimitador        string  `json:"delimitador" validate:"omitempty,max=1"`
+}
+
+// FilaExtractoOmitida es una fila del extracto que no se pudo leer.
+type FilaExtractoOmitida struct {
+	Fila   int    `json:"fila"`
+	Motivo string `json:"motivo"`
+}
+
+// ResultadoImportacionExtracto resume la importación de un extracto y las coincidencias propuestas.
+type ResultadoImportacionExtracto struct {
+	Extracto      *ExtractoBancario     `json:"extracto"`
+	Propuestas    int                   `json:"propuestas"`
+	FilasDebito   int                   `json:"filas_debito"`
+	FilasOmitidas []FilaExtractoOmitida `json:"filas_omitidas"`
+}
+
+// ConfirmarLineaExtracto confirma el pago de una línea; PagoID reemplaza al pago propuesto.
+type ConfirmarLineaExtracto struct {
+	LineaID uint  `json:"linea_id" validate:"required"`
+	PagoID  *uint `json:"pago_id"`
+}
+
+// ConfirmarConciliacionRequest confirma en bloque los pagos de las líneas indicadas.
+type ConfirmarConciliacionRequest struct {
+	Lineas []ConfirmarLineaExtracto `json:"lineas" validate:"required,min=1,max=200,dive"`
+}
+
+// ResultadoConciliacionLinea es el resultado de confirmar una línea; un error no detiene el resto.
+type ResultadoConciliacionLinea struct {
+	LineaID    uint   `json:"linea_id"`
+	PagoID     *uint  `json:"pago_id,omitempty"`
+	Confirmado bool   `json:"confirmado"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PagoPendienteConciliacion es un pago por QR o transferencia pendiente que se puede conciliar.
+type PagoPendienteConciliacion struct {
+	PagoID          uint      `json:"pago_id" gorm:"column:pago_id"`
+	CompraID        uint      `json:"compra_id" gorm:"column:compra_id"`
+	MetodoPago      string    `json:"metodo_pago" gorm:"column:metodo_pago"`
+	Monto           float64   `json:"monto" gorm:"column:monto"`
+	FechaPago       time.Time `json:"fecha_pago" gorm:"column:fecha_pago"`
+	PaqueteNombre   string    `json:"paquete_nombre" gorm:"column:paquete_nombre"`
+	TuristaNombre   string    `json:"turista_nombre" gorm:"column:turista_nombre"`
+	TuristaApellido string    `json:"turista_apellido" gorm:"column:turista_apellido"`
+	ReferenciaPago  string    `json:"referencia_pago" gorm:"-"`
+}
+
+// PendientesConciliacionResponse lista lo que queda por seguir tras conciliar un extracto.
+type PendientesConciliacionResponse struct {
+	CreditosSinConciliar []LineaExtractoBancario     `json:"creditos_sin_conciliar"`
+	PagosSinCredito      []PagoPendienteConciliacion `json:"pagos_sin_credito"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// EventoWebhookPago guarda cada notificación de pago recibida de un proveedor (pasarela o QR bancario),
+// con el cuerpo tal como llegó. Un mismo evento reenviado por el proveedor no se vuelve a procesar:
+// solo incrementa Recepciones.
+// Tabla: eventos_webhook_pagos
+type EventoWebhookPago struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	Proveedor string `gorm:"size:30;not null;uniqueIndex:uniq_evento_webhook_pago" json:"proveedor"`
+	// ID del evento según el proveedor; si no lo informa (o la firma es inválida) es el hash del cuerpo
+	EventoID string `gorm:"size:150;not null;uniqueIndex:uniq_evento_webhook_pago" json:"evento_id"`
+
+	Cuerpo      string `gorm:"type:text;not null" json:"cuerpo"`
+	FirmaValida bool   `gorm:"not null;default:false" json:"firma_valida"`
+
+	// Datos informados por el proveedor
+	Referencia      *string  `gorm:"size:150;index" json:"referencia,omitempty"`
+	Glosa           *string  `gorm:"type:text" json:"glosa,omitempty"`
+	Monto           *float64 `gorm:"type:decimal(10,2)" json:"monto,omitempty"`
+	EstadoInformado *string  `gorm:"size:20" json:"estado_informado,omitempty"`
+
+	// recibido | procesado | ignorado | pendiente_revision | descartado | firma_invalida | invalido
+	Estado string  `gorm:"size:20;not null;default:'recibido';index" json:"estado"`
+	Motivo *string `gorm:"type:text" json:"motivo,omitempty"`
+
+	// Pago al que se aplicó el evento y, si no se pudo aplicar, el candidato sugerido por monto
+	PagoID         *uint       `gorm:"index" json:"pago_id,omitempty"`
+	Pago           *PagoCompra `gorm:"foreignKey:PagoID" json:"pago,omitempty"`
+	PagoSugeridoID *uint       `json:"pago_sugerido_id,omitempty"`
+
+	Recepciones     int       `gorm:"not null;default:1" json:"recepciones"`
+	UltimaRecepcion time.Time `json:"ultima_recepcion"`
+
+	// Resolución manual desde la cola de revisión del admin
+	RevisadoPor   *uint      `json:"revisado_por,omitempty"`
+	FechaRevision *time.Time `json:"fecha_revision,omitempty"`
+	NotasRevision *string    `gorm:"type:text" json:"notas_revision,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (EventoWebhookPago) TableName() string {
+	return "eventos_webhook_pagos"
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Guia es un guía del staff de una agencia. Si tiene cuenta en la plataforma (UsuarioID),
+// recibe notificaciones de sus asignaciones y puede registrar asistencia en sus salidas.
+// Tabla: guias
+type Guia struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	AgenciaID uint     `gorm:"not null;index;uniqueIndex:uniq_guia_agencia_usuario,priority:1" json:"agencia_id"`
+	UsuarioID *uint    `gorm:"index;uniqueIndex:uniq_guia_agencia_usuario,priority:2" json:"usuario_id,omitempty"`
+	Usuario   *Usuario `gorm:"foreignKey:UsuarioID" json:"usuario,omitempty"`
+
+	Nombre   string  `gorm:"size:255;not null" json:"nombre"`
+	Telefono *string `gorm:"size:20" json:"telefono,omitempty"`
+	Email    *string `gorm:"size:255" json:"email,omitempty"`
+
+	Idiomas         StringArray `gorm:"type:text[]" json:"idiomas"`
+	Certificaciones StringArray `gorm:"type:text[]" json:"certificaciones"`
+
+	NumeroLicencia      *string    `gorm:"size:50" json:"numero_licencia,omitempty"`
+	LicenciaVencimiento *time.Time `gorm:"type:date" json:"licencia_vencimiento,omitempty"`
+
+	Notas  *string `gorm:"type:text" json:"notas,omitempty"`
+	Activo bool    `gorm:"default:true;index" json:"activo"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Guia) TableName() string {
+	return "guias"
+}
+
+// SalidaGuia asigna un guía a una salida.
+// Tabla: salida_guias
+type SalidaGuia struct {
+	ID       uint  `gorm:"primaryKey" json:"id"`
+	SalidaID uint  `gorm:"not null;index;uniqueIndex:uniq_salida_guia,priority:1" json:"salida_id"`
+	GuiaID   uint  `gorm:"not null;index;uniqueIndex:uniq_salida_guia,priority:2" json:"guia_id"`
+	Guia     *Guia `gorm:"foreignKey:GuiaID" json:"guia,omitempty"`
+
+	// principal | apoyo
+	Rol string `gorm:"size:20;default:'principal'" json:"rol"`
+
+	AsignadoPorID *uint     `json:"asignado_por_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (SalidaGuia) TableName() string {
+	return "salida_guias"
+}
@@ -0,0 +1,42 @@
+package models
+
+// AsignacionGuiaRequest es un guía dentro de la asignación de una salida.
+type AsignacionGuiaRequest struct {
+	GuiaID uint   `json:"guia_id" validate:"required"`
+	Rol    string `json:"rol" validate:"omitempty,oneof=principal apoyo"`
+}
+
+// AsignarGuiasSalidaRequest reemplaza los guías asignados a una salida (lista vacía = sin guías).
+type AsignarGuiasSalidaRequest struct {
+	Guias []AsignacionGuiaRequest `json:"guias" validate:"dive"`
+}
+
+// ConflictoGuia describe una salida que se superpone con la que se intenta asignar.
+type ConflictoGuia struct {
+	GuiaID        uint   `json:"guia_id" gorm:"column:guia_id"`
+	GuiaNombre    string `json:"guia_nombre" gorm:"column:guia_nombre"`
+	SalidaID      uint   `json:"salida_id" gorm:"column:salida_id"`
+	PaqueteNombre string `json:"paquete_nombre" gorm:"column:paquete_nombre"`
+	FechaInicio   string `json:"fecha_inicio" gorm:"column:fecha_inicio"`
+	FechaFin      string `json:"fecha_fin" gorm:"column:fecha_fin"`
+	Horario       string `json:"horario" gorm:"column:horario"`
+}
+
+// CalendarioGuiaItem es una salida del calendario de un guía.
+type CalendarioGuiaItem struct {
+	SalidaID      uint   `json:"salida_id" gorm:"column:salida_id"`
+	AgenciaID     uint   `json:"agencia_id" gorm:"column:agencia_id"`
+	GuiaID        uint   `json:"guia_id" gorm:"column:guia_id"`
+	PaqueteID     uint   `json:"paquete_id" gorm:"column:paquete_id"`
+	PaqueteNombre string `json:"paquete_nombre" gorm:"column:paquete_nombre"`
+	FechaInicio   string `json:"fecha_inicio" gorm:"column:fecha_inicio"`
+	FechaFin      string `json:"fecha_fin" gorm:"column:fecha_fin"`
+	Horario       string `json:"horario" gorm:"column:horario"`
+	TipoSalida    string `json:"tipo_salida" gorm:"column:tipo_salida"`
+	Estado        string `json:"estado" gorm:"column:estado"`
+	Rol           string `json:"rol" gorm:"column:rol"`
+	Participantes int    `json:"participantes" gorm:"column:participantes"`
+
+	PuntoEncuentro *string `json:"punto_encuentro,omitempty" gorm:"column:punto_encuentro"`
+	HoraEncuentro  *string `json:"hora_encuentro,omitempty" gorm:"column:hora_encuentro"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// ListaEsperaSalida representa a un turista esperando cupos en una salida llena.
+// Cuando se liberan cupos, la primera entrada que entra en los cupos libres recibe una oferta
+// con tiempo límite; mientras la oferta está vigente sus cupos quedan retenidos en la salida.
+// Tabla: lista_espera_salidas
+type ListaEsperaSalida struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	SalidaID  uint                     `gorm:"not null;index" json:"salida_id"`
+	Salida    *PaqueteSalidaHabilitada `gorm:"foreignKey:SalidaID" json:"salida,omitempty"`
+	PaqueteID uint                     `gorm:"not null;index" json:"paquete_id"`
+	Paquete   *PaqueteTuristico        `gorm:"foreignKey:PaqueteID" json:"paquete,omitempty"`
+	TuristaID uint                     `gorm:"not null;index" json:"turista_id"`
+
+	CantidadAdultos     int  `gorm:"not null" json:"cantidad_adultos"`
+	CantidadNinosPagan  int  `gorm:"default:0" json:"cantidad_ninos_pagan"`
+	CantidadNinosGratis int  `gorm:"default:0" json:"cantidad_ninos_gratis"`
+	TotalParticipantes  int  `gorm:"not null" json:"total_participantes"`
+	Extranjero          bool `gorm:"default:false" json:"extranjero"`
+
+	Notas *string `gorm:"type:text" json:"notas,omitempty"`
+
+	// esperando | ofertada | convertida | expirada | cancelada
+	Estado string `gorm:"size:20;default:'esperando';index" json:"estado"`
+
+	FechaOferta    *time.Time `json:"fecha_oferta,omitempty"`
+	OfertaExpiraEn *time.Time `json:"oferta_expira_en,omitempty"`
+	CompraID       *uint      `gorm:"index" json:"compra_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ListaEsperaSalida) TableName() string {
+	return "lista_espera_salidas"
+}
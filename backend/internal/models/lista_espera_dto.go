@@ -0,0 +1,21 @@
+package models
+
+// UnirseListaEsperaRequest inscribe al turista en la lista de espera de una salida.
+type UnirseListaEsperaRequest struct {
+	CantidadAdultos     int     `json:"cantidad_adultos" validate:"required,min=1"`
+	CantidadNinosPagan  int     `json:"cantidad_ninos_pagan" validate:"min=0"`
+	CantidadNinosGratis int     `json:"cantidad_ninos_gratis" validate:"min=0"`
+	Extranjero          bool    `json:"extranjero"`
+	Notas               *string `json:"notas"`
+}
+
+// AceptarOfertaListaEsperaRequest convierte una oferta vigente en compra.
+// Las cantidades y la fecha se toman de la entrada de la lista de espera.
+type AceptarOfertaListaEsperaRequest struct {
+	TieneDiscapacidad       bool    `json:"tiene_discapacidad"`
+	DescripcionDiscapacidad *string `json:"descripcion_discapacidad"`
+	NotasTurista            *string `json:"notas_turista"`
+
+	CodigoPromocion *string               `json:"codigo_promocion" validate:"omitempty,max=50"`
+	Participantes   []ParticipanteRequest `json:"participantes" validate:"omitempty,dive"`
+}
@@ -12,7 +12,7 @@ import (
 type Notificacion struct {
 	ID         uint           `gorm:"primarykey" json:"id"`
 	UsuarioID  uint           `gorm:"not null;index:idx_notificaciones_usuario_leida" json:"usuario_id"`
-	Tipo       string         `gorm:"size:50;not null" json:"tipo"` // nuevo_pago_pendiente, pago_confirmado, pago_rechazado, compra_expirada
+	Tipo       string         `gorm:"size:50;not null" json:"tipo"` // nuevo_pago_pendiente, pago_confirmado, pago_rechazado, compra_expirada, ...
 	Titulo     string         `gorm:"size:255;not null" json:"titulo"`
 	Mensaje    string         `gorm:"type:text;not null" json:"mensaje"`
 	DatosJSON  NotifDatosJSON `gorm:"type:jsonb" json:"datos_json"`
@@ -76,8 +76,41 @@ func (n *Notificacion) MarcarComoLeida(db *gorm.DB) error {
 
 // Constantes para tipos de notificaciones
 const (
-	TipoNuevoPagoPendiente = "nuevo_pago_pendiente"
-	TipoPagoConfirmado     = "pago_confirmado"
-	TipoPagoRechazado      = "pago_rechazado"
-	TipoCompraExpirada     = "compra_expirada"
+	TipoNuevoPagoPendiente   = "nuevo_pago_pendiente"
+	TipoPagoConfirmado       = "pago_confirmado"
+	TipoPagoRechazado        = "pago_rechazado"
+	TipoCompraExpirada       = "compra_expirada"
+	TipoAnticipoConfirmado   = "anticipo_confirmado"
+	TipoRecordatorioSaldo    = "recordatorio_saldo"
+	TipoSaldoVencido         = "saldo_vencido"
+	TipoCompraCanceladaSaldo = "compra_cancelada_saldo"
+	TipoReembolsoPendiente   = "reembolso_pendiente"
+	TipoReembolsoPagado      = "reembolso_pagado"
+	TipoOfertaListaEspera    = "oferta_lista_espera"
+	TipoOfertaExpirada       = "oferta_lista_espera_expirada"
+	TipoCompraModificada     = "compra_modificada"
+
+	TipoSolicitudPrivadaNueva     = "solicitud_privada_nueva"
+	TipoSolicitudPrivadaCotizada  = "solicitud_privada_cotizada"
+	TipoSolicitudPrivadaMensaje   = "solicitud_privada_mensaje"
+	TipoSolicitudPrivadaAceptada  = "solicitud_privada_aceptada"
+	TipoSolicitudPrivadaRechazada = "solicitud_privada_rechazada"
+	TipoSolicitudPrivadaCancelada = "solicitud_privada_cancelada"
+	TipoSolicitudPrivadaExpirada  = "solicitud_privada_expirada"
+
+	TipoGuiaAsignado          = "guia_asignado"
+	TipoGuiaDesasignado       = "guia_desasignado"
+	TipoSalidaGuiaActualizada = "salida_guia_actualizada"
+
+	TipoSalidaConfirmada         = "salida_confirmada"
+	TipoSalidaCancelada          = "salida_cancelada"
+	TipoResumenQuorumSalida      = "resumen_quorum_salida"
+	TipoResumenCancelacionSalida = "resumen_cancelacion_salida"
+	TipoSalidaReprogramada       = "salida_reprogramada"
+	TipoReprogramacionRespuesta  = "reprogramacion_respuesta"
+
+	TipoPagoPasarelaFallido = "pago_pasarela_fallido"
+
+	TipoComprobanteReportado = "comprobante_reportado"
+	TipoComprobanteRevisado  = "comprobante_revisado"
 )
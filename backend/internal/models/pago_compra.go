@@ -10,11 +10,27 @@ type PagoCompra struct {
 	CompraID uint           `gorm:"not null;index" json:"compra_id"`
 	Compra   *CompraPaquete `gorm:"foreignKey:CompraID" json:"compra,omitempty"`
 
+	// efectivo | qr | transferencia | pasarela
 	MetodoPago string  `gorm:"size:20;not null" json:"metodo_pago"`
 	Monto      float64 `gorm:"type:decimal(10,2);not null" json:"monto"`
 
 	ComprobanteFoto *string `gorm:"type:text" json:"comprobante_foto,omitempty"`
 
+	// Huellas del comprobante para detectar capturas reutilizadas: SHA-256 del archivo y hash perceptual
+	// (dHash de 64 bits; nil si la imagen no se pudo decodificar)
+	ComprobanteSHA256 *string `gorm:"size:64;index" json:"-"`
+	ComprobantePHash  *int64  `gorm:"index" json:"-"`
+	// El comprobante coincide con el de otra compra (ver CoincidenciaComprobante)
+	ComprobanteAlerta bool `gorm:"not null;default:false;index" json:"comprobante_alerta"`
+
+	// Pagos en línea (metodo_pago = pasarela): proveedor, referencia del intento y URL de checkout
+	Proveedor           *string    `gorm:"size:30;index:idx_pagos_compras_proveedor_ref" json:"proveedor,omitempty"`
+	ReferenciaProveedor *string    `gorm:"size:100;index:idx_pagos_compras_proveedor_ref" json:"referencia_proveedor,omitempty"`
+	CheckoutURL         *string    `gorm:"type:text" json:"checkout_url,omitempty"`
+	FechaExpiracion     *time.Time `json:"fecha_expiracion,omitempty"`
+
+	// pendiente | confirmado | rechazado; los pagos en línea también pueden quedar fallido | expirado
+	// (no afectan a la compra: el turista puede volver a intentar)
 	Estado string `gorm:"size:20;default:'pendiente';index" json:"estado"`
 
 	ConfirmadoPor     *uint      `gorm:"index" json:"confirmado_por,omitempty"`
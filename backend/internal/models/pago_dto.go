@@ -1,6 +1,9 @@
 package models
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+	"time"
+)
 
 type CrearPagoRequest struct {
 	CompraID    uint                  `validate:"required"`
@@ -24,5 +27,72 @@ type PagoResponse struct {
 	Monto           float64 `json:"monto"`
 	Estado          string  `json:"estado"`
 	ComprobanteFoto *string `json:"comprobante_foto"`
-	Mensaje         string  `json:"mensaje"`
+	// Saldo de la compra antes de confirmar este pago
+	SaldoPendiente float64 `json:"saldo_pendiente"`
+	Mensaje        string  `json:"mensaje"`
+}
+
+// IniciarPagoPasarelaRequest inicia un pago en línea de una compra.
+type IniciarPagoPasarelaRequest struct {
+	CompraID  uint    `json:"compra_id" validate:"required"`
+	Monto     float64 `json:"monto" validate:"required,gt=0"`
+	Proveedor string  `json:"proveedor" validate:"required,max=30"`
+}
+
+// PagoPasarelaResponse es el pago en línea creado; el turista completa el pago en CheckoutURL.
+type PagoPasarelaResponse struct {
+	PagoID          uint       `json:"pago_id"`
+	CompraID        uint       `json:"compra_id"`
+	Proveedor       string     `json:"proveedor"`
+	Referencia      string     `json:"referencia"`
+	CheckoutURL     string     `json:"checkout_url"`
+	Monto           float64    `json:"monto"`
+	Estado          string     `json:"estado"`
+	FechaExpiracion *time.Time `json:"fecha_expiracion,omitempty"`
+}
+
+// WebhookPagoResponse es la respuesta al proveedor que envió el webhook.
+type WebhookPagoResponse struct {
+	EventoID  uint   `json:"evento_id"`
+	Estado    string `json:"estado"`
+	Duplicado bool   `json:"duplicado"`
+}
+
+// AsignarEventoWebhookRequest aplica un evento de la cola de revisión a un pago pendiente.
+type AsignarEventoWebhookRequest struct {
+	PagoID uint    `json:"pago_id" validate:"required"`
+	Notas  *string `json:"notas"`
+}
+
+// DescartarEventoWebhookRequest cierra un evento de la cola de revisión sin aplicarlo.
+type DescartarEventoWebhookRequest struct {
+	Notas string `json:"notas" validate:"required,min=3,max=500"`
+}
+
+// CoincidenciaComprobanteResumen es una coincidencia del comprobante de un pago, tal como la ve el encargado
+// antes de confirmarlo. Los datos de la compra anterior solo se incluyen si es de la misma agencia.
+type CoincidenciaComprobanteResumen struct {
+	ID               uint      `json:"id" gorm:"column:id"`
+	PagoID           uint      `json:"pago_id" gorm:"column:pago_id"`
+	PagoPrevioID     uint      `json:"pago_previo_id" gorm:"column:pago_previo_id"`
+	Tipo             string    `json:"tipo" gorm:"column:tipo"`
+	Distancia        int       `json:"distancia" gorm:"column:distancia"`
+	MismaAgencia     bool      `json:"misma_agencia" gorm:"column:misma_agencia"`
+	MismoTurista     bool      `json:"mismo_turista" gorm:"column:mismo_turista"`
+	Estado           string    `json:"estado" gorm:"column:estado"`
+	EstadoPagoPrevio string    `json:"estado_pago_previo" gorm:"column:estado_pago_previo"`
+	MontoPagoPrevio  float64   `json:"monto_pago_previo" gorm:"column:monto_pago_previo"`
+	FechaPagoPrevio  time.Time `json:"fecha_pago_previo" gorm:"column:fecha_pago_previo"`
+	CompraPreviaID   *uint     `json:"compra_previa_id,omitempty" gorm:"column:compra_previa_id"`
+}
+
+// ReportarComprobanteRequest envía al admin las coincidencias del comprobante de un pago.
+type ReportarComprobanteRequest struct {
+	Motivo string `json:"motivo" validate:"required,min=3,max=500"`
+}
+
+// ResolverCoincidenciaComprobanteRequest cierra una coincidencia de comprobante revisada por el admin.
+type ResolverCoincidenciaComprobanteRequest struct {
+	Resultado string `json:"resultado" validate:"required,oneof=legitima fraude"`
+	Notas     string `json:"notas" validate:"required,min=3,max=500"`
 }
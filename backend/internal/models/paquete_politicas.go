@@ -12,9 +12,18 @@ type PaquetePolitica struct {
 	// Política de niños: menores a esta edad no pagan
 	EdadMinimaPago int `gorm:"default:6" json:"edad_minima_pago"`
 
+	// Desde esta edad (a la fecha de salida) el participante se registra como adulto
+	EdadAdulto int `gorm:"default:18" json:"edad_adulto"`
+
 	// Porcentaje de recargo para paquetes privados
 	RecargoPrivadoPorcentaje float64 `gorm:"type:decimal(5,2);default:0" json:"recargo_privado_porcentaje"`
 
+	// Anticipo para paquetes de varios días: porcentaje del total que permite reservar (0 = pago completo)
+	AnticipoPorcentaje float64 `gorm:"type:decimal(5,2);default:0" json:"anticipo_porcentaje"`
+
+	// Días antes de la salida en que vence el saldo de una reserva con anticipo
+	DiasLimiteSaldo int `gorm:"default:7" json:"dias_limite_saldo"`
+
 	// Política de cancelación (texto libre)
 	PoliticaCancelacion *string `gorm:"type:text" json:"politica_cancelacion"`
 
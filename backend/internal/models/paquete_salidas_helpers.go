@@ -1,6 +1,6 @@
 package models
 
-// CuposDisponibles retorna cupos libres considerando reservados y confirmados.
+// CuposDisponibles retorna cupos libres considerando reservados, confirmados y ofertados a la lista de espera.
 func (s PaqueteSalidaHabilitada) CuposDisponibles() int {
-	return s.CupoMaximo - s.CuposReservados - s.CuposConfirmados
+	return s.CupoMaximo - s.CuposReservados - s.CuposConfirmados - s.CuposOfertados
 }
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// PaqueteTemporadaPrecio es una regla de precio por temporada de un paquete.
+// Tabla: paquete_temporadas_precio
+//
+// Tipos:
+//   - rango_fechas: aplica a toda fecha entre FechaInicio y FechaFin.
+//   - dias_semana: aplica a los días ISO listados en DiasSemana (1 = lunes ... 7 = domingo),
+//     opcionalmente acotados por FechaInicio/FechaFin.
+//   - fechas_especificas: aplica a las fechas listadas en Fechas (feriados, Carnaval, etc.).
+//
+// Si varias reglas aplican a una fecha gana la de mayor Prioridad; a igual prioridad,
+// fechas_especificas sobre rango_fechas sobre dias_semana.
+type PaqueteTemporadaPrecio struct {
+	ID        uint `gorm:"primaryKey" json:"id"`
+	PaqueteID uint `gorm:"not null;index" json:"paquete_id"`
+
+	Nombre string `gorm:"size:100;not null" json:"nombre"`
+	Tipo   string `gorm:"size:20;not null" json:"tipo"`
+
+	FechaInicio *string     `gorm:"type:date" json:"fecha_inicio"`
+	FechaFin    *string     `gorm:"type:date" json:"fecha_fin"`
+	DiasSemana  StringArray `gorm:"type:text[]" json:"dias_semana"`
+	Fechas      StringArray `gorm:"type:text[]" json:"fechas"`
+
+	PrecioBaseNacionales float64 `gorm:"type:decimal(10,2);not null" json:"precio_base_nacionales"`
+	// NULL = usa el recargo de extranjeros del paquete
+	PrecioAdicionalExtranjeros *float64 `gorm:"type:decimal(10,2)" json:"precio_adicional_extranjeros"`
+
+	Prioridad int  `gorm:"default:0" json:"prioridad"`
+	Activa    bool `gorm:"default:true" json:"activa"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (PaqueteTemporadaPrecio) TableName() string {
+	return "paquete_temporadas_precio"
+}
+
+// PrecioSalida es el precio resuelto para una fecha/salida (public.resolver_precio_paquete).
+type PrecioSalida struct {
+	PrecioBaseNacionales       float64 `json:"precio_base_nacionales" gorm:"column:precio_base"`
+	PrecioAdicionalExtranjeros float64 `json:"precio_adicional_extranjeros" gorm:"column:precio_adicional_extranjeros"`
+	// base | temporada | salida
+	ReglaPrecio       string `json:"regla_precio" gorm:"column:regla_precio"`
+	TemporadaPrecioID *uint  `json:"temporada_precio_id,omitempty" gorm:"column:temporada_precio_id"`
+}
@@ -53,6 +53,10 @@ type PaqueteTuristico struct {
 	Status         string `gorm:"size:20;default:'borrador';index" json:"status"`
 	VisiblePublico bool   `gorm:"default:true;index" json:"visible_publico"`
 
+	// Reseñas visibles (desnormalizado, lo actualiza ResenaService; solo lectura para GORM)
+	RatingPromedio float64 `gorm:"type:decimal(3,2);default:0;index;<-:false" json:"rating_promedio"`
+	TotalResenas   int     `gorm:"default:0;<-:false" json:"total_resenas"`
+
 	// Relaciones (opcionales en responses)
 	Fotos       []PaqueteFoto             `gorm:"foreignKey:PaqueteID" json:"fotos,omitempty"`
 	Itinerario  []PaqueteItinerario       `gorm:"foreignKey:PaqueteID" json:"itinerario,omitempty"`
@@ -83,18 +87,34 @@ type PaqueteSalidaHabilitada struct {
 	CupoMaximo       int `gorm:"not null" json:"cupo_maximo"`
 	CuposReservados  int `gorm:"default:0" json:"cupos_reservados"`
 	CuposConfirmados int `gorm:"default:0" json:"cupos_confirmados"`
+	// Cupos retenidos para ofertas vigentes de la lista de espera
+	CuposOfertados int `gorm:"default:0" json:"cupos_ofertados"`
+
+	// Precio especial de esta salida. NULL = se resuelve por temporada o precio base del paquete
+	PrecioBaseNacionales       *float64 `gorm:"type:decimal(10,2)" json:"precio_base_nacionales,omitempty"`
+	PrecioAdicionalExtranjeros *float64 `gorm:"type:decimal(10,2)" json:"precio_adicional_extranjeros,omitempty"`
 
 	PuntoEncuentro        *string `gorm:"type:text" json:"punto_encuentro"`
 	HoraEncuentro         *string `gorm:"type:time" json:"hora_encuentro"`
 	NotasLogistica        *string `gorm:"type:text" json:"notas_logistica"`
 	InstruccionesTuristas *string `gorm:"type:text" json:"instrucciones_turistas"`
 
-	GuiaNombre   *string `gorm:"size:255" json:"guia_nombre"`
-	GuiaTelefono *string `gorm:"size:20" json:"guia_telefono"`
+	// Guía principal (se sincroniza con las asignaciones de salida_guias)
+	GuiaNombre   *string      `gorm:"size:255" json:"guia_nombre"`
+	GuiaTelefono *string      `gorm:"size:20" json:"guia_telefono"`
+	Guias        []SalidaGuia `gorm:"foreignKey:SalidaID" json:"guias,omitempty"`
 
+	// Vehículos y equipo asignados (salida_recursos)
+	Recursos []SalidaRecurso `gorm:"foreignKey:SalidaID" json:"recursos,omitempty"`
+
+	// pendiente | activa | en_curso | completada | cancelada
 	Estado           string  `gorm:"size:20;default:'pendiente'" json:"estado"`
 	RazonCancelacion *string `gorm:"type:text" json:"razon_cancelacion"`
 
+	// Inicio (primer check-in) y cierre reales de la salida
+	FechaInicioReal *time.Time `json:"fecha_inicio_real,omitempty"`
+	FechaFinReal    *time.Time `json:"fecha_fin_real,omitempty"`
+
 	// Campos para salidas pre-creadas manualmente
 	CreadaManualmente        bool       `gorm:"default:false" json:"creada_manualmente"`
 	CreadaPorUsuarioID       *uint      `gorm:"index" json:"creada_por_usuario_id,omitempty"`
@@ -102,6 +122,15 @@ type PaqueteSalidaHabilitada struct {
 	DescripcionSalida        *string    `gorm:"type:text" json:"descripcion_salida,omitempty"`
 	NotasInternas            *string    `gorm:"type:text" json:"notas_internas,omitempty"`
 
+	// Serie recurrente que generó la salida (salida_series)
+	SerieID *uint `gorm:"index" json:"serie_id,omitempty"`
+
+	// UID del evento .ics del que se importó la salida; una nueva importación no la duplica
+	ICalUID *string `gorm:"size:255;index" json:"ical_uid,omitempty"`
+
+	// Precio resuelto para la salida (calculado, no persistido)
+	Precio *PrecioSalida `gorm:"-" json:"precio,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Promocion representa un código de descuento creado por una agencia.
+// Tabla: promociones
+type Promocion struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	AgenciaID uint `gorm:"not null;index;uniqueIndex:idx_promociones_agencia_codigo" json:"agencia_id"`
+
+	// Código ingresado por el turista (se guarda en mayúsculas)
+	Codigo      string  `gorm:"size:50;not null;uniqueIndex:idx_promociones_agencia_codigo" json:"codigo"`
+	Descripcion *string `gorm:"type:text" json:"descripcion,omitempty"`
+
+	// porcentaje | monto_fijo
+	TipoDescuento string  `gorm:"size:20;not null" json:"tipo_descuento"`
+	Valor         float64 `gorm:"type:decimal(10,2);not null" json:"valor"`
+
+	// Vigencia (fecha de compra). NULL = sin límite
+	FechaInicio *string `gorm:"type:date" json:"fecha_inicio"`
+	FechaFin    *string `gorm:"type:date" json:"fecha_fin"`
+
+	// Límites de uso. NULL = ilimitado
+	UsosMaximos    *int `json:"usos_maximos"`
+	UsosPorUsuario *int `json:"usos_por_usuario"`
+
+	Activa bool `gorm:"default:true" json:"activa"`
+
+	// Paquetes a los que aplica. Vacío = todos los paquetes de la agencia
+	Paquetes []PromocionPaquete `gorm:"foreignKey:PromocionID" json:"paquetes,omitempty"`
+
+	// Usos vigentes (compras no canceladas/expiradas/rechazadas). Calculado, no persistido.
+	UsosActuales int64 `gorm:"-" json:"usos_actuales"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Promocion) TableName() string {
+	return "promociones"
+}
+
+// PromocionPaquete limita una promoción a paquetes específicos.
+// Tabla: promocion_paquetes
+type PromocionPaquete struct {
+	ID          uint `gorm:"primaryKey" json:"id"`
+	PromocionID uint `gorm:"not null;uniqueIndex:idx_promocion_paquete" json:"promocion_id"`
+	PaqueteID   uint `gorm:"not null;uniqueIndex:idx_promocion_paquete;index" json:"paquete_id"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (PromocionPaquete) TableName() string {
+	return "promocion_paquetes"
+}
+
+// PromocionUso registra cada aplicación de una promoción a una compra.
+// Tabla: promocion_usos
+type PromocionUso struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	PromocionID    uint      `gorm:"not null;index" json:"promocion_id"`
+	CompraID       uint      `gorm:"not null;uniqueIndex" json:"compra_id"`
+	TuristaID      uint      `gorm:"not null;index" json:"turista_id"`
+	MontoDescuento float64   `gorm:"type:decimal(10,2);not null" json:"monto_descuento"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (PromocionUso) TableName() string {
+	return "promocion_usos"
+}
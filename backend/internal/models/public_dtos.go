@@ -59,6 +59,7 @@ type PaquetePublicoDTO struct {
 // PaquetePoliticaDTO sin información sensible
 type PaquetePoliticaDTO struct {
 	EdadMinimaPago           int     `json:"edad_minima_pago"`
+	EdadAdulto               int     `json:"edad_adulto"`
 	RecargoPrivadoPorcentaje float64 `json:"recargo_privado_porcentaje"`
 	PoliticaCancelacion      *string `json:"politica_cancelacion"`
 }
@@ -188,6 +189,7 @@ func (p *PaqueteTuristico) ToPublicDTO() *PaquetePublicoDTO {
 	if p.Politicas != nil {
 		dto.Politicas = &PaquetePoliticaDTO{
 			EdadMinimaPago:           p.Politicas.EdadMinimaPago,
+			EdadAdulto:               p.Politicas.EdadAdulto,
 			RecargoPrivadoPorcentaje: p.Politicas.RecargoPrivadoPorcentaje,
 			PoliticaCancelacion:      p.Politicas.PoliticaCancelacion,
 		}
@@ -203,7 +205,7 @@ func (p *PaqueteTuristico) ToPublicDTO() *PaquetePublicoDTO {
 }
 
 func (s *PaqueteSalidaHabilitada) ToPublicDTO() *SalidaPublicaDTO {
-	cuposDisponibles := s.CupoMaximo - s.CuposReservados - s.CuposConfirmados
+	cuposDisponibles := s.CuposDisponibles()
 	if cuposDisponibles < 0 {
 		cuposDisponibles = 0
 	}
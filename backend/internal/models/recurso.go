@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Recurso es un vehículo o equipo de la agencia que se asigna a salidas.
+// Para vehículos, Capacidad son los asientos para turistas; para el resto, las personas que cubre.
+// Tabla: recursos
+type Recurso struct {
+	ID        uint `gorm:"primaryKey" json:"id"`
+	AgenciaID uint `gorm:"not null;index" json:"agencia_id"`
+
+	// vehiculo | bicicleta | equipo_camping | otro
+	Tipo          string  `gorm:"size:30;not null;index" json:"tipo"`
+	Nombre        string  `gorm:"size:255;not null" json:"nombre"`
+	Identificador *string `gorm:"size:100" json:"identificador,omitempty"` // placa o número de serie
+	Capacidad     int     `gorm:"not null;default:1" json:"capacidad"`
+
+	Notas  *string `gorm:"type:text" json:"notas,omitempty"`
+	Activo bool    `gorm:"default:true;index" json:"activo"`
+
+	Mantenimientos []RecursoMantenimiento `gorm:"foreignKey:RecursoID" json:"mantenimientos,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Recurso) TableName() string {
+	return "recursos"
+}
+
+// RecursoMantenimiento es una ventana en la que el recurso no puede asignarse.
+// Tabla: recurso_mantenimientos
+type RecursoMantenimiento struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	RecursoID   uint      `gorm:"not null;index" json:"recurso_id"`
+	FechaInicio time.Time `gorm:"type:date;not null" json:"fecha_inicio"`
+	FechaFin    time.Time `gorm:"type:date;not null" json:"fecha_fin"`
+	Motivo      *string   `gorm:"size:255" json:"motivo,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (RecursoMantenimiento) TableName() string {
+	return "recurso_mantenimientos"
+}
+
+// SalidaRecurso asigna un recurso a una salida.
+// Tabla: salida_recursos
+type SalidaRecurso struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	SalidaID  uint     `gorm:"not null;index;uniqueIndex:uniq_salida_recurso,priority:1" json:"salida_id"`
+	RecursoID uint     `gorm:"not null;index;uniqueIndex:uniq_salida_recurso,priority:2" json:"recurso_id"`
+	Recurso   *Recurso `gorm:"foreignKey:RecursoID" json:"recurso,omitempty"`
+
+	AsignadoPorID *uint     `json:"asignado_por_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (SalidaRecurso) TableName() string {
+	return "salida_recursos"
+}
@@ -0,0 +1,70 @@
+package models
+
+// AsignarRecursosSalidaRequest reemplaza los recursos asignados a una salida (lista vacía = sin recursos).
+// El cupo máximo de la salida se limita siempre a los asientos de los vehículos asignados; con AjustarCupo
+// también se amplía hasta esos asientos.
+type AsignarRecursosSalidaRequest struct {
+	RecursoIDs  []uint `json:"recurso_ids"`
+	AjustarCupo bool   `json:"ajustar_cupo"`
+}
+
+// CrearMantenimientoRequest registra una ventana de mantenimiento (fechas YYYY-MM-DD, inclusive).
+type CrearMantenimientoRequest struct {
+	FechaInicio string  `json:"fecha_inicio" validate:"required"`
+	FechaFin    string  `json:"fecha_fin" validate:"required"`
+	Motivo      *string `json:"motivo" validate:"omitempty,max=255"`
+}
+
+// ConflictoRecurso describe por qué un recurso no está disponible para una salida.
+type ConflictoRecurso struct {
+	RecursoID     uint   `json:"recurso_id" gorm:"column:recurso_id"`
+	RecursoNombre string `json:"recurso_nombre" gorm:"column:recurso_nombre"`
+	// salida | mantenimiento
+	Motivo        string  `json:"motivo" gorm:"column:motivo"`
+	SalidaID      *uint   `json:"salida_id,omitempty" gorm:"column:salida_id"`
+	PaqueteNombre *string `json:"paquete_nombre,omitempty" gorm:"column:paquete_nombre"`
+	FechaInicio   string  `json:"fecha_inicio" gorm:"column:fecha_inicio"`
+	FechaFin      string  `json:"fecha_fin" gorm:"column:fecha_fin"`
+}
+
+// RecursosSalidaResponse resume los recursos de una salida y si los asientos cubren la ocupación.
+type RecursosSalidaResponse struct {
+	SalidaID          uint            `json:"salida_id"`
+	Recursos          []SalidaRecurso `json:"recursos"`
+	CapacidadAsientos int             `json:"capacidad_asientos"`
+	Ocupacion         int             `json:"ocupacion"`
+	CupoMaximo        int             `json:"cupo_maximo"`
+	Advertencias      []string        `json:"advertencias"`
+}
+
+// OcupacionRecurso es un período en el que un recurso está asignado o en mantenimiento.
+type OcupacionRecurso struct {
+	RecursoID     uint    `json:"recurso_id" gorm:"column:recurso_id"`
+	Motivo        string  `json:"motivo" gorm:"column:motivo"`
+	SalidaID      *uint   `json:"salida_id,omitempty" gorm:"column:salida_id"`
+	PaqueteNombre *string `json:"paquete_nombre,omitempty" gorm:"column:paquete_nombre"`
+	FechaInicio   string  `json:"fecha_inicio" gorm:"column:fecha_inicio"`
+	FechaFin      string  `json:"fecha_fin" gorm:"column:fecha_fin"`
+	Horario       string  `json:"horario" gorm:"column:horario"`
+}
+
+// DisponibilidadRecurso es la agenda de un recurso en el rango consultado.
+type DisponibilidadRecurso struct {
+	Recurso     Recurso            `json:"recurso"`
+	Ocupaciones []OcupacionRecurso `json:"ocupaciones"`
+}
+
+// DisponibilidadDia resume los recursos libres de un día por tipo.
+type DisponibilidadDia struct {
+	Fecha               string         `json:"fecha"`
+	AsientosDisponibles int            `json:"asientos_disponibles"`
+	LibresPorTipo       map[string]int `json:"libres_por_tipo"`
+}
+
+// DisponibilidadRecursosResponse es la vista de disponibilidad de recursos por fechas.
+type DisponibilidadRecursosResponse struct {
+	Desde    string                  `json:"desde"`
+	Hasta    string                  `json:"hasta"`
+	Recursos []DisponibilidadRecurso `json:"recursos"`
+	Dias     []DisponibilidadDia     `json:"dias"`
+}
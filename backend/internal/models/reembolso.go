@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// PoliticaReembolsoTramo define el porcentaje reembolsable según los días de anticipación a la salida.
+// Se aplica el tramo con mayor DiasAntesMinimo que no supere los días restantes.
+// Tabla: politica_reembolso_tramos
+type PoliticaReembolsoTramo struct {
+	ID        uint `gorm:"primaryKey" json:"id"`
+	AgenciaID uint `gorm:"not null;index" json:"agencia_id"`
+
+	// Aplica si faltan al menos esta cantidad de días para la salida
+	DiasAntesMinimo     int     `gorm:"not null" json:"dias_antes_minimo"`
+	PorcentajeReembolso float64 `gorm:"type:decimal(5,2);not null" json:"porcentaje_reembolso"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (PoliticaReembolsoTramo) TableName() string {
+	return "politica_reembolso_tramos"
+}
+
+// Reembolso representa un monto a devolver al turista por una compra cancelada.
+// Tabla: reembolsos
+type Reembolso struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	CompraID  uint           `gorm:"not null;index" json:"compra_id"`
+	Compra    *CompraPaquete `gorm:"foreignKey:CompraID" json:"compra,omitempty"`
+	AgenciaID uint           `gorm:"not null;index" json:"agencia_id"`
+	TuristaID uint           `gorm:"not null;index" json:"turista_id"`
+
+	// cancelacion_turista | cancelacion_agencia | modificacion
+	Motivo string  `gorm:"size:30;not null" json:"motivo"`
+	Razon  *string `gorm:"type:text" json:"razon,omitempty"`
+
+	MontoPagado         float64 `gorm:"type:decimal(10,2);not null" json:"monto_pagado"`
+	PorcentajeReembolso float64 `gorm:"type:decimal(5,2);not null" json:"porcentaje_reembolso"`
+	MontoReembolso      float64 `gorm:"type:decimal(10,2);not null" json:"monto_reembolso"`
+	DiasAntesSalida     *int    `json:"dias_antes_salida,omitempty"`
+
+	// pendiente | procesando (devolución por pasarela en curso) | pagado | no_aplica
+	Estado string `gorm:"size:20;default:'pendiente';index" json:"estado"`
+
+	MetodoPago      *string    `gorm:"size:20" json:"metodo_pago,omitempty"`
+	ComprobanteFoto *string    `gorm:"type:text" json:"comprobante_foto,omitempty"`
+	PagadoPor       *uint      `gorm:"index" json:"pagado_por,omitempty"`
+	FechaPago       *time.Time `json:"fecha_pago,omitempty"`
+	NotasEncargado  *string    `gorm:"type:text" json:"notas_encargado,omitempty"`
+
+	// Referencias de las devoluciones en la pasarela, separadas por coma (metodo_pago = pasarela)
+	ReferenciaPasarela *string `gorm:"size:255" json:"referencia_pasarela,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Reembolso) TableName() string {
+	return "reembolsos"
+}
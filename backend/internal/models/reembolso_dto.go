@@ -0,0 +1,34 @@
+package models
+
+import "mime/multipart"
+
+// TramoReembolsoRequest representa un tramo de la política de reembolso.
+type TramoReembolsoRequest struct {
+	DiasAntesMinimo     int     `json:"dias_antes_minimo" validate:"min=0,max=365"`
+	PorcentajeReembolso float64 `json:"porcentaje_reembolso" validate:"min=0,max=100"`
+}
+
+// ActualizarTramosReembolsoRequest reemplaza la tabla de tramos de reembolso de una agencia.
+type ActualizarTramosReembolsoRequest struct {
+	Tramos []TramoReembolsoRequest `json:"tramos" validate:"dive"`
+}
+
+// CancelacionPreviewResponse muestra al turista cuánto se le reembolsaría si cancela hoy.
+type CancelacionPreviewResponse struct {
+	CompraID            uint    `json:"compra_id"`
+	Status              string  `json:"status"`
+	FechaSalida         string  `json:"fecha_salida"`
+	DiasAntesSalida     int     `json:"dias_antes_salida"`
+	MontoPagado         float64 `json:"monto_pagado"`
+	PorcentajeReembolso float64 `json:"porcentaje_reembolso"`
+	MontoReembolso      float64 `json:"monto_reembolso"`
+	RequiereReembolso   bool    `json:"requiere_reembolso"`
+}
+
+// PagarReembolsoRequest registra el pago de un reembolso por parte del encargado.
+type PagarReembolsoRequest struct {
+	// pasarela devuelve el monto por la pasarela en línea con la que se cobró la compra
+	MetodoPago     string                `validate:"required,oneof=efectivo qr transferencia pasarela"`
+	NotasEncargado *string               `validate:"-"`
+	Comprobante    *multipart.FileHeader `validate:"-"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// ReglaDescuento es un descuento automático configurado por la agencia.
+// Tabla: reglas_descuento
+//
+// Tipos:
+//   - grupo: aplica si las personas que pagan son al menos MinPersonas.
+//   - anticipacion: aplica si se reserva con al menos DiasAnticipacion días antes de la salida.
+//   - ultimo_minuto: aplica si se reserva con DiasAnticipacion días o menos antes de la salida;
+//     con SoloBajoCupoMinimo, solo en salidas compartidas que aún no alcanzan su cupo mínimo.
+//
+// Política de acumulación: las reglas se evalúan por Orden. Una regla no acumulable solo se aplica
+// si es la primera que cumple su condición y detiene la evaluación; las acumulables se aplican en cadena
+// sobre el precio restante. El código de promoción se aplica al final sobre el precio resultante.
+type ReglaDescuento struct {
+	ID        uint  `gorm:"primaryKey" json:"id"`
+	AgenciaID uint  `gorm:"not null;index" json:"agencia_id"`
+	PaqueteID *uint `gorm:"index" json:"paquete_id"` // NULL = todos los paquetes de la agencia
+
+	Nombre string `gorm:"size:100;not null" json:"nombre"`
+	Tipo   string `gorm:"size:20;not null" json:"tipo"`
+
+	MinPersonas        *int `json:"min_personas"`
+	DiasAnticipacion   *int `json:"dias_anticipacion"`
+	SoloBajoCupoMinimo bool `gorm:"default:false" json:"solo_bajo_cupo_minimo"`
+
+	Porcentaje float64 `gorm:"type:decimal(5,2);not null" json:"porcentaje"`
+	Orden      int     `gorm:"default:0" json:"orden"`
+	Acumulable bool    `gorm:"default:false" json:"acumulable"`
+	Activa     bool    `gorm:"default:true" json:"activa"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ReglaDescuento) TableName() string {
+	return "reglas_descuento"
+}
+
+// CompraDescuento registra cada descuento aplicado a una compra (reglas automáticas y código de promoción).
+// La suma de Monto coincide con CompraPaquete.DescuentoAplicado.
+// Tabla: compra_descuentos
+type CompraDescuento struct {
+	ID               uint  `gorm:"primaryKey" json:"id"`
+	CompraID         uint  `gorm:"not null;index" json:"compra_id"`
+	ReglaDescuentoID *uint `gorm:"index" json:"regla_descuento_id,omitempty"`
+	PromocionID      *uint `gorm:"index" json:"promocion_id,omitempty"`
+
+	// grupo | anticipacion | ultimo_minuto | promocion
+	Tipo       string  `gorm:"size:20;not null" json:"tipo"`
+	Nombre     string  `gorm:"size:100;not null" json:"nombre"`
+	Porcentaje float64 `gorm:"type:decimal(5,2);default:0" json:"porcentaje"`
+	Monto      float64 `gorm:"type:decimal(10,2);not null" json:"monto"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (CompraDescuento) TableName() string {
+	return "compra_descuentos"
+}
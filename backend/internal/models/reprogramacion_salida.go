@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// ReprogramacionSalida registra el cambio de fecha de una salida decidido por la agencia.
+// La salida original queda cancelada y sus compras pasan a SalidaDestinoID; cada turista debe
+// aceptar la nueva fecha antes de FechaLimiteRespuesta o su compra se cancela con reembolso total.
+// Tabla: reprogramaciones_salidas
+type ReprogramacionSalida struct {
+	ID              uint `gorm:"primaryKey" json:"id"`
+	AgenciaID       uint `gorm:"not null;index" json:"agencia_id"`
+	SalidaOrigenID  uint `gorm:"not null;index" json:"salida_origen_id"`
+	SalidaDestinoID uint `gorm:"not null;index" json:"salida_destino_id"`
+
+	FechaAnterior string `gorm:"type:date;not null" json:"fecha_anterior"`
+	FechaNueva    string `gorm:"type:date;not null" json:"fecha_nueva"`
+	Razon         string `gorm:"type:text;not null" json:"razon"`
+
+	FechaLimiteRespuesta time.Time `gorm:"not null;index" json:"fecha_limite_respuesta"`
+
+	// abierta | cerrada (todas las compras respondieron o vencieron)
+	Estado string `gorm:"size:20;default:'abierta';index" json:"estado"`
+
+	Compras []ReprogramacionCompra `gorm:"foreignKey:ReprogramacionID" json:"compras,omitempty"`
+
+	CreadaPorID uint      `json:"creada_por_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (ReprogramacionSalida) TableName() string {
+	return "reprogramaciones_salidas"
+}
+
+// ReprogramacionCompra es la respuesta de un turista a la reprogramación de su salida.
+// Tabla: reprogramaciones_compras
+type ReprogramacionCompra struct {
+	ID               uint                  `gorm:"primaryKey" json:"id"`
+	ReprogramacionID uint                  `gorm:"not null;index" json:"reprogramacion_id"`
+	Reprogramacion   *ReprogramacionSalida `gorm:"foreignKey:ReprogramacionID" json:"reprogramacion,omitempty"`
+	CompraID         uint                  `gorm:"not null;index" json:"compra_id"`
+	TuristaID        uint                  `gorm:"not null;index" json:"turista_id"`
+
+	// pendiente | aceptada | rechazada | vencida
+	Estado         string     `gorm:"size:20;default:'pendiente';index" json:"estado"`
+	FechaRespuesta *time.Time `json:"fecha_respuesta,omitempty"`
+	ReembolsoID    *uint      `json:"reembolso_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ReprogramacionCompra) TableName() string {
+	return "reprogramaciones_compras"
+}
@@ -0,0 +1,34 @@
+package models
+
+// ReprogramarSalidaRequest mueve una salida a otra fecha.
+type ReprogramarSalidaRequest struct {
+	NuevaFecha    string  `json:"nueva_fecha" validate:"required"` // YYYY-MM-DD
+	HoraEncuentro *string `json:"hora_encuentro"`                  // HH:MM, por defecto la de la salida original
+	Razon         string  `json:"razon" validate:"required,max=500"`
+	// Días que tienen los turistas para aceptar la nueva fecha (por defecto 3)
+	DiasRespuesta int `json:"dias_respuesta" validate:"omitempty,min=1,max=30"`
+}
+
+// ResponderReprogramacionRequest es la respuesta del turista a la nueva fecha.
+type ResponderReprogramacionRequest struct {
+	Acepta bool `json:"acepta"`
+}
+
+// ResumenCancelacionSalida resume el efecto de cancelar una salida sobre sus compras.
+type ResumenCancelacionSalida struct {
+	SalidaID          uint    `json:"salida_id"`
+	ComprasCanceladas int     `json:"compras_canceladas"`
+	Reembolsos        int     `json:"reembolsos"`
+	MontoReembolsos   float64 `json:"monto_reembolsos"`
+	PagosRechazados   int     `json:"pagos_rechazados"`
+	ListaEspera       int     `json:"lista_espera_cerrada"`
+}
+
+// ResultadoReprogramacionSalida es el resultado de reprogramar una salida.
+type ResultadoReprogramacionSalida struct {
+	Reprogramacion *ReprogramacionSalida    `json:"reprogramacion"`
+	SalidaDestino  *PaqueteSalidaHabilitada `json:"salida_destino"`
+	ComprasMovidas int                      `json:"compras_movidas"`
+	CuposMovidos   int                      `json:"cupos_movidos"`
+	ListaEspera    int                      `json:"lista_espera_cerrada"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Resena es la reseña verificada que un turista deja sobre una compra completada (una por compra).
+// Tabla: resenas
+type Resena struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	CompraID  uint     `gorm:"not null;uniqueIndex" json:"compra_id"`
+	TuristaID uint     `gorm:"not null;index" json:"turista_id"`
+	Turista   *Usuario `gorm:"foreignKey:TuristaID" json:"-"`
+	PaqueteID uint     `gorm:"not null;index" json:"paquete_id"`
+	AgenciaID uint     `gorm:"not null;index" json:"agencia_id"`
+
+	// Calificaciones de 1 a 5. Solo la general es obligatoria
+	CalificacionGeneral      int  `gorm:"not null" json:"calificacion_general"`
+	CalificacionGuia         *int `json:"calificacion_guia,omitempty"`
+	CalificacionPrecio       *int `json:"calificacion_precio,omitempty"` // relación calidad-precio
+	CalificacionOrganizacion *int `json:"calificacion_organizacion,omitempty"`
+
+	Comentario string       `gorm:"type:text" json:"comentario"`
+	Fotos      []ResenaFoto `gorm:"foreignKey:ResenaID" json:"fotos,omitempty"`
+
+	// Respuesta única de la agencia
+	RespuestaAgencia *string    `gorm:"type:text" json:"respuesta_agencia,omitempty"`
+	FechaRespuesta   *time.Time `json:"fecha_respuesta,omitempty"`
+	RespondidaPorID  *uint      `json:"respondida_por_id,omitempty"`
+
+	// Moderación (admin). Las reseñas ocultas no cuentan en el promedio
+	Oculta        bool    `gorm:"default:false;index" json:"oculta"`
+	RazonOculta   *string `gorm:"type:text" json:"razon_oculta,omitempty"`
+	OcultadaPorID *uint   `json:"ocultada_por_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Resena) TableName() string {
+	return "resenas"
+}
+
+// ResenaFoto es una foto adjunta a una reseña.
+// Tabla: resena_fotos
+type ResenaFoto struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	ResenaID uint   `gorm:"not null;index" json:"resena_id"`
+	Foto     string `gorm:"type:text;not null" json:"foto"`
+	Orden    int    `gorm:"default:0" json:"orden"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (ResenaFoto) TableName() string {
+	return "resena_fotos"
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// CrearResenaRequest crea la reseña de una compra completada.
+type CrearResenaRequest struct {
+	CalificacionGeneral      int    `json:"calificacion_general" validate:"required,min=1,max=5"`
+	CalificacionGuia         *int   `json:"calificacion_guia" validate:"omitempty,min=1,max=5"`
+	CalificacionPrecio       *int   `json:"calificacion_precio" validate:"omitempty,min=1,max=5"`
+	CalificacionOrganizacion *int   `json:"calificacion_organizacion" validate:"omitempty,min=1,max=5"`
+	Comentario               string `json:"comentario" validate:"max=2000"`
+}
+
+// ResponderResenaRequest es la respuesta (única) de la agencia a una reseña.
+type ResponderResenaRequest struct {
+	Respuesta string `json:"respuesta" validate:"required,max=2000"`
+}
+
+// ModerarResenaRequest oculta o vuelve a mostrar una reseña (admin).
+type ModerarResenaRequest struct {
+	Oculta bool    `json:"oculta"`
+	Razon  *string `json:"razon" validate:"omitempty,max=500"`
+}
+
+// ResenaPublicaResponse es una reseña visible en el catálogo público.
+type ResenaPublicaResponse struct {
+	ID                       uint         `json:"id"`
+	PaqueteID                uint         `json:"paquete_id"`
+	PaqueteNombre            string       `json:"paquete_nombre"`
+	AgenciaID                uint         `json:"agencia_id"`
+	TuristaNombre            string       `json:"turista_nombre"`
+	CalificacionGeneral      int          `json:"calificacion_general"`
+	CalificacionGuia         *int         `json:"calificacion_guia,omitempty"`
+	CalificacionPrecio       *int         `json:"calificacion_precio,omitempty"`
+	CalificacionOrganizacion *int         `json:"calificacion_organizacion,omitempty"`
+	Comentario               string       `json:"comentario"`
+	Fotos                    []ResenaFoto `json:"fotos"`
+	RespuestaAgencia         *string      `json:"respuesta_agencia,omitempty"`
+	FechaRespuesta           *time.Time   `json:"fecha_respuesta,omitempty"`
+	FechaSalida              time.Time    `json:"fecha_salida"`
+	CreatedAt                time.Time    `json:"created_at"`
+}
+
+// ResumenResenasResponse agrega las calificaciones visibles de un paquete o agencia.
+type ResumenResenasResponse struct {
+	Total                int64    `json:"total" gorm:"column:total"`
+	PromedioGeneral      float64  `json:"promedio_general" gorm:"column:promedio_general"`
+	PromedioGuia         *float64 `json:"promedio_guia" gorm:"column:promedio_guia"`
+	PromedioPrecio       *float64 `json:"promedio_precio" gorm:"column:promedio_precio"`
+	PromedioOrganizacion *float64 `json:"promedio_organizacion" gorm:"column:promedio_organizacion"`
+}
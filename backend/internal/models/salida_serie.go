@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// SalidaSerie es una regla de recurrencia que genera salidas compartidas de un paquete por adelantado.
+// Regla usa un subconjunto de RRULE (RFC 5545): FREQ=DAILY|WEEKLY, INTERVAL, BYDAY y BYMONTH.
+// Ejemplos: "FREQ=WEEKLY;BYDAY=SA", "FREQ=DAILY;BYDAY=TU,WE,TH,FR,SA,SU", "FREQ=WEEKLY;BYDAY=SA,SU;BYMONTH=5,6,7,8".
+// Tabla: salida_series
+type SalidaSerie struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	AgenciaID uint              `gorm:"not null;index" json:"agencia_id"`
+	PaqueteID uint              `gorm:"not null;index" json:"paquete_id"`
+	Paquete   *PaqueteTuristico `gorm:"foreignKey:PaqueteID" json:"paquete,omitempty"`
+
+	Nombre string `gorm:"size:100;not null" json:"nombre"`
+	Regla  string `gorm:"size:255;not null" json:"regla"`
+
+	FechaInicio string  `gorm:"type:date;not null" json:"fecha_inicio"`
+	FechaFin    *string `gorm:"type:date" json:"fecha_fin,omitempty"`
+
+	// Cuántas semanas hacia adelante se materializan las salidas
+	SemanasAnticipacion int `gorm:"default:8" json:"semanas_anticipacion"`
+
+	// Valores por defecto de las salidas generadas (0 / NULL = los del paquete)
+	CupoMinimo            int     `gorm:"default:0" json:"cupo_minimo"`
+	CupoMaximo            int     `gorm:"default:0" json:"cupo_maximo"`
+	PuntoEncuentro        *string `gorm:"type:text" json:"punto_encuentro,omitempty"`
+	HoraEncuentro         *string `gorm:"type:time" json:"hora_encuentro,omitempty"`
+	InstruccionesTuristas *string `gorm:"type:text" json:"instrucciones_turistas,omitempty"`
+	NotasInternas         *string `gorm:"type:text" json:"notas_internas,omitempty"`
+
+	Activa bool `gorm:"default:true;index" json:"activa"`
+	// Última fecha evaluada por el generador
+	GeneradaHasta *string `gorm:"type:date" json:"generada_hasta,omitempty"`
+
+	CreadaPorID *uint     `json:"creada_por_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (SalidaSerie) TableName() string {
+	return "salida_series"
+}
+
+// AgenciaFechaBloqueada es un período en el que la agencia no opera (todos sus paquetes o uno en particular).
+// No se crean salidas nuevas en esas fechas.
+// Tabla: agencia_fechas_bloqueadas
+type AgenciaFechaBloqueada struct {
+	ID        uint  `gorm:"primaryKey" json:"id"`
+	AgenciaID uint  `gorm:"not null;index" json:"agencia_id"`
+	PaqueteID *uint `gorm:"index" json:"paquete_id,omitempty"` // NULL = todos los paquetes
+
+	FechaInicio string  `gorm:"type:date;not null" json:"fecha_inicio"`
+	FechaFin    string  `gorm:"type:date;not null" json:"fecha_fin"`
+	Motivo      *string `gorm:"size:255" json:"motivo,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AgenciaFechaBloqueada) TableName() string {
+	return "agencia_fechas_bloqueadas"
+}
@@ -0,0 +1,73 @@
+package models
+
+// CrearSalidaSerieRequest define una serie de salidas recurrentes.
+type CrearSalidaSerieRequest struct {
+	PaqueteID           uint    `json:"paquete_id" validate:"required"`
+	Nombre              string  `json:"nombre" validate:"required,max=100"`
+	Regla               string  `json:"regla" validate:"required,max=255"`
+	FechaInicio         string  `json:"fecha_inicio" validate:"required"` // YYYY-MM-DD
+	FechaFin            *string `json:"fecha_fin"`                        // YYYY-MM-DD
+	SemanasAnticipacion int     `json:"semanas_anticipacion" validate:"omitempty,min=1,max=52"`
+
+	CupoMinimo            int     `json:"cupo_minimo" validate:"omitempty,min=1"`
+	CupoMaximo            int     `json:"cupo_maximo" validate:"omitempty,min=1"`
+	PuntoEncuentro        *string `json:"punto_encuentro"`
+	HoraEncuentro         *string `json:"hora_encuentro"` // HH:MM
+	InstruccionesTuristas *string `json:"instrucciones_turistas"`
+	NotasInternas         *string `json:"notas_internas"`
+}
+
+// ActualizarSalidaSerieRequest modifica una serie. Con AplicarAFuturas los cambios de cupos y logística
+// se copian a las salidas futuras de la serie; si cambia la regla se cancelan las futuras sin reservas
+// que ya no coinciden y se vuelve a generar.
+type ActualizarSalidaSerieRequest struct {
+	Nombre              *string `json:"nombre" validate:"omitempty,max=100"`
+	Regla               *string `json:"regla" validate:"omitempty,max=255"`
+	FechaFin            *string `json:"fecha_fin"` // "" = sin fin
+	SemanasAnticipacion *int    `json:"semanas_anticipacion" validate:"omitempty,min=1,max=52"`
+
+	CupoMinimo            *int    `json:"cupo_minimo" validate:"omitempty,min=0"`
+	CupoMaximo            *int    `json:"cupo_maximo" validate:"omitempty,min=0"`
+	PuntoEncuentro        *string `json:"punto_encuentro"`
+	HoraEncuentro         *string `json:"hora_encuentro"`
+	InstruccionesTuristas *string `json:"instrucciones_turistas"`
+	NotasInternas         *string `json:"notas_internas"`
+
+	AplicarAFuturas bool `json:"aplicar_a_futuras"`
+}
+
+// CancelarSalidaSerieRequest cancela las salidas de la serie desde una fecha (por defecto mañana) y,
+// sin Hasta, desactiva la serie.
+type CancelarSalidaSerieRequest struct {
+	Desde *string `json:"desde"` // YYYY-MM-DD
+	Hasta *string `json:"hasta"` // YYYY-MM-DD
+	Razon string  `json:"razon" validate:"required,max=500"`
+}
+
+// CrearFechaBloqueadaRequest registra un período sin operación.
+type CrearFechaBloqueadaRequest struct {
+	PaqueteID   *uint   `json:"paquete_id"`
+	FechaInicio string  `json:"fecha_inicio" validate:"required"`
+	FechaFin    string  `json:"fecha_fin" validate:"required"`
+	Motivo      *string `json:"motivo" validate:"omitempty,max=255"`
+}
+
+// OcurrenciaOmitida es una fecha de la regla para la que no se creó salida.
+type OcurrenciaOmitida struct {
+	Fecha  string `json:"fecha"`
+	Motivo string `json:"motivo"`
+}
+
+// ResultadoGeneracionSerie resume una ejecución del generador de una serie.
+type ResultadoGeneracionSerie struct {
+	SerieID  uint                `json:"serie_id"`
+	Creadas  []string            `json:"creadas"`
+	Omitidas []OcurrenciaOmitida `json:"omitidas"`
+}
+
+// ResultadoOperacionSerie resume una edición o cancelación masiva de salidas de la serie.
+type ResultadoOperacionSerie struct {
+	Actualizadas []uint              `json:"actualizadas"`
+	Canceladas   []uint              `json:"canceladas"`
+	Omitidas     []OcurrenciaOmitida `json:"omitidas"`
+}
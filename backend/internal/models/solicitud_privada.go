@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// SolicitudPrivada es el pedido de cotización de un tour privado que un turista envía a la agencia.
+// La agencia responde con un precio y condiciones con vencimiento; al aceptar la cotización se crea
+// la compra y una salida privada al precio cotizado.
+// Tabla: solicitudes_privadas
+type SolicitudPrivada struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	PaqueteID uint              `gorm:"not null;index" json:"paquete_id"`
+	Paquete   *PaqueteTuristico `gorm:"foreignKey:PaqueteID" json:"paquete,omitempty"`
+	AgenciaID uint              `gorm:"not null;index" json:"agencia_id"`
+	TuristaID uint              `gorm:"not null;index" json:"turista_id"`
+	Turista   *Usuario          `gorm:"foreignKey:TuristaID" json:"turista,omitempty"`
+
+	// Propuesta del turista
+	FechaPropuesta      time.Time   `gorm:"type:date;not null" json:"fecha_propuesta"`
+	FechasAlternativas  StringArray `gorm:"type:text[]" json:"fechas_alternativas"`
+	CantidadAdultos     int         `gorm:"not null" json:"cantidad_adultos"`
+	CantidadNinosPagan  int         `gorm:"default:0" json:"cantidad_ninos_pagan"`
+	CantidadNinosGratis int         `gorm:"default:0" json:"cantidad_ninos_gratis"`
+	TotalParticipantes  int         `gorm:"not null" json:"total_participantes"`
+	Extranjero          bool        `gorm:"default:false" json:"extranjero"`
+
+	NecesidadesEspeciales   *string `gorm:"type:text" json:"necesidades_especiales,omitempty"`
+	TieneDiscapacidad       bool    `gorm:"default:false" json:"tiene_discapacidad"`
+	DescripcionDiscapacidad *string `gorm:"type:text" json:"descripcion_discapacidad,omitempty"`
+
+	// pendiente | cotizada | aceptada | rechazada | cancelada | expirada
+	Estado string `gorm:"size:20;default:'pendiente';index" json:"estado"`
+
+	// Cotización vigente (la última enviada por la agencia)
+	FechaCotizada         *time.Time `gorm:"type:date" json:"fecha_cotizada,omitempty"`
+	PrecioCotizado        *float64   `gorm:"type:decimal(10,2)" json:"precio_cotizado,omitempty"`
+	CondicionesCotizacion *string    `gorm:"type:text" json:"condiciones_cotizacion,omitempty"`
+	CotizacionExpiraEn    *time.Time `gorm:"index" json:"cotizacion_expira_en,omitempty"`
+	CotizadaPorID         *uint      `json:"cotizada_por_id,omitempty"`
+	FechaCotizacion       *time.Time `json:"fecha_cotizacion,omitempty"`
+
+	// Resultado de la aceptación
+	CompraID        *uint      `gorm:"index" json:"compra_id,omitempty"`
+	SalidaID        *uint      `gorm:"index" json:"salida_id,omitempty"`
+	FechaAceptacion *time.Time `json:"fecha_aceptacion,omitempty"`
+
+	Mensajes []SolicitudPrivadaMensaje `gorm:"foreignKey:SolicitudID" json:"mensajes,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SolicitudPrivada) TableName() string {
+	return "solicitudes_privadas"
+}
+
+// SolicitudPrivadaMensaje es una entrada del hilo de negociación de una solicitud privada.
+// Tabla: solicitud_privada_mensajes
+type SolicitudPrivadaMensaje struct {
+	ID          uint `gorm:"primaryKey" json:"id"`
+	SolicitudID uint `gorm:"not null;index" json:"solicitud_id"`
+	AutorID     uint `gorm:"not null" json:"autor_id"`
+	// turista | agencia
+	AutorRol string `gorm:"size:20;not null" json:"autor_rol"`
+
+	// solicitud | mensaje | cotizacion | aceptacion | rechazo | cancelacion
+	Tipo    string  `gorm:"size:20;not null" json:"tipo"`
+	Mensaje *string `gorm:"type:text" json:"mensaje,omitempty"`
+
+	// Datos de la cotización (solo Tipo = cotizacion)
+	FechaCotizada  *time.Time `gorm:"type:date" json:"fecha_cotizada,omitempty"`
+	PrecioCotizado *float64   `gorm:"type:decimal(10,2)" json:"precio_cotizado,omitempty"`
+	ExpiraEn       *time.Time `json:"expira_en,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (SolicitudPrivadaMensaje) TableName() string {
+	return "solicitud_privada_mensajes"
+}
@@ -0,0 +1,43 @@
+package models
+
+// CrearSolicitudPrivadaRequest pide a la agencia una cotización para un tour privado.
+type CrearSolicitudPrivadaRequest struct {
+	PaqueteID           uint     `json:"paquete_id" validate:"required"`
+	FechaPropuesta      string   `json:"fecha_propuesta" validate:"required"` // YYYY-MM-DD
+	FechasAlternativas  []string `json:"fechas_alternativas" validate:"omitempty,max=5"`
+	CantidadAdultos     int      `json:"cantidad_adultos" validate:"required,min=1"`
+	CantidadNinosPagan  int      `json:"cantidad_ninos_pagan" validate:"min=0"`
+	CantidadNinosGratis int      `json:"cantidad_ninos_gratis" validate:"min=0"`
+	Extranjero          bool     `json:"extranjero"`
+
+	NecesidadesEspeciales   *string `json:"necesidades_especiales" validate:"omitempty,max=2000"`
+	TieneDiscapacidad       bool    `json:"tiene_discapacidad"`
+	DescripcionDiscapacidad *string `json:"descripcion_discapacidad"`
+	Mensaje                 *string `json:"mensaje" validate:"omitempty,max=2000"`
+}
+
+// CotizarSolicitudPrivadaRequest es la cotización de la agencia. Reemplaza a la anterior si existía.
+// FechaCotizada por defecto es la fecha propuesta; DiasValidez por defecto 3.
+type CotizarSolicitudPrivadaRequest struct {
+	FechaCotizada *string `json:"fecha_cotizada"` // YYYY-MM-DD
+	PrecioTotal   float64 `json:"precio_total" validate:"required,gt=0"`
+	Condiciones   *string `json:"condiciones" validate:"omitempty,max=4000"`
+	DiasValidez   int     `json:"dias_validez" validate:"omitempty,min=1,max=30"`
+	Mensaje       *string `json:"mensaje" validate:"omitempty,max=2000"`
+}
+
+// MensajeSolicitudPrivadaRequest agrega un mensaje al hilo de la solicitud.
+type MensajeSolicitudPrivadaRequest struct {
+	Mensaje string `json:"mensaje" validate:"required,max=2000"`
+}
+
+// CerrarSolicitudPrivadaRequest rechaza (agencia) o cancela (turista) la solicitud.
+type CerrarSolicitudPrivadaRequest struct {
+	Motivo *string `json:"motivo" validate:"omitempty,max=2000"`
+}
+
+// AceptarSolicitudPrivadaRequest acepta la cotización vigente y crea la compra.
+type AceptarSolicitudPrivadaRequest struct {
+	NotasTurista  *string               `json:"notas_turista"`
+	Participantes []ParticipanteRequest `json:"participantes" validate:"omitempty,dive"`
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+type AsistenciaService struct {
+	db *gorm.DB
+}
+
+func NewAsistenciaService(db *gorm.DB) *AsistenciaService {
+	return &AsistenciaService{db: db}
+}
+
+// BloquearSalidaAgencia obtiene y bloquea una salida de la agencia dentro de la transacción. Los cupos de la
+// salida bloqueada solo se modifican con InventarioService.
+func BloquearSalidaAgencia(tx *gorm.DB, agenciaID uint, salidaID uint) (*models.PaqueteSalidaHabilitada, error) {
+	var salida models.PaqueteSalidaHabilitada
+	if err := tx.Raw(`
+		SELECT s.*
+		FROM paquete_salidas_habilitadas s
+		JOIN paquetes_turisticos p ON p.id = s.paquete_id
+		WHERE s.id = ? AND p.agencia_id = ?
+		FOR UPDATE OF s
+	`, salidaID, agenciaID).Scan(&salida).Error; err != nil {
+		return nil, err
+	}
+	if salida.ID == 0 {
+		return nil, errors.New("salida no encontrada")
+	}
+	return &salida, nil
+}
+
+// iniciarSalida pasa la salida de activa a en_curso.
+func iniciarSalida(tx *gorm.DB, salida *models.PaqueteSalidaHabilitada, now time.Time) error {
+	if salida.Estado == "en_curso" {
+		return nil
+	}
+	if salida.Estado != "activa" {
+		return fmt.Errorf("la salida debe estar activa para iniciarla (estado actual: %s)", salida.Estado)
+	}
+	if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(map[string]interface{}{
+		"estado":            "en_curso",
+		"fecha_inicio_real": now,
+		"updated_at":        now,
+	}).Error; err != nil {
+		return err
+	}
+	salida.Estado = "en_curso"
+	salida.FechaInicioReal = &now
+	return nil
+}
+
+// RegistrarCheckIn registra la asistencia de una compra confirmada identificada por el QR del voucher
+// o por su código de confirmación. El primer check-in inicia la salida (activa -> en_curso).
+func (s *AsistenciaService) RegistrarCheckIn(agenciaID uint, salidaID uint, req *models.CheckInRequest) (*models.AsistenciaCompraResponse, error) {
+	var compraID uint
+	var codigo string
+	switch {
+	case req.Token != nil && strings.TrimSpace(*req.Token) != "":
+		claims, err := utils.ValidateVoucherToken(strings.TrimSpace(*req.Token))
+		if err != nil {
+			return nil, errors.New("token de voucher inválido o vencido")
+		}
+		compraID = claims.CompraID
+		codigo = claims.CodigoConfirmacion
+	case req.CodigoConfirmacion != nil && strings.TrimSpace(*req.CodigoConfirmacion) != "":
+		codigo = strings.ToUpper(strings.TrimSpace(*req.CodigoConfirmacion))
+	default:
+		return nil, errors.New("debe enviar el token del voucher o el codigo_confirmacion")
+	}
+
+	var compraRegistrada uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		salida, err := BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil {
+			return err
+		}
+		if salida.Estado != "activa" && salida.Estado != "en_curso" {
+			return fmt.Errorf("no se puede registrar asistencia en una salida %s", salida.Estado)
+		}
+
+		var compra models.CompraPaquete
+		query := `SELECT * FROM compras_paquetes WHERE codigo_confirmacion = ?`
+		args := []interface{}{codigo}
+		if compraID > 0 {
+			query += ` AND id = ?`
+			args = append(args, compraID)
+		}
+		if err := tx.Raw(query+` FOR UPDATE`, args...).Scan(&compra).Error; err != nil {
+			return err
+		}
+		if compra.ID == 0 {
+			return errors.New("compra no encontrada para el código indicado")
+		}
+		if compra.SalidaID == nil || *compra.SalidaID != salida.ID {
+			return errors.New("la compra no pertenece a esta salida")
+		}
+		if compra.Status != "confirmada" {
+			return fmt.Errorf("la compra no está confirmada (estado: %s)", compra.Status)
+		}
+
+		var participantes []models.CompraParticipante
+		if err := tx.Where("compra_id = ?", compra.ID).Order("id ASC").Find(&participantes).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		presentes := compra.TotalParticipantes
+		if len(participantes) > 0 {
+			marcas := map[uint]bool{}
+			if len(req.Participantes) == 0 {
+				for _, p := range participantes {
+					marcas[p.ID] = true
+				}
+			} else {
+				indice := map[uint]bool{}
+				for _, p := range participantes {
+					indice[p.ID] = true
+				}
+				for _, m := range req.Participantes {
+					if !indice[m.ParticipanteID] {
+						return fmt.Errorf("el participante %d no pertenece a la compra", m.ParticipanteID)
+					}
+					marcas[m.ParticipanteID] = m.Presente
+				}
+			}
+
+			for id, presente := range marcas {
+				asistencia := "ausente"
+				if presente {
+					asistencia = "presente"
+				}
+				if err := tx.Model(&models.CompraParticipante{}).Where("id = ?", id).Updates(map[string]interface{}{
+					"asistencia":     asistencia,
+					"fecha_check_in": now,
+					"updated_at":     now,
+				}).Error; err != nil {
+					return err
+				}
+			}
+
+			// Los participantes que ya estaban marcados y no se enviaron conservan su marca
+			var count int64
+			if err := tx.Model(&models.CompraParticipante{}).
+				Where("compra_id = ? AND asistencia = 'presente'", compra.ID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			presentes = int(count)
+
+			// Manifiesto incompleto: Presentes define el total; si no se envía, los no registrados llegan con el grupo
+			if faltantes := compra.TotalParticipantes - len(participantes); faltantes > 0 {
+				if req.Presentes != nil {
+					if *req.Presentes < presentes || *req.Presentes > compra.TotalParticipantes {
+						return fmt.Errorf("presentes debe estar entre %d y %d", presentes, compra.TotalParticipantes)
+					}
+					presentes = *req.Presentes
+				} else if presentes > 0 {
+					presentes += faltantes
+				}
+			}
+		} else if req.Presentes != nil {
+			if *req.Presentes > compra.TotalParticipantes {
+				return fmt.Errorf("presentes no puede superar el total de participantes (%d)", compra.TotalParticipantes)
+			}
+			presentes = *req.Presentes
+		}
+
+		if err := tx.Model(&models.CompraPaquete{}).Where("id = ?", compra.ID).Updates(map[string]interface{}{
+			"check_in_at":             now,
+			"participantes_presentes": presentes,
+			"no_show":                 presentes == 0,
+			"updated_at":              now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := iniciarSalida(tx, salida, now); err != nil {
+			return err
+		}
+
+		compraRegistrada = compra.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.ObtenerAsistenciaSalida(agenciaID, salidaID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.Compras {
+		if resp.Compras[i].CompraID == compraRegistrada {
+			return &resp.Compras[i], nil
+		}
+	}
+	return nil, errors.New("compra no encontrada")
+}
+
+// IniciarSalida marca la salida como en curso sin registrar asistencia.
+func (s *AsistenciaService) IniciarSalida(agenciaID uint, salidaID uint) (*models.AsistenciaSalidaResponse, error) {
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		salida, err := BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil {
+			return err
+		}
+		if salida.Estado == "en_curso" {
+			return errors.New("la salida ya está en curso")
+		}
+		return iniciarSalida(tx, salida, time.Now())
+	}); err != nil {
+		return nil, err
+	}
+	return s.ObtenerAsistenciaSalida(agenciaID, salidaID)
+}
+
+// CompletarSalida cierra la salida: las compras confirmadas sin check-in quedan como no-show
+// y los participantes sin marca como ausentes.
+func (s *AsistenciaService) CompletarSalida(agenciaID uint, salidaID uint) (*models.AsistenciaSalidaResponse, error) {
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		salida, err := BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil {
+			return err
+		}
+		if salida.Estado != "activa" && salida.Estado != "en_curso" {
+			return fmt.Errorf("no se puede completar una salida %s", salida.Estado)
+		}
+
+		now := time.Now()
+		if err := tx.Exec(`
+			UPDATE compras_participantes
+			SET asistencia = 'ausente',
+			    fecha_check_in = ?,
+			    updated_at = ?
+			WHERE asistencia IS NULL
+			  AND compra_id IN (
+			      SELECT id FROM compras_paquetes WHERE salida_id = ? AND status = 'confirmada'
+			  )
+		`, now, now, salida.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.CompraPaquete{}).
+			Where("salida_id = ? AND status = 'confirmada' AND participantes_presentes IS NULL", salida.ID).
+			Updates(map[string]interface{}{
+				"participantes_presentes": 0,
+				"no_show":                 true,
+				"updated_at":              now,
+			}).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"estado":         "completada",
+			"fecha_fin_real": now,
+			"updated_at":     now,
+		}
+		if salida.FechaInicioReal == nil {
+			updates["fecha_inicio_real"] = now
+		}
+		return tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(updates).Error
+	}); err != nil {
+		return nil, err
+	}
+	return s.ObtenerAsistenciaSalida(agenciaID, salidaID)
+}
+
+// ObtenerAsistenciaSalida retorna el estado de asistencia de las compras confirmadas de la salida.
+func (s *AsistenciaService) ObtenerAsistenciaSalida(agenciaID uint, salidaID uint) (*models.AsistenciaSalidaResponse, error) {
+	var salida models.PaqueteSalidaHabilitada
+	if err := s.db.Joins("JOIN paquetes_turisticos ON paquete_salidas_habilitadas.paquete_id = paquetes_turisticos.id").
+		Where("paquete_salidas_habilitadas.id = ? AND paquetes_turisticos.agencia_id = ?", salidaID, agenciaID).
+		First(&salida).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("salida no encontrada")
+		}
+		return nil, err
+	}
+
+	var compras []models.CompraPaquete
+	if err := s.db.
+		Preload("Turista").
+		Preload("Participantes", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Where("salida_id = ? AND status = 'confirmada'", salida.ID).
+		Order("id ASC").
+		Find(&compras).Error; err != nil {
+		return nil, err
+	}
+
+	resp := &models.AsistenciaSalidaResponse{
+		SalidaID:        salida.ID,
+		Estado:          salida.Estado,
+		FechaInicioReal: salida.FechaInicioReal,
+		FechaFinReal:    salida.FechaFinReal,
+		Compras:         make([]models.AsistenciaCompraResponse, 0, len(compras)),
+	}
+
+	for _, compra := range compras {
+		nombre := ""
+		if compra.Turista != nil {
+			nombre = strings.TrimSpace(compra.Turista.Nombre + " " + compra.Turista.ApellidoPaterno)
+		}
+		participantes := compra.Participantes
+		if participantes == nil {
+			participantes = []models.CompraParticipante{}
+		}
+
+		resp.Esperados += compra.TotalParticipantes
+		if compra.ParticipantesPresentes == nil {
+			resp.Pendientes += compra.TotalParticipantes
+		} else {
+			resp.Presentes += *compra.ParticipantesPresentes
+		}
+		if compra.NoShow {
+			resp.NoShows++
+		}
+
+		resp.Compras = append(resp.Compras, models.AsistenciaCompraResponse{
+			CompraID:               compra.ID,
+			CodigoConfirmacion:     compra.CodigoConfirmacion,
+			TuristaNombre:          nombre,
+			TotalParticipantes:     compra.TotalParticipantes,
+			ParticipantesPresentes: compra.ParticipantesPresentes,
+			NoShow:                 compra.NoShow,
+			CheckInAt:              compra.CheckInAt,
+			Participantes:          participantes,
+		})
+	}
+
+	return resp, nil
+}
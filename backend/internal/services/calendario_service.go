@@ -0,0 +1,324 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrCalendarioNoEncontrado = errors.New("calendario no encontrado")
+
+type CalendarioService struct {
+	db *gorm.DB
+}
+
+func NewCalendarioService(db *gorm.DB) *CalendarioService {
+	return &CalendarioService{db: db}
+}
+
+func generarTokenCalendario() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// obtenerFeed retorna el feed del dueño indicado, creándolo si no existe. Con regenerar, rota el token.
+func (s *CalendarioService) obtenerFeed(tipo string, columna string, duenoID uint, regenerar bool) (*models.CalendarioFeed, error) {
+	var feed models.CalendarioFeed
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(`SELECT * FROM calendario_feeds WHERE `+columna+` = ? FOR UPDATE`, duenoID).Scan(&feed).Error
+		if err != nil {
+			return err
+		}
+		if feed.ID != 0 && !regenerar {
+			return nil
+		}
+
+		token, err := generarTokenCalendario()
+		if err != nil {
+			return err
+		}
+		if feed.ID != 0 {
+			feed.Token = token
+			return tx.Model(&models.CalendarioFeed{}).Where("id = ?", feed.ID).Updates(map[string]interface{}{
+				"token":      token,
+				"updated_at": time.Now(),
+			}).Error
+		}
+
+		feed = models.CalendarioFeed{Tipo: tipo, Token: token}
+		id := duenoID
+		if tipo == "agencia" {
+			feed.AgenciaID = &id
+		} else {
+			feed.UsuarioID = &id
+		}
+		return tx.Create(&feed).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// ObtenerFeedAgencia retorna (o crea) el feed de salidas de la agencia.
+func (s *CalendarioService) ObtenerFeedAgencia(agenciaID uint, regenerar bool) (*models.CalendarioFeed, error) {
+	return s.obtenerFeed("agencia", "agencia_id", agenciaID, regenerar)
+}
+
+// ObtenerFeedTurista retorna (o crea) el feed de compras confirmadas del usuario.
+func (s *CalendarioService) ObtenerFeedTurista(usuarioID uint, regenerar bool) (*models.CalendarioFeed, error) {
+	return s.obtenerFeed("turista", "usuario_id", usuarioID, regenerar)
+}
+
+func (s *CalendarioService) feedPorToken(tipo string, token string) (*models.CalendarioFeed, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrCalendarioNoEncontrado
+	}
+	var feed models.CalendarioFeed
+	if err := s.db.Where("token = ? AND tipo = ?", token, tipo).First(&feed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCalendarioNoEncontrado
+		}
+		return nil, err
+	}
+	// El registro del acceso es informativo: si falla, el calendario se entrega igual
+	if err := s.db.Model(&models.CalendarioFeed{}).Where("id = ?", feed.ID).Update("ultimo_acceso", time.Now()).Error; err != nil {
+		log.Printf("Error registrando acceso al calendario %d: %v", feed.ID, err)
+	}
+	return &feed, nil
+}
+
+// eventoSalida arma el rango del evento: con hora de encuentro en salidas de un día es un evento con hora;
+// en otro caso ocupa los días completos de la duración del paquete.
+func eventoSalida(fecha string, horaEncuentro *string, paquete *models.PaqueteTuristico) (time.Time, time.Time, bool, error) {
+	dia, err := time.Parse("2006-01-02", fechaSalidaString(fecha))
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	duracionDias := 1
+	if paquete != nil && paquete.DuracionDias != nil && *paquete.DuracionDias > 1 {
+		duracionDias = *paquete.DuracionDias
+	}
+
+	if duracionDias == 1 && horaEncuentro != nil && len(*horaEncuentro) >= 5 {
+		hora, err := time.Parse("15:04", (*horaEncuentro)[:5])
+		if err == nil {
+			inicio := time.Date(dia.Year(), dia.Month(), dia.Day(), hora.Hour(), hora.Minute(), 0, 0, utils.ICalZona)
+			fin := inicio.Add(4 * time.Hour)
+			if paquete != nil && paquete.Horario != nil && *paquete.Horario == "todo_dia" {
+				fin = inicio.Add(8 * time.Hour)
+			}
+			return inicio, fin, false, nil
+		}
+	}
+	return dia, dia.AddDate(0, 0, duracionDias), true, nil
+}
+
+// FeedAgenciaICS genera el calendario de salidas de la agencia (desde 30 días atrás hasta un año adelante).
+func (s *CalendarioService) FeedAgenciaICS(token string) ([]byte, error) {
+	feed, err := s.feedPorToken("agencia", token)
+	if err != nil {
+		return nil, err
+	}
+
+	var agencia models.AgenciaTurismo
+	if err := s.db.Select("id", "nombre_comercial").First(&agencia, *feed.AgenciaID).Error; err != nil {
+		return nil, err
+	}
+
+	hoy := fechaHoyUTC()
+	var salidas []models.PaqueteSalidaHabilitada
+	if err := s.db.
+		Preload("Guias", func(db *gorm.DB) *gorm.DB {
+			return db.Order("CASE WHEN rol = 'principal' THEN 0 ELSE 1 END").Order("id ASC")
+		}).
+		Preload("Guias.Guia").
+		Joins("JOIN paquetes_turisticos ON paquetes_turisticos.id = paquete_salidas_habilitadas.paquete_id").
+		Where("paquetes_turisticos.agencia_id = ?", agencia.ID).
+		Where("paquete_salidas_habilitadas.fecha_salida BETWEEN ? AND ?", hoy.AddDate(0, 0, -30).Format("2006-01-02"), hoy.AddDate(1, 0, 0).Format("2006-01-02")).
+		Order("paquete_salidas_habilitadas.fecha_salida ASC").
+		Find(&salidas).Error; err != nil {
+		return nil, err
+	}
+
+	paquetes := map[uint]*models.PaqueteTuristico{}
+	if len(salidas) > 0 {
+		ids := []uint{}
+		for _, salida := range salidas {
+			ids = append(ids, salida.PaqueteID)
+		}
+		var lista []models.PaqueteTuristico
+		if err := s.db.Select("id", "nombre", "duracion_dias", "horario").Where("id IN ?", ids).Find(&lista).Error; err != nil {
+			return nil, err
+		}
+		for i := range lista {
+			paquetes[lista[i].ID] = &lista[i]
+		}
+	}
+
+	eventos := make([]utils.ICalEvent, 0, len(salidas))
+	for _, salida := range salidas {
+		paquete := paquetes[salida.PaqueteID]
+		inicio, fin, todoElDia, err := eventoSalida(salida.FechaSalida, salida.HoraEncuentro, paquete)
+		if err != nil {
+			continue
+		}
+		nombre := "Salida"
+		if paquete != nil {
+			nombre = paquete.Nombre
+		}
+		ocupados := salida.CuposReservados + salida.CuposConfirmados
+
+		lineas := []string{
+			fmt.Sprintf("Estado: %s", salida.Estado),
+			fmt.Sprintf("Tipo: %s", salida.TipoSalida),
+			fmt.Sprintf("Cupos: %d confirmados, %d reservados, %d máximo (mínimo %d)", salida.CuposConfirmados, salida.CuposReservados, salida.CupoMaximo, salida.CupoMinimo),
+		}
+		guias := []string{}
+		for _, asignacion := range salida.Guias {
+			if asignacion.Guia == nil {
+				continue
+			}
+			texto := asignacion.Guia.Nombre
+			if asignacion.Guia.Telefono != nil {
+				texto += " (" + *asignacion.Guia.Telefono + ")"
+			}
+			guias = append(guias, texto)
+		}
+		if len(guias) == 0 && salida.GuiaNombre != nil {
+			texto := *salida.GuiaNombre
+			if salida.GuiaTelefono != nil {
+				texto += " (" + *salida.GuiaTelefono + ")"
+			}
+			guias = append(guias, texto)
+		}
+		if len(guias) > 0 {
+			lineas = append(lineas, "Guías: "+strings.Join(guias, ", "))
+		}
+		if salida.PuntoEncuentro != nil {
+			lineas = append(lineas, "Punto de encuentro: "+*salida.PuntoEncuentro)
+		}
+		if salida.HoraEncuentro != nil {
+			lineas = append(lineas, "Hora de encuentro: "+*salida.HoraEncuentro)
+		}
+		if salida.NotasLogistica != nil {
+			lineas = append(lineas, "Logística: "+*salida.NotasLogistica)
+		}
+
+		lugar := ""
+		if salida.PuntoEncuentro != nil {
+			lugar = *salida.PuntoEncuentro
+		}
+		eventos = append(eventos, utils.ICalEvent{
+			UID:         fmt.Sprintf("salida-%d@andaria", salida.ID),
+			Inicio:      inicio,
+			Fin:         fin,
+			TodoElDia:   todoElDia,
+			Resumen:     fmt.Sprintf("%s (%d/%d)", nombre, ocupados, salida.CupoMaximo),
+			Descripcion: strings.Join(lineas, "\n"),
+			Lugar:       lugar,
+			Cancelado:   salida.Estado == "cancelada",
+			Modificado:  salida.UpdatedAt,
+		})
+	}
+
+	return utils.BuildICalendar("Salidas - "+agencia.NombreComercial, eventos), nil
+}
+
+// FeedTuristaICS genera el calendario de las compras confirmadas del turista.
+func (s *CalendarioService) FeedTuristaICS(token string) ([]byte, error) {
+	feed, err := s.feedPorToken("turista", token)
+	if err != nil {
+		return nil, err
+	}
+
+	var compras []models.CompraPaquete
+	if err := s.db.
+		Preload("Paquete", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "agencia_id", "nombre", "duracion_dias", "horario")
+		}).
+		Preload("Paquete.Agencia", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "nombre_comercial", "telefono")
+		}).
+		Preload("Salida").
+		Where("turista_id = ? AND status = ?", *feed.UsuarioID, "confirmada").
+		Where("fecha_seleccionada >= ?", fechaHoyUTC().AddDate(0, 0, -30).Format("2006-01-02")).
+		Order("fecha_seleccionada ASC").
+		Find(&compras).Error; err != nil {
+		return nil, err
+	}
+
+	eventos := make([]utils.ICalEvent, 0, len(compras))
+	for _, compra := range compras {
+		fecha := compra.FechaSeleccionada.Format("2006-01-02")
+		var horaEncuentro, puntoEncuentro, instrucciones *string
+		cancelada := false
+		if compra.Salida != nil {
+			horaEncuentro = compra.Salida.HoraEncuentro
+			puntoEncuentro = compra.Salida.PuntoEncuentro
+			instrucciones = compra.Salida.InstruccionesTuristas
+			cancelada = compra.Salida.Estado == "cancelada"
+		}
+		inicio, fin, todoElDia, err := eventoSalida(fecha, horaEncuentro, compra.Paquete)
+		if err != nil {
+			continue
+		}
+
+		nombre := "Tour"
+		lineas := []string{}
+		if compra.Paquete != nil {
+			nombre = compra.Paquete.Nombre
+			if compra.Paquete.Agencia != nil {
+				lineas = append(lineas, fmt.Sprintf("Agencia: %s (%s)", compra.Paquete.Agencia.NombreComercial, compra.Paquete.Agencia.Telefono))
+			}
+		}
+		if compra.CodigoConfirmacion != nil {
+			lineas = append(lineas, "Código de confirmación: "+*compra.CodigoConfirmacion)
+		}
+		lineas = append(lineas, fmt.Sprintf("Participantes: %d", compra.TotalParticipantes))
+		if puntoEncuentro != nil {
+			lineas = append(lineas, "Punto de encuentro: "+*puntoEncuentro)
+		}
+		if horaEncuentro != nil {
+			lineas = append(lineas, "Hora de encuentro: "+*horaEncuentro)
+		}
+		if instrucciones != nil {
+			lineas = append(lineas, "Instrucciones: "+*instrucciones)
+		}
+
+		lugar := ""
+		if puntoEncuentro != nil {
+			lugar = *puntoEncuentro
+		}
+		modificado := compra.UpdatedAt
+		if compra.Salida != nil && compra.Salida.UpdatedAt.After(modificado) {
+			modificado = compra.Salida.UpdatedAt
+		}
+		eventos = append(eventos, utils.ICalEvent{
+			UID:         fmt.Sprintf("compra-%d@andaria", compra.ID),
+			Inicio:      inicio,
+			Fin:         fin,
+			TodoElDia:   todoElDia,
+			Resumen:     nombre,
+			Descripcion: strings.Join(lineas, "\n"),
+			Lugar:       lugar,
+			Cancelado:   cancelada,
+			Modificado:  modificado,
+		})
+	}
+
+	return utils.BuildICalendar("Mis tours", eventos), nil
+}
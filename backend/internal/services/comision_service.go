@@ -0,0 +1,885 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Cuentas del libro de comisiones; cada movimiento pertenece a la cuenta de una agencia.
+const (
+	CuentaCobrosAgencia     = "cobros_agencia"      // cobrado por la agencia a los turistas
+	CuentaVentasBrutas      = "ventas_brutas"       // ventas confirmadas de la agencia
+	CuentaComisionPorCobrar = "comision_por_cobrar" // lo que la agencia adeuda a la plataforma
+	CuentaIngresosComision  = "ingresos_comision"   // comisión ganada por la plataforma
+	CuentaAjustesComision   = "ajustes_comision"    // ajustes manuales del admin
+	CuentaBancoPlataforma   = "banco_plataforma"    // pagos de comisiones recibidos
+)
+
+// Tipos de asiento del libro de comisiones.
+const (
+	AsientoComisionVenta        = "venta"
+	AsientoComisionModificacion = "modificacion"
+	AsientoComisionReembolso    = "reembolso"
+	AsientoComisionAjuste       = "ajuste"
+	AsientoComisionPago         = "pago"
+)
+
+// Compras confirmadas sin asiento (o con un precio distinto al registrado) revisadas por pasada del worker
+const maxVentasSincronizacion = 200
+
+var (
+	ErrTarifaComisionNoEncontrada = errors.New("tarifa de comisión no encontrada")
+	ErrLiquidacionNoEncontrada    = errors.New("liquidación no encontrada")
+	ErrSinMovimientosPorLiquidar  = errors.New("no hay movimientos por liquidar en el período")
+)
+
+type ComisionService struct {
+	db *gorm.DB
+}
+
+func NewComisionService(db *gorm.DB) *ComisionService {
+	return &ComisionService{db: db}
+}
+
+// PorcentajeComisionDefecto es la comisión cuando no hay una tarifa para la agencia ni para la categoría
+// del paquete (COMISION_PORCENTAJE_DEFECTO, 10% si no se define).
+func PorcentajeComisionDefecto() float64 {
+	valor, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("COMISION_PORCENTAJE_DEFECTO")), 64)
+	if err != nil || valor < 0 || valor > 100 {
+		return 10
+	}
+	return valor
+}
+
+// inicioComisiones es la fecha desde la que las ventas confirmadas generan comisión (COMISIONES_DESDE,
+// YYYY-MM-DD); las compras confirmadas antes no entran al libro. Sin definir, todas generan comisión.
+func inicioComisiones() *time.Time {
+	valor := strings.TrimSpace(os.Getenv("COMISIONES_DESDE"))
+	if valor == "" {
+		return nil
+	}
+	fecha, err := time.Parse("2006-01-02", valor)
+	if err != nil {
+		return nil
+	}
+	return &fecha
+}
+
+// parseFechaContable interpreta una fecha YYYY-MM-DD opcional (hoy si no se indica); no admite fechas futuras.
+func parseFechaContable(valor *string) (time.Time, error) {
+	hoy := fechaHoyUTC()
+	if valor == nil || strings.TrimSpace(*valor) == "" {
+		return hoy, nil
+	}
+	fecha, err := time.Parse("2006-01-02", strings.TrimSpace(*valor))
+	if err != nil {
+		return time.Time{}, errors.New("fecha inválida (use YYYY-MM-DD)")
+	}
+	if fecha.After(hoy) {
+		return time.Time{}, errors.New("la fecha no puede ser futura")
+	}
+	return fecha, nil
+}
+
+// partida carga monto al debe de una cuenta y al haber de la otra; un monto negativo invierte las cuentas.
+func partida(cuentaDebe, cuentaHaber string, monto float64) []models.MovimientoComision {
+	monto = redondearMonto(monto)
+	if monto == 0 {
+		return nil
+	}
+	if monto < 0 {
+		cuentaDebe, cuentaHaber, monto = cuentaHaber, cuentaDebe, -monto
+	}
+	return []models.MovimientoComision{
+		{Cuenta: cuentaDebe, Debe: monto},
+		{Cuenta: cuentaHaber, Haber: monto},
+	}
+}
+
+// registrarAsiento guarda el asiento con sus movimientos si cuadran; un asiento sin montos no se guarda.
+func registrarAsiento(tx *gorm.DB, asiento *models.AsientoComision, movimientos ...[]models.MovimientoComision) error {
+	var debe, haber float64
+	asiento.Movimientos = nil
+	for _, grupo := range movimientos {
+		for _, m := range grupo {
+			m.AgenciaID = asiento.AgenciaID
+			debe += m.Debe
+			haber += m.Haber
+			asiento.Movimientos = append(asiento.Movimientos, m)
+		}
+	}
+	if math.Abs(debe-haber) > 0.005 {
+		return fmt.Errorf("asiento descuadrado: debe %.2f, haber %.2f", debe, haber)
+	}
+	if len(asiento.Movimientos) == 0 {
+		return nil
+	}
+	return tx.Create(asiento).Error
+}
+
+// categoriaPaquete es la categoría predominante entre las atracciones del paquete (la subcategoría principal de
+// cada atracción cuenta doble; a igualdad, la de la primera visita). nil si el paquete no tiene atracciones.
+func categoriaPaquete(tx *gorm.DB, paqueteID uint) (*uint, error) {
+	var categorias []uint
+	if err := tx.Raw(`
+		SELECT sc.categoria_id
+		FROM paquete_atracciones pa
+		JOIN atraccion_subcategorias ats ON ats.atraccion_id = pa.atraccion_id
+		JOIN subcategorias_atracciones sc ON sc.id = ats.subcategoria_id
+		WHERE pa.paquete_id = ?
+		GROUP BY sc.categoria_id
+		ORDER BY SUM(CASE WHEN ats.es_principal THEN 2 ELSE 1 END) DESC,
+			MIN(COALESCE(pa.dia_numero, 0)), MIN(pa.orden_visita), sc.categoria_id
+		LIMIT 1
+	`, paqueteID).Scan(&categorias).Error; err != nil {
+		return nil, err
+	}
+	if len(categorias) == 0 {
+		return nil, nil
+	}
+	return &categorias[0], nil
+}
+
+// tarifaAplicable elige la tarifa más específica: agencia y categoría, agencia, categoría. nil si ninguna aplica.
+func tarifaAplicable(tx *gorm.DB, agenciaID uint, categoriaID *uint) (*models.TarifaComision, error) {
+	var tarifas []models.TarifaComision
+	if err := tx.Raw(`
+		SELECT * FROM tarifas_comision
+		WHERE (agencia_id = ? OR agencia_id IS NULL)
+			AND (categoria_id = ? OR categoria_id IS NULL)
+			AND (agencia_id IS NOT NULL OR categoria_id IS NOT NULL)
+		ORDER BY (agencia_id IS NOT NULL) DESC, (categoria_id IS NOT NULL) DESC
+		LIMIT 1
+	`, agenciaID, categoriaID).Scan(&tarifas).Error; err != nil {
+		return nil, err
+	}
+	if len(tarifas) == 0 {
+		return nil, nil
+	}
+	return &tarifas[0], nil
+}
+
+type compraComision struct {
+	ID                uint       `gorm:"column:id"`
+	PaqueteID         uint       `gorm:"column:paquete_id"`
+	AgenciaID         uint       `gorm:"column:agencia_id"`
+	Status            string     `gorm:"column:status"`
+	PrecioTotal       float64    `gorm:"column:precio_total"`
+	FechaConfirmacion *time.Time `gorm:"column:fecha_confirmacion"`
+}
+
+// registrarVentaComision lleva al libro la venta de una compra confirmada. La primera vez registra el precio
+// total con la tarifa vigente; si después una modificación cambia el precio, registra la diferencia con la
+// misma tarifa. Es idempotente: sin diferencias no registra nada.
+func registrarVentaComision(tx *gorm.DB, compraID uint) error {
+	var compra compraComision
+	res := tx.Raw(`
+		SELECT c.id, c.paquete_id, p.agencia_id, c.status, c.precio_total, c.fecha_confirmacion
+		FROM compras_paquetes c
+		JOIN paquetes_turisticos p ON p.id = c.paquete_id
+		WHERE c.id = ?
+		FOR UPDATE OF c
+	`, compraID).Scan(&compra)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 || compra.Status != "confirmada" {
+		return nil
+	}
+
+	var previos []models.AsientoComision
+	if err := tx.Where("compra_id = ? AND tipo IN ?", compra.ID, []string{AsientoComisionVenta, AsientoComisionModificacion}).
+		Order("id ASC").
+		Find(&previos).Error; err != nil {
+		return err
+	}
+	var registrado float64
+	for _, a := range previos {
+		registrado += a.MontoBase
+	}
+	diferencia := redondearMonto(compra.PrecioTotal - registrado)
+	if math.Abs(diferencia) < 0.01 {
+		return nil
+	}
+
+	asiento := models.AsientoComision{
+		AgenciaID: compra.AgenciaID,
+		CompraID:  &compra.ID,
+		MontoBase: diferencia,
+	}
+	if len(previos) == 0 {
+		if inicio := inicioComisiones(); inicio != nil && compra.FechaConfirmacion != nil && compra.FechaConfirmacion.Before(*inicio) {
+			return nil
+		}
+		categoriaID, err := categoriaPaquete(tx, compra.PaqueteID)
+		if err != nil {
+			return err
+		}
+		tarifa, err := tarifaAplicable(tx, compra.AgenciaID, categoriaID)
+		if err != nil {
+			return err
+		}
+		porcentaje := PorcentajeComisionDefecto()
+		if tarifa != nil {
+			porcentaje = tarifa.Porcentaje
+			asiento.TarifaID = &tarifa.ID
+		}
+		asiento.Tipo = AsientoComisionVenta
+		asiento.Porcentaje = &porcentaje
+		asiento.CategoriaID = categoriaID
+		asiento.Descripcion = fmt.Sprintf("Venta compra #%d", compra.ID)
+		asiento.Fecha = fechaHoyUTC()
+		if compra.FechaConfirmacion != nil {
+			asiento.Fecha = *compra.FechaConfirmacion
+		}
+	} else {
+		// La tarifa queda fija desde la venta original
+		asiento.Tipo = AsientoComisionModificacion
+		asiento.Porcentaje = previos[0].Porcentaje
+		asiento.TarifaID = previos[0].TarifaID
+		asiento.CategoriaID = previos[0].CategoriaID
+		asiento.Descripcion = fmt.Sprintf("Modificación de precio compra #%d", compra.ID)
+		asiento.Fecha = fechaHoyUTC()
+	}
+
+	var porcentaje float64
+	if asiento.Porcentaje != nil {
+		porcentaje = *asiento.Porcentaje
+	}
+	comision := redondearMonto(diferencia * porcentaje / 100)
+	return registrarAsiento(tx, &asiento,
+		partida(CuentaCobrosAgencia, CuentaVentasBrutas, diferencia),
+		partida(CuentaComisionPorCobrar, CuentaIngresosComision, comision))
+}
+
+// registrarReembolsoComision revierte la venta devuelta al turista y la parte proporcional de la comisión.
+// Los reembolsos por modificación no se registran: la diferencia de precio ya entra como modificación, y
+// tampoco los de compras que nunca se confirmaron (no generaron comisión).
+func registrarReembolsoComision(tx *gorm.DB, reembolsoID uint) error {
+	var reembolso models.Reembolso
+	if err := tx.First(&reembolso, reembolsoID).Error; err != nil {
+		return err
+	}
+	if reembolso.Estado != "pagado" || reembolso.Motivo == "modificacion" || reembolso.MontoReembolso <= 0 {
+		return nil
+	}
+
+	var asientos []models.AsientoComision
+	if err := tx.Where("compra_id = ? AND tipo IN ?", reembolso.CompraID,
+		[]string{AsientoComisionVenta, AsientoComisionModificacion, AsientoComisionReembolso}).
+		Order("id ASC").
+		Find(&asientos).Error; err != nil {
+		return err
+	}
+	var venta *models.AsientoComision
+	var neto float64
+	for i := range asientos {
+		if asientos[i].Tipo == AsientoComisionVenta && venta == nil {
+			venta = &asientos[i]
+		}
+		neto += asientos[i].MontoBase
+	}
+	if venta == nil || neto <= 0 {
+		return nil
+	}
+
+	devuelto := redondearMonto(math.Min(reembolso.MontoReembolso, neto))
+	var porcentaje float64
+	if venta.Porcentaje != nil {
+		porcentaje = *venta.Porcentaje
+	}
+	comision := redondearMonto(devuelto * porcentaje / 100)
+
+	fecha := fechaHoyUTC()
+	if reembolso.FechaPago != nil {
+		fecha = *reembolso.FechaPago
+	}
+	asiento := models.AsientoComision{
+		AgenciaID:   reembolso.AgenciaID,
+		Tipo:        AsientoComisionReembolso,
+		CompraID:    &reembolso.CompraID,
+		ReembolsoID: &reembolso.ID,
+		Fecha:       fecha,
+		Descripcion: fmt.Sprintf("Reembolso #%d compra #%d", reembolso.ID, reembolso.CompraID),
+		MontoBase:   -devuelto,
+		Porcentaje:  venta.Porcentaje,
+		TarifaID:    venta.TarifaID,
+		CategoriaID: venta.CategoriaID,
+		CreadoPor:   reembolso.PagadoPor,
+	}
+	return registrarAsiento(tx, &asiento,
+		partida(CuentaVentasBrutas, CuentaCobrosAgencia, devuelto),
+		partida(CuentaIngresosComision, CuentaComisionPorCobrar, comision))
+}
+
+// RegistrarVenta lleva al libro la venta de una compra recién confirmada.
+func (s *ComisionService) RegistrarVenta(compraID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return registrarVentaComision(tx, compraID)
+	})
+}
+
+// SincronizarVentas registra las ventas confirmadas que aún no están en el libro o cuyo precio cambió
+// (p. ej. si falló el registro al confirmar el pago). Retorna cuántas compras revisó.
+func (s *ComisionService) SincronizarVentas() (int, error) {
+	q := s.db.Table("compras_paquetes cp").
+		Select("cp.id").
+		Joins(`LEFT JOIN (
+			SELECT compra_id, SUM(monto_base) AS registrado
+			FROM asientos_comision
+			WHERE tipo IN ('venta', 'modificacion')
+			GROUP BY compra_id
+		) a ON a.compra_id = cp.id`).
+		Where("cp.status = ?", "confirmada").
+		Where("ABS(COALESCE(a.registrado, 0) - cp.precio_total) >= 0.01")
+	if inicio := inicioComisiones(); inicio != nil {
+		q = q.Where("(a.compra_id IS NOT NULL OR cp.fecha_confirmacion >= ?)", *inicio)
+	}
+
+	var ids []uint
+	if err := q.Order("cp.id ASC").Limit(maxVentasSincronizacion).Pluck("cp.id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := s.RegistrarVenta(id); err != nil {
+			return 0, fmt.Errorf("compra %d: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+func (s *ComisionService) ListarTarifas() ([]models.TarifaComision, error) {
+	var tarifas []models.TarifaComision
+	if err := s.db.
+		Preload("Agencia", func(db *gorm.DB) *gorm.DB { return db.Select("id", "nombre_comercial") }).
+		Preload("Categoria").
+		Order("agencia_id ASC NULLS FIRST").
+		Order("categoria_id ASC NULLS FIRST").
+		Find(&tarifas).Error; err != nil {
+		return nil, err
+	}
+	return tarifas, nil
+}
+
+// validarTarifa comprueba la agencia y la categoría, y que no exista otra tarifa para la misma combinación.
+func (s *ComisionService) validarTarifa(tarifaID uint, req *models.TarifaComisionRequest) error {
+	if req.AgenciaID == nil && req.CategoriaID == nil {
+		return errors.New("indique la agencia, la categoría o ambas (la tarifa general es COMISION_PORCENTAJE_DEFECTO)")
+	}
+	if req.AgenciaID != nil {
+		if err := existeAgencia(s.db, *req.AgenciaID); err != nil {
+			return err
+		}
+	}
+	if req.CategoriaID != nil {
+		var n int64
+		if err := s.db.Model(&models.CategoriaAtraccion{}).Where("id = ?", *req.CategoriaID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New("categoría no encontrada")
+		}
+	}
+
+	q := s.db.Model(&models.TarifaComision{}).Where("id <> ?", tarifaID)
+	if req.AgenciaID != nil {
+		q = q.Where("agencia_id = ?", *req.AgenciaID)
+	} else {
+		q = q.Where("agencia_id IS NULL")
+	}
+	if req.CategoriaID != nil {
+		q = q.Where("categoria_id = ?", *req.CategoriaID)
+	} else {
+		q = q.Where("categoria_id IS NULL")
+	}
+	var existentes int64
+	if err := q.Count(&existentes).Error; err != nil {
+		return err
+	}
+	if existentes > 0 {
+		return errors.New("ya existe una tarifa para esa agencia y categoría")
+	}
+	return nil
+}
+
+// CrearTarifa registra una tarifa; rige para las ventas que se confirmen desde ahora.
+func (s *ComisionService) CrearTarifa(adminID uint, req *models.TarifaComisionRequest) (*models.TarifaComision, error) {
+	if err := s.validarTarifa(0, req); err != nil {
+		return nil, err
+	}
+	tarifa := models.TarifaComision{
+		AgenciaID:   req.AgenciaID,
+		CategoriaID: req.CategoriaID,
+		Porcentaje:  req.Porcentaje,
+		Notas:       req.Notas,
+		CreadoPor:   adminID,
+	}
+	if err := s.db.Create(&tarifa).Error; err != nil {
+		return nil, err
+	}
+	return &tarifa, nil
+}
+
+// ActualizarTarifa cambia una tarifa; las ventas ya registradas conservan el porcentaje con que se asentaron.
+func (s *ComisionService) ActualizarTarifa(tarifaID uint, req *models.TarifaComisionRequest) (*models.TarifaComision, error) {
+	var tarifa models.TarifaComision
+	if err := s.db.First(&tarifa, tarifaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTarifaComisionNoEncontrada
+		}
+		return nil, err
+	}
+	if err := s.validarTarifa(tarifa.ID, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&tarifa).Updates(map[string]interface{}{
+		"agencia_id":   req.AgenciaID,
+		"categoria_id": req.CategoriaID,
+		"porcentaje":   req.Porcentaje,
+		"notas":        req.Notas,
+	}).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.First(&tarifa, tarifa.ID).Error; err != nil {
+		return nil, err
+	}
+	return &tarifa, nil
+}
+
+func (s *ComisionService) EliminarTarifa(tarifaID uint) error {
+	res := s.db.Delete(&models.TarifaComision{}, tarifaID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTarifaComisionNoEncontrada
+	}
+	return nil
+}
+
+func existeAgencia(db *gorm.DB, agenciaID uint) error {
+	var n int64
+	if err := db.Model(&models.AgenciaTurismo{}).Where("id = ?", agenciaID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("agencia no encontrada")
+	}
+	return nil
+}
+
+// RegistrarAjuste asienta un ajuste manual del saldo de la agencia.
+func (s *ComisionService) RegistrarAjuste(adminID uint, req *models.AjusteComisionRequest) (*models.AsientoComision, error) {
+	if err := existeAgencia(s.db, req.AgenciaID); err != nil {
+		return nil, err
+	}
+	fecha, err := parseFechaContable(req.Fecha)
+	if err != nil {
+		return nil, err
+	}
+
+	asiento := models.AsientoComision{
+		AgenciaID:   req.AgenciaID,
+		Tipo:        AsientoComisionAjuste,
+		Fecha:       fecha,
+		Descripcion: req.Descripcion,
+		CreadoPor:   &adminID,
+	}
+	if err := registrarAsiento(s.db, &asiento, partida(CuentaComisionPorCobrar, CuentaAjustesComision, req.Monto)); err != nil {
+		return nil, err
+	}
+	return &asiento, nil
+}
+
+// RegistrarPago asienta un pago de comisiones recibido de la agencia.
+func (s *ComisionService) RegistrarPago(adminID uint, req *models.PagoComisionRequest) (*models.AsientoComision, error) {
+	if err := existeAgencia(s.db, req.AgenciaID); err != nil {
+		return nil, err
+	}
+	fecha, err := parseFechaContable(req.Fecha)
+	if err != nil {
+		return nil, err
+	}
+
+	asiento := models.AsientoComision{
+		AgenciaID:   req.AgenciaID,
+		Tipo:        AsientoComisionPago,
+		Fecha:       fecha,
+		Descripcion: "Pago de comisiones",
+		Referencia:  &req.Referencia,
+		CreadoPor:   &adminID,
+	}
+	if err := registrarAsiento(s.db, &asiento, partida(CuentaBancoPlataforma, CuentaComisionPorCobrar, req.Monto)); err != nil {
+		return nil, err
+	}
+	return &asiento, nil
+}
+
+// Resumen lista por agencia las comisiones del rango [desde, hasta) y el saldo adeudado a la fecha.
+func (s *ComisionService) Resumen(desde, hasta time.Time) ([]models.ResumenComisionAgencia, error) {
+	var filas []models.ResumenComisionAgencia
+	if err := s.db.Raw(`
+		SELECT
+			ag.id AS agencia_id,
+			ag.nombre_comercial AS agencia_nombre,
+			COALESCE(SUM(CASE WHEN en_rango AND m.cuenta = 'ventas_brutas' AND a.tipo IN ('venta', 'modificacion')
+				THEN m.haber - m.debe END), 0) AS ventas_brutas,
+			COALESCE(SUM(CASE WHEN en_rango AND m.cuenta = 'ventas_brutas' AND a.tipo = 'reembolso'
+				THEN m.debe - m.haber END), 0) AS reembolsos,
+			COALESCE(SUM(CASE WHEN en_rango AND m.cuenta = 'ingresos_comision' THEN m.haber - m.debe END), 0) AS comision,
+			COALESCE(SUM(CASE WHEN en_rango AND m.cuenta = 'ajustes_comision' THEN m.haber - m.debe END), 0) AS ajustes,
+			COALESCE(SUM(CASE WHEN en_rango AND m.cuenta = 'banco_plataforma' THEN m.debe - m.haber END), 0) AS pagos,
+			COALESCE(SUM(CASE WHEN m.cuenta = 'comision_por_cobrar' THEN m.debe - m.haber END), 0) AS saldo_adeudado,
+			(SELECT MAX(l.periodo_hasta) FROM liquidaciones_comision l WHERE l.agencia_id = ag.id) AS ultima_liquidacion
+		FROM (
+			SELECT a.*, (a.fecha >= ? AND a.fecha < ?) AS en_rango FROM asientos_comision a
+		) a
+		JOIN movimientos_comision m ON m.asiento_id = a.id
+		JOIN agencias_turismo ag ON ag.id = a.agencia_id
+		GROUP BY ag.id, ag.nombre_comercial
+		ORDER BY saldo_adeudado DESC, ag.nombre_comercial ASC
+	`, desde, hasta).Scan(&filas).Error; err != nil {
+		return nil, err
+	}
+	return filas, nil
+}
+
+// CuentaAgencia retorna el saldo de la agencia, las tarifas que le aplican y su última liquidación.
+func (s *ComisionService) CuentaAgencia(agenciaID uint) (*models.CuentaComisionAgencia, error) {
+	var saldos struct {
+		SaldoAdeudado float64 `gorm:"column:saldo_adeudado"`
+		SinLiquidar   float64 `gorm:"column:sin_liquidar"`
+	}
+	if err := s.db.Raw(`
+		SELECT
+			COALESCE(SUM(m.debe - m.haber), 0) AS saldo_adeudado,
+			COALESCE(SUM(CASE WHEN a.liquidacion_id IS NULL THEN m.debe - m.haber END), 0) AS sin_liquidar
+		FROM movimientos_comision m
+		JOIN asientos_comision a ON a.id = m.asiento_id
+		WHERE m.agencia_id = ? AND m.cuenta = ?
+	`, agenciaID, CuentaComisionPorCobrar).Scan(&saldos).Error; err != nil {
+		return nil, err
+	}
+
+	cuenta := &models.CuentaComisionAgencia{
+		SaldoAdeudado:    saldos.SaldoAdeudado,
+		SinLiquidar:      saldos.SinLiquidar,
+		TarifaPorDefecto: PorcentajeComisionDefecto(),
+	}
+	if err := s.db.Preload("Categoria").
+		Where("agencia_id = ? OR agencia_id IS NULL", agenciaID).
+		Order("agencia_id ASC NULLS LAST").
+		Order("categoria_id ASC NULLS FIRST").
+		Find(&cuenta.Tarifas).Error; err != nil {
+		return nil, err
+	}
+
+	var ultima models.LiquidacionComision
+	if err := s.db.Where("agencia_id = ?", agenciaID).Order("periodo_hasta DESC").First(&ultima).Error; err == nil {
+		cuenta.UltimaLiquidacion = &ultima
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return cuenta, nil
+}
+
+// ListarAsientos lista los asientos de la agencia con fecha en [desde, hasta), con sus movimientos.
+func (s *ComisionService) ListarAsientos(agenciaID uint, desde, hasta time.Time, page, pageSize int) ([]models.AsientoComision, int64, error) {
+	q := s.db.Model(&models.AsientoComision{}).
+		Where("agencia_id = ? AND fecha >= ? AND fecha < ?", agenciaID, desde, hasta)
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var asientos []models.AsientoComision
+	if err := q.Session(&gorm.Session{}).
+		Preload("Movimientos").
+		Order("fecha DESC").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&asientos).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return asientos, total, nil
+}
+
+type totalesLiquidacion struct {
+	VentasBrutas float64 `gorm:"column:ventas_brutas"`
+	Reembolsos   float64 `gorm:"column:reembolsos"`
+	Comision     float64 `gorm:"column:comision"`
+	Ajustes      float64 `gorm:"column:ajustes"`
+	Pagos        float64 `gorm:"column:pagos"`
+}
+
+// emitirLiquidacion liquida los asientos de la agencia no liquidados con fecha hasta el día indicado
+// (incluidos los de períodos anteriores registrados tarde) y avisa al encargado principal.
+func emitirLiquidacion(tx *gorm.DB, agenciaID uint, hasta time.Time, emitidaPor *uint) (*models.LiquidacionComision, error) {
+	var agencia models.AgenciaTurismo
+	res := tx.Raw(`SELECT * FROM agencias_turismo WHERE id = ? FOR UPDATE`, agenciaID).Scan(&agencia)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.New("agencia no encontrada")
+	}
+
+	var anterior *models.LiquidacionComision
+	var ultima models.LiquidacionComision
+	if err := tx.Where("agencia_id = ?", agenciaID).Order("periodo_hasta DESC").First(&ultima).Error; err == nil {
+		if !hasta.After(ultima.PeriodoHasta) {
+			return nil, fmt.Errorf("la agencia ya tiene una liquidación hasta el %s", ultima.PeriodoHasta.Format("2006-01-02"))
+		}
+		anterior = &ultima
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var asientos []models.AsientoComision
+	if err := tx.Select("id", "fecha").
+		Where("agencia_id = ? AND liquidacion_id IS NULL AND fecha <= ?", agenciaID, hasta).
+		Order("fecha ASC").
+		Find(&asientos).Error; err != nil {
+		return nil, err
+	}
+	if len(asientos) == 0 {
+		return nil, ErrSinMovimientosPorLiquidar
+	}
+	ids := make([]uint, 0, len(asientos))
+	for _, a := range asientos {
+		ids = append(ids, a.ID)
+	}
+
+	var totales totalesLiquidacion
+	if err := tx.Raw(`
+		SELECT
+			COALESCE(SUM(CASE WHEN m.cuenta = 'ventas_brutas' AND a.tipo IN ('venta', 'modificacion')
+				THEN m.haber - m.debe END), 0) AS ventas_brutas,
+			COALESCE(SUM(CASE WHEN m.cuenta = 'ventas_brutas' AND a.tipo = 'reembolso' THEN m.debe - m.haber END), 0) AS reembolsos,
+			COALESCE(SUM(CASE WHEN m.cuenta = 'ingresos_comision' THEN m.haber - m.debe END), 0) AS comision,
+			COALESCE(SUM(CASE WHEN m.cuenta = 'ajustes_comision' THEN m.haber - m.debe END), 0) AS ajustes,
+			COALESCE(SUM(CASE WHEN m.cuenta = 'banco_plataforma' THEN m.debe - m.haber END), 0) AS pagos
+		FROM movimientos_comision m
+		JOIN asientos_comision a ON a.id = m.asiento_id
+		WHERE a.id IN ?
+	`, ids).Scan(&totales).Error; err != nil {
+		return nil, err
+	}
+
+	liquidacion := models.LiquidacionComision{
+		AgenciaID:     agenciaID,
+		PeriodoDesde:  asientos[0].Fecha,
+		PeriodoHasta:  hasta,
+		VentasBrutas:  redondearMonto(totales.VentasBrutas),
+		Reembolsos:    redondearMonto(totales.Reembolsos),
+		Comision:      redondearMonto(totales.Comision),
+		Ajustes:       redondearMonto(totales.Ajustes),
+		Pagos:         redondearMonto(totales.Pagos),
+		TotalAsientos: len(ids),
+		EmitidaPor:    emitidaPor,
+	}
+	if anterior != nil {
+		liquidacion.PeriodoDesde = anterior.PeriodoHasta.AddDate(0, 0, 1)
+		liquidacion.SaldoAnterior = anterior.SaldoFinal
+	}
+	if asientos[0].Fecha.Before(liquidacion.PeriodoDesde) {
+		liquidacion.PeriodoDesde = asientos[0].Fecha
+	}
+	liquidacion.SaldoFinal = redondearMonto(liquidacion.SaldoAnterior + liquidacion.Comision + liquidacion.Ajustes - liquidacion.Pagos)
+
+	if err := tx.Create(&liquidacion).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.AsientoComision{}).Where("id IN ?", ids).
+		Update("liquidacion_id", liquidacion.ID).Error; err != nil {
+		return nil, err
+	}
+
+	if agencia.EncargadoPrincipalID != nil {
+		if _, err := notificarUsuario(tx, *agencia.EncargadoPrincipalID, "liquidacion_comisiones",
+			"Liquidación de comisiones disponible",
+			fmt.Sprintf("Se emitió la liquidación de comisiones hasta el %s. Saldo a pagar: Bs %.2f",
+				hasta.Format("2006-01-02"), liquidacion.SaldoFinal),
+			models.NotifDatosJSON{
+				"liquidacion_id": liquidacion.ID,
+				"agencia_id":     agenciaID,
+				"saldo_final":    liquidacion.SaldoFinal,
+			}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &liquidacion, nil
+}
+
+// EmitirLiquidacionesMes emite las liquidaciones del mes (ya cerrado) para la agencia indicada o para
+// todas las que tengan movimientos sin liquidar.
+func (s *ComisionService) EmitirLiquidacionesMes(mes, anio int, agenciaID *uint, emitidaPor *uint) ([]models.LiquidacionComision, error) {
+	hasta := time.Date(anio, time.Month(mes), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, -1)
+	if !hasta.Before(fechaHoyUTC()) {
+		return nil, errors.New("solo se pueden liquidar meses cerrados")
+	}
+
+	agencias := []uint{}
+	if agenciaID != nil {
+		agencias = append(agencias, *agenciaID)
+	} else {
+		var err error
+		if agencias, err = s.agenciasPorLiquidar(hasta); err != nil {
+			return nil, err
+		}
+	}
+
+	liquidaciones := make([]models.LiquidacionComision, 0, len(agencias))
+	for _, id := range agencias {
+		var liquidacion *models.LiquidacionComision
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			liquidacion, err = emitirLiquidacion(tx, id, hasta, emitidaPor)
+			return err
+		}); err != nil {
+			if agenciaID == nil {
+				return liquidaciones, fmt.Errorf("agencia %d: %w", id, err)
+			}
+			return nil, err
+		}
+		liquidaciones = append(liquidaciones, *liquidacion)
+	}
+	return liquidaciones, nil
+}
+
+// agenciasPorLiquidar retorna las agencias con asientos sin liquidar hasta la fecha y sin una liquidación
+// que ya cubra ese día.
+func (s *ComisionService) agenciasPorLiquidar(hasta time.Time) ([]uint, error) {
+	var agencias []uint
+	if err := s.db.Model(&models.AsientoComision{}).
+		Where("liquidacion_id IS NULL AND fecha <= ?", hasta).
+		Where("NOT EXISTS (SELECT 1 FROM liquidaciones_comision l WHERE l.agencia_id = asientos_comision.agencia_id AND l.periodo_hasta >= ?)", hasta).
+		Distinct().
+		Order("agencia_id").
+		Pluck("agencia_id", &agencias).Error; err != nil {
+		return nil, err
+	}
+	return agencias, nil
+}
+
+// EmitirLiquidacionesVencidas emite, al cerrar cada mes, la liquidación del mes anterior de las agencias
+// con movimientos sin liquidar que aún no la tienen. Retorna cuántas emitió.
+func (s *ComisionService) EmitirLiquidacionesVencidas() (int, error) {
+	hoy := fechaHoyUTC()
+	hasta := time.Date(hoy.Year(), hoy.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+
+	agencias, err := s.agenciasPorLiquidar(hasta)
+	if err != nil {
+		return 0, err
+	}
+
+	// Un error en una agencia no detiene al resto; se reporta el primero
+	emitidas := 0
+	var primerError error
+	for _, id := range agencias {
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			_, err := emitirLiquidacion(tx, id, hasta, nil)
+			return err
+		}); err != nil {
+			if primerError == nil {
+				primerError = fmt.Errorf("agencia %d: %w", id, err)
+			}
+			continue
+		}
+		emitidas++
+	}
+	return emitidas, primerError
+}
+
+// ListarLiquidaciones lista las liquidaciones, de una agencia o de todas (agenciaID 0).
+func (s *ComisionService) ListarLiquidaciones(agenciaID uint, page, pageSize int) ([]models.LiquidacionComision, int64, error) {
+	q := s.db.Model(&models.LiquidacionComision{})
+	if agenciaID != 0 {
+		q = q.Where("agencia_id = ?", agenciaID)
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var liquidaciones []models.LiquidacionComision
+	if err := q.Session(&gorm.Session{}).
+		Preload("Agencia", func(db *gorm.DB) *gorm.DB { return db.Select("id", "nombre_comercial") }).
+		Order("periodo_hasta DESC").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&liquidaciones).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return liquidaciones, total, nil
+}
+
+// ObtenerLiquidacion retorna una liquidación de la agencia con sus asientos y movimientos.
+func (s *ComisionService) ObtenerLiquidacion(agenciaID uint, liquidacionID uint) (*models.LiquidacionComision, error) {
+	var liquidacion models.LiquidacionComision
+	if err := s.db.
+		Preload("Asientos", func(db *gorm.DB) *gorm.DB { return db.Order("fecha ASC").Order("id ASC") }).
+		Preload("Asientos.Movimientos").
+		Where("id = ? AND agencia_id = ?", liquidacionID, agenciaID).
+		First(&liquidacion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLiquidacionNoEncontrada
+		}
+		return nil, err
+	}
+	return &liquidacion, nil
+}
+
+// StartComisionWorker inicia un worker que sincroniza periódicamente las ventas con el libro de comisiones
+// y, al cerrar cada mes, emite las liquidaciones del mes anterior
+func StartComisionWorker(db *gorm.DB, intervaloChequeoMinutos int) {
+	if intervaloChequeoMinutos < 1 {
+		intervaloChequeoMinutos = 30
+	}
+
+	service := NewComisionService(db)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(intervaloChequeoMinutos) * time.Minute)
+		defer ticker.Stop()
+
+		log.Printf("Worker de comisiones iniciado: chequea cada %d minutos", intervaloChequeoMinutos)
+
+		for {
+			ventas, err := service.SincronizarVentas()
+			if err != nil {
+				log.Printf("Error sincronizando ventas con el libro de comisiones: %v", err)
+			} else if ventas > 0 {
+				log.Printf("Worker de comisiones: %d ventas sincronizadas con el libro de comisiones", ventas)
+			}
+
+			// Al cerrar el mes, liquidar las comisiones del mes anterior
+			liquidaciones, err := service.EmitirLiquidacionesVencidas()
+			if err != nil {
+				log.Printf("Error emitiendo liquidaciones de comisiones: %v", err)
+			}
+			if liquidaciones > 0 {
+				log.Printf("Worker de comisiones: %d liquidaciones de comisiones emitidas", liquidaciones)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func fechaHoyUTC() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// politicaAnticipoCompra calcula el anticipo mínimo y la fecha límite del saldo para un precio y fecha de salida.
+// Solo aplica a paquetes de varios días cuando la agencia configuró un porcentaje de anticipo;
+// retorna nil si se exige el pago completo.
+func politicaAnticipoCompra(tx *gorm.DB, paquete *models.PaqueteTuristico, precioTotal float64, fechaSeleccionada time.Time) (*float64, *time.Time, error) {
+	if paquete == nil || paquete.DuracionDias == nil || *paquete.DuracionDias <= 1 {
+		return nil, nil, nil
+	}
+
+	var politicas models.PaquetePolitica
+	if err := tx.Where("agencia_id = ?", paquete.AgenciaID).First(&politicas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	if politicas.AnticipoPorcentaje <= 0 || politicas.AnticipoPorcentaje >= 100 || precioTotal <= 0 {
+		return nil, nil, nil
+	}
+
+	// Si la salida está demasiado cerca, se exige el pago completo.
+	fechaSalida := time.Date(fechaSeleccionada.Year(), fechaSeleccionada.Month(), fechaSeleccionada.Day(), 0, 0, 0, 0, time.UTC)
+	fechaLimite := fechaSalida.AddDate(0, 0, -politicas.DiasLimiteSaldo)
+	if !fechaLimite.After(fechaHoyUTC()) {
+		return nil, nil, nil
+	}
+
+	anticipo := redondearMonto(precioTotal * politicas.AnticipoPorcentaje / 100)
+	return &anticipo, &fechaLimite, nil
+}
+
+// aplicarPoliticaAnticipo define el anticipo mínimo y la fecha límite del saldo de una compra recién creada.
+func aplicarPoliticaAnticipo(tx *gorm.DB, compraID uint) (*models.CompraPaquete, error) {
+	var compra models.CompraPaquete
+	if err := tx.Preload("Paquete").First(&compra, compraID).Error; err != nil {
+		return nil, err
+	}
+
+	anticipo, fechaLimite, err := politicaAnticipoCompra(tx, compra.Paquete, compra.PrecioTotal, compra.FechaSeleccionada)
+	if err != nil {
+		return nil, err
+	}
+	if anticipo == nil {
+		return &compra, nil
+	}
+
+	if err := tx.Model(&compra).Updates(map[string]interface{}{
+		"monto_anticipo":     *anticipo,
+		"fecha_limite_saldo": *fechaLimite,
+		"updated_at":         time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	compra.MontoAnticipo = anticipo
+	compra.FechaLimiteSaldo = fechaLimite
+	return &compra, nil
+}
+
+// validarMontoPago valida el monto de un nuevo pago contra el saldo de la compra.
+// Sin anticipo configurado se exige el total; con anticipo, el primer pago debe cubrir al menos el anticipo
+// y los siguientes pueden ser cuotas de cualquier monto hasta completar el saldo.
+func validarMontoPago(compra *models.CompraPaquete, monto float64) error {
+	saldo := compra.SaldoPendiente()
+	if saldo <= 0.01 {
+		return errors.New("la compra no tiene saldo pendiente")
+	}
+
+	if monto > saldo+0.01 {
+		return fmt.Errorf("el monto excede el saldo pendiente (%.2f Bs)", saldo)
+	}
+
+	if compra.Status == "pendiente_confirmacion" {
+		if compra.MontoAnticipo == nil {
+			if saldo-monto > 0.01 {
+				return fmt.Errorf("el monto debe ser %.2f Bs", saldo)
+			}
+			return nil
+		}
+		if monto < *compra.MontoAnticipo-0.01 {
+			return fmt.Errorf("el anticipo mínimo es %.2f Bs (total %.2f Bs)", *compra.MontoAnticipo, compra.PrecioTotal)
+		}
+	}
+
+	return nil
+}
+
+// EnviarRecordatoriosSaldo notifica a los turistas con reservas con anticipo cuyo saldo vence
+// dentro de diasAnticipacion días (una vez por día). Si el saldo ya venció, también avisa al encargado
+// y le indica al turista cuándo se cancelará la reserva (ver CancelarSaldosVencidos).
+func (s *CompraService) EnviarRecordatoriosSaldo(diasAnticipacion int, diasGracia int) (int64, error) {
+	if diasAnticipacion < 0 {
+		diasAnticipacion = 3
+	}
+	if diasGracia < 0 {
+		diasGracia = 0
+	}
+
+	hoy := fechaHoyUTC()
+
+	var compras []models.CompraPaquete
+	if err := s.db.
+		Preload("Paquete").
+		Where("status = ?", "reservada_con_anticipo").
+		Where("fecha_limite_saldo IS NOT NULL AND fecha_limite_saldo <= ?", hoy.AddDate(0, 0, diasAnticipacion)).
+		Where("ultimo_recordatorio_saldo IS NULL OR ultimo_recordatorio_saldo < ?", hoy).
+		Find(&compras).Error; err != nil {
+		return 0, fmt.Errorf("error buscando saldos pendientes: %w", err)
+	}
+
+	var enviados int64
+	for _, compra := range compras {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			paqueteNombre := ""
+			if compra.Paquete != nil {
+				paqueteNombre = compra.Paquete.Nombre
+			}
+			saldo := compra.SaldoPendiente()
+			fechaLimite := compra.FechaLimiteSaldo.Format("2006-01-02")
+			datos := models.NotifDatosJSON{
+				"compra_id":          compra.ID,
+				"paquete_id":         compra.PaqueteID,
+				"paquete_nombre":     paqueteNombre,
+				"saldo_pendiente":    saldo,
+				"fecha_limite_saldo": fechaLimite,
+			}
+
+			if compra.FechaLimiteSaldo.Before(hoy) {
+				fechaCancelacion := compra.FechaLimiteSaldo.AddDate(0, 0, diasGracia+1).Format("2006-01-02")
+				datos["fecha_cancelacion"] = fechaCancelacion
+				if _, err := notificarUsuario(tx, compra.TuristaID, models.TipoSaldoVencido,
+					"El saldo de tu reserva está vencido",
+					fmt.Sprintf("El saldo de Bs %.2f de tu reserva para \"%s\" venció el %s. Si no se paga, la reserva se cancelará el %s.", saldo, paqueteNombre, fechaLimite, fechaCancelacion),
+					datos); err != nil {
+					return err
+				}
+
+				encargadoID, err := encargadoPrincipalDePaquete(tx, compra.PaqueteID)
+				if err != nil {
+					return err
+				}
+				if encargadoID != 0 {
+					if _, err := notificarUsuario(tx, encargadoID, models.TipoSaldoVencido,
+						"Reserva con saldo vencido",
+						fmt.Sprintf("La compra #%d de \"%s\" tiene un saldo vencido de Bs %.2f", compra.ID, paqueteNombre, saldo),
+						datos); err != nil {
+						return err
+					}
+				}
+			} else {
+				if _, err := notificarUsuario(tx, compra.TuristaID, models.TipoRecordatorioSaldo,
+					"Recuerda completar el pago de tu reserva",
+					fmt.Sprintf("Tienes un saldo pendiente de Bs %.2f para \"%s\". Fecha límite: %s", saldo, paqueteNombre, fechaLimite),
+					datos); err != nil {
+					return err
+				}
+			}
+
+			return tx.Model(&models.CompraPaquete{}).
+				Where("id = ?", compra.ID).
+				Update("ultimo_recordatorio_saldo", time.Now()).Error
+		})
+		if err != nil {
+			log.Printf("Error enviando recordatorio de saldo para compra %d: %v", compra.ID, err)
+			continue
+		}
+		enviados++
+	}
+
+	return enviados, nil
+}
+
+// CancelarSaldosVencidos cancela las reservas con anticipo cuyo saldo sigue impago diasGracia días después
+// de la fecha límite. Se tratan como una cancelación del turista: el anticipo se reembolsa según los tramos
+// de la agencia y los cupos vuelven a la salida (y se ofrecen a su lista de espera).
+func (s *CompraService) CancelarSaldosVencidos(diasGracia int) (int64, error) {
+	if diasGracia < 0 {
+		diasGracia = 0
+	}
+	vence := fechaHoyUTC().AddDate(0, 0, -diasGracia)
+
+	var candidatas []models.CompraPaquete
+	if err := s.db.Select("id").
+		Where("status = ?", "reservada_con_anticipo").
+		Where("fecha_limite_saldo IS NOT NULL AND fecha_limite_saldo < ?", vence).
+		Find(&candidatas).Error; err != nil {
+		return 0, fmt.Errorf("error buscando saldos vencidos: %w", err)
+	}
+
+	var canceladas int64
+	for _, candidata := range candidatas {
+		cancelada := false
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// Bloquear y revalidar: un pago pudo completar el saldo mientras tanto
+			var compra models.CompraPaquete
+			if err := tx.Raw(`SELECT * FROM compras_paquetes WHERE id = ? FOR UPDATE`, candidata.ID).Scan(&compra).Error; err != nil {
+				return err
+			}
+			if compra.ID == 0 || compra.Status != "reservada_con_anticipo" || compra.FechaLimiteSaldo == nil || !compra.FechaLimiteSaldo.Before(vence) {
+				return nil
+			}
+			// Un pago en revisión puede completar el saldo: se espera a que el encargado lo resuelva
+			var pagosPendientes int64
+			if err := tx.Model(&models.PagoCompra{}).
+				Where("compra_id = ? AND estado = ?", compra.ID, "pendiente").
+				Count(&pagosPendientes).Error; err != nil {
+				return err
+			}
+			if pagosPendientes > 0 {
+				return nil
+			}
+
+			fechaLimite := compra.FechaLimiteSaldo.Format("2006-01-02")
+			reembolso, err := cancelarCompraTurista(tx, &compra, fmt.Sprintf("Saldo no pagado (fecha límite %s)", fechaLimite))
+			if err != nil {
+				return err
+			}
+
+			var paquete models.PaqueteTuristico
+			if err := tx.Select("id", "nombre").First(&paquete, compra.PaqueteID).Error; err != nil {
+				return err
+			}
+			datos := models.NotifDatosJSON{
+				"compra_id":          compra.ID,
+				"paquete_id":         compra.PaqueteID,
+				"paquete_nombre":     paquete.Nombre,
+				"fecha_limite_saldo": fechaLimite,
+			}
+			mensaje := fmt.Sprintf("Tu reserva para \"%s\" se canceló porque el saldo no se pagó hasta el %s.", paquete.Nombre, fechaLimite)
+			if reembolso != nil && reembolso.MontoReembolso > 0 {
+				datos["reembolso_id"] = reembolso.ID
+				datos["monto_reembolso"] = reembolso.MontoReembolso
+				mensaje += fmt.Sprintf(" La agencia te devolverá Bs %.2f.", reembolso.MontoReembolso)
+			}
+			if _, err := notificarUsuario(tx, compra.TuristaID, models.TipoCompraCanceladaSaldo,
+				"Tu reserva fue cancelada", mensaje, datos); err != nil {
+				return err
+			}
+
+			encargadoID, err := encargadoPrincipalDePaquete(tx, compra.PaqueteID)
+			if err != nil {
+				return err
+			}
+			if encargadoID != 0 {
+				if _, err := notificarUsuario(tx, encargadoID, models.TipoCompraCanceladaSaldo,
+					"Reserva cancelada por saldo vencido",
+					fmt.Sprintf("La compra #%d de \"%s\" se canceló por no pagar el saldo hasta el %s; sus cupos quedaron libres", compra.ID, paquete.Nombre, fechaLimite),
+					datos); err != nil {
+					return err
+				}
+			}
+			cancelada = true
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error cancelando la compra %d con saldo vencido: %v", candidata.ID, err)
+			continue
+		}
+		if cancelada {
+			canceladas++
+		}
+	}
+
+	return canceladas, nil
+}
+
+// StartSaldoReminderWorker inicia un worker que cancela las reservas con saldo vencido (pasados diasGracia
+// días de la fecha límite) y envía recordatorios de saldo pendiente periódicamente
+func StartSaldoReminderWorker(db *gorm.DB, diasAnticipacion int, diasGracia int, intervaloChequeoMinutos int) {
+	if intervaloChequeoMinutos < 1 {
+		intervaloChequeoMinutos = 60
+	}
+
+	service := NewCompraService(db)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(intervaloChequeoMinutos) * time.Minute)
+		defer ticker.Stop()
+
+		log.Printf("Worker de recordatorios de saldo iniciado: avisa %d días antes, cancela %d días después del vencimiento, chequea cada %d minutos",
+			diasAnticipacion, diasGracia, intervaloChequeoMinutos)
+
+		for {
+			canceladas, err := service.CancelarSaldosVencidos(diasGracia)
+			if err != nil {
+				log.Printf("Error en worker de recordatorios de saldo: %v", err)
+			} else if canceladas > 0 {
+				log.Printf("Worker de recordatorios de saldo: %d reservas canceladas por saldo vencido", canceladas)
+			}
+
+			enviados, err := service.EnviarRecordatoriosSaldo(diasAnticipacion, diasGracia)
+			if err != nil {
+				log.Printf("Error en worker de recordatorios de saldo: %v", err)
+			} else if enviados > 0 {
+				log.Printf("Worker de recordatorios de saldo: %d recordatorios enviados", enviados)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrCotizacionPaqueteNoDisponible indica que el paquete o su agencia no existen o no son públicos.
+var ErrCotizacionPaqueteNoDisponible = errors.New("paquete no disponible")
+
+// CotizarCompra calcula el precio y la disponibilidad de una compra sin reservar cupos.
+// Usa las mismas funciones SQL (validar_compra_paquete, reservar_salida_paquete en modo verificación
+// y cotizar_compra_paquete) y los mismos pasos en Go (descuentos, anticipo) que CrearCompra.
+func (s *CompraService) CotizarCompra(req *models.CotizarCompraRequest) (*models.CotizacionCompraResponse, error) {
+	resp, err := s.cotizarCompra(req)
+	if err != nil && (isUndefinedFunctionError(err) || isFunctionResultMismatchError(err)) {
+		if bootstrapErr := database.ApplySQLBootstrap(s.db); bootstrapErr != nil {
+			return nil, fmt.Errorf("la base de datos no está preparada (funciones de compra faltantes o desactualizadas): %w", bootstrapErr)
+		}
+		return s.cotizarCompra(req)
+	}
+	return resp, err
+}
+
+func (s *CompraService) cotizarCompra(req *models.CotizarCompraRequest) (*models.CotizacionCompraResponse, error) {
+	fecha, err := time.Parse("2006-01-02", req.FechaSeleccionada)
+	if err != nil {
+		return nil, fmt.Errorf("fecha inválida (use YYYY-MM-DD)")
+	}
+
+	var mensajeValidacion *string
+	if err := s.db.Raw(`SELECT public.validar_compra_paquete(?::int, ?::int, ?::int, ?::int)`,
+		req.PaqueteID, req.CantidadAdultos, req.CantidadNinosPagan, req.CantidadNinosGratis).
+		Scan(&mensajeValidacion).Error; err != nil {
+		return nil, err
+	}
+	if mensajeValidacion != nil {
+		switch *mensajeValidacion {
+		case "Paquete no encontrado", "El paquete no está disponible", "La agencia no está disponible":
+			return nil, ErrCotizacionPaqueteNoDisponible
+		}
+		return nil, errors.New(*mensajeValidacion)
+	}
+
+	resp := &models.CotizacionCompraResponse{
+		PaqueteID:           req.PaqueteID,
+		FechaSeleccionada:   fecha.Format("2006-01-02"),
+		TipoCompra:          req.TipoCompra,
+		Extranjero:          req.Extranjero,
+		CantidadAdultos:     req.CantidadAdultos,
+		CantidadNinosPagan:  req.CantidadNinosPagan,
+		CantidadNinosGratis: req.CantidadNinosGratis,
+		TotalParticipantes:  req.CantidadAdultos + req.CantidadNinosPagan + req.CantidadNinosGratis,
+		PersonasQuePagan:    req.CantidadAdultos + req.CantidadNinosPagan,
+	}
+
+	// Disponibilidad: mismas reglas que la compra, sin reservar
+	var mensajeDisponibilidad *string
+	if req.TipoCompra == "compartido" {
+		if err := validarCupoMinimoPrimeraSalida(s.db, req.PaqueteID, fecha, resp.TotalParticipantes); err != nil {
+			mensaje := err.Error()
+			mensajeDisponibilidad = &mensaje
+		}
+	}
+	reserva, err := verificarSalidaPaquete(s.db, req.PaqueteID, fecha, req.TipoCompra, resp.TotalParticipantes)
+	if err != nil {
+		return nil, err
+	}
+	if reserva.Mensaje != nil && *reserva.Mensaje != "" {
+		mensajeDisponibilidad = reserva.Mensaje
+	}
+	resp.Disponible = mensajeDisponibilidad == nil
+	resp.MensajeDisponibilidad = mensajeDisponibilidad
+	if resp.Disponible {
+		resp.NuevaSalida = reserva.NuevaSalida
+		resp.CuposDisponibles = reserva.CuposDisponibles
+		if reserva.SalidaID != 0 {
+			salidaID := reserva.SalidaID
+			resp.SalidaID = &salidaID
+		}
+	}
+
+	cot, err := cotizarCompraPaquete(s.db, req.PaqueteID, fecha, reserva.SalidaID, req.TipoCompra, req.Extranjero, req.CantidadAdultos, req.CantidadNinosPagan)
+	if err != nil {
+		return nil, err
+	}
+	resp.PrecioUnitario = cot.PrecioUnitario
+	resp.ReglaPrecio = cot.ReglaPrecio
+	resp.TemporadaPrecioID = cot.TemporadaPrecioID
+	resp.Subtotal = cot.Subtotal
+	resp.RecargoPrivadoPorcentaje = cot.RecargoPrivadoPorcentaje
+	resp.RecargoExtranjero = cot.RecargoExtranjero
+	resp.TotalRecargo = cot.TotalRecargo
+	resp.PrecioTotal = cot.PrecioTotal
+
+	var paquete models.PaqueteTuristico
+	if err := s.db.Select("id", "agencia_id", "duracion_dias").First(&paquete, req.PaqueteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCotizacionPaqueteNoDisponible
+		}
+		return nil, err
+	}
+
+	// Reglas automáticas de descuento + código de promoción (mismo pipeline que la compra)
+	var promo *models.Promocion
+	if req.CodigoPromocion != nil && strings.TrimSpace(*req.CodigoPromocion) != "" {
+		promo, err = buscarPromocionPorCodigo(s.db, req.PaqueteID, *req.CodigoPromocion, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := validarPromocion(s.db, promo, req.PaqueteID, nil); err != nil {
+			return nil, err
+		}
+	}
+	cupoMinimo, ocupados, err := ocupacionSalidaDescuentos(s.db, req.PaqueteID, reserva.SalidaID, 0)
+	if err != nil {
+		return nil, err
+	}
+	detalle, descuento, err := calcularDescuentosCompra(s.db, &entradaDescuentos{
+		AgenciaID:        paquete.AgenciaID,
+		PaqueteID:        req.PaqueteID,
+		TipoCompra:       req.TipoCompra,
+		FechaReserva:     time.Now(),
+		FechaSalida:      fecha,
+		PersonasPagan:    resp.PersonasQuePagan,
+		CupoMinimoSalida: cupoMinimo,
+		OcupadosPrevios:  ocupados,
+		Precio:           cot.PrecioTotal,
+	}, promo)
+	if err != nil {
+		return nil, err
+	}
+	if promo != nil {
+		if montoPromocionDetalle(detalle) <= 0 {
+			return nil, errors.New("el código de promoción no genera descuento para esta compra")
+		}
+		resp.CodigoPromocion = &promo.Codigo
+	}
+	resp.Descuentos = detalle
+	resp.DescuentoAplicado = descuento
+	resp.PrecioSinDescuento, _, resp.PrecioTotal = totalesDescuento(cot.PrecioTotal, descuento)
+	resp.MontoAnticipo, resp.FechaLimiteSaldo, err = politicaAnticipoCompra(s.db, &paquete, resp.PrecioTotal, fecha)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
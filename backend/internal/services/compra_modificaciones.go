@@ -0,0 +1,472 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// reservaSalidaResult es el resultado de public.reservar_salida_paquete.
+type reservaSalidaResult struct {
+	SalidaID         uint    `gorm:"column:salida_id"`
+	Mensaje          *string `gorm:"column:mensaje"`
+	NuevaSalida      bool    `gorm:"column:nueva_salida"`
+	CuposDisponibles int     `gorm:"column:cupos_disponibles"`
+}
+
+// cotizacionCompra es el resultado de public.cotizar_compra_paquete (precio sin descuentos).
+type cotizacionCompra struct {
+	PrecioUnitario           float64 `gorm:"column:precio_unitario"`
+	RecargoPrivadoPorcentaje float64 `gorm:"column:recargo_privado_porcentaje"`
+	RecargoExtranjero        float64 `gorm:"column:recargo_extranjero"`
+	Subtotal                 float64 `gorm:"column:subtotal"`
+	TotalRecargo             float64 `gorm:"column:total_recargo"`
+	PrecioTotal              float64 `gorm:"column:precio_total"`
+	ReglaPrecio              string  `gorm:"column:regla_precio"`
+	TemporadaPrecioID        *uint   `gorm:"column:temporada_precio_id"`
+}
+
+// reservarSalidaPaquete elige (o crea) la salida con reservar_salida_paquete y reserva en ella los cupos.
+func reservarSalidaPaquete(tx *gorm.DB, paqueteID uint, fecha time.Time, tipoCompra string, total int) (uint, error) {
+	var reserva reservaSalidaResult
+	if err := tx.Raw(`SELECT * FROM public.reservar_salida_paquete(?::int, ?::date, ?::text, ?::int)`,
+		paqueteID, fecha.Format("2006-01-02"), tipoCompra, total).
+		Scan(&reserva).Error; err != nil {
+		return 0, err
+	}
+	if reserva.SalidaID == 0 {
+		if reserva.Mensaje != nil && *reserva.Mensaje != "" {
+			return 0, errors.New(*reserva.Mensaje)
+		}
+		return 0, errors.New("no se pudo reservar cupos para la fecha seleccionada")
+	}
+	if err := NewInventarioService(tx).Reservar(reserva.SalidaID, total); err != nil {
+		return 0, err
+	}
+	return reserva.SalidaID, nil
+}
+
+// verificarSalidaPaquete aplica las reglas de reservarSalidaPaquete sin reservar cupos ni crear salidas.
+func verificarSalidaPaquete(db *gorm.DB, paqueteID uint, fecha time.Time, tipoCompra string, total int) (*reservaSalidaResult, error) {
+	var reserva reservaSalidaResult
+	if err := db.Raw(`SELECT * FROM public.reservar_salida_paquete(?::int, ?::date, ?::text, ?::int, TRUE)`,
+		paqueteID, fecha.Format("2006-01-02"), tipoCompra, total).
+		Scan(&reserva).Error; err != nil {
+		return nil, err
+	}
+	return &reserva, nil
+}
+
+// cotizarCompraPaquete calcula el precio de una compra en la fecha indicada.
+// salidaID es 0 si la compra crearía una salida nueva (no aplica precio especial de salida).
+func cotizarCompraPaquete(tx *gorm.DB, paqueteID uint, fecha time.Time, salidaID uint, tipoCompra string, extranjero bool, adultos, ninosPagan int) (*cotizacionCompra, error) {
+	var cot []cotizacionCompra
+	if err := tx.Raw(`SELECT * FROM public.cotizar_compra_paquete(?::int, ?::date, ?::int, ?::text, ?::boolean, ?::int, ?::int)`,
+		paqueteID, fecha.Format("2006-01-02"), salidaID, tipoCompra, extranjero, adultos, ninosPagan).
+		Scan(&cot).Error; err != nil {
+		return nil, err
+	}
+	if len(cot) == 0 {
+		return nil, errors.New("paquete no encontrado")
+	}
+	return &cot[0], nil
+}
+
+func participantesARequests(ps []models.CompraParticipante) []models.ParticipanteRequest {
+	out := make([]models.ParticipanteRequest, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, models.ParticipanteRequest{
+			Tipo:                       p.Tipo,
+			NombreCompleto:             p.NombreCompleto,
+			TipoDocumento:              p.TipoDocumento,
+			NumeroDocumento:            p.NumeroDocumento,
+			Nacionalidad:               p.Nacionalidad,
+			FechaNacimiento:            p.FechaNacimiento.Format("2006-01-02"),
+			ContactoEmergenciaNombre:   p.ContactoEmergenciaNombre,
+			ContactoEmergenciaTelefono: p.ContactoEmergenciaTelefono,
+			Notas:                      p.Notas,
+		})
+	}
+	return out
+}
+
+// ModificarCompra mueve una compra a otra fecha/salida, cambia su tipo o sus cantidades.
+// Los cupos se mueven entre salidas y el precio se recalcula con las mismas reglas que
+// procesar_compra_paquete, todo en una sola transacción. Si lo ya pagado supera el nuevo
+// precio se genera un reembolso; si no alcanza, la compra queda con saldo pendiente.
+func (s *CompraService) ModificarCompra(compraID uint, turistaID uint, req *models.ModificarCompraRequest) (*models.ModificarCompraResponse, error) {
+	var actual models.CompraPaquete
+	if err := s.db.Preload("Participantes").Where("id = ? AND turista_id = ?", compraID, turistaID).First(&actual).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("compra no encontrada")
+		}
+		return nil, err
+	}
+
+	if actual.Status != "pendiente_confirmacion" && actual.Status != "reservada_con_anticipo" && actual.Status != "confirmada" {
+		return nil, fmt.Errorf("no se puede modificar una compra con estado: %s", actual.Status)
+	}
+
+	hoy := fechaHoyUTC()
+	fechaActual := time.Date(actual.FechaSeleccionada.Year(), actual.FechaSeleccionada.Month(), actual.FechaSeleccionada.Day(), 0, 0, 0, 0, time.UTC)
+	if fechaActual.Before(hoy) {
+		return nil, errors.New("no se puede modificar una compra cuya salida ya se realizó")
+	}
+
+	fecha := fechaActual
+	if req.FechaSeleccionada != nil {
+		parsed, err := time.Parse("2006-01-02", strings.TrimSpace(*req.FechaSeleccionada))
+		if err != nil {
+			return nil, errors.New("fecha_seleccionada inválida (use YYYY-MM-DD)")
+		}
+		fecha = parsed
+	}
+	tipo := actual.TipoCompra
+	if req.TipoCompra != nil {
+		tipo = *req.TipoCompra
+	}
+	adultos := actual.CantidadAdultos
+	if req.CantidadAdultos != nil {
+		adultos = *req.CantidadAdultos
+	}
+	ninosPagan := actual.CantidadNinosPagan
+	if req.CantidadNinosPagan != nil {
+		ninosPagan = *req.CantidadNinosPagan
+	}
+	ninosGratis := actual.CantidadNinosGratis
+	if req.CantidadNinosGratis != nil {
+		ninosGratis = *req.CantidadNinosGratis
+	}
+	total := adultos + ninosPagan + ninosGratis
+
+	cambiaFecha := !fecha.Equal(fechaActual)
+	cambiaTipo := tipo != actual.TipoCompra
+	cambianCantidades := adultos != actual.CantidadAdultos || ninosPagan != actual.CantidadNinosPagan || ninosGratis != actual.CantidadNinosGratis
+	if !cambiaFecha && !cambiaTipo && !cambianCantidades {
+		return nil, errors.New("no hay cambios que aplicar")
+	}
+
+	if tipo == "compartido" && (cambiaFecha || cambiaTipo) {
+		if err := validarCupoMinimoPrimeraSalida(s.db, actual.PaqueteID, fecha, total); err != nil {
+			return nil, err
+		}
+	}
+
+	// Participantes: el listado enviado reemplaza al actual; si solo cambia la fecha se revalidan las edades.
+	var participantes []models.CompraParticipante
+	reemplazarParticipantes := cambianCantidades || len(req.Participantes) > 0
+	reqParticipantes := req.Participantes
+	if len(reqParticipantes) == 0 && !cambianCantidades && cambiaFecha && len(actual.Participantes) > 0 {
+		reqParticipantes = participantesARequests(actual.Participantes)
+		reemplazarParticipantes = true
+	}
+	if len(reqParticipantes) > 0 {
+		edadMinima, edadAdulto, err := edadesParticipantesAgencia(s.db, actual.PaqueteID)
+		if err != nil {
+			return nil, err
+		}
+		participantes, err = validarParticipantes(edadMinima, edadAdulto, fecha, adultos, ninosPagan, ninosGratis, reqParticipantes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &models.ModificarCompraResponse{}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var compra models.CompraPaquete
+		if err := tx.Raw(`SELECT * FROM compras_paquetes WHERE id = ? FOR UPDATE`, actual.ID).Scan(&compra).Error; err != nil {
+			return err
+		}
+		if compra.Status != actual.Status || compra.PrecioTotal != actual.PrecioTotal {
+			return errors.New("la compra cambió mientras se procesaba la modificación, intente nuevamente")
+		}
+
+		var pagosPendientes int64
+		if err := tx.Model(&models.PagoCompra{}).
+			Where("compra_id = ? AND estado = ?", compra.ID, "pendiente").
+			Count(&pagosPendientes).Error; err != nil {
+			return err
+		}
+		if pagosPendientes > 0 {
+			return errors.New("no se puede modificar, hay pagos pendientes de revisión")
+		}
+
+		pagado, err := montoConfirmadoCompra(tx, compra.ID)
+		if err != nil {
+			return err
+		}
+
+		var paquete models.PaqueteTuristico
+		if err := tx.Select("id", "nombre", "duracion_dias", "horario").First(&paquete, compra.PaqueteID).Error; err != nil {
+			return err
+		}
+
+		// Mover cupos: liberar la salida actual y reservar en la nueva (puede ser la misma)
+		if compra.SalidaID != nil {
+			if err := NewInventarioService(tx).LiberarCompra(&compra); err != nil {
+				return err
+			}
+		}
+		salidaNuevaID, err := reservarSalidaPaquete(tx, compra.PaqueteID, fecha, tipo, total)
+		if err != nil {
+			return err
+		}
+
+		cot, err := cotizarCompraPaquete(tx, compra.PaqueteID, fecha, salidaNuevaID, tipo, compra.Extranjero, adultos, ninosPagan)
+		if err != nil {
+			return err
+		}
+
+		// Reevaluar reglas automáticas (respecto de la fecha original de compra) y mantener la
+		// promoción usada al comprar, sin volver a consumir usos
+		var promo *models.Promocion
+		if compra.PromocionID != nil {
+			promo = &models.Promocion{}
+			if err := tx.First(promo, *compra.PromocionID).Error; err != nil {
+				return err
+			}
+		}
+		compraEvaluada := compra
+		compraEvaluada.SalidaID = &salidaNuevaID
+		compraEvaluada.FechaSeleccionada = fecha
+		compraEvaluada.TipoCompra = tipo
+		compraEvaluada.CantidadAdultos = adultos
+		compraEvaluada.CantidadNinosPagan = ninosPagan
+		compraEvaluada.TotalParticipantes = total
+		entrada, err := entradaDescuentosCompra(tx, &compraEvaluada, cot.PrecioTotal)
+		if err != nil {
+			return err
+		}
+		detalleDescuentos, descuento, err := calcularDescuentosCompra(tx, entrada, promo)
+		if err != nil {
+			return err
+		}
+		precioSinDescuento, porcentajeDescuento, precioFinal := totalesDescuento(cot.PrecioTotal, descuento)
+		if err := reemplazarDetalleDescuentos(tx, compra.ID, detalleDescuentos); err != nil {
+			return err
+		}
+		if promo != nil {
+			if err := tx.Model(&models.PromocionUso{}).
+				Where("compra_id = ?", compra.ID).
+				Update("monto_descuento", montoPromocionDetalle(detalleDescuentos)).Error; err != nil {
+				return err
+			}
+		}
+
+		var horario *string
+		if paquete.DuracionDias == nil || *paquete.DuracionDias <= 1 {
+			horario = paquete.Horario
+		}
+
+		// Estado resultante según lo ya pagado
+		nuevoStatus := compra.Status
+		montoPagado := pagado
+		var reembolso *models.Reembolso
+		if pagado > 0.01 {
+			switch {
+			case pagado > precioFinal+0.01:
+				exceso := redondearMonto(pagado - precioFinal)
+				reembolso, err = registrarReembolso(tx, &compra, "modificacion", req.Motivo, exceso, 100, nil)
+				if err != nil {
+					return err
+				}
+				montoPagado = precioFinal
+				nuevoStatus = "confirmada"
+			case precioFinal-pagado <= 0.01:
+				nuevoStatus = "confirmada"
+			default:
+				nuevoStatus = "reservada_con_anticipo"
+			}
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"salida_id":                     salidaNuevaID,
+			"fecha_seleccionada":            fecha,
+			"horario_seleccionado":          horario,
+			"tipo_compra":                   tipo,
+			"cantidad_adultos":              adultos,
+			"cantidad_ninos_pagan":          ninosPagan,
+			"cantidad_ninos_gratis":         ninosGratis,
+			"total_participantes":           total,
+			"precio_unitario":               cot.PrecioUnitario,
+			"regla_precio":                  cot.ReglaPrecio,
+			"temporada_precio_id":           cot.TemporadaPrecioID,
+			"recargo_privado_porcentaje":    cot.RecargoPrivadoPorcentaje,
+			"recargo_extranjero":            cot.RecargoExtranjero,
+			"subtotal":                      cot.Subtotal,
+			"total_recargo":                 cot.TotalRecargo,
+			"precio_total":                  precioFinal,
+			"precio_sin_descuento":          precioSinDescuento,
+			"descuento_aplicado":            descuento,
+			"porcentaje_descuento_aplicado": porcentajeDescuento,
+			"monto_pagado":                  montoPagado,
+			"status":                        nuevoStatus,
+			"monto_anticipo":                nil,
+			"fecha_limite_saldo":            nil,
+			"ultimo_recordatorio_saldo":     nil,
+			"updated_at":                    now,
+		}
+		if nuevoStatus == "confirmada" && compra.Status != "confirmada" {
+			updates["fecha_confirmacion"] = now
+			updates["codigo_confirmacion"] = gorm.Expr("COALESCE(codigo_confirmacion, ?)", fmt.Sprintf("CONF-%06d", compra.ID))
+		}
+		if err := tx.Model(&models.CompraPaquete{}).Where("id = ?", compra.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		// reservarSalidaPaquete deja los cupos como reservados; una compra pagada los ocupa como confirmados
+		if nuevoStatus == "confirmada" {
+			if err := NewInventarioService(tx).ConfirmarReservados(salidaNuevaID, total); err != nil {
+				return err
+			}
+			// Venta nueva o diferencia de precio en el libro de comisiones
+			if err := registrarVentaComision(tx, compra.ID); err != nil {
+				return err
+			}
+		} else {
+			actualizada, err := aplicarPoliticaAnticipo(tx, compra.ID)
+			if err != nil {
+				return err
+			}
+			if nuevoStatus == "reservada_con_anticipo" && actualizada.FechaLimiteSaldo == nil {
+				// Sin plazo según la política: el saldo vence de inmediato
+				if err := tx.Model(&models.CompraPaquete{}).Where("id = ?", compra.ID).
+					Update("fecha_limite_saldo", hoy).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		// Cupos liberados en la salida anterior: ofrecerlos a la lista de espera
+		if compra.SalidaID != nil {
+			if err := ofrecerCuposListaEspera(tx, *compra.SalidaID); err != nil {
+				return err
+			}
+			if *compra.SalidaID != salidaNuevaID {
+				if err := cancelSalidaIfEmpty(tx, *compra.SalidaID, "Salida cancelada por modificación de compra"); err != nil {
+					return err
+				}
+			}
+		}
+
+		if reemplazarParticipantes {
+			if err := tx.Where("compra_id = ?", compra.ID).Delete(&models.CompraParticipante{}).Error; err != nil {
+				return err
+			}
+			if err := guardarParticipantes(tx, compra.ID, participantes); err != nil {
+				return err
+			}
+		}
+
+		modificacion := models.CompraModificacion{
+			CompraID:            compra.ID,
+			RealizadoPor:        turistaID,
+			SalidaAnteriorID:    compra.SalidaID,
+			SalidaNuevaID:       &salidaNuevaID,
+			FechaAnterior:       fechaActual,
+			FechaNueva:          fecha,
+			TipoCompraAnterior:  compra.TipoCompra,
+			TipoCompraNuevo:     tipo,
+			AdultosAnterior:     compra.CantidadAdultos,
+			NinosPaganAnterior:  compra.CantidadNinosPagan,
+			NinosGratisAnterior: compra.CantidadNinosGratis,
+			AdultosNuevo:        adultos,
+			NinosPaganNuevo:     ninosPagan,
+			NinosGratisNuevo:    ninosGratis,
+			PrecioAnterior:      compra.PrecioTotal,
+			PrecioNuevo:         precioFinal,
+			Diferencia:          redondearMonto(precioFinal - compra.PrecioTotal),
+			StatusAnterior:      compra.Status,
+			StatusNuevo:         nuevoStatus,
+			Motivo:              req.Motivo,
+		}
+		if reembolso != nil {
+			modificacion.ReembolsoID = &reembolso.ID
+		}
+		if err := tx.Create(&modificacion).Error; err != nil {
+			return err
+		}
+
+		encargadoID, err := encargadoPrincipalDePaquete(tx, compra.PaqueteID)
+		if err != nil {
+			return err
+		}
+		if encargadoID != 0 {
+			if _, err := notificarUsuario(tx, encargadoID, models.TipoCompraModificada,
+				"Compra modificada",
+				fmt.Sprintf("La compra #%d de \"%s\" fue modificada: %s, %s, %d participantes (diferencia Bs %.2f)",
+					compra.ID, paquete.Nombre, fecha.Format("2006-01-02"), tipo, total, modificacion.Diferencia),
+				models.NotifDatosJSON{
+					"compra_id":       compra.ID,
+					"modificacion_id": modificacion.ID,
+					"paquete_id":      compra.PaqueteID,
+					"salida_id":       salidaNuevaID,
+					"diferencia":      modificacion.Diferencia,
+				}); err != nil {
+				return err
+			}
+		}
+
+		resp.Modificacion = modificacion
+		resp.PrecioTotal = precioFinal
+		resp.MontoPagado = montoPagado
+		resp.SaldoPendiente = redondearMonto(precioFinal - montoPagado)
+		if resp.SaldoPendiente < 0 {
+			resp.SaldoPendiente = 0
+		}
+		resp.Reembolso = reembolso
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ListarModificacionesCompra retorna el historial de modificaciones de una compra del turista.
+func (s *CompraService) ListarModificacionesCompra(compraID uint, turistaID uint) ([]models.CompraModificacion, error) {
+	var compra models.CompraPaquete
+	if err := s.db.Select("id").Where("id = ? AND turista_id = ?", compraID, turistaID).First(&compra).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("compra no encontrada")
+		}
+		return nil, err
+	}
+
+	return cargarModificacionesCompra(s.db, compraID)
+}
+
+func cargarModificacionesCompra(db *gorm.DB, compraID uint) ([]models.CompraModificacion, error) {
+	var modificaciones []models.CompraModificacion
+	if err := db.Where("compra_id = ?", compraID).
+		Order("created_at DESC").
+		Order("id DESC").
+		Find(&modificaciones).Error; err != nil {
+		return nil, err
+	}
+	return modificaciones, nil
+}
+
+// ListarModificacionesCompraAgencia retorna el historial de una compra de un paquete de la agencia.
+func ListarModificacionesCompraAgencia(db *gorm.DB, agenciaID uint, compraID uint) ([]models.CompraModificacion, error) {
+	var existe int64
+	if err := db.Table("compras_paquetes c").
+		Joins("JOIN paquetes_turisticos p ON p.id = c.paquete_id").
+		Where("c.id = ? AND p.agencia_id = ?", compraID, agenciaID).
+		Count(&existe).Error; err != nil {
+		return nil, err
+	}
+	if existe == 0 {
+		return nil, errors.New("compra no encontrada")
+	}
+
+	return cargarModificacionesCompra(db, compraID)
+}
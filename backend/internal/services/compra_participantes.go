@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func edadEnFecha(nacimiento time.Time, fecha time.Time) int {
+	edad := fecha.Year() - nacimiento.Year()
+	if fecha.Month() < nacimiento.Month() || (fecha.Month() == nacimiento.Month() && fecha.Day() < nacimiento.Day()) {
+		edad--
+	}
+	return edad
+}
+
+// edadesParticipantesAgencia retorna, según la política de la agencia del paquete, la edad desde la que un
+// niño paga y la edad desde la que un participante es adulto.
+func edadesParticipantesAgencia(db *gorm.DB, paqueteID uint) (int, int, error) {
+	var paquete models.PaqueteTuristico
+	if err := db.Select("id", "agencia_id").First(&paquete, paqueteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, errors.New("paquete no encontrado")
+		}
+		return 0, 0, err
+	}
+
+	var politicas models.PaquetePolitica
+	if err := db.Where("agencia_id = ?", paquete.AgenciaID).First(&politicas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 6, 18, nil
+		}
+		return 0, 0, err
+	}
+	return politicas.EdadMinimaPago, politicas.EdadAdulto, nil
+}
+
+// validarParticipantes verifica que el listado coincida con las cantidades de la compra
+// y que la edad de cada participante (a la fecha de salida) corresponda a su tipo.
+func validarParticipantes(
+	edadMinimaPago, edadAdulto int,
+	fechaSalida time.Time,
+	adultos, ninosPagan, ninosGratis int,
+	reqs []models.ParticipanteRequest,
+) ([]models.CompraParticipante, error) {
+	total := adultos + ninosPagan + ninosGratis
+	if len(reqs) != total {
+		return nil, fmt.Errorf("debe registrar exactamente %d participantes (recibidos: %d)", total, len(reqs))
+	}
+
+	hoy := time.Now()
+	conteo := map[string]int{}
+	documentos := map[string]bool{}
+	out := make([]models.CompraParticipante, 0, len(reqs))
+
+	for i, req := range reqs {
+		pos := i + 1
+		nombre := strings.TrimSpace(req.NombreCompleto)
+		if nombre == "" {
+			return nil, fmt.Errorf("participante %d: nombre_completo es requerido", pos)
+		}
+
+		numeroDoc := strings.ToUpper(strings.TrimSpace(req.NumeroDocumento))
+		if numeroDoc == "" {
+			return nil, fmt.Errorf("participante %d: numero_documento es requerido", pos)
+		}
+		claveDoc := req.TipoDocumento + ":" + numeroDoc
+		if documentos[claveDoc] {
+			return nil, fmt.Errorf("participante %d: documento %s repetido", pos, numeroDoc)
+		}
+		documentos[claveDoc] = true
+
+		nacimiento, err := time.Parse("2006-01-02", strings.TrimSpace(req.FechaNacimiento))
+		if err != nil {
+			return nil, fmt.Errorf("participante %d: fecha_nacimiento inválida (use YYYY-MM-DD)", pos)
+		}
+		if nacimiento.After(hoy) {
+			return nil, fmt.Errorf("participante %d: fecha_nacimiento no puede ser futura", pos)
+		}
+
+		edad := edadEnFecha(nacimiento, fechaSalida)
+		switch req.Tipo {
+		case "adulto":
+			if edad < edadAdulto {
+				return nil, fmt.Errorf("participante %d: %s tendrá %d años a la fecha de salida y no puede registrarse como adulto", pos, nombre, edad)
+			}
+		case "nino_paga":
+			if edad < edadMinimaPago || edad >= edadAdulto {
+				return nil, fmt.Errorf("participante %d: un niño que paga debe tener entre %d y %d años a la fecha de salida", pos, edadMinimaPago, edadAdulto-1)
+			}
+		case "nino_gratis":
+			if edad >= edadMinimaPago {
+				return nil, fmt.Errorf("participante %d: un niño gratis debe ser menor de %d años a la fecha de salida", pos, edadMinimaPago)
+			}
+		default:
+			return nil, fmt.Errorf("participante %d: tipo inválido", pos)
+		}
+		conteo[req.Tipo]++
+
+		out = append(out, models.CompraParticipante{
+			Tipo:                       req.Tipo,
+			NombreCompleto:             nombre,
+			TipoDocumento:              req.TipoDocumento,
+			NumeroDocumento:            numeroDoc,
+			Nacionalidad:               strings.TrimSpace(req.Nacionalidad),
+			FechaNacimiento:            nacimiento,
+			ContactoEmergenciaNombre:   strings.TrimSpace(req.ContactoEmergenciaNombre),
+			ContactoEmergenciaTelefono: strings.TrimSpace(req.ContactoEmergenciaTelefono),
+			Notas:                      req.Notas,
+		})
+	}
+
+	if conteo["adulto"] != adultos {
+		return nil, fmt.Errorf("la compra tiene %d adultos pero se registraron %d", adultos, conteo["adulto"])
+	}
+	if conteo["nino_paga"] != ninosPagan {
+		return nil, fmt.Errorf("la compra tiene %d niños que pagan pero se registraron %d", ninosPagan, conteo["nino_paga"])
+	}
+	if conteo["nino_gratis"] != ninosGratis {
+		return nil, fmt.Errorf("la compra tiene %d niños gratis pero se registraron %d", ninosGratis, conteo["nino_gratis"])
+	}
+
+	return out, nil
+}
+
+func guardarParticipantes(tx *gorm.DB, compraID uint, participantes []models.CompraParticipante) error {
+	if len(participantes) == 0 {
+		return nil
+	}
+	for i := range participantes {
+		participantes[i].ID = 0
+		participantes[i].CompraID = compraID
+	}
+	return tx.Create(&participantes).Error
+}
+
+// ActualizarParticipantes reemplaza el listado de participantes de una compra del turista.
+func (s *CompraService) ActualizarParticipantes(compraID uint, turistaID uint, req *models.ActualizarParticipantesRequest) ([]models.CompraParticipante, error) {
+	var compra models.CompraPaquete
+	if err := s.db.Where("id = ? AND turista_id = ?", compraID, turistaID).First(&compra).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("compra no encontrada")
+		}
+		return nil, err
+	}
+
+	if compra.Status != "pendiente_confirmacion" && compra.Status != "reservada_con_anticipo" && compra.Status != "confirmada" {
+		return nil, fmt.Errorf("no se pueden modificar participantes de una compra con estado: %s", compra.Status)
+	}
+
+	hoy := time.Now().Truncate(24 * time.Hour)
+	if compra.FechaSeleccionada.Before(hoy) {
+		return nil, errors.New("no se pueden modificar participantes de una salida pasada")
+	}
+
+	edadMinima, edadAdulto, err := edadesParticipantesAgencia(s.db, compra.PaqueteID)
+	if err != nil {
+		return nil, err
+	}
+
+	participantes, err := validarParticipantes(
+		edadMinima,
+		edadAdulto,
+		compra.FechaSeleccionada,
+		compra.CantidadAdultos,
+		compra.CantidadNinosPagan,
+		compra.CantidadNinosGratis,
+		req.Participantes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("compra_id = ?", compra.ID).Delete(&models.CompraParticipante{}).Error; err != nil {
+			return err
+		}
+		return guardarParticipantes(tx, compra.ID, participantes)
+	}); err != nil {
+		return nil, err
+	}
+
+	return participantes, nil
+}
+
+func buildParticipanteDetalle(p models.CompraParticipante, fechaSalida time.Time) models.ParticipanteDetalle {
+	id := p.ID
+	nombre := p.NombreCompleto
+	edad := edadEnFecha(p.FechaNacimiento, fechaSalida)
+	tipoDoc := p.TipoDocumento
+	numeroDoc := p.NumeroDocumento
+	nacionalidad := p.Nacionalidad
+	nacimiento := p.FechaNacimiento.Format("2006-01-02")
+	contactoNombre := p.ContactoEmergenciaNombre
+	contactoTelefono := p.ContactoEmergenciaTelefono
+
+	return models.ParticipanteDetalle{
+		ID:                         &id,
+		Nombre:                     &nombre,
+		Edad:                       &edad,
+		Tipo:                       p.Tipo,
+		Notas:                      p.Notas,
+		TipoDocumento:              &tipoDoc,
+		NumeroDocumento:            &numeroDoc,
+		Nacionalidad:               &nacionalidad,
+		FechaNacimiento:            &nacimiento,
+		ContactoEmergenciaNombre:   &contactoNombre,
+		ContactoEmergenciaTelefono: &contactoTelefono,
+	}
+}
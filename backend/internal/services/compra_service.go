@@ -31,6 +31,12 @@ func isFunctionResultMismatchError(err error) bool {
 	return errors.As(err, &pgErr) && pgErr.Code == "42804"
 }
 
+// isCheckViolationError detecta la violación de una restricción CHECK (p. ej. chk_salida_cupos).
+func isCheckViolationError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
+
 func buildCodigoConfirmacion(compra *models.CompraPaquete) *string {
 	if compra == nil {
 		return nil
@@ -53,7 +59,29 @@ func buildParticipanteSlice(tipo string, count int) []models.ParticipanteDetalle
 	return out
 }
 
-func buildParticipantesDetalle(adultos, ninosPagan, ninosGratis int) *models.ParticipantesDetalle {
+func buildParticipantesDetalle(compra *models.CompraPaquete) *models.ParticipantesDetalle {
+	if compra == nil {
+		return nil
+	}
+
+	// Si el turista ya registró el manifiesto, se exponen los participantes reales.
+	if len(compra.Participantes) > 0 {
+		out := &models.ParticipantesDetalle{}
+		for _, p := range compra.Participantes {
+			detalle := buildParticipanteDetalle(p, compra.FechaSeleccionada)
+			switch p.Tipo {
+			case "adulto":
+				out.Adultos = append(out.Adultos, detalle)
+			case "nino_paga":
+				out.NinosPagan = append(out.NinosPagan, detalle)
+			case "nino_gratis":
+				out.NinosGratis = append(out.NinosGratis, detalle)
+			}
+		}
+		return out
+	}
+
+	adultos, ninosPagan, ninosGratis := compra.CantidadAdultos, compra.CantidadNinosPagan, compra.CantidadNinosGratis
 	if adultos <= 0 && ninosPagan <= 0 && ninosGratis <= 0 {
 		return nil
 	}
@@ -114,7 +142,54 @@ func buildPaqueteDetalleResponse(paquete *models.PaqueteTuristico) models.Paquet
 	}
 }
 
+// validarCupoMinimoPrimeraSalida exige el cupo mínimo del paquete cuando todavía no existe una salida
+// compartida habilitada para la fecha (la compra crearía la primera salida).
+func validarCupoMinimoPrimeraSalida(db *gorm.DB, paqueteID uint, fecha time.Time, totalParticipantes int) error {
+	fechaStr := fecha.Format("2006-01-02")
+
+	var salidaExiste bool
+	if err := db.Raw(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM paquete_salidas_habilitadas
+			WHERE paquete_id = ?
+			  AND fecha_salida = ?
+			  AND tipo_salida = 'compartido'
+			  AND estado IN ('pendiente', 'activa')
+		)
+	`, paqueteID, fechaStr).Scan(&salidaExiste).Error; err != nil {
+		return err
+	}
+
+	if salidaExiste {
+		return nil
+	}
+
+	var paquete models.PaqueteTuristico
+	if err := db.Select("cupo_minimo").First(&paquete, paqueteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	min := paquete.CupoMinimo
+	if min < 1 {
+		min = 1
+	}
+	if totalParticipantes < min {
+		return fmt.Errorf("para habilitar la primera salida en esta fecha debe registrar al menos %d participantes", min)
+	}
+	return nil
+}
+
 func (s *CompraService) CrearCompra(turistaID uint, req *models.CrearCompraRequest) (*models.ProcesarCompraPaqueteResult, error) {
+	return s.crearCompra(turistaID, req, nil)
+}
+
+// crearCompra registra la compra. Si listaEsperaID no es nil, la compra consume la oferta vigente
+// de esa entrada de lista de espera dentro de la misma transacción.
+func (s *CompraService) crearCompra(turistaID uint, req *models.CrearCompraRequest, listaEsperaID *uint) (*models.ProcesarCompraPaqueteResult, error) {
 	fecha, err := time.Parse("2006-01-02", req.FechaSeleccionada)
 	if err != nil {
 		return nil, fmt.Errorf("fecha_seleccionada inválida (use YYYY-MM-DD)")
@@ -130,39 +205,22 @@ func (s *CompraService) CrearCompra(turistaID uint, req *models.CrearCompraReque
 	}
 	totalParticipantes := req.CantidadAdultos + ninosPagan + ninosGratis
 
-	// Si no existe una salida habilitada para la fecha, exigir cupo mínimo para crear la primera salida compartida.
 	if req.TipoCompra == "compartido" {
-		fechaStr := fecha.Format("2006-01-02")
-
-		var salidaExiste bool
-		if err := s.db.Raw(`
-			SELECT EXISTS (
-				SELECT 1
-				FROM paquete_salidas_habilitadas
-				WHERE paquete_id = ?
-				  AND fecha_salida = ?
-				  AND tipo_salida = 'compartido'
-				  AND estado IN ('pendiente', 'activa')
-			)
-		`, req.PaqueteID, fechaStr).Scan(&salidaExiste).Error; err != nil {
+		if err := validarCupoMinimoPrimeraSalida(s.db, req.PaqueteID, fecha, totalParticipantes); err != nil {
 			return nil, err
 		}
+	}
 
-		if !salidaExiste {
-			var paquete models.PaqueteTuristico
-			if err := s.db.Select("cupo_minimo").First(&paquete, req.PaqueteID).Error; err != nil {
-				if !errors.Is(err, gorm.ErrRecordNotFound) {
-					return nil, err
-				}
-			} else {
-				min := paquete.CupoMinimo
-				if min < 1 {
-					min = 1
-				}
-				if totalParticipantes < min {
-					return nil, fmt.Errorf("para habilitar la primera salida en esta fecha debe registrar al menos %d participantes", min)
-				}
-			}
+	// Validar participantes antes de reservar cupos (son opcionales al momento de la compra).
+	var participantes []models.CompraParticipante
+	if len(req.Participantes) > 0 {
+		edadMinima, edadAdulto, err := edadesParticipantesAgencia(s.db, req.PaqueteID)
+		if err != nil {
+			return nil, err
+		}
+		participantes, err = validarParticipantes(edadMinima, edadAdulto, fecha, req.CantidadAdultos, ninosPagan, ninosGratis, req.Participantes)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -182,15 +240,66 @@ func (s *CompraService) CrearCompra(turistaID uint, req *models.CrearCompraReque
 	}
 
 	var result models.ProcesarCompraPaqueteResult
-	if err := s.db.Raw(query, args...).Scan(&result).Error; err != nil {
+	procesar := func(tx *gorm.DB) error {
+		if listaEsperaID != nil {
+			if _, err := tomarOfertaListaEspera(tx, *listaEsperaID, turistaID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Raw(query, args...).Scan(&result).Error; err != nil {
+			return err
+		}
+		if !result.Success || result.CompraID == 0 {
+			if listaEsperaID != nil && result.Mensaje != "" {
+				// Revertir para que los cupos vuelvan a quedar retenidos para la oferta
+				return errors.New(result.Mensaje)
+			}
+			return nil
+		}
+		// procesar_compra_paquete elige la salida; los cupos se reservan aquí, en la misma transacción
+		if err := NewInventarioService(tx).Reservar(result.SalidaID, totalParticipantes); err != nil {
+			return err
+		}
+		// Reglas automáticas de descuento + código de promoción
+		compra, err := aplicarDescuentosCompra(tx, result.CompraID, turistaID, req.CodigoPromocion)
+		if err != nil {
+			return err
+		}
+		result.PrecioTotal = compra.PrecioTotal
+		result.PrecioSinDescuento = compra.PrecioSinDescuento
+		result.DescuentoAplicado = compra.DescuentoAplicado
+		compra, err = aplicarPoliticaAnticipo(tx, result.CompraID)
+		if err != nil {
+			return err
+		}
+		result.MontoAnticipo = compra.MontoAnticipo
+		result.FechaLimiteSaldo = compra.FechaLimiteSaldo
+		if listaEsperaID != nil {
+			if err := tx.Model(&models.ListaEsperaSalida{}).
+				Where("id = ?", *listaEsperaID).
+				Updates(map[string]interface{}{
+					"estado":     "convertida",
+					"compra_id":  result.CompraID,
+					"updated_at": time.Now(),
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return guardarParticipantes(tx, result.CompraID, participantes)
+	}
+
+	if err := s.db.Transaction(procesar); err != nil {
 		if isUndefinedFunctionError(err) || isFunctionResultMismatchError(err) {
 			if bootstrapErr := database.ApplySQLBootstrap(s.db); bootstrapErr != nil {
 				return nil, fmt.Errorf("la base de datos no est\u00e1 preparada (procesar_compra_paquete faltante o desactualizada): %w", bootstrapErr)
 			}
 
-			if retryErr := s.db.Raw(query, args...).Scan(&result).Error; retryErr != nil {
+			if retryErr := s.db.Transaction(procesar); retryErr != nil {
 				return nil, retryErr
 			}
+		} else if isCheckViolationError(err) {
+			// Otra compra tomó los últimos cupos de la salida
+			return nil, ErrCuposInsuficientes
 		} else {
 			return nil, err
 		}
@@ -231,7 +340,10 @@ func (s *CompraService) ObtenerDetalleCompra(compraID uint, turistaID uint) (*mo
 		}).
 		Preload("Salida").
 		Preload("Pagos", func(db *gorm.DB) *gorm.DB {
-			return db.Order("created_at DESC").Order("id DESC").Limit(1)
+			return db.Order("created_at DESC").Order("id DESC")
+		}).
+		Preload("Participantes", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
 		}).
 		Where("id = ? AND turista_id = ?", compraID, turistaID).
 		First(&compra).Error
@@ -263,6 +375,7 @@ func (s *CompraService) ObtenerDetalleCompra(compraID uint, turistaID uint) (*mo
 	resp := &models.CompraDetalleResponse{
 		ID:                     compra.ID,
 		CodigoConfirmacion:     buildCodigoConfirmacion(&compra),
+		ReferenciaPago:         ReferenciaPagoCompra(compra.ID),
 		FechaCompra:            compra.FechaCompra,
 		FechaSeleccionada:      compra.FechaSeleccionada,
 		FechaConfirmacion:      compra.FechaConfirmacion,
@@ -271,9 +384,15 @@ func (s *CompraService) ObtenerDetalleCompra(compraID uint, turistaID uint) (*mo
 		CantidadAdultos:        compra.CantidadAdultos,
 		CantidadNinosPagan:     compra.CantidadNinosPagan,
 		CantidadNinosGratis:    compra.CantidadNinosGratis,
-		Participantes:          buildParticipantesDetalle(compra.CantidadAdultos, compra.CantidadNinosPagan, compra.CantidadNinosGratis),
+		Participantes:          buildParticipantesDetalle(&compra),
 		TotalParticipantes:     compra.TotalParticipantes,
 		PrecioTotal:            compra.PrecioTotal,
+		PrecioSinDescuento:     compra.PrecioSinDescuento,
+		DescuentoAplicado:      compra.DescuentoAplicado,
+		MontoPagado:            compra.MontoPagado,
+		SaldoPendiente:         compra.SaldoPendiente(),
+		MontoAnticipo:          compra.MontoAnticipo,
+		FechaLimiteSaldo:       compra.FechaLimiteSaldo,
 		Status:                 compra.Status,
 		TieneDiscapacidad:      compra.TieneDiscapacidad,
 		DescripcionDiscapacidad: compra.DescripcionDiscapacidad,
@@ -296,9 +415,8 @@ func (s *CompraService) ObtenerDetalleCompra(compraID uint, turistaID uint) (*mo
 		}
 	}
 
-	if len(compra.Pagos) > 0 {
-		p := compra.Pagos[0]
-		resp.UltimoPago = &models.PagoSimpleResponse{
+	for _, p := range compra.Pagos {
+		resp.Pagos = append(resp.Pagos, models.PagoSimpleResponse{
 			ID:                p.ID,
 			MetodoPago:        p.MetodoPago,
 			Monto:             p.Monto,
@@ -306,8 +424,26 @@ func (s *CompraService) ObtenerDetalleCompra(compraID uint, turistaID uint) (*mo
 			ComprobanteFoto:   p.ComprobanteFoto,
 			FechaConfirmacion: p.FechaConfirmacion,
 			FechaRegistro:     p.CreatedAt,
-		}
+		})
+	}
+	if len(resp.Pagos) > 0 {
+		ultimo := resp.Pagos[0]
+		resp.UltimoPago = &ultimo
+	}
+
+	if err := s.db.Where("compra_id = ?", compra.ID).Order("id DESC").Find(&resp.Reembolsos).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Where("compra_id = ?", compra.ID).Order("id ASC").Find(&resp.Descuentos).Error; err != nil {
+		return nil, err
+	}
+
+	modificaciones, err := cargarModificacionesCompra(s.db, compra.ID)
+	if err != nil {
+		return nil, err
 	}
+	resp.Modificaciones = modificaciones
 
 	return resp, nil
 }
@@ -350,6 +486,7 @@ func (s *CompraService) ListarComprasTurista(turistaID uint, page int, pageSize
 		item := models.CompraDetalleResponse{
 			ID:                     compra.ID,
 			CodigoConfirmacion:     buildCodigoConfirmacion(&compra),
+			ReferenciaPago:         ReferenciaPagoCompra(compra.ID),
 			FechaCompra:            compra.FechaCompra,
 			FechaSeleccionada:      compra.FechaSeleccionada,
 			FechaConfirmacion:      compra.FechaConfirmacion,
@@ -361,6 +498,12 @@ func (s *CompraService) ListarComprasTurista(turistaID uint, page int, pageSize
 			Participantes:          nil,
 			TotalParticipantes:     compra.TotalParticipantes,
 			PrecioTotal:            compra.PrecioTotal,
+			PrecioSinDescuento:     compra.PrecioSinDescuento,
+			DescuentoAplicado:      compra.DescuentoAplicado,
+			MontoPagado:            compra.MontoPagado,
+			SaldoPendiente:         compra.SaldoPendiente(),
+			MontoAnticipo:          compra.MontoAnticipo,
+			FechaLimiteSaldo:       compra.FechaLimiteSaldo,
 			Status:                 compra.Status,
 			TieneDiscapacidad:      compra.TieneDiscapacidad,
 			DescripcionDiscapacidad: compra.DescripcionDiscapacidad,
@@ -429,7 +572,7 @@ func cancelSalidaIfEmpty(tx *gorm.DB, salidaID uint, motivo string) error {
 		return nil
 	}
 
-	if salida.CuposReservados == 0 && salida.CuposConfirmados == 0 {
+	if salida.CuposReservados == 0 && salida.CuposConfirmados == 0 && salida.CuposOfertados == 0 {
 		return tx.Model(&salida).Updates(map[string]interface{}{
 			"estado":            "cancelada",
 			"razon_cancelacion": motivo,
@@ -471,16 +614,35 @@ func (s *CompraService) ExpirarComprasPendientes(minutosLimite int) (int64, erro
 
 	var expiradas int64 = 0
 
-	for _, compra := range comprasExpirar {
+	for _, candidata := range comprasExpirar {
+		omitida := false
+		var compra models.CompraPaquete
 		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// Bloquear y revalidar: otra instancia o un pago recién registrado pudo cambiarla
+			if err := tx.Raw(`SELECT * FROM compras_paquetes WHERE id = ? FOR UPDATE`, candidata.ID).Scan(&compra).Error; err != nil {
+				return err
+			}
+			if compra.ID == 0 || compra.Status != "pendiente_confirmacion" {
+				omitida = true
+				return nil
+			}
+			var pagosActivos int64
+			if err := tx.Model(&models.PagoCompra{}).
+				Where("compra_id = ? AND estado IN ('pendiente', 'confirmado')", compra.ID).
+				Count(&pagosActivos).Error; err != nil {
+				return err
+			}
+			if pagosActivos > 0 {
+				omitida = true
+				return nil
+			}
+
 			// Liberar cupos reservados
 			if compra.SalidaID != nil {
-				if err := tx.Exec(`
-					UPDATE paquete_salidas_habilitadas
-					SET cupos_reservados = GREATEST(0, cupos_reservados - ?),
-					    updated_at = NOW()
-					WHERE id = ?
-				`, compra.TotalParticipantes, *compra.SalidaID).Error; err != nil {
+				if err := NewInventarioService(tx).LiberarReservados(*compra.SalidaID, compra.TotalParticipantes); err != nil {
+					return err
+				}
+				if err := ofrecerCuposListaEspera(tx, *compra.SalidaID); err != nil {
 					return err
 				}
 				if err := cancelSalidaIfEmpty(tx, *compra.SalidaID, "Salida cancelada por compra expirada"); err != nil {
@@ -529,7 +691,10 @@ func (s *CompraService) ExpirarComprasPendientes(minutosLimite int) (int64, erro
 		})
 
 		if err != nil {
-			log.Printf("Error expirando compra %d: %v", compra.ID, err)
+			log.Printf("Error expirando compra %d: %v", candidata.ID, err)
+			continue
+		}
+		if omitida {
 			continue
 		}
 
@@ -539,28 +704,28 @@ func (s *CompraService) ExpirarComprasPendientes(minutosLimite int) (int64, erro
 	return expiradas, nil
 }
 
-// CancelarCompra cancela una compra y libera los cupos
-func (s *CompraService) CancelarCompra(compraID uint, turistaID uint, razon string) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+// CancelarCompra cancela una compra y libera los cupos.
+// Las compras pagadas (confirmadas o con anticipo) generan un reembolso según los tramos de la agencia.
+func (s *CompraService) CancelarCompra(compraID uint, turistaID uint, razon string) (*models.Reembolso, error) {
+	var reembolso *models.Reembolso
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Bloquear la compra: dos cancelaciones simultáneas no deben liberar los cupos dos veces
 		var compra models.CompraPaquete
-		if err := tx.Where("id = ? AND turista_id = ?", compraID, turistaID).First(&compra).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("compra no encontrada")
-			}
+		if err := tx.Raw(`SELECT * FROM compras_paquetes WHERE id = ? AND turista_id = ? FOR UPDATE`, compraID, turistaID).
+			Scan(&compra).Error; err != nil {
 			return err
 		}
+		if compra.ID == 0 {
+			return errors.New("compra no encontrada")
+		}
 
 		// Validar que se puede cancelar
 		if compra.Status == "cancelada" || compra.Status == "expirada" {
 			return errors.New("la compra ya está cancelada o expirada")
 		}
 
-		if compra.Status == "confirmada" {
-			return errors.New("no se puede cancelar una compra confirmada, contacte a la agencia")
-		}
-
-		// Solo se puede cancelar si está pendiente_confirmacion
-		if compra.Status != "pendiente_confirmacion" {
+		if compra.Status != "pendiente_confirmacion" && compra.Status != "reservada_con_anticipo" && compra.Status != "confirmada" {
 			return fmt.Errorf("no se puede cancelar una compra con estado: %s", compra.Status)
 		}
 
@@ -576,39 +741,113 @@ func (s *CompraService) CancelarCompra(compraID uint, turistaID uint, razon stri
 			return errors.New("no se puede cancelar, hay pagos pendientes de revisión")
 		}
 
-		// Liberar cupos reservados
-		if compra.SalidaID != nil {
-			if err := tx.Exec(`
-				UPDATE paquete_salidas_habilitadas
-				SET cupos_reservados = GREATEST(0, cupos_reservados - ?),
-				    updated_at = NOW()
-				WHERE id = ?
-			`, compra.TotalParticipantes, *compra.SalidaID).Error; err != nil {
-				return err
-			}
-			if err := cancelSalidaIfEmpty(tx, *compra.SalidaID, "Salida cancelada por compra cancelada"); err != nil {
-				return err
-			}
-		}
-
-		// Marcar compra como cancelada
-		now := time.Now()
 		razonFinal := "Cancelada por el turista"
 		if razon != "" {
 			razonFinal = razon
 		}
 
-		if err := tx.Model(&compra).Updates(map[string]interface{}{
-			"status":        "cancelada",
-			"razon_rechazo": razonFinal,
-			"fecha_rechazo": now,
-			"updated_at":    now,
-		}).Error; err != nil {
-			return err
+		var err error
+		reembolso, err = cancelarCompraTurista(tx, &compra, razonFinal)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reembolso, nil
+}
+
+// cancelarCompraTurista cancela una compra ya bloqueada por cuenta del turista: si tiene pagos registra el
+// reembolso según los tramos de la agencia, libera sus cupos y la marca cancelada.
+func cancelarCompraTurista(tx *gorm.DB, compra *models.CompraPaquete, razon string) (*models.Reembolso, error) {
+	var reembolso *models.Reembolso
+
+	// Compras pagadas: calcular reembolso según la política de la agencia
+	if compra.Status == "confirmada" || compra.Status == "reservada_con_anticipo" {
+		preview, err := calcularCancelacion(tx, compra)
+		if err != nil {
+			return nil, err
+		}
+		if preview.DiasAntesSalida < 0 {
+			return nil, errors.New("no se puede cancelar una compra cuya salida ya se realizó")
 		}
 
-		return nil
-	})
+		dias := preview.DiasAntesSalida
+		reembolso, err = registrarReembolso(tx, compra, "cancelacion_turista", &razon, preview.MontoPagado, preview.PorcentajeReembolso, &dias)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Liberar cupos (reservados o confirmados según el estado de la compra)
+	if compra.SalidaID != nil {
+		if err := NewInventarioService(tx).LiberarCompra(compra); err != nil {
+			return nil, err
+		}
+		if err := ofrecerCuposListaEspera(tx, *compra.SalidaID); err != nil {
+			return nil, err
+		}
+		if err := cancelSalidaIfEmpty(tx, *compra.SalidaID, "Salida cancelada por compra cancelada"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Marcar compra como cancelada
+	now := time.Now()
+	if err := tx.Model(compra).Updates(map[string]interface{}{
+		"status":        "cancelada",
+		"razon_rechazo": razon,
+		"fecha_rechazo": now,
+		"updated_at":    now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return reembolso, nil
+}
+
+// PreviewCancelacion calcula el reembolso que obtendría el turista si cancela la compra hoy.
+func (s *CompraService) PreviewCancelacion(compraID uint, turistaID uint) (*models.CancelacionPreviewResponse, error) {
+	var compra models.CompraPaquete
+	if err := s.db.Where("id = ? AND turista_id = ?", compraID, turistaID).First(&compra).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("compra no encontrada")
+		}
+		return nil, err
+	}
+
+	return calcularCancelacion(s.db, &compra)
+}
+
+// AceptarOfertaListaEspera convierte la oferta vigente de una entrada de lista de espera en compra.
+func (s *CompraService) AceptarOfertaListaEspera(turistaID uint, entradaID uint, req *models.AceptarOfertaListaEsperaRequest) (*models.ProcesarCompraPaqueteResult, error) {
+	var entrada models.ListaEsperaSalida
+	if err := s.db.Preload("Salida").Where("id = ? AND turista_id = ?", entradaID, turistaID).First(&entrada).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("entrada de lista de espera no encontrada")
+		}
+		return nil, err
+	}
+	if entrada.Estado != "ofertada" || entrada.Salida == nil {
+		return nil, errors.New("no tiene una oferta vigente para esta salida")
+	}
+
+	compraReq := models.CrearCompraRequest{
+		PaqueteID:               entrada.PaqueteID,
+		FechaSeleccionada:       fechaSalidaString(entrada.Salida.FechaSalida),
+		TipoCompra:              "compartido",
+		Extranjero:              entrada.Extranjero,
+		CantidadAdultos:         entrada.CantidadAdultos,
+		CantidadNinosPagan:      entrada.CantidadNinosPagan,
+		CantidadNinosGratis:     entrada.CantidadNinosGratis,
+		TieneDiscapacidad:       req.TieneDiscapacidad,
+		DescripcionDiscapacidad: req.DescripcionDiscapacidad,
+		NotasTurista:            req.NotasTurista,
+		CodigoPromocion:         req.CodigoPromocion,
+		Participantes:           req.Participantes,
+	}
+
+	return s.crearCompra(turistaID, &compraReq, &entrada.ID)
 }
 
 // StartExpirationWorker inicia un worker que expira compras periódicamente
@@ -618,6 +857,10 @@ func StartExpirationWorker(db *gorm.DB, minutosExpiracion int, intervaloChequeoM
 	}
 
 	service := NewCompraService(db)
+	listaEspera := NewListaEsperaService(db)
+	solicitudes := NewSolicitudPrivadaService(db)
+	salidas := NewSalidaService(db)
+	pasarela := NewPasarelaService(db)
 
 	go func() {
 		ticker := time.NewTicker(time.Duration(intervaloChequeoMinutos) * time.Minute)
@@ -635,14 +878,41 @@ func StartExpirationWorker(db *gorm.DB, minutosExpiracion int, intervaloChequeoM
 		}
 
 		for range ticker.C {
+			// Primero los pagos en línea abandonados: un pago pendiente impide expirar su compra
+			intentos, err := pasarela.ExpirarIntentos()
+			if err != nil {
+				log.Printf("Error venciendo pagos en línea: %v", err)
+			} else if intentos > 0 {
+				log.Printf("Worker de expiración: %d pagos en línea vencidos", intentos)
+			}
+
 			expiradas, err := service.ExpirarComprasPendientes(minutosExpiracion)
 			if err != nil {
 				log.Printf("Error en worker de expiración: %v", err)
-				continue
-			}
-			if expiradas > 0 {
+			} else if expiradas > 0 {
 				log.Printf("Worker de expiración: %d compras expiradas", expiradas)
 			}
+
+			ofertas, err := listaEspera.ExpirarOfertas()
+			if err != nil {
+				log.Printf("Error expirando ofertas de lista de espera: %v", err)
+			} else if ofertas > 0 {
+				log.Printf("Worker de expiración: %d ofertas de lista de espera expiradas", ofertas)
+			}
+
+			cotizaciones, err := solicitudes.ExpirarCotizaciones()
+			if err != nil {
+				log.Printf("Error expirando cotizaciones de solicitudes privadas: %v", err)
+			} else if cotizaciones > 0 {
+				log.Printf("Worker de expiración: %d cotizaciones privadas expiradas", cotizaciones)
+			}
+
+			reprogramaciones, err := salidas.ExpirarReprogramaciones()
+			if err != nil {
+				log.Printf("Error venciendo reprogramaciones de salidas: %v", err)
+			} else if reprogramaciones > 0 {
+				log.Printf("Worker de expiración: %d compras canceladas por no responder a un cambio de fecha", reprogramaciones)
+			}
 		}
 	}()
 }
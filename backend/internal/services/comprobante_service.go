@@ -0,0 +1,448 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Estados de una coincidencia de comprobante.
+const (
+	CoincidenciaComprobantePendiente = "pendiente"
+	CoincidenciaComprobanteReportada = "reportada"
+	CoincidenciaComprobanteLegitima  = "legitima"
+	CoincidenciaComprobanteFraude    = "fraude"
+)
+
+const (
+	// Bits distintos (de 64) hasta los que dos comprobantes se consideran la misma imagen: tolera
+	// recompresión, cambio de tamaño y de brillo. Un recorte desplaza las celdas del hash y puede superarlo.
+	umbralDistanciaComprobante = 6
+	// Coincidencias guardadas por pago; basta con las más cercanas
+	maxCoincidenciasComprobante = 10
+	// Las imágenes más grandes no se decodifican (solo se compara el archivo exacto)
+	maxPixelesComprobante = 40_000_000
+	// Muestras por imagen al calcular el hash perceptual
+	maxMuestrasComprobante = 1_000_000
+	// La búsqueda por hash perceptual recorre solo los pagos de este período (idx_pagos_compras_phash_fecha);
+	// el archivo idéntico se busca en todos por su SHA-256
+	diasBusquedaSimilarComprobante = 180
+	// Pagos por lote al calcular las huellas de los comprobantes anteriores a la detección
+	loteHuellasComprobante = 200
+)
+
+var (
+	ErrCoincidenciaComprobanteNoEncontrada = errors.New("coincidencia de comprobante no encontrada")
+	ErrPagoAgenciaNoEncontrado             = errors.New("pago no encontrado")
+)
+
+type ComprobanteService struct {
+	db *gorm.DB
+}
+
+func NewComprobanteService(db *gorm.DB) *ComprobanteService {
+	return &ComprobanteService{db: db}
+}
+
+// huellaComprobante calcula el SHA-256 del comprobante guardado y, si la imagen se puede decodificar
+// (jpg, png o gif), su hash perceptual.
+func huellaComprobante(ruta string) (string, *int64, error) {
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		return "", nil, err
+	}
+	suma := sha256.Sum256(contenido)
+	sha := hex.EncodeToString(suma[:])
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(contenido))
+	if err != nil || cfg.Width*cfg.Height > maxPixelesComprobante {
+		return sha, nil, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(contenido))
+	if err != nil {
+		return sha, nil, nil
+	}
+	phash, ok := dHashImagen(img)
+	if !ok {
+		return sha, nil, nil
+	}
+	return sha, &phash, nil
+}
+
+// dHashImagen reduce la imagen a 9x8 celdas de luminancia promedio y marca un bit por cada celda más
+// clara que su vecina derecha. Una imagen uniforme no tiene un hash útil (ok = false): coincidiría con
+// cualquier otra imagen lisa.
+func dHashImagen(img image.Image) (int64, bool) {
+	b := img.Bounds()
+	ancho, alto := b.Dx(), b.Dy()
+	if ancho < 9 || alto < 8 {
+		return 0, false
+	}
+	paso := int(math.Sqrt(float64(ancho*alto) / maxMuestrasComprobante))
+	if paso < 1 {
+		paso = 1
+	}
+
+	var suma, cuenta [8][9]float64
+	for y := 0; y < alto; y += paso {
+		fila := y * 8 / alto
+		for x := 0; x < ancho; x += paso {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			columna := x * 9 / ancho
+			suma[fila][columna] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			cuenta[fila][columna]++
+		}
+	}
+
+	var hash uint64
+	for fila := 0; fila < 8; fila++ {
+		for columna := 0; columna < 8; columna++ {
+			izquierda := suma[fila][columna] / math.Max(cuenta[fila][columna], 1)
+			derecha := suma[fila][columna+1] / math.Max(cuenta[fila][columna+1], 1)
+			hash <<= 1
+			if izquierda > derecha {
+				hash |= 1
+			}
+		}
+	}
+	if hash == 0 || hash == math.MaxUint64 {
+		return 0, false
+	}
+	return int64(hash), true
+}
+
+type candidatoComprobante struct {
+	PagoPrevioID uint `gorm:"column:pago_previo_id"`
+	Exacta       bool `gorm:"column:exacta"`
+	Distancia    int  `gorm:"column:distancia"`
+	MismaAgencia bool `gorm:"column:misma_agencia"`
+	MismoTurista bool `gorm:"column:mismo_turista"`
+}
+
+// registrarCoincidenciasComprobante busca el comprobante del pago entre los pagos de otras compras de
+// todas las agencias y, si lo encuentra, guarda las coincidencias y marca la alerta del pago. Los pagos
+// anteriores de la misma compra no cuentan: el turista puede reenviar su comprobante tras un rechazo.
+// Las imágenes parecidas se buscan solo entre los pagos de los últimos diasBusquedaSimilarComprobante días.
+func registrarCoincidenciasComprobante(tx *gorm.DB, pago *models.PagoCompra) error {
+	if pago.ComprobanteSHA256 == nil {
+		return nil
+	}
+
+	distancia := "64"
+	condicion := "pc.comprobante_sha256 = @sha"
+	if pago.ComprobantePHash != nil {
+		distancia = "length(replace(((pc.comprobante_phash # @phash)::bit(64))::text, '0', ''))"
+		condicion += " OR (pc.comprobante_phash IS NOT NULL AND pc.created_at >= @desde AND " + distancia + " <= @umbral)"
+	}
+
+	var candidatos []candidatoComprobante
+	if err := tx.Raw(`
+		SELECT
+			pc.id AS pago_previo_id,
+			COALESCE(pc.comprobante_sha256 = @sha, false) AS exacta,
+			CASE WHEN pc.comprobante_phash IS NULL THEN 64 ELSE `+distancia+` END AS distancia,
+			p.agencia_id = pa.agencia_id AS misma_agencia,
+			c.turista_id = ca.turista_id AS mismo_turista
+		FROM pagos_compras pc
+		JOIN compras_paquetes c ON c.id = pc.compra_id
+		JOIN paquetes_turisticos p ON p.id = c.paquete_id
+		JOIN compras_paquetes ca ON ca.id = @compra
+		JOIN paquetes_turisticos pa ON pa.id = ca.paquete_id
+		WHERE pc.id <> @pago
+			AND pc.compra_id <> ca.id
+			AND (`+condicion+`)
+		ORDER BY exacta DESC, distancia ASC, pc.id DESC
+		LIMIT @limite
+	`, map[string]interface{}{
+		"sha":    *pago.ComprobanteSHA256,
+		"phash":  pago.ComprobantePHash,
+		"umbral": umbralDistanciaComprobante,
+		"desde":  time.Now().AddDate(0, 0, -diasBusquedaSimilarComprobante),
+		"compra": pago.CompraID,
+		"pago":   pago.ID,
+		"limite": maxCoincidenciasComprobante,
+	}).Scan(&candidatos).Error; err != nil {
+		return err
+	}
+	if len(candidatos) == 0 {
+		return nil
+	}
+
+	coincidencias := make([]models.CoincidenciaComprobante, 0, len(candidatos))
+	for _, cand := range candidatos {
+		coincidencia := models.CoincidenciaComprobante{
+			PagoID:       pago.ID,
+			PagoPrevioID: cand.PagoPrevioID,
+			Tipo:         "similar",
+			Distancia:    cand.Distancia,
+			MismaAgencia: cand.MismaAgencia,
+			MismoTurista: cand.MismoTurista,
+			Estado:       CoincidenciaComprobantePendiente,
+		}
+		if cand.Exacta {
+			coincidencia.Tipo = "exacta"
+			coincidencia.Distancia = 0
+		}
+		coincidencias = append(coincidencias, coincidencia)
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&coincidencias).Error; err != nil {
+		return err
+	}
+
+	pago.ComprobanteAlerta = true
+	return tx.Model(&models.PagoCompra{}).Where("id = ?", pago.ID).Update("comprobante_alerta", true).Error
+}
+
+// RegistrarHuellasPendientes calcula las huellas de los comprobantes guardados antes de la detección de
+// reutilización, para que los pagos nuevos también se comparen con ellos. No genera coincidencias entre
+// pagos anteriores. Los comprobantes cuyo archivo ya no existe se omiten. Retorna los pagos actualizados.
+func (s *ComprobanteService) RegistrarHuellasPendientes() (int, error) {
+	actualizados := 0
+	var ultimoID uint
+	for {
+		var pagos []models.PagoCompra
+		if err := s.db.Select("id", "comprobante_foto").
+			Where("id > ? AND comprobante_foto IS NOT NULL AND comprobante_sha256 IS NULL", ultimoID).
+			Order("id").
+			Limit(loteHuellasComprobante).
+			Find(&pagos).Error; err != nil {
+			return actualizados, err
+		}
+		if len(pagos) == 0 {
+			return actualizados, nil
+		}
+
+		for _, pago := range pagos {
+			ultimoID = pago.ID
+			sha, phash, err := huellaComprobante(*pago.ComprobanteFoto)
+			if err != nil {
+				log.Printf("Huella del comprobante del pago %d: %v", pago.ID, err)
+				continue
+			}
+			if err := s.db.Model(&models.PagoCompra{}).
+				Where("id = ? AND comprobante_sha256 IS NULL", pago.ID).
+				Updates(map[string]interface{}{
+					"comprobante_sha256": sha,
+					"comprobante_phash":  phash,
+				}).Error; err != nil {
+				return actualizados, err
+			}
+			actualizados++
+		}
+	}
+}
+
+// CoincidenciasDePagos retorna las coincidencias de comprobante de los pagos indicados, agrupadas por pago.
+// La compra anterior solo se identifica si pertenece a la agencia que consulta.
+func (s *ComprobanteService) CoincidenciasDePagos(agenciaID uint, pagoIDs []uint) (map[uint][]models.CoincidenciaComprobanteResumen, error) {
+	resultado := make(map[uint][]models.CoincidenciaComprobanteResumen)
+	if len(pagoIDs) == 0 {
+		return resultado, nil
+	}
+
+	var filas []models.CoincidenciaComprobanteResumen
+	if err := s.db.Raw(`
+		SELECT
+			cc.id,
+			cc.pago_id,
+			cc.pago_previo_id,
+			cc.tipo,
+			cc.distancia,
+			cc.misma_agencia,
+			cc.mismo_turista,
+			cc.estado,
+			pp.estado AS estado_pago_previo,
+			pp.monto AS monto_pago_previo,
+			pp.created_at AS fecha_pago_previo,
+			CASE WHEN p.agencia_id = ? THEN pp.compra_id END AS compra_previa_id
+		FROM coincidencias_comprobante cc
+		JOIN pagos_compras pp ON pp.id = cc.pago_previo_id
+		JOIN compras_paquetes c ON c.id = pp.compra_id
+		JOIN paquetes_turisticos p ON p.id = c.paquete_id
+		WHERE cc.pago_id IN ?
+		ORDER BY cc.pago_id, cc.tipo, cc.distancia, cc.id
+	`, agenciaID, pagoIDs).Scan(&filas).Error; err != nil {
+		return nil, err
+	}
+
+	for _, fila := range filas {
+		resultado[fila.PagoID] = append(resultado[fila.PagoID], fila)
+	}
+	return resultado, nil
+}
+
+// ReportarPago envía al admin las coincidencias pendientes del comprobante de un pago de la agencia.
+func (s *ComprobanteService) ReportarPago(agenciaID uint, pagoID uint, usuarioID uint, motivo string) (int, error) {
+	var reportadas int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var pertenece int64
+		if err := tx.Table("pagos_compras pc").
+			Joins("JOIN compras_paquetes c ON c.id = pc.compra_id").
+			Joins("JOIN paquetes_turisticos p ON p.id = c.paquete_id").
+			Where("pc.id = ? AND p.agencia_id = ?", pagoID, agenciaID).
+			Count(&pertenece).Error; err != nil {
+			return err
+		}
+		if pertenece == 0 {
+			return ErrPagoAgenciaNoEncontrado
+		}
+
+		now := time.Now()
+		res := tx.Model(&models.CoincidenciaComprobante{}).
+			Where("pago_id = ? AND estado = ?", pagoID, CoincidenciaComprobantePendiente).
+			Updates(map[string]interface{}{
+				"estado":         CoincidenciaComprobanteReportada,
+				"reportada_por":  usuarioID,
+				"fecha_reporte":  now,
+				"motivo_reporte": motivo,
+				"updated_at":     now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("el pago no tiene coincidencias de comprobante pendientes de reportar")
+		}
+		reportadas = res.RowsAffected
+
+		var admins []uint
+		if err := tx.Model(&models.Usuario{}).
+			Where("rol = ? AND status = ?", "admin", "active").
+			Pluck("id", &admins).Error; err != nil {
+			return err
+		}
+		for _, adminID := range admins {
+			if _, err := notificarUsuario(tx, adminID, models.TipoComprobanteReportado,
+				"Comprobante reportado",
+				fmt.Sprintf("Una agencia reportó el comprobante del pago #%d por coincidir con el de otra compra.", pagoID),
+				models.NotifDatosJSON{"pago_id": pagoID, "agencia_id": agenciaID}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(reportadas), nil
+}
+
+func (s *ComprobanteService) ListarCoincidencias(estados []string, page, pageSize int) ([]models.CoincidenciaComprobante, int64, error) {
+	q := s.db.Model(&models.CoincidenciaComprobante{})
+	if len(estados) > 0 {
+		q = q.Where("estado IN ?", estados)
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var coincidencias []models.CoincidenciaComprobante
+	if err := q.Session(&gorm.Session{}).
+		Preload("Pago.Compra.Paquete.Agencia").
+		Preload("Pago.Compra.Turista").
+		Preload("PagoPrevio.Compra.Paquete.Agencia").
+		Preload("PagoPrevio.Compra.Turista").
+		Order("CASE WHEN estado = 'reportada' THEN 0 ELSE 1 END").
+		Order("created_at DESC").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&coincidencias).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return coincidencias, total, nil
+}
+
+func (s *ComprobanteService) ObtenerCoincidencia(coincidenciaID uint) (*models.CoincidenciaComprobante, error) {
+	var coincidencia models.CoincidenciaComprobante
+	if err := s.db.
+		Preload("Pago.Compra.Paquete.Agencia").
+		Preload("Pago.Compra.Turista").
+		Preload("PagoPrevio.Compra.Paquete.Agencia").
+		Preload("PagoPrevio.Compra.Turista").
+		First(&coincidencia, coincidenciaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCoincidenciaComprobanteNoEncontrada
+		}
+		return nil, err
+	}
+	return &coincidencia, nil
+}
+
+// ResolverCoincidencia cierra una coincidencia abierta como legítima o fraude. La alerta del pago se
+// retira cuando todas sus coincidencias resultan legítimas.
+func (s *ComprobanteService) ResolverCoincidencia(coincidenciaID uint, adminID uint, req *models.ResolverCoincidenciaComprobanteRequest) (*models.CoincidenciaComprobante, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var coincidencia models.CoincidenciaComprobante
+		res := tx.Raw(`SELECT * FROM coincidencias_comprobante WHERE id = ? FOR UPDATE`, coincidenciaID).Scan(&coincidencia)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrCoincidenciaComprobanteNoEncontrada
+		}
+		if coincidencia.Estado != CoincidenciaComprobantePendiente && coincidencia.Estado != CoincidenciaComprobanteReportada {
+			return fmt.Errorf("la coincidencia ya fue resuelta (estado: %s)", coincidencia.Estado)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.CoincidenciaComprobante{}).Where("id = ?", coincidencia.ID).Updates(map[string]interface{}{
+			"estado":         req.Resultado,
+			"revisado_por":   adminID,
+			"fecha_revision": now,
+			"notas_revision": req.Notas,
+			"updated_at":     now,
+		}).Error; err != nil {
+			return err
+		}
+
+		var abiertas int64
+		if err := tx.Model(&models.CoincidenciaComprobante{}).
+			Where("pago_id = ? AND estado <> ?", coincidencia.PagoID, CoincidenciaComprobanteLegitima).
+			Count(&abiertas).Error; err != nil {
+			return err
+		}
+		if abiertas == 0 {
+			if err := tx.Model(&models.PagoCompra{}).Where("id = ?", coincidencia.PagoID).
+				Update("comprobante_alerta", false).Error; err != nil {
+				return err
+			}
+		}
+
+		if coincidencia.ReportadaPor != nil {
+			resultado := "legítimo"
+			if req.Resultado == CoincidenciaComprobanteFraude {
+				resultado = "fraudulento"
+			}
+			if _, err := notificarUsuario(tx, *coincidencia.ReportadaPor, models.TipoComprobanteRevisado,
+				"Comprobante revisado",
+				fmt.Sprintf("El admin revisó el comprobante reportado del pago #%d y lo consideró %s.", coincidencia.PagoID, resultado),
+				models.NotifDatosJSON{"pago_id": coincidencia.PagoID, "resultado": req.Resultado}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ObtenerCoincidencia(coincidenciaID)
+}
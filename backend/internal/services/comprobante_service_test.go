@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math/bits"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// comprobantePrueba dibuja una captura de transferencia ficticia: fondo claro, encabezado y bloques de
+// texto de largo variable según la semilla.
+func comprobantePrueba(semilla int64) *image.RGBA {
+	rnd := rand.New(rand.NewSource(semilla))
+	img := image.NewRGBA(image.Rect(0, 0, 720, 1280))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{245, 245, 240, 255}}, image.Point{}, draw.Src)
+	encabezado := color.RGBA{uint8(rnd.Intn(120)), uint8(60 + rnd.Intn(120)), uint8(120 + rnd.Intn(120)), 255}
+	draw.Draw(img, image.Rect(0, 0, 720, 160+rnd.Intn(80)), &image.Uniform{encabezado}, image.Point{}, draw.Src)
+	for y := 300; y < 1200; y += 70 + rnd.Intn(40) {
+		x := 40 + rnd.Intn(200)
+		gris := uint8(rnd.Intn(90))
+		draw.Draw(img, image.Rect(x, y, x+80+rnd.Intn(560-x+160), y+24+rnd.Intn(24)), &image.Uniform{color.RGBA{gris, gris, gris, 255}}, image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// reducirImagen escala la imagen por vecino más cercano.
+func reducirImagen(img image.Image, ancho, alto int) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, ancho, alto))
+	for y := 0; y < alto; y++ {
+		for x := 0; x < ancho; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*b.Dx()/ancho, b.Min.Y+y*b.Dy()/alto))
+		}
+	}
+	return dst
+}
+
+func recomprimirJPEG(t *testing.T, img image.Image, calidad int) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: calidad}); err != nil {
+		t.Fatal(err)
+	}
+	decodificada, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decodificada
+}
+
+func aclararImagen(img *image.RGBA, delta uint8) *image.RGBA {
+	dst := image.NewRGBA(img.Bounds())
+	for i, v := range img.Pix {
+		if i%4 == 3 {
+			dst.Pix[i] = v
+			continue
+		}
+		dst.Pix[i] = uint8(min(int(v)+int(delta), 255))
+	}
+	return dst
+}
+
+func hashPrueba(t *testing.T, img image.Image) int64 {
+	t.Helper()
+	hash, ok := dHashImagen(img)
+	if !ok {
+		t.Fatalf("dHashImagen no calculó un hash para una imagen %v", img.Bounds())
+	}
+	return hash
+}
+
+func distanciaHash(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+func TestDHashImagenCasiDuplicados(t *testing.T) {
+	original := comprobantePrueba(1)
+	hashOriginal := hashPrueba(t, original)
+
+	casos := []struct {
+		nombre string
+		img    image.Image
+	}{
+		{"misma imagen", original},
+		{"reducida a la mitad", reducirImagen(original, 360, 640)},
+		{"recomprimida en JPEG", recomprimirJPEG(t, original, 60)},
+		{"reducida y recomprimida", recomprimirJPEG(t, reducirImagen(original, 540, 960), 75)},
+		{"más clara", aclararImagen(original, 12)},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if d := distanciaHash(hashOriginal, hashPrueba(t, c.img)); d > umbralDistanciaComprobante {
+				t.Errorf("distancia %d, se esperaba a lo sumo %d", d, umbralDistanciaComprobante)
+			}
+		})
+	}
+}
+
+func TestDHashImagenImagenesDistintas(t *testing.T) {
+	original := comprobantePrueba(1)
+	hashOriginal := hashPrueba(t, original)
+
+	b := original.Bounds()
+	otro := comprobantePrueba(2)
+	otroBanco := image.NewRGBA(b)
+	oscuro := image.NewRGBA(b)
+	foto := image.NewRGBA(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			// Otro comprobante con el encabezado abajo, el mismo en modo oscuro y un degradé
+			otroBanco.SetRGBA(x, y, otro.RGBAAt(x, b.Dy()-1-y))
+			c := original.RGBAAt(x, y)
+			oscuro.SetRGBA(x, y, color.RGBA{255 - c.R, 255 - c.G, 255 - c.B, 255})
+			v := uint8((x*255/b.Dx() + y*128/b.Dy()) % 256)
+			foto.SetRGBA(x, y, color.RGBA{v, v / 2, 200, 255})
+		}
+	}
+
+	casos := []struct {
+		nombre string
+		img    image.Image
+	}{
+		{"comprobante de otro banco", otroBanco},
+		{"modo oscuro", oscuro},
+		{"foto", foto},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if d := distanciaHash(hashOriginal, hashPrueba(t, c.img)); d <= umbralDistanciaComprobante {
+				t.Errorf("distancia %d, se esperaba más de %d", d, umbralDistanciaComprobante)
+			}
+		})
+	}
+}
+
+func TestDHashImagenSinHashUtil(t *testing.T) {
+	lisa := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(lisa, lisa.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	if _, ok := dHashImagen(lisa); ok {
+		t.Error("una imagen uniforme no debería tener hash")
+	}
+	if _, ok := dHashImagen(comprobantePrueba(1).SubImage(image.Rect(0, 0, 8, 8))); ok {
+		t.Error("una imagen de menos de 9x8 píxeles no debería tener hash")
+	}
+}
+
+func TestHuellaComprobante(t *testing.T) {
+	img := comprobantePrueba(1)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	ruta := filepath.Join(t.TempDir(), "comprobante.png")
+	if err := os.WriteFile(ruta, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sha, phash, err := huellaComprobante(ruta)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	suma := sha256.Sum256(buf.Bytes())
+	if sha != hex.EncodeToString(suma[:]) {
+		t.Errorf("sha = %s, se esperaba el SHA-256 del archivo", sha)
+	}
+	if phash == nil || *phash != hashPrueba(t, img) {
+		t.Errorf("phash = %v, se esperaba el hash de la imagen", phash)
+	}
+
+	// Un archivo que no es imagen solo se compara por su SHA-256
+	ruta = filepath.Join(t.TempDir(), "comprobante.webp")
+	if err := os.WriteFile(ruta, []byte("RIFF----WEBPVP8 "), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, phash, err := huellaComprobante(ruta); err != nil || phash != nil {
+		t.Errorf("phash = %v, err = %v; se esperaba solo el SHA-256", phash, err)
+	}
+}
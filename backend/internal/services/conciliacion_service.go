@@ -0,0 +1,861 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Estados de una LineaExtractoBancario.
+const (
+	LineaExtractoSinCoincidencia = "sin_coincidencia"
+	LineaExtractoPropuesta       = "propuesta"
+	LineaExtractoConciliada      = "conciliada"
+	LineaExtractoIgnorada        = "ignorada"
+)
+
+const (
+	// Días de tolerancia por defecto entre la fecha del crédito y el registro del pago
+	VentanaConciliacionDefecto = 3
+	VentanaConciliacionMaxima  = 15
+	maxLineasExtracto          = 5000
+)
+
+var (
+	ErrFormatoExtractoNoEncontrado = errors.New("formato de extracto no encontrado")
+	ErrExtractoNoEncontrado        = errors.New("extracto no encontrado")
+)
+
+// Métodos de pago que se acreditan en la cuenta bancaria de la agencia.
+var metodosPagoBancarios = []string{"qr", "transferencia"}
+
+// Valor numérico tal como lo guarda Excel (punto decimal, sin separador de miles).
+var patronNumeroCrudo = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][-+]?\d+)?$`)
+
+type ConciliacionService struct {
+	db *gorm.DB
+}
+
+func NewConciliacionService(db *gorm.DB) *ConciliacionService {
+	return &ConciliacionService{db: db}
+}
+
+// layoutFechaExtracto traduce un patrón DD/MM/YYYY al layout de Go.
+func layoutFechaExtracto(patron string) string {
+	return strings.NewReplacer(
+		"YYYY", "2006", "YY", "06",
+		"DD", "02", "MM", "01",
+		"HH", "15", "mm", "04", "ss", "05",
+	).Replace(patron)
+}
+
+func normalizarFormatoExtracto(req *models.FormatoExtractoRequest) error {
+	req.Banco = strings.TrimSpace(req.Banco)
+	req.ColumnaFecha = strings.TrimSpace(req.ColumnaFecha)
+	req.ColumnaMonto = strings.TrimSpace(req.ColumnaMonto)
+	for _, columna := range []**string{&req.ColumnaDescripcion, &req.ColumnaReferencia} {
+		if *columna != nil {
+			if valor := strings.TrimSpace(**columna); valor != "" {
+				*columna = &valor
+			} else {
+				*columna = nil
+			}
+		}
+	}
+	if req.Banco == "" || req.ColumnaFecha == "" || req.ColumnaMonto == "" {
+		return errors.New("banco, columna_fecha y columna_monto son obligatorios")
+	}
+
+	if req.FilaEncabezado == 0 {
+		req.FilaEncabezado = 1
+	}
+	req.FormatoFecha = strings.TrimSpace(req.FormatoFecha)
+	if req.FormatoFecha == "" {
+		req.FormatoFecha = "DD/MM/YYYY"
+	}
+	layout := layoutFechaExtracto(req.FormatoFecha)
+	if !strings.Contains(layout, "02") || !strings.Contains(layout, "01") || !strings.Contains(layout, "06") {
+		return errors.New("formato_fecha debe incluir DD, MM y YYYY (o YY)")
+	}
+	if req.SeparadorDecimal == "" {
+		req.SeparadorDecimal = ","
+	}
+	if req.SeparadorDecimal != "," && req.SeparadorDecimal != "." {
+		return errors.New("separador_decimal debe ser \",\" o \".\"")
+	}
+	if req.Delimitador != "" && !strings.Contains(",;\t|", req.Delimitador) {
+		return errors.New("delimitador debe ser \",\", \";\", \"|\" o tabulación (vacío para detectarlo)")
+	}
+	return nil
+}
+
+func (s *ConciliacionService) ListarFormatos(agenciaID uint) ([]models.FormatoExtractoBancario, error) {
+	var formatos []models.FormatoExtractoBancario
+	err := s.db.Where("agencia_id = ?", agenciaID).Order("banco ASC").Find(&formatos).Error
+	return formatos, err
+}
+
+func (s *ConciliacionService) CrearFormato(agenciaID uint, req *models.FormatoExtractoRequest) (*models.FormatoExtractoBancario, error) {
+	if err := normalizarFormatoExtracto(req); err != nil {
+		return nil, err
+	}
+
+	var existentes int64
+	if err := s.db.Model(&models.FormatoExtractoBancario{}).
+		Where("agencia_id = ? AND LOWER(banco) = LOWER(?)", agenciaID, req.Banco).
+		Count(&existentes).Error; err != nil {
+		return nil, err
+	}
+	if existentes > 0 {
+		return nil, fmt.Errorf("ya existe un formato para el banco %s", req.Banco)
+	}
+
+	formato := models.FormatoExtractoBancario{AgenciaID: agenciaID}
+	aplicarFormatoExtracto(&formato, req)
+	if err := s.db.Create(&formato).Error; err != nil {
+		return nil, err
+	}
+	return &formato, nil
+}
+
+func (s *ConciliacionService) ActualizarFormato(agenciaID, formatoID uint, req *models.FormatoExtractoRequest) (*models.FormatoExtractoBancario, error) {
+	if err := normalizarFormatoExtracto(req); err != nil {
+		return nil, err
+	}
+
+	formato, err := s.obtenerFormato(agenciaID, formatoID)
+	if err != nil {
+		return nil, err
+	}
+
+	var existentes int64
+	if err := s.db.Model(&models.FormatoExtractoBancario{}).
+		Where("agencia_id = ? AND LOWER(banco) = LOWER(?) AND id <> ?", agenciaID, req.Banco, formatoID).
+		Count(&existentes).Error; err != nil {
+		return nil, err
+	}
+	if existentes > 0 {
+		return nil, fmt.Errorf("ya existe un formato para el banco %s", req.Banco)
+	}
+
+	aplicarFormatoExtracto(formato, req)
+	if err := s.db.Save(formato).Error; err != nil {
+		return nil, err
+	}
+	return formato, nil
+}
+
+func (s *ConciliacionService) EliminarFormato(agenciaID, formatoID uint) error {
+	res := s.db.Where("id = ? AND agencia_id = ?", formatoID, agenciaID).Delete(&models.FormatoExtractoBancario{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrFormatoExtractoNoEncontrado
+	}
+	return nil
+}
+
+func aplicarFormatoExtracto(formato *models.FormatoExtractoBancario, req *models.FormatoExtractoRequest) {
+	formato.Banco = req.Banco
+	formato.ColumnaFecha = req.ColumnaFecha
+	formato.ColumnaMonto = req.ColumnaMonto
+	formato.ColumnaDescripcion = req.ColumnaDescripcion
+	formato.ColumnaReferencia = req.ColumnaReferencia
+	formato.FilaEncabezado = req.FilaEncabezado
+	formato.FormatoFecha = req.FormatoFecha
+	formato.SeparadorDecimal = req.SeparadorDecimal
+	formato.Delimitador = req.Delimitador
+}
+
+func (s *ConciliacionService) obtenerFormato(agenciaID, formatoID uint) (*models.FormatoExtractoBancario, error) {
+	var formato models.FormatoExtractoBancario
+	if err := s.db.Where("id = ? AND agencia_id = ?", formatoID, agenciaID).First(&formato).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFormatoExtractoNoEncontrado
+		}
+		return nil, err
+	}
+	return &formato, nil
+}
+
+// leerArchivoExtracto lee el extracto como tabla: .xlsx (detectado por su firma zip) o CSV. Retorna si
+// los números vienen con el valor crudo de Excel.
+func leerArchivoExtracto(contenido []byte, formato *models.FormatoExtractoBancario) ([][]string, bool, error) {
+	switch {
+	case bytes.HasPrefix(contenido, []byte("PK\x03\x04")):
+		filas, err := utils.LeerTablaXLSX(contenido)
+		return filas, true, err
+	case bytes.HasPrefix(contenido, []byte{0xD0, 0xCF, 0x11, 0xE0}):
+		return nil, false, errors.New("el formato .xls no está soportado: guarde el extracto como .xlsx o .csv")
+	}
+
+	var delimitador rune
+	if formato.Delimitador != "" {
+		delimitador = []rune(formato.Delimitador)[0]
+	}
+	filas, err := utils.LeerTablaCSV(contenido, delimitador)
+	return filas, false, err
+}
+
+// columnaExtracto ubica una columna por su encabezado o, si no coincide ninguno, por su letra de Excel.
+func columnaExtracto(encabezados []string, columna string) int {
+	for i, encabezado := range encabezados {
+		if strings.EqualFold(strings.TrimSpace(encabezado), columna) {
+			return i
+		}
+	}
+	if len(columna) <= 3 {
+		indice := 0
+		for _, r := range strings.ToUpper(columna) {
+			if r < 'A' || r > 'Z' {
+				return -1
+			}
+			indice = indice*26 + int(r-'A') + 1
+		}
+		return indice - 1
+	}
+	return -1
+}
+
+func celdaExtracto(fila []string, columna int) string {
+	if columna < 0 || columna >= len(fila) {
+		return ""
+	}
+	return strings.TrimSpace(fila[columna])
+}
+
+// montoExtracto interpreta un monto del extracto ("Bs 1.234,56", "(150,00)", "1234.5" de Excel).
+func montoExtracto(valor string, separadorDecimal string, numerosCrudos bool) (float64, error) {
+	if numerosCrudos && patronNumeroCrudo.MatchString(valor) {
+		monto, err := strconv.ParseFloat(valor, 64)
+		return math.Round(monto*100) / 100, err
+	}
+
+	negativo := strings.HasPrefix(valor, "-") || strings.HasSuffix(valor, "-") ||
+		(strings.HasPrefix(valor, "(") && strings.HasSuffix(valor, ")"))
+	limpio := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || r == '.' || r == ',' {
+			return r
+		}
+		return -1
+	}, valor)
+	// Descarta puntos de abreviaturas como "Bs."
+	limpio = strings.TrimLeft(limpio, ".,")
+	if limpio == "" {
+		return 0, errors.New("monto vacío")
+	}
+
+	separadorMiles := "."
+	if separadorDecimal == "." {
+		separadorMiles = ","
+	}
+	limpio = strings.ReplaceAll(limpio, separadorMiles, "")
+	limpio = strings.Replace(limpio, separadorDecimal, ".", 1)
+
+	monto, err := strconv.ParseFloat(limpio, 64)
+	if err != nil {
+		return 0, fmt.Errorf("monto inválido %q", valor)
+	}
+	if negativo {
+		monto = -monto
+	}
+	return math.Round(monto*100) / 100, nil
+}
+
+// fechaExtracto interpreta la fecha de un crédito; si trae hora después del patrón, se ignora.
+func fechaExtracto(valor string, layout string, numerosCrudos bool) (time.Time, error) {
+	if numerosCrudos {
+		if serial, err := strconv.ParseFloat(valor, 64); err == nil && serial > 0 {
+			fecha := utils.FechaSerialExcel(serial)
+			return time.Date(fecha.Year(), fecha.Month(), fecha.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+
+	fecha, err := time.Parse(layout, valor)
+	if err != nil && len(valor) > len(layout) {
+		fecha, err = time.Parse(layout, valor[:len(layout)])
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fecha inválida %q", valor)
+	}
+	return time.Date(fecha.Year(), fecha.Month(), fecha.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// ImportarExtracto lee los créditos del extracto (CSV o .xlsx) con el formato del banco, omite los ya
+// importados en extractos anteriores (períodos superpuestos) y propone coincidencias con los pagos
+// pendientes.
+func (s *ConciliacionService) ImportarExtracto(agenciaID, usuarioID, formatoID uint, nombreArchivo string, contenido []byte, ventanaDias int) (*models.ResultadoImportacionExtracto, error) {
+	formato, err := s.obtenerFormato(agenciaID, formatoID)
+	if err != nil {
+		return nil, err
+	}
+	if ventanaDias <= 0 {
+		ventanaDias = VentanaConciliacionDefecto
+	}
+	if ventanaDias > VentanaConciliacionMaxima {
+		return nil, fmt.Errorf("ventana_dias no puede superar %d", VentanaConciliacionMaxima)
+	}
+
+	filas, numerosCrudos, err := leerArchivoExtracto(contenido, formato)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el extracto: %w", err)
+	}
+
+	filaEncabezado := formato.FilaEncabezado - 1
+	if filaEncabezado < 0 || filaEncabezado >= len(filas) {
+		return nil, fmt.Errorf("el archivo no tiene la fila de encabezados %d", formato.FilaEncabezado)
+	}
+	encabezados := filas[filaEncabezado]
+	colFecha := columnaExtracto(encabezados, formato.ColumnaFecha)
+	colMonto := columnaExtracto(encabezados, formato.ColumnaMonto)
+	if colFecha < 0 || colMonto < 0 {
+		return nil, fmt.Errorf("no se encontraron las columnas %q y %q en la fila %d", formato.ColumnaFecha, formato.ColumnaMonto, formato.FilaEncabezado)
+	}
+	colDescripcion, colReferencia := -1, -1
+	if formato.ColumnaDescripcion != nil {
+		if colDescripcion = columnaExtracto(encabezados, *formato.ColumnaDescripcion); colDescripcion < 0 {
+			return nil, fmt.Errorf("no se encontró la columna %q", *formato.ColumnaDescripcion)
+		}
+	}
+	if formato.ColumnaReferencia != nil {
+		if colReferencia = columnaExtracto(encabezados, *formato.ColumnaReferencia); colReferencia < 0 {
+			return nil, fmt.Errorf("no se encontró la columna %q", *formato.ColumnaReferencia)
+		}
+	}
+
+	resultado := &models.ResultadoImportacionExtracto{FilasOmitidas: []models.FilaExtractoOmitida{}}
+	layout := layoutFechaExtracto(formato.FormatoFecha)
+	ocurrencias := map[string]int{}
+	var lineas []models.LineaExtractoBancario
+
+	for i := filaEncabezado + 1; i < len(filas); i++ {
+		fila := filas[i]
+		numeroFila := i + 1
+		valorFecha := celdaExtracto(fila, colFecha)
+		valorMonto := celdaExtracto(fila, colMonto)
+		// Filas vacías, separadores o totales sin fecha
+		if valorFecha == "" || valorMonto == "" {
+			continue
+		}
+
+		monto, err := montoExtracto(valorMonto, formato.SeparadorDecimal, numerosCrudos)
+		if err != nil {
+			resultado.FilasOmitidas = append(resultado.FilasOmitidas, models.FilaExtractoOmitida{Fila: numeroFila, Motivo: err.Error()})
+			continue
+		}
+		if monto <= 0 {
+			resultado.FilasDebito++
+			continue
+		}
+		fecha, err := fechaExtracto(valorFecha, layout, numerosCrudos)
+		if err != nil {
+			resultado.FilasOmitidas = append(resultado.FilasOmitidas, models.FilaExtractoOmitida{Fila: numeroFila, Motivo: err.Error()})
+			continue
+		}
+
+		descripcion := celdaExtracto(fila, colDescripcion)
+		referencia := celdaExtracto(fila, colReferencia)
+		clave := fmt.Sprintf("%s|%.2f|%s|%s", fecha.Format("2006-01-02"), monto, descripcion, referencia)
+		ocurrencias[clave]++
+		huella := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", clave, ocurrencias[clave])))
+
+		lineas = append(lineas, models.LineaExtractoBancario{
+			AgenciaID:   agenciaID,
+			Fila:        numeroFila,
+			Fecha:       fecha,
+			Monto:       monto,
+			Descripcion: textoOpcional(descripcion, 1000),
+			Referencia:  textoOpcional(referencia, 150),
+			Huella:      hex.EncodeToString(huella[:]),
+			Estado:      LineaExtractoSinCoincidencia,
+		})
+	}
+
+	if len(lineas) == 0 {
+		return nil, errors.New("el extracto no tiene créditos para importar")
+	}
+	if len(lineas) > maxLineasExtracto {
+		return nil, fmt.Errorf("el extracto no puede tener más de %d créditos", maxLineasExtracto)
+	}
+
+	extracto := models.ExtractoBancario{
+		AgenciaID:     agenciaID,
+		FormatoID:     formato.ID,
+		Banco:         formato.Banco,
+		NombreArchivo: recortarTexto(nombreArchivo, 255),
+		SubidoPor:     usuarioID,
+		VentanaDias:   ventanaDias,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&extracto).Error; err != nil {
+			return err
+		}
+		for i := range lineas {
+			lineas[i].ExtractoID = extracto.ID
+		}
+
+		res := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "agencia_id"}, {Name: "huella"}},
+			DoNothing: true,
+		}).CreateInBatches(&lineas, 500)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("todos los créditos del extracto ya fueron importados")
+		}
+
+		var totales struct {
+			Lineas int
+			Monto  float64
+			Desde  time.Time
+			Hasta  time.Time
+		}
+		if err := tx.Model(&models.LineaExtractoBancario{}).
+			Select("COUNT(*) AS lineas, COALESCE(SUM(monto), 0) AS monto, MIN(fecha) AS desde, MAX(fecha) AS hasta").
+			Where("extracto_id = ?", extracto.ID).
+			Scan(&totales).Error; err != nil {
+			return err
+		}
+
+		extracto.TotalLineas = totales.Lineas
+		extracto.LineasRepetidas = len(lineas) - totales.Lineas
+		extracto.TotalCreditos = totales.Monto
+		extracto.FechaDesde = &totales.Desde
+		extracto.FechaHasta = &totales.Hasta
+		return tx.Save(&extracto).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	propuestas, err := s.ProponerCoincidencias(agenciaID, extracto.ID)
+	if err != nil {
+		return nil, fmt.Errorf("extracto importado, pero falló la búsqueda de coincidencias: %w", err)
+	}
+	resultado.Propuestas = propuestas
+	resultado.Extracto = &extracto
+	return resultado, nil
+}
+
+func (s *ConciliacionService) obtenerExtracto(agenciaID, extractoID uint) (*models.ExtractoBancario, error) {
+	var extracto models.ExtractoBancario
+	if err := s.db.Where("id = ? AND agencia_id = ?", extractoID, agenciaID).First(&extracto).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExtractoNoEncontrado
+		}
+		return nil, err
+	}
+	return &extracto, nil
+}
+
+// pagosConciliables lista los pagos por QR o transferencia pendientes de la agencia registrados entre
+// desde y hasta (inclusive), sin los que ya están propuestos en líneas de otros extractos.
+func (s *ConciliacionService) pagosConciliables(agenciaID uint, desde, hasta time.Time, extractoID uint) ([]models.PagoPendienteConciliacion, error) {
+	var pagos []models.PagoPendienteConciliacion
+	err := s.db.Raw(`
+		SELECT p.id AS pago_id, p.compra_id, p.metodo_pago, p.monto, p.created_at AS fecha_pago,
+		       pt.nombre AS paquete_nombre, u.nombre AS turista_nombre, u.apellido_paterno AS turista_apellido
+		FROM pagos_compras p
+		JOIN compras_paquetes c ON c.id = p.compra_id
+		JOIN paquetes_turisticos pt ON pt.id = c.paquete_id
+		JOIN usuarios u ON u.id = c.turista_id
+		WHERE pt.agencia_id = ?
+		  AND p.estado = 'pendiente'
+		  AND p.metodo_pago IN ?
+		  AND p.created_at >= ? AND p.created_at < ?
+		  AND NOT EXISTS (
+		      SELECT 1 FROM lineas_extracto_bancario l
+		      WHERE l.pago_id = p.id AND l.estado = ? AND l.extracto_id <> ?
+		  )
+		ORDER BY p.created_at ASC, p.id ASC
+	`, agenciaID, metodosPagoBancarios, desde, hasta.AddDate(0, 0, 1), LineaExtractoPropuesta, extractoID).Scan(&pagos).Error
+	for i := range pagos {
+		pagos[i].ReferenciaPago = ReferenciaPagoCompra(pagos[i].CompraID)
+	}
+	return pagos, err
+}
+
+// parConciliacion es una posible coincidencia entre una línea y un pago.
+type parConciliacion struct {
+	linea     int
+	pago      int
+	puntaje   int
+	dias      int
+	criterios []string
+}
+
+func diasEntre(a, b time.Time) int {
+	fa := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	fb := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	dias := int(fa.Sub(fb).Hours() / 24)
+	if dias < 0 {
+		return -dias
+	}
+	return dias
+}
+
+// puntuarCoincidencia evalúa si el crédito puede ser el pago: el monto debe ser igual y la fecha estar
+// dentro de la ventana; suman la cercanía de fechas, la referencia de la compra y el apellido del
+// turista en la glosa. Una referencia a otra compra descarta el par.
+func puntuarCoincidencia(linea *models.LineaExtractoBancario, pago *models.PagoPendienteConciliacion, ventanaDias int) (*parConciliacion, bool) {
+	if math.Abs(linea.Monto-pago.Monto) > 0.01 {
+		return nil, false
+	}
+	dias := diasEntre(linea.Fecha, pago.FechaPago)
+	if dias > ventanaDias {
+		return nil, false
+	}
+
+	par := &parConciliacion{puntaje: 50, dias: dias, criterios: []string{"monto"}}
+	if dias == 0 {
+		par.criterios = append(par.criterios, "misma fecha")
+	} else {
+		par.criterios = append(par.criterios, fmt.Sprintf("fecha a %d día(s)", dias))
+	}
+	if cercania := 20 - 5*dias; cercania > 0 {
+		par.puntaje += cercania
+	}
+
+	var textos []string
+	if linea.Descripcion != nil {
+		textos = append(textos, *linea.Descripcion)
+	}
+	if linea.Referencia != nil {
+		textos = append(textos, *linea.Referencia)
+	}
+	if compraID, ok := compraDeReferenciaPago(textos...); ok {
+		if compraID != pago.CompraID {
+			return nil, false
+		}
+		par.puntaje += 40
+		par.criterios = append(par.criterios, "referencia "+ReferenciaPagoCompra(compraID))
+	}
+	if apellido := utils.GenerateSlug(pago.TuristaApellido); len(apellido) >= 3 {
+		texto := "-" + utils.GenerateSlug(strings.Join(textos, " ")) + "-"
+		if strings.Contains(texto, "-"+apellido+"-") {
+			par.puntaje += 15
+			par.criterios = append(par.criterios, "apellido del turista")
+		}
+	}
+	return par, true
+}
+
+// ProponerCoincidencias vuelve a calcular las coincidencias de las líneas del extracto que no están
+// conciliadas ni ignoradas. Cada pago se propone a una sola línea, priorizando el mayor puntaje.
+func (s *ConciliacionService) ProponerCoincidencias(agenciaID, extractoID uint) (int, error) {
+	extracto, err := s.obtenerExtracto(agenciaID, extractoID)
+	if err != nil {
+		return 0, err
+	}
+
+	var lineas []models.LineaExtractoBancario
+	if err := s.db.Where("extracto_id = ? AND estado IN ?", extracto.ID, []string{LineaExtractoSinCoincidencia, LineaExtractoPropuesta}).
+		Order("fila ASC").Find(&lineas).Error; err != nil {
+		return 0, err
+	}
+	if len(lineas) == 0 {
+		return 0, nil
+	}
+
+	desde, hasta := lineas[0].Fecha, lineas[0].Fecha
+	for _, linea := range lineas {
+		if linea.Fecha.Before(desde) {
+			desde = linea.Fecha
+		}
+		if linea.Fecha.After(hasta) {
+			hasta = linea.Fecha
+		}
+	}
+	pagos, err := s.pagosConciliables(agenciaID, desde.AddDate(0, 0, -extracto.VentanaDias), hasta.AddDate(0, 0, extracto.VentanaDias), extracto.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	var pares []*parConciliacion
+	for i := range lineas {
+		for j := range pagos {
+			if par, ok := puntuarCoincidencia(&lineas[i], &pagos[j], extracto.VentanaDias); ok {
+				par.linea, par.pago = i, j
+				pares = append(pares, par)
+			}
+		}
+	}
+	sort.SliceStable(pares, func(a, b int) bool {
+		if pares[a].puntaje != pares[b].puntaje {
+			return pares[a].puntaje > pares[b].puntaje
+		}
+		if pares[a].dias != pares[b].dias {
+			return pares[a].dias < pares[b].dias
+		}
+		if lineas[pares[a].linea].Fila != lineas[pares[b].linea].Fila {
+			return lineas[pares[a].linea].Fila < lineas[pares[b].linea].Fila
+		}
+		return pagos[pares[a].pago].PagoID < pagos[pares[b].pago].PagoID
+	})
+
+	asignadas := make(map[int]*parConciliacion, len(lineas))
+	pagosUsados := make(map[int]bool, len(pagos))
+	for _, par := range pares {
+		if asignadas[par.linea] != nil || pagosUsados[par.pago] {
+			continue
+		}
+		asignadas[par.linea] = par
+		pagosUsados[par.pago] = true
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range lineas {
+			cambios := map[string]interface{}{
+				"estado":     LineaExtractoSinCoincidencia,
+				"pago_id":    nil,
+				"puntaje":    0,
+				"criterios":  nil,
+				"updated_at": time.Now(),
+			}
+			if par := asignadas[i]; par != nil {
+				cambios["estado"] = LineaExtractoPropuesta
+				cambios["pago_id"] = pagos[par.pago].PagoID
+				cambios["puntaje"] = par.puntaje
+				cambios["criterios"] = recortarTexto(strings.Join(par.criterios, ", "), 255)
+			}
+			// Solo si la línea no se concilió o ignoró mientras tanto
+			if err := tx.Model(&models.LineaExtractoBancario{}).
+				Where("id = ? AND estado IN ?", lineas[i].ID, []string{LineaExtractoSinCoincidencia, LineaExtractoPropuesta}).
+				Updates(cambios).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(asignadas), nil
+}
+
+func (s *ConciliacionService) ListarExtractos(agenciaID uint, page, pageSize int) ([]models.ExtractoBancario, int64, error) {
+	q := s.db.Model(&models.ExtractoBancario{}).Where("agencia_id = ?", agenciaID)
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var extractos []models.ExtractoBancario
+	if err := q.Session(&gorm.Session{}).
+		Order("created_at DESC").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&extractos).Error; err != nil {
+		return nil, 0, err
+	}
+	return extractos, total, nil
+}
+
+// ObtenerExtracto retorna el extracto con sus líneas y los pagos propuestos o conciliados.
+func (s *ConciliacionService) ObtenerExtracto(agenciaID, extractoID uint) (*models.ExtractoBancario, error) {
+	var extracto models.ExtractoBancario
+	if err := s.db.
+		Preload("Lineas", func(db *gorm.DB) *gorm.DB { return db.Order("fila ASC") }).
+		Preload("Lineas.Pago.Compra.Turista").
+		Where("id = ? AND agencia_id = ?", extractoID, agenciaID).
+		First(&extracto).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExtractoNoEncontrado
+		}
+		return nil, err
+	}
+	return &extracto, nil
+}
+
+// ConfirmarCoincidencias confirma en bloque los pagos de las líneas indicadas. Cada línea se procesa por
+// separado: un error (p. ej. un pago que ya no está pendiente) no detiene el resto.
+func (s *ConciliacionService) ConfirmarCoincidencias(agenciaID, extractoID, usuarioID uint, req *models.ConfirmarConciliacionRequest) ([]models.ResultadoConciliacionLinea, error) {
+	extracto, err := s.obtenerExtracto(agenciaID, extractoID)
+	if err != nil {
+		return nil, err
+	}
+
+	resultados := make([]models.ResultadoConciliacionLinea, 0, len(req.Lineas))
+	for _, item := range req.Lineas {
+		resultado := models.ResultadoConciliacionLinea{LineaID: item.LineaID}
+		pagoID, err := s.confirmarLinea(agenciaID, extracto, usuarioID, item)
+		if err != nil {
+			resultado.Error = err.Error()
+		} else {
+			resultado.PagoID = &pagoID
+			resultado.Confirmado = true
+		}
+		resultados = append(resultados, resultado)
+	}
+	return resultados, nil
+}
+
+func (s *ConciliacionService) confirmarLinea(agenciaID uint, extracto *models.ExtractoBancario, usuarioID uint, item models.ConfirmarLineaExtracto) (uint, error) {
+	var pagoID, compraConfirmadaID uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var linea models.LineaExtractoBancario
+		res := tx.Raw(`SELECT * FROM lineas_extracto_bancario WHERE id = ? AND extracto_id = ? FOR UPDATE`, item.LineaID, extracto.ID).Scan(&linea)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("línea no encontrada en el extracto")
+		}
+		if linea.Estado != LineaExtractoPropuesta && linea.Estado != LineaExtractoSinCoincidencia {
+			return fmt.Errorf("la línea ya está %s", linea.Estado)
+		}
+
+		switch {
+		case item.PagoID != nil:
+			pagoID = *item.PagoID
+		case linea.PagoID != nil:
+			pagoID = *linea.PagoID
+		default:
+			return errors.New("la línea no tiene un pago propuesto: indique pago_id")
+		}
+
+		var pago models.PagoCompra
+		if err := tx.Model(&models.PagoCompra{}).
+			Joins("JOIN compras_paquetes c ON c.id = pagos_compras.compra_id").
+			Joins("JOIN paquetes_turisticos pt ON pt.id = c.paquete_id").
+			Where("pagos_compras.id = ? AND pt.agencia_id = ?", pagoID, agenciaID).
+			First(&pago).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("pago #%d no encontrado en la agencia", pagoID)
+			}
+			return err
+		}
+		if pago.Estado != "pendiente" {
+			return fmt.Errorf("el pago #%d ya está %s", pago.ID, pago.Estado)
+		}
+		if pago.MetodoPago != "qr" && pago.MetodoPago != "transferencia" {
+			return fmt.Errorf("el pago #%d es por %s y no se acredita en el banco", pago.ID, pago.MetodoPago)
+		}
+		if math.Abs(pago.Monto-linea.Monto) > 0.01 {
+			return fmt.Errorf("el crédito es de Bs %.2f y el pago #%d de Bs %.2f", linea.Monto, pago.ID, pago.Monto)
+		}
+
+		notas := fmt.Sprintf("Conciliado con el extracto %s #%d (fila %d, %s)", extracto.Banco, extracto.ID, linea.Fila, linea.Fecha.Format("02/01/2006"))
+		// La confirmación del pago y la conciliación de la línea se confirman o revierten juntas
+		var err error
+		compraConfirmadaID, err = NewPagoService(tx).confirmarPago(pago.ID, &usuarioID, &notas)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.LineaExtractoBancario{}).Where("id = ?", linea.ID).Updates(map[string]interface{}{
+			"estado":             LineaExtractoConciliada,
+			"pago_id":            pago.ID,
+			"conciliado_por":     usuarioID,
+			"fecha_conciliacion": now,
+			"updated_at":         now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// El pago ya no puede ser la propuesta de otra línea
+		return tx.Model(&models.LineaExtractoBancario{}).
+			Where("pago_id = ? AND id <> ? AND estado = ?", pago.ID, linea.ID, LineaExtractoPropuesta).
+			Updates(map[string]interface{}{
+				"estado":     LineaExtractoSinCoincidencia,
+				"pago_id":    nil,
+				"puntaje":    0,
+				"criterios":  nil,
+				"updated_at": now,
+			}).Error
+	})
+	if err != nil {
+		return pagoID, err
+	}
+	enviarVoucherConfirmacion(s.db, compraConfirmadaID)
+	return pagoID, nil
+}
+
+// IgnorarLinea marca un crédito que no corresponde a pagos de turistas (p. ej. un depósito propio).
+func (s *ConciliacionService) IgnorarLinea(agenciaID, extractoID, lineaID uint) error {
+	if _, err := s.obtenerExtracto(agenciaID, extractoID); err != nil {
+		return err
+	}
+	res := s.db.Model(&models.LineaExtractoBancario{}).
+		Where("id = ? AND extracto_id = ? AND estado IN ?", lineaID, extractoID, []string{LineaExtractoSinCoincidencia, LineaExtractoPropuesta}).
+		Updates(map[string]interface{}{
+			"estado":     LineaExtractoIgnorada,
+			"pago_id":    nil,
+			"puntaje":    0,
+			"criterios":  nil,
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("línea no encontrada o ya conciliada")
+	}
+	return nil
+}
+
+// Pendientes lista los créditos del extracto sin pago y los pagos pendientes del período del extracto
+// (más la ventana de tolerancia) que no tienen un crédito propuesto.
+func (s *ConciliacionService) Pendientes(agenciaID, extractoID uint) (*models.PendientesConciliacionResponse, error) {
+	extracto, err := s.obtenerExtracto(agenciaID, extractoID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.PendientesConciliacionResponse{
+		CreditosSinConciliar: []models.LineaExtractoBancario{},
+		PagosSinCredito:      []models.PagoPendienteConciliacion{},
+	}
+	if err := s.db.Where("extracto_id = ? AND estado = ?", extracto.ID, LineaExtractoSinCoincidencia).
+		Order("fila ASC").Find(&resp.CreditosSinConciliar).Error; err != nil {
+		return nil, err
+	}
+	if extracto.FechaDesde == nil || extracto.FechaHasta == nil {
+		return resp, nil
+	}
+
+	pagos, err := s.pagosConciliables(agenciaID,
+		extracto.FechaDesde.AddDate(0, 0, -extracto.VentanaDias),
+		extracto.FechaHasta.AddDate(0, 0, extracto.VentanaDias),
+		extracto.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var propuestos []uint
+	if err := s.db.Model(&models.LineaExtractoBancario{}).
+		Where("extracto_id = ? AND estado = ? AND pago_id IS NOT NULL", extracto.ID, LineaExtractoPropuesta).
+		Pluck("pago_id", &propuestos).Error; err != nil {
+		return nil, err
+	}
+	conLinea := make(map[uint]bool, len(propuestos))
+	for _, id := range propuestos {
+		conLinea[id] = true
+	}
+	for _, pago := range pagos {
+		if !conLinea[pago.PagoID] {
+			resp.PagosSinCredito = append(resp.PagosSinCredito, pago)
+		}
+	}
+	return resp, nil
+}
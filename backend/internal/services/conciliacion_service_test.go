@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMontoExtracto(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		valor     string
+		separador string
+		crudos    bool
+		want      float64
+		err       bool
+	}{
+		{"coma decimal", "350,50", ",", false, 350.5, false},
+		{"coma decimal con miles", "1.234.567,89", ",", false, 1234567.89, false},
+		{"punto decimal con miles", "1,234,567.89", ".", false, 1234567.89, false},
+		{"miles sin decimales", "12.500", ",", false, 12500, false},
+		{"prefijo de moneda con punto", "Bs. 1.234,56", ",", false, 1234.56, false},
+		{"espacios y moneda al final", " 980,00 BOB ", ",", false, 980, false},
+		{"negativo con signo", "-150,00", ",", false, -150, false},
+		{"negativo con signo al final", "150,00-", ",", false, -150, false},
+		{"negativo entre paréntesis", "(1.150,25)", ",", false, -1150.25, false},
+		{"redondeo a centavos", "10,555", ",", false, 10.56, false},
+		{"número crudo de Excel", "1234.5", ",", true, 1234.5, false},
+		{"número crudo negativo", "-80.125", ",", true, -80.13, false},
+		{"número crudo en notación científica", "1.5E3", ",", true, 1500, false},
+		{"texto en una celda de Excel", "1.234,56", ",", true, 1234.56, false},
+		{"celda sin dígitos", "Bs.", ",", false, 0, true},
+		{"dos comas decimales", "1.234,5,6", ",", false, 0, true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			got, err := montoExtracto(c.valor, c.separador, c.crudos)
+			if c.err {
+				if err == nil {
+					t.Fatalf("montoExtracto(%q) = %v, se esperaba un error", c.valor, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("montoExtracto(%q) = %v, se esperaba %v", c.valor, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFechaExtracto(t *testing.T) {
+	casos := []struct {
+		nombre string
+		valor  string
+		patron string
+		crudos bool
+		want   string
+		err    bool
+	}{
+		{"día/mes/año", "15/11/2026", "DD/MM/YYYY", false, "2026-11-15", false},
+		{"año-mes-día", "2026-11-15", "YYYY-MM-DD", false, "2026-11-15", false},
+		{"año de dos dígitos", "15-11-26", "DD-MM-YY", false, "2026-11-15", false},
+		{"mes/día/año", "11/15/2026", "MM/DD/YYYY", false, "2026-11-15", false},
+		{"con hora después del patrón", "15/11/2026 14:32:10", "DD/MM/YYYY", false, "2026-11-15", false},
+		{"patrón con hora", "15/11/2026 14:32", "DD/MM/YYYY HH:mm", false, "2026-11-15", false},
+		{"serial de Excel", "46341", "DD/MM/YYYY", true, "2026-11-15", false},
+		{"serial de Excel con hora", "46341.75", "DD/MM/YYYY", true, "2026-11-15", false},
+		{"texto en una celda de Excel", "15/11/2026", "DD/MM/YYYY", true, "2026-11-15", false},
+		{"serial fuera de un .xlsx", "46341", "DD/MM/YYYY", false, "", true},
+		{"fecha inexistente", "31/02/2026", "DD/MM/YYYY", false, "", true},
+		{"patrón distinto", "2026-11-15", "DD/MM/YYYY", false, "", true},
+		{"vacía", "", "DD/MM/YYYY", false, "", true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			got, err := fechaExtracto(c.valor, layoutFechaExtracto(c.patron), c.crudos)
+			if c.err {
+				if err == nil {
+					t.Fatalf("fechaExtracto(%q) = %v, se esperaba un error", c.valor, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if got.Format("2006-01-02") != c.want || got.Location() != time.UTC || got.Hour() != 0 || got.Minute() != 0 {
+				t.Errorf("fechaExtracto(%q) = %v, se esperaba %s 00:00 UTC", c.valor, got, c.want)
+			}
+		})
+	}
+}
+
+func TestColumnaExtracto(t *testing.T) {
+	encabezados := []string{"Fecha", " Descripción ", "Débito", "Crédito", "Saldo"}
+	casos := []struct {
+		columna string
+		want    int
+	}{
+		{"fecha", 0},
+		{"Descripción", 1},
+		{"CRÉDITO", 3},
+		{"E", 4},
+		{"d", 3},
+		{"AA", 26},
+		{"Referencia", -1},
+		{"A1", -1},
+	}
+	for _, c := range casos {
+		if got := columnaExtracto(encabezados, c.columna); got != c.want {
+			t.Errorf("columnaExtracto(%q) = %d, se esperaba %d", c.columna, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// entradaDescuentos reúne los datos de una compra (o cotización) necesarios para evaluar descuentos.
+type entradaDescuentos struct {
+	AgenciaID     uint
+	PaqueteID     uint
+	TipoCompra    string
+	FechaReserva  time.Time
+	FechaSalida   time.Time
+	PersonasPagan int
+	// Cupo mínimo de la salida y participantes que ya tenía sin contar esta compra
+	CupoMinimoSalida int
+	OcupadosPrevios  int
+	Precio           float64
+}
+
+func diasEntreFechas(desde, hasta time.Time) int {
+	d := time.Date(desde.Year(), desde.Month(), desde.Day(), 0, 0, 0, 0, time.UTC)
+	h := time.Date(hasta.Year(), hasta.Month(), hasta.Day(), 0, 0, 0, 0, time.UTC)
+	return int(h.Sub(d).Hours() / 24)
+}
+
+func reglaDescuentoAplica(regla *models.ReglaDescuento, in *entradaDescuentos) bool {
+	diasAntes := diasEntreFechas(in.FechaReserva, in.FechaSalida)
+	switch regla.Tipo {
+	case "grupo":
+		return regla.MinPersonas != nil && in.PersonasPagan >= *regla.MinPersonas
+	case "anticipacion":
+		return regla.DiasAnticipacion != nil && diasAntes >= *regla.DiasAnticipacion
+	case "ultimo_minuto":
+		if regla.DiasAnticipacion == nil || diasAntes > *regla.DiasAnticipacion {
+			return false
+		}
+		if regla.SoloBajoCupoMinimo {
+			return in.TipoCompra == "compartido" && in.OcupadosPrevios < in.CupoMinimoSalida
+		}
+		return true
+	}
+	return false
+}
+
+// calcularDescuentosCompra evalúa las reglas automáticas de la agencia y, si se indica, la promoción,
+// siguiendo la política de acumulación descrita en models.ReglaDescuento.
+// Retorna el detalle (sin CompraID) y el descuento total. La promoción se omite si no genera descuento.
+func calcularDescuentosCompra(tx *gorm.DB, in *entradaDescuentos, promo *models.Promocion) ([]models.CompraDescuento, float64, error) {
+	var reglas []models.ReglaDescuento
+	if err := tx.
+		Where("agencia_id = ? AND activa = ?", in.AgenciaID, true).
+		Where("paquete_id IS NULL OR paquete_id = ?", in.PaqueteID).
+		Order("orden ASC").
+		Order("id ASC").
+		Find(&reglas).Error; err != nil {
+		return nil, 0, err
+	}
+	detalle, descuento := aplicarDescuentos(reglas, in, promo)
+	return detalle, descuento, nil
+}
+
+// aplicarDescuentos aplica sobre in.Precio las reglas (ya ordenadas por Orden e ID) y la promoción.
+func aplicarDescuentos(reglas []models.ReglaDescuento, in *entradaDescuentos, promo *models.Promocion) ([]models.CompraDescuento, float64) {
+	detalle := []models.CompraDescuento{}
+	restante := in.Precio
+	for i := range reglas {
+		regla := &reglas[i]
+		if !reglaDescuentoAplica(regla, in) {
+			continue
+		}
+		if !regla.Acumulable && len(detalle) > 0 {
+			continue
+		}
+		monto := redondearMonto(restante * regla.Porcentaje / 100)
+		if monto > restante {
+			monto = restante
+		}
+		if monto > 0 {
+			reglaID := regla.ID
+			detalle = append(detalle, models.CompraDescuento{
+				ReglaDescuentoID: &reglaID,
+				Tipo:             regla.Tipo,
+				Nombre:           regla.Nombre,
+				Porcentaje:       regla.Porcentaje,
+				Monto:            monto,
+			})
+			restante = redondearMonto(restante - monto)
+		}
+		if !regla.Acumulable {
+			break
+		}
+	}
+
+	if promo != nil {
+		monto := calcularDescuentoPromocion(promo, restante)
+		if monto <= 0 {
+			return detalle, redondearMonto(in.Precio - restante)
+		}
+		promoID := promo.ID
+		porcentaje := 0.0
+		if restante > 0 {
+			porcentaje = redondearMonto(monto / restante * 100)
+		}
+		detalle = append(detalle, models.CompraDescuento{
+			PromocionID: &promoID,
+			Tipo:        "promocion",
+			Nombre:      promo.Codigo,
+			Porcentaje:  porcentaje,
+			Monto:       monto,
+		})
+		restante = redondearMonto(restante - monto)
+	}
+
+	return detalle, redondearMonto(in.Precio - restante)
+}
+
+// montoPromocionDetalle retorna el descuento aportado por el código de promoción (0 si no aplicó).
+func montoPromocionDetalle(detalle []models.CompraDescuento) float64 {
+	for _, d := range detalle {
+		if d.PromocionID != nil {
+			return d.Monto
+		}
+	}
+	return 0
+}
+
+// totalesDescuento calcula los campos de descuento de la compra a partir del precio base.
+func totalesDescuento(precioBase, descuento float64) (*float64, float64, float64) {
+	var precioSinDescuento *float64
+	porcentaje := 0.0
+	if descuento > 0 {
+		base := redondearMonto(precioBase)
+		precioSinDescuento = &base
+		if precioBase > 0 {
+			porcentaje = redondearMonto(descuento / precioBase * 100)
+		}
+	}
+	return precioSinDescuento, porcentaje, redondearMonto(precioBase - descuento)
+}
+
+// reemplazarDetalleDescuentos reemplaza el detalle de descuentos registrado para la compra.
+func reemplazarDetalleDescuentos(tx *gorm.DB, compraID uint, detalle []models.CompraDescuento) error {
+	if err := tx.Where("compra_id = ?", compraID).Delete(&models.CompraDescuento{}).Error; err != nil {
+		return err
+	}
+	for i := range detalle {
+		detalle[i].ID = 0
+		detalle[i].CompraID = compraID
+		if err := tx.Create(&detalle[i]).Error; err != nil {
+			return fmt.Errorf("error registrando descuento: %w", err)
+		}
+	}
+	return nil
+}
+
+// ocupacionSalidaDescuentos retorna el cupo mínimo y los participantes previos de la salida, excluyendo
+// los de la compra evaluada (ya reservados). Si salidaID es 0 la compra crearía la salida.
+func ocupacionSalidaDescuentos(tx *gorm.DB, paqueteID uint, salidaID uint, excluir int) (int, int, error) {
+	if salidaID == 0 {
+		var paquete models.PaqueteTuristico
+		if err := tx.Select("id", "cupo_minimo").First(&paquete, paqueteID).Error; err != nil {
+			return 0, 0, err
+		}
+		return paquete.CupoMinimo, 0, nil
+	}
+
+	var salida models.PaqueteSalidaHabilitada
+	if err := tx.Select("id", "cupo_minimo", "cupos_reservados", "cupos_confirmados").First(&salida, salidaID).Error; err != nil {
+		return 0, 0, err
+	}
+	ocupados := salida.CuposReservados + salida.CuposConfirmados - excluir
+	if ocupados < 0 {
+		ocupados = 0
+	}
+	return salida.CupoMinimo, ocupados, nil
+}
+
+// entradaDescuentosCompra arma la entrada del pipeline para una compra ya registrada (con sus cupos reservados).
+func entradaDescuentosCompra(tx *gorm.DB, compra *models.CompraPaquete, precio float64) (*entradaDescuentos, error) {
+	var paquete models.PaqueteTuristico
+	if err := tx.Select("id", "agencia_id").First(&paquete, compra.PaqueteID).Error; err != nil {
+		return nil, err
+	}
+
+	var salidaID uint
+	if compra.SalidaID != nil {
+		salidaID = *compra.SalidaID
+	}
+	cupoMinimo, ocupados, err := ocupacionSalidaDescuentos(tx, compra.PaqueteID, salidaID, compra.TotalParticipantes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entradaDescuentos{
+		AgenciaID:        paquete.AgenciaID,
+		PaqueteID:        compra.PaqueteID,
+		TipoCompra:       compra.TipoCompra,
+		FechaReserva:     compra.FechaCompra,
+		FechaSalida:      compra.FechaSeleccionada,
+		PersonasPagan:    compra.CantidadAdultos + compra.CantidadNinosPagan,
+		CupoMinimoSalida: cupoMinimo,
+		OcupadosPrevios:  ocupados,
+		Precio:           precio,
+	}, nil
+}
+
+// guardarDescuentosCompra registra el detalle de descuentos y actualiza los totales de la compra.
+// precioBase es el precio calculado antes de descuentos.
+func guardarDescuentosCompra(tx *gorm.DB, compra *models.CompraPaquete, precioBase float64, detalle []models.CompraDescuento, descuento float64) error {
+	if err := reemplazarDetalleDescuentos(tx, compra.ID, detalle); err != nil {
+		return err
+	}
+
+	precioSinDescuento, porcentaje, precioFinal := totalesDescuento(precioBase, descuento)
+
+	if err := tx.Model(&models.CompraPaquete{}).Where("id = ?", compra.ID).Updates(map[string]interface{}{
+		"precio_sin_descuento":          precioSinDescuento,
+		"descuento_aplicado":            descuento,
+		"porcentaje_descuento_aplicado": porcentaje,
+		"precio_total":                  precioFinal,
+		"updated_at":                    time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+
+	compra.PrecioSinDescuento = precioSinDescuento
+	compra.DescuentoAplicado = descuento
+	compra.PorcentajeDescuentoAplicado = porcentaje
+	compra.PrecioTotal = precioFinal
+	return nil
+}
+
+// aplicarDescuentosCompra aplica las reglas automáticas y el código de promoción (opcional) a una compra
+// recién creada dentro de la misma transacción. Registra el uso de la promoción si corresponde.
+func aplicarDescuentosCompra(tx *gorm.DB, compraID uint, turistaID uint, codigo *string) (*models.CompraPaquete, error) {
+	var compra models.CompraPaquete
+	if err := tx.Where("id = ? AND turista_id = ?", compraID, turistaID).First(&compra).Error; err != nil {
+		return nil, err
+	}
+
+	var promo *models.Promocion
+	if codigo != nil && strings.TrimSpace(*codigo) != "" {
+		var err error
+		promo, err = buscarPromocionPorCodigo(tx, compra.PaqueteID, *codigo, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := validarPromocion(tx, promo, compra.PaqueteID, &turistaID); err != nil {
+			return nil, err
+		}
+	}
+
+	in, err := entradaDescuentosCompra(tx, &compra, compra.PrecioTotal)
+	if err != nil {
+		return nil, err
+	}
+	detalle, descuento, err := calcularDescuentosCompra(tx, in, promo)
+	if err != nil {
+		return nil, err
+	}
+	montoPromo := montoPromocionDetalle(detalle)
+	if promo != nil && montoPromo <= 0 {
+		return nil, errors.New("el código de promoción no genera descuento para esta compra")
+	}
+	if descuento <= 0 {
+		return &compra, nil
+	}
+
+	if err := guardarDescuentosCompra(tx, &compra, in.Precio, detalle, descuento); err != nil {
+		return nil, err
+	}
+
+	if promo != nil {
+		if err := tx.Model(&compra).Update("promocion_id", promo.ID).Error; err != nil {
+			return nil, err
+		}
+		uso := models.PromocionUso{
+			PromocionID:    promo.ID,
+			CompraID:       compra.ID,
+			TuristaID:      turistaID,
+			MontoDescuento: montoPromo,
+		}
+		if err := tx.Create(&uso).Error; err != nil {
+			return nil, fmt.Errorf("error registrando uso de promoción: %w", err)
+		}
+		compra.PromocionID = &promo.ID
+	}
+
+	return &compra, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"andaria-backend/internal/models"
+)
+
+func TestAplicarDescuentos(t *testing.T) {
+	entero := func(n int) *int { return &n }
+	grupo := func(id uint, porcentaje float64, acumulable bool) models.ReglaDescuento {
+		return models.ReglaDescuento{ID: id, Nombre: "grupo", Tipo: "grupo", MinPersonas: entero(4), Porcentaje: porcentaje, Acumulable: acumulable}
+	}
+	anticipacion := func(id uint, dias int, porcentaje float64, acumulable bool) models.ReglaDescuento {
+		return models.ReglaDescuento{ID: id, Nombre: "anticipacion", Tipo: "anticipacion", DiasAnticipacion: entero(dias), Porcentaje: porcentaje, Acumulable: acumulable}
+	}
+	ultimoMinuto := func(id uint, porcentaje float64) models.ReglaDescuento {
+		return models.ReglaDescuento{ID: id, Nombre: "ultimo_minuto", Tipo: "ultimo_minuto", DiasAnticipacion: entero(5), SoloBajoCupoMinimo: true, Porcentaje: porcentaje, Acumulable: true}
+	}
+	salida := time.Date(2026, 11, 15, 0, 0, 0, 0, time.UTC)
+	// Cuatro personas reservan 45 días antes en una salida compartida que aún no alcanza su cupo mínimo
+	entrada := func(cambios ...func(*entradaDescuentos)) *entradaDescuentos {
+		in := &entradaDescuentos{
+			AgenciaID:        1,
+			PaqueteID:        1,
+			TipoCompra:       "compartido",
+			FechaReserva:     salida.AddDate(0, 0, -45),
+			FechaSalida:      salida,
+			PersonasPagan:    4,
+			CupoMinimoSalida: 6,
+			OcupadosPrevios:  2,
+			Precio:           1000,
+		}
+		for _, cambio := range cambios {
+			cambio(in)
+		}
+		return in
+	}
+	ultimaSemana := func(in *entradaDescuentos) { in.FechaReserva = salida.AddDate(0, 0, -3) }
+
+	type aplicado struct {
+		nombre string
+		monto  float64
+	}
+	casos := []struct {
+		nombre string
+		reglas []models.ReglaDescuento
+		in     *entradaDescuentos
+		promo  *models.Promocion
+		want   []aplicado
+		total  float64
+	}{
+		{nombre: "sin reglas ni promoción", in: entrada()},
+		{nombre: "regla cuya condición no se cumple",
+			reglas: []models.ReglaDescuento{grupo(1, 10, true)},
+			in:     entrada(func(in *entradaDescuentos) { in.PersonasPagan = 3 })},
+		{nombre: "acumulables en cadena sobre el precio restante",
+			reglas: []models.ReglaDescuento{grupo(1, 10, true), anticipacion(2, 30, 5, true)},
+			in:     entrada(),
+			want:   []aplicado{{"grupo", 100}, {"anticipacion", 45}}, total: 145},
+		{nombre: "no acumulable primera detiene la evaluación",
+			reglas: []models.ReglaDescuento{anticipacion(1, 30, 20, false), grupo(2, 10, true)},
+			in:     entrada(),
+			want:   []aplicado{{"anticipacion", 200}}, total: 200},
+		{nombre: "no acumulable después de otra aplicada se omite",
+			reglas: []models.ReglaDescuento{grupo(1, 10, true), anticipacion(2, 30, 20, false), ultimoMinuto(3, 5)},
+			in:     entrada(ultimaSemana),
+			want:   []aplicado{{"grupo", 100}, {"ultimo_minuto", 45}}, total: 145},
+		{nombre: "no acumulable que no se cumple no detiene la evaluación",
+			reglas: []models.ReglaDescuento{anticipacion(1, 60, 20, false), grupo(2, 10, true)},
+			in:     entrada(),
+			want:   []aplicado{{"grupo", 100}}, total: 100},
+		{nombre: "el orden recibido decide cuál no acumulable gana",
+			reglas: []models.ReglaDescuento{grupo(2, 10, false), anticipacion(1, 30, 20, false)},
+			in:     entrada(),
+			want:   []aplicado{{"grupo", 100}}, total: 100},
+		{nombre: "último minuto en salida bajo el cupo mínimo",
+			reglas: []models.ReglaDescuento{ultimoMinuto(1, 15)},
+			in:     entrada(ultimaSemana),
+			want:   []aplicado{{"ultimo_minuto", 150}}, total: 150},
+		{nombre: "último minuto en salida que ya alcanzó el cupo mínimo",
+			reglas: []models.ReglaDescuento{ultimoMinuto(1, 15)},
+			in:     entrada(ultimaSemana, func(in *entradaDescuentos) { in.OcupadosPrevios = 6 })},
+		{nombre: "último minuto en salida privada",
+			reglas: []models.ReglaDescuento{ultimoMinuto(1, 15)},
+			in:     entrada(ultimaSemana, func(in *entradaDescuentos) { in.TipoCompra = "privado" })},
+		{nombre: "promoción porcentual sobre el precio con descuentos",
+			reglas: []models.ReglaDescuento{grupo(1, 10, true)},
+			in:     entrada(),
+			promo:  &models.Promocion{ID: 7, Codigo: "VERANO", TipoDescuento: "porcentaje", Valor: 10},
+			want:   []aplicado{{"grupo", 100}, {"VERANO", 90}}, total: 190},
+		{nombre: "promoción se aplica después de una no acumulable",
+			reglas: []models.ReglaDescuento{anticipacion(1, 30, 20, false)},
+			in:     entrada(),
+			promo:  &models.Promocion{ID: 7, Codigo: "FIJO50", TipoDescuento: "monto_fijo", Valor: 50},
+			want:   []aplicado{{"anticipacion", 200}, {"FIJO50", 50}}, total: 250},
+		{nombre: "promoción de monto fijo mayor al precio restante",
+			reglas: []models.ReglaDescuento{grupo(1, 50, false)},
+			in:     entrada(),
+			promo:  &models.Promocion{ID: 7, Codigo: "FIJO800", TipoDescuento: "monto_fijo", Valor: 800},
+			want:   []aplicado{{"grupo", 500}, {"FIJO800", 500}}, total: 1000},
+		{nombre: "promoción sin descuento se omite",
+			reglas: []models.ReglaDescuento{grupo(1, 10, true)},
+			in:     entrada(),
+			promo:  &models.Promocion{ID: 7, Codigo: "CERO", TipoDescuento: "porcentaje", Valor: 0},
+			want:   []aplicado{{"grupo", 100}}, total: 100},
+		{nombre: "cada descuento se redondea a centavos",
+			reglas: []models.ReglaDescuento{grupo(1, 15, true), anticipacion(2, 30, 15, true)},
+			in:     entrada(func(in *entradaDescuentos) { in.Precio = 333.33 }),
+			want:   []aplicado{{"grupo", 50}, {"anticipacion", 42.5}}, total: 92.5},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			detalle, total := aplicarDescuentos(c.reglas, c.in, c.promo)
+			if total != c.total {
+				t.Errorf("descuento total = %v, se esperaba %v", total, c.total)
+			}
+			if len(detalle) != len(c.want) {
+				t.Fatalf("detalle = %+v, se esperaban %v", detalle, c.want)
+			}
+			suma := 0.0
+			for i, w := range c.want {
+				if detalle[i].Nombre != w.nombre || detalle[i].Monto != w.monto {
+					t.Errorf("descuento %d = %s %v, se esperaba %s %v", i, detalle[i].Nombre, detalle[i].Monto, w.nombre, w.monto)
+				}
+				suma += detalle[i].Monto
+			}
+			if redondearMonto(suma) != total {
+				t.Errorf("la suma del detalle (%v) no coincide con el total (%v)", suma, total)
+			}
+		})
+	}
+}
+
+func TestAplicarDescuentosDetallePromocion(t *testing.T) {
+	in := &entradaDescuentos{PersonasPagan: 4, Precio: 1000}
+	reglas := []models.ReglaDescuento{{ID: 3, Nombre: "grupo", Tipo: "grupo", MinPersonas: &in.PersonasPagan, Porcentaje: 20, Acumulable: true}}
+	promo := &models.Promocion{ID: 7, Codigo: "FIJO200", TipoDescuento: "monto_fijo", Valor: 200}
+
+	detalle, _ := aplicarDescuentos(reglas, in, promo)
+	if len(detalle) != 2 {
+		t.Fatalf("detalle = %+v, se esperaban dos descuentos", detalle)
+	}
+	regla, promocion := detalle[0], detalle[1]
+	if regla.ReglaDescuentoID == nil || *regla.ReglaDescuentoID != 3 || regla.PromocionID != nil || regla.Porcentaje != 20 {
+		t.Errorf("descuento de la regla = %+v", regla)
+	}
+	// 200 sobre los 800 restantes
+	if promocion.PromocionID == nil || *promocion.PromocionID != 7 || promocion.ReglaDescuentoID != nil ||
+		promocion.Tipo != "promocion" || promocion.Porcentaje != 25 {
+		t.Errorf("descuento de la promoción = %+v", promocion)
+	}
+}
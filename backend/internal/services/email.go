@@ -1,10 +1,15 @@
 package services
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"mime/multipart"
 	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"os"
+	"strings"
 )
 
 type EmailService struct {
@@ -50,6 +55,77 @@ func (s *EmailService) SendEmail(to, subject, body string) error {
 	return smtp.SendMail(addr, auth, envelopeFrom, []string{to}, []byte(msg))
 }
 
+// EmailAttachment es un archivo adjunto a un email.
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SendEmailWithAttachments envía un email de texto plano con archivos adjuntos (multipart/mixed).
+func (s *EmailService) SendEmailWithAttachments(to, subject, body string, attachments []EmailAttachment) error {
+	auth := smtp.PlainAuth("", s.User, s.Pass, s.Host)
+
+	fromHeader := s.From
+	envelopeFrom := s.User
+	if parsed, err := mail.ParseAddress(s.From); err == nil {
+		fromHeader = parsed.String()
+		envelopeFrom = parsed.Address
+	} else if s.From == "" {
+		fromHeader = s.User
+	}
+
+	var content bytes.Buffer
+	writer := multipart.NewWriter(&content)
+
+	textPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=UTF-8"},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := textPart.Write([]byte(body)); err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {fmt.Sprintf("%s; name=\"%s\"", attachment.ContentType, attachment.Filename)},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=\"%s\"", attachment.Filename)},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return err
+		}
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		// RFC 2045: líneas de máximo 76 caracteres
+		for len(encoded) > 76 {
+			if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+				return err
+			}
+			encoded = encoded[76:]
+		}
+		if _, err := part.Write([]byte(encoded + "\r\n")); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("From: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: multipart/mixed; boundary=\"%s\"\r\n"+
+		"\r\n", fromHeader, to, subject, writer.Boundary())
+
+	addr := fmt.Sprintf("%s:%s", s.Host, s.Port)
+
+	return smtp.SendMail(addr, auth, envelopeFrom, []string{to}, append([]byte(msg), content.Bytes()...))
+}
+
 func (s *EmailService) SendVerificationCode(to, code string) error {
 	subject := "ANDARIA - Código de Verificación"
 	body := fmt.Sprintf(`Hola,
@@ -105,3 +181,61 @@ Equipo ANDARIA`, agencyName, code)
 
 	return s.SendEmail(to, subject, body)
 }
+
+func (s *EmailService) SendCompraConfirmadaVoucher(to, nombre, paqueteNombre, codigo string, voucher EmailAttachment) error {
+	subject := fmt.Sprintf("ANDARIA - Reserva confirmada %s", codigo)
+	body := fmt.Sprintf(`Hola %s,
+
+Tu reserva para "%s" fue confirmada.
+
+Código de confirmación: %s
+
+Adjuntamos tu voucher. Preséntalo (impreso o en tu celular) en el punto de encuentro;
+el código QR puede leerse sin conexión a internet.
+
+Saludos,
+Equipo ANDARIA`, nombre, paqueteNombre, codigo)
+
+	return s.SendEmailWithAttachments(to, subject, body, []EmailAttachment{voucher})
+}
+
+func (s *EmailService) SendSalidaCancelada(to, nombre, paqueteNombre, fecha, razon string, montoReembolso float64, alternativas []string) error {
+	subject := fmt.Sprintf("ANDARIA - Salida cancelada: %s", paqueteNombre)
+	detalle := "No tenías pagos confirmados, por lo que no corresponde reembolso."
+	if montoReembolso > 0 {
+		detalle = fmt.Sprintf("La agencia te devolverá Bs %.2f (el total pagado). Te avisaremos cuando el reembolso sea pagado.", montoReembolso)
+	}
+	otras := ""
+	if len(alternativas) > 0 {
+		otras = "\nFechas alternativas con cupos disponibles: " + strings.Join(alternativas, ", ") + "\n"
+	}
+	body := fmt.Sprintf(`Hola %s,
+
+La agencia canceló la salida de "%s" del %s.
+
+Motivo: %s
+
+%s
+%s
+Saludos,
+Equipo ANDARIA`, nombre, paqueteNombre, fecha, razon, detalle, otras)
+
+	return s.SendEmail(to, subject, body)
+}
+
+func (s *EmailService) SendSalidaReprogramada(to, nombre, paqueteNombre, fechaAnterior, fechaNueva, razon, fechaLimite string) error {
+	subject := fmt.Sprintf("ANDARIA - Cambio de fecha: %s", paqueteNombre)
+	body := fmt.Sprintf(`Hola %s,
+
+La agencia cambió la fecha de tu salida de "%s" del %s al %s.
+
+Motivo: %s
+
+Ingresa a ANDARIA para aceptar la nueva fecha o cancelar tu compra con reembolso total
+antes del %s. Si no respondes a tiempo, tu compra se cancelará con reembolso total.
+
+Saludos,
+Equipo ANDARIA`, nombre, paqueteNombre, fechaAnterior, fechaNueva, razon, fechaLimite)
+
+	return s.SendEmail(to, subject, body)
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ConflictoGuiasError indica que uno o más guías ya están asignados a salidas que se superponen.
+type ConflictoGuiasError struct {
+	Conflictos []models.ConflictoGuia
+}
+
+func (e *ConflictoGuiasError) Error() string {
+	nombres := []string{}
+	vistos := map[uint]bool{}
+	for _, c := range e.Conflictos {
+		if !vistos[c.GuiaID] {
+			vistos[c.GuiaID] = true
+			nombres = append(nombres, c.GuiaNombre)
+		}
+	}
+	return fmt.Sprintf("conflicto de horario: %s ya tiene salidas asignadas en esas fechas", strings.Join(nombres, ", "))
+}
+
+type GuiaService struct {
+	db *gorm.DB
+}
+
+func NewGuiaService(db *gorm.DB) *GuiaService {
+	return &GuiaService{db: db}
+}
+
+// sqlRangoSalida calcula las fechas que ocupa una salida según la duración del paquete.
+// Los paquetes de varios días ocupan el día completo.
+const sqlRangoSalida = `
+	s.fecha_salida AS inicio,
+	s.fecha_salida + (GREATEST(COALESCE(p.duracion_dias, 1), 1) - 1) AS fin,
+	CASE WHEN COALESCE(p.duracion_dias, 1) > 1 THEN 'todo_dia' ELSE COALESCE(p.horario, 'todo_dia') END AS horario`
+
+// conflictosGuias retorna las salidas vigentes de los guías que se superponen con la salida indicada.
+// Dos salidas del mismo día no chocan si ambas son de medio día en horarios distintos.
+func conflictosGuias(tx *gorm.DB, salidaID uint, guiaIDs []uint) ([]models.ConflictoGuia, error) {
+	if len(guiaIDs) == 0 {
+		return nil, nil
+	}
+
+	var conflictos []models.ConflictoGuia
+	err := tx.Raw(`
+		WITH objetivo AS (
+			SELECT s.id,`+sqlRangoSalida+`
+			FROM paquete_salidas_habilitadas s
+			JOIN paquetes_turisticos p ON p.id = s.paquete_id
+			WHERE s.id = ?
+		), otras AS (
+			SELECT sg.guia_id, g.nombre AS guia_nombre, s.id AS salida_id, p.nombre AS paquete_nombre,`+sqlRangoSalida+`
+			FROM salida_guias sg
+			JOIN guias g ON g.id = sg.guia_id
+			JOIN paquete_salidas_habilitadas s ON s.id = sg.salida_id
+			JOIN paquetes_turisticos p ON p.id = s.paquete_id
+			WHERE sg.guia_id IN ?
+			  AND s.estado IN ('pendiente', 'activa', 'en_curso')
+		)
+		SELECT o.guia_id, o.guia_nombre, o.salida_id, o.paquete_nombre,
+		       to_char(o.inicio, 'YYYY-MM-DD') AS fecha_inicio,
+		       to_char(o.fin, 'YYYY-MM-DD') AS fecha_fin,
+		       o.horario
+		FROM otras o
+		CROSS JOIN objetivo t
+		WHERE o.salida_id <> t.id
+		  AND o.inicio <= t.fin
+		  AND o.fin >= t.inicio
+		  AND (o.horario = 'todo_dia' OR t.horario = 'todo_dia' OR o.horario = t.horario)
+		ORDER BY o.inicio ASC, o.guia_id ASC
+	`, salidaID, guiaIDs).Scan(&conflictos).Error
+	return conflictos, err
+}
+
+// notificarGuiasSalida notifica a los guías con cuenta asignados a la salida.
+func notificarGuiasSalida(tx *gorm.DB, salidaID uint, tipo, titulo, mensaje string) error {
+	var usuarioIDs []uint
+	if err := tx.Raw(`
+		SELECT DISTINCT g.usuario_id
+		FROM salida_guias sg
+		JOIN guias g ON g.id = sg.guia_id
+		WHERE sg.salida_id = ? AND g.usuario_id IS NOT NULL
+	`, salidaID).Scan(&usuarioIDs).Error; err != nil {
+		return err
+	}
+	for _, usuarioID := range usuarioIDs {
+		if _, err := notificarUsuario(tx, usuarioID, tipo, titulo, mensaje, models.NotifDatosJSON{
+			"salida_id": salidaID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotificarCambioSalidaGuias avisa a los guías asignados que la salida cambió. Los errores solo se registran.
+func NotificarCambioSalidaGuias(db *gorm.DB, salidaID uint, mensaje string) {
+	if err := notificarGuiasSalida(db, salidaID, models.TipoSalidaGuiaActualizada, "Cambios en una salida asignada", mensaje); err != nil {
+		log.Printf("Error notificando guías de la salida %d: %v", salidaID, err)
+	}
+}
+
+// EsGuiaDeSalida indica si el usuario es guía asignado de la salida de la agencia.
+func EsGuiaDeSalida(db *gorm.DB, usuarioID uint, agenciaID uint, salidaID uint) (bool, error) {
+	var count int64
+	err := db.Table("salida_guias sg").
+		Joins("JOIN guias g ON g.id = sg.guia_id").
+		Where("sg.salida_id = ? AND g.agencia_id = ? AND g.usuario_id = ? AND g.activo = TRUE", salidaID, agenciaID, usuarioID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ObtenerGuiasSalida lista los guías asignados a una salida de la agencia.
+func (s *GuiaService) ObtenerGuiasSalida(agenciaID uint, salidaID uint) ([]models.SalidaGuia, error) {
+	var count int64
+	if err := s.db.Model(&models.PaqueteSalidaHabilitada{}).
+		Joins("JOIN paquetes_turisticos ON paquete_salidas_habilitadas.paquete_id = paquetes_turisticos.id").
+		Where("paquete_salidas_habilitadas.id = ? AND paquetes_turisticos.agencia_id = ?", salidaID, agenciaID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("salida no encontrada")
+	}
+
+	asignaciones := []models.SalidaGuia{}
+	if err := s.db.Preload("Guia").
+		Where("salida_id = ?", salidaID).
+		Order("CASE WHEN rol = 'principal' THEN 0 ELSE 1 END").
+		Order("id ASC").
+		Find(&asignaciones).Error; err != nil {
+		return nil, err
+	}
+	return asignaciones, nil
+}
+
+// AsignarGuias reemplaza los guías de una salida. Valida que los guías estén activos, con licencia vigente
+// durante la salida y sin otras salidas superpuestas; sincroniza guia_nombre/guia_telefono con el principal.
+func (s *GuiaService) AsignarGuias(agenciaID uint, salidaID uint, usuarioID uint, req *models.AsignarGuiasSalidaRequest) ([]models.SalidaGuia, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		salida, err := BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil {
+			return err
+		}
+		if salida.Estado != "pendiente" && salida.Estado != "activa" && salida.Estado != "en_curso" {
+			return fmt.Errorf("no se pueden asignar guías a una salida %s", salida.Estado)
+		}
+
+		var paquete models.PaqueteTuristico
+		if err := tx.Select("id", "nombre", "duracion_dias").First(&paquete, salida.PaqueteID).Error; err != nil {
+			return err
+		}
+		inicio, err := time.Parse("2006-01-02", fechaSalidaString(salida.FechaSalida))
+		if err != nil {
+			return err
+		}
+		fin := inicio
+		if paquete.DuracionDias != nil && *paquete.DuracionDias > 1 {
+			fin = inicio.AddDate(0, 0, *paquete.DuracionDias-1)
+		}
+
+		// Normalizar: sin duplicados y exactamente un principal
+		guiaIDs := []uint{}
+		roles := map[uint]string{}
+		principales := 0
+		for _, a := range req.Guias {
+			if _, ok := roles[a.GuiaID]; ok {
+				return fmt.Errorf("el guía %d está repetido", a.GuiaID)
+			}
+			rol := a.Rol
+			if rol == "" {
+				rol = "apoyo"
+			}
+			if rol == "principal" {
+				principales++
+			}
+			roles[a.GuiaID] = rol
+			guiaIDs = append(guiaIDs, a.GuiaID)
+		}
+		if principales > 1 {
+			return errors.New("solo puede haber un guía principal por salida")
+		}
+		if principales == 0 && len(guiaIDs) > 0 {
+			roles[guiaIDs[0]] = "principal"
+		}
+
+		guias := map[uint]models.Guia{}
+		if len(guiaIDs) > 0 {
+			// Bloquear a los guías serializa asignaciones simultáneas a distintas salidas
+			var encontrados []models.Guia
+			if err := tx.Raw(`SELECT * FROM guias WHERE id IN ? AND agencia_id = ? ORDER BY id FOR UPDATE`, guiaIDs, agenciaID).
+				Scan(&encontrados).Error; err != nil {
+				return err
+			}
+			for _, g := range encontrados {
+				guias[g.ID] = g
+			}
+		}
+		for _, id := range guiaIDs {
+			g, ok := guias[id]
+			if !ok {
+				return fmt.Errorf("el guía %d no pertenece a la agencia", id)
+			}
+			if !g.Activo {
+				return fmt.Errorf("el guía %s está inactivo", g.Nombre)
+			}
+			if g.LicenciaVencimiento != nil && g.LicenciaVencimiento.Before(fin) {
+				return fmt.Errorf("la licencia de %s vence el %s, antes del fin de la salida", g.Nombre, g.LicenciaVencimiento.Format("2006-01-02"))
+			}
+		}
+
+		conflictos, err := conflictosGuias(tx, salida.ID, guiaIDs)
+		if err != nil {
+			return err
+		}
+		if len(conflictos) > 0 {
+			return &ConflictoGuiasError{Conflictos: conflictos}
+		}
+
+		var actuales []models.SalidaGuia
+		if err := tx.Where("salida_id = ?", salida.ID).Find(&actuales).Error; err != nil {
+			return err
+		}
+		anteriores := map[uint]models.SalidaGuia{}
+		for _, a := range actuales {
+			anteriores[a.GuiaID] = a
+		}
+
+		fecha := inicio.Format("2006-01-02")
+		for _, a := range actuales {
+			if _, sigue := roles[a.GuiaID]; sigue {
+				continue
+			}
+			if err := tx.Delete(&models.SalidaGuia{}, a.ID).Error; err != nil {
+				return err
+			}
+			if g := guiasPorID(tx, a.GuiaID); g != nil && g.UsuarioID != nil {
+				if _, err := notificarUsuario(tx, *g.UsuarioID, models.TipoGuiaDesasignado,
+					"Ya no estás asignado a una salida",
+					fmt.Sprintf("Fuiste retirado de la salida de \"%s\" del %s", paquete.Nombre, fecha),
+					models.NotifDatosJSON{"salida_id": salida.ID}); err != nil {
+					return err
+				}
+			}
+		}
+
+		var principal *models.Guia
+		for _, id := range guiaIDs {
+			g := guias[id]
+			rol := roles[id]
+			if rol == "principal" {
+				principal = &g
+			}
+			if anterior, ok := anteriores[id]; ok {
+				if anterior.Rol != rol {
+					if err := tx.Model(&models.SalidaGuia{}).Where("id = ?", anterior.ID).Update("rol", rol).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			asignacion := models.SalidaGuia{SalidaID: salida.ID, GuiaID: id, Rol: rol, AsignadoPorID: &usuarioID}
+			if err := tx.Create(&asignacion).Error; err != nil {
+				return err
+			}
+			if g.UsuarioID != nil {
+				if _, err := notificarUsuario(tx, *g.UsuarioID, models.TipoGuiaAsignado,
+					"Nueva salida asignada",
+					fmt.Sprintf("Fuiste asignado como guía %s de \"%s\" el %s", rol, paquete.Nombre, fecha),
+					models.NotifDatosJSON{"salida_id": salida.ID, "guia_id": id}); err != nil {
+					return err
+				}
+			}
+		}
+
+		updates := map[string]interface{}{
+			"guia_nombre":   nil,
+			"guia_telefono": nil,
+			"updated_at":    time.Now(),
+		}
+		if principal != nil {
+			updates["guia_nombre"] = principal.Nombre
+			updates["guia_telefono"] = principal.Telefono
+		}
+		return tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ObtenerGuiasSalida(agenciaID, salidaID)
+}
+
+func guiasPorID(tx *gorm.DB, guiaID uint) *models.Guia {
+	var g models.Guia
+	if err := tx.First(&g, guiaID).Error; err != nil {
+		return nil
+	}
+	return &g
+}
+
+// CalendarioGuia retorna las salidas asignadas a un guía de la agencia en el rango indicado.
+func (s *GuiaService) CalendarioGuia(agenciaID uint, guiaID uint, desde, hasta time.Time) ([]models.CalendarioGuiaItem, error) {
+	return s.calendario("g.id = ? AND g.agencia_id = ?", []interface{}{guiaID, agenciaID}, desde, hasta)
+}
+
+// CalendarioUsuario retorna las salidas asignadas al usuario como guía (en todas sus agencias).
+func (s *GuiaService) CalendarioUsuario(usuarioID uint, desde, hasta time.Time) ([]models.CalendarioGuiaItem, error) {
+	return s.calendario("g.usuario_id = ? AND g.activo = TRUE", []interface{}{usuarioID}, desde, hasta)
+}
+
+func (s *GuiaService) calendario(filtro string, args []interface{}, desde, hasta time.Time) ([]models.CalendarioGuiaItem, error) {
+	args = append(args, hasta.Format("2006-01-02"), desde.Format("2006-01-02"))
+
+	items := []models.CalendarioGuiaItem{}
+	err := s.db.Raw(`
+		SELECT c.*,
+		       to_char(c.inicio, 'YYYY-MM-DD') AS fecha_inicio,
+		       to_char(c.fin, 'YYYY-MM-DD') AS fecha_fin
+		FROM (
+			SELECT s.id AS salida_id, p.agencia_id, g.id AS guia_id, p.id AS paquete_id, p.nombre AS paquete_nombre,`+sqlRangoSalida+`,
+			       s.punto_encuentro, s.hora_encuentro::text AS hora_encuentro, s.tipo_salida, s.estado, sg.rol,
+			       (s.cupos_reservados + s.cupos_confirmados) AS participantes
+			FROM salida_guias sg
+			JOIN guias g ON g.id = sg.guia_id
+			JOIN paquete_salidas_habilitadas s ON s.id = sg.salida_id
+			JOIN paquetes_turisticos p ON p.id = s.paquete_id
+			WHERE `+filtro+`
+			  AND s.estado <> 'cancelada'
+		) c
+		WHERE c.inicio <= ?::date
+		  AND c.fin >= ?::date
+		ORDER BY c.inicio ASC, c.salida_id ASC
+	`, args...).Scan(&items).Error
+	return items, err
+}
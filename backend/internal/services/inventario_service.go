@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCuposInsuficientes indica que la salida no tiene lugar libre para el movimiento pedido.
+	ErrCuposInsuficientes = errors.New("no hay cupos suficientes en la salida")
+	// ErrInconsistenciaCupos indica que se intenta liberar más cupos de los registrados en la salida
+	// (por ejemplo, una compra liberada dos veces).
+	ErrInconsistenciaCupos = errors.New("inconsistencia de cupos")
+)
+
+// InventarioService es el único lugar que mueve los cupos de las salidas: las funciones SQL
+// (reservar_salida_paquete, procesar_compra_paquete) solo eligen o crean la salida y los triggers de
+// pagos no tocan sus contadores. Cada operación bloquea la fila de la salida (SELECT ... FOR UPDATE) y
+// valida el movimiento antes de aplicarlo: un descuento mayor a lo registrado es un error y no se recorta
+// a cero. La restricción chk_salida_cupos de la base de datos respalda las mismas reglas.
+type InventarioService struct {
+	db *gorm.DB
+}
+
+// NewInventarioService recibe la transacción en curso; los bloqueos se mantienen hasta su fin.
+func NewInventarioService(db *gorm.DB) *InventarioService {
+	return &InventarioService{db: db}
+}
+
+// cuposSalida son los contadores de una salida bloqueada.
+type cuposSalida struct {
+	ID               uint
+	CupoMaximo       int
+	CuposReservados  int
+	CuposConfirmados int
+	CuposOfertados   int
+}
+
+// movimientoCupos es la variación a aplicar en cada contador de la salida.
+type movimientoCupos struct {
+	reservados  int
+	confirmados int
+	ofertados   int
+}
+
+func (s *InventarioService) bloquear(salidaID uint) (*cuposSalida, error) {
+	var cupos cuposSalida
+	if err := s.db.Raw(`
+		SELECT id, cupo_maximo, cupos_reservados, cupos_confirmados, COALESCE(cupos_ofertados, 0) AS cupos_ofertados
+		FROM paquete_salidas_habilitadas
+		WHERE id = ?
+		FOR UPDATE
+	`, salidaID).Scan(&cupos).Error; err != nil {
+		return nil, err
+	}
+	if cupos.ID == 0 {
+		return nil, fmt.Errorf("salida %d no encontrada", salidaID)
+	}
+	return &cupos, nil
+}
+
+// aplicarMovimiento valida el movimiento sobre los contadores de la salida y retorna los contadores resultantes.
+func aplicarMovimiento(cupos cuposSalida, mov movimientoCupos) (cuposSalida, error) {
+	nuevos := cupos
+	nuevos.CuposReservados += mov.reservados
+	nuevos.CuposConfirmados += mov.confirmados
+	nuevos.CuposOfertados += mov.ofertados
+	if nuevos.CuposReservados < 0 || nuevos.CuposConfirmados < 0 || nuevos.CuposOfertados < 0 {
+		return cupos, fmt.Errorf("%w en la salida %d: hay %d reservados, %d confirmados y %d ofertados; movimiento %+d/%+d/%+d",
+			ErrInconsistenciaCupos, cupos.ID, cupos.CuposReservados, cupos.CuposConfirmados, cupos.CuposOfertados,
+			mov.reservados, mov.confirmados, mov.ofertados)
+	}
+	// Solo los movimientos que agregan ocupación necesitan lugar libre
+	ocupados := nuevos.CuposReservados + nuevos.CuposConfirmados + nuevos.CuposOfertados
+	if mov.reservados+mov.confirmados+mov.ofertados > 0 && ocupados > cupos.CupoMaximo {
+		return cupos, ErrCuposInsuficientes
+	}
+	return nuevos, nil
+}
+
+func (s *InventarioService) mover(salidaID uint, mov movimientoCupos) error {
+	cupos, err := s.bloquear(salidaID)
+	if err != nil {
+		return err
+	}
+	nuevos, err := aplicarMovimiento(*cupos, mov)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Exec(`
+		UPDATE paquete_salidas_habilitadas
+		SET cupos_reservados = ?,
+		    cupos_confirmados = ?,
+		    cupos_ofertados = ?,
+		    updated_at = NOW()
+		WHERE id = ?
+	`, nuevos.CuposReservados, nuevos.CuposConfirmados, nuevos.CuposOfertados, salidaID).Error
+}
+
+// Reservar ocupa cupos libres de una salida ya elegida (reservar_salida_paquete elige o crea la salida por fecha).
+func (s *InventarioService) Reservar(salidaID uint, cantidad int) error {
+	return s.mover(salidaID, movimientoCupos{reservados: cantidad})
+}
+
+// LiberarReservados descuenta cupos reservados de una compra que no llegó a pagarse por completo.
+func (s *InventarioService) LiberarReservados(salidaID uint, cantidad int) error {
+	return s.mover(salidaID, movimientoCupos{reservados: -cantidad})
+}
+
+// ConfirmarReservados pasa cupos de reservados a confirmados cuando la compra queda pagada.
+func (s *InventarioService) ConfirmarReservados(salidaID uint, cantidad int) error {
+	return s.mover(salidaID, movimientoCupos{reservados: -cantidad, confirmados: cantidad})
+}
+
+// Ofertar retiene cupos libres para las ofertas de la lista de espera.
+func (s *InventarioService) Ofertar(salidaID uint, cantidad int) error {
+	return s.mover(salidaID, movimientoCupos{ofertados: cantidad})
+}
+
+// LiberarOferta devuelve a la salida los cupos retenidos por una oferta de la lista de espera.
+func (s *InventarioService) LiberarOferta(salidaID uint, cantidad int) error {
+	return s.mover(salidaID, movimientoCupos{ofertados: -cantidad})
+}
+
+// LiberarCompra descuenta los cupos de la compra de su salida según el estado en que estaban.
+func (s *InventarioService) LiberarCompra(compra *models.CompraPaquete) error {
+	if compra.SalidaID == nil {
+		return nil
+	}
+	if compra.Status == "confirmada" {
+		return s.mover(*compra.SalidaID, movimientoCupos{confirmados: -compra.TotalParticipantes})
+	}
+	return s.mover(*compra.SalidaID, movimientoCupos{reservados: -compra.TotalParticipantes})
+}
+
+// AjustarCupoMaximo cambia el cupo máximo de la salida sin dejarlo por debajo de los cupos ocupados.
+func (s *InventarioService) AjustarCupoMaximo(salidaID uint, cupoMaximo int) error {
+	cupos, err := s.bloquear(salidaID)
+	if err != nil {
+		return err
+	}
+	if ocupados := cupos.CuposReservados + cupos.CuposConfirmados + cupos.CuposOfertados; cupoMaximo < ocupados {
+		return fmt.Errorf("no se puede reducir el cupo máximo por debajo de los %d cupos ocupados", ocupados)
+	}
+
+	return s.db.Exec(`
+		UPDATE paquete_salidas_habilitadas
+		SET cupo_maximo = ?,
+		    updated_at = NOW()
+		WHERE id = ?
+	`, cupoMaximo, salidaID).Error
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAplicarMovimiento(t *testing.T) {
+	salida := cuposSalida{ID: 1, CupoMaximo: 10, CuposReservados: 4, CuposConfirmados: 3, CuposOfertados: 1}
+	casos := []struct {
+		nombre string
+		cupos  cuposSalida
+		mov    movimientoCupos
+		want   cuposSalida
+		err    error
+	}{
+		{"reservar con lugar", salida, movimientoCupos{reservados: 2},
+			cuposSalida{ID: 1, CupoMaximo: 10, CuposReservados: 6, CuposConfirmados: 3, CuposOfertados: 1}, nil},
+		{"reservar sin lugar", salida, movimientoCupos{reservados: 3}, salida, ErrCuposInsuficientes},
+		{"liberar reservados", salida, movimientoCupos{reservados: -4},
+			cuposSalida{ID: 1, CupoMaximo: 10, CuposReservados: 0, CuposConfirmados: 3, CuposOfertados: 1}, nil},
+		{"liberar más reservados de los registrados", salida, movimientoCupos{reservados: -5}, salida, ErrInconsistenciaCupos},
+		{"confirmar reservados", salida, movimientoCupos{reservados: -4, confirmados: 4},
+			cuposSalida{ID: 1, CupoMaximo: 10, CuposReservados: 0, CuposConfirmados: 7, CuposOfertados: 1}, nil},
+		{"confirmar más de lo reservado", salida, movimientoCupos{reservados: -5, confirmados: 5}, salida, ErrInconsistenciaCupos},
+		{"liberar confirmados", salida, movimientoCupos{confirmados: -3},
+			cuposSalida{ID: 1, CupoMaximo: 10, CuposReservados: 4, CuposConfirmados: 0, CuposOfertados: 1}, nil},
+		{"liberar más confirmados de los registrados", salida, movimientoCupos{confirmados: -4}, salida, ErrInconsistenciaCupos},
+		{"ofertar con lugar", salida, movimientoCupos{ofertados: 2},
+			cuposSalida{ID: 1, CupoMaximo: 10, CuposReservados: 4, CuposConfirmados: 3, CuposOfertados: 3}, nil},
+		{"ofertar sin lugar", salida, movimientoCupos{ofertados: 3}, salida, ErrCuposInsuficientes},
+		{"liberar oferta", salida, movimientoCupos{ofertados: -1},
+			cuposSalida{ID: 1, CupoMaximo: 10, CuposReservados: 4, CuposConfirmados: 3, CuposOfertados: 0}, nil},
+		{"liberar oferta dos veces", salida, movimientoCupos{ofertados: -2}, salida, ErrInconsistenciaCupos},
+		{"liberar en una salida sobrevendida", cuposSalida{ID: 2, CupoMaximo: 5, CuposReservados: 6}, movimientoCupos{reservados: -1},
+			cuposSalida{ID: 2, CupoMaximo: 5, CuposReservados: 5}, nil},
+		{"confirmar en una salida llena", cuposSalida{ID: 3, CupoMaximo: 5, CuposReservados: 5}, movimientoCupos{reservados: -5, confirmados: 5},
+			cuposSalida{ID: 3, CupoMaximo: 5, CuposConfirmados: 5}, nil},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			got, err := aplicarMovimiento(c.cupos, c.mov)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("error = %v, se esperaba %v", err, c.err)
+			}
+			if got != c.want {
+				t.Errorf("contadores = %+v, se esperaban %+v", got, c.want)
+			}
+		})
+	}
+}
+
+// Pruebas de concurrencia del inventario de cupos contra PostgreSQL real. Se omiten si TEST_DATABASE_URL no
+// está definido; la base indicada se migra y recibe el bootstrap SQL, y los datos creados se eliminan al final.
+//
+//	TEST_DATABASE_URL="host=localhost user=postgres password=postgres dbname=andaria_test sslmode=disable" go test ./internal/services -run Concurrencia
+
+const reservasConcurrentes = 200
+
+func abrirBaseDePrueba(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL no definido; se omite la prueba contra PostgreSQL")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatalf("conectando a la base de prueba: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("obteniendo el pool: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(40)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("migrando la base de prueba: %v", err)
+	}
+	if err := database.ApplySQLBootstrap(db); err != nil {
+		t.Fatalf("bootstrap SQL: %v", err)
+	}
+	return db
+}
+
+type fixtureInventario struct {
+	turista models.Usuario
+	agencia models.AgenciaTurismo
+	paquete models.PaqueteTuristico
+	fecha   time.Time
+}
+
+// crearFixtureInventario registra un turista, una agencia activa y un paquete de salida diaria publicado.
+func crearFixtureInventario(t *testing.T, db *gorm.DB, cupoMaximo int) *fixtureInventario {
+	t.Helper()
+	sufijo := fmt.Sprintf("%d", time.Now().UnixNano())
+	f := &fixtureInventario{fecha: fechaHoyUTC().AddDate(0, 0, 10)}
+
+	f.turista = models.Usuario{
+		Nombre:          "Prueba",
+		ApellidoPaterno: "Concurrencia",
+		ApellidoMaterno: "Cupos",
+		FechaNacimiento: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		CI:              "CI-" + sufijo,
+		Email:           "turista-" + sufijo + "@prueba.test",
+		PasswordHash:    "-",
+		Rol:             "turista",
+	}
+	if err := db.Create(&f.turista).Error; err != nil {
+		t.Fatalf("creando turista: %v", err)
+	}
+
+	f.agencia = models.AgenciaTurismo{
+		NombreComercial: "Agencia prueba " + sufijo,
+		Slug:            "agencia-prueba-" + sufijo,
+		Direccion:       "-",
+		DepartamentoID:  1,
+		Telefono:        "70000000",
+		Email:           "agencia-" + sufijo + "@prueba.test",
+		Status:          "activa",
+		VisiblePublico:  true,
+		CreatedBy:       f.turista.ID,
+	}
+	if err := db.Create(&f.agencia).Error; err != nil {
+		t.Fatalf("creando agencia: %v", err)
+	}
+
+	duracion := 1
+	f.paquete = models.PaqueteTuristico{
+		AgenciaID:            f.agencia.ID,
+		Nombre:               "Paquete prueba " + sufijo,
+		Frecuencia:           "salida_diaria",
+		DuracionDias:         &duracion,
+		DiasPreviosCompra:    1,
+		CupoMinimo:           1,
+		CupoMaximo:           cupoMaximo,
+		PrecioBaseNacionales: 100,
+		Status:               "activo",
+		VisiblePublico:       true,
+	}
+	if err := db.Create(&f.paquete).Error; err != nil {
+		t.Fatalf("creando paquete: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM compras_paquetes WHERE paquete_id = ?`, f.paquete.ID)
+		db.Exec(`DELETE FROM paquete_salidas_habilitadas WHERE paquete_id = ?`, f.paquete.ID)
+		db.Exec(`DELETE FROM paquetes_turisticos WHERE id = ?`, f.paquete.ID)
+		db.Exec(`DELETE FROM agencias_turismo WHERE id = ?`, f.agencia.ID)
+		db.Exec(`DELETE FROM usuarios WHERE id = ?`, f.turista.ID)
+	})
+	return f
+}
+
+func (f *fixtureInventario) crearSalida(t *testing.T, db *gorm.DB, cupoMaximo, reservados, confirmados int) uint {
+	t.Helper()
+	salida := models.PaqueteSalidaHabilitada{
+		PaqueteID:        f.paquete.ID,
+		FechaSalida:      f.fecha.Format("2006-01-02"),
+		TipoSalida:       "compartido",
+		CupoMinimo:       1,
+		CupoMaximo:       cupoMaximo,
+		CuposReservados:  reservados,
+		CuposConfirmados: confirmados,
+		Estado:           "activa",
+	}
+	if err := db.Create(&salida).Error; err != nil {
+		t.Fatalf("creando salida: %v", err)
+	}
+	return salida.ID
+}
+
+// verificarCuposSalidas comprueba que ninguna salida del paquete supera su cupo ni tiene contadores
+// negativos, y que chk_salida_cupos está validada.
+func verificarCuposSalidas(t *testing.T, db *gorm.DB, paqueteID uint) []cuposSalida {
+	t.Helper()
+	var salidas []cuposSalida
+	if err := db.Raw(`
+		SELECT id, cupo_maximo, cupos_reservados, cupos_confirmados, COALESCE(cupos_ofertados, 0) AS cupos_ofertados
+		FROM paquete_salidas_habilitadas
+		WHERE paquete_id = ?
+		ORDER BY id
+	`, paqueteID).Scan(&salidas).Error; err != nil {
+		t.Fatalf("leyendo salidas: %v", err)
+	}
+	for _, s := range salidas {
+		if s.CuposReservados < 0 || s.CuposConfirmados < 0 || s.CuposOfertados < 0 {
+			t.Errorf("salida %d con contadores negativos: %+v", s.ID, s)
+		}
+		if ocupados := s.CuposReservados + s.CuposConfirmados + s.CuposOfertados; ocupados > s.CupoMaximo {
+			t.Errorf("salida %d sobrevendida: %d ocupados de %d", s.ID, ocupados, s.CupoMaximo)
+		}
+	}
+
+	var validada bool
+	if err := db.Raw(`SELECT convalidated FROM pg_constraint WHERE conname = 'chk_salida_cupos'`).Scan(&validada).Error; err != nil {
+		t.Fatalf("leyendo chk_salida_cupos: %v", err)
+	}
+	if !validada {
+		t.Errorf("chk_salida_cupos no existe o no está validada")
+	}
+	return salidas
+}
+
+// correrConcurrente ejecuta fn en n goroutines que arrancan a la vez y retorna sus errores.
+func correrConcurrente(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	var listo sync.WaitGroup
+	var fin sync.WaitGroup
+	inicio := make(chan struct{})
+	listo.Add(n)
+	fin.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer fin.Done()
+			listo.Done()
+			<-inicio
+			errs[i] = fn(i)
+		}(i)
+	}
+	listo.Wait()
+	close(inicio)
+	fin.Wait()
+	return errs
+}
+
+func TestInventarioConcurrenciaUltimosCupos(t *testing.T) {
+	db := abrirBaseDePrueba(t)
+	f := crearFixtureInventario(t, db, 10)
+	salidaID := f.crearSalida(t, db, 10, 6, 2)
+
+	// Quedan 2 cupos: de las reservas simultáneas solo 2 pueden entrar
+	errs := correrConcurrente(reservasConcurrentes, func(int) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			return NewInventarioService(tx).mover(salidaID, movimientoCupos{reservados: 1})
+		})
+	})
+
+	exitosas := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			exitosas++
+		case !errors.Is(err, ErrCuposInsuficientes):
+			t.Errorf("error inesperado: %v", err)
+		}
+	}
+	if exitosas != 2 {
+		t.Errorf("se aceptaron %d reservas, se esperaban 2", exitosas)
+	}
+
+	salidas := verificarCuposSalidas(t, db, f.paquete.ID)
+	if len(salidas) != 1 || salidas[0].CuposReservados != 8 || salidas[0].CuposConfirmados != 2 {
+		t.Errorf("contadores finales inesperados: %+v", salidas)
+	}
+}
+
+func TestInventarioConcurrenciaMovimientosMixtos(t *testing.T) {
+	db := abrirBaseDePrueba(t)
+	f := crearFixtureInventario(t, db, 20)
+	salidaID := f.crearSalida(t, db, 20, 10, 0)
+
+	// Confirmaciones, liberaciones, ofertas y reservas nuevas compitiendo por la misma salida
+	errs := correrConcurrente(reservasConcurrentes, func(i int) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			inventario := NewInventarioService(tx)
+			switch i % 4 {
+			case 0:
+				return inventario.ConfirmarReservados(salidaID, 1)
+			case 1:
+				return inventario.LiberarReservados(salidaID, 1)
+			case 2:
+				return inventario.Ofertar(salidaID, 1)
+			default:
+				return inventario.mover(salidaID, movimientoCupos{reservados: 1})
+			}
+		})
+	})
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrCuposInsuficientes) && !errors.Is(err, ErrInconsistenciaCupos) {
+			t.Errorf("error inesperado: %v", err)
+		}
+	}
+
+	verificarCuposSalidas(t, db, f.paquete.ID)
+}
+
+func TestProcesarCompraPaqueteConcurrenciaUltimosCupos(t *testing.T) {
+	db := abrirBaseDePrueba(t)
+	f := crearFixtureInventario(t, db, 10)
+	f.crearSalida(t, db, 10, 5, 2)
+
+	type resultado struct {
+		CompraID uint   `gorm:"column:compra_id"`
+		SalidaID uint   `gorm:"column:salida_id"`
+		Mensaje  string `gorm:"column:mensaje"`
+		Success  bool   `gorm:"column:success"`
+	}
+	errs := correrConcurrente(reservasConcurrentes, func(int) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			var r resultado
+			if err := tx.Raw(
+				`SELECT * FROM public.procesar_compra_paquete(?::int, ?::int, ?::date, ?::text, ?::boolean, ?::int, ?::int, ?::int, ?::boolean, ?::text, ?::text)`,
+				f.turista.ID, f.paquete.ID, f.fecha.Format("2006-01-02"), "compartido", false, 1, 0, 0, false, nil, nil,
+			).Scan(&r).Error; err != nil {
+				return err
+			}
+			if !r.Success {
+				return errors.New(r.Mensaje)
+			}
+			// Igual que CompraService: la función elige la salida y los cupos se reservan en Go
+			return NewInventarioService(tx).Reservar(r.SalidaID, 1)
+		})
+	})
+	for _, err := range errs {
+		if errors.Is(err, ErrCuposInsuficientes) {
+			continue
+		}
+		// Sin lugar en la salida existente se abren salidas nuevas hasta el máximo diario de la agencia
+		if err != nil && err.Error() != "La agencia alcanzó su máximo de salidas para ese día" &&
+			err.Error() != "La agencia alcanzó su máximo de salidas simultáneas para ese horario" {
+			t.Errorf("error inesperado: %v", err)
+		}
+	}
+
+	salidas := verificarCuposSalidas(t, db, f.paquete.ID)
+
+	// Cada cupo reservado corresponde a una compra registrada (sin actualizaciones perdidas)
+	for _, s := range salidas {
+		var participantes int
+		if err := db.Raw(`SELECT COALESCE(SUM(total_participantes), 0) FROM compras_paquetes WHERE salida_id = ?`, s.ID).
+			Scan(&participantes).Error; err != nil {
+			t.Fatalf("sumando compras: %v", err)
+		}
+		previos := 0
+		if s.ID == salidas[0].ID {
+			previos = 5
+		}
+		if s.CuposReservados != participantes+previos {
+			t.Errorf("salida %d: %d cupos reservados para %d participantes de compras", s.ID, s.CuposReservados, participantes+previos)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Tiempo que tiene un turista para convertir una oferta de la lista de espera en compra.
+const duracionOfertaListaEspera = 12 * time.Hour
+
+type ListaEsperaService struct {
+	db *gorm.DB
+}
+
+func NewListaEsperaService(db *gorm.DB) *ListaEsperaService {
+	return &ListaEsperaService{db: db}
+}
+
+func fechaSalidaString(fecha string) string {
+	value := strings.TrimSpace(fecha)
+	if len(value) > 10 {
+		value = value[:10]
+	}
+	return value
+}
+
+// ofrecerCuposListaEspera ofrece los cupos libres de una salida a las entradas en espera, en orden de
+// llegada. Se salta las entradas que no entran en los cupos libres y retiene los cupos ofertados.
+func ofrecerCuposListaEspera(tx *gorm.DB, salidaID uint) error {
+	var salida models.PaqueteSalidaHabilitada
+	if err := tx.Raw(`SELECT * FROM paquete_salidas_habilitadas WHERE id = ? FOR UPDATE`, salidaID).Scan(&salida).Error; err != nil {
+		return err
+	}
+	if salida.ID == 0 {
+		return nil
+	}
+	if salida.Estado != "pendiente" && salida.Estado != "activa" {
+		return nil
+	}
+	fecha := fechaSalidaString(salida.FechaSalida)
+	if fecha < fechaHoyUTC().Format("2006-01-02") {
+		return nil
+	}
+
+	disponibles := salida.CuposDisponibles()
+	if disponibles <= 0 {
+		return nil
+	}
+
+	var entradas []models.ListaEsperaSalida
+	if err := tx.Raw(`
+		SELECT *
+		FROM lista_espera_salidas
+		WHERE salida_id = ? AND estado = 'esperando'
+		ORDER BY created_at ASC, id ASC
+		FOR UPDATE
+	`, salidaID).Scan(&entradas).Error; err != nil {
+		return err
+	}
+	if len(entradas) == 0 {
+		return nil
+	}
+
+	var paquete models.PaqueteTuristico
+	if err := tx.Select("id", "nombre").First(&paquete, salida.PaqueteID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expira := now.Add(duracionOfertaListaEspera)
+	ofertados := 0
+
+	for _, entrada := range entradas {
+		if entrada.TotalParticipantes > disponibles {
+			continue
+		}
+
+		if err := tx.Model(&models.ListaEsperaSalida{}).
+			Where("id = ?", entrada.ID).
+			Updates(map[string]interface{}{
+				"estado":           "ofertada",
+				"fecha_oferta":     now,
+				"oferta_expira_en": expira,
+				"updated_at":       now,
+			}).Error; err != nil {
+			return err
+		}
+
+		if _, err := notificarUsuario(tx, entrada.TuristaID, models.TipoOfertaListaEspera,
+			"¡Se liberaron cupos para tu salida!",
+			fmt.Sprintf("Hay %d cupos disponibles para \"%s\" el %s. Confirma tu compra antes del %s",
+				entrada.TotalParticipantes, paquete.Nombre, fecha, expira.Format("02/01/2006 15:04")),
+			models.NotifDatosJSON{
+				"lista_espera_id":     entrada.ID,
+				"salida_id":           salida.ID,
+				"paquete_id":          salida.PaqueteID,
+				"paquete_nombre":      paquete.Nombre,
+				"fecha_salida":        fecha,
+				"total_participantes": entrada.TotalParticipantes,
+				"oferta_expira_en":    expira,
+			}); err != nil {
+			return err
+		}
+
+		disponibles -= entrada.TotalParticipantes
+		ofertados += entrada.TotalParticipantes
+		if disponibles <= 0 {
+			break
+		}
+	}
+
+	if ofertados == 0 {
+		return nil
+	}
+
+	return NewInventarioService(tx).Ofertar(salidaID, ofertados)
+}
+
+// liberarOfertaListaEspera cierra una oferta (con el estado indicado) y devuelve sus cupos retenidos a la salida.
+func liberarOfertaListaEspera(tx *gorm.DB, entrada *models.ListaEsperaSalida, estado string) error {
+	if err := tx.Model(&models.ListaEsperaSalida{}).
+		Where("id = ?", entrada.ID).
+		Updates(map[string]interface{}{
+			"estado":     estado,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+		return err
+	}
+
+	return NewInventarioService(tx).LiberarOferta(entrada.SalidaID, entrada.TotalParticipantes)
+}
+
+// tomarOfertaListaEspera valida que la oferta siga vigente y libera sus cupos retenidos para que
+// la compra los reserve dentro de la misma transacción.
+func tomarOfertaListaEspera(tx *gorm.DB, entradaID uint, turistaID uint) (*models.ListaEsperaSalida, error) {
+	var entrada models.ListaEsperaSalida
+	if err := tx.Raw(`SELECT * FROM lista_espera_salidas WHERE id = ? AND turista_id = ? FOR UPDATE`, entradaID, turistaID).
+		Scan(&entrada).Error; err != nil {
+		return nil, err
+	}
+	if entrada.ID == 0 {
+		return nil, errors.New("entrada de lista de espera no encontrada")
+	}
+	if entrada.Estado != "ofertada" {
+		return nil, errors.New("no tiene una oferta vigente para esta salida")
+	}
+	if entrada.OfertaExpiraEn != nil && entrada.OfertaExpiraEn.Before(time.Now()) {
+		return nil, errors.New("la oferta ya expiró")
+	}
+
+	if err := NewInventarioService(tx).LiberarOferta(entrada.SalidaID, entrada.TotalParticipantes); err != nil {
+		return nil, err
+	}
+
+	return &entrada, nil
+}
+
+// Unirse inscribe al turista en la lista de espera de una salida compartida sin cupos suficientes.
+func (s *ListaEsperaService) Unirse(turistaID uint, salidaID uint, req *models.UnirseListaEsperaRequest) (*models.ListaEsperaSalida, error) {
+	var salida models.PaqueteSalidaHabilitada
+	if err := s.db.First(&salida, salidaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("salida no encontrada")
+		}
+		return nil, err
+	}
+
+	if salida.TipoSalida != "compartido" {
+		return nil, errors.New("solo las salidas compartidas tienen lista de espera")
+	}
+	if salida.Estado != "pendiente" && salida.Estado != "activa" {
+		return nil, errors.New("la salida no está disponible")
+	}
+	if fechaSalidaString(salida.FechaSalida) < fechaHoyUTC().Format("2006-01-02") {
+		return nil, errors.New("la salida ya se realizó")
+	}
+
+	total := req.CantidadAdultos + req.CantidadNinosPagan + req.CantidadNinosGratis
+	if total > salida.CupoMaximo {
+		return nil, errors.New("la cantidad de participantes excede el cupo máximo de la salida")
+	}
+	if salida.CuposDisponibles() >= total {
+		return nil, errors.New("la salida tiene cupos disponibles, puede realizar la compra directamente")
+	}
+
+	var existentes int64
+	if err := s.db.Model(&models.ListaEsperaSalida{}).
+		Where("salida_id = ? AND turista_id = ? AND estado IN ?", salidaID, turistaID, []string{"esperando", "ofertada"}).
+		Count(&existentes).Error; err != nil {
+		return nil, err
+	}
+	if existentes > 0 {
+		return nil, errors.New("ya está en la lista de espera de esta salida")
+	}
+
+	entrada := models.ListaEsperaSalida{
+		SalidaID:            salida.ID,
+		PaqueteID:           salida.PaqueteID,
+		TuristaID:           turistaID,
+		CantidadAdultos:     req.CantidadAdultos,
+		CantidadNinosPagan:  req.CantidadNinosPagan,
+		CantidadNinosGratis: req.CantidadNinosGratis,
+		TotalParticipantes:  total,
+		Extranjero:          req.Extranjero,
+		Notas:               req.Notas,
+		Estado:              "esperando",
+	}
+	if err := s.db.Create(&entrada).Error; err != nil {
+		return nil, err
+	}
+
+	return &entrada, nil
+}
+
+// ListarTurista retorna las entradas de lista de espera del turista.
+func (s *ListaEsperaService) ListarTurista(turistaID uint) ([]models.ListaEsperaSalida, error) {
+	var entradas []models.ListaEsperaSalida
+	if err := s.db.
+		Preload("Salida").
+		Preload("Paquete").
+		Where("turista_id = ?", turistaID).
+		Order("created_at DESC").
+		Find(&entradas).Error; err != nil {
+		return nil, err
+	}
+	return entradas, nil
+}
+
+// Salir retira al turista de la lista de espera; si tenía una oferta, los cupos pasan al siguiente.
+func (s *ListaEsperaService) Salir(turistaID uint, entradaID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var entrada models.ListaEsperaSalida
+		if err := tx.Raw(`SELECT * FROM lista_espera_salidas WHERE id = ? AND turista_id = ? FOR UPDATE`, entradaID, turistaID).
+			Scan(&entrada).Error; err != nil {
+			return err
+		}
+		if entrada.ID == 0 {
+			return errors.New("entrada de lista de espera no encontrada")
+		}
+
+		switch entrada.Estado {
+		case "esperando":
+			return tx.Model(&entrada).Updates(map[string]interface{}{
+				"estado":     "cancelada",
+				"updated_at": time.Now(),
+			}).Error
+		case "ofertada":
+			if err := liberarOfertaListaEspera(tx, &entrada, "cancelada"); err != nil {
+				return err
+			}
+			return ofrecerCuposListaEspera(tx, entrada.SalidaID)
+		default:
+			return fmt.Errorf("la entrada ya no está activa (estado: %s)", entrada.Estado)
+		}
+	})
+}
+
+// ListarSalidaAgencia retorna la lista de espera de una salida de la agencia.
+func (s *ListaEsperaService) ListarSalidaAgencia(agenciaID uint, salidaID uint) ([]models.ListaEsperaSalida, error) {
+	var entradas []models.ListaEsperaSalida
+	if err := s.db.
+		Joins("JOIN paquetes_turisticos p ON p.id = lista_espera_salidas.paquete_id").
+		Where("lista_espera_salidas.salida_id = ? AND p.agencia_id = ?", salidaID, agenciaID).
+		Order("lista_espera_salidas.created_at ASC").
+		Order("lista_espera_salidas.id ASC").
+		Find(&entradas).Error; err != nil {
+		return nil, err
+	}
+	return entradas, nil
+}
+
+// ExpirarOfertas vence las ofertas no aceptadas a tiempo y pasa sus cupos a la siguiente entrada.
+func (s *ListaEsperaService) ExpirarOfertas() (int64, error) {
+	var vencidas []models.ListaEsperaSalida
+	if err := s.db.
+		Where("estado = ? AND oferta_expira_en < ?", "ofertada", time.Now()).
+		Find(&vencidas).Error; err != nil {
+		return 0, fmt.Errorf("error buscando ofertas vencidas: %w", err)
+	}
+
+	var expiradas int64
+	for _, v := range vencidas {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var entrada models.ListaEsperaSalida
+			if err := tx.Raw(`SELECT * FROM lista_espera_salidas WHERE id = ? FOR UPDATE`, v.ID).Scan(&entrada).Error; err != nil {
+				return err
+			}
+			if entrada.Estado != "ofertada" {
+				return nil
+			}
+
+			if err := liberarOfertaListaEspera(tx, &entrada, "expirada"); err != nil {
+				return err
+			}
+
+			if _, err := notificarUsuario(tx, entrada.TuristaID, models.TipoOfertaExpirada,
+				"Tu oferta de cupos expiró",
+				"No confirmaste la compra a tiempo y los cupos se ofrecieron a la siguiente persona en la lista de espera",
+				models.NotifDatosJSON{
+					"lista_espera_id": entrada.ID,
+					"salida_id":       entrada.SalidaID,
+					"paquete_id":      entrada.PaqueteID,
+				}); err != nil {
+				return err
+			}
+
+			if err := ofrecerCuposListaEspera(tx, entrada.SalidaID); err != nil {
+				return err
+			}
+			return cancelSalidaIfEmpty(tx, entrada.SalidaID, "Salida cancelada por falta de participantes")
+		})
+		if err != nil {
+			log.Printf("Error expirando oferta de lista de espera %d: %v", v.ID, err)
+			continue
+		}
+		expiradas++
+	}
+
+	return expiradas, nil
+}
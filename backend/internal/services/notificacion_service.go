@@ -123,3 +123,41 @@ func (s *NotificacionService) EliminarAntiguas(diasAntiguedad int) (int64, error
 
 	return result.RowsAffected, result.Error
 }
+
+// notificarUsuario crea una notificación dentro de la transacción y la publica vía PostgreSQL NOTIFY.
+func notificarUsuario(tx *gorm.DB, usuarioID uint, tipo, titulo, mensaje string, datos models.NotifDatosJSON) (*models.Notificacion, error) {
+	notif := models.Notificacion{
+		UsuarioID: usuarioID,
+		Tipo:      tipo,
+		Titulo:    titulo,
+		Mensaje:   mensaje,
+		DatosJSON: datos,
+	}
+
+	if err := tx.Create(&notif).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Exec("SELECT pg_notify('notificaciones', ?)", fmt.Sprintf(`{"usuario_id":%d,"notificacion_id":%d}`, usuarioID, notif.ID)).Error; err != nil {
+		return nil, err
+	}
+
+	return &notif, nil
+}
+
+// encargadoPrincipalDePaquete retorna el encargado principal de la agencia dueña del paquete (0 si no tiene).
+func encargadoPrincipalDePaquete(tx *gorm.DB, paqueteID uint) (uint, error) {
+	var encargadoID *uint
+	if err := tx.Raw(`
+		SELECT a.encargado_principal_id
+		FROM paquetes_turisticos p
+		JOIN agencias_turismo a ON a.id = p.agencia_id
+		WHERE p.id = ?
+	`, paqueteID).Scan(&encargadoID).Error; err != nil {
+		return 0, err
+	}
+	if encargadoID == nil {
+		return 0, nil
+	}
+	return *encargadoID, nil
+}
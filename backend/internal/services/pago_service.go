@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
+	"log"
 	"mime/multipart"
 	"os"
 	"path/filepath"
@@ -33,12 +33,12 @@ func (s *PagoService) CrearPago(turistaID uint, req *models.CrearPagoRequest) (*
 		return nil, err
 	}
 
-	if compra.Status != "pendiente_confirmacion" {
-		return nil, errors.New("la compra no está pendiente de confirmación")
+	if compra.Status != "pendiente_confirmacion" && compra.Status != "reservada_con_anticipo" {
+		return nil, errors.New("la compra no está pendiente de pago")
 	}
 
-	if math.Abs(req.Monto-compra.PrecioTotal) > 0.01 {
-		return nil, fmt.Errorf("el monto debe ser %.2f Bs", compra.PrecioTotal)
+	if err := validarMontoPago(&compra, req.Monto); err != nil {
+		return nil, err
 	}
 
 	// Evitar múltiples pagos pendientes para la misma compra (previene inconsistencias con triggers).
@@ -54,24 +54,35 @@ func (s *PagoService) CrearPago(turistaID uint, req *models.CrearPagoRequest) (*
 		return nil, errors.New("debe adjuntar comprobante para pagos QR o transferencia")
 	}
 
-	var comprobantePath *string
+	pago := models.PagoCompra{
+		CompraID:   req.CompraID,
+		MetodoPago: req.MetodoPago,
+		Monto:      req.Monto,
+		Estado:     "pendiente",
+	}
+
 	if req.Comprobante != nil {
 		path, err := saveComprobante(req.Comprobante, req.CompraID)
 		if err != nil {
 			return nil, err
 		}
-		comprobantePath = &path
-	}
-
-	pago := models.PagoCompra{
-		CompraID:        req.CompraID,
-		MetodoPago:      req.MetodoPago,
-		Monto:           req.Monto,
-		ComprobanteFoto: comprobantePath,
-		Estado:          "pendiente",
+		sha, phash, err := huellaComprobante(path)
+		if err != nil {
+			return nil, err
+		}
+		pago.ComprobanteFoto = &path
+		pago.ComprobanteSHA256 = &sha
+		pago.ComprobantePHash = phash
 	}
 
-	if err := s.db.Create(&pago).Error; err != nil {
+	// El comprobante se compara con los de compras anteriores; una coincidencia no impide el pago, pero
+	// queda marcada para que el encargado la vea antes de confirmarlo.
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&pago).Error; err != nil {
+			return err
+		}
+		return registrarCoincidenciasComprobante(tx, &pago)
+	}); err != nil {
 		return nil, err
 	}
 
@@ -82,6 +93,7 @@ func (s *PagoService) CrearPago(turistaID uint, req *models.CrearPagoRequest) (*
 		Monto:           pago.Monto,
 		Estado:          pago.Estado,
 		ComprobanteFoto: pago.ComprobanteFoto,
+		SaldoPendiente:  compra.SaldoPendiente(),
 		Mensaje:         "Pago registrado. Esperando confirmación del encargado.",
 	}, nil
 }
@@ -99,7 +111,59 @@ func (s *PagoService) ObtenerPagoConContexto(pagoID uint) (*models.PagoCompra, e
 	return &pago, nil
 }
 
+// errPagoYaProcesado indica que el pago no existe o ya no está pendiente.
+var errPagoYaProcesado = errors.New("pago no encontrado o ya fue procesado")
+
+// validarPagoManual impide que el encargado resuelva a mano un pago en línea pendiente.
+func (s *PagoService) validarPagoManual(pagoID uint) error {
+	var pago models.PagoCompra
+	if err := s.db.Select("id", "metodo_pago").First(&pago, pagoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errPagoYaProcesado
+		}
+		return err
+	}
+	if pago.MetodoPago == "pasarela" {
+		return errors.New("los pagos en línea los confirma o rechaza la pasarela")
+	}
+	return nil
+}
+
 func (s *PagoService) ConfirmarPago(pagoID uint, confirmadoPor uint, notas *string) error {
+	if err := s.validarPagoManual(pagoID); err != nil {
+		return err
+	}
+	var compraID uint
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		compraID, err = NewPagoService(tx).confirmarPago(pagoID, &confirmadoPor, notas)
+		return err
+	}); err != nil {
+		return err
+	}
+	enviarVoucherConfirmacion(s.db, compraID)
+	return nil
+}
+
+// confirmarPago es el camino común de confirmación, tanto del encargado como de una pasarela en línea
+// (confirmadoPor nil): fn_on_pago_confirmado acumula el monto y confirma la compra, y aquí se mueven sus
+// cupos de reservados a confirmados.
+// Se ejecuta en la transacción del servicio (NewPagoService(tx)) y retorna la compra cuyo voucher debe
+// enviarse una vez confirmada la transacción: solo si este pago la dejó confirmada (0 en otro caso, por
+// ejemplo un anticipo o un pago de saldo de una compra ya confirmada).
+func (s *PagoService) confirmarPago(pagoID uint, confirmadoPor *uint, notas *string) (uint, error) {
+	// Bloquear la compra antes que el trigger: dos pagos simultáneos ven su estado uno después del otro
+	var antes models.CompraPaquete
+	if err := s.db.Raw(`
+		SELECT c.id, c.status
+		FROM compras_paquetes c
+		JOIN pagos_compras pc ON pc.compra_id = c.id
+		WHERE pc.id = ?
+		FOR UPDATE OF c
+	`, pagoID).Scan(&antes).Error; err != nil {
+		return 0, err
+	}
+
 	now := time.Now()
 	res := s.db.Model(&models.PagoCompra{}).
 		Where("id = ? AND estado = ?", pagoID, "pendiente").
@@ -111,34 +175,103 @@ func (s *PagoService) ConfirmarPago(pagoID uint, confirmadoPor uint, notas *stri
 		})
 
 	if res.Error != nil {
-		return res.Error
+		return 0, res.Error
 	}
 	if res.RowsAffected == 0 {
-		return errors.New("pago no encontrado o ya fue procesado")
+		return 0, errPagoYaProcesado
 	}
-	return nil
-}
 
-func (s *PagoService) RechazarPago(pagoID uint, confirmadoPor uint, razon string, notas *string) error {
-	res := s.db.Model(&models.PagoCompra{}).
-		Where("id = ? AND estado = ?", pagoID, "pendiente").
-		Updates(map[string]interface{}{
-			"estado":          "rechazado",
-			"confirmado_por":  confirmadoPor,
-			"razon_rechazo":   razon,
-			"notas_encargado": notas,
-		})
+	var compra models.CompraPaquete
+	if err := s.db.Select("id", "status", "salida_id", "total_participantes").First(&compra, antes.ID).Error; err != nil {
+		return 0, err
+	}
+	confirmada := antes.Status != "confirmada" && compra.Status == "confirmada"
+	if confirmada && compra.SalidaID != nil {
+		if err := NewInventarioService(s.db).ConfirmarReservados(*compra.SalidaID, compra.TotalParticipantes); err != nil {
+			return 0, err
+		}
+	}
 
-	if res.Error != nil {
-		return res.Error
+	// Si el pago completó la compra, registrar la venta en el libro de comisiones. Va en un savepoint: si
+	// falla no revierte la confirmación y la sincronización del worker la recupera
+	if err := s.db.Transaction(func(sp *gorm.DB) error {
+		return registrarVentaComision(sp, compra.ID)
+	}); err != nil {
+		log.Printf("Error registrando la comisión de la compra %d: %v", compra.ID, err)
 	}
-	if res.RowsAffected == 0 {
-		return errors.New("pago no encontrado o ya fue procesado")
+
+	if !confirmada {
+		return 0, nil
 	}
-	return nil
+	return compra.ID, nil
+}
+
+// enviarVoucherConfirmacion envía en segundo plano el voucher de la compra; se llama después de confirmar
+// la transacción del pago para que el envío vea el código de confirmación generado por fn_on_pago_confirmado.
+func enviarVoucherConfirmacion(db *gorm.DB, compraID uint) {
+	if compraID == 0 {
+		return
+	}
+	go func() {
+		if err := NewVoucherService(db).EnviarVoucherConfirmacion(compraID); err != nil {
+			log.Printf("Error enviando voucher de la compra %d: %v", compraID, err)
+		}
+	}()
+}
+
+func (s *PagoService) RechazarPago(pagoID uint, confirmadoPor uint, razon string, notas *string) error {
+	if err := s.validarPagoManual(pagoID); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var antes models.CompraPaquete
+		if err := tx.Raw(`
+			SELECT c.id, c.status
+			FROM compras_paquetes c
+			JOIN pagos_compras pc ON pc.compra_id = c.id
+			WHERE pc.id = ?
+			FOR UPDATE OF c
+		`, pagoID).Scan(&antes).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&models.PagoCompra{}).
+			Where("id = ? AND estado = ?", pagoID, "pendiente").
+			Updates(map[string]interface{}{
+				"estado":          "rechazado",
+				"confirmado_por":  confirmadoPor,
+				"razon_rechazo":   razon,
+				"notas_encargado": notas,
+			})
+
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errPagoYaProcesado
+		}
+
+		// fn_on_pago_rechazado rechaza la compra; liberar sus cupos y ofrecerlos a la lista de espera
+		var compra models.CompraPaquete
+		if err := tx.First(&compra, antes.ID).Error; err != nil {
+			return err
+		}
+		if antes.Status == "rechazada" || compra.Status != "rechazada" || compra.SalidaID == nil {
+			return nil
+		}
+		if err := NewInventarioService(tx).LiberarReservados(*compra.SalidaID, compra.TotalParticipantes); err != nil {
+			return err
+		}
+		return ofrecerCuposListaEspera(tx, *compra.SalidaID)
+	})
 }
 
 func saveComprobante(fileHeader *multipart.FileHeader, compraID uint) (string, error) {
+	return guardarImagenSubida(fileHeader, filepath.Join("uploads", "comprobantes"), fmt.Sprintf("comprobante_%d", compraID))
+}
+
+// guardarImagenSubida guarda una imagen (jpg/png/webp) en destDir con el prefijo indicado y retorna la ruta relativa.
+func guardarImagenSubida(fileHeader *multipart.FileHeader, destDir string, prefijo string) (string, error) {
 	allowedTypes := map[string]string{
 		"image/jpeg": ".jpg",
 		"image/png":  ".png",
@@ -169,12 +302,11 @@ func saveComprobante(fileHeader *multipart.FileHeader, compraID uint) (string, e
 	}
 	defer src.Close()
 
-	destDir := filepath.Join("uploads", "comprobantes")
 	if err := os.MkdirAll(destDir, 0o755); err != nil {
 		return "", err
 	}
 
-	filename := fmt.Sprintf("comprobante_%d_%d%s", compraID, time.Now().UnixNano(), ext)
+	filename := fmt.Sprintf("%s_%d%s", prefijo, time.Now().UnixNano(), ext)
 	destPath := filepath.Join(destDir, filename)
 
 	dst, err := os.Create(destPath)
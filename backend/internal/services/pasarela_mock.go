@@ -0,0 +1,284 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	NombreProveedorMock = "mock"
+	// Header con la firma HMAC-SHA256 (hex) del cuerpo de los webhooks del proveedor simulado
+	HeaderFirmaMock = "X-Mock-Signature"
+)
+
+// Resultados que puede elegir el turista en el checkout simulado.
+const (
+	ResultadoMockExito    = "exito"
+	ResultadoMockFallo    = "fallo"
+	ResultadoMockDemorado = "demorado"
+)
+
+// MockPaymentProvider simula una pasarela para desarrollo y pruebas. Sirve un checkout propio
+// (ver handlers.PasarelaMockHandler) donde se elige el resultado: éxito, fallo o éxito con el webhook
+// demorado. Los webhooks se firman y se entregan por el mismo camino que los de una pasarela real.
+// Los intentos viven en memoria: se pierden al reiniciar el servidor.
+type MockPaymentProvider struct {
+	db      *gorm.DB
+	baseURL string
+	secreto string
+	demora  time.Duration
+
+	mu         sync.Mutex
+	intentos   map[string]*intentoMock
+	reembolsos map[string]*ReembolsoPasarela // por clave de idempotencia
+}
+
+type intentoMock struct {
+	referencia  string
+	monto       float64
+	descripcion string
+	urlRetorno  string
+	estado      string
+	reembolsado float64
+	expiraEn    time.Time
+}
+
+// CheckoutMock son los datos que muestra la página de checkout simulada.
+type CheckoutMock struct {
+	Referencia  string
+	Descripcion string
+	Monto       float64
+	Estado      string
+	ExpiraEn    time.Time
+}
+
+// webhookMock es el cuerpo de los webhooks del proveedor simulado.
+type webhookMock struct {
+	ID         string  `json:"id"`
+	Referencia string  `json:"referencia"`
+	Estado     string  `json:"estado"`
+	Monto      float64 `json:"monto"`
+	Detalle    string  `json:"detalle,omitempty"`
+}
+
+// NewMockPaymentProvider crea el proveedor simulado. baseURL es la URL pública de la API (para armar el
+// checkout) y demora el retraso de los webhooks del resultado "demorado".
+func NewMockPaymentProvider(db *gorm.DB, baseURL string, secreto string, demora time.Duration) *MockPaymentProvider {
+	return &MockPaymentProvider{
+		db:         db,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		secreto:    secreto,
+		demora:     demora,
+		intentos:   map[string]*intentoMock{},
+		reembolsos: map[string]*ReembolsoPasarela{},
+	}
+}
+
+func (m *MockPaymentProvider) Nombre() string {
+	return NombreProveedorMock
+}
+
+func referenciaMock(prefijo string) (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefijo + hex.EncodeToString(buf), nil
+}
+
+func (m *MockPaymentProvider) CrearIntento(solicitud SolicitudIntentoPago) (*IntentoPago, error) {
+	referencia, err := referenciaMock("mock_pi_")
+	if err != nil {
+		return nil, err
+	}
+
+	intento := &intentoMock{
+		referencia:  referencia,
+		monto:       solicitud.Monto,
+		descripcion: solicitud.Descripcion,
+		urlRetorno:  solicitud.URLRetorno,
+		estado:      EstadoPasarelaPendiente,
+		expiraEn:    time.Now().Add(duracionIntentoPago),
+	}
+
+	m.mu.Lock()
+	m.intentos[referencia] = intento
+	m.mu.Unlock()
+
+	return &IntentoPago{
+		Referencia:  referencia,
+		CheckoutURL: m.baseURL + "/api/v1/pasarela-mock/checkout/" + referencia,
+		ExpiraEn:    intento.expiraEn,
+	}, nil
+}
+
+// estadoVigente retorna el estado actual (vence los pendientes); debe llamarse con m.mu tomado.
+func (i *intentoMock) estadoVigente() string {
+	if i.estado == EstadoPasarelaPendiente && time.Now().After(i.expiraEn) {
+		i.estado = EstadoPasarelaExpirado
+	}
+	return i.estado
+}
+
+func (m *MockPaymentProvider) ConsultarEstado(referencia string) (*EstadoPagoPasarela, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	intento, ok := m.intentos[referencia]
+	if !ok {
+		// Intento perdido al reiniciar: para la pasarela simulada ya no se puede pagar
+		return &EstadoPagoPasarela{Referencia: referencia, Estado: EstadoPasarelaExpirado, Detalle: "La pasarela simulada no conoce el intento de pago"}, nil
+	}
+	return &EstadoPagoPasarela{Referencia: referencia, Estado: intento.estadoVigente(), Monto: intento.monto}, nil
+}
+
+func (m *MockPaymentProvider) firmar(cuerpo []byte) string {
+	mac := hmac.New(sha256.New, []byte(m.secreto))
+	mac.Write(cuerpo)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *MockPaymentProvider) ProcesarWebhook(headers http.Header, cuerpo []byte) (*EstadoPagoPasarela, error) {
+	firma, err := hex.DecodeString(strings.TrimSpace(headers.Get(HeaderFirmaMock)))
+	if err != nil || len(firma) == 0 {
+		return nil, ErrFirmaWebhookInvalida
+	}
+	esperada, _ := hex.DecodeString(m.firmar(cuerpo))
+	if !hmac.Equal(firma, esperada) {
+		return nil, ErrFirmaWebhookInvalida
+	}
+
+	var evento webhookMock
+	if err := json.Unmarshal(cuerpo, &evento); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookInvalido, err)
+	}
+	if evento.Referencia == "" {
+		return nil, fmt.Errorf("%w: sin referencia", ErrWebhookInvalido)
+	}
+	return &EstadoPagoPasarela{
+		EventoID:   evento.ID,
+		Referencia: evento.Referencia,
+		Estado:     evento.Estado,
+		Monto:      evento.Monto,
+		Detalle:    evento.Detalle,
+	}, nil
+}
+
+func (m *MockPaymentProvider) Reembolsar(referencia string, monto float64, claveIdempotencia string) (*ReembolsoPasarela, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if previo, ok := m.reembolsos[claveIdempotencia]; ok {
+		return previo, nil
+	}
+
+	intento, ok := m.intentos[referencia]
+	if !ok {
+		return nil, errors.New("la pasarela simulada no conoce el pago")
+	}
+	if intento.estadoVigente() != EstadoPasarelaAprobado {
+		return nil, errors.New("el pago no está aprobado")
+	}
+	if monto <= 0 || intento.reembolsado+monto > intento.monto+0.01 {
+		return nil, fmt.Errorf("el monto supera lo reembolsable (Bs %.2f)", intento.monto-intento.reembolsado)
+	}
+
+	refReembolso, err := referenciaMock("mock_re_")
+	if err != nil {
+		return nil, err
+	}
+	intento.reembolsado += monto
+	reembolso := &ReembolsoPasarela{Referencia: refReembolso, Monto: monto}
+	m.reembolsos[claveIdempotencia] = reembolso
+	return reembolso, nil
+}
+
+// Checkout retorna los datos del intento para la página de checkout simulada.
+func (m *MockPaymentProvider) Checkout(referencia string) (*CheckoutMock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	intento, ok := m.intentos[referencia]
+	if !ok {
+		return nil, errors.New("intento de pago no encontrado")
+	}
+	return &CheckoutMock{
+		Referencia:  intento.referencia,
+		Descripcion: intento.descripcion,
+		Monto:       intento.monto,
+		Estado:      intento.estadoVigente(),
+		ExpiraEn:    intento.expiraEn,
+	}, nil
+}
+
+// Simular aplica el resultado elegido en el checkout y programa el webhook: inmediato para éxito y
+// fallo, y con la demora configurada para "demorado" (la consulta de estado ya lo informa aprobado).
+// Retorna la URL de retorno del intento.
+func (m *MockPaymentProvider) Simular(referencia string, resultado string) (string, error) {
+	m.mu.Lock()
+	intento, ok := m.intentos[referencia]
+	if !ok {
+		m.mu.Unlock()
+		return "", errors.New("intento de pago no encontrado")
+	}
+	if intento.estadoVigente() != EstadoPasarelaPendiente {
+		m.mu.Unlock()
+		return "", fmt.Errorf("el intento de pago ya está %s", intento.estado)
+	}
+
+	evento := webhookMock{Referencia: intento.referencia, Monto: intento.monto}
+	demora := time.Duration(0)
+	switch resultado {
+	case ResultadoMockExito:
+		intento.estado = EstadoPasarelaAprobado
+	case ResultadoMockDemorado:
+		intento.estado = EstadoPasarelaAprobado
+		demora = m.demora
+	case ResultadoMockFallo:
+		intento.estado = EstadoPasarelaFallido
+		evento.Detalle = "Pago rechazado por el emisor (simulado)"
+	default:
+		m.mu.Unlock()
+		return "", errors.New("resultado inválido (use exito, fallo o demorado)")
+	}
+	evento.Estado = intento.estado
+	urlRetorno := intento.urlRetorno
+	m.mu.Unlock()
+
+	id, err := referenciaMock("mock_evt_")
+	if err != nil {
+		return "", err
+	}
+	evento.ID = id
+	time.AfterFunc(demora, func() { m.entregarWebhook(evento) })
+
+	return urlRetorno, nil
+}
+
+// entregarWebhook firma el evento y lo procesa como si llegara por HTTP.
+func (m *MockPaymentProvider) entregarWebhook(evento webhookMock) {
+	cuerpo, err := json.Marshal(evento)
+	if err != nil {
+		log.Printf("Error armando webhook simulado %s: %v", evento.ID, err)
+		return
+	}
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set(HeaderFirmaMock, m.firmar(cuerpo))
+
+	if _, err := NewWebhookPagoService(m.db).Recibir(m.Nombre(), headers, cuerpo); err != nil {
+		log.Printf("Error procesando webhook simulado %s: %v", evento.ID, err)
+	}
+}
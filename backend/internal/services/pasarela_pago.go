@@ -0,0 +1,468 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Estados normalizados que reportan las pasarelas de pago.
+const (
+	EstadoPasarelaPendiente = "pendiente"
+	EstadoPasarelaAprobado  = "aprobado"
+	EstadoPasarelaFallido   = "fallido"
+	EstadoPasarelaExpirado  = "expirado"
+)
+
+// Plazo para completar un pago en línea si la pasarela no informa uno propio.
+const duracionIntentoPago = 30 * time.Minute
+
+var (
+	ErrProveedorPagoNoDisponible = errors.New("proveedor de pago no disponible")
+	ErrFirmaWebhookInvalida      = errors.New("firma del webhook inválida")
+	ErrMontoPasarelaDistinto     = errors.New("el monto informado por la pasarela no coincide con el pago")
+)
+
+// SolicitudIntentoPago son los datos que recibe la pasarela para cobrar un PagoCompra.
+type SolicitudIntentoPago struct {
+	PagoID      uint
+	CompraID    uint
+	Monto       float64
+	Moneda      string
+	Descripcion string
+	// URL del frontend a la que vuelve el turista al terminar el checkout
+	URLRetorno string
+}
+
+// IntentoPago es el cobro creado en la pasarela; el turista paga en CheckoutURL.
+type IntentoPago struct {
+	Referencia  string
+	CheckoutURL string
+	ExpiraEn    time.Time
+}
+
+// EstadoPagoPasarela es el estado de un cobro informado por la pasarela (consulta o webhook).
+type EstadoPagoPasarela struct {
+	// ID del evento; solo viene en los webhooks
+	EventoID   string
+	Referencia string
+	Estado     string
+	Monto      float64
+	Detalle    string
+}
+
+// ReembolsoPasarela es una devolución registrada en la pasarela.
+type ReembolsoPasarela struct {
+	Referencia string
+	Monto      float64
+}
+
+// PaymentProvider es una pasarela de pago en línea. Cada implementación traduce sus estados a los
+// EstadoPasarela* y verifica la autenticidad de sus propios webhooks.
+type PaymentProvider interface {
+	Nombre() string
+	CrearIntento(solicitud SolicitudIntentoPago) (*IntentoPago, error)
+	ConsultarEstado(referencia string) (*EstadoPagoPasarela, error)
+	ProcesarWebhook(headers http.Header, cuerpo []byte) (*EstadoPagoPasarela, error)
+	// Reembolsar devuelve parte o todo un pago. Con la misma claveIdempotencia retorna la devolución ya
+	// registrada en lugar de devolver otra vez.
+	Reembolsar(referencia string, monto float64, claveIdempotencia string) (*ReembolsoPasarela, error)
+}
+
+var (
+	proveedoresPagoMu sync.RWMutex
+	proveedoresPago   = map[string]PaymentProvider{}
+)
+
+// RegistrarProveedorPago habilita una pasarela; se llama al iniciar la aplicación.
+func RegistrarProveedorPago(proveedor PaymentProvider) {
+	proveedoresPagoMu.Lock()
+	defer proveedoresPagoMu.Unlock()
+	proveedoresPago[proveedor.Nombre()] = proveedor
+}
+
+// ObtenerProveedorPago retorna la pasarela registrada con ese nombre.
+func ObtenerProveedorPago(nombre string) (PaymentProvider, error) {
+	proveedoresPagoMu.RLock()
+	defer proveedoresPagoMu.RUnlock()
+	proveedor, ok := proveedoresPago[strings.TrimSpace(nombre)]
+	if !ok {
+		return nil, ErrProveedorPagoNoDisponible
+	}
+	return proveedor, nil
+}
+
+// ProveedoresPagoDisponibles lista los nombres de las pasarelas registradas.
+func ProveedoresPagoDisponibles() []string {
+	proveedoresPagoMu.RLock()
+	defer proveedoresPagoMu.RUnlock()
+	nombres := make([]string, 0, len(proveedoresPago))
+	for nombre := range proveedoresPago {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
+
+// PasarelaService conecta los PagoCompra con las pasarelas en línea: crea el intento de cobro, aplica
+// los estados que informa la pasarela (por consulta o webhook) y reembolsa por la misma vía.
+type PasarelaService struct {
+	db *gorm.DB
+}
+
+func NewPasarelaService(db *gorm.DB) *PasarelaService {
+	return &PasarelaService{db: db}
+}
+
+// urlRetornoPasarela arma la URL del frontend a la que vuelve el turista (PASARELA_URL_RETORNO).
+func urlRetornoPasarela(pagoID uint) string {
+	base := strings.TrimSpace(os.Getenv("PASARELA_URL_RETORNO"))
+	if base == "" {
+		return ""
+	}
+	separador := "?"
+	if strings.Contains(base, "?") {
+		separador = "&"
+	}
+	return fmt.Sprintf("%s%spago_id=%d", base, separador, pagoID)
+}
+
+// IniciarPago registra un PagoCompra pendiente con metodo_pago "pasarela" y crea el cobro en la pasarela.
+func (s *PasarelaService) IniciarPago(turistaID uint, req *models.IniciarPagoPasarelaRequest) (*models.PagoPasarelaResponse, error) {
+	proveedor, err := ObtenerProveedorPago(req.Proveedor)
+	if err != nil {
+		return nil, err
+	}
+
+	var pago models.PagoCompra
+	var paqueteNombre string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var compra models.CompraPaquete
+		if err := tx.Raw(`SELECT * FROM compras_paquetes WHERE id = ? AND turista_id = ? FOR UPDATE`, req.CompraID, turistaID).
+			Scan(&compra).Error; err != nil {
+			return err
+		}
+		if compra.ID == 0 {
+			return errors.New("compra no encontrada")
+		}
+		if compra.Status != "pendiente_confirmacion" && compra.Status != "reservada_con_anticipo" {
+			return errors.New("la compra no está pendiente de pago")
+		}
+		if err := validarMontoPago(&compra, req.Monto); err != nil {
+			return err
+		}
+
+		var pendientes int64
+		if err := tx.Model(&models.PagoCompra{}).
+			Where("compra_id = ? AND estado = ?", compra.ID, "pendiente").
+			Count(&pendientes).Error; err != nil {
+			return err
+		}
+		if pendientes > 0 {
+			return errors.New("ya existe un pago pendiente para esta compra")
+		}
+
+		var paquete models.PaqueteTuristico
+		if err := tx.Select("id", "nombre").First(&paquete, compra.PaqueteID).Error; err != nil {
+			return err
+		}
+		paqueteNombre = paquete.Nombre
+
+		nombreProveedor := proveedor.Nombre()
+		pago = models.PagoCompra{
+			CompraID:   compra.ID,
+			MetodoPago: "pasarela",
+			Monto:      req.Monto,
+			Proveedor:  &nombreProveedor,
+			Estado:     "pendiente",
+		}
+		return tx.Create(&pago).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	intento, err := proveedor.CrearIntento(SolicitudIntentoPago{
+		PagoID:      pago.ID,
+		CompraID:    pago.CompraID,
+		Monto:       pago.Monto,
+		Moneda:      "BOB",
+		Descripcion: fmt.Sprintf("Compra #%d - %s", pago.CompraID, paqueteNombre),
+		URLRetorno:  urlRetornoPasarela(pago.ID),
+	})
+	if err != nil {
+		if cerrarErr := s.cerrarIntento(&pago, EstadoPasarelaFallido, "No se pudo iniciar el pago en línea"); cerrarErr != nil {
+			log.Printf("Error cerrando el pago en línea %d: %v", pago.ID, cerrarErr)
+		}
+		return nil, fmt.Errorf("no se pudo iniciar el pago en línea: %w", err)
+	}
+
+	expira := intento.ExpiraEn
+	if expira.IsZero() {
+		expira = time.Now().Add(duracionIntentoPago)
+	}
+	if err := s.db.Model(&models.PagoCompra{}).Where("id = ?", pago.ID).Updates(map[string]interface{}{
+		"referencia_proveedor": intento.Referencia,
+		"checkout_url":         intento.CheckoutURL,
+		"fecha_expiracion":     expira,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.PagoPasarelaResponse{
+		PagoID:          pago.ID,
+		CompraID:        pago.CompraID,
+		Proveedor:       proveedor.Nombre(),
+		Referencia:      intento.Referencia,
+		CheckoutURL:     intento.CheckoutURL,
+		Monto:           pago.Monto,
+		Estado:          pago.Estado,
+		FechaExpiracion: &expira,
+	}, nil
+}
+
+// SincronizarPago consulta a la pasarela el estado de un pago en línea pendiente y lo aplica.
+// Sirve al volver del checkout, sin esperar el webhook.
+func (s *PasarelaService) SincronizarPago(pagoID uint) (*models.PagoCompra, error) {
+	var pago models.PagoCompra
+	if err := s.db.First(&pago, pagoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("pago no encontrado")
+		}
+		return nil, err
+	}
+	if pago.MetodoPago != "pasarela" || pago.Proveedor == nil {
+		return nil, errors.New("el pago no es un pago en línea")
+	}
+
+	if pago.Estado == "pendiente" && pago.ReferenciaProveedor != nil {
+		proveedor, err := ObtenerProveedorPago(*pago.Proveedor)
+		if err != nil {
+			return nil, err
+		}
+		estado, err := proveedor.ConsultarEstado(*pago.ReferenciaProveedor)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo consultar la pasarela: %w", err)
+		}
+		compraID, err := s.aplicarEstado(&pago, estado)
+		if err != nil {
+			return nil, err
+		}
+		enviarVoucherConfirmacion(s.db, compraID)
+		if err := s.db.First(&pago, pago.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &pago, nil
+}
+
+// aplicarEstado lleva el PagoCompra al estado informado por la pasarela. Es idempotente: un pago que
+// ya no está pendiente no se vuelve a procesar. Si el servicio se creó con una transacción, los cambios
+// quedan en ella. Retorna la compra cuyo voucher hay que enviar al confirmar la transacción (0 si ninguna).
+func (s *PasarelaService) aplicarEstado(pago *models.PagoCompra, estado *EstadoPagoPasarela) (uint, error) {
+	if pago.Estado != "pendiente" {
+		if estado.Estado == EstadoPasarelaAprobado && pago.Estado != "confirmado" {
+			log.Printf("La pasarela aprobó el pago %d que ya estaba %s; revise si corresponde devolverlo", pago.ID, pago.Estado)
+		}
+		return 0, nil
+	}
+
+	switch estado.Estado {
+	case EstadoPasarelaAprobado:
+		if math.Abs(estado.Monto-pago.Monto) > 0.01 {
+			return 0, fmt.Errorf("%w: la pasarela informa Bs %.2f y el pago %d es de Bs %.2f", ErrMontoPasarelaDistinto, estado.Monto, pago.ID, pago.Monto)
+		}
+		notas := fmt.Sprintf("Confirmado por la pasarela %s (ref. %s)", *pago.Proveedor, estado.Referencia)
+		var compraID uint
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			compraID, err = NewPagoService(tx).confirmarPago(pago.ID, nil, &notas)
+			return err
+		})
+		if errors.Is(err, errPagoYaProcesado) {
+			return 0, nil
+		}
+		return compraID, err
+	case EstadoPasarelaFallido, EstadoPasarelaExpirado:
+		detalle := strings.TrimSpace(estado.Detalle)
+		if detalle == "" {
+			detalle = "El pago en línea no se completó"
+		}
+		return 0, s.cerrarIntento(pago, estado.Estado, detalle)
+	}
+	return 0, nil
+}
+
+// cerrarIntento marca un pago en línea como fallido o expirado. A diferencia de un rechazo, la compra
+// sigue pendiente y el turista puede volver a intentar el pago.
+func (s *PasarelaService) cerrarIntento(pago *models.PagoCompra, estado string, detalle string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.PagoCompra{}).
+			Where("id = ? AND estado = ?", pago.ID, "pendiente").
+			Updates(map[string]interface{}{
+				"estado":        estado,
+				"razon_rechazo": detalle,
+				"updated_at":    time.Now(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		var compra models.CompraPaquete
+		if err := tx.Select("id", "turista_id", "paquete_id").First(&compra, pago.CompraID).Error; err != nil {
+			return err
+		}
+		_, err := notificarUsuario(tx, compra.TuristaID, models.TipoPagoPasarelaFallido,
+			"Tu pago en línea no se completó",
+			fmt.Sprintf("%s. Tu compra #%d sigue pendiente: puedes volver a intentar el pago.", detalle, compra.ID),
+			models.NotifDatosJSON{
+				"pago_id":    pago.ID,
+				"compra_id":  compra.ID,
+				"paquete_id": compra.PaqueteID,
+				"monto":      pago.Monto,
+				"estado":     estado,
+			})
+		return err
+	})
+}
+
+// ExpirarIntentos cierra los pagos en línea pendientes cuyo plazo venció. Antes consulta a la pasarela
+// por si el pago se completó y el webhook no llegó.
+func (s *PasarelaService) ExpirarIntentos() (int64, error) {
+	var pagos []models.PagoCompra
+	if err := s.db.
+		Where("metodo_pago = ? AND estado = ?", "pasarela", "pendiente").
+		Where("COALESCE(fecha_expiracion, created_at + ?::interval) < ?", fmt.Sprintf("%d minutes", int(duracionIntentoPago.Minutes())), time.Now()).
+		Find(&pagos).Error; err != nil {
+		return 0, fmt.Errorf("error buscando pagos en línea vencidos: %w", err)
+	}
+
+	var expirados int64
+	for i := range pagos {
+		pago := &pagos[i]
+		estado := &EstadoPagoPasarela{Estado: EstadoPasarelaExpirado, Detalle: "El plazo para completar el pago en línea venció"}
+		if pago.Proveedor != nil && pago.ReferenciaProveedor != nil {
+			if proveedor, err := ObtenerProveedorPago(*pago.Proveedor); err == nil {
+				consultado, err := proveedor.ConsultarEstado(*pago.ReferenciaProveedor)
+				if err != nil {
+					log.Printf("Error consultando el pago en línea %d: %v", pago.ID, err)
+					continue
+				}
+				if consultado.Estado != EstadoPasarelaPendiente {
+					estado = consultado
+				} else {
+					estado.Referencia = consultado.Referencia
+				}
+			}
+		}
+		compraID, err := s.aplicarEstado(pago, estado)
+		if err != nil {
+			log.Printf("Error cerrando el pago en línea %d: %v", pago.ID, err)
+			continue
+		}
+		enviarVoucherConfirmacion(s.db, compraID)
+		if estado.Estado != EstadoPasarelaAprobado {
+			expirados++
+		}
+	}
+
+	return expirados, nil
+}
+
+// devolucionPasarela es la parte de un reembolso que se devuelve por uno de los pagos en línea de la compra.
+type devolucionPasarela struct {
+	proveedor  string
+	referencia string // referencia del pago en la pasarela
+	monto      float64
+	clave      string // clave de idempotencia de la devolución
+}
+
+// planificarReembolsoPasarela reparte el reembolso entre los pagos en línea confirmados de la compra (una
+// compra con anticipo y saldo tiene varios). Lo devuelto por reembolsos anteriores de la compra se descuenta
+// en el mismo orden, así cada reintento produce el mismo reparto y las mismas claves de idempotencia.
+func planificarReembolsoPasarela(tx *gorm.DB, reembolso *models.Reembolso) ([]devolucionPasarela, error) {
+	var pagos []models.PagoCompra
+	if err := tx.
+		Where("compra_id = ? AND metodo_pago = ? AND estado = ?", reembolso.CompraID, "pasarela", "confirmado").
+		Order("id DESC").
+		Find(&pagos).Error; err != nil {
+		return nil, err
+	}
+	var previo float64
+	if err := tx.Model(&models.Reembolso{}).
+		Where("compra_id = ? AND id < ? AND metodo_pago = ? AND estado IN ?", reembolso.CompraID, reembolso.ID, "pasarela", []string{"procesando", "pagado"}).
+		Select("COALESCE(SUM(monto_reembolso), 0)").
+		Scan(&previo).Error; err != nil {
+		return nil, err
+	}
+	return repartirReembolso(pagos, previo, reembolso.ID, reembolso.MontoReembolso)
+}
+
+// repartirReembolso asigna el monto a los pagos del más reciente al más antiguo, saltando lo ya devuelto.
+func repartirReembolso(pagos []models.PagoCompra, previo float64, reembolsoID uint, monto float64) ([]devolucionPasarela, error) {
+	devoluciones := []devolucionPasarela{}
+	restante := monto
+	for _, pago := range pagos {
+		if restante < 0.01 {
+			break
+		}
+		disponible := pago.Monto
+		if previo > 0 {
+			usado := math.Min(previo, disponible)
+			previo -= usado
+			disponible -= usado
+		}
+		if disponible < 0.01 {
+			continue
+		}
+		if pago.Proveedor == nil || pago.ReferenciaProveedor == nil {
+			return nil, fmt.Errorf("el pago en línea %d no tiene referencia de la pasarela", pago.ID)
+		}
+		parte := redondearMonto(math.Min(disponible, restante))
+		devoluciones = append(devoluciones, devolucionPasarela{
+			proveedor:  *pago.Proveedor,
+			referencia: *pago.ReferenciaProveedor,
+			monto:      parte,
+			clave:      fmt.Sprintf("reembolso-%d-pago-%d", reembolsoID, pago.ID),
+		})
+		restante = redondearMonto(restante - parte)
+	}
+	if restante >= 0.01 {
+		return nil, fmt.Errorf("los pagos en línea de la compra cubren Bs %.2f de los Bs %.2f a devolver; registre el reembolso por efectivo, QR o transferencia",
+			redondearMonto(monto-restante), monto)
+	}
+	return devoluciones, nil
+}
+
+// ejecutarReembolsoPasarela pide cada devolución a su pasarela y retorna las referencias separadas por coma.
+// Se llama fuera de toda transacción: una devolución ya hecha no se puede revertir con un rollback.
+func ejecutarReembolsoPasarela(devoluciones []devolucionPasarela) (string, error) {
+	referencias := make([]string, 0, len(devoluciones))
+	for _, d := range devoluciones {
+		proveedor, err := ObtenerProveedorPago(d.proveedor)
+		if err != nil {
+			return "", err
+		}
+		reembolso, err := proveedor.Reembolsar(d.referencia, d.monto, d.clave)
+		if err != nil {
+			return "", fmt.Errorf("la pasarela rechazó el reembolso: %w", err)
+		}
+		referencias = append(referencias, reembolso.Referencia)
+	}
+	return strings.Join(referencias, ","), nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ResolverPreciosSalidas completa el precio vigente (salida, temporada o base) de cada salida
+// usando public.resolver_precio_paquete, el mismo cálculo que aplica la compra.
+func ResolverPreciosSalidas(db *gorm.DB, salidas []models.PaqueteSalidaHabilitada) error {
+	if len(salidas) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(salidas))
+	for _, s := range salidas {
+		ids = append(ids, s.ID)
+	}
+
+	var rows []struct {
+		SalidaID uint `gorm:"column:salida_id"`
+		models.PrecioSalida
+	}
+	if err := db.Raw(`
+		SELECT s.id AS salida_id, r.precio_base, r.precio_adicional_extranjeros, r.regla_precio, r.temporada_precio_id
+		FROM paquete_salidas_habilitadas s
+		CROSS JOIN LATERAL public.resolver_precio_paquete(s.paquete_id, s.fecha_salida::date, s.id) r
+		WHERE s.id IN ?
+	`, ids).Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	precios := make(map[uint]models.PrecioSalida, len(rows))
+	for _, row := range rows {
+		precios[row.SalidaID] = row.PrecioSalida
+	}
+	for i := range salidas {
+		if precio, ok := precios[salidas[i].ID]; ok {
+			p := precio
+			salidas[i].Precio = &p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Estados de compra que cuentan como uso vigente de una promoción.
+var estadosCompraUsoPromocion = []string{"pendiente_confirmacion", "reservada_con_anticipo", "confirmada"}
+
+func redondearMonto(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// NormalizarCodigoPromocion deja el código en el formato en que se almacena.
+func NormalizarCodigoPromocion(codigo string) string {
+	return strings.ToUpper(strings.TrimSpace(codigo))
+}
+
+func parseFechaPromocion(raw *string) (*time.Time, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return nil, nil
+	}
+	value := strings.TrimSpace(*raw)
+	if len(value) > 10 {
+		value = value[:10]
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Now().Location())
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ContarUsosPromocion retorna los usos vigentes de una promoción (opcionalmente por turista).
+func ContarUsosPromocion(db *gorm.DB, promocionID uint, turistaID *uint) (int64, error) {
+	q := db.Table("promocion_usos pu").
+		Joins("JOIN compras_paquetes c ON c.id = pu.compra_id").
+		Where("pu.promocion_id = ?", promocionID).
+		Where("c.status IN ?", estadosCompraUsoPromocion)
+	if turistaID != nil {
+		q = q.Where("pu.turista_id = ?", *turistaID)
+	}
+	var total int64
+	err := q.Count(&total).Error
+	return total, err
+}
+
+// calcularDescuentoPromocion retorna el monto a descontar sobre el precio indicado.
+func calcularDescuentoPromocion(promo *models.Promocion, precio float64) float64 {
+	if promo == nil || precio <= 0 {
+		return 0
+	}
+	var descuento float64
+	switch promo.TipoDescuento {
+	case "porcentaje":
+		descuento = precio * promo.Valor / 100
+	case "monto_fijo":
+		descuento = promo.Valor
+	}
+	if descuento > precio {
+		descuento = precio
+	}
+	if descuento < 0 {
+		descuento = 0
+	}
+	return redondearMonto(descuento)
+}
+
+// validarPromocion verifica vigencia, alcance y límites de uso de una promoción.
+// Debe llamarse con la fila de la promoción bloqueada para que el conteo de usos sea consistente.
+func validarPromocion(tx *gorm.DB, promo *models.Promocion, paqueteID uint, turistaID *uint) error {
+	if !promo.Activa {
+		return errors.New("el código de promoción no está activo")
+	}
+
+	hoy := time.Now()
+	hoy = time.Date(hoy.Year(), hoy.Month(), hoy.Day(), 0, 0, 0, 0, hoy.Location())
+
+	inicio, err := parseFechaPromocion(promo.FechaInicio)
+	if err != nil {
+		return err
+	}
+	if inicio != nil && hoy.Before(*inicio) {
+		return errors.New("el código de promoción aún no está vigente")
+	}
+	fin, err := parseFechaPromocion(promo.FechaFin)
+	if err != nil {
+		return err
+	}
+	if fin != nil && hoy.After(*fin) {
+		return errors.New("el código de promoción está vencido")
+	}
+
+	var alcance int64
+	if err := tx.Model(&models.PromocionPaquete{}).Where("promocion_id = ?", promo.ID).Count(&alcance).Error; err != nil {
+		return err
+	}
+	if alcance > 0 {
+		var aplica int64
+		if err := tx.Model(&models.PromocionPaquete{}).
+			Where("promocion_id = ? AND paquete_id = ?", promo.ID, paqueteID).
+			Count(&aplica).Error; err != nil {
+			return err
+		}
+		if aplica == 0 {
+			return errors.New("el código de promoción no aplica a este paquete")
+		}
+	}
+
+	if promo.UsosMaximos != nil {
+		usos, err := ContarUsosPromocion(tx, promo.ID, nil)
+		if err != nil {
+			return err
+		}
+		if usos >= int64(*promo.UsosMaximos) {
+			return errors.New("el código de promoción alcanzó su límite de usos")
+		}
+	}
+
+	if promo.UsosPorUsuario != nil && turistaID != nil {
+		usos, err := ContarUsosPromocion(tx, promo.ID, turistaID)
+		if err != nil {
+			return err
+		}
+		if usos >= int64(*promo.UsosPorUsuario) {
+			return errors.New("ya utilizó este código de promoción el máximo de veces permitido")
+		}
+	}
+
+	return nil
+}
+
+// buscarPromocionPorCodigo busca la promoción de la agencia dueña del paquete.
+// Si lock es true, bloquea la fila (SELECT ... FOR UPDATE) hasta el fin de la transacción.
+func buscarPromocionPorCodigo(tx *gorm.DB, paqueteID uint, codigo string, lock bool) (*models.Promocion, error) {
+	query := `
+		SELECT pr.*
+		FROM promociones pr
+		JOIN paquetes_turisticos p ON p.agencia_id = pr.agencia_id
+		WHERE p.id = ? AND pr.codigo = ?
+		LIMIT 1`
+	if lock {
+		query += " FOR UPDATE OF pr"
+	}
+
+	var promo models.Promocion
+	if err := tx.Raw(query, paqueteID, NormalizarCodigoPromocion(codigo)).Scan(&promo).Error; err != nil {
+		return nil, err
+	}
+	if promo.ID == 0 {
+		return nil, errors.New("código de promoción inválido")
+	}
+	return &promo, nil
+}
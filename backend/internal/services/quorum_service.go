@@ -0,0 +1,179 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// QuorumService decide, al vencer la fecha límite de inscripción, si una salida compartida se realiza:
+// la activa si alcanzó el cupo mínimo y la cancela (con reembolso a los turistas) si no.
+type QuorumService struct {
+	db *gorm.DB
+}
+
+func NewQuorumService(db *gorm.DB) *QuorumService {
+	return &QuorumService{db: db}
+}
+
+// ProcesarQuorumSalidas evalúa las salidas compartidas pendientes cuya fecha límite de inscripción ya pasó.
+// Retorna la cantidad de salidas activadas y canceladas.
+func (s *QuorumService) ProcesarQuorumSalidas() (int64, int64, error) {
+	var ids []uint
+	if err := s.db.Model(&models.PaqueteSalidaHabilitada{}).
+		Where("estado = ? AND tipo_salida = ?", "pendiente", "compartido").
+		Where("fecha_limite_inscripcion IS NOT NULL AND fecha_limite_inscripcion <= ?", time.Now()).
+		Order("fecha_salida ASC").
+		Pluck("id", &ids).Error; err != nil {
+		return 0, 0, fmt.Errorf("error buscando salidas con inscripción vencida: %w", err)
+	}
+
+	var activadas, canceladas int64
+	for _, id := range ids {
+		var resultado string
+		var avisos []avisoEmail
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			resultado, avisos, err = s.decidirSalida(tx, id)
+			return err
+		})
+		if err != nil {
+			log.Printf("Error evaluando cupo mínimo de la salida %d: %v", id, err)
+			continue
+		}
+		enviarAvisosEmail(avisos)
+		switch resultado {
+		case "activa":
+			activadas++
+		case "cancelada":
+			canceladas++
+		}
+	}
+
+	return activadas, canceladas, nil
+}
+
+// decidirSalida bloquea la salida y aplica la decisión de quórum. Retorna el nuevo estado ("" si no cambió)
+// y los emails a enviar una vez confirmada la transacción.
+func (s *QuorumService) decidirSalida(tx *gorm.DB, salidaID uint) (string, []avisoEmail, error) {
+	var salida models.PaqueteSalidaHabilitada
+	if err := tx.Raw(`SELECT * FROM paquete_salidas_habilitadas WHERE id = ? FOR UPDATE SKIP LOCKED`, salidaID).
+		Scan(&salida).Error; err != nil {
+		return "", nil, err
+	}
+	// Otra instancia la está procesando o ya cambió de estado
+	if salida.ID == 0 || salida.Estado != "pendiente" || salida.FechaLimiteInscripcion == nil || salida.FechaLimiteInscripcion.After(time.Now()) {
+		return "", nil, nil
+	}
+
+	var paquete models.PaqueteTuristico
+	if err := tx.Select("id", "nombre").First(&paquete, salida.PaqueteID).Error; err != nil {
+		return "", nil, err
+	}
+
+	ocupados := salida.CuposReservados + salida.CuposConfirmados
+	nuevoEstado := "cancelada"
+	if ocupados > 0 && ocupados >= salida.CupoMinimo {
+		nuevoEstado = "activa"
+	}
+	if err := NewSalidaService(tx).validarTransicionEstado(salida.Estado, nuevoEstado); err != nil {
+		return "", nil, err
+	}
+
+	fecha := fechaSalidaString(salida.FechaSalida)
+	encargadoID, err := encargadoPrincipalDePaquete(tx, salida.PaqueteID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if nuevoEstado == "activa" {
+		if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(map[string]interface{}{
+			"estado":     "activa",
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return "", nil, err
+		}
+
+		var compras []models.CompraPaquete
+		if err := tx.Select("id", "turista_id", "paquete_id", "status").
+			Where("salida_id = ? AND status IN ?", salida.ID, comprasActivasSalida).
+			Find(&compras).Error; err != nil {
+			return "", nil, err
+		}
+		for _, compra := range compras {
+			mensaje := fmt.Sprintf("Se alcanzó el cupo mínimo: la salida de \"%s\" del %s se realizará.", paquete.Nombre, fecha)
+			if compra.Status != "confirmada" {
+				mensaje += " Recuerda completar el pago de tu compra."
+			}
+			if _, err := notificarUsuario(tx, compra.TuristaID, models.TipoSalidaConfirmada, "Tu salida está confirmada", mensaje,
+				models.NotifDatosJSON{
+					"compra_id":      compra.ID,
+					"salida_id":      salida.ID,
+					"paquete_id":     compra.PaqueteID,
+					"paquete_nombre": paquete.Nombre,
+					"fecha_salida":   fecha,
+				}); err != nil {
+				return "", nil, err
+			}
+		}
+
+		if err := notificarGuiasSalida(tx, salida.ID, models.TipoSalidaGuiaActualizada, "Salida confirmada",
+			fmt.Sprintf("La salida del %s alcanzó el cupo mínimo y se realizará", fecha)); err != nil {
+			return "", nil, err
+		}
+
+		if encargadoID != 0 {
+			if _, err := notificarUsuario(tx, encargadoID, models.TipoResumenQuorumSalida, "Salida activada automáticamente",
+				fmt.Sprintf("La salida de \"%s\" del %s se activó al cierre de inscripciones con %d de %d cupos mínimos", paquete.Nombre, fecha, ocupados, salida.CupoMinimo),
+				models.NotifDatosJSON{
+					"salida_id":   salida.ID,
+					"paquete_id":  salida.PaqueteID,
+					"estado":      "activa",
+					"ocupados":    ocupados,
+					"cupo_minimo": salida.CupoMinimo,
+					"compras":     len(compras),
+				}); err != nil {
+				return "", nil, err
+			}
+		}
+		return nuevoEstado, nil, nil
+	}
+
+	razon := fmt.Sprintf("No se alcanzó el cupo mínimo (%d de %d) al cierre de inscripciones", ocupados, salida.CupoMinimo)
+	_, avisos, err := cancelarSalidaConCompras(tx, &salida, razon, "Salida cancelada por cupo mínimo")
+	if err != nil {
+		return "", nil, err
+	}
+	return nuevoEstado, avisos, nil
+}
+
+// StartQuorumWorker inicia un worker que cierra periódicamente las inscripciones vencidas de las salidas
+// compartidas
+func StartQuorumWorker(db *gorm.DB, intervaloChequeoMinutos int) {
+	if intervaloChequeoMinutos < 1 {
+		intervaloChequeoMinutos = 15
+	}
+
+	service := NewQuorumService(db)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(intervaloChequeoMinutos) * time.Minute)
+		defer ticker.Stop()
+
+		log.Printf("Worker de cupo mínimo de salidas iniciado: chequea cada %d minutos", intervaloChequeoMinutos)
+
+		for {
+			activadas, canceladas, err := service.ProcesarQuorumSalidas()
+			if err != nil {
+				log.Printf("Error evaluando cupo mínimo de salidas: %v", err)
+			} else if activadas > 0 || canceladas > 0 {
+				log.Printf("Worker de cupo mínimo de salidas: cierre de inscripciones, %d salidas activadas y %d canceladas", activadas, canceladas)
+			}
+			<-ticker.C
+		}
+	}()
+}
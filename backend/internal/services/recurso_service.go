@@ -0,0 +1,444 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ConflictoRecursosError indica que uno o más recursos no están disponibles en las fechas de la salida.
+type ConflictoRecursosError struct {
+	Conflictos []models.ConflictoRecurso
+}
+
+func (e *ConflictoRecursosError) Error() string {
+	nombres := []string{}
+	vistos := map[uint]bool{}
+	for _, c := range e.Conflictos {
+		if !vistos[c.RecursoID] {
+			vistos[c.RecursoID] = true
+			nombres = append(nombres, c.RecursoNombre)
+		}
+	}
+	return fmt.Sprintf("recursos no disponibles en esas fechas: %s", strings.Join(nombres, ", "))
+}
+
+type RecursoService struct {
+	db *gorm.DB
+}
+
+func NewRecursoService(db *gorm.DB) *RecursoService {
+	return &RecursoService{db: db}
+}
+
+// rangoSalida retorna las fechas (YYYY-MM-DD) y el horario que ocupa una salida.
+func rangoSalida(tx *gorm.DB, salidaID uint) (string, string, string, error) {
+	var rango struct {
+		Inicio  string
+		Fin     string
+		Horario string
+	}
+	err := tx.Raw(`
+		SELECT to_char(r.inicio, 'YYYY-MM-DD') AS inicio, to_char(r.fin, 'YYYY-MM-DD') AS fin, r.horario
+		FROM (
+			SELECT`+sqlRangoSalida+`
+			FROM paquete_salidas_habilitadas s
+			JOIN paquetes_turisticos p ON p.id = s.paquete_id
+			WHERE s.id = ?
+		) r
+	`, salidaID).Scan(&rango).Error
+	if err != nil {
+		return "", "", "", err
+	}
+	if rango.Inicio == "" {
+		return "", "", "", errors.New("salida no encontrada")
+	}
+	return rango.Inicio, rango.Fin, rango.Horario, nil
+}
+
+// ocupacionesRecursos lista las salidas vigentes y los mantenimientos de los recursos que tocan el rango.
+func ocupacionesRecursos(tx *gorm.DB, recursoIDs []uint, desde, hasta string, excluirSalidaID uint) ([]models.OcupacionRecurso, error) {
+	ocupaciones := []models.OcupacionRecurso{}
+	if len(recursoIDs) == 0 {
+		return ocupaciones, nil
+	}
+
+	err := tx.Raw(`
+		SELECT o.recurso_id, o.motivo, o.salida_id, o.paquete_nombre,
+		       to_char(o.inicio, 'YYYY-MM-DD') AS fecha_inicio,
+		       to_char(o.fin, 'YYYY-MM-DD') AS fecha_fin,
+		       o.horario
+		FROM (
+			SELECT sr.recurso_id, 'salida' AS motivo, s.id AS salida_id, p.nombre AS paquete_nombre,`+sqlRangoSalida+`
+			FROM salida_recursos sr
+			JOIN paquete_salidas_habilitadas s ON s.id = sr.salida_id
+			JOIN paquetes_turisticos p ON p.id = s.paquete_id
+			WHERE sr.recurso_id IN ?
+			  AND sr.salida_id <> ?
+			  AND s.estado IN ('pendiente', 'activa', 'en_curso')
+			UNION ALL
+			SELECT m.recurso_id, 'mantenimiento' AS motivo, NULL, m.motivo,
+			       m.fecha_inicio AS inicio, m.fecha_fin AS fin, 'todo_dia' AS horario
+			FROM recurso_mantenimientos m
+			WHERE m.recurso_id IN ?
+		) o
+		WHERE o.inicio <= ?::date
+		  AND o.fin >= ?::date
+		ORDER BY o.inicio ASC, o.recurso_id ASC
+	`, recursoIDs, excluirSalidaID, recursoIDs, hasta, desde).Scan(&ocupaciones).Error
+	return ocupaciones, err
+}
+
+// horariosChocan aplica la regla de medio día: mañana y tarde del mismo día no se superponen.
+func horariosChocan(a, b string) bool {
+	return a == "todo_dia" || b == "todo_dia" || a == b
+}
+
+// capacidadAsientos suma los asientos de los vehículos asignados a la salida.
+func capacidadAsientos(tx *gorm.DB, salidaID uint) (int, int64, error) {
+	var resultado struct {
+		Asientos  int
+		Vehiculos int64
+	}
+	err := tx.Raw(`
+		SELECT COALESCE(SUM(r.capacidad), 0) AS asientos, COUNT(*) AS vehiculos
+		FROM salida_recursos sr
+		JOIN recursos r ON r.id = sr.recurso_id
+		WHERE sr.salida_id = ? AND r.tipo = 'vehiculo'
+	`, salidaID).Scan(&resultado).Error
+	return resultado.Asientos, resultado.Vehiculos, err
+}
+
+// validarCupoVehiculos impide subir el cupo de una salida por encima de los asientos de sus vehículos.
+func validarCupoVehiculos(tx *gorm.DB, salidaID uint, cupoMaximo int) error {
+	asientos, vehiculos, err := capacidadAsientos(tx, salidaID)
+	if err != nil {
+		return err
+	}
+	if vehiculos > 0 && cupoMaximo > asientos {
+		return fmt.Errorf("el cupo máximo (%d) supera los asientos de los vehículos asignados (%d)", cupoMaximo, asientos)
+	}
+	return nil
+}
+
+// ObtenerRecursosSalida retorna los recursos asignados a una salida y el resumen de capacidad.
+func (s *RecursoService) ObtenerRecursosSalida(agenciaID uint, salidaID uint) (*models.RecursosSalidaResponse, error) {
+	var salida models.PaqueteSalidaHabilitada
+	if err := s.db.Joins("JOIN paquetes_turisticos ON paquete_salidas_habilitadas.paquete_id = paquetes_turisticos.id").
+		Where("paquete_salidas_habilitadas.id = ? AND paquetes_turisticos.agencia_id = ?", salidaID, agenciaID).
+		First(&salida).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("salida no encontrada")
+		}
+		return nil, err
+	}
+
+	asignaciones := []models.SalidaRecurso{}
+	if err := s.db.Preload("Recurso").
+		Joins("JOIN recursos ON recursos.id = salida_recursos.recurso_id").
+		Where("salida_recursos.salida_id = ?", salidaID).
+		Order("recursos.tipo ASC").
+		Order("recursos.nombre ASC").
+		Find(&asignaciones).Error; err != nil {
+		return nil, err
+	}
+
+	resp := &models.RecursosSalidaResponse{
+		SalidaID:     salida.ID,
+		Recursos:     asignaciones,
+		Ocupacion:    salida.CuposReservados + salida.CuposConfirmados,
+		CupoMaximo:   salida.CupoMaximo,
+		Advertencias: []string{},
+	}
+
+	capacidadPorTipo := map[string]int{}
+	for _, a := range asignaciones {
+		if a.Recurso == nil {
+			continue
+		}
+		capacidadPorTipo[a.Recurso.Tipo] += a.Recurso.Capacidad
+	}
+	resp.CapacidadAsientos = capacidadPorTipo["vehiculo"]
+
+	if resp.CapacidadAsientos > 0 && resp.CapacidadAsientos < salida.CupoMaximo {
+		resp.Advertencias = append(resp.Advertencias, fmt.Sprintf("los vehículos tienen %d asientos y el cupo máximo es %d", resp.CapacidadAsientos, salida.CupoMaximo))
+	}
+	tipos := make([]string, 0, len(capacidadPorTipo))
+	for tipo := range capacidadPorTipo {
+		tipos = append(tipos, tipo)
+	}
+	sort.Strings(tipos)
+	for _, tipo := range tipos {
+		if tipo == "vehiculo" {
+			continue
+		}
+		if capacidadPorTipo[tipo] < resp.Ocupacion {
+			resp.Advertencias = append(resp.Advertencias, fmt.Sprintf("%s cubre %d de %d participantes", tipo, capacidadPorTipo[tipo], resp.Ocupacion))
+		}
+	}
+
+	return resp, nil
+}
+
+// AsignarRecursos reemplaza los recursos de una salida. Valida que estén activos, fuera de mantenimiento y
+// sin otras salidas superpuestas, y que los asientos de los vehículos cubran los cupos reservados y confirmados.
+// Con vehículos asignados, el cupo máximo de la salida queda limitado a sus asientos.
+func (s *RecursoService) AsignarRecursos(agenciaID uint, salidaID uint, usuarioID uint, req *models.AsignarRecursosSalidaRequest) (*models.RecursosSalidaResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		salida, err := BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil {
+			return err
+		}
+		if salida.Estado != "pendiente" && salida.Estado != "activa" && salida.Estado != "en_curso" {
+			return fmt.Errorf("no se pueden asignar recursos a una salida %s", salida.Estado)
+		}
+
+		recursoIDs := []uint{}
+		vistos := map[uint]bool{}
+		for _, id := range req.RecursoIDs {
+			if vistos[id] {
+				return fmt.Errorf("el recurso %d está repetido", id)
+			}
+			vistos[id] = true
+			recursoIDs = append(recursoIDs, id)
+		}
+
+		recursos := map[uint]models.Recurso{}
+		if len(recursoIDs) > 0 {
+			// Bloquear los recursos serializa asignaciones simultáneas a distintas salidas
+			var encontrados []models.Recurso
+			if err := tx.Raw(`SELECT * FROM recursos WHERE id IN ? AND agencia_id = ? ORDER BY id FOR UPDATE`, recursoIDs, agenciaID).
+				Scan(&encontrados).Error; err != nil {
+				return err
+			}
+			for _, r := range encontrados {
+				recursos[r.ID] = r
+			}
+		}
+
+		asientos := 0
+		vehiculos := 0
+		for _, id := range recursoIDs {
+			r, ok := recursos[id]
+			if !ok {
+				return fmt.Errorf("el recurso %d no pertenece a la agencia", id)
+			}
+			if !r.Activo {
+				return fmt.Errorf("el recurso %s está inactivo", r.Nombre)
+			}
+			if r.Tipo == "vehiculo" {
+				asientos += r.Capacidad
+				vehiculos++
+			}
+		}
+
+		ocupacion := salida.CuposReservados + salida.CuposConfirmados
+		if vehiculos > 0 && asientos < ocupacion {
+			return fmt.Errorf("los vehículos asignados tienen %d asientos y la salida tiene %d cupos reservados o confirmados", asientos, ocupacion)
+		}
+
+		inicio, fin, horario, err := rangoSalida(tx, salida.ID)
+		if err != nil {
+			return err
+		}
+		ocupaciones, err := ocupacionesRecursos(tx, recursoIDs, inicio, fin, salida.ID)
+		if err != nil {
+			return err
+		}
+		conflictos := []models.ConflictoRecurso{}
+		for _, o := range ocupaciones {
+			if o.Motivo == "salida" && !horariosChocan(o.Horario, horario) {
+				continue
+			}
+			conflictos = append(conflictos, models.ConflictoRecurso{
+				RecursoID:     o.RecursoID,
+				RecursoNombre: recursos[o.RecursoID].Nombre,
+				Motivo:        o.Motivo,
+				SalidaID:      o.SalidaID,
+				PaqueteNombre: o.PaqueteNombre,
+				FechaInicio:   o.FechaInicio,
+				FechaFin:      o.FechaFin,
+			})
+		}
+		if len(conflictos) > 0 {
+			return &ConflictoRecursosError{Conflictos: conflictos}
+		}
+
+		query := tx.Where("salida_id = ?", salida.ID)
+		if len(recursoIDs) > 0 {
+			query = query.Where("recurso_id NOT IN ?", recursoIDs)
+		}
+		if err := query.Delete(&models.SalidaRecurso{}).Error; err != nil {
+			return err
+		}
+
+		var actuales []uint
+		if err := tx.Model(&models.SalidaRecurso{}).Where("salida_id = ?", salida.ID).Pluck("recurso_id", &actuales).Error; err != nil {
+			return err
+		}
+		yaAsignados := map[uint]bool{}
+		for _, id := range actuales {
+			yaAsignados[id] = true
+		}
+		for _, id := range recursoIDs {
+			if yaAsignados[id] {
+				continue
+			}
+			asignacion := models.SalidaRecurso{SalidaID: salida.ID, RecursoID: id, AsignadoPorID: &usuarioID}
+			if err := tx.Create(&asignacion).Error; err != nil {
+				return err
+			}
+		}
+
+		if req.AjustarCupo && vehiculos == 0 {
+			return errors.New("ajustar_cupo requiere al menos un vehículo asignado")
+		}
+		// Con vehículos asignados la salida no vende más lugares que asientos: el cupo se limita siempre y
+		// ajustar_cupo además lo amplía hasta los asientos
+		if vehiculos == 0 || asientos == salida.CupoMaximo || (asientos > salida.CupoMaximo && !req.AjustarCupo) {
+			return nil
+		}
+		retenidos := ocupacion + salida.CuposOfertados
+		if asientos < retenidos {
+			return fmt.Errorf("no se puede ajustar el cupo a %d: hay %d cupos reservados, confirmados u ofertados", asientos, retenidos)
+		}
+		if err := NewInventarioService(tx).AjustarCupoMaximo(salida.ID, asientos); err != nil {
+			return err
+		}
+		// Si el ajuste amplió el cupo, ofrecer los nuevos lugares a la lista de espera
+		if asientos > salida.CupoMaximo {
+			return ofrecerCuposListaEspera(tx, salida.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ObtenerRecursosSalida(agenciaID, salidaID)
+}
+
+// CrearMantenimiento registra una ventana de mantenimiento. Falla si el recurso ya está asignado a salidas en esas fechas.
+func (s *RecursoService) CrearMantenimiento(recurso *models.Recurso, req *models.CrearMantenimientoRequest) (*models.RecursoMantenimiento, error) {
+	inicio, err := time.Parse("2006-01-02", strings.TrimSpace(req.FechaInicio))
+	if err != nil {
+		return nil, errors.New("fecha_inicio invalida (YYYY-MM-DD)")
+	}
+	fin, err := time.Parse("2006-01-02", strings.TrimSpace(req.FechaFin))
+	if err != nil {
+		return nil, errors.New("fecha_fin invalida (YYYY-MM-DD)")
+	}
+	if fin.Before(inicio) {
+		return nil, errors.New("fecha_fin debe ser igual o posterior a fecha_inicio")
+	}
+
+	mantenimiento := models.RecursoMantenimiento{
+		RecursoID:   recurso.ID,
+		FechaInicio: inicio,
+		FechaFin:    fin,
+		Motivo:      req.Motivo,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT id FROM recursos WHERE id = ? FOR UPDATE`, recurso.ID).Error; err != nil {
+			return err
+		}
+		ocupaciones, err := ocupacionesRecursos(tx, []uint{recurso.ID}, inicio.Format("2006-01-02"), fin.Format("2006-01-02"), 0)
+		if err != nil {
+			return err
+		}
+		conflictos := []models.ConflictoRecurso{}
+		for _, o := range ocupaciones {
+			if o.Motivo != "salida" {
+				continue
+			}
+			conflictos = append(conflictos, models.ConflictoRecurso{
+				RecursoID:     o.RecursoID,
+				RecursoNombre: recurso.Nombre,
+				Motivo:        o.Motivo,
+				SalidaID:      o.SalidaID,
+				PaqueteNombre: o.PaqueteNombre,
+				FechaInicio:   o.FechaInicio,
+				FechaFin:      o.FechaFin,
+			})
+		}
+		if len(conflictos) > 0 {
+			return &ConflictoRecursosError{Conflictos: conflictos}
+		}
+		return tx.Create(&mantenimiento).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &mantenimiento, nil
+}
+
+// Disponibilidad retorna la agenda de los recursos activos de la agencia y el resumen diario de recursos libres.
+func (s *RecursoService) Disponibilidad(agenciaID uint, desde, hasta time.Time, tipo string) (*models.DisponibilidadRecursosResponse, error) {
+	query := s.db.Where("agencia_id = ? AND activo = ?", agenciaID, true)
+	if tipo != "" {
+		query = query.Where("tipo = ?", tipo)
+	}
+	var recursos []models.Recurso
+	if err := query.Order("tipo ASC").Order("nombre ASC").Find(&recursos).Error; err != nil {
+		return nil, err
+	}
+
+	recursoIDs := make([]uint, 0, len(recursos))
+	for _, r := range recursos {
+		recursoIDs = append(recursoIDs, r.ID)
+	}
+	desdeStr, hastaStr := desde.Format("2006-01-02"), hasta.Format("2006-01-02")
+	ocupaciones, err := ocupacionesRecursos(s.db, recursoIDs, desdeStr, hastaStr, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	porRecurso := map[uint][]models.OcupacionRecurso{}
+	for _, o := range ocupaciones {
+		porRecurso[o.RecursoID] = append(porRecurso[o.RecursoID], o)
+	}
+
+	resp := &models.DisponibilidadRecursosResponse{
+		Desde:    desdeStr,
+		Hasta:    hastaStr,
+		Recursos: make([]models.DisponibilidadRecurso, 0, len(recursos)),
+		Dias:     []models.DisponibilidadDia{},
+	}
+	for _, r := range recursos {
+		items := porRecurso[r.ID]
+		if items == nil {
+			items = []models.OcupacionRecurso{}
+		}
+		resp.Recursos = append(resp.Recursos, models.DisponibilidadRecurso{Recurso: r, Ocupaciones: items})
+	}
+
+	// Un recurso cuenta como libre en el día si no tiene ninguna ocupación ese día
+	for dia := desde; !dia.After(hasta); dia = dia.AddDate(0, 0, 1) {
+		fecha := dia.Format("2006-01-02")
+		resumen := models.DisponibilidadDia{Fecha: fecha, LibresPorTipo: map[string]int{}}
+		for _, r := range recursos {
+			libre := true
+			for _, o := range porRecurso[r.ID] {
+				if o.FechaInicio <= fecha && o.FechaFin >= fecha {
+					libre = false
+					break
+				}
+			}
+			if !libre {
+				continue
+			}
+			resumen.LibresPorTipo[r.Tipo]++
+			if r.Tipo == "vehiculo" {
+				resumen.AsientosDisponibles += r.Capacidad
+			}
+		}
+		resp.Dias = append(resp.Dias, resumen)
+	}
+
+	return resp, nil
+}
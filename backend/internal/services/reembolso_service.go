@@ -0,0 +1,343 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type ReembolsoService struct {
+	db *gorm.DB
+}
+
+func NewReembolsoService(db *gorm.DB) *ReembolsoService {
+	return &ReembolsoService{db: db}
+}
+
+// CargarTramosReembolso retorna los tramos de la agencia ordenados de mayor a menor anticipación.
+func CargarTramosReembolso(db *gorm.DB, agenciaID uint) ([]models.PoliticaReembolsoTramo, error) {
+	var tramos []models.PoliticaReembolsoTramo
+	if err := db.Where("agencia_id = ?", agenciaID).
+		Order("dias_antes_minimo DESC").
+		Find(&tramos).Error; err != nil {
+		return nil, err
+	}
+	return tramos, nil
+}
+
+// porcentajeReembolsoPorTramos aplica el tramo con mayor anticipación que no supere los días restantes.
+// Sin tramos configurados no hay reembolso automático.
+func porcentajeReembolsoPorTramos(tramos []models.PoliticaReembolsoTramo, diasAntes int) float64 {
+	sorted := append([]models.PoliticaReembolsoTramo(nil), tramos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].DiasAntesMinimo > sorted[j].DiasAntesMinimo
+	})
+	for _, tramo := range sorted {
+		if diasAntes >= tramo.DiasAntesMinimo {
+			return tramo.PorcentajeReembolso
+		}
+	}
+	return 0
+}
+
+// montoConfirmadoCompra suma los pagos confirmados de la compra.
+func montoConfirmadoCompra(tx *gorm.DB, compraID uint) (float64, error) {
+	var total float64
+	if err := tx.Model(&models.PagoCompra{}).
+		Where("compra_id = ? AND estado = ?", compraID, "confirmado").
+		Select("COALESCE(SUM(monto), 0)").
+		Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// fechaSalidaCompra retorna la fecha de salida efectiva de la compra (la de su salida si existe).
+func fechaSalidaCompra(tx *gorm.DB, compra *models.CompraPaquete) (time.Time, error) {
+	base := time.Date(compra.FechaSeleccionada.Year(), compra.FechaSeleccionada.Month(), compra.FechaSeleccionada.Day(), 0, 0, 0, 0, time.UTC)
+	if compra.SalidaID == nil {
+		return base, nil
+	}
+
+	var salida models.PaqueteSalidaHabilitada
+	if err := tx.Select("id", "fecha_salida").First(&salida, *compra.SalidaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return base, nil
+		}
+		return base, err
+	}
+
+	value := strings.TrimSpace(salida.FechaSalida)
+	if len(value) > 10 {
+		value = value[:10]
+	}
+	fecha, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return base, nil
+	}
+	return fecha, nil
+}
+
+func agenciaIDDePaquete(tx *gorm.DB, paqueteID uint) (uint, error) {
+	var paquete models.PaqueteTuristico
+	if err := tx.Select("id", "agencia_id").First(&paquete, paqueteID).Error; err != nil {
+		return 0, err
+	}
+	return paquete.AgenciaID, nil
+}
+
+// calcularCancelacion calcula el reembolso que corresponde si la compra se cancela hoy.
+func calcularCancelacion(tx *gorm.DB, compra *models.CompraPaquete) (*models.CancelacionPreviewResponse, error) {
+	fechaSalida, err := fechaSalidaCompra(tx, compra)
+	if err != nil {
+		return nil, err
+	}
+	diasAntes := int(fechaSalida.Sub(fechaHoyUTC()).Hours() / 24)
+
+	resp := &models.CancelacionPreviewResponse{
+		CompraID:        compra.ID,
+		Status:          compra.Status,
+		FechaSalida:     fechaSalida.Format("2006-01-02"),
+		DiasAntesSalida: diasAntes,
+	}
+
+	if compra.Status != "confirmada" && compra.Status != "reservada_con_anticipo" {
+		return resp, nil
+	}
+
+	pagado, err := montoConfirmadoCompra(tx, compra.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	agenciaID, err := agenciaIDDePaquete(tx, compra.PaqueteID)
+	if err != nil {
+		return nil, err
+	}
+	tramos, err := CargarTramosReembolso(tx, agenciaID)
+	if err != nil {
+		return nil, err
+	}
+
+	porcentaje := porcentajeReembolsoPorTramos(tramos, diasAntes)
+	resp.MontoPagado = pagado
+	resp.PorcentajeReembolso = porcentaje
+	resp.MontoReembolso = redondearMonto(pagado * porcentaje / 100)
+	resp.RequiereReembolso = resp.MontoReembolso > 0
+	return resp, nil
+}
+
+// registrarReembolso crea el registro de reembolso de una compra y avisa al encargado si hay monto a devolver.
+func registrarReembolso(
+	tx *gorm.DB,
+	compra *models.CompraPaquete,
+	motivo string,
+	razon *string,
+	montoPagado float64,
+	porcentaje float64,
+	diasAntes *int,
+) (*models.Reembolso, error) {
+	agenciaID, err := agenciaIDDePaquete(tx, compra.PaqueteID)
+	if err != nil {
+		return nil, err
+	}
+
+	monto := redondearMonto(montoPagado * porcentaje / 100)
+	estado := "pendiente"
+	if monto <= 0 {
+		estado = "no_aplica"
+	}
+
+	reembolso := models.Reembolso{
+		CompraID:            compra.ID,
+		AgenciaID:           agenciaID,
+		TuristaID:           compra.TuristaID,
+		Motivo:              motivo,
+		Razon:               razon,
+		MontoPagado:         montoPagado,
+		PorcentajeReembolso: porcentaje,
+		MontoReembolso:      monto,
+		DiasAntesSalida:     diasAntes,
+		Estado:              estado,
+	}
+	if err := tx.Create(&reembolso).Error; err != nil {
+		return nil, err
+	}
+
+	if estado == "pendiente" {
+		encargadoID, err := encargadoPrincipalDePaquete(tx, compra.PaqueteID)
+		if err != nil {
+			return nil, err
+		}
+		if encargadoID != 0 {
+			if _, err := notificarUsuario(tx, encargadoID, models.TipoReembolsoPendiente,
+				"Reembolso pendiente de pago",
+				fmt.Sprintf("La compra #%d fue cancelada y tiene un reembolso pendiente de Bs %.2f", compra.ID, monto),
+				models.NotifDatosJSON{
+					"reembolso_id":    reembolso.ID,
+					"compra_id":       compra.ID,
+					"paquete_id":      compra.PaqueteID,
+					"monto_reembolso": monto,
+					"motivo":          motivo,
+				}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &reembolso, nil
+}
+
+// ListarReembolsosAgencia lista reembolsos de una agencia (opcionalmente filtrados por estado).
+func (s *ReembolsoService) ListarReembolsosAgencia(agenciaID uint, estado string, page, pageSize int) ([]models.Reembolso, int64, error) {
+	q := s.db.Model(&models.Reembolso{}).Where("agencia_id = ?", agenciaID)
+	if estado != "" {
+		q = q.Where("estado = ?", estado)
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var reembolsos []models.Reembolso
+	if err := q.Session(&gorm.Session{}).
+		Preload("Compra.Paquete").
+		Preload("Compra.Turista").
+		Order("created_at DESC").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&reembolsos).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return reembolsos, total, nil
+}
+
+// MarcarPagado registra que la agencia devolvió el monto al turista.
+func (s *ReembolsoService) MarcarPagado(agenciaID uint, reembolsoID uint, usuarioID uint, req *models.PagarReembolsoRequest) (*models.Reembolso, error) {
+	var reembolso models.Reembolso
+	if err := s.db.Where("id = ? AND agencia_id = ?", reembolsoID, agenciaID).First(&reembolso).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("reembolso no encontrado")
+		}
+		return nil, err
+	}
+
+	// Un reembolso en 'procesando' quedó a medias en la pasarela: solo se reintenta por la pasarela
+	estadosPagables := []string{"pendiente"}
+	if req.MetodoPago == "pasarela" {
+		estadosPagables = append(estadosPagables, "procesando")
+	}
+	if reembolso.Estado == "procesando" && req.MetodoPago != "pasarela" {
+		return nil, errors.New("el reembolso se está procesando por la pasarela; reintente con metodo_pago pasarela")
+	}
+	if reembolso.Estado != "pendiente" && reembolso.Estado != "procesando" {
+		return nil, errors.New("el reembolso no está pendiente")
+	}
+
+	needsComprobante := req.MetodoPago == "qr" || req.MetodoPago == "transferencia"
+	if needsComprobante && req.Comprobante == nil {
+		return nil, errors.New("debe adjuntar comprobante para reembolsos por QR o transferencia")
+	}
+
+	var comprobantePath *string
+	if req.Comprobante != nil {
+		path, err := guardarImagenSubida(req.Comprobante, filepath.Join("uploads", "reembolsos"), fmt.Sprintf("reembolso_%d", reembolso.ID))
+		if err != nil {
+			return nil, err
+		}
+		comprobantePath = &path
+	}
+
+	var referenciaPasarela *string
+	if req.MetodoPago == "pasarela" {
+		referencias, err := s.devolverPorPasarela(reembolso.ID)
+		if err != nil {
+			return nil, err
+		}
+		referenciaPasarela = &referencias
+	}
+
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Reembolso{}).
+			Where("id = ? AND estado IN ?", reembolso.ID, estadosPagables).
+			Updates(map[string]interface{}{
+				"estado":              "pagado",
+				"metodo_pago":         req.MetodoPago,
+				"comprobante_foto":    comprobantePath,
+				"referencia_pasarela": referenciaPasarela,
+				"pagado_por":          usuarioID,
+				"fecha_pago":          now,
+				"notas_encargado":     req.NotasEncargado,
+				"updated_at":          now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("el reembolso ya fue procesado")
+		}
+		if err := registrarReembolsoComision(tx, reembolso.ID); err != nil {
+			return err
+		}
+
+		_, err := notificarUsuario(tx, reembolso.TuristaID, models.TipoReembolsoPagado,
+			"Tu reembolso fue pagado",
+			fmt.Sprintf("La agencia registró la devolución de Bs %.2f de tu compra #%d", reembolso.MontoReembolso, reembolso.CompraID),
+			models.NotifDatosJSON{
+				"reembolso_id":     reembolso.ID,
+				"compra_id":        reembolso.CompraID,
+				"monto_reembolso":  reembolso.MontoReembolso,
+				"metodo_pago":      req.MetodoPago,
+				"comprobante_foto": comprobantePath,
+			})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.First(&reembolso, reembolso.ID).Error; err != nil {
+		return nil, err
+	}
+	return &reembolso, nil
+}
+
+// devolverPorPasarela deja el reembolso en 'procesando' en su propia transacción y recién después pide las
+// devoluciones a la pasarela, cada una con su clave de idempotencia. Si algo falla después, el reembolso
+// queda en 'procesando' y reintentarlo no devuelve dos veces el mismo monto.
+func (s *ReembolsoService) devolverPorPasarela(reembolsoID uint) (string, error) {
+	var devoluciones []devolucionPasarela
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		var actual models.Reembolso
+		if err := tx.Raw(`SELECT * FROM reembolsos WHERE id = ? FOR UPDATE`, reembolsoID).Scan(&actual).Error; err != nil {
+			return err
+		}
+		if actual.Estado != "pendiente" && actual.Estado != "procesando" {
+			return errors.New("el reembolso ya fue procesado")
+		}
+		var err error
+		devoluciones, err = planificarReembolsoPasarela(tx, &actual)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Reembolso{}).Where("id = ?", actual.ID).Updates(map[string]interface{}{
+			"estado":      "procesando",
+			"metodo_pago": "pasarela",
+			"updated_at":  time.Now(),
+		}).Error
+	}); err != nil {
+		return "", err
+	}
+	return ejecutarReembolsoPasarela(devoluciones)
+}
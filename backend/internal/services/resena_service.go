@@ -0,0 +1,331 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const maxFotosResena = 5
+
+var ErrResenaNoEncontrada = errors.New("reseña no encontrada")
+
+type ResenaService struct {
+	db *gorm.DB
+}
+
+func NewResenaService(db *gorm.DB) *ResenaService {
+	return &ResenaService{db: db}
+}
+
+// CrearResena registra la reseña de una compra confirmada cuya salida ya se completó.
+// Los turistas marcados como no-show no pueden reseñar.
+func (s *ResenaService) CrearResena(compraID uint, turistaID uint, req *models.CrearResenaRequest) (*models.Resena, error) {
+	var resena models.Resena
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var compra models.CompraPaquete
+		if err := tx.Preload("Paquete").Preload("Salida").
+			Where("id = ? AND turista_id = ?", compraID, turistaID).
+			First(&compra).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("compra no encontrada")
+			}
+			return err
+		}
+
+		if compra.Status != "confirmada" {
+			return errors.New("solo se pueden reseñar compras confirmadas")
+		}
+		if compra.Salida == nil || compra.Salida.Estado != "completada" {
+			return errors.New("podrás dejar tu reseña cuando la salida haya finalizado")
+		}
+		if compra.NoShow {
+			return errors.New("no se registró tu asistencia a esta salida")
+		}
+		if compra.Paquete == nil {
+			return errors.New("paquete no encontrado")
+		}
+
+		var existentes int64
+		if err := tx.Model(&models.Resena{}).Where("compra_id = ?", compra.ID).Count(&existentes).Error; err != nil {
+			return err
+		}
+		if existentes > 0 {
+			return errors.New("ya dejaste una reseña para esta compra")
+		}
+
+		resena = models.Resena{
+			CompraID:                 compra.ID,
+			TuristaID:                turistaID,
+			PaqueteID:                compra.PaqueteID,
+			AgenciaID:                compra.Paquete.AgenciaID,
+			CalificacionGeneral:      req.CalificacionGeneral,
+			CalificacionGuia:         req.CalificacionGuia,
+			CalificacionPrecio:       req.CalificacionPrecio,
+			CalificacionOrganizacion: req.CalificacionOrganizacion,
+			Comentario:               strings.TrimSpace(req.Comentario),
+		}
+		if err := tx.Create(&resena).Error; err != nil {
+			return err
+		}
+
+		return recalcularRatings(tx, resena.PaqueteID, resena.AgenciaID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resena, nil
+}
+
+// AgregarFotoResena adjunta una foto a la reseña del turista (máximo maxFotosResena).
+func (s *ResenaService) AgregarFotoResena(resenaID uint, turistaID uint, fileHeader *multipart.FileHeader) (*models.ResenaFoto, error) {
+	var resena models.Resena
+	if err := s.db.Where("id = ? AND turista_id = ?", resenaID, turistaID).First(&resena).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResenaNoEncontrada
+		}
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.ResenaFoto{}).Where("resena_id = ?", resena.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count >= maxFotosResena {
+		return nil, fmt.Errorf("la reseña ya tiene el máximo de %d fotos", maxFotosResena)
+	}
+
+	path, err := guardarImagenSubida(fileHeader, filepath.Join("uploads", "resenas"), fmt.Sprintf("resena_%d", resena.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	foto := models.ResenaFoto{ResenaID: resena.ID, Foto: path, Orden: int(count)}
+	if err := s.db.Create(&foto).Error; err != nil {
+		return nil, err
+	}
+	return &foto, nil
+}
+
+// ResponderResena registra la respuesta de la agencia. Solo se permite una respuesta por reseña.
+func (s *ResenaService) ResponderResena(agenciaID uint, resenaID uint, usuarioID uint, respuesta string) (*models.Resena, error) {
+	respuesta = strings.TrimSpace(respuesta)
+	if respuesta == "" {
+		return nil, errors.New("la respuesta no puede estar vacía")
+	}
+
+	now := time.Now()
+	res := s.db.Model(&models.Resena{}).
+		Where("id = ? AND agencia_id = ? AND respuesta_agencia IS NULL", resenaID, agenciaID).
+		Updates(map[string]interface{}{
+			"respuesta_agencia": respuesta,
+			"fecha_respuesta":   now,
+			"respondida_por_id": usuarioID,
+			"updated_at":        now,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	var resena models.Resena
+	if err := s.db.Preload("Fotos").Where("id = ? AND agencia_id = ?", resenaID, agenciaID).First(&resena).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResenaNoEncontrada
+		}
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.New("la agencia ya respondió esta reseña")
+	}
+	return &resena, nil
+}
+
+// ModerarResena oculta o vuelve a mostrar una reseña y recalcula los promedios.
+func (s *ResenaService) ModerarResena(resenaID uint, adminID uint, req *models.ModerarResenaRequest) (*models.Resena, error) {
+	var resena models.Resena
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&resena, resenaID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrResenaNoEncontrada
+			}
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"oculta":          req.Oculta,
+			"razon_oculta":    nil,
+			"ocultada_por_id": nil,
+			"updated_at":      time.Now(),
+		}
+		if req.Oculta {
+			updates["razon_oculta"] = req.Razon
+			updates["ocultada_por_id"] = adminID
+		}
+		if err := tx.Model(&resena).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		return recalcularRatings(tx, resena.PaqueteID, resena.AgenciaID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Preload("Fotos").First(&resena, resenaID).Error; err != nil {
+		return nil, err
+	}
+	return &resena, nil
+}
+
+// ListarResenasAgencia lista todas las reseñas de la agencia (incluidas las ocultas).
+func (s *ResenaService) ListarResenasAgencia(agenciaID uint, page, limit int) ([]models.Resena, int64, error) {
+	query := s.db.Model(&models.Resena{}).Where("agencia_id = ?", agenciaID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var resenas []models.Resena
+	if err := query.
+		Preload("Fotos", func(db *gorm.DB) *gorm.DB {
+			return db.Order("orden ASC").Order("id ASC")
+		}).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&resenas).Error; err != nil {
+		return nil, 0, err
+	}
+	return resenas, total, nil
+}
+
+// ListarResenasPublicas lista las reseñas visibles de un paquete o de una agencia (el otro ID en 0).
+func (s *ResenaService) ListarResenasPublicas(paqueteID uint, agenciaID uint, page, limit int) ([]models.ResenaPublicaResponse, *models.ResumenResenasResponse, int64, error) {
+	query := s.db.Model(&models.Resena{}).Where("resenas.oculta = ?", false)
+	if paqueteID > 0 {
+		query = query.Where("resenas.paquete_id = ?", paqueteID)
+	}
+	if agenciaID > 0 {
+		query = query.Where("resenas.agencia_id = ?", agenciaID)
+	}
+
+	var resumen models.ResumenResenasResponse
+	if err := query.Session(&gorm.Session{}).Select(`
+		COUNT(*) AS total,
+		COALESCE(ROUND(AVG(calificacion_general)::numeric, 2), 0) AS promedio_general,
+		ROUND(AVG(calificacion_guia)::numeric, 2) AS promedio_guia,
+		ROUND(AVG(calificacion_precio)::numeric, 2) AS promedio_precio,
+		ROUND(AVG(calificacion_organizacion)::numeric, 2) AS promedio_organizacion
+	`).Scan(&resumen).Error; err != nil {
+		return nil, nil, 0, err
+	}
+
+	var resenas []models.Resena
+	if err := query.Session(&gorm.Session{}).
+		Preload("Turista").
+		Preload("Fotos", func(db *gorm.DB) *gorm.DB {
+			return db.Order("orden ASC").Order("id ASC")
+		}).
+		Order("resenas.created_at DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&resenas).Error; err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Nombre del paquete y fecha de salida de cada reseña
+	compraIDs := make([]uint, 0, len(resenas))
+	for _, r := range resenas {
+		compraIDs = append(compraIDs, r.CompraID)
+	}
+	type compraInfo struct {
+		CompraID          uint      `gorm:"column:compra_id"`
+		PaqueteNombre     string    `gorm:"column:paquete_nombre"`
+		FechaSeleccionada time.Time `gorm:"column:fecha_seleccionada"`
+	}
+	info := map[uint]compraInfo{}
+	if len(compraIDs) > 0 {
+		var rows []compraInfo
+		if err := s.db.Table("compras_paquetes c").
+			Select("c.id AS compra_id, p.nombre AS paquete_nombre, c.fecha_seleccionada").
+			Joins("JOIN paquetes_turisticos p ON p.id = c.paquete_id").
+			Where("c.id IN ?", compraIDs).
+			Scan(&rows).Error; err != nil {
+			return nil, nil, 0, err
+		}
+		for _, row := range rows {
+			info[row.CompraID] = row
+		}
+	}
+
+	items := make([]models.ResenaPublicaResponse, 0, len(resenas))
+	for _, r := range resenas {
+		nombre := ""
+		if r.Turista != nil {
+			// Solo nombre e inicial del apellido
+			nombre = r.Turista.Nombre
+			if apellido := strings.TrimSpace(r.Turista.ApellidoPaterno); apellido != "" {
+				nombre += " " + string([]rune(apellido)[0]) + "."
+			}
+		}
+		fotos := r.Fotos
+		if fotos == nil {
+			fotos = []models.ResenaFoto{}
+		}
+		items = append(items, models.ResenaPublicaResponse{
+			ID:                       r.ID,
+			PaqueteID:                r.PaqueteID,
+			PaqueteNombre:            info[r.CompraID].PaqueteNombre,
+			AgenciaID:                r.AgenciaID,
+			TuristaNombre:            nombre,
+			CalificacionGeneral:      r.CalificacionGeneral,
+			CalificacionGuia:         r.CalificacionGuia,
+			CalificacionPrecio:       r.CalificacionPrecio,
+			CalificacionOrganizacion: r.CalificacionOrganizacion,
+			Comentario:               r.Comentario,
+			Fotos:                    fotos,
+			RespuestaAgencia:         r.RespuestaAgencia,
+			FechaRespuesta:           r.FechaRespuesta,
+			FechaSalida:              info[r.CompraID].FechaSeleccionada,
+			CreatedAt:                r.CreatedAt,
+		})
+	}
+
+	return items, &resumen, resumen.Total, nil
+}
+
+// recalcularRatings actualiza el promedio y la cantidad de reseñas visibles del paquete y de la agencia.
+func recalcularRatings(tx *gorm.DB, paqueteID uint, agenciaID uint) error {
+	if err := tx.Exec(`
+		UPDATE paquetes_turisticos p
+		SET rating_promedio = COALESCE(r.promedio, 0),
+		    total_resenas = r.total
+		FROM (
+		    SELECT ROUND(AVG(calificacion_general)::numeric, 2) AS promedio, COUNT(*) AS total
+		    FROM resenas
+		    WHERE paquete_id = ? AND oculta = FALSE
+		) r
+		WHERE p.id = ?
+	`, paqueteID, paqueteID).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		UPDATE agencias_turismo a
+		SET rating_promedio = COALESCE(r.promedio, 0),
+		    total_resenas = r.total
+		FROM (
+		    SELECT ROUND(AVG(calificacion_general)::numeric, 2) AS promedio, COUNT(*) AS total
+		    FROM resenas
+		    WHERE agencia_id = ? AND oculta = FALSE
+		) r
+		WHERE a.id = ?
+	`, agenciaID, agenciaID).Error
+}
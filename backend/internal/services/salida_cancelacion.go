@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// comprasActivasSalida son los estados de compra que ocupan cupos en una salida.
+var comprasActivasSalida = []string{"pendiente_confirmacion", "reservada_con_anticipo", "confirmada"}
+
+// avisoEmail es un email pendiente de envío; se envían después de confirmar la transacción.
+type avisoEmail func(email *EmailService) error
+
+// enviarAvisosEmail envía los avisos en segundo plano. Un fallo de SMTP no revierte la operación.
+func enviarAvisosEmail(avisos []avisoEmail) {
+	if len(avisos) == 0 {
+		return
+	}
+	go func() {
+		email := NewEmailService()
+		for _, aviso := range avisos {
+			if err := aviso(email); err != nil {
+				log.Printf("Error enviando email de salida: %v", err)
+			}
+		}
+	}()
+}
+
+// destinatarioTurista retorna email y nombre del turista ("" si no tiene email activo).
+func destinatarioTurista(tx *gorm.DB, turistaID uint) (string, string, error) {
+	var usuario models.Usuario
+	if err := tx.Select("id", "nombre", "email", "status").First(&usuario, turistaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	if usuario.Status != "active" {
+		return "", "", nil
+	}
+	return usuario.Email, usuario.Nombre, nil
+}
+
+// alternativasSalida busca hasta tres salidas futuras del mismo paquete con cupos para el grupo.
+func alternativasSalida(tx *gorm.DB, salida *models.PaqueteSalidaHabilitada, participantes int) ([]map[string]interface{}, error) {
+	var salidas []models.PaqueteSalidaHabilitada
+	if err := tx.
+		Where("paquete_id = ? AND id <> ?", salida.PaqueteID, salida.ID).
+		Where("tipo_salida = ? AND estado IN ('pendiente', 'activa')", "compartido").
+		Where("fecha_salida > ?", fechaHoyUTC().Format("2006-01-02")).
+		Where("cupo_maximo - cupos_reservados - cupos_confirmados - cupos_ofertados >= ?", participantes).
+		Order("fecha_salida ASC").
+		Limit(3).
+		Find(&salidas).Error; err != nil {
+		return nil, err
+	}
+
+	alternativas := make([]map[string]interface{}, 0, len(salidas))
+	for _, alt := range salidas {
+		alternativas = append(alternativas, map[string]interface{}{
+			"salida_id":         alt.ID,
+			"fecha_salida":      fechaSalidaString(alt.FechaSalida),
+			"cupos_disponibles": alt.CupoMaximo - alt.CuposReservados - alt.CuposConfirmados - alt.CuposOfertados,
+		})
+	}
+	return alternativas, nil
+}
+
+// cancelarCompraPorAgencia cancela una compra activa cuya salida la agencia no realizará:
+// registra el reembolso del 100% de lo pagado, libera sus cupos y rechaza los pagos aún sin revisar.
+// Retorna el reembolso (nil si la compra no tenía pagos confirmados) y los pagos rechazados.
+func cancelarCompraPorAgencia(tx *gorm.DB, compra *models.CompraPaquete, razon string) (*models.Reembolso, int, error) {
+	var reembolso *models.Reembolso
+	if compra.Status == "confirmada" || compra.Status == "reservada_con_anticipo" {
+		pagado, err := montoConfirmadoCompra(tx, compra.ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		fechaSalida, err := fechaSalidaCompra(tx, compra)
+		if err != nil {
+			return nil, 0, err
+		}
+		dias := int(fechaSalida.Sub(fechaHoyUTC()).Hours() / 24)
+		reembolso, err = registrarReembolso(tx, compra, "cancelacion_agencia", &razon, pagado, 100, &dias)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := NewInventarioService(tx).LiberarCompra(compra); err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.CompraPaquete{}).Where("id = ?", compra.ID).Updates(map[string]interface{}{
+		"status":        "cancelada",
+		"razon_rechazo": razon,
+		"fecha_rechazo": now,
+		"updated_at":    now,
+	}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Con la compra ya cancelada, fn_on_pago_rechazado no la vuelve a rechazar
+	res := tx.Model(&models.PagoCompra{}).
+		Where("compra_id = ? AND estado = ?", compra.ID, "pendiente").
+		Updates(map[string]interface{}{
+			"estado":        "rechazado",
+			"razon_rechazo": "Salida cancelada por la agencia: " + razon,
+		})
+	if res.Error != nil {
+		return nil, 0, res.Error
+	}
+
+	return reembolso, int(res.RowsAffected), nil
+}
+
+// cerrarListaEsperaSalida cancela las entradas vigentes de la lista de espera de una salida que no se realizará.
+func cerrarListaEsperaSalida(tx *gorm.DB, salida *models.PaqueteSalidaHabilitada, mensaje string) (int, error) {
+	var entradas []models.ListaEsperaSalida
+	if err := tx.Where("salida_id = ? AND estado IN ('esperando', 'ofertada')", salida.ID).Find(&entradas).Error; err != nil {
+		return 0, err
+	}
+	for i := range entradas {
+		entrada := &entradas[i]
+		if entrada.Estado == "ofertada" {
+			if err := liberarOfertaListaEspera(tx, entrada, "cancelada"); err != nil {
+				return 0, err
+			}
+		} else if err := tx.Model(&models.ListaEsperaSalida{}).Where("id = ?", entrada.ID).Updates(map[string]interface{}{
+			"estado":     "cancelada",
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return 0, err
+		}
+		if _, err := notificarUsuario(tx, entrada.TuristaID, models.TipoSalidaCancelada, "Salida cancelada", mensaje,
+			models.NotifDatosJSON{
+				"lista_espera_id": entrada.ID,
+				"salida_id":       salida.ID,
+				"paquete_id":      salida.PaqueteID,
+			}); err != nil {
+			return 0, err
+		}
+	}
+	return len(entradas), nil
+}
+
+// cancelarSalidaConCompras cancela la salida (ya bloqueada) y todo lo que depende de ella: compras con
+// reembolso total, lista de espera y guías. Avisa a cada turista con fechas alternativas y envía al
+// encargado el resumen de reembolsos adeudados. Los emails se retornan para enviarlos tras el commit.
+func cancelarSalidaConCompras(tx *gorm.DB, salida *models.PaqueteSalidaHabilitada, razon string, tituloResumen string) (*models.ResumenCancelacionSalida, []avisoEmail, error) {
+	if err := NewSalidaService(tx).validarTransicionEstado(salida.Estado, "cancelada"); err != nil {
+		return nil, nil, err
+	}
+
+	var paquete models.PaqueteTuristico
+	if err := tx.Select("id", "nombre").First(&paquete, salida.PaqueteID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(map[string]interface{}{
+		"estado":            "cancelada",
+		"razon_cancelacion": razon,
+		"updated_at":        time.Now(),
+	}).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var compras []models.CompraPaquete
+	if err := tx.Raw(`
+		SELECT * FROM compras_paquetes
+		WHERE salida_id = ? AND status IN ?
+		ORDER BY id
+		FOR UPDATE
+	`, salida.ID, comprasActivasSalida).Scan(&compras).Error; err != nil {
+		return nil, nil, err
+	}
+
+	resumen := &models.ResumenCancelacionSalida{SalidaID: salida.ID}
+	avisos := []avisoEmail{}
+	fecha := fechaSalidaString(salida.FechaSalida)
+
+	for i := range compras {
+		compra := &compras[i]
+		reembolso, rechazados, err := cancelarCompraPorAgencia(tx, compra, razon)
+		if err != nil {
+			return nil, nil, err
+		}
+		resumen.ComprasCanceladas++
+		resumen.PagosRechazados += rechazados
+
+		montoReembolso := 0.0
+		if reembolso != nil && reembolso.Estado == "pendiente" {
+			montoReembolso = reembolso.MontoReembolso
+			resumen.Reembolsos++
+			resumen.MontoReembolsos = redondearMonto(resumen.MontoReembolsos + montoReembolso)
+		}
+
+		alternativas, err := alternativasSalida(tx, salida, compra.TotalParticipantes)
+		if err != nil {
+			return nil, nil, err
+		}
+		fechas := []string{}
+		for _, alt := range alternativas {
+			fechas = append(fechas, alt["fecha_salida"].(string))
+		}
+
+		mensaje := fmt.Sprintf("La salida de \"%s\" del %s fue cancelada: %s.", paquete.Nombre, fecha, razon)
+		if montoReembolso > 0 {
+			mensaje += fmt.Sprintf(" La agencia te devolverá Bs %.2f.", montoReembolso)
+		}
+		if len(fechas) > 0 {
+			mensaje += " Fechas alternativas con cupos: " + strings.Join(fechas, ", ") + "."
+		}
+
+		datos := models.NotifDatosJSON{
+			"compra_id":      compra.ID,
+			"salida_id":      salida.ID,
+			"paquete_id":     compra.PaqueteID,
+			"paquete_nombre": paquete.Nombre,
+			"fecha_salida":   fecha,
+			"razon":          razon,
+			"alternativas":   alternativas,
+		}
+		if reembolso != nil {
+			datos["reembolso_id"] = reembolso.ID
+			datos["monto_reembolso"] = reembolso.MontoReembolso
+		}
+		if _, err := notificarUsuario(tx, compra.TuristaID, models.TipoSalidaCancelada, "Tu salida fue cancelada", mensaje, datos); err != nil {
+			return nil, nil, err
+		}
+
+		to, nombre, err := destinatarioTurista(tx, compra.TuristaID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if to != "" {
+			nombrePaquete := paquete.Nombre
+			avisos = append(avisos, func(email *EmailService) error {
+				return email.SendSalidaCancelada(to, nombre, nombrePaquete, fecha, razon, montoReembolso, fechas)
+			})
+		}
+	}
+
+	cerradas, err := cerrarListaEsperaSalida(tx, salida,
+		fmt.Sprintf("La salida de \"%s\" del %s en cuya lista de espera estabas fue cancelada", paquete.Nombre, fecha))
+	if err != nil {
+		return nil, nil, err
+	}
+	resumen.ListaEspera = cerradas
+
+	if err := notificarGuiasSalida(tx, salida.ID, models.TipoSalidaGuiaActualizada, "Salida cancelada",
+		fmt.Sprintf("La salida del %s fue cancelada: %s", fecha, razon)); err != nil {
+		return nil, nil, err
+	}
+
+	encargadoID, err := encargadoPrincipalDePaquete(tx, salida.PaqueteID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if encargadoID != 0 {
+		mensaje := fmt.Sprintf("La salida de \"%s\" del %s se canceló: %s. Compras canceladas: %d.", paquete.Nombre, fecha, razon, resumen.ComprasCanceladas)
+		if resumen.Reembolsos > 0 {
+			mensaje += fmt.Sprintf(" Reembolsos pendientes: %d por Bs %.2f.", resumen.Reembolsos, resumen.MontoReembolsos)
+		}
+		if resumen.PagosRechazados > 0 {
+			mensaje += fmt.Sprintf(" Se rechazaron %d pagos sin revisar; verifique si el dinero fue recibido.", resumen.PagosRechazados)
+		}
+		if _, err := notificarUsuario(tx, encargadoID, models.TipoResumenCancelacionSalida, tituloResumen, mensaje,
+			models.NotifDatosJSON{
+				"salida_id":          salida.ID,
+				"paquete_id":         salida.PaqueteID,
+				"compras_canceladas": resumen.ComprasCanceladas,
+				"reembolsos":         resumen.Reembolsos,
+				"monto_reembolsos":   resumen.MontoReembolsos,
+				"pagos_rechazados":   resumen.PagosRechazados,
+			}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return resumen, avisos, nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// ResultadoImportacionSalida es el resultado de un evento del archivo .ics importado.
+type ResultadoImportacionSalida struct {
+	UID         string `json:"uid,omitempty"`
+	Resumen     string `json:"resumen,omitempty"`
+	FechaSalida string `json:"fecha_salida,omitempty"`
+	SalidaID    *uint  `json:"salida_id,omitempty"`
+	// Duplicada indica un evento ya importado: SalidaID es la salida existente, no una nueva
+	Duplicada bool   `json:"duplicada,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportarSalidasICS crea una salida manual por cada evento del calendario con las mismas validaciones
+// de CrearSalidaManual. Los eventos cancelados y los ya importados (mismo UID en el paquete) se omiten; un
+// error en un evento no detiene el resto.
+func (s *SalidaService) ImportarSalidasICS(agenciaID, usuarioID, paqueteID uint, eventos []utils.ICalEvent, cupoMinimo, cupoMaximo int) ([]ResultadoImportacionSalida, error) {
+	var paquete models.PaqueteTuristico
+	if err := s.db.Select("id", "cupo_minimo", "cupo_maximo").First(&paquete, "id = ? AND agencia_id = ?", paqueteID, agenciaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("paquete no encontrado o no pertenece a esta agencia")
+		}
+		return nil, err
+	}
+	if cupoMaximo <= 0 {
+		cupoMaximo = paquete.CupoMaximo
+	}
+
+	resultados := make([]ResultadoImportacionSalida, 0, len(eventos))
+	for _, evento := range eventos {
+		resultado := ResultadoImportacionSalida{
+			UID:         evento.UID,
+			Resumen:     evento.Resumen,
+			FechaSalida: evento.Inicio.Format("2006-01-02"),
+		}
+		if evento.Cancelado {
+			resultado.Error = "evento cancelado, se omite"
+			resultados = append(resultados, resultado)
+			continue
+		}
+
+		uid := strings.TrimSpace(evento.UID)
+		if uid != "" {
+			var importada models.PaqueteSalidaHabilitada
+			err := s.db.Select("id").Where("paquete_id = ? AND ical_uid = ?", paqueteID, uid).First(&importada).Error
+			if err == nil {
+				resultado.SalidaID = &importada.ID
+				resultado.Duplicada = true
+				resultado.Error = "evento ya importado, se omite"
+				resultados = append(resultados, resultado)
+				continue
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+		}
+
+		req := CrearSalidaManualRequest{
+			FechaSalida: resultado.FechaSalida,
+			CupoMinimo:  cupoMinimo,
+			CupoMaximo:  cupoMaximo,
+		}
+		if uid != "" {
+			req.ICalUID = &uid
+		}
+		if !evento.TodoElDia {
+			hora := evento.Inicio.In(utils.ICalZona).Format("15:04")
+			req.HoraEncuentro = &hora
+		}
+		if lugar := strings.TrimSpace(evento.Lugar); lugar != "" {
+			req.PuntoEncuentro = &lugar
+		}
+		partes := []string{}
+		if resumen := strings.TrimSpace(evento.Resumen); resumen != "" {
+			partes = append(partes, resumen)
+		}
+		if descripcion := strings.TrimSpace(evento.Descripcion); descripcion != "" {
+			partes = append(partes, descripcion)
+		}
+		if len(partes) > 0 {
+			descripcion := strings.Join(partes, "\n\n")
+			req.Descripcion = &descripcion
+		}
+
+		salida, err := s.CrearSalidaManual(agenciaID, usuarioID, paqueteID, req)
+		if err != nil {
+			resultado.Error = err.Error()
+		} else {
+			resultado.SalidaID = &salida.ID
+		}
+		resultados = append(resultados, resultado)
+	}
+
+	return resultados, nil
+}
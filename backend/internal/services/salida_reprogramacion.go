@@ -0,0 +1,478 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ReprogramarSalida mueve una salida a otra fecha. Se crea la salida de destino con la misma configuración,
+// las compras y sus cupos pasan a ella y la original queda cancelada. Cada turista debe aceptar la nueva fecha
+// antes del plazo; si la rechaza o no responde, su compra se cancela con reembolso total.
+func (s *SalidaService) ReprogramarSalida(agenciaID, usuarioID, salidaID uint, req *models.ReprogramarSalidaRequest) (*models.ResultadoReprogramacionSalida, error) {
+	nuevaFecha, err := time.Parse("2006-01-02", strings.TrimSpace(req.NuevaFecha))
+	if err != nil {
+		return nil, errors.New("formato de nueva_fecha inválido (use YYYY-MM-DD)")
+	}
+	if nuevaFecha.Before(time.Now().AddDate(0, 0, 1)) {
+		return nil, errors.New("la nueva fecha debe ser al menos mañana")
+	}
+	fechaNueva := nuevaFecha.Format("2006-01-02")
+
+	diasRespuesta := req.DiasRespuesta
+	if diasRespuesta <= 0 {
+		diasRespuesta = 3
+	}
+	// Los turistas deben responder antes del día de la nueva salida
+	limite := time.Now().AddDate(0, 0, diasRespuesta)
+	inicioNuevaFecha := time.Date(nuevaFecha.Year(), nuevaFecha.Month(), nuevaFecha.Day(), 0, 0, 0, 0, time.Local)
+	if limite.After(inicioNuevaFecha) {
+		limite = inicioNuevaFecha
+	}
+
+	resultado := &models.ResultadoReprogramacionSalida{}
+	var avisos []avisoEmail
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		origen, err := BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil {
+			return err
+		}
+		if err := s.validarTransicionEstado(origen.Estado, "cancelada"); err != nil {
+			return fmt.Errorf("no se puede reprogramar una salida en estado '%s'", origen.Estado)
+		}
+
+		fechaAnterior := fechaSalidaString(origen.FechaSalida)
+		if fechaAnterior == fechaNueva {
+			return errors.New("la nueva fecha es igual a la actual")
+		}
+
+		bloqueada, err := FechaBloqueada(tx, agenciaID, origen.PaqueteID, fechaNueva)
+		if err != nil {
+			return err
+		}
+		if bloqueada {
+			return errors.New("la agencia tiene bloqueada esa fecha")
+		}
+
+		if origen.TipoSalida == "compartido" {
+			var existentes int64
+			if err := tx.Model(&models.PaqueteSalidaHabilitada{}).
+				Where("paquete_id = ? AND fecha_salida = ? AND tipo_salida = 'compartido' AND estado IN ('pendiente', 'activa')", origen.PaqueteID, fechaNueva).
+				Count(&existentes).Error; err != nil {
+				return err
+			}
+			if existentes > 0 {
+				return errors.New("ya existe una salida compartida habilitada para la nueva fecha")
+			}
+		}
+
+		var paquete models.PaqueteTuristico
+		if err := tx.Select("id", "nombre").First(&paquete, origen.PaqueteID).Error; err != nil {
+			return err
+		}
+
+		cerradas, err := cerrarListaEsperaSalida(tx, origen,
+			fmt.Sprintf("La salida de \"%s\" del %s en cuya lista de espera estabas se cambió al %s", paquete.Nombre, fechaAnterior, fechaNueva))
+		if err != nil {
+			return err
+		}
+		resultado.ListaEspera = cerradas
+
+		ocupados := origen.CuposReservados + origen.CuposConfirmados
+		cupoMaximo := origen.CupoMaximo
+		if ocupados > cupoMaximo {
+			cupoMaximo = ocupados
+		}
+		// Días que se corre la salida; se aplica a la fecha límite de inscripción y al vencimiento del saldo
+		desplazamiento := 0
+		if anterior, err := time.Parse("2006-01-02", fechaAnterior); err == nil {
+			desplazamiento = int(nuevaFecha.Sub(anterior).Hours() / 24)
+		}
+
+		horaEncuentro := origen.HoraEncuentro
+		if req.HoraEncuentro != nil {
+			horaEncuentro = req.HoraEncuentro
+		}
+		var limiteInscripcion *time.Time
+		if origen.FechaLimiteInscripcion != nil {
+			desplazada := origen.FechaLimiteInscripcion.AddDate(0, 0, desplazamiento)
+			limiteInscripcion = &desplazada
+		}
+
+		destino := &models.PaqueteSalidaHabilitada{
+			PaqueteID:                  origen.PaqueteID,
+			FechaSalida:                fechaNueva,
+			TipoSalida:                 origen.TipoSalida,
+			CupoMinimo:                 origen.CupoMinimo,
+			CupoMaximo:                 cupoMaximo,
+			PrecioBaseNacionales:       origen.PrecioBaseNacionales,
+			PrecioAdicionalExtranjeros: origen.PrecioAdicionalExtranjeros,
+			PuntoEncuentro:             origen.PuntoEncuentro,
+			HoraEncuentro:              horaEncuentro,
+			NotasLogistica:             origen.NotasLogistica,
+			InstruccionesTuristas:      origen.InstruccionesTuristas,
+			Estado:                     origen.Estado,
+			CreadaManualmente:          true,
+			CreadaPorUsuarioID:         &usuarioID,
+			FechaLimiteInscripcion:     limiteInscripcion,
+			DescripcionSalida:          origen.DescripcionSalida,
+			NotasInternas:              origen.NotasInternas,
+		}
+		if err := tx.Create(destino).Error; err != nil {
+			return fmt.Errorf("error al crear la salida reprogramada: %w", err)
+		}
+
+		razonOrigen := fmt.Sprintf("Reprogramada al %s: %s", fechaNueva, req.Razon)
+		if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", origen.ID).Updates(map[string]interface{}{
+			"estado":            "cancelada",
+			"razon_cancelacion": razonOrigen,
+			"updated_at":        time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		var compras []models.CompraPaquete
+		if err := tx.Raw(`
+			SELECT * FROM compras_paquetes
+			WHERE salida_id = ? AND status IN ?
+			ORDER BY id
+			FOR UPDATE
+		`, origen.ID, comprasActivasSalida).Scan(&compras).Error; err != nil {
+			return err
+		}
+
+		// Las compras y sus cupos pasan a la nueva salida
+		inventario := NewInventarioService(tx)
+		for i := range compras {
+			compra := &compras[i]
+			if err := inventario.LiberarCompra(compra); err != nil {
+				return err
+			}
+			if err := inventario.Reservar(destino.ID, compra.TotalParticipantes); err != nil {
+				return err
+			}
+			if compra.Status == "confirmada" {
+				if err := inventario.ConfirmarReservados(destino.ID, compra.TotalParticipantes); err != nil {
+					return err
+				}
+			}
+		}
+		if len(compras) > 0 {
+			if err := tx.Exec(`
+				UPDATE compras_paquetes
+				SET salida_id = ?,
+				    fecha_seleccionada = ?,
+				    fecha_limite_saldo = fecha_limite_saldo + ?::integer,
+				    updated_at = NOW()
+				WHERE salida_id = ? AND status IN ?
+			`, destino.ID, fechaNueva, desplazamiento, origen.ID, comprasActivasSalida).Error; err != nil {
+				return err
+			}
+		}
+
+		reprogramacion := &models.ReprogramacionSalida{
+			AgenciaID:            agenciaID,
+			SalidaOrigenID:       origen.ID,
+			SalidaDestinoID:      destino.ID,
+			FechaAnterior:        fechaAnterior,
+			FechaNueva:           fechaNueva,
+			Razon:                req.Razon,
+			FechaLimiteRespuesta: limite,
+			Estado:               "abierta",
+			CreadaPorID:          usuarioID,
+		}
+		if len(compras) == 0 {
+			reprogramacion.Estado = "cerrada"
+		}
+		if err := tx.Create(reprogramacion).Error; err != nil {
+			return err
+		}
+
+		limiteTexto := limite.Format("02/01/2006 15:04")
+		for _, compra := range compras {
+			respuesta := models.ReprogramacionCompra{
+				ReprogramacionID: reprogramacion.ID,
+				CompraID:         compra.ID,
+				TuristaID:        compra.TuristaID,
+				Estado:           "pendiente",
+			}
+			if err := tx.Create(&respuesta).Error; err != nil {
+				return err
+			}
+			reprogramacion.Compras = append(reprogramacion.Compras, respuesta)
+			resultado.ComprasMovidas++
+			resultado.CuposMovidos += compra.TotalParticipantes
+
+			mensaje := fmt.Sprintf("La salida de \"%s\" del %s se cambió al %s: %s. Acepta la nueva fecha o cancela con reembolso total antes del %s.",
+				paquete.Nombre, fechaAnterior, fechaNueva, req.Razon, limiteTexto)
+			if _, err := notificarUsuario(tx, compra.TuristaID, models.TipoSalidaReprogramada, "Cambio de fecha de tu salida", mensaje,
+				models.NotifDatosJSON{
+					"reprogramacion_compra_id": respuesta.ID,
+					"compra_id":                compra.ID,
+					"paquete_id":               compra.PaqueteID,
+					"paquete_nombre":           paquete.Nombre,
+					"salida_id":                destino.ID,
+					"fecha_anterior":           fechaAnterior,
+					"fecha_nueva":              fechaNueva,
+					"fecha_limite_respuesta":   limite,
+				}); err != nil {
+				return err
+			}
+
+			to, nombre, err := destinatarioTurista(tx, compra.TuristaID)
+			if err != nil {
+				return err
+			}
+			if to != "" {
+				nombrePaquete, razon := paquete.Nombre, req.Razon
+				avisos = append(avisos, func(email *EmailService) error {
+					return email.SendSalidaReprogramada(to, nombre, nombrePaquete, fechaAnterior, fechaNueva, razon, limiteTexto)
+				})
+			}
+		}
+
+		if err := notificarGuiasSalida(tx, origen.ID, models.TipoSalidaGuiaActualizada, "Salida reprogramada",
+			fmt.Sprintf("La salida del %s se cambió al %s. La asignación de guías no se traslada a la nueva fecha.", fechaAnterior, fechaNueva)); err != nil {
+			return err
+		}
+
+		encargadoID, err := encargadoPrincipalDePaquete(tx, origen.PaqueteID)
+		if err != nil {
+			return err
+		}
+		if encargadoID != 0 {
+			if _, err := notificarUsuario(tx, encargadoID, models.TipoSalidaReprogramada, "Salida reprogramada",
+				fmt.Sprintf("La salida de \"%s\" del %s pasó al %s con %d compras (%d cupos) a la espera de confirmación hasta el %s. Asigne guías y recursos a la nueva salida.",
+					paquete.Nombre, fechaAnterior, fechaNueva, resultado.ComprasMovidas, resultado.CuposMovidos, limiteTexto),
+				models.NotifDatosJSON{
+					"reprogramacion_id": reprogramacion.ID,
+					"salida_origen_id":  origen.ID,
+					"salida_destino_id": destino.ID,
+					"compras":           resultado.ComprasMovidas,
+					"cupos":             resultado.CuposMovidos,
+				}); err != nil {
+				return err
+			}
+		}
+
+		// Contadores de la nueva salida tal como quedaron tras mover los cupos
+		if err := tx.First(destino, destino.ID).Error; err != nil {
+			return err
+		}
+		resultado.Reprogramacion = reprogramacion
+		resultado.SalidaDestino = destino
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	enviarAvisosEmail(avisos)
+	return resultado, nil
+}
+
+// resolverReprogramacionCompra registra la respuesta (o el vencimiento) de una compra reprogramada.
+// Rechazada o vencida, la compra se cancela con reembolso total y sus cupos se ofrecen a la lista de espera.
+func resolverReprogramacionCompra(tx *gorm.DB, respuesta *models.ReprogramacionCompra, reprogramacion *models.ReprogramacionSalida, estado string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"estado":          estado,
+		"fecha_respuesta": now,
+		"updated_at":      now,
+	}
+
+	if estado != "aceptada" {
+		var compra models.CompraPaquete
+		if err := tx.Raw(`SELECT * FROM compras_paquetes WHERE id = ? FOR UPDATE`, respuesta.CompraID).Scan(&compra).Error; err != nil {
+			return err
+		}
+		// Si el turista ya canceló la compra por su cuenta no hay nada que devolver aquí
+		activa := false
+		for _, status := range comprasActivasSalida {
+			if compra.Status == status {
+				activa = true
+			}
+		}
+		if compra.ID != 0 && activa && compra.SalidaID != nil && *compra.SalidaID == reprogramacion.SalidaDestinoID {
+			razon := fmt.Sprintf("No aceptó el cambio de fecha del %s al %s", reprogramacion.FechaAnterior, reprogramacion.FechaNueva)
+			if estado == "vencida" {
+				razon = fmt.Sprintf("No respondió al cambio de fecha del %s al %s", reprogramacion.FechaAnterior, reprogramacion.FechaNueva)
+			}
+			reembolso, _, err := cancelarCompraPorAgencia(tx, &compra, razon)
+			if err != nil {
+				return err
+			}
+			if reembolso != nil {
+				updates["reembolso_id"] = reembolso.ID
+			}
+			if err := ofrecerCuposListaEspera(tx, reprogramacion.SalidaDestinoID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Model(&models.ReprogramacionCompra{}).Where("id = ?", respuesta.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	var pendientes int64
+	if err := tx.Model(&models.ReprogramacionCompra{}).
+		Where("reprogramacion_id = ? AND estado = ?", reprogramacion.ID, "pendiente").
+		Count(&pendientes).Error; err != nil {
+		return err
+	}
+	if pendientes == 0 {
+		return tx.Model(&models.ReprogramacionSalida{}).Where("id = ?", reprogramacion.ID).Updates(map[string]interface{}{
+			"estado":     "cerrada",
+			"updated_at": now,
+		}).Error
+	}
+	return nil
+}
+
+// ResponderReprogramacion registra si el turista acepta la nueva fecha de su salida.
+func (s *SalidaService) ResponderReprogramacion(turistaID, respuestaID uint, acepta bool) (*models.ReprogramacionCompra, error) {
+	var respuesta models.ReprogramacionCompra
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(`SELECT * FROM reprogramaciones_compras WHERE id = ? AND turista_id = ? FOR UPDATE`, respuestaID, turistaID).
+			Scan(&respuesta).Error; err != nil {
+			return err
+		}
+		if respuesta.ID == 0 {
+			return errors.New("reprogramación no encontrada")
+		}
+		if respuesta.Estado != "pendiente" {
+			return fmt.Errorf("la reprogramación ya fue respondida (%s)", respuesta.Estado)
+		}
+
+		var reprogramacion models.ReprogramacionSalida
+		if err := tx.First(&reprogramacion, respuesta.ReprogramacionID).Error; err != nil {
+			return err
+		}
+		if reprogramacion.FechaLimiteRespuesta.Before(time.Now()) {
+			return errors.New("el plazo para responder ya venció")
+		}
+
+		estado := "rechazada"
+		if acepta {
+			estado = "aceptada"
+		}
+		if err := resolverReprogramacionCompra(tx, &respuesta, &reprogramacion, estado); err != nil {
+			return err
+		}
+
+		var salida models.PaqueteSalidaHabilitada
+		if err := tx.Select("id", "paquete_id").First(&salida, reprogramacion.SalidaDestinoID).Error; err != nil {
+			return err
+		}
+		encargadoID, err := encargadoPrincipalDePaquete(tx, salida.PaqueteID)
+		if err != nil {
+			return err
+		}
+		if encargadoID != 0 {
+			mensaje := fmt.Sprintf("La compra #%d aceptó el cambio de fecha al %s", respuesta.CompraID, reprogramacion.FechaNueva)
+			if !acepta {
+				mensaje = fmt.Sprintf("La compra #%d no aceptó el cambio de fecha al %s y fue cancelada con reembolso total", respuesta.CompraID, reprogramacion.FechaNueva)
+			}
+			if _, err := notificarUsuario(tx, encargadoID, models.TipoReprogramacionRespuesta, "Respuesta a cambio de fecha", mensaje,
+				models.NotifDatosJSON{
+					"reprogramacion_id": reprogramacion.ID,
+					"compra_id":         respuesta.CompraID,
+					"salida_id":         reprogramacion.SalidaDestinoID,
+					"acepta":            acepta,
+				}); err != nil {
+				return err
+			}
+		}
+
+		return tx.First(&respuesta, respuesta.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &respuesta, nil
+}
+
+// ListarReprogramacionesTurista retorna las reprogramaciones de compras del turista (pendientes primero).
+func (s *SalidaService) ListarReprogramacionesTurista(turistaID uint) ([]models.ReprogramacionCompra, error) {
+	var respuestas []models.ReprogramacionCompra
+	if err := s.db.Preload("Reprogramacion").
+		Where("turista_id = ?", turistaID).
+		Order("CASE WHEN estado = 'pendiente' THEN 0 ELSE 1 END").
+		Order("created_at DESC").
+		Limit(50).
+		Find(&respuestas).Error; err != nil {
+		return nil, err
+	}
+	return respuestas, nil
+}
+
+// ObtenerReprogramacionesSalida retorna las reprogramaciones en que participa la salida (como origen o destino)
+// con la respuesta de cada compra.
+func (s *SalidaService) ObtenerReprogramacionesSalida(agenciaID, salidaID uint) ([]models.ReprogramacionSalida, error) {
+	var reprogramaciones []models.ReprogramacionSalida
+	if err := s.db.Preload("Compras", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).
+		Where("agencia_id = ? AND (salida_origen_id = ? OR salida_destino_id = ?)", agenciaID, salidaID, salidaID).
+		Order("created_at DESC").
+		Find(&reprogramaciones).Error; err != nil {
+		return nil, err
+	}
+	return reprogramaciones, nil
+}
+
+// ExpirarReprogramaciones cancela con reembolso las compras que no respondieron al cambio de fecha a tiempo.
+func (s *SalidaService) ExpirarReprogramaciones() (int64, error) {
+	var vencidas []models.ReprogramacionCompra
+	if err := s.db.
+		Joins("JOIN reprogramaciones_salidas r ON r.id = reprogramaciones_compras.reprogramacion_id").
+		Where("reprogramaciones_compras.estado = ? AND r.fecha_limite_respuesta < ?", "pendiente", time.Now()).
+		Find(&vencidas).Error; err != nil {
+		return 0, fmt.Errorf("error buscando reprogramaciones vencidas: %w", err)
+	}
+
+	var expiradas int64
+	for _, v := range vencidas {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var respuesta models.ReprogramacionCompra
+			if err := tx.Raw(`SELECT * FROM reprogramaciones_compras WHERE id = ? FOR UPDATE SKIP LOCKED`, v.ID).Scan(&respuesta).Error; err != nil {
+				return err
+			}
+			if respuesta.ID == 0 || respuesta.Estado != "pendiente" {
+				return nil
+			}
+
+			var reprogramacion models.ReprogramacionSalida
+			if err := tx.First(&reprogramacion, respuesta.ReprogramacionID).Error; err != nil {
+				return err
+			}
+			if err := resolverReprogramacionCompra(tx, &respuesta, &reprogramacion, "vencida"); err != nil {
+				return err
+			}
+
+			_, err := notificarUsuario(tx, respuesta.TuristaID, models.TipoSalidaCancelada, "Compra cancelada por cambio de fecha",
+				fmt.Sprintf("No respondiste al cambio de fecha del %s al %s; tu compra se canceló y la agencia te devolverá lo pagado",
+					reprogramacion.FechaAnterior, reprogramacion.FechaNueva),
+				models.NotifDatosJSON{
+					"reprogramacion_compra_id": respuesta.ID,
+					"compra_id":                respuesta.CompraID,
+				})
+			return err
+		})
+		if err != nil {
+			log.Printf("Error venciendo reprogramación %d: %v", v.ID, err)
+			continue
+		}
+		expiradas++
+	}
+
+	return expiradas, nil
+}
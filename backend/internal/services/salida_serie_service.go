@@ -0,0 +1,824 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// reglaRecurrencia es el subconjunto de RRULE que soportan las series de salidas.
+type reglaRecurrencia struct {
+	Frecuencia string // DAILY | WEEKLY
+	Intervalo  int
+	Dias       map[time.Weekday]bool
+	Meses      map[time.Month]bool
+}
+
+var diasRRULE = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// parseReglaRecurrencia interpreta una regla "FREQ=WEEKLY;INTERVAL=1;BYDAY=SA,SU;BYMONTH=6,7".
+func parseReglaRecurrencia(regla string) (*reglaRecurrencia, error) {
+	r := &reglaRecurrencia{Intervalo: 1, Dias: map[time.Weekday]bool{}, Meses: map[time.Month]bool{}}
+
+	value := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(regla)), "RRULE:")
+	if value == "" {
+		return nil, errors.New("la regla de recurrencia es obligatoria")
+	}
+	for _, parte := range strings.Split(value, ";") {
+		if strings.TrimSpace(parte) == "" {
+			continue
+		}
+		kv := strings.SplitN(parte, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("regla invalida: %s", parte)
+		}
+		clave, valor := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch clave {
+		case "FREQ":
+			if valor != "DAILY" && valor != "WEEKLY" {
+				return nil, errors.New("FREQ debe ser DAILY o WEEKLY")
+			}
+			r.Frecuencia = valor
+		case "INTERVAL":
+			n, err := strconv.Atoi(valor)
+			if err != nil || n < 1 || n > 52 {
+				return nil, errors.New("INTERVAL debe estar entre 1 y 52")
+			}
+			r.Intervalo = n
+		case "BYDAY":
+			for _, d := range strings.Split(valor, ",") {
+				dia, ok := diasRRULE[strings.TrimSpace(d)]
+				if !ok {
+					return nil, fmt.Errorf("dia invalido en BYDAY: %s (MO,TU,WE,TH,FR,SA,SU)", d)
+				}
+				r.Dias[dia] = true
+			}
+		case "BYMONTH":
+			for _, m := range strings.Split(valor, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(m))
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("mes invalido en BYMONTH: %s", m)
+				}
+				r.Meses[time.Month(n)] = true
+			}
+		default:
+			return nil, fmt.Errorf("parte de la regla no soportada: %s", clave)
+		}
+	}
+	if r.Frecuencia == "" {
+		return nil, errors.New("la regla debe indicar FREQ")
+	}
+	return r, nil
+}
+
+// coincide indica si la fecha pertenece a la serie que empieza en inicio.
+func (r *reglaRecurrencia) coincide(fecha, inicio time.Time) bool {
+	if fecha.Before(inicio) {
+		return false
+	}
+	if len(r.Meses) > 0 && !r.Meses[fecha.Month()] {
+		return false
+	}
+
+	dias := int(fecha.Sub(inicio).Hours() / 24)
+	switch r.Frecuencia {
+	case "DAILY":
+		if len(r.Dias) > 0 && !r.Dias[fecha.Weekday()] {
+			return false
+		}
+		return dias%r.Intervalo == 0
+	default: // WEEKLY
+		if len(r.Dias) == 0 {
+			if fecha.Weekday() != inicio.Weekday() {
+				return false
+			}
+		} else if !r.Dias[fecha.Weekday()] {
+			return false
+		}
+		// Semanas contadas desde el lunes de la semana de inicio
+		lunesInicio := inicio.AddDate(0, 0, -((int(inicio.Weekday()) + 6) % 7))
+		semanas := int(fecha.Sub(lunesInicio).Hours()/24) / 7
+		return semanas%r.Intervalo == 0
+	}
+}
+
+// FechaBloqueada indica si la agencia tiene bloqueada la fecha para el paquete.
+func FechaBloqueada(db *gorm.DB, agenciaID uint, paqueteID uint, fecha string) (bool, error) {
+	var count int64
+	err := db.Model(&models.AgenciaFechaBloqueada{}).
+		Where("agencia_id = ? AND (paquete_id IS NULL OR paquete_id = ?)", agenciaID, paqueteID).
+		Where("fecha_inicio <= ?::date AND fecha_fin >= ?::date", fecha, fecha).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// verificarCapacidadAgencia valida AgenciaCapacidad para una salida nueva en la fecha y horario dados.
+func verificarCapacidadAgencia(tx *gorm.DB, agenciaID uint, fecha string, horario string) error {
+	maxDia, maxHorario := 5, 3
+	var capacidad models.AgenciaCapacidad
+	if err := tx.Where("agencia_id = ?", agenciaID).First(&capacidad).Error; err == nil {
+		if capacidad.MaxSalidasPorDia > 0 {
+			maxDia = capacidad.MaxSalidasPorDia
+		}
+		if capacidad.MaxSalidasPorHorario > 0 {
+			maxHorario = capacidad.MaxSalidasPorHorario
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var conteo struct {
+		Dia     int64
+		Horario int64
+	}
+	if err := tx.Raw(`
+		SELECT COUNT(*) AS dia,
+		       COUNT(*) FILTER (WHERE COALESCE(p.horario, 'todo_dia') = ?) AS horario
+		FROM paquete_salidas_habilitadas s
+		JOIN paquetes_turisticos p ON p.id = s.paquete_id
+		WHERE p.agencia_id = ?
+		  AND s.fecha_salida = ?::date
+		  AND s.estado IN ('pendiente', 'activa')
+	`, horario, agenciaID, fecha).Scan(&conteo).Error; err != nil {
+		return err
+	}
+	if conteo.Dia >= int64(maxDia) {
+		return errors.New("la agencia alcanzó su máximo de salidas para ese día")
+	}
+	if conteo.Horario >= int64(maxHorario) {
+		return errors.New("la agencia alcanzó su máximo de salidas simultáneas para ese horario")
+	}
+	return nil
+}
+
+type SalidaSerieService struct {
+	db *gorm.DB
+}
+
+func NewSalidaSerieService(db *gorm.DB) *SalidaSerieService {
+	return &SalidaSerieService{db: db}
+}
+
+// CrearSerie valida y registra la serie y genera sus primeras salidas.
+func (s *SalidaSerieService) CrearSerie(agenciaID uint, usuarioID uint, req *models.CrearSalidaSerieRequest) (*models.SalidaSerie, *models.ResultadoGeneracionSerie, error) {
+	var paquete models.PaqueteTuristico
+	if err := s.db.Where("id = ? AND agencia_id = ?", req.PaqueteID, agenciaID).First(&paquete).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("paquete no encontrado o no pertenece a esta agencia")
+		}
+		return nil, nil, err
+	}
+	if paquete.Frecuencia == "salida_unica" {
+		return nil, nil, errors.New("los paquetes de salida única no admiten series recurrentes")
+	}
+
+	serie := models.SalidaSerie{
+		AgenciaID:             agenciaID,
+		PaqueteID:             paquete.ID,
+		Nombre:                strings.TrimSpace(req.Nombre),
+		Regla:                 strings.ToUpper(strings.TrimSpace(req.Regla)),
+		FechaInicio:           strings.TrimSpace(req.FechaInicio),
+		SemanasAnticipacion:   req.SemanasAnticipacion,
+		CupoMinimo:            req.CupoMinimo,
+		CupoMaximo:            req.CupoMaximo,
+		PuntoEncuentro:        req.PuntoEncuentro,
+		HoraEncuentro:         req.HoraEncuentro,
+		InstruccionesTuristas: req.InstruccionesTuristas,
+		NotasInternas:         req.NotasInternas,
+		Activa:                true,
+		CreadaPorID:           &usuarioID,
+	}
+	if serie.SemanasAnticipacion == 0 {
+		serie.SemanasAnticipacion = 8
+	}
+	if req.FechaFin != nil && strings.TrimSpace(*req.FechaFin) != "" {
+		fin := strings.TrimSpace(*req.FechaFin)
+		serie.FechaFin = &fin
+	}
+	if err := validarSerie(&serie, &paquete); err != nil {
+		return nil, nil, err
+	}
+
+	var resultado *models.ResultadoGeneracionSerie
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&serie).Error; err != nil {
+			return err
+		}
+		var err error
+		resultado, err = generarSalidasSerie(tx, &serie, fechaHoyUTC())
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &serie, resultado, nil
+}
+
+// validarSerie valida la regla, las fechas y los cupos de la serie contra el paquete.
+func validarSerie(serie *models.SalidaSerie, paquete *models.PaqueteTuristico) error {
+	if serie.Nombre == "" {
+		return errors.New("nombre es obligatorio")
+	}
+	if _, err := parseReglaRecurrencia(serie.Regla); err != nil {
+		return err
+	}
+	inicio, err := time.Parse("2006-01-02", serie.FechaInicio)
+	if err != nil {
+		return errors.New("fecha_inicio invalida (YYYY-MM-DD)")
+	}
+	if serie.FechaFin != nil {
+		fin, err := time.Parse("2006-01-02", *serie.FechaFin)
+		if err != nil {
+			return errors.New("fecha_fin invalida (YYYY-MM-DD)")
+		}
+		if fin.Before(inicio) {
+			return errors.New("fecha_fin debe ser posterior a fecha_inicio")
+		}
+	}
+
+	cupoMinimo, cupoMaximo := serie.CupoMinimo, serie.CupoMaximo
+	if cupoMinimo <= 0 {
+		cupoMinimo = paquete.CupoMinimo
+	}
+	if cupoMaximo <= 0 {
+		cupoMaximo = paquete.CupoMaximo
+	}
+	if cupoMinimo > cupoMaximo {
+		return errors.New("el cupo mínimo no puede ser mayor al cupo máximo")
+	}
+	return nil
+}
+
+// generarSalidasSerie materializa las salidas de la serie entre el último día generado y el horizonte.
+// Omite fechas bloqueadas, fechas con salida compartida existente y días sin capacidad de la agencia.
+func generarSalidasSerie(tx *gorm.DB, serie *models.SalidaSerie, hoy time.Time) (*models.ResultadoGeneracionSerie, error) {
+	resultado := &models.ResultadoGeneracionSerie{SerieID: serie.ID, Creadas: []string{}, Omitidas: []models.OcurrenciaOmitida{}}
+	if !serie.Activa {
+		return resultado, nil
+	}
+
+	regla, err := parseReglaRecurrencia(serie.Regla)
+	if err != nil {
+		return nil, err
+	}
+	var paquete models.PaqueteTuristico
+	if err := tx.First(&paquete, serie.PaqueteID).Error; err != nil {
+		return nil, err
+	}
+
+	inicioSerie, err := time.Parse("2006-01-02", fechaSalidaString(serie.FechaInicio))
+	if err != nil {
+		return nil, err
+	}
+	desde := hoy.AddDate(0, 0, paquete.DiasPreviosCompra)
+	if paquete.DiasPreviosCompra < 1 {
+		desde = hoy.AddDate(0, 0, 1)
+	}
+	if inicioSerie.After(desde) {
+		desde = inicioSerie
+	}
+	if serie.GeneradaHasta != nil {
+		if generada, err := time.Parse("2006-01-02", fechaSalidaString(*serie.GeneradaHasta)); err == nil && !generada.Before(desde) {
+			desde = generada.AddDate(0, 0, 1)
+		}
+	}
+	hasta := hoy.AddDate(0, 0, serie.SemanasAnticipacion*7)
+	if serie.FechaFin != nil {
+		if fin, err := time.Parse("2006-01-02", fechaSalidaString(*serie.FechaFin)); err == nil && fin.Before(hasta) {
+			hasta = fin
+		}
+	}
+	if desde.After(hasta) {
+		return resultado, nil
+	}
+
+	horario := "todo_dia"
+	if (paquete.DuracionDias == nil || *paquete.DuracionDias <= 1) && paquete.Horario != nil && strings.TrimSpace(*paquete.Horario) != "" {
+		horario = strings.TrimSpace(*paquete.Horario)
+	}
+	cupoMinimo, cupoMaximo := serie.CupoMinimo, serie.CupoMaximo
+	if cupoMinimo <= 0 {
+		cupoMinimo = paquete.CupoMinimo
+	}
+	if cupoMaximo <= 0 {
+		cupoMaximo = paquete.CupoMaximo
+	}
+
+	for dia := desde; !dia.After(hasta); dia = dia.AddDate(0, 0, 1) {
+		if !regla.coincide(dia, inicioSerie) {
+			continue
+		}
+		fecha := dia.Format("2006-01-02")
+
+		bloqueada, err := FechaBloqueada(tx, serie.AgenciaID, paquete.ID, fecha)
+		if err != nil {
+			return nil, err
+		}
+		if bloqueada {
+			resultado.Omitidas = append(resultado.Omitidas, models.OcurrenciaOmitida{Fecha: fecha, Motivo: "fecha bloqueada"})
+			continue
+		}
+
+		// Una ocurrencia cancelada de la propia serie no se vuelve a crear
+		var existentes int64
+		if err := tx.Model(&models.PaqueteSalidaHabilitada{}).
+			Where("paquete_id = ? AND fecha_salida = ?", paquete.ID, fecha).
+			Where("(tipo_salida = 'compartido' AND estado <> 'cancelada') OR serie_id = ?", serie.ID).
+			Count(&existentes).Error; err != nil {
+			return nil, err
+		}
+		if existentes > 0 {
+			resultado.Omitidas = append(resultado.Omitidas, models.OcurrenciaOmitida{Fecha: fecha, Motivo: "ya existe una salida para esa fecha"})
+			continue
+		}
+
+		if err := verificarCapacidadAgencia(tx, serie.AgenciaID, fecha, horario); err != nil {
+			resultado.Omitidas = append(resultado.Omitidas, models.OcurrenciaOmitida{Fecha: fecha, Motivo: err.Error()})
+			continue
+		}
+
+		serieID := serie.ID
+		salida := models.PaqueteSalidaHabilitada{
+			PaqueteID:             paquete.ID,
+			SerieID:               &serieID,
+			FechaSalida:           fecha,
+			TipoSalida:            "compartido",
+			CupoMinimo:            cupoMinimo,
+			CupoMaximo:            cupoMaximo,
+			Estado:                "pendiente",
+			CreadaManualmente:     true,
+			CreadaPorUsuarioID:    serie.CreadaPorID,
+			PuntoEncuentro:        serie.PuntoEncuentro,
+			HoraEncuentro:         serie.HoraEncuentro,
+			InstruccionesTuristas: serie.InstruccionesTuristas,
+			NotasInternas:         serie.NotasInternas,
+		}
+		if err := tx.Create(&salida).Error; err != nil {
+			return nil, err
+		}
+		resultado.Creadas = append(resultado.Creadas, fecha)
+	}
+
+	generadaHasta := hasta.Format("2006-01-02")
+	serie.GeneradaHasta = &generadaHasta
+	if err := tx.Model(&models.SalidaSerie{}).Where("id = ?", serie.ID).Update("generada_hasta", generadaHasta).Error; err != nil {
+		return nil, err
+	}
+	return resultado, nil
+}
+
+// bloquearSerie obtiene la serie de la agencia con bloqueo de fila.
+func bloquearSerie(tx *gorm.DB, agenciaID uint, serieID uint) (*models.SalidaSerie, error) {
+	var serie models.SalidaSerie
+	if err := tx.Raw(`SELECT * FROM salida_series WHERE id = ? AND agencia_id = ? FOR UPDATE`, serieID, agenciaID).
+		Scan(&serie).Error; err != nil {
+		return nil, err
+	}
+	if serie.ID == 0 {
+		return nil, errors.New("serie no encontrada")
+	}
+	normalizarFechasSerie(&serie)
+	return &serie, nil
+}
+
+// normalizarFechasSerie deja las fechas de la serie en formato YYYY-MM-DD.
+func normalizarFechasSerie(serie *models.SalidaSerie) {
+	serie.FechaInicio = fechaSalidaString(serie.FechaInicio)
+	if serie.FechaFin != nil {
+		fin := fechaSalidaString(*serie.FechaFin)
+		serie.FechaFin = &fin
+	}
+	if serie.GeneradaHasta != nil {
+		generada := fechaSalidaString(*serie.GeneradaHasta)
+		serie.GeneradaHasta = &generada
+	}
+}
+
+// Generar materializa ahora las salidas pendientes de la serie.
+func (s *SalidaSerieService) Generar(agenciaID uint, serieID uint) (*models.ResultadoGeneracionSerie, error) {
+	var resultado *models.ResultadoGeneracionSerie
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		serie, err := bloquearSerie(tx, agenciaID, serieID)
+		if err != nil {
+			return err
+		}
+		resultado, err = generarSalidasSerie(tx, serie, fechaHoyUTC())
+		return err
+	})
+	return resultado, err
+}
+
+// GenerarSalidasSeries materializa las salidas de todas las series activas. Lo ejecuta StartSalidaSerieWorker.
+func (s *SalidaSerieService) GenerarSalidasSeries() (int, error) {
+	var ids []uint
+	if err := s.db.Model(&models.SalidaSerie{}).Where("activa = ?", true).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	hoy := fechaHoyUTC()
+	creadas := 0
+	for _, id := range ids {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var serie models.SalidaSerie
+			if err := tx.Raw(`SELECT * FROM salida_series WHERE id = ? AND activa = TRUE FOR UPDATE SKIP LOCKED`, id).
+				Scan(&serie).Error; err != nil {
+				return err
+			}
+			if serie.ID == 0 {
+				return nil
+			}
+			normalizarFechasSerie(&serie)
+			resultado, err := generarSalidasSerie(tx, &serie, hoy)
+			if err != nil {
+				return err
+			}
+			creadas += len(resultado.Creadas)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error generando salidas de la serie %d: %v", id, err)
+		}
+	}
+	return creadas, nil
+}
+
+// ListarSeries lista las series de la agencia (opcionalmente de un paquete).
+func (s *SalidaSerieService) ListarSeries(agenciaID uint, paqueteID uint) ([]models.SalidaSerie, error) {
+	query := s.db.Preload("Paquete", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "nombre", "frecuencia", "horario", "duracion_dias")
+	}).Where("agencia_id = ?", agenciaID)
+	if paqueteID != 0 {
+		query = query.Where("paquete_id = ?", paqueteID)
+	}
+	series := []models.SalidaSerie{}
+	err := query.Order("activa DESC").Order("id DESC").Find(&series).Error
+	return series, err
+}
+
+// ObtenerSerie retorna la serie con sus próximas salidas.
+func (s *SalidaSerieService) ObtenerSerie(agenciaID uint, serieID uint) (map[string]interface{}, error) {
+	var serie models.SalidaSerie
+	if err := s.db.Where("id = ? AND agencia_id = ?", serieID, agenciaID).First(&serie).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("serie no encontrada")
+		}
+		return nil, err
+	}
+
+	salidas := []models.PaqueteSalidaHabilitada{}
+	if err := s.db.Where("serie_id = ? AND fecha_salida >= CURRENT_DATE", serie.ID).
+		Order("fecha_salida ASC").
+		Find(&salidas).Error; err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"serie":            serie,
+		"proximas_salidas": salidas,
+	}, nil
+}
+
+// salidasFuturasSerie bloquea las salidas vigentes de la serie posteriores a hoy, opcionalmente dentro de un rango.
+func salidasFuturasSerie(tx *gorm.DB, serieID uint, desde *string, hasta *string) ([]models.PaqueteSalidaHabilitada, error) {
+	query := `
+		SELECT * FROM paquete_salidas_habilitadas
+		WHERE serie_id = ?
+		  AND fecha_salida > CURRENT_DATE
+		  AND estado IN ('pendiente', 'activa')`
+	args := []interface{}{serieID}
+	if desde != nil {
+		query += ` AND fecha_salida >= ?::date`
+		args = append(args, *desde)
+	}
+	if hasta != nil {
+		query += ` AND fecha_salida <= ?::date`
+		args = append(args, *hasta)
+	}
+	query += ` ORDER BY fecha_salida ASC FOR UPDATE`
+
+	var salidas []models.PaqueteSalidaHabilitada
+	err := tx.Raw(query, args...).Scan(&salidas).Error
+	return salidas, err
+}
+
+// cancelarOcurrencia cancela una salida futura de la serie y avisa a sus guías.
+func cancelarOcurrencia(tx *gorm.DB, salida *models.PaqueteSalidaHabilitada, razon string) error {
+	if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(map[string]interface{}{
+		"estado":            "cancelada",
+		"razon_cancelacion": razon,
+		"updated_at":        time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	mensaje := fmt.Sprintf("La salida del %s fue cancelada: %s", fechaSalidaString(salida.FechaSalida), razon)
+	return notificarGuiasSalida(tx, salida.ID, models.TipoSalidaGuiaActualizada, "Salida cancelada", mensaje)
+}
+
+// ActualizarSerie modifica la serie y, si se pide, propaga los cambios a sus salidas futuras.
+// Las salidas con reservas no se cancelan por cambio de regla ni se les reduce el cupo por debajo de lo vendido.
+func (s *SalidaSerieService) ActualizarSerie(agenciaID uint, serieID uint, req *models.ActualizarSalidaSerieRequest) (*models.SalidaSerie, *models.ResultadoOperacionSerie, error) {
+	resultado := &models.ResultadoOperacionSerie{Actualizadas: []uint{}, Canceladas: []uint{}, Omitidas: []models.OcurrenciaOmitida{}}
+	var serie *models.SalidaSerie
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		serie, err = bloquearSerie(tx, agenciaID, serieID)
+		if err != nil {
+			return err
+		}
+		var paquete models.PaqueteTuristico
+		if err := tx.First(&paquete, serie.PaqueteID).Error; err != nil {
+			return err
+		}
+
+		reglaAnterior, finAnterior := serie.Regla, serie.FechaFin
+		if req.Nombre != nil {
+			serie.Nombre = strings.TrimSpace(*req.Nombre)
+		}
+		if req.Regla != nil {
+			serie.Regla = strings.ToUpper(strings.TrimSpace(*req.Regla))
+		}
+		if req.FechaFin != nil {
+			if fin := strings.TrimSpace(*req.FechaFin); fin == "" {
+				serie.FechaFin = nil
+			} else {
+				serie.FechaFin = &fin
+			}
+		}
+		if req.SemanasAnticipacion != nil {
+			serie.SemanasAnticipacion = *req.SemanasAnticipacion
+		}
+		if req.CupoMinimo != nil {
+			serie.CupoMinimo = *req.CupoMinimo
+		}
+		if req.CupoMaximo != nil {
+			serie.CupoMaximo = *req.CupoMaximo
+		}
+		if req.PuntoEncuentro != nil {
+			serie.PuntoEncuentro = req.PuntoEncuentro
+		}
+		if req.HoraEncuentro != nil {
+			serie.HoraEncuentro = req.HoraEncuentro
+		}
+		if req.InstruccionesTuristas != nil {
+			serie.InstruccionesTuristas = req.InstruccionesTuristas
+		}
+		if req.NotasInternas != nil {
+			serie.NotasInternas = req.NotasInternas
+		}
+		if err := validarSerie(serie, &paquete); err != nil {
+			return err
+		}
+
+		cambioRegla := serie.Regla != reglaAnterior ||
+			(serie.FechaFin == nil) != (finAnterior == nil) ||
+			(serie.FechaFin != nil && finAnterior != nil && *serie.FechaFin != *finAnterior)
+		if cambioRegla {
+			// Volver a generar desde hoy con la nueva regla
+			serie.GeneradaHasta = nil
+		}
+		if err := tx.Model(&models.SalidaSerie{}).Where("id = ?", serie.ID).Updates(map[string]interface{}{
+			"nombre":                 serie.Nombre,
+			"regla":                  serie.Regla,
+			"fecha_fin":              serie.FechaFin,
+			"semanas_anticipacion":   serie.SemanasAnticipacion,
+			"cupo_minimo":            serie.CupoMinimo,
+			"cupo_maximo":            serie.CupoMaximo,
+			"punto_encuentro":        serie.PuntoEncuentro,
+			"hora_encuentro":         serie.HoraEncuentro,
+			"instrucciones_turistas": serie.InstruccionesTuristas,
+			"notas_internas":         serie.NotasInternas,
+			"generada_hasta":         serie.GeneradaHasta,
+			"updated_at":             time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		if !req.AplicarAFuturas && !cambioRegla {
+			return nil
+		}
+
+		salidas, err := salidasFuturasSerie(tx, serie.ID, nil, nil)
+		if err != nil {
+			return err
+		}
+		regla, err := parseReglaRecurrencia(serie.Regla)
+		if err != nil {
+			return err
+		}
+		inicioSerie, err := time.Parse("2006-01-02", serie.FechaInicio)
+		if err != nil {
+			return err
+		}
+
+		for i := range salidas {
+			salida := &salidas[i]
+			fecha := fechaSalidaString(salida.FechaSalida)
+			ocupados := salida.CuposReservados + salida.CuposConfirmados + salida.CuposOfertados
+
+			if cambioRegla {
+				dia, err := time.Parse("2006-01-02", fecha)
+				if err != nil {
+					return err
+				}
+				fueraDeFin := false
+				if serie.FechaFin != nil {
+					fueraDeFin = fecha > *serie.FechaFin
+				}
+				if fueraDeFin || !regla.coincide(dia, inicioSerie) {
+					if ocupados > 0 {
+						resultado.Omitidas = append(resultado.Omitidas, models.OcurrenciaOmitida{Fecha: fecha, Motivo: "ya no coincide con la regla pero tiene reservas"})
+					} else {
+						if err := cancelarOcurrencia(tx, salida, "Cambio en la programación de la serie"); err != nil {
+							return err
+						}
+						resultado.Canceladas = append(resultado.Canceladas, salida.ID)
+						continue
+					}
+				}
+			}
+
+			if !req.AplicarAFuturas {
+				continue
+			}
+
+			updates := map[string]interface{}{}
+			cupoAmpliado := false
+			if req.CupoMaximo != nil {
+				cupoMaximo := *req.CupoMaximo
+				if cupoMaximo <= 0 {
+					cupoMaximo = paquete.CupoMaximo
+				}
+				if cupoMaximo < ocupados {
+					resultado.Omitidas = append(resultado.Omitidas, models.OcurrenciaOmitida{Fecha: fecha, Motivo: fmt.Sprintf("tiene %d cupos ocupados, mayor al nuevo cupo máximo", ocupados)})
+					continue
+				}
+				if err := validarCupoVehiculos(tx, salida.ID, cupoMaximo); err != nil {
+					resultado.Omitidas = append(resultado.Omitidas, models.OcurrenciaOmitida{Fecha: fecha, Motivo: err.Error()})
+					continue
+				}
+				if cupoMaximo != salida.CupoMaximo {
+					if err := NewInventarioService(tx).AjustarCupoMaximo(salida.ID, cupoMaximo); err != nil {
+						return err
+					}
+					cupoAmpliado = cupoMaximo > salida.CupoMaximo
+				}
+			}
+			if req.CupoMinimo != nil {
+				cupoMinimo := *req.CupoMinimo
+				if cupoMinimo <= 0 {
+					cupoMinimo = paquete.CupoMinimo
+				}
+				updates["cupo_minimo"] = cupoMinimo
+			}
+			if req.PuntoEncuentro != nil {
+				updates["punto_encuentro"] = req.PuntoEncuentro
+			}
+			if req.HoraEncuentro != nil {
+				updates["hora_encuentro"] = req.HoraEncuentro
+			}
+			if req.InstruccionesTuristas != nil {
+				updates["instrucciones_turistas"] = req.InstruccionesTuristas
+			}
+			if req.NotasInternas != nil {
+				updates["notas_internas"] = req.NotasInternas
+			}
+			if len(updates) == 0 && req.CupoMaximo == nil {
+				continue
+			}
+			updates["updated_at"] = time.Now()
+			if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", salida.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			if cambiaOperacionSalida(updates) {
+				mensaje := fmt.Sprintf("La salida del %s tuvo cambios de horario, punto de encuentro o estado. Revise los detalles.", fecha)
+				if err := notificarGuiasSalida(tx, salida.ID, models.TipoSalidaGuiaActualizada, "Cambios en una salida asignada", mensaje); err != nil {
+					return err
+				}
+			}
+			if cupoAmpliado {
+				if err := ofrecerCuposListaEspera(tx, salida.ID); err != nil {
+					return err
+				}
+			}
+			resultado.Actualizadas = append(resultado.Actualizadas, salida.ID)
+		}
+
+		if cambioRegla {
+			if _, err := generarSalidasSerie(tx, serie, fechaHoyUTC()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return serie, resultado, nil
+}
+
+// CancelarSerie cancela las salidas futuras de la serie en el rango. Sin Hasta, también desactiva la serie.
+// Las salidas con reservas no se cancelan aquí: se informan para cancelarlas individualmente.
+func (s *SalidaSerieService) CancelarSerie(agenciaID uint, serieID uint, req *models.CancelarSalidaSerieRequest) (*models.ResultadoOperacionSerie, error) {
+	resultado := &models.ResultadoOperacionSerie{Actualizadas: []uint{}, Canceladas: []uint{}, Omitidas: []models.OcurrenciaOmitida{}}
+
+	desde := fechaHoyUTC().AddDate(0, 0, 1).Format("2006-01-02")
+	if req.Desde != nil && strings.TrimSpace(*req.Desde) != "" {
+		value := strings.TrimSpace(*req.Desde)
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, errors.New("desde invalido (YYYY-MM-DD)")
+		}
+		desde = value
+	}
+	var hasta *string
+	if req.Hasta != nil && strings.TrimSpace(*req.Hasta) != "" {
+		value := strings.TrimSpace(*req.Hasta)
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, errors.New("hasta invalido (YYYY-MM-DD)")
+		}
+		if value < desde {
+			return nil, errors.New("hasta debe ser posterior a desde")
+		}
+		hasta = &value
+	}
+	razon := strings.TrimSpace(req.Razon)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		serie, err := bloquearSerie(tx, agenciaID, serieID)
+		if err != nil {
+			return err
+		}
+
+		salidas, err := salidasFuturasSerie(tx, serie.ID, &desde, hasta)
+		if err != nil {
+			return err
+		}
+		for i := range salidas {
+			salida := &salidas[i]
+			if salida.CuposReservados+salida.CuposConfirmados+salida.CuposOfertados > 0 {
+				resultado.Omitidas = append(resultado.Omitidas, models.OcurrenciaOmitida{
+					Fecha:  fechaSalidaString(salida.FechaSalida),
+					Motivo: "tiene reservas; debe cancelarse individualmente",
+				})
+				continue
+			}
+			if err := cancelarOcurrencia(tx, salida, razon); err != nil {
+				return err
+			}
+			resultado.Canceladas = append(resultado.Canceladas, salida.ID)
+		}
+
+		if hasta == nil {
+			return tx.Model(&models.SalidaSerie{}).Where("id = ?", serie.ID).Updates(map[string]interface{}{
+				"activa":     false,
+				"updated_at": time.Now(),
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultado, nil
+}
+
+// StartSalidaSerieWorker inicia un worker que genera periódicamente las salidas de las series recurrentes
+func StartSalidaSerieWorker(db *gorm.DB, intervaloChequeoMinutos int) {
+	if intervaloChequeoMinutos < 1 {
+		intervaloChequeoMinutos = 60
+	}
+
+	service := NewSalidaSerieService(db)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(intervaloChequeoMinutos) * time.Minute)
+		defer ticker.Stop()
+
+		log.Printf("Worker de series de salidas iniciado: chequea cada %d minutos", intervaloChequeoMinutos)
+
+		for {
+			generadas, err := service.GenerarSalidasSeries()
+			if err != nil {
+				log.Printf("Error generando salidas de series recurrentes: %v", err)
+			} else if generadas > 0 {
+				log.Printf("Worker de series de salidas: %d salidas generadas", generadas)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReglaRecurrencia(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		regla      string
+		frecuencia string
+		intervalo  int
+		dias       []time.Weekday
+		meses      []time.Month
+		err        string
+	}{
+		{nombre: "semanal con días y meses", regla: "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA,SU;BYMONTH=6,7",
+			frecuencia: "WEEKLY", intervalo: 2, dias: []time.Weekday{time.Saturday, time.Sunday}, meses: []time.Month{time.June, time.July}},
+		{nombre: "prefijo RRULE en minúsculas y espacios", regla: " rrule:freq=daily; byday=mo ;",
+			frecuencia: "DAILY", intervalo: 1, dias: []time.Weekday{time.Monday}},
+		{nombre: "diaria sin intervalo", regla: "FREQ=DAILY", frecuencia: "DAILY", intervalo: 1},
+		{nombre: "regla vacía", regla: "  ", err: "obligatoria"},
+		{nombre: "frecuencia mensual", regla: "FREQ=MONTHLY;BYMONTHDAY=-1", err: "FREQ debe ser DAILY o WEEKLY"},
+		{nombre: "sin FREQ", regla: "BYDAY=MO", err: "debe indicar FREQ"},
+		{nombre: "intervalo cero", regla: "FREQ=WEEKLY;INTERVAL=0", err: "INTERVAL"},
+		{nombre: "intervalo mayor a 52", regla: "FREQ=WEEKLY;INTERVAL=53", err: "INTERVAL"},
+		{nombre: "día inexistente", regla: "FREQ=WEEKLY;BYDAY=MO,XX", err: "dia invalido en BYDAY"},
+		{nombre: "mes 13", regla: "FREQ=DAILY;BYMONTH=13", err: "mes invalido en BYMONTH"},
+		{nombre: "parte sin valor", regla: "FREQ=DAILY;INTERVAL", err: "regla invalida"},
+		{nombre: "parte no soportada", regla: "FREQ=DAILY;COUNT=10", err: "no soportada: COUNT"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			r, err := parseReglaRecurrencia(c.regla)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("error = %v, se esperaba uno que contenga %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if r.Frecuencia != c.frecuencia || r.Intervalo != c.intervalo {
+				t.Errorf("frecuencia %s intervalo %d, se esperaba %s %d", r.Frecuencia, r.Intervalo, c.frecuencia, c.intervalo)
+			}
+			if len(r.Dias) != len(c.dias) || len(r.Meses) != len(c.meses) {
+				t.Fatalf("días %v meses %v, se esperaban %v %v", r.Dias, r.Meses, c.dias, c.meses)
+			}
+			for _, d := range c.dias {
+				if !r.Dias[d] {
+					t.Errorf("falta el día %s", d)
+				}
+			}
+			for _, m := range c.meses {
+				if !r.Meses[m] {
+					t.Errorf("falta el mes %s", m)
+				}
+			}
+		})
+	}
+}
+
+func TestReglaRecurrenciaCoincide(t *testing.T) {
+	dia := func(valor string) time.Time {
+		f, err := time.Parse("2006-01-02", valor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	casos := []struct {
+		nombre string
+		regla  string
+		inicio string
+		fecha  string
+		want   bool
+	}{
+		// 2026-11-04 es miércoles
+		{"semanal sin BYDAY en el día de inicio", "FREQ=WEEKLY", "2026-11-04", "2026-11-04", true},
+		{"semanal sin BYDAY una semana después", "FREQ=WEEKLY", "2026-11-04", "2026-11-11", true},
+		{"semanal sin BYDAY otro día", "FREQ=WEEKLY", "2026-11-04", "2026-11-05", false},
+		{"antes del inicio de la serie", "FREQ=WEEKLY", "2026-11-04", "2026-10-28", false},
+		{"cada dos semanas, sábado de la semana de inicio", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA,SU", "2026-11-04", "2026-11-07", true},
+		{"cada dos semanas, domingo de la semana de inicio", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA,SU", "2026-11-04", "2026-11-08", true},
+		{"cada dos semanas, semana intermedia", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA,SU", "2026-11-04", "2026-11-14", false},
+		{"cada dos semanas, tercera semana", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA,SU", "2026-11-04", "2026-11-21", true},
+		{"cada dos semanas, día fuera de BYDAY", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA,SU", "2026-11-04", "2026-11-16", false},
+		{"cada tres días", "FREQ=DAILY;INTERVAL=3", "2026-11-04", "2026-11-07", true},
+		{"cada tres días, día intermedio", "FREQ=DAILY;INTERVAL=3", "2026-11-04", "2026-11-06", false},
+		{"diaria con BYDAY", "FREQ=DAILY;BYDAY=MO", "2026-11-04", "2026-11-09", true},
+		{"diaria con BYDAY, otro día", "FREQ=DAILY;BYDAY=MO", "2026-11-04", "2026-11-10", false},
+		// Fin de mes y de año: el intervalo se cuenta en días corridos
+		{"cada dos días cruzando fin de mes", "FREQ=DAILY;INTERVAL=2", "2026-11-30", "2026-12-02", true},
+		{"cada dos días, primer día del mes", "FREQ=DAILY;INTERVAL=2", "2026-11-30", "2026-12-01", false},
+		{"cada dos días cruzando fin de año", "FREQ=DAILY;INTERVAL=2", "2026-12-31", "2027-01-02", true},
+		{"29 de febrero en año bisiesto", "FREQ=DAILY;BYMONTH=2", "2028-02-01", "2028-02-29", true},
+		{"día siguiente fuera de BYMONTH", "FREQ=DAILY;BYMONTH=2", "2028-02-01", "2028-03-01", false},
+		{"último domingo de enero", "FREQ=WEEKLY;BYDAY=SU;BYMONTH=1", "2026-12-01", "2027-01-31", true},
+		{"domingo de diciembre fuera de BYMONTH", "FREQ=WEEKLY;BYDAY=SU;BYMONTH=1", "2026-12-01", "2026-12-27", false},
+		{"primer domingo de febrero fuera de BYMONTH", "FREQ=WEEKLY;BYDAY=SU;BYMONTH=1", "2026-12-01", "2027-02-07", false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			r, err := parseReglaRecurrencia(c.regla)
+			if err != nil {
+				t.Fatalf("regla %q: %v", c.regla, err)
+			}
+			if got := r.coincide(dia(c.fecha), dia(c.inicio)); got != c.want {
+				t.Errorf("coincide(%s, inicio %s) = %v, se esperaba %v", c.fecha, c.inicio, got, c.want)
+			}
+		})
+	}
+}
@@ -53,6 +53,18 @@ func (s *SalidaService) CrearSalidaManual(agenciaID, usuarioID, paqueteID uint,
 		return nil, errors.New("el cupo mínimo no puede ser mayor al cupo máximo")
 	}
 
+	if err := validarPrecioSalida(req.PrecioBaseNacionales, req.PrecioAdicionalExtranjeros); err != nil {
+		return nil, err
+	}
+
+	bloqueada, err := FechaBloqueada(s.db, agenciaID, paqueteID, req.FechaSalida)
+	if err != nil {
+		return nil, err
+	}
+	if bloqueada {
+		return nil, errors.New("la agencia tiene bloqueada esa fecha")
+	}
+
 	// Validar que no exista ya una salida compartida para esa fecha
 	var existente models.PaqueteSalidaHabilitada
 	err = s.db.First(&existente,
@@ -85,6 +97,10 @@ func (s *SalidaService) CrearSalidaManual(agenciaID, usuarioID, paqueteID uint,
 		InstruccionesTuristas:  req.InstruccionesTuristas,
 		GuiaNombre:             req.GuiaNombre,
 		GuiaTelefono:           req.GuiaTelefono,
+
+		PrecioBaseNacionales:       req.PrecioBaseNacionales,
+		PrecioAdicionalExtranjeros: req.PrecioAdicionalExtranjeros,
+		ICalUID:                    req.ICalUID,
 	}
 
 	if err := s.db.Create(salida).Error; err != nil {
@@ -145,18 +161,16 @@ func (s *SalidaService) ActualizarSalida(agenciaID, salidaID uint, req Actualiza
 		return nil, err
 	}
 
-	// No permitir modificar salidas con compras confirmadas
-	if salida.CuposConfirmados > 0 && req.CupoMaximo != nil && *req.CupoMaximo < salida.CuposConfirmados {
-		return nil, errors.New("no se puede reducir el cupo máximo por debajo de los cupos ya confirmados")
+	// El cupo máximo se valida contra los cupos ocupados con la salida bloqueada (ver InventarioService)
+	if req.CupoMaximo != nil {
+		if err := validarCupoVehiculos(s.db, salida.ID, *req.CupoMaximo); err != nil {
+			return nil, err
+		}
 	}
 
 	// Actualizar campos permitidos
 	updates := make(map[string]interface{})
 
-	if req.CupoMaximo != nil {
-		updates["cupo_maximo"] = *req.CupoMaximo
-	}
-
 	if req.FechaLimiteInscripcion != nil {
 		updates["fecha_limite_inscripcion"] = req.FechaLimiteInscripcion
 	}
@@ -189,7 +203,20 @@ func (s *SalidaService) ActualizarSalida(agenciaID, salidaID uint, req Actualiza
 		updates["guia_telefono"] = req.GuiaTelefono
 	}
 
+	if req.PrecioBaseNacionales != nil || req.PrecioAdicionalExtranjeros != nil {
+		base, adicional, err := CombinarPrecioSalida(req.PrecioBaseNacionales, req.PrecioAdicionalExtranjeros, salida.PrecioBaseNacionales, salida.PrecioAdicionalExtranjeros)
+		if err != nil {
+			return nil, err
+		}
+		updates["precio_base_nacionales"] = base
+		updates["precio_adicional_extranjeros"] = adicional
+	}
+
 	if req.Estado != nil {
+		// La cancelación afecta compras y pagos: se hace por CancelarSalida (requiere razón)
+		if *req.Estado == "cancelada" {
+			return nil, errors.New("para cancelar la salida use la acción de cancelar e indique la razón")
+		}
 		// Validar transiciones de estado
 		if err := s.validarTransicionEstado(salida.Estado, *req.Estado); err != nil {
 			return nil, err
@@ -197,8 +224,31 @@ func (s *SalidaService) ActualizarSalida(agenciaID, salidaID uint, req Actualiza
 		updates["estado"] = *req.Estado
 	}
 
-	if len(updates) > 0 {
-		if err := s.db.Model(&salida).Updates(updates).Error; err != nil {
+	if len(updates) > 0 || req.CupoMaximo != nil {
+		cupoAnterior := salida.CupoMaximo
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			if req.CupoMaximo != nil {
+				if err := NewInventarioService(tx).AjustarCupoMaximo(salida.ID, *req.CupoMaximo); err != nil {
+					return err
+				}
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(&salida).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if cambiaOperacionSalida(updates) {
+				mensaje := fmt.Sprintf("La salida del %s tuvo cambios de horario, punto de encuentro o estado. Revise los detalles.", fechaSalidaString(salida.FechaSalida))
+				if err := notificarGuiasSalida(tx, salida.ID, models.TipoSalidaGuiaActualizada, "Cambios en una salida asignada", mensaje); err != nil {
+					return err
+				}
+			}
+			// Si se amplió el cupo, ofrecer los nuevos lugares a la lista de espera
+			if req.CupoMaximo != nil && *req.CupoMaximo > cupoAnterior {
+				return ofrecerCuposListaEspera(tx, salida.ID)
+			}
+			return nil
+		}); err != nil {
 			return nil, err
 		}
 
@@ -209,40 +259,90 @@ func (s *SalidaService) ActualizarSalida(agenciaID, salidaID uint, req Actualiza
 	return &salida, nil
 }
 
-// CancelarSalida cancela una salida
-func (s *SalidaService) CancelarSalida(agenciaID, salidaID uint, razon string) error {
-	var salida models.PaqueteSalidaHabilitada
+// CancelarSalida cancela una salida y sus compras: cada compra pasa a cancelada con reembolso total de lo
+// pagado, los turistas reciben notificación y email, y el encargado un resumen de los reembolsos adeudados.
+func (s *SalidaService) CancelarSalida(agenciaID, salidaID uint, razon string) (*models.ResumenCancelacionSalida, error) {
+	var resumen *models.ResumenCancelacionSalida
+	var avisos []avisoEmail
 
-	err := s.db.Joins("JOIN paquetes_turisticos ON paquete_salidas_habilitadas.paquete_id = paquetes_turisticos.id").
-		Where("paquete_salidas_habilitadas.id = ? AND paquetes_turisticos.agencia_id = ?", salidaID, agenciaID).
-		First(&salida).Error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		salida, err := BloquearSalidaAgencia(tx, agenciaID, salidaID)
+		if err != nil {
+			return err
+		}
 
+		switch salida.Estado {
+		case "cancelada":
+			return errors.New("la salida ya está cancelada")
+		case "completada":
+			return errors.New("no se puede cancelar una salida completada")
+		case "en_curso":
+			return errors.New("no se puede cancelar una salida en curso")
+		}
+
+		resumen, avisos, err = cancelarSalidaConCompras(tx, salida, razon, "Salida cancelada")
+		return err
+	})
 	if err != nil {
-		return errors.New("salida no encontrada")
+		return nil, err
 	}
 
-	if salida.Estado == "cancelada" {
-		return errors.New("la salida ya está cancelada")
-	}
+	enviarAvisosEmail(avisos)
+	return resumen, nil
+}
 
-	if salida.Estado == "completada" {
-		return errors.New("no se puede cancelar una salida completada")
+// cambiaOperacionSalida indica si la actualización afecta la operación que deben conocer los guías.
+func cambiaOperacionSalida(updates map[string]interface{}) bool {
+	for _, campo := range []string{"estado", "punto_encuentro", "hora_encuentro", "instrucciones_turistas", "notas_internas"} {
+		if _, ok := updates[campo]; ok {
+			return true
+		}
 	}
+	return false
+}
 
-	// TODO: Aquí se debería notificar a los turistas afectados
-	// y procesar devoluciones si hay compras confirmadas
+// validarPrecioSalida valida el precio especial de una salida.
+// El recargo de extranjeros solo puede definirse junto con un precio base.
+func validarPrecioSalida(base, adicional *float64) error {
+	if base != nil && *base <= 0 {
+		return errors.New("el precio base de la salida debe ser mayor a 0")
+	}
+	if adicional != nil {
+		if *adicional < 0 {
+			return errors.New("el precio adicional para extranjeros no puede ser negativo")
+		}
+		if base == nil {
+			return errors.New("debe definir precio_base_nacionales para fijar el precio adicional de extranjeros")
+		}
+	}
+	return nil
+}
 
-	return s.db.Model(&salida).Updates(map[string]interface{}{
-		"estado":            "cancelada",
-		"razon_cancelacion": razon,
-	}).Error
+// CombinarPrecioSalida combina el precio especial actual de una salida con el enviado y lo valida.
+// precio_base_nacionales = 0 elimina el precio especial.
+func CombinarPrecioSalida(reqBase, reqAdicional, actualBase, actualAdicional *float64) (*float64, *float64, error) {
+	base, adicional := actualBase, actualAdicional
+	if reqBase != nil {
+		if *reqBase == 0 {
+			return nil, nil, nil
+		}
+		base = reqBase
+	}
+	if reqAdicional != nil {
+		adicional = reqAdicional
+	}
+	if err := validarPrecioSalida(base, adicional); err != nil {
+		return nil, nil, err
+	}
+	return base, adicional, nil
 }
 
 // validarTransicionEstado valida que la transición de estado sea válida
 func (s *SalidaService) validarTransicionEstado(estadoActual, estadoNuevo string) error {
 	transicionesValidas := map[string][]string{
 		"pendiente":  {"activa", "cancelada"},
-		"activa":     {"completada", "cancelada"},
+		"activa":     {"en_curso", "completada", "cancelada"},
+		"en_curso":   {"completada"},
 		"completada": {}, // No se puede cambiar desde completada
 		"cancelada":  {}, // No se puede cambiar desde cancelada
 	}
@@ -274,6 +374,11 @@ type CrearSalidaManualRequest struct {
 	InstruccionesTuristas  *string    `json:"instrucciones_turistas,omitempty"`
 	GuiaNombre             *string    `json:"guia_nombre,omitempty"`
 	GuiaTelefono           *string    `json:"guia_telefono,omitempty"`
+	// Precio especial de la salida (opcional, sobre la temporada y el precio base del paquete)
+	PrecioBaseNacionales       *float64 `json:"precio_base_nacionales,omitempty"`
+	PrecioAdicionalExtranjeros *float64 `json:"precio_adicional_extranjeros,omitempty"`
+	// UID del evento .ics (solo ImportarSalidasICS)
+	ICalUID *string `json:"-"`
 }
 
 type ActualizarSalidaRequest struct {
@@ -287,6 +392,9 @@ type ActualizarSalidaRequest struct {
 	GuiaNombre             *string    `json:"guia_nombre,omitempty"`
 	GuiaTelefono           *string    `json:"guia_telefono,omitempty"`
 	Estado                 *string    `json:"estado,omitempty"`
+	// 0 quita el precio especial de la salida
+	PrecioBaseNacionales       *float64 `json:"precio_base_nacionales,omitempty"`
+	PrecioAdicionalExtranjeros *float64 `json:"precio_adicional_extranjeros,omitempty"`
 }
 
 type SalidaFiltros struct {
@@ -0,0 +1,597 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/database"
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const diasValidezCotizacionDefault = 3
+
+var ErrSolicitudPrivadaNoEncontrada = errors.New("solicitud no encontrada")
+
+type SolicitudPrivadaService struct {
+	db *gorm.DB
+}
+
+func NewSolicitudPrivadaService(db *gorm.DB) *SolicitudPrivadaService {
+	return &SolicitudPrivadaService{db: db}
+}
+
+func parseFechaSolicitud(valor string, campo string) (time.Time, error) {
+	fecha, err := time.Parse("2006-01-02", strings.TrimSpace(valor))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s inválida (use YYYY-MM-DD)", campo)
+	}
+	return fecha, nil
+}
+
+// validarFechaSolicitud aplica los días previos de compra del paquete a una fecha propuesta o cotizada.
+func validarFechaSolicitud(paquete *models.PaqueteTuristico, fecha time.Time) error {
+	minimo := fechaHoyUTC().AddDate(0, 0, paquete.DiasPreviosCompra)
+	if fecha.Before(minimo) {
+		return fmt.Errorf("la fecha %s no cumple los días previos de compra del paquete", fecha.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// agregarMensajeSolicitud registra una entrada en el hilo de la solicitud.
+func agregarMensajeSolicitud(tx *gorm.DB, solicitudID uint, autorID uint, autorRol, tipo string, mensaje *string) (*models.SolicitudPrivadaMensaje, error) {
+	if mensaje != nil {
+		texto := strings.TrimSpace(*mensaje)
+		if texto == "" {
+			mensaje = nil
+		} else {
+			mensaje = &texto
+		}
+	}
+	entrada := models.SolicitudPrivadaMensaje{
+		SolicitudID: solicitudID,
+		AutorID:     autorID,
+		AutorRol:    autorRol,
+		Tipo:        tipo,
+		Mensaje:     mensaje,
+	}
+	if err := tx.Create(&entrada).Error; err != nil {
+		return nil, err
+	}
+	return &entrada, nil
+}
+
+// notificarContraparteSolicitud notifica al turista (si escribe la agencia) o al encargado principal de la agencia.
+func notificarContraparteSolicitud(tx *gorm.DB, solicitud *models.SolicitudPrivada, autorRol, tipo, titulo, mensaje string) error {
+	destinatarioID := solicitud.TuristaID
+	if autorRol == "turista" {
+		encargadoID, err := encargadoPrincipalDePaquete(tx, solicitud.PaqueteID)
+		if err != nil {
+			return err
+		}
+		if encargadoID == 0 {
+			return nil
+		}
+		destinatarioID = encargadoID
+	}
+	_, err := notificarUsuario(tx, destinatarioID, tipo, titulo, mensaje, models.NotifDatosJSON{
+		"solicitud_privada_id": solicitud.ID,
+		"paquete_id":           solicitud.PaqueteID,
+		"agencia_id":           solicitud.AgenciaID,
+	})
+	return err
+}
+
+// bloquearSolicitud obtiene y bloquea la solicitud del turista (agenciaID = 0) o de la agencia (turistaID = 0).
+func bloquearSolicitud(tx *gorm.DB, solicitudID uint, turistaID uint, agenciaID uint) (*models.SolicitudPrivada, error) {
+	query := `SELECT * FROM solicitudes_privadas WHERE id = ?`
+	args := []interface{}{solicitudID}
+	if turistaID > 0 {
+		query += ` AND turista_id = ?`
+		args = append(args, turistaID)
+	}
+	if agenciaID > 0 {
+		query += ` AND agencia_id = ?`
+		args = append(args, agenciaID)
+	}
+
+	var solicitud models.SolicitudPrivada
+	if err := tx.Raw(query+` FOR UPDATE`, args...).Scan(&solicitud).Error; err != nil {
+		return nil, err
+	}
+	if solicitud.ID == 0 {
+		return nil, ErrSolicitudPrivadaNoEncontrada
+	}
+	return &solicitud, nil
+}
+
+func solicitudAbierta(estado string) bool {
+	return estado == "pendiente" || estado == "cotizada"
+}
+
+// CrearSolicitud registra el pedido de cotización privada del turista.
+func (s *SolicitudPrivadaService) CrearSolicitud(turistaID uint, req *models.CrearSolicitudPrivadaRequest) (*models.SolicitudPrivada, error) {
+	fecha, err := parseFechaSolicitud(req.FechaPropuesta, "fecha_propuesta")
+	if err != nil {
+		return nil, err
+	}
+
+	var paquete models.PaqueteTuristico
+	if err := s.db.Preload("Agencia").First(&paquete, req.PaqueteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("paquete no encontrado")
+		}
+		return nil, err
+	}
+	if paquete.Status != "activo" || !paquete.VisiblePublico || paquete.Agencia == nil ||
+		paquete.Agencia.Status != "activa" || !paquete.Agencia.VisiblePublico {
+		return nil, errors.New("el paquete no está disponible")
+	}
+	if !paquete.PermitePrivado {
+		return nil, errors.New("este paquete no permite compras privadas")
+	}
+	if paquete.Frecuencia != "salida_diaria" {
+		return nil, errors.New("el tipo privado solo está disponible para paquetes de salida diaria")
+	}
+	if err := validarFechaSolicitud(&paquete, fecha); err != nil {
+		return nil, err
+	}
+
+	alternativas := models.StringArray{}
+	for _, alt := range req.FechasAlternativas {
+		f, err := parseFechaSolicitud(alt, "fechas_alternativas")
+		if err != nil {
+			return nil, err
+		}
+		if err := validarFechaSolicitud(&paquete, f); err != nil {
+			return nil, err
+		}
+		alternativas = append(alternativas, f.Format("2006-01-02"))
+	}
+
+	total := req.CantidadAdultos + req.CantidadNinosPagan + req.CantidadNinosGratis
+	if total > paquete.CupoMaximo {
+		return nil, errors.New("la cantidad de participantes excede el cupo máximo del paquete")
+	}
+
+	solicitud := models.SolicitudPrivada{
+		PaqueteID:               paquete.ID,
+		AgenciaID:               paquete.AgenciaID,
+		TuristaID:               turistaID,
+		FechaPropuesta:          fecha,
+		FechasAlternativas:      alternativas,
+		CantidadAdultos:         req.CantidadAdultos,
+		CantidadNinosPagan:      req.CantidadNinosPagan,
+		CantidadNinosGratis:     req.CantidadNinosGratis,
+		TotalParticipantes:      total,
+		Extranjero:              req.Extranjero,
+		NecesidadesEspeciales:   req.NecesidadesEspeciales,
+		TieneDiscapacidad:       req.TieneDiscapacidad,
+		DescripcionDiscapacidad: req.DescripcionDiscapacidad,
+		Estado:                  "pendiente",
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&solicitud).Error; err != nil {
+			return err
+		}
+		if _, err := agregarMensajeSolicitud(tx, solicitud.ID, turistaID, "turista", "solicitud", req.Mensaje); err != nil {
+			return err
+		}
+		return notificarContraparteSolicitud(tx, &solicitud, "turista", models.TipoSolicitudPrivadaNueva,
+			"Nueva solicitud de tour privado",
+			fmt.Sprintf("Un turista solicita una cotización privada de \"%s\" para %d personas el %s",
+				paquete.Nombre, total, fecha.Format("2006-01-02")))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.obtener(solicitud.ID, 0, 0)
+}
+
+// ListarTurista retorna las solicitudes privadas del turista.
+func (s *SolicitudPrivadaService) ListarTurista(turistaID uint) ([]models.SolicitudPrivada, error) {
+	var solicitudes []models.SolicitudPrivada
+	if err := s.db.
+		Preload("Paquete").
+		Where("turista_id = ?", turistaID).
+		Order("updated_at DESC").
+		Find(&solicitudes).Error; err != nil {
+		return nil, err
+	}
+	return solicitudes, nil
+}
+
+// ListarAgencia retorna las solicitudes privadas recibidas por la agencia, opcionalmente filtradas por estado.
+func (s *SolicitudPrivadaService) ListarAgencia(agenciaID uint, estado string) ([]models.SolicitudPrivada, error) {
+	query := s.db.
+		Preload("Paquete").
+		Preload("Turista").
+		Where("agencia_id = ?", agenciaID)
+	if estado != "" {
+		query = query.Where("estado = ?", estado)
+	}
+
+	var solicitudes []models.SolicitudPrivada
+	if err := query.Order("updated_at DESC").Find(&solicitudes).Error; err != nil {
+		return nil, err
+	}
+	return solicitudes, nil
+}
+
+// ObtenerTurista retorna una solicitud del turista con su hilo.
+func (s *SolicitudPrivadaService) ObtenerTurista(solicitudID uint, turistaID uint) (*models.SolicitudPrivada, error) {
+	return s.obtener(solicitudID, turistaID, 0)
+}
+
+// ObtenerAgencia retorna una solicitud de la agencia con su hilo.
+func (s *SolicitudPrivadaService) ObtenerAgencia(solicitudID uint, agenciaID uint) (*models.SolicitudPrivada, error) {
+	return s.obtener(solicitudID, 0, agenciaID)
+}
+
+func (s *SolicitudPrivadaService) obtener(solicitudID uint, turistaID uint, agenciaID uint) (*models.SolicitudPrivada, error) {
+	query := s.db.
+		Preload("Paquete").
+		Preload("Turista").
+		Preload("Mensajes", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC").Order("id ASC")
+		}).
+		Where("id = ?", solicitudID)
+	if turistaID > 0 {
+		query = query.Where("turista_id = ?", turistaID)
+	}
+	if agenciaID > 0 {
+		query = query.Where("agencia_id = ?", agenciaID)
+	}
+
+	var solicitud models.SolicitudPrivada
+	if err := query.First(&solicitud).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSolicitudPrivadaNoEncontrada
+		}
+		return nil, err
+	}
+	return &solicitud, nil
+}
+
+// Cotizar registra (o reemplaza) la cotización de la agencia para una solicitud abierta.
+func (s *SolicitudPrivadaService) Cotizar(agenciaID uint, solicitudID uint, usuarioID uint, req *models.CotizarSolicitudPrivadaRequest) (*models.SolicitudPrivada, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		solicitud, err := bloquearSolicitud(tx, solicitudID, 0, agenciaID)
+		if err != nil {
+			return err
+		}
+		if !solicitudAbierta(solicitud.Estado) {
+			return fmt.Errorf("no se puede cotizar una solicitud %s", solicitud.Estado)
+		}
+
+		var paquete models.PaqueteTuristico
+		if err := tx.First(&paquete, solicitud.PaqueteID).Error; err != nil {
+			return err
+		}
+
+		fecha := solicitud.FechaPropuesta
+		if req.FechaCotizada != nil && strings.TrimSpace(*req.FechaCotizada) != "" {
+			fecha, err = parseFechaSolicitud(*req.FechaCotizada, "fecha_cotizada")
+			if err != nil {
+				return err
+			}
+		}
+		if err := validarFechaSolicitud(&paquete, fecha); err != nil {
+			return err
+		}
+
+		dias := req.DiasValidez
+		if dias <= 0 {
+			dias = diasValidezCotizacionDefault
+		}
+		now := time.Now()
+		expira := now.AddDate(0, 0, dias)
+		// La cotización no puede seguir vigente después del plazo de compra de la fecha cotizada. Ese plazo
+		// incluye todo el último día de compra (que puede ser hoy): vence al final de ese día
+		if limite := fecha.AddDate(0, 0, 1-paquete.DiasPreviosCompra).Add(-time.Second); limite.Before(expira) {
+			expira = limite
+		}
+		precio := redondearMonto(req.PrecioTotal)
+
+		if err := tx.Model(&models.SolicitudPrivada{}).Where("id = ?", solicitud.ID).Updates(map[string]interface{}{
+			"estado":                 "cotizada",
+			"fecha_cotizada":         fecha,
+			"precio_cotizado":        precio,
+			"condiciones_cotizacion": req.Condiciones,
+			"cotizacion_expira_en":   expira,
+			"cotizada_por_id":        usuarioID,
+			"fecha_cotizacion":       now,
+			"updated_at":             now,
+		}).Error; err != nil {
+			return err
+		}
+
+		entrada, err := agregarMensajeSolicitud(tx, solicitud.ID, usuarioID, "agencia", "cotizacion", req.Mensaje)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(entrada).Updates(map[string]interface{}{
+			"fecha_cotizada":  fecha,
+			"precio_cotizado": precio,
+			"expira_en":       expira,
+		}).Error; err != nil {
+			return err
+		}
+
+		return notificarContraparteSolicitud(tx, solicitud, "agencia", models.TipoSolicitudPrivadaCotizada,
+			"Recibiste una cotización para tu tour privado",
+			fmt.Sprintf("La agencia cotizó tu tour privado para el %s en %.2f. La cotización vence el %s",
+				fecha.Format("2006-01-02"), precio, expira.Format("2006-01-02 15:04")))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.obtener(solicitudID, 0, agenciaID)
+}
+
+// AgregarMensaje agrega un mensaje al hilo de una solicitud abierta.
+// autorRol es turista (turistaID = autorID) o agencia (agenciaID indica la agencia).
+func (s *SolicitudPrivadaService) AgregarMensaje(solicitudID uint, autorID uint, autorRol string, agenciaID uint, mensaje string) (*models.SolicitudPrivada, error) {
+	turistaID := uint(0)
+	if autorRol == "turista" {
+		turistaID = autorID
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		solicitud, err := bloquearSolicitud(tx, solicitudID, turistaID, agenciaID)
+		if err != nil {
+			return err
+		}
+		if !solicitudAbierta(solicitud.Estado) {
+			return fmt.Errorf("la solicitud está %s", solicitud.Estado)
+		}
+		if _, err := agregarMensajeSolicitud(tx, solicitud.ID, autorID, autorRol, "mensaje", &mensaje); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SolicitudPrivada{}).Where("id = ?", solicitud.ID).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		titulo := "Nuevo mensaje de la agencia sobre tu tour privado"
+		if autorRol == "turista" {
+			titulo = "Nuevo mensaje en una solicitud de tour privado"
+		}
+		return notificarContraparteSolicitud(tx, solicitud, autorRol, models.TipoSolicitudPrivadaMensaje, titulo, mensaje)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.obtener(solicitudID, turistaID, agenciaID)
+}
+
+// Cerrar rechaza (agencia) o cancela (turista) una solicitud abierta.
+func (s *SolicitudPrivadaService) Cerrar(solicitudID uint, autorID uint, autorRol string, agenciaID uint, motivo *string) (*models.SolicitudPrivada, error) {
+	turistaID := uint(0)
+	estado, tipo := "rechazada", "rechazo"
+	titulo := "La agencia rechazó tu solicitud de tour privado"
+	tipoNotif := models.TipoSolicitudPrivadaRechazada
+	if autorRol == "turista" {
+		turistaID = autorID
+		estado, tipo = "cancelada", "cancelacion"
+		titulo = "El turista canceló una solicitud de tour privado"
+		tipoNotif = models.TipoSolicitudPrivadaCancelada
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		solicitud, err := bloquearSolicitud(tx, solicitudID, turistaID, agenciaID)
+		if err != nil {
+			return err
+		}
+		if !solicitudAbierta(solicitud.Estado) {
+			return fmt.Errorf("la solicitud ya está %s", solicitud.Estado)
+		}
+		if err := tx.Model(&models.SolicitudPrivada{}).Where("id = ?", solicitud.ID).Updates(map[string]interface{}{
+			"estado":     estado,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if _, err := agregarMensajeSolicitud(tx, solicitud.ID, autorID, autorRol, tipo, motivo); err != nil {
+			return err
+		}
+
+		mensaje := titulo
+		if motivo != nil && strings.TrimSpace(*motivo) != "" {
+			mensaje = fmt.Sprintf("%s: %s", titulo, strings.TrimSpace(*motivo))
+		}
+		return notificarContraparteSolicitud(tx, solicitud, autorRol, tipoNotif, titulo, mensaje)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.obtener(solicitudID, turistaID, agenciaID)
+}
+
+// Aceptar acepta la cotización vigente: crea la compra privada con su salida exclusiva
+// (mismas validaciones de procesar_compra_paquete) y fija el precio cotizado en ambas.
+func (s *SolicitudPrivadaService) Aceptar(solicitudID uint, turistaID uint, req *models.AceptarSolicitudPrivadaRequest) (*models.ProcesarCompraPaqueteResult, error) {
+	var result models.ProcesarCompraPaqueteResult
+	procesar := func(tx *gorm.DB) error {
+		result = models.ProcesarCompraPaqueteResult{}
+
+		solicitud, err := bloquearSolicitud(tx, solicitudID, turistaID, 0)
+		if err != nil {
+			return err
+		}
+		if solicitud.Estado != "cotizada" || solicitud.PrecioCotizado == nil || solicitud.FechaCotizada == nil {
+			return errors.New("la solicitud no tiene una cotización vigente")
+		}
+		if solicitud.CotizacionExpiraEn != nil && solicitud.CotizacionExpiraEn.Before(time.Now()) {
+			return errors.New("la cotización ya expiró")
+		}
+		fecha := *solicitud.FechaCotizada
+
+		var participantes []models.CompraParticipante
+		if len(req.Participantes) > 0 {
+			edadMinima, edadAdulto, err := edadesParticipantesAgencia(tx, solicitud.PaqueteID)
+			if err != nil {
+				return err
+			}
+			participantes, err = validarParticipantes(edadMinima, edadAdulto, fecha, solicitud.CantidadAdultos,
+				solicitud.CantidadNinosPagan, solicitud.CantidadNinosGratis, req.Participantes)
+			if err != nil {
+				return err
+			}
+		}
+
+		notas := req.NotasTurista
+		if notas == nil {
+			notas = solicitud.NecesidadesEspeciales
+		}
+
+		if err := tx.Raw(
+			`SELECT * FROM public.procesar_compra_paquete(?::int, ?::int, ?::date, ?::text, ?::boolean, ?::int, ?::int, ?::int, ?::boolean, ?::text, ?::text)`,
+			turistaID,
+			solicitud.PaqueteID,
+			fecha,
+			"privado",
+			solicitud.Extranjero,
+			solicitud.CantidadAdultos,
+			solicitud.CantidadNinosPagan,
+			solicitud.CantidadNinosGratis,
+			solicitud.TieneDiscapacidad,
+			solicitud.DescripcionDiscapacidad,
+			notas,
+		).Scan(&result).Error; err != nil {
+			return err
+		}
+		if !result.Success || result.CompraID == 0 {
+			if result.Mensaje == "" {
+				return errors.New("no se pudo procesar la compra")
+			}
+			return errors.New(result.Mensaje)
+		}
+		total := solicitud.CantidadAdultos + solicitud.CantidadNinosPagan + solicitud.CantidadNinosGratis
+		if err := NewInventarioService(tx).Reservar(result.SalidaID, total); err != nil {
+			return err
+		}
+
+		// Precio cotizado: se fija como precio especial de la salida privada para que
+		// resolver_precio_paquete (modificaciones) lo respete, y como total de la compra.
+		precio := *solicitud.PrecioCotizado
+		pagantes := solicitud.CantidadAdultos + solicitud.CantidadNinosPagan
+		if pagantes < 1 {
+			pagantes = 1
+		}
+		unitario := redondearMonto(precio / float64(pagantes))
+		now := time.Now()
+
+		if err := tx.Model(&models.PaqueteSalidaHabilitada{}).Where("id = ?", result.SalidaID).Updates(map[string]interface{}{
+			"precio_base_nacionales":       unitario,
+			"precio_adicional_extranjeros": 0,
+			"descripcion_salida":           solicitud.CondicionesCotizacion,
+			"updated_at":                   now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.CompraPaquete{}).Where("id = ?", result.CompraID).Updates(map[string]interface{}{
+			"precio_unitario":            unitario,
+			"recargo_privado_porcentaje": 0,
+			"recargo_extranjero":         0,
+			"subtotal":                   precio,
+			"total_recargo":              0,
+			"precio_total":               precio,
+			"regla_precio":               "salida",
+			"temporada_precio_id":        nil,
+			"updated_at":                 now,
+		}).Error; err != nil {
+			return err
+		}
+		result.PrecioTotal = precio
+
+		compra, err := aplicarPoliticaAnticipo(tx, result.CompraID)
+		if err != nil {
+			return err
+		}
+		result.MontoAnticipo = compra.MontoAnticipo
+		result.FechaLimiteSaldo = compra.FechaLimiteSaldo
+
+		if err := guardarParticipantes(tx, result.CompraID, participantes); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.SolicitudPrivada{}).Where("id = ?", solicitud.ID).Updates(map[string]interface{}{
+			"estado":           "aceptada",
+			"compra_id":        result.CompraID,
+			"salida_id":        result.SalidaID,
+			"fecha_aceptacion": now,
+			"updated_at":       now,
+		}).Error; err != nil {
+			return err
+		}
+		if _, err := agregarMensajeSolicitud(tx, solicitud.ID, turistaID, "turista", "aceptacion", nil); err != nil {
+			return err
+		}
+
+		result.Mensaje = "Cotización aceptada. Compra registrada, esperando confirmación de pago."
+		return notificarContraparteSolicitud(tx, solicitud, "turista", models.TipoSolicitudPrivadaAceptada,
+			"Cotización de tour privado aceptada",
+			fmt.Sprintf("El turista aceptó la cotización para el %s (compra #%d). Pendiente de pago.",
+				fecha.Format("2006-01-02"), result.CompraID))
+	}
+
+	if err := s.db.Transaction(procesar); err != nil {
+		if !isUndefinedFunctionError(err) && !isFunctionResultMismatchError(err) {
+			return nil, err
+		}
+		if bootstrapErr := database.ApplySQLBootstrap(s.db); bootstrapErr != nil {
+			return nil, fmt.Errorf("la base de datos no está preparada (procesar_compra_paquete faltante o desactualizada): %w", bootstrapErr)
+		}
+		if retryErr := s.db.Transaction(procesar); retryErr != nil {
+			return nil, retryErr
+		}
+	}
+
+	return &result, nil
+}
+
+// ExpirarCotizaciones vence las cotizaciones no aceptadas a tiempo.
+func (s *SolicitudPrivadaService) ExpirarCotizaciones() (int64, error) {
+	var vencidas []models.SolicitudPrivada
+	if err := s.db.
+		Where("estado = ? AND cotizacion_expira_en < ?", "cotizada", time.Now()).
+		Find(&vencidas).Error; err != nil {
+		return 0, fmt.Errorf("error buscando cotizaciones vencidas: %w", err)
+	}
+
+	var expiradas int64
+	for _, v := range vencidas {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			solicitud, err := bloquearSolicitud(tx, v.ID, 0, 0)
+			if err != nil {
+				return err
+			}
+			if solicitud.Estado != "cotizada" || solicitud.CotizacionExpiraEn == nil || solicitud.CotizacionExpiraEn.After(time.Now()) {
+				return nil
+			}
+			if err := tx.Model(&models.SolicitudPrivada{}).Where("id = ?", solicitud.ID).Updates(map[string]interface{}{
+				"estado":     "expirada",
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			return notificarContraparteSolicitud(tx, solicitud, "agencia", models.TipoSolicitudPrivadaExpirada,
+				"Tu cotización de tour privado expiró",
+				"No aceptaste la cotización a tiempo. Puedes enviar una nueva solicitud a la agencia")
+		})
+		if err != nil {
+			log.Printf("Error expirando cotización de solicitud privada %d: %v", v.ID, err)
+			continue
+		}
+		expiradas++
+	}
+
+	return expiradas, nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/jung-kurt/gofpdf/contrib/barcode"
+	"gorm.io/gorm"
+)
+
+var ErrVoucherNoDisponible = errors.New("el voucher solo está disponible para compras confirmadas")
+
+type VoucherService struct {
+	db *gorm.DB
+}
+
+func NewVoucherService(db *gorm.DB) *VoucherService {
+	return &VoucherService{db: db}
+}
+
+// Voucher es el PDF generado para una compra confirmada.
+type Voucher struct {
+	Filename string
+	Data     []byte
+}
+
+// ObtenerVoucherTurista genera el voucher de una compra confirmada del turista.
+func (s *VoucherService) ObtenerVoucherTurista(compraID uint, turistaID uint) (*Voucher, error) {
+	compra, err := cargarCompraVoucher(s.db.Where("id = ? AND turista_id = ?", compraID, turistaID))
+	if err != nil {
+		return nil, err
+	}
+	return generarVoucherCompra(compra)
+}
+
+// EnviarVoucherConfirmacion envía al turista el email de confirmación con el voucher adjunto.
+// No hace nada si la compra aún no está confirmada (por ejemplo, tras confirmar solo un anticipo).
+func (s *VoucherService) EnviarVoucherConfirmacion(compraID uint) error {
+	compra, err := cargarCompraVoucher(s.db.Preload("Turista").Where("id = ?", compraID))
+	if errors.Is(err, ErrVoucherNoDisponible) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if compra.Turista == nil || compra.Turista.Email == "" {
+		return errors.New("el turista no tiene email registrado")
+	}
+
+	voucher, err := generarVoucherCompra(compra)
+	if err != nil {
+		return err
+	}
+
+	return NewEmailService().SendCompraConfirmadaVoucher(
+		compra.Turista.Email,
+		compra.Turista.Nombre,
+		compra.Paquete.Nombre,
+		*compra.CodigoConfirmacion,
+		EmailAttachment{Filename: voucher.Filename, ContentType: "application/pdf", Data: voucher.Data},
+	)
+}
+
+func cargarCompraVoucher(query *gorm.DB) (*models.CompraPaquete, error) {
+	var compra models.CompraPaquete
+	err := query.
+		Preload("Paquete.Agencia").
+		Preload("Salida").
+		Preload("Participantes", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		First(&compra).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("compra no encontrada")
+		}
+		return nil, err
+	}
+
+	if compra.Status != "confirmada" || compra.CodigoConfirmacion == nil || compra.Paquete == nil {
+		return nil, ErrVoucherNoDisponible
+	}
+
+	return &compra, nil
+}
+
+// venceVoucher es el fin del día siguiente al último día de la salida: el QR sirve para el check-in y
+// durante el viaje. Si la salida cambia de fecha, el voucher descargado de nuevo trae el vencimiento nuevo.
+func venceVoucher(compra *models.CompraPaquete) time.Time {
+	dias := 1
+	if compra.Paquete != nil && compra.Paquete.DuracionDias != nil && *compra.Paquete.DuracionDias > 1 {
+		dias = *compra.Paquete.DuracionDias
+	}
+	fecha := compra.FechaSeleccionada
+	inicio := time.Date(fecha.Year(), fecha.Month(), fecha.Day(), 0, 0, 0, 0, time.UTC)
+	return inicio.AddDate(0, 0, dias+1)
+}
+
+func generarVoucherCompra(compra *models.CompraPaquete) (*Voucher, error) {
+	token, err := utils.GenerateVoucherToken(compra.ID, *compra.CodigoConfirmacion, venceVoucher(compra))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := generarVoucherPDF(compra, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Voucher{
+		Filename: fmt.Sprintf("voucher_%s.pdf", *compra.CodigoConfirmacion),
+		Data:     data,
+	}, nil
+}
+
+func generarVoucherPDF(compra *models.CompraPaquete, token string) ([]byte, error) {
+	paquete := compra.Paquete
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(12, 12, 12)
+	pdf.AddPage()
+
+	keyValue := func(label, value string) {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(45, 6, tr(label), "", 0, "", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(90, 6, tr(value), "", "", false)
+	}
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.Cell(0, 10, tr("Voucher de reserva"))
+	pdf.Ln(10)
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.Cell(0, 8, tr(*compra.CodigoConfirmacion))
+	pdf.Ln(12)
+
+	// QR con el token firmado del código de confirmación (verificable sin conexión a la API de pagos)
+	qrKey := barcode.RegisterQR(pdf, token, qr.M, qr.Unicode)
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+	barcode.Barcode(pdf, qrKey, 148, 12, 50, 50, false)
+
+	keyValue("Paquete", paquete.Nombre)
+	keyValue("Fecha de salida", compra.FechaSeleccionada.Format("2006-01-02"))
+	if compra.HorarioSeleccionado != nil && *compra.HorarioSeleccionado != "" {
+		keyValue("Horario", *compra.HorarioSeleccionado)
+	}
+	if paquete.DuracionDias != nil && *paquete.DuracionDias > 1 {
+		keyValue("Duración", fmt.Sprintf("%d días", *paquete.DuracionDias))
+	}
+	keyValue("Tipo", compra.TipoCompra)
+	if salida := compra.Salida; salida != nil {
+		if salida.PuntoEncuentro != nil && strings.TrimSpace(*salida.PuntoEncuentro) != "" {
+			keyValue("Punto de encuentro", *salida.PuntoEncuentro)
+		}
+		if salida.HoraEncuentro != nil && len(*salida.HoraEncuentro) >= 5 {
+			keyValue("Hora de encuentro", (*salida.HoraEncuentro)[:5])
+		}
+		if salida.InstruccionesTuristas != nil && strings.TrimSpace(*salida.InstruccionesTuristas) != "" {
+			keyValue("Instrucciones", *salida.InstruccionesTuristas)
+		}
+	}
+	keyValue("Participantes", fmt.Sprintf("%d (adultos: %d, niños que pagan: %d, niños gratis: %d)",
+		compra.TotalParticipantes, compra.CantidadAdultos, compra.CantidadNinosPagan, compra.CantidadNinosGratis))
+	pdf.Ln(4)
+
+	if len(compra.Participantes) > 0 {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.Cell(0, 7, tr("Lista de participantes"))
+		pdf.Ln(8)
+
+		widths := []float64{80, 30, 50}
+		pdf.SetFont("Helvetica", "B", 9)
+		for i, header := range []string{"Nombre", "Tipo", "Documento"} {
+			pdf.CellFormat(widths[i], 6, tr(header), "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 9)
+		for _, p := range compra.Participantes {
+			pdf.CellFormat(widths[0], 6, tr(p.NombreCompleto), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(widths[1], 6, tr(p.Tipo), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(widths[2], 6, tr(fmt.Sprintf("%s %s", p.TipoDocumento, p.NumeroDocumento)), "1", 0, "L", false, 0, "")
+			pdf.Ln(-1)
+		}
+		pdf.Ln(4)
+	}
+
+	if agencia := paquete.Agencia; agencia != nil {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.Cell(0, 7, tr("Agencia"))
+		pdf.Ln(8)
+		keyValue("Nombre", agencia.NombreComercial)
+		keyValue("Teléfono", agencia.Telefono)
+		keyValue("Email", agencia.Email)
+		keyValue("Dirección", agencia.Direccion)
+		pdf.Ln(4)
+	}
+
+	pdf.SetFont("Helvetica", "I", 9)
+	pdf.MultiCell(0, 5, tr("Presenta este voucher (impreso o en tu celular) en el punto de encuentro. "+
+		"El código QR identifica tu reserva y no contiene datos de pago."), "", "", false)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"andaria-backend/internal/models"
+	"andaria-backend/pkg/utils"
+)
+
+func TestVenceVoucher(t *testing.T) {
+	dias := func(n int) *int { return &n }
+	casos := []struct {
+		nombre   string
+		salida   time.Time
+		duracion *int
+		want     time.Time
+	}{
+		{"paquete sin duración", time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC), nil, time.Date(2026, 11, 22, 0, 0, 0, 0, time.UTC)},
+		{"paquete de un día", time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC), dias(1), time.Date(2026, 11, 22, 0, 0, 0, 0, time.UTC)},
+		{"paquete de tres días", time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC), dias(3), time.Date(2026, 11, 24, 0, 0, 0, 0, time.UTC)},
+		{"salida leída en otra zona horaria", time.Date(2026, 11, 20, 0, 0, 0, 0, time.FixedZone("BOT", -4*3600)), dias(1), time.Date(2026, 11, 22, 0, 0, 0, 0, time.UTC)},
+		{"viaje que termina a fin de año", time.Date(2026, 12, 30, 0, 0, 0, 0, time.UTC), dias(2), time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			compra := &models.CompraPaquete{FechaSeleccionada: c.salida, Paquete: &models.PaqueteTuristico{DuracionDias: c.duracion}}
+			if got := venceVoucher(compra); !got.Equal(c.want) {
+				t.Errorf("venceVoucher = %v, se esperaba %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTokenVoucherVenceDespuesDeLaSalida(t *testing.T) {
+	utils.InitJWT("secreto-de-prueba-para-vouchers")
+	hoy := time.Now().UTC()
+	hoy = time.Date(hoy.Year(), hoy.Month(), hoy.Day(), 0, 0, 0, 0, time.UTC)
+
+	casos := []struct {
+		nombre string
+		salida time.Time
+		valido bool
+	}{
+		{"salida futura", hoy.AddDate(0, 0, 7), true},
+		{"salida de hoy", hoy, true},
+		{"salida de ayer, día de gracia", hoy.AddDate(0, 0, -1), true},
+		{"salida de anteayer", hoy.AddDate(0, 0, -2), false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			compra := &models.CompraPaquete{ID: 42, FechaSeleccionada: c.salida}
+			token, err := utils.GenerateVoucherToken(compra.ID, "AND-7Q2K9X", venceVoucher(compra))
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := utils.ValidateVoucherToken(token)
+			if c.valido && (err != nil || claims.CompraID != compra.ID) {
+				t.Errorf("token rechazado: %v", err)
+			}
+			if !c.valido && err == nil {
+				t.Error("se aceptó el token de una salida ya terminada")
+			}
+		})
+	}
+}
@@ -0,0 +1,566 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"andaria-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Header con la firma HMAC-SHA256 (hex, admite el prefijo "sha256=") de los webhooks de QR bancario.
+const HeaderFirmaWebhookPago = "X-Webhook-Signature"
+
+// Estados de un EventoWebhookPago.
+const (
+	EventoWebhookRecibido          = "recibido"
+	EventoWebhookProcesado         = "procesado"
+	EventoWebhookIgnorado          = "ignorado"
+	EventoWebhookPendienteRevision = "pendiente_revision"
+	EventoWebhookDescartado        = "descartado"
+	EventoWebhookFirmaInvalida     = "firma_invalida"
+	EventoWebhookInvalido          = "invalido"
+)
+
+var (
+	ErrWebhookInvalido           = errors.New("webhook inválido")
+	ErrEventoWebhookNoEncontrado = errors.New("evento de webhook no encontrado")
+)
+
+// Referencia de pago de una compra (AND-000123). El turista la indica en la glosa de su QR o
+// transferencia y así el webhook del banco se puede asociar a la compra.
+var patronReferenciaPago = regexp.MustCompile(`(?i)\bAND-?0*([1-9][0-9]*)\b`)
+
+// ReferenciaPagoCompra retorna la referencia de pago que el turista debe indicar en la glosa.
+func ReferenciaPagoCompra(compraID uint) string {
+	return fmt.Sprintf("AND-%06d", compraID)
+}
+
+// compraDeReferenciaPago busca una referencia de pago en los textos informados por el banco.
+func compraDeReferenciaPago(textos ...string) (uint, bool) {
+	for _, texto := range textos {
+		m := patronReferenciaPago.FindStringSubmatch(texto)
+		if m == nil {
+			continue
+		}
+		id, err := strconv.ParseUint(m[1], 10, 32)
+		if err == nil {
+			return uint(id), true
+		}
+	}
+	return 0, false
+}
+
+// webhookBanco es el formato de notificación acordado con los proveedores de QR bancario.
+type webhookBanco struct {
+	ID         string  `json:"id"`
+	Referencia string  `json:"referencia"`
+	Glosa      string  `json:"glosa"`
+	Monto      float64 `json:"monto"`
+	Estado     string  `json:"estado"`
+}
+
+// notificacionPago es un webhook ya verificado, en forma común para pasarelas y bancos.
+type notificacionPago struct {
+	estado *EstadoPagoPasarela
+	glosa  string
+	// El proveedor es una pasarela registrada: el pago se busca por su referencia de intento
+	pasarela bool
+}
+
+// resolucionWebhook es el resultado de aplicar un evento.
+type resolucionWebhook struct {
+	estado         string
+	motivo         string
+	pagoID         *uint
+	pagoSugeridoID *uint
+	// Compra cuyo voucher se envía al confirmar la transacción (0 si ninguna)
+	voucherCompraID uint
+}
+
+type WebhookPagoService struct {
+	db *gorm.DB
+}
+
+func NewWebhookPagoService(db *gorm.DB) *WebhookPagoService {
+	return &WebhookPagoService{db: db}
+}
+
+// secretoWebhookBanco retorna el secreto del proveedor configurado en WEBHOOK_PAGOS_SECRETOS
+// (formato "proveedor=secreto,proveedor2=secreto2").
+func secretoWebhookBanco(proveedor string) (string, bool) {
+	for _, par := range strings.Split(os.Getenv("WEBHOOK_PAGOS_SECRETOS"), ",") {
+		nombre, secreto, ok := strings.Cut(strings.TrimSpace(par), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(nombre), proveedor) && strings.TrimSpace(secreto) != "" {
+			return strings.TrimSpace(secreto), true
+		}
+	}
+	return "", false
+}
+
+func firmaWebhookValida(secreto string, firma string, cuerpo []byte) bool {
+	firma = strings.TrimPrefix(strings.TrimSpace(firma), "sha256=")
+	recibida, err := hex.DecodeString(firma)
+	if err != nil || len(recibida) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secreto))
+	mac.Write(cuerpo)
+	return hmac.Equal(recibida, mac.Sum(nil))
+}
+
+// estadoWebhookBanco traduce el estado informado por el banco; una notificación sin estado es un cobro.
+func estadoWebhookBanco(estado string) string {
+	estado = strings.ToLower(strings.TrimSpace(estado))
+	switch estado {
+	case "", "aprobado", "pagado", "completado", "exitoso":
+		return EstadoPasarelaAprobado
+	}
+	return estado
+}
+
+// verificarWebhookPago verifica la firma y traduce el cuerpo. Las pasarelas registradas verifican sus
+// propios webhooks; los proveedores de QR bancario se autentican con el secreto de WEBHOOK_PAGOS_SECRETOS.
+func verificarWebhookPago(nombreProveedor string, headers http.Header, cuerpo []byte) (*notificacionPago, error) {
+	if proveedor, err := ObtenerProveedorPago(nombreProveedor); err == nil {
+		estado, err := proveedor.ProcesarWebhook(headers, cuerpo)
+		if err != nil {
+			return nil, err
+		}
+		return &notificacionPago{estado: estado, pasarela: true}, nil
+	}
+
+	secreto, ok := secretoWebhookBanco(nombreProveedor)
+	if !ok {
+		return nil, ErrProveedorPagoNoDisponible
+	}
+	if !firmaWebhookValida(secreto, headers.Get(HeaderFirmaWebhookPago), cuerpo) {
+		return nil, ErrFirmaWebhookInvalida
+	}
+
+	var evento webhookBanco
+	if err := json.Unmarshal(cuerpo, &evento); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookInvalido, err)
+	}
+	return &notificacionPago{
+		estado: &EstadoPagoPasarela{
+			EventoID:   strings.TrimSpace(evento.ID),
+			Referencia: strings.TrimSpace(evento.Referencia),
+			Estado:     estadoWebhookBanco(evento.Estado),
+			Monto:      evento.Monto,
+		},
+		glosa: strings.TrimSpace(evento.Glosa),
+	}, nil
+}
+
+func hashCuerpoWebhook(cuerpo []byte) string {
+	suma := sha256.Sum256(cuerpo)
+	return "sha256:" + hex.EncodeToString(suma[:])
+}
+
+// recortarTexto limita s a max caracteres (para columnas de tamaño fijo).
+func recortarTexto(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+func textoOpcional(s string, max int) *string {
+	if s == "" {
+		return nil
+	}
+	s = recortarTexto(s, max)
+	return &s
+}
+
+// Recibir registra un webhook de pago y lo aplica. Todo evento se guarda, incluso con firma inválida;
+// un evento que el proveedor reenvía (mismo ID) no se vuelve a aplicar. Los que no se pueden asociar a
+// un pago pendiente quedan en la cola de revisión del admin.
+func (s *WebhookPagoService) Recibir(nombreProveedor string, headers http.Header, cuerpo []byte) (*models.WebhookPagoResponse, error) {
+	nombreProveedor = strings.ToLower(strings.TrimSpace(nombreProveedor))
+	notif, errVerificacion := verificarWebhookPago(nombreProveedor, headers, cuerpo)
+	if errors.Is(errVerificacion, ErrProveedorPagoNoDisponible) {
+		return nil, errVerificacion
+	}
+
+	evento := models.EventoWebhookPago{
+		Proveedor:       nombreProveedor,
+		EventoID:        hashCuerpoWebhook(cuerpo),
+		Cuerpo:          strings.ToValidUTF8(string(cuerpo), "\uFFFD"),
+		Estado:          EventoWebhookRecibido,
+		Recepciones:     1,
+		UltimaRecepcion: time.Now(),
+	}
+	switch {
+	case errors.Is(errVerificacion, ErrFirmaWebhookInvalida):
+		evento.Estado = EventoWebhookFirmaInvalida
+	case errVerificacion != nil:
+		evento.FirmaValida = true
+		evento.Estado = EventoWebhookInvalido
+		evento.Motivo = textoOpcional(errVerificacion.Error(), 500)
+	default:
+		evento.FirmaValida = true
+		if id := notif.estado.EventoID; id != "" {
+			if len(id) > 150 {
+				id = hashCuerpoWebhook([]byte(id))
+			}
+			evento.EventoID = id
+		}
+		evento.Referencia = textoOpcional(notif.estado.Referencia, 150)
+		evento.Glosa = textoOpcional(notif.glosa, 500)
+		evento.EstadoInformado = textoOpcional(notif.estado.Estado, 20)
+		if notif.estado.Monto > 0 {
+			monto := notif.estado.Monto
+			evento.Monto = &monto
+		}
+	}
+
+	duplicado, err := s.registrar(&evento)
+	if err != nil {
+		return nil, fmt.Errorf("error registrando el webhook: %w", err)
+	}
+	if errVerificacion != nil {
+		return nil, errVerificacion
+	}
+
+	// Un reenvío de un evento que quedó a medias (p. ej. por un error de base de datos) se reintenta
+	if evento.Estado == EventoWebhookRecibido {
+		estado, err := s.procesar(evento.ID, notif)
+		if err != nil {
+			return nil, err
+		}
+		evento.Estado = estado
+	}
+
+	return &models.WebhookPagoResponse{
+		EventoID:  evento.ID,
+		Estado:    evento.Estado,
+		Duplicado: duplicado,
+	}, nil
+}
+
+// registrar inserta el evento; si el proveedor ya lo había enviado solo cuenta la recepción y carga el
+// evento existente. Retorna si era un duplicado.
+func (s *WebhookPagoService) registrar(evento *models.EventoWebhookPago) (bool, error) {
+	res := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "proveedor"}, {Name: "evento_id"}},
+		DoNothing: true,
+	}).Create(evento)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return false, nil
+	}
+
+	var existente models.EventoWebhookPago
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EventoWebhookPago{}).
+			Where("proveedor = ? AND evento_id = ?", evento.Proveedor, evento.EventoID).
+			Updates(map[string]interface{}{
+				"recepciones":      gorm.Expr("recepciones + 1"),
+				"ultima_recepcion": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Where("proveedor = ? AND evento_id = ?", evento.Proveedor, evento.EventoID).First(&existente).Error
+	})
+	if err != nil {
+		return true, err
+	}
+	*evento = existente
+	return true, nil
+}
+
+// procesar aplica el evento con la fila bloqueada, de modo que dos entregas simultáneas no lo apliquen
+// dos veces. La confirmación del pago y el estado del evento van en la misma transacción. Retorna el
+// estado final del evento.
+func (s *WebhookPagoService) procesar(eventoID uint, notif *notificacionPago) (string, error) {
+	var estadoFinal string
+	var voucherCompraID uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var evento models.EventoWebhookPago
+		if err := tx.Raw(`SELECT * FROM eventos_webhook_pagos WHERE id = ? FOR UPDATE`, eventoID).Scan(&evento).Error; err != nil {
+			return err
+		}
+		estadoFinal = evento.Estado
+		if evento.Estado != EventoWebhookRecibido {
+			return nil
+		}
+
+		resolucion, err := s.aplicar(tx, evento.Proveedor, notif)
+		if err != nil {
+			return err
+		}
+		voucherCompraID = resolucion.voucherCompraID
+		if resolucion.estado == EventoWebhookPendienteRevision {
+			log.Printf("Webhook de pago %d (%s) enviado a revisión: %s", evento.ID, evento.Proveedor, resolucion.motivo)
+		}
+
+		estadoFinal = resolucion.estado
+		return tx.Model(&models.EventoWebhookPago{}).Where("id = ?", evento.ID).Updates(map[string]interface{}{
+			"estado":           resolucion.estado,
+			"motivo":           textoOpcional(resolucion.motivo, 500),
+			"pago_id":          resolucion.pagoID,
+			"pago_sugerido_id": resolucion.pagoSugeridoID,
+			"updated_at":       time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return estadoFinal, err
+	}
+	enviarVoucherConfirmacion(s.db, voucherCompraID)
+	return estadoFinal, nil
+}
+
+func enRevision(motivo string, pagoSugeridoID *uint) *resolucionWebhook {
+	return &resolucionWebhook{estado: EventoWebhookPendienteRevision, motivo: motivo, pagoSugeridoID: pagoSugeridoID}
+}
+
+// aplicar asocia la notificación a un pago pendiente y lo confirma dentro de tx. Solo retorna error ante
+// fallas de infraestructura; lo que no se puede resolver automáticamente va a revisión.
+func (s *WebhookPagoService) aplicar(tx *gorm.DB, proveedor string, notif *notificacionPago) (*resolucionWebhook, error) {
+	estado := notif.estado
+	if notif.pasarela {
+		return s.aplicarPasarela(tx, proveedor, estado)
+	}
+
+	if estado.Estado != EstadoPasarelaAprobado {
+		return &resolucionWebhook{estado: EventoWebhookIgnorado, motivo: fmt.Sprintf("El proveedor informa el estado %q", estado.Estado)}, nil
+	}
+	if estado.Monto <= 0 {
+		return enRevision("La notificación no informa el monto pagado", nil), nil
+	}
+
+	compraID, ok := compraDeReferenciaPago(estado.Referencia, notif.glosa)
+	if !ok {
+		sugerido, err := s.pagoSugeridoPorMonto(estado.Monto)
+		if err != nil {
+			return nil, err
+		}
+		return enRevision("La notificación no incluye una referencia de compra (AND-…)", sugerido), nil
+	}
+
+	var pagos []models.PagoCompra
+	if err := tx.
+		Where("compra_id = ? AND estado = ? AND metodo_pago IN ?", compraID, "pendiente", []string{"qr", "transferencia"}).
+		Order("created_at ASC").Order("id ASC").
+		Find(&pagos).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range pagos {
+		pago := &pagos[i]
+		if math.Abs(pago.Monto-estado.Monto) > 0.01 {
+			continue
+		}
+		notas := fmt.Sprintf("Confirmado por webhook de %s (ref. %s)", proveedor, estado.Referencia)
+		voucherCompraID, err := NewPagoService(tx).confirmarPago(pago.ID, nil, &notas)
+		if errors.Is(err, errPagoYaProcesado) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &resolucionWebhook{estado: EventoWebhookProcesado, pagoID: &pago.ID, voucherCompraID: voucherCompraID}, nil
+	}
+
+	if len(pagos) == 0 {
+		return enRevision(fmt.Sprintf("La compra #%d no tiene pagos por QR o transferencia pendientes", compraID), nil), nil
+	}
+	var sugerido *uint
+	if len(pagos) == 1 {
+		sugerido = &pagos[0].ID
+	}
+	return enRevision(fmt.Sprintf("Ningún pago pendiente de la compra #%d es de Bs %.2f", compraID, estado.Monto), sugerido), nil
+}
+
+// aplicarPasarela aplica el webhook de una pasarela al pago en línea de su referencia, dentro de tx.
+func (s *WebhookPagoService) aplicarPasarela(tx *gorm.DB, proveedor string, estado *EstadoPagoPasarela) (*resolucionWebhook, error) {
+	var pago models.PagoCompra
+	if err := tx.Where("proveedor = ? AND referencia_proveedor = ?", proveedor, estado.Referencia).
+		First(&pago).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return enRevision(fmt.Sprintf("No existe un pago en línea con la referencia %s", estado.Referencia), nil), nil
+		}
+		return nil, err
+	}
+
+	voucherCompraID, err := NewPasarelaService(tx).aplicarEstado(&pago, estado)
+	if err != nil {
+		if errors.Is(err, ErrMontoPasarelaDistinto) {
+			return enRevision(err.Error(), &pago.ID), nil
+		}
+		return nil, err
+	}
+	if estado.Estado == EstadoPasarelaPendiente {
+		return &resolucionWebhook{estado: EventoWebhookIgnorado, motivo: "El pago sigue pendiente en la pasarela"}, nil
+	}
+	return &resolucionWebhook{estado: EventoWebhookProcesado, pagoID: &pago.ID, voucherCompraID: voucherCompraID}, nil
+}
+
+// pagoSugeridoPorMonto retorna el único pago por QR o transferencia pendiente con ese monto, si lo hay.
+// Es solo una sugerencia para la revisión: el monto por sí solo no basta para confirmar.
+func (s *WebhookPagoService) pagoSugeridoPorMonto(monto float64) (*uint, error) {
+	var ids []uint
+	if err := s.db.Model(&models.PagoCompra{}).
+		Where("estado = ? AND metodo_pago IN ?", "pendiente", []string{"qr", "transferencia"}).
+		Where("ABS(monto - ?) <= 0.01", monto).
+		Limit(2).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) != 1 {
+		return nil, nil
+	}
+	return &ids[0], nil
+}
+
+// ListarEventos lista los webhooks recibidos, por defecto la cola de revisión.
+func (s *WebhookPagoService) ListarEventos(estado string, proveedor string, page, pageSize int) ([]models.EventoWebhookPago, int64, error) {
+	q := s.db.Model(&models.EventoWebhookPago{})
+	if estado != "" {
+		q = q.Where("estado = ?", estado)
+	}
+	if proveedor != "" {
+		q = q.Where("proveedor = ?", proveedor)
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var eventos []models.EventoWebhookPago
+	if err := q.Session(&gorm.Session{}).
+		Order("created_at DESC").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&eventos).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return eventos, total, nil
+}
+
+func (s *WebhookPagoService) ObtenerEvento(eventoID uint) (*models.EventoWebhookPago, error) {
+	var evento models.EventoWebhookPago
+	if err := s.db.Preload("Pago").First(&evento, eventoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventoWebhookNoEncontrado
+		}
+		return nil, err
+	}
+	return &evento, nil
+}
+
+// bloquearEventoEnRevision bloquea el evento y valida que siga en la cola de revisión.
+func bloquearEventoEnRevision(tx *gorm.DB, eventoID uint) (*models.EventoWebhookPago, error) {
+	var evento models.EventoWebhookPago
+	res := tx.Raw(`SELECT * FROM eventos_webhook_pagos WHERE id = ? FOR UPDATE`, eventoID).Scan(&evento)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrEventoWebhookNoEncontrado
+	}
+	if evento.Estado != EventoWebhookPendienteRevision {
+		return nil, fmt.Errorf("el evento no está pendiente de revisión (estado: %s)", evento.Estado)
+	}
+	return &evento, nil
+}
+
+// AsignarEvento aplica un evento de la cola de revisión al pago pendiente indicado por el admin. El pago
+// debe ser del medio del proveedor: los eventos de una pasarela solo se asignan a sus pagos en línea y
+// los de QR bancario a pagos por QR o transferencia.
+func (s *WebhookPagoService) AsignarEvento(eventoID uint, adminID uint, req *models.AsignarEventoWebhookRequest) (*models.EventoWebhookPago, error) {
+	var voucherCompraID uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		evento, err := bloquearEventoEnRevision(tx, eventoID)
+		if err != nil {
+			return err
+		}
+
+		var pago models.PagoCompra
+		if err := tx.First(&pago, req.PagoID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("pago no encontrado")
+			}
+			return err
+		}
+		if pago.Estado != "pendiente" {
+			return fmt.Errorf("el pago #%d no está pendiente (estado: %s)", pago.ID, pago.Estado)
+		}
+		if _, err := ObtenerProveedorPago(evento.Proveedor); err == nil {
+			if pago.MetodoPago != "pasarela" || pago.Proveedor == nil || *pago.Proveedor != evento.Proveedor {
+				return fmt.Errorf("el pago #%d no es un pago en línea de %s", pago.ID, evento.Proveedor)
+			}
+		} else if pago.MetodoPago != "qr" && pago.MetodoPago != "transferencia" {
+			return fmt.Errorf("el pago #%d es por %s; los webhooks de QR bancario solo se asignan a pagos por QR o transferencia", pago.ID, pago.MetodoPago)
+		}
+		if evento.Monto != nil && math.Abs(*evento.Monto-pago.Monto) > 0.01 {
+			return fmt.Errorf("el evento informa Bs %.2f y el pago #%d es de Bs %.2f", *evento.Monto, pago.ID, pago.Monto)
+		}
+
+		notas := fmt.Sprintf("Confirmado por webhook de %s (evento #%d, revisado por el admin)", evento.Proveedor, evento.ID)
+		voucherCompraID, err = NewPagoService(tx).confirmarPago(pago.ID, &adminID, &notas)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&models.EventoWebhookPago{}).Where("id = ?", evento.ID).Updates(map[string]interface{}{
+			"estado":         EventoWebhookProcesado,
+			"pago_id":        pago.ID,
+			"revisado_por":   adminID,
+			"fecha_revision": now,
+			"notas_revision": req.Notas,
+			"updated_at":     now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	enviarVoucherConfirmacion(s.db, voucherCompraID)
+	return s.ObtenerEvento(eventoID)
+}
+
+// DescartarEvento cierra un evento de la cola de revisión sin aplicarlo (p. ej. un depósito ajeno).
+func (s *WebhookPagoService) DescartarEvento(eventoID uint, adminID uint, notas string) (*models.EventoWebhookPago, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		evento, err := bloquearEventoEnRevision(tx, eventoID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&models.EventoWebhookPago{}).Where("id = ?", evento.ID).Updates(map[string]interface{}{
+			"estado":         EventoWebhookDescartado,
+			"revisado_por":   adminID,
+			"fecha_revision": now,
+			"notas_revision": notas,
+			"updated_at":     now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ObtenerEvento(eventoID)
+}
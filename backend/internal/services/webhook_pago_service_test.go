@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func firmarWebhook(secreto string, cuerpo []byte) string {
+	mac := hmac.New(sha256.New, []byte(secreto))
+	mac.Write(cuerpo)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestFirmaWebhookValida(t *testing.T) {
+	const secreto = "secreto-banco"
+	cuerpo := []byte(`{"id":"evt-1","referencia":"AND-00042","monto":350.5,"estado":"aprobado"}`)
+	firma := firmarWebhook(secreto, cuerpo)
+
+	casos := []struct {
+		nombre string
+		firma  string
+		cuerpo []byte
+		want   bool
+	}{
+		{"firma válida", firma, cuerpo, true},
+		{"firma válida con prefijo sha256=", "sha256=" + firma, cuerpo, true},
+		{"firma en mayúsculas y con espacios", "  " + strings.ToUpper(firma) + " ", cuerpo, true},
+		{"cuerpo alterado", firma, []byte(strings.Replace(string(cuerpo), "350.5", "3505", 1)), false},
+		{"firma de otro secreto", firmarWebhook("otro-secreto", cuerpo), cuerpo, false},
+		{"firma truncada", firma[:len(firma)-2], cuerpo, false},
+		{"firma que no es hexadecimal", "no-es-hex", cuerpo, false},
+		{"firma vacía", "", cuerpo, false},
+		{"solo el prefijo", "sha256=", cuerpo, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := firmaWebhookValida(secreto, c.firma, c.cuerpo); got != c.want {
+				t.Errorf("firmaWebhookValida = %v, se esperaba %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestVerificarWebhookPagoBanco(t *testing.T) {
+	t.Setenv("WEBHOOK_PAGOS_SECRETOS", "bancoprueba=secreto-banco, otrobanco=otro-secreto")
+	cuerpo := []byte(`{"id":"evt-1","referencia":"AND-00042","glosa":"Pago AND-00042","monto":350.5}`)
+
+	casos := []struct {
+		nombre    string
+		proveedor string
+		headers   http.Header
+		err       error
+	}{
+		{"firma válida", "bancoprueba", http.Header{HeaderFirmaWebhookPago: {firmarWebhook("secreto-banco", cuerpo)}}, nil},
+		{"firma con el secreto de otro proveedor", "bancoprueba", http.Header{HeaderFirmaWebhookPago: {firmarWebhook("otro-secreto", cuerpo)}}, ErrFirmaWebhookInvalida},
+		{"firma en otro header", "bancoprueba", http.Header{"X-Signature": {firmarWebhook("secreto-banco", cuerpo)}}, ErrFirmaWebhookInvalida},
+		{"sin header de firma", "bancoprueba", http.Header{}, ErrFirmaWebhookInvalida},
+		{"proveedor sin secreto configurado", "bancodesconocido", http.Header{HeaderFirmaWebhookPago: {firmarWebhook("secreto-banco", cuerpo)}}, ErrProveedorPagoNoDisponible},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			notif, err := verificarWebhookPago(c.proveedor, c.headers, cuerpo)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("error = %v, se esperaba %v", err, c.err)
+			}
+			if c.err != nil {
+				return
+			}
+			if notif.pasarela || notif.glosa != "Pago AND-00042" {
+				t.Errorf("notificación = %+v", notif)
+			}
+			if notif.estado.EventoID != "evt-1" || notif.estado.Referencia != "AND-00042" ||
+				notif.estado.Estado != EstadoPasarelaAprobado || notif.estado.Monto != 350.5 {
+				t.Errorf("estado = %+v", notif.estado)
+			}
+		})
+	}
+}
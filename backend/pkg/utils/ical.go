@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ICalTZID es la zona horaria de los calendarios exportados (Bolivia, UTC-4 sin horario de verano).
+const ICalTZID = "America/La_Paz"
+
+// ICalZona es la zona fija equivalente a ICalTZID.
+var ICalZona = time.FixedZone("BOT", -4*60*60)
+
+// ICalEvent es un VEVENT de un calendario iCalendar (RFC 5545).
+// Si TodoElDia es true, Inicio y Fin son fechas y Fin es exclusivo.
+type ICalEvent struct {
+	UID         string
+	Inicio      time.Time
+	Fin         time.Time
+	TodoElDia   bool
+	Resumen     string
+	Descripcion string
+	Lugar       string
+	Cancelado   bool
+	Modificado  time.Time
+}
+
+// ICalEscape escapa un texto para una propiedad iCalendar.
+func ICalEscape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+func icalUnescape(value string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return replacer.Replace(value)
+}
+
+// icalFold corta las líneas a 75 octetos como exige RFC 5545, sin partir caracteres UTF-8.
+func icalFold(buf *bytes.Buffer, line string) {
+	limite := 75
+	for len(line) > limite {
+		corte := limite
+		for corte > 0 && (line[corte]&0xC0) == 0x80 {
+			corte--
+		}
+		buf.WriteString(line[:corte])
+		buf.WriteString("\r\n ")
+		line = line[corte:]
+		// Las líneas de continuación ya llevan el espacio inicial
+		limite = 74
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+// BuildICalendar genera un archivo .ics con los eventos indicados.
+func BuildICalendar(nombre string, eventos []ICalEvent) []byte {
+	var buf bytes.Buffer
+	icalFold(&buf, "BEGIN:VCALENDAR")
+	icalFold(&buf, "VERSION:2.0")
+	icalFold(&buf, "PRODID:-//Andaria//Salidas//ES")
+	icalFold(&buf, "CALSCALE:GREGORIAN")
+	icalFold(&buf, "METHOD:PUBLISH")
+	icalFold(&buf, "X-WR-CALNAME:"+ICalEscape(nombre))
+	icalFold(&buf, "X-WR-TIMEZONE:"+ICalTZID)
+	icalFold(&buf, "BEGIN:VTIMEZONE")
+	icalFold(&buf, "TZID:"+ICalTZID)
+	icalFold(&buf, "BEGIN:STANDARD")
+	icalFold(&buf, "DTSTART:19700101T000000")
+	icalFold(&buf, "TZOFFSETFROM:-0400")
+	icalFold(&buf, "TZOFFSETTO:-0400")
+	icalFold(&buf, "TZNAME:BOT")
+	icalFold(&buf, "END:STANDARD")
+	icalFold(&buf, "END:VTIMEZONE")
+
+	ahora := time.Now().UTC().Format("20060102T150405Z")
+	for _, e := range eventos {
+		icalFold(&buf, "BEGIN:VEVENT")
+		icalFold(&buf, "UID:"+e.UID)
+		if e.Modificado.IsZero() {
+			icalFold(&buf, "DTSTAMP:"+ahora)
+		} else {
+			icalFold(&buf, "DTSTAMP:"+e.Modificado.UTC().Format("20060102T150405Z"))
+		}
+		if e.TodoElDia {
+			icalFold(&buf, "DTSTART;VALUE=DATE:"+e.Inicio.Format("20060102"))
+			icalFold(&buf, "DTEND;VALUE=DATE:"+e.Fin.Format("20060102"))
+		} else {
+			icalFold(&buf, fmt.Sprintf("DTSTART;TZID=%s:%s", ICalTZID, e.Inicio.In(ICalZona).Format("20060102T150405")))
+			icalFold(&buf, fmt.Sprintf("DTEND;TZID=%s:%s", ICalTZID, e.Fin.In(ICalZona).Format("20060102T150405")))
+		}
+		icalFold(&buf, "SUMMARY:"+ICalEscape(e.Resumen))
+		if e.Descripcion != "" {
+			icalFold(&buf, "DESCRIPTION:"+ICalEscape(e.Descripcion))
+		}
+		if e.Lugar != "" {
+			icalFold(&buf, "LOCATION:"+ICalEscape(e.Lugar))
+		}
+		if e.Cancelado {
+			icalFold(&buf, "STATUS:CANCELLED")
+		} else {
+			icalFold(&buf, "STATUS:CONFIRMED")
+		}
+		icalFold(&buf, "END:VEVENT")
+	}
+	icalFold(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// parseICalFecha interpreta DTSTART/DTEND. Las horas sin zona o con TZID se toman como hora de Bolivia.
+func parseICalFecha(params string, value string) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(strings.ToUpper(params), "VALUE=DATE") || len(value) == 8 {
+		fecha, err := time.Parse("20060102", value)
+		return fecha, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		fecha, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return fecha, false, err
+		}
+		return fecha.In(ICalZona), false, nil
+	}
+	fecha, err := time.ParseInLocation("20060102T150405", value, ICalZona)
+	return fecha, false, err
+}
+
+// ParseICalendar lee los VEVENT de un archivo .ics. Ignora propiedades y componentes no soportados, salvo las
+// recurrencias (RRULE, RDATE, EXDATE): un evento recurrente es un error, no se importa solo su primera fecha.
+func ParseICalendar(r io.Reader) ([]ICalEvent, error) {
+	// Desplegar líneas continuadas (las que empiezan con espacio o tab)
+	lineas := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		linea := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(linea, " ") || strings.HasPrefix(linea, "\t")) && len(lineas) > 0 {
+			lineas[len(lineas)-1] += linea[1:]
+			continue
+		}
+		lineas = append(lineas, linea)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	eventos := []ICalEvent{}
+	var actual *ICalEvent
+	anidados := 0     // componentes dentro del VEVENT (p. ej. VALARM)
+	recurrencia := "" // primera propiedad de recurrencia del VEVENT
+	vistoCalendario := false
+	for _, linea := range lineas {
+		if linea == "" {
+			continue
+		}
+		sep := strings.Index(linea, ":")
+		if sep < 0 {
+			continue
+		}
+		nombre, valor := linea[:sep], linea[sep+1:]
+		params := ""
+		if i := strings.Index(nombre, ";"); i >= 0 {
+			params = nombre[i+1:]
+			nombre = nombre[:i]
+		}
+		nombre = strings.ToUpper(nombre)
+
+		switch {
+		case nombre == "BEGIN" && strings.EqualFold(valor, "VCALENDAR"):
+			vistoCalendario = true
+		case nombre == "BEGIN" && strings.EqualFold(valor, "VEVENT"):
+			actual = &ICalEvent{}
+			anidados = 0
+			recurrencia = ""
+		case nombre == "END" && strings.EqualFold(valor, "VEVENT"):
+			if actual != nil {
+				if actual.Inicio.IsZero() {
+					return nil, fmt.Errorf("evento %q sin DTSTART", actual.UID)
+				}
+				if recurrencia != "" {
+					return nil, fmt.Errorf("evento %q recurrente (%s): las recurrencias no se admiten, exporte cada fecha como un evento", actual.UID, recurrencia)
+				}
+				eventos = append(eventos, *actual)
+			}
+			actual = nil
+		case actual == nil:
+			continue
+		case nombre == "BEGIN":
+			anidados++
+		case nombre == "END" && anidados > 0:
+			anidados--
+		case anidados > 0:
+			continue
+		case nombre == "RRULE" || nombre == "RDATE" || nombre == "EXDATE":
+			if recurrencia == "" {
+				recurrencia = nombre
+			}
+		case nombre == "UID":
+			actual.UID = strings.TrimSpace(valor)
+		case nombre == "SUMMARY":
+			actual.Resumen = icalUnescape(valor)
+		case nombre == "DESCRIPTION":
+			actual.Descripcion = icalUnescape(valor)
+		case nombre == "LOCATION":
+			actual.Lugar = icalUnescape(valor)
+		case nombre == "STATUS":
+			actual.Cancelado = strings.EqualFold(strings.TrimSpace(valor), "CANCELLED")
+		case nombre == "DTSTART":
+			fecha, todoElDia, err := parseICalFecha(params, valor)
+			if err != nil {
+				return nil, fmt.Errorf("DTSTART invalido: %s", valor)
+			}
+			actual.Inicio, actual.TodoElDia = fecha, todoElDia
+		case nombre == "DTEND":
+			fecha, _, err := parseICalFecha(params, valor)
+			if err != nil {
+				return nil, fmt.Errorf("DTEND invalido: %s", valor)
+			}
+			actual.Fin = fecha
+		}
+	}
+	if !vistoCalendario {
+		return nil, errors.New("el archivo no es un calendario iCalendar (falta BEGIN:VCALENDAR)")
+	}
+	return eventos, nil
+}
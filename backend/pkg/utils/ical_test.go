@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func calendarioPrueba(lineas ...string) string {
+	return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lineas...), "END:VCALENDAR"), "\r\n") + "\r\n"
+}
+
+func TestParseICalendar(t *testing.T) {
+	casos := []struct {
+		nombre string
+		ics    string
+		want   []ICalEvent
+		err    string
+	}{
+		{
+			nombre: "líneas plegadas y texto escapado",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:salida-1@andaria",
+				"DTSTART;VALUE=DATE:20261120",
+				"SUMMARY:Salar de Uyuni\\, tres días",
+				"DESCRIPTION:Llevar abrigo.\\nSalida desde la",
+				"  plaza principal",
+				"LOCATION:Plaza\\; puerta norte",
+				"END:VEVENT",
+			),
+			want: []ICalEvent{{
+				UID:         "salida-1@andaria",
+				Inicio:      time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC),
+				TodoElDia:   true,
+				Resumen:     "Salar de Uyuni, tres días",
+				Descripcion: "Llevar abrigo.\nSalida desde la plaza principal",
+				Lugar:       "Plaza; puerta norte",
+			}},
+		},
+		{
+			nombre: "DTSTART con TZID",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:tzid",
+				"DTSTART;TZID=America/La_Paz:20261120T073000",
+				"DTEND;TZID=America/La_Paz:20261120T180000",
+				"END:VEVENT",
+			),
+			want: []ICalEvent{{
+				UID:    "tzid",
+				Inicio: time.Date(2026, 11, 20, 7, 30, 0, 0, ICalZona),
+				Fin:    time.Date(2026, 11, 20, 18, 0, 0, 0, ICalZona),
+			}},
+		},
+		{
+			nombre: "DTSTART en UTC",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:utc",
+				"DTSTART:20261120T113000Z",
+				"END:VEVENT",
+			),
+			want: []ICalEvent{{
+				UID:    "utc",
+				Inicio: time.Date(2026, 11, 20, 7, 30, 0, 0, ICalZona),
+			}},
+		},
+		{
+			nombre: "evento de todo el día sin VALUE=DATE",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:dia",
+				"DTSTART:20261120",
+				"DTEND:20261123",
+				"END:VEVENT",
+			),
+			want: []ICalEvent{{
+				UID:       "dia",
+				Inicio:    time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC),
+				Fin:       time.Date(2026, 11, 23, 0, 0, 0, 0, time.UTC),
+				TodoElDia: true,
+			}},
+		},
+		{
+			nombre: "evento sin UID y evento cancelado",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"DTSTART;VALUE=DATE:20261120",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:cancelado",
+				"DTSTART;VALUE=DATE:20261121",
+				"STATUS:CANCELLED",
+				"END:VEVENT",
+			),
+			want: []ICalEvent{
+				{Inicio: time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC), TodoElDia: true},
+				{UID: "cancelado", Inicio: time.Date(2026, 11, 21, 0, 0, 0, 0, time.UTC), TodoElDia: true, Cancelado: true},
+			},
+		},
+		{
+			nombre: "las propiedades de una alarma no pisan las del evento",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:alarma",
+				"DTSTART;VALUE=DATE:20261120",
+				"SUMMARY:Salida",
+				"BEGIN:VALARM",
+				"DESCRIPTION:Recordatorio",
+				"END:VALARM",
+				"END:VEVENT",
+			),
+			want: []ICalEvent{{
+				UID:       "alarma",
+				Inicio:    time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC),
+				TodoElDia: true,
+				Resumen:   "Salida",
+			}},
+		},
+		{
+			nombre: "evento recurrente",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:semanal",
+				"DTSTART;VALUE=DATE:20261120",
+				"RRULE:FREQ=WEEKLY;BYDAY=FR",
+				"END:VEVENT",
+			),
+			err: "recurrente (RRULE)",
+		},
+		{
+			nombre: "evento sin DTSTART",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:sin-fecha",
+				"END:VEVENT",
+			),
+			err: "sin DTSTART",
+		},
+		{
+			nombre: "DTSTART inválido",
+			ics: calendarioPrueba(
+				"BEGIN:VEVENT",
+				"UID:invalido",
+				"DTSTART:2026-11-20",
+				"END:VEVENT",
+			),
+			err: "DTSTART invalido",
+		},
+		{
+			nombre: "archivo que no es un calendario",
+			ics:    "BEGIN:VEVENT\r\nUID:x\r\nDTSTART:20261120\r\nEND:VEVENT\r\n",
+			err:    "falta BEGIN:VCALENDAR",
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			eventos, err := ParseICalendar(strings.NewReader(c.ics))
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("error = %v, se esperaba uno que contenga %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if len(eventos) != len(c.want) {
+				t.Fatalf("se leyeron %d eventos, se esperaban %d: %+v", len(eventos), len(c.want), eventos)
+			}
+			for i, want := range c.want {
+				got := eventos[i]
+				if got.UID != want.UID || got.TodoElDia != want.TodoElDia || got.Cancelado != want.Cancelado ||
+					got.Resumen != want.Resumen || got.Descripcion != want.Descripcion || got.Lugar != want.Lugar {
+					t.Errorf("evento %d = %+v, se esperaba %+v", i, got, want)
+				}
+				if !got.Inicio.Equal(want.Inicio) || !got.Fin.Equal(want.Fin) {
+					t.Errorf("evento %d: inicio %v fin %v, se esperaba inicio %v fin %v", i, got.Inicio, got.Fin, want.Inicio, want.Fin)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildICalendarLeidoPorParseICalendar(t *testing.T) {
+	evento := ICalEvent{
+		UID:         "salida-42@andaria",
+		Inicio:      time.Date(2026, 11, 20, 7, 30, 0, 0, ICalZona),
+		Fin:         time.Date(2026, 11, 20, 18, 0, 0, 0, ICalZona),
+		Resumen:     "Tiwanaku y Puma Punku",
+		Descripcion: strings.Repeat("Recorrido guiado por los templos, ", 5),
+		Lugar:       "Plaza Murillo, La Paz",
+	}
+	eventos, err := ParseICalendar(strings.NewReader(string(BuildICalendar("Salidas", []ICalEvent{evento}))))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(eventos) != 1 {
+		t.Fatalf("se leyeron %d eventos, se esperaba 1", len(eventos))
+	}
+	got := eventos[0]
+	if got.UID != evento.UID || got.Resumen != evento.Resumen || got.Descripcion != evento.Descripcion || got.Lugar != evento.Lugar {
+		t.Errorf("evento = %+v, se esperaba %+v", got, evento)
+	}
+	if !got.Inicio.Equal(evento.Inicio) || !got.Fin.Equal(evento.Fin) {
+		t.Errorf("inicio %v fin %v, se esperaba inicio %v fin %v", got.Inicio, got.Fin, evento.Inicio, evento.Fin)
+	}
+}
@@ -61,7 +61,8 @@ func ValidateToken(tokenString string) (*JWTClaims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+	// Los tokens de voucher comparten el secret pero no sirven como token de sesión
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid && claims.Subject != voucherTokenSubject {
 		return claims, nil
 	}
 
@@ -77,3 +78,60 @@ func RefreshToken(oldToken string, duration time.Duration) (string, error) {
 
 	return GenerateToken(claims.UserID, claims.Email, claims.Rol, duration)
 }
+
+// VoucherClaims identifica el voucher (e-ticket) de una compra confirmada.
+type VoucherClaims struct {
+	CompraID           uint   `json:"compra_id"`
+	CodigoConfirmacion string `json:"codigo_confirmacion"`
+	jwt.RegisteredClaims
+}
+
+const voucherTokenSubject = "voucher"
+
+// GenerateVoucherToken firma el código de confirmación de una compra para el QR del voucher. Vence en
+// expiresAt (después de la salida); antes de eso la validez la determina el estado de la compra al
+// momento del check-in.
+func GenerateVoucherToken(compraID uint, codigoConfirmacion string, expiresAt time.Time) (string, error) {
+	if len(jwtSecret) == 0 {
+		return "", errors.New("JWT secret not initialized")
+	}
+
+	claims := VoucherClaims{
+		CompraID:           compraID,
+		CodigoConfirmacion: codigoConfirmacion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   voucherTokenSubject,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "andaria-backend",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// ValidateVoucherToken valida la firma y el vencimiento de un token de voucher y retorna sus claims.
+// Los tokens sin vencimiento se rechazan; el turista puede descargar de nuevo el voucher.
+func ValidateVoucherToken(tokenString string) (*VoucherClaims, error) {
+	if len(jwtSecret) == 0 {
+		return nil, errors.New("JWT secret not initialized")
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &VoucherClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return jwtSecret, nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*VoucherClaims)
+	if !ok || !token.Valid || claims.Subject != voucherTokenSubject || claims.CodigoConfirmacion == "" {
+		return nil, errors.New("invalid voucher token")
+	}
+
+	return claims, nil
+}
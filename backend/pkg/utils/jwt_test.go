@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const secretoPrueba = "secreto-de-prueba-para-vouchers"
+
+func firmarVoucherPrueba(t *testing.T, claims jwt.Claims, metodo jwt.SigningMethod, clave interface{}) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(metodo, claims).SignedString(clave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidateVoucherToken(t *testing.T) {
+	InitJWT(secretoPrueba)
+	ahora := time.Now()
+	// Un viaje de un día que sale pasado mañana: el voucher vence al terminar el día siguiente a la salida
+	vence := time.Date(ahora.Year(), ahora.Month(), ahora.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 4)
+
+	valido, err := GenerateVoucherToken(42, "AND-7Q2K9X", vence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vencido, err := GenerateVoucherToken(42, "AND-7Q2K9X", ahora.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sesion, err := GenerateToken(42, "turista@example.com", "turista", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partes := strings.Split(valido, ".")
+	// Mismo token con otra compra en el payload y la firma original
+	payload, err := base64.RawURLEncoding.DecodeString(partes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadAlterado := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), `"compra_id":42`, `"compra_id":43`, 1)))
+	if payloadAlterado == partes[1] {
+		t.Fatal("no se pudo alterar el payload del token")
+	}
+	firma := []byte(partes[2])
+	firma[0] ^= 'A' ^ 'B'
+
+	claimsVoucher := func(cambio func(*VoucherClaims)) *VoucherClaims {
+		c := &VoucherClaims{
+			CompraID:           42,
+			CodigoConfirmacion: "AND-7Q2K9X",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   voucherTokenSubject,
+				ExpiresAt: jwt.NewNumericDate(vence),
+				IssuedAt:  jwt.NewNumericDate(ahora),
+			},
+		}
+		cambio(c)
+		return c
+	}
+
+	casos := []struct {
+		nombre string
+		token  string
+		valido bool
+	}{
+		{"voucher vigente", valido, true},
+		{"voucher vencido después de la salida", vencido, false},
+		{"payload alterado", partes[0] + "." + payloadAlterado + "." + partes[2], false},
+		{"firma alterada", partes[0] + "." + partes[1] + "." + string(firma), false},
+		{"sin firma", partes[0] + "." + partes[1] + ".", false},
+		{"firmado con otro secreto", firmarVoucherPrueba(t, claimsVoucher(func(*VoucherClaims) {}), jwt.SigningMethodHS256, []byte("otro-secreto")), false},
+		{"sin vencimiento", firmarVoucherPrueba(t, claimsVoucher(func(c *VoucherClaims) { c.ExpiresAt = nil }), jwt.SigningMethodHS256, []byte(secretoPrueba)), false},
+		{"sin código de confirmación", firmarVoucherPrueba(t, claimsVoucher(func(c *VoucherClaims) { c.CodigoConfirmacion = "" }), jwt.SigningMethodHS256, []byte(secretoPrueba)), false},
+		{"algoritmo none", firmarVoucherPrueba(t, claimsVoucher(func(*VoucherClaims) {}), jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), false},
+		{"token de sesión", sesion, false},
+		{"texto que no es un token", "AND-7Q2K9X", false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			claims, err := ValidateVoucherToken(c.token)
+			if !c.valido {
+				if err == nil {
+					t.Fatalf("se aceptó el token: %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if claims.CompraID != 42 || claims.CodigoConfirmacion != "AND-7Q2K9X" || !claims.ExpiresAt.Time.Equal(vence) {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
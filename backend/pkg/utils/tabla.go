@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Límites de lectura de un .xlsx: tamaño descomprimido de cada parte (evita archivos zip manipulados),
+// filas y columnas de la hoja.
+const (
+	maxParteXLSX    = 32 << 20
+	maxFilasXLSX    = 50000
+	maxColumnasXLSX = 16384
+)
+
+// LeerTablaCSV lee un CSV como filas de celdas. Acepta UTF-8 (con o sin BOM) y Windows-1252, habitual en
+// los archivos exportados por los bancos. Con delimitador 0 se detecta entre coma, punto y coma, tab y |.
+func LeerTablaCSV(contenido []byte, delimitador rune) ([][]string, error) {
+	contenido = bytes.TrimPrefix(contenido, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(contenido) {
+		decodificado, err := charmap.Windows1252.NewDecoder().Bytes(contenido)
+		if err != nil {
+			return nil, err
+		}
+		contenido = decodificado
+	}
+	if delimitador == 0 {
+		delimitador = detectarDelimitadorCSV(contenido)
+	}
+
+	lector := csv.NewReader(bytes.NewReader(contenido))
+	lector.Comma = delimitador
+	lector.FieldsPerRecord = -1
+	lector.LazyQuotes = true
+	lector.TrimLeadingSpace = true
+	return lector.ReadAll()
+}
+
+// detectarDelimitadorCSV elige el delimitador más frecuente en las primeras líneas.
+func detectarDelimitadorCSV(contenido []byte) rune {
+	lineas := bytes.SplitN(contenido, []byte("\n"), 11)
+	if len(lineas) > 10 {
+		lineas = lineas[:10]
+	}
+	muestra := bytes.Join(lineas, []byte("\n"))
+
+	mejor, maximo := ',', 0
+	for _, d := range []rune{',', ';', '\t', '|'} {
+		if n := bytes.Count(muestra, []byte(string(d))); n > maximo {
+			mejor, maximo = d, n
+		}
+	}
+	return mejor
+}
+
+type xlsxLibro struct {
+	Hojas []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelaciones struct {
+	Relaciones []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxTextoEnriquecido struct {
+	Texto string `xml:"t"`
+	Runs  []struct {
+		Texto string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxTextoEnriquecido) String() string {
+	if len(t.Runs) == 0 {
+		return t.Texto
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Texto)
+	}
+	return b.String()
+}
+
+type xlsxTextosCompartidos struct {
+	Items []xlsxTextoEnriquecido `xml:"si"`
+}
+
+type xlsxHoja struct {
+	Filas []struct {
+		Numero int `xml:"r,attr"`
+		Celdas []struct {
+			Ref    string               `xml:"r,attr"`
+			Tipo   string               `xml:"t,attr"`
+			Valor  string               `xml:"v"`
+			Inline xlsxTextoEnriquecido `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func leerParteXLSX(archivos map[string]*zip.File, nombre string, destino interface{}) error {
+	archivo, ok := archivos[nombre]
+	if !ok {
+		return fmt.Errorf("el libro no contiene %s", nombre)
+	}
+	rc, err := archivo.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, maxParteXLSX)).Decode(destino)
+}
+
+// rutaPrimeraHojaXLSX resuelve la primera hoja del libro; si no puede, usa la ruta habitual.
+func rutaPrimeraHojaXLSX(archivos map[string]*zip.File) string {
+	var libro xlsxLibro
+	var relaciones xlsxRelaciones
+	if leerParteXLSX(archivos, "xl/workbook.xml", &libro) != nil || len(libro.Hojas) == 0 ||
+		leerParteXLSX(archivos, "xl/_rels/workbook.xml.rels", &relaciones) != nil {
+		return "xl/worksheets/sheet1.xml"
+	}
+	for _, rel := range relaciones.Relaciones {
+		if rel.ID != libro.Hojas[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+	return "xl/worksheets/sheet1.xml"
+}
+
+// columnaXLSX convierte la referencia de una celda (p. ej. "C12") en el índice de su columna.
+func columnaXLSX(ref string) int {
+	columna := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		columna = columna*26 + int(r-'A') + 1
+	}
+	return columna - 1
+}
+
+// LeerTablaXLSX lee la primera hoja de un libro .xlsx como filas de celdas, respetando la numeración de
+// filas de Excel. Las celdas numéricas se retornan con su valor crudo: punto decimal y, para las
+// fechas, el número de serie de Excel (ver FechaSerialExcel). No admite el formato binario .xls.
+func LeerTablaXLSX(contenido []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(contenido), int64(len(contenido)))
+	if err != nil {
+		return nil, errors.New("el archivo no es un libro .xlsx válido")
+	}
+	archivos := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		archivos[f.Name] = f
+	}
+
+	var compartidos xlsxTextosCompartidos
+	if _, ok := archivos["xl/sharedStrings.xml"]; ok {
+		if err := leerParteXLSX(archivos, "xl/sharedStrings.xml", &compartidos); err != nil {
+			return nil, err
+		}
+	}
+
+	var hoja xlsxHoja
+	if err := leerParteXLSX(archivos, rutaPrimeraHojaXLSX(archivos), &hoja); err != nil {
+		return nil, err
+	}
+
+	var filas [][]string
+	for _, fila := range hoja.Filas {
+		indice := len(filas)
+		if fila.Numero > 0 {
+			indice = fila.Numero - 1
+		}
+		if indice >= maxFilasXLSX {
+			return nil, fmt.Errorf("la hoja supera las %d filas", maxFilasXLSX)
+		}
+		for len(filas) <= indice {
+			filas = append(filas, nil)
+		}
+
+		var celdas []string
+		for i, celda := range fila.Celdas {
+			columna := i
+			if celda.Ref != "" {
+				columna = columnaXLSX(celda.Ref)
+			}
+			if columna < 0 || columna >= maxColumnasXLSX {
+				continue
+			}
+			for len(celdas) <= columna {
+				celdas = append(celdas, "")
+			}
+
+			valor := celda.Valor
+			switch celda.Tipo {
+			case "s":
+				idx, err := strconv.Atoi(strings.TrimSpace(celda.Valor))
+				if err != nil || idx < 0 || idx >= len(compartidos.Items) {
+					return nil, fmt.Errorf("celda %s: texto compartido inválido", celda.Ref)
+				}
+				valor = compartidos.Items[idx].String()
+			case "inlineStr":
+				valor = celda.Inline.String()
+			case "b":
+				valor = strings.ToUpper(strconv.FormatBool(celda.Valor == "1"))
+			case "e":
+				valor = ""
+			}
+			celdas[columna] = valor
+		}
+		filas[indice] = celdas
+	}
+	return filas, nil
+}
+
+// FechaSerialExcel convierte un número de serie de fecha de Excel (sistema 1900) a fecha UTC.
+func FechaSerialExcel(serial float64) time.Time {
+	dias := math.Floor(serial)
+	segundos := math.Round((serial - dias) * 24 * 60 * 60)
+	return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).
+		AddDate(0, 0, int(dias)).
+		Add(time.Duration(segundos) * time.Second)
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLeerTablaCSV(t *testing.T) {
+	casos := []struct {
+		nombre      string
+		contenido   string
+		delimitador rune
+		want        [][]string
+	}{
+		{
+			nombre:    "coma detectada",
+			contenido: "Fecha,Monto,Glosa\n15/11/2026,350.50,Pago AND-42\n",
+			want:      [][]string{{"Fecha", "Monto", "Glosa"}, {"15/11/2026", "350.50", "Pago AND-42"}},
+		},
+		{
+			nombre:    "punto y coma con coma decimal y BOM",
+			contenido: "\xef\xbb\xbfFecha;Monto;Glosa\r\n15/11/2026;1.234,56;Pago AND-42\r\n",
+			want:      [][]string{{"Fecha", "Monto", "Glosa"}, {"15/11/2026", "1.234,56", "Pago AND-42"}},
+		},
+		{
+			nombre:    "tab detectado",
+			contenido: "Fecha\tMonto\n15/11/2026\t350,50\n",
+			want:      [][]string{{"Fecha", "Monto"}, {"15/11/2026", "350,50"}},
+		},
+		{
+			nombre:    "barra vertical detectada",
+			contenido: "Fecha|Crédito|Débito\n15/11/2026|350,50|\n16/11/2026||(80,00)\n",
+			want:      [][]string{{"Fecha", "Crédito", "Débito"}, {"15/11/2026", "350,50", ""}, {"16/11/2026", "", "(80,00)"}},
+		},
+		{
+			nombre:      "delimitador indicado aunque otro sea más frecuente",
+			contenido:   "Fecha;Glosa\n15/11/2026;Pago, cuota 1, anticipo\n",
+			delimitador: ';',
+			want:        [][]string{{"Fecha", "Glosa"}, {"15/11/2026", "Pago, cuota 1, anticipo"}},
+		},
+		{
+			nombre:    "campos entre comillas con el delimitador",
+			contenido: "Fecha,Monto,Glosa\n15/11/2026,\"1,234.56\",\"Depósito \"\"QR\"\"\"\n",
+			want:      [][]string{{"Fecha", "Monto", "Glosa"}, {"15/11/2026", "1,234.56", "Depósito \"QR\""}},
+		},
+		{
+			nombre:    "Windows-1252",
+			contenido: "Fecha;Descripci\xf3n\n15/11/2026;Dep\xf3sito a\xf1o\n",
+			want:      [][]string{{"Fecha", "Descripción"}, {"15/11/2026", "Depósito año"}},
+		},
+		{
+			nombre:    "filas de distinto largo y espacios iniciales",
+			contenido: "BANCO DE PRUEBA\nFecha, Monto, Glosa\n15/11/2026, 350.50, Pago\n",
+			want:      [][]string{{"BANCO DE PRUEBA"}, {"Fecha", "Monto", "Glosa"}, {"15/11/2026", "350.50", "Pago"}},
+		},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			filas, err := LeerTablaCSV([]byte(c.contenido), c.delimitador)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if !reflect.DeepEqual(filas, c.want) {
+				t.Errorf("filas = %q, se esperaban %q", filas, c.want)
+			}
+		})
+	}
+}